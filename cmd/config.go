@@ -7,15 +7,25 @@ import (
 
 	"github.com/spf13/viper"
 	"github.com/xataio/pgstream/internal/backoff"
+	"github.com/xataio/pgstream/internal/blobstore/local"
+	"github.com/xataio/pgstream/internal/blobstore/s3"
+	"github.com/xataio/pgstream/internal/es"
 	"github.com/xataio/pgstream/internal/kafka"
 	"github.com/xataio/pgstream/internal/tls"
 	pgschemalog "github.com/xataio/pgstream/pkg/schemalog/postgres"
 	"github.com/xataio/pgstream/pkg/stream"
 	kafkacheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/kafka"
+	"github.com/xataio/pgstream/pkg/wal/claimcheck"
 	kafkalistener "github.com/xataio/pgstream/pkg/wal/listener/kafka"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
+	deadletterfile "github.com/xataio/pgstream/pkg/wal/processor/search/deadletter/file"
+	deadletterpg "github.com/xataio/pgstream/pkg/wal/processor/search/deadletter/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/elasticsearch"
 	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
+	relationspg "github.com/xataio/pgstream/pkg/wal/processor/search/relations/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/typesense"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/verify"
 	"github.com/xataio/pgstream/pkg/wal/processor/translator"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/server"
@@ -95,10 +105,44 @@ func parseKafkaReaderConfig(kafkaServers []string, kafkaTopic, consumerGroupID s
 				Topic: kafka.TopicConfig{
 					Name: kafkaTopic,
 				},
-				TLS: parseTLSConfig("PGSTREAM_KAFKA"),
+				TLS:  parseTLSConfig("PGSTREAM_KAFKA"),
+				SASL: parseSASLConfig("PGSTREAM_KAFKA"),
 			},
 			ConsumerGroupID:          consumerGroupID,
 			ConsumerGroupStartOffset: viper.GetString("PGSTREAM_KAFKA_READER_CONSUMER_GROUP_START_OFFSET"),
+			ReadCommitted:            viper.GetBool("PGSTREAM_KAFKA_READER_READ_COMMITTED"),
+		},
+		RetryBackoff: parseBackoffConfig("PGSTREAM_KAFKA_READER_RETRY"),
+		DeadLetter:   parseKafkaDeadLetterConfig(kafkaServers),
+		Parallel:     parseKafkaParallelConfig(),
+		ClaimCheck:   parseClaimCheckConfig(),
+	}
+}
+
+func parseKafkaParallelConfig() *kafkalistener.ParallelConfig {
+	if !viper.GetBool("PGSTREAM_KAFKA_READER_PARTITION_WORKERS_ENABLED") {
+		return nil
+	}
+	return &kafkalistener.ParallelConfig{
+		MaxInFlightMessages: viper.GetInt("PGSTREAM_KAFKA_READER_MAX_IN_FLIGHT_MESSAGES"),
+	}
+}
+
+func parseKafkaDeadLetterConfig(kafkaServers []string) *kafkalistener.DeadLetterConfig {
+	topic := viper.GetString("PGSTREAM_KAFKA_READER_DEAD_LETTER_TOPIC_NAME")
+	if topic == "" {
+		return nil
+	}
+
+	return &kafkalistener.DeadLetterConfig{
+		Kafka: kafka.ConnConfig{
+			Servers: kafkaServers,
+			Topic: kafka.TopicConfig{
+				Name:       topic,
+				AutoCreate: viper.GetBool("PGSTREAM_KAFKA_READER_DEAD_LETTER_TOPIC_AUTO_CREATE"),
+			},
+			TLS:  parseTLSConfig("PGSTREAM_KAFKA"),
+			SASL: parseSASLConfig("PGSTREAM_KAFKA"),
 		},
 	}
 }
@@ -123,7 +167,10 @@ func parseProcessorConfig() stream.ProcessorConfig {
 
 func parseKafkaProcessorConfig() *stream.KafkaProcessorConfig {
 	kafkaServers := viper.GetStringSlice("PGSTREAM_KAFKA_SERVERS")
-	kafkaTopic := viper.GetString("PGSTREAM_KAFKA_TOPIC_NAME")
+	kafkaTopic := viper.GetString("PGSTREAM_KAFKA_WRITER_TOPIC_NAME")
+	if kafkaTopic == "" {
+		kafkaTopic = viper.GetString("PGSTREAM_KAFKA_TOPIC_NAME")
+	}
 	topicPartitions := viper.GetInt("PGSTREAM_KAFKA_TOPIC_PARTITIONS")
 	if len(kafkaServers) == 0 || kafkaTopic == "" || topicPartitions == 0 {
 		return nil
@@ -144,15 +191,59 @@ func parseKafkaWriterConfig(kafkaServers []string, kafkaTopic string) *kafkaproc
 				ReplicationFactor: viper.GetInt("PGSTREAM_KAFKA_TOPIC_REPLICATION_FACTOR"),
 				AutoCreate:        viper.GetBool("PGSTREAM_KAFKA_TOPIC_AUTO_CREATE"),
 			},
-			TLS: parseTLSConfig("PGSTREAM_KAFKA"),
+			TLS:  parseTLSConfig("PGSTREAM_KAFKA"),
+			SASL: parseSASLConfig("PGSTREAM_KAFKA"),
 		},
 		BatchTimeout:  viper.GetDuration("PGSTREAM_KAFKA_WRITER_BATCH_TIMEOUT"),
 		BatchBytes:    viper.GetInt64("PGSTREAM_KAFKA_WRITER_BATCH_BYTES"),
 		BatchSize:     viper.GetInt("PGSTREAM_KAFKA_WRITER_BATCH_SIZE"),
 		MaxQueueBytes: viper.GetInt64("PGSTREAM_KAFKA_WRITER_MAX_QUEUE_BYTES"),
+		Transaction:   parseKafkaTransactionConfig(),
+		ClaimCheck:    parseClaimCheckConfig(),
+	}
+}
+
+func parseKafkaTransactionConfig() *kafkaprocessor.TransactionConfig {
+	transactionalID := viper.GetString("PGSTREAM_KAFKA_WRITER_TRANSACTIONAL_ID")
+	if transactionalID == "" {
+		return nil
+	}
+
+	return &kafkaprocessor.TransactionConfig{
+		ID:              transactionalID,
+		ConsumerGroupID: viper.GetString("PGSTREAM_KAFKA_READER_CONSUMER_GROUP_ID"),
 	}
 }
 
+func parseClaimCheckConfig() *claimcheck.Config {
+	localDir := viper.GetString("PGSTREAM_CLAIM_CHECK_LOCAL_DIR")
+	s3Bucket := viper.GetString("PGSTREAM_CLAIM_CHECK_S3_BUCKET")
+	if localDir == "" && s3Bucket == "" {
+		return nil
+	}
+
+	cfg := &claimcheck.Config{
+		KeyPrefix: viper.GetString("PGSTREAM_CLAIM_CHECK_KEY_PREFIX"),
+	}
+	if localDir != "" {
+		cfg.Local = &local.Config{
+			Dir: localDir,
+		}
+	}
+	if s3Bucket != "" {
+		cfg.S3 = &s3.Config{
+			Endpoint:        viper.GetString("PGSTREAM_CLAIM_CHECK_S3_ENDPOINT"),
+			Region:          viper.GetString("PGSTREAM_CLAIM_CHECK_S3_REGION"),
+			Bucket:          s3Bucket,
+			UsePathStyle:    viper.GetBool("PGSTREAM_CLAIM_CHECK_S3_USE_PATH_STYLE"),
+			AccessKeyID:     viper.GetString("PGSTREAM_CLAIM_CHECK_S3_ACCESS_KEY_ID"),
+			SecretAccessKey: viper.GetString("PGSTREAM_CLAIM_CHECK_S3_SECRET_ACCESS_KEY"),
+			SessionToken:    viper.GetString("PGSTREAM_CLAIM_CHECK_S3_SESSION_TOKEN"),
+		}
+	}
+	return cfg
+}
+
 func parseSearchProcessorConfig() *stream.SearchProcessorConfig {
 	searchStore := viper.GetString("PGSTREAM_SEARCH_STORE_URL")
 	if searchStore == "" {
@@ -161,17 +252,116 @@ func parseSearchProcessorConfig() *stream.SearchProcessorConfig {
 
 	return &stream.SearchProcessorConfig{
 		Indexer: search.IndexerConfig{
-			BatchSize:      viper.GetInt("PGSTREAM_SEARCH_INDEXER_BATCH_SIZE"),
-			BatchTime:      viper.GetDuration("PGSTREAM_SEARCH_INDEXER_BATCH_TIMEOUT"),
-			MaxQueueBytes:  viper.GetInt64("PGSTREAM_SEARCH_INDEXER_MAX_QUEUE_BYTES"),
-			CleanupBackoff: parseBackoffConfig("PGSTREAM_SEARCH_INDEXER_CLEANUP"),
-		},
-		Store: opensearch.Config{
-			URL: searchStore,
+			BatchSize:       viper.GetInt("PGSTREAM_SEARCH_INDEXER_BATCH_SIZE"),
+			BatchTime:       viper.GetDuration("PGSTREAM_SEARCH_INDEXER_BATCH_TIMEOUT"),
+			MaxQueueBytes:   viper.GetInt64("PGSTREAM_SEARCH_INDEXER_MAX_QUEUE_BYTES"),
+			BulkConcurrency: viper.GetInt("PGSTREAM_SEARCH_INDEXER_BULK_CONCURRENCY"),
+			CleanupBackoff:  parseBackoffConfig("PGSTREAM_SEARCH_INDEXER_CLEANUP"),
+
+			DeadLetterIgnored: viper.GetBool("PGSTREAM_SEARCH_INDEXER_DEAD_LETTER_IGNORED"),
+			SoftDelete: search.SoftDeleteConfig{
+				Tables:        viper.GetStringSlice("PGSTREAM_SEARCH_INDEXER_SOFT_DELETE_TABLES"),
+				Retention:     viper.GetDuration("PGSTREAM_SEARCH_INDEXER_SOFT_DELETE_RETENTION"),
+				PurgeInterval: viper.GetDuration("PGSTREAM_SEARCH_INDEXER_SOFT_DELETE_PURGE_INTERVAL"),
+			},
 		},
+		Store: parseSearchStoreConfig(searchStore),
 		Retrier: &search.StoreRetryConfig{
 			Backoff: parseBackoffConfig("PGSTREAM_SEARCH_STORE"),
 		},
+		DeadLetter: parseSearchDeadLetterConfig(),
+		Verify:     parseSearchVerifyConfig(),
+
+		Denormalisation: parseSearchDenormalisationConfig(),
+	}
+}
+
+func parseSearchDenormalisationConfig() *stream.SearchDenormalisationConfig {
+	configFile := viper.GetString("PGSTREAM_SEARCH_DENORMALISATION_CONFIG_FILE")
+	if configFile == "" {
+		return nil
+	}
+	return &stream.SearchDenormalisationConfig{
+		Postgres:   relationspg.Config{URL: pgURL()},
+		ConfigFile: configFile,
+	}
+}
+
+func parseSearchVerifyConfig() *verify.Config {
+	return &verify.Config{
+		PostgresURL:   pgURL(),
+		Schemas:       viper.GetStringSlice("PGSTREAM_SEARCH_VERIFY_SCHEMAS"),
+		SampleSize:    viper.GetInt("PGSTREAM_SEARCH_VERIFY_SAMPLE_SIZE"),
+		BatchSize:     viper.GetInt("PGSTREAM_SEARCH_VERIFY_BATCH_SIZE"),
+		VersionColumn: viper.GetString("PGSTREAM_SEARCH_VERIFY_VERSION_COLUMN"),
+		Repair:        viper.GetBool("PGSTREAM_SEARCH_VERIFY_REPAIR"),
+		Interval:      viper.GetDuration("PGSTREAM_SEARCH_VERIFY_INTERVAL"),
+	}
+}
+
+func parseSearchDeadLetterConfig() *stream.SearchDeadLetterConfig {
+	file := viper.GetString("PGSTREAM_SEARCH_DEAD_LETTER_FILE")
+	pgURL := viper.GetString("PGSTREAM_SEARCH_DEAD_LETTER_POSTGRES_URL")
+	index := viper.GetString("PGSTREAM_SEARCH_DEAD_LETTER_INDEX")
+	if file == "" && pgURL == "" && index == "" {
+		return nil
+	}
+
+	cfg := &stream.SearchDeadLetterConfig{
+		Index: index,
+	}
+	if file != "" {
+		cfg.File = &deadletterfile.Config{Path: file}
+	}
+	if pgURL != "" {
+		cfg.Postgres = &deadletterpg.Config{URL: pgURL}
+	}
+	return cfg
+}
+
+func parseSearchStoreConfig(url string) stream.SearchStoreConfig {
+	switch viper.GetString("PGSTREAM_SEARCH_STORE_ENGINE") {
+	case "elasticsearch":
+		return stream.SearchStoreConfig{
+			Elasticsearch: &elasticsearch.Config{
+				URL:      url,
+				Username: viper.GetString("PGSTREAM_SEARCH_STORE_USERNAME"),
+				Password: viper.GetString("PGSTREAM_SEARCH_STORE_PASSWORD"),
+				APIKey:   viper.GetString("PGSTREAM_SEARCH_STORE_API_KEY"),
+				TLS:      parseTLSConfig("PGSTREAM_SEARCH_STORE"),
+
+				IndexLayout:       opensearch.IndexLayout(viper.GetString("PGSTREAM_SEARCH_STORE_INDEX_LAYOUT")),
+				MappingConfigFile: viper.GetString("PGSTREAM_SEARCH_STORE_MAPPING_CONFIG_FILE"),
+			},
+		}
+	case "typesense":
+		return stream.SearchStoreConfig{
+			Typesense: &typesense.Config{
+				URL:    url,
+				APIKey: viper.GetString("PGSTREAM_SEARCH_STORE_API_KEY"),
+
+				IndexLayout:       viper.GetString("PGSTREAM_SEARCH_STORE_INDEX_LAYOUT"),
+				MappingConfigFile: viper.GetString("PGSTREAM_SEARCH_STORE_MAPPING_CONFIG_FILE"),
+			},
+		}
+	case "", "opensearch":
+		return stream.SearchStoreConfig{
+			OpenSearch: &opensearch.Config{
+				URL:      url,
+				Username: viper.GetString("PGSTREAM_SEARCH_STORE_USERNAME"),
+				Password: viper.GetString("PGSTREAM_SEARCH_STORE_PASSWORD"),
+				APIKey:   viper.GetString("PGSTREAM_SEARCH_STORE_API_KEY"),
+				TLS:      parseTLSConfig("PGSTREAM_SEARCH_STORE"),
+				AWSSigV4: parseSearchStoreSigV4Config(),
+
+				IndexLayout:       opensearch.IndexLayout(viper.GetString("PGSTREAM_SEARCH_STORE_INDEX_LAYOUT")),
+				MappingConfigFile: viper.GetString("PGSTREAM_SEARCH_STORE_MAPPING_CONFIG_FILE"),
+			},
+		}
+	default:
+		// unsupported engine, leave the store unset so that the config
+		// validation fails
+		return stream.SearchStoreConfig{}
 	}
 }
 
@@ -188,9 +378,16 @@ func parseWebhookProcessorConfig() *stream.WebhookProcessorConfig {
 			CacheRefreshInterval: viper.GetDuration("PGSTREAM_WEBHOOK_SUBSCRIPTION_STORE_CACHE_REFRESH_INTERVAL"),
 		},
 		Notifier: notifier.Config{
-			MaxQueueBytes:  viper.GetInt64("PGSTREAM_WEBHOOK_NOTIFIER_MAX_QUEUE_BYTES"),
-			URLWorkerCount: viper.GetUint("PGSTREAM_WEBHOOK_NOTIFIER_WORKER_COUNT"),
-			ClientTimeout:  viper.GetDuration("PGSTREAM_WEBHOOK_NOTIFIER_CLIENT_TIMEOUT"),
+			MaxQueueBytes:        viper.GetInt64("PGSTREAM_WEBHOOK_NOTIFIER_MAX_QUEUE_BYTES"),
+			URLWorkerCount:       viper.GetUint("PGSTREAM_WEBHOOK_NOTIFIER_WORKER_COUNT"),
+			ClientTimeout:        viper.GetDuration("PGSTREAM_WEBHOOK_NOTIFIER_CLIENT_TIMEOUT"),
+			RetryBackoff:         parseBackoffConfig("PGSTREAM_WEBHOOK_NOTIFIER_RETRY"),
+			RetryTimeout:         viper.GetDuration("PGSTREAM_WEBHOOK_NOTIFIER_RETRY_TIMEOUT"),
+			RedriveInterval:      viper.GetDuration("PGSTREAM_WEBHOOK_NOTIFIER_REDRIVE_INTERVAL"),
+			RedriveBatchSize:     viper.GetUint("PGSTREAM_WEBHOOK_NOTIFIER_REDRIVE_BATCH_SIZE"),
+			RetryMaxAge:          viper.GetDuration("PGSTREAM_WEBHOOK_NOTIFIER_RETRY_MAX_AGE"),
+			FailedRetention:      viper.GetDuration("PGSTREAM_WEBHOOK_NOTIFIER_FAILED_RETENTION"),
+			DeliveryLogRetention: viper.GetDuration("PGSTREAM_WEBHOOK_NOTIFIER_DELIVERY_LOG_RETENTION"),
 		},
 		SubscriptionServer: server.Config{
 			Address:      viper.GetString("PGSTREAM_WEBHOOK_SUBSCRIPTION_SERVER_ADDRESS"),
@@ -245,6 +442,19 @@ func parseTranslatorConfig() *translator.Config {
 	}
 }
 
+func parseSearchStoreSigV4Config() *es.SigV4Config {
+	if !viper.GetBool("PGSTREAM_SEARCH_STORE_AWS_SIGV4_ENABLED") {
+		return nil
+	}
+	return &es.SigV4Config{
+		Region:          viper.GetString("PGSTREAM_SEARCH_STORE_AWS_REGION"),
+		Service:         viper.GetString("PGSTREAM_SEARCH_STORE_AWS_SERVICE"),
+		AccessKeyID:     viper.GetString("PGSTREAM_SEARCH_STORE_AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: viper.GetString("PGSTREAM_SEARCH_STORE_AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    viper.GetString("PGSTREAM_SEARCH_STORE_AWS_SESSION_TOKEN"),
+	}
+}
+
 func parseTLSConfig(prefix string) *tls.Config {
 	return &tls.Config{
 		Enabled:        viper.GetBool(fmt.Sprintf("%s_TLS_ENABLED", prefix)),
@@ -253,3 +463,15 @@ func parseTLSConfig(prefix string) *tls.Config {
 		ClientKeyFile:  viper.GetString(fmt.Sprintf("%s_TLS_CLIENT_KEY_FILE", prefix)),
 	}
 }
+
+func parseSASLConfig(prefix string) *kafka.SASLConfig {
+	mechanism := viper.GetString(fmt.Sprintf("%s_SASL_MECHANISM", prefix))
+	if mechanism == "" {
+		return nil
+	}
+	return &kafka.SASLConfig{
+		Mechanism: kafka.SASLMechanism(mechanism),
+		Username:  viper.GetString(fmt.Sprintf("%s_SASL_USERNAME", prefix)),
+		Password:  viper.GetString(fmt.Sprintf("%s_SASL_PASSWORD", prefix)),
+	}
+}
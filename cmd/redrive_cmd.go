@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xataio/pgstream/internal/log/zerolog"
+	"github.com/xataio/pgstream/pkg/stream"
+)
+
+var redriveCmd = &cobra.Command{
+	Use:   "redrive",
+	Short: "Redrive sends the events kept in a dead letter store to their destination again",
+}
+
+var redriveSearchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Sends the documents in the search dead letter store to the search store again, removing the ones that are indexed",
+	Long:  "Sends the documents in the search dead letter store to the search store again, removing the ones that are indexed. It exits with an error if any of the documents fail again, which are kept in the dead letter store.",
+	RunE:  withSignalWatcher(redriveSearch),
+}
+
+func redriveSearch(ctx context.Context) error {
+	logger := zerolog.NewLogger(&zerolog.Config{
+		LogLevel: viper.GetString("PGSTREAM_LOG_LEVEL"),
+	})
+	zerolog.SetGlobalLogger(logger)
+
+	sp, _ := pterm.DefaultSpinner.WithText("redriving search dead letters...").Start()
+
+	result, err := stream.RedriveSearchDeadLetters(ctx, zerolog.NewStdLogger(logger), parseSearchProcessorConfig())
+	if err != nil {
+		sp.Fail(err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("search dead letters redriven: %d indexed, %d superseded, %d failed", result.Indexed, result.Superseded, result.Failed)
+	if result.Failed > 0 {
+		sp.Fail(msg)
+		return errors.New(msg)
+	}
+	sp.Success(msg)
+	return nil
+}
@@ -45,6 +45,10 @@ func Execute() error {
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(tearDownCmd)
 	rootCmd.AddCommand(runCmd)
+	redriveCmd.AddCommand(redriveSearchCmd)
+	rootCmd.AddCommand(redriveCmd)
+	verifyCmd.AddCommand(verifySearchCmd)
+	rootCmd.AddCommand(verifyCmd)
 
 	return rootCmd.Execute()
 }
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xataio/pgstream/internal/log/zerolog"
+	"github.com/xataio/pgstream/pkg/stream"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/verify"
+)
+
+func init() {
+	verifySearchCmd.Flags().Bool("repair", false, "re-emit the events of the missing and stale documents, and delete the extra documents")
+	verifySearchCmd.Flags().Int("sample-size", 0, "number of random rows and documents compared per table, all of them if 0")
+
+	viper.BindPFlag("PGSTREAM_SEARCH_VERIFY_REPAIR", verifySearchCmd.Flags().Lookup("repair"))
+	viper.BindPFlag("PGSTREAM_SEARCH_VERIFY_SAMPLE_SIZE", verifySearchCmd.Flags().Lookup("sample-size"))
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify checks the consistency between postgres and the stream destination",
+}
+
+var verifySearchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Compares the postgres tables with the search store documents, reporting the missing, extra and stale documents",
+	Long:  "Compares the postgres tables with the search store documents, reporting the missing, extra and stale documents. It exits with an error if any table has drifted or couldn't be verified, including when the drift is repaired, so that it can be used as a scheduled check.",
+	RunE:  withSignalWatcher(verifySearch),
+}
+
+func verifySearch(ctx context.Context) error {
+	logger := zerolog.NewLogger(&zerolog.Config{
+		LogLevel: viper.GetString("PGSTREAM_LOG_LEVEL"),
+	})
+	zerolog.SetGlobalLogger(logger)
+
+	sp, _ := pterm.DefaultSpinner.WithText("verifying search documents...").Start()
+
+	report, err := stream.VerifySearch(ctx, zerolog.NewStdLogger(logger), parseSearchProcessorConfig())
+	if err != nil {
+		sp.Fail(err.Error())
+		return err
+	}
+
+	if !report.Drifted() {
+		sp.Success(fmt.Sprintf("search documents verified: %d tables consistent", len(report.Tables)))
+		return nil
+	}
+	sp.Warning("search documents drifted")
+	if err := renderVerifyReport(report); err != nil {
+		return err
+	}
+	return fmt.Errorf("search documents drifted in %d of %d tables", driftedTables(report), len(report.Tables))
+}
+
+func driftedTables(report *verify.Report) int {
+	drifted := 0
+	for _, t := range report.Tables {
+		if t.Err != nil || t.Drifted() {
+			drifted++
+		}
+	}
+	return drifted
+}
+
+func renderVerifyReport(report *verify.Report) error {
+	data := [][]string{
+		{"schema", "table", "rows", "documents", "missing", "extra", "stale", "repaired", "details"},
+	}
+	for _, t := range report.Tables {
+		if t.Err == nil && !t.Drifted() {
+			continue
+		}
+		details := driftExamples(&t)
+		if t.Err != nil {
+			details = t.Err.Error()
+		}
+		data = append(data, []string{
+			t.Schema,
+			t.Table,
+			strconv.Itoa(t.Rows),
+			strconv.Itoa(t.Documents),
+			strconv.Itoa(t.Missing.Count),
+			strconv.Itoa(t.Extra.Count),
+			strconv.Itoa(t.Stale.Count),
+			strconv.Itoa(t.Repaired),
+			details,
+		})
+	}
+	return pterm.DefaultTable.WithHasHeader().WithData(data).Render()
+}
+
+// driftExamples returns a few of the drifted document ids of the table, to keep
+// the report readable.
+func driftExamples(t *verify.TableReport) string {
+	const maxExamples = 5
+	examples := []string{}
+	for _, drift := range []struct {
+		kind string
+		ids  []string
+	}{
+		{kind: "missing", ids: t.Missing.IDs},
+		{kind: "extra", ids: t.Extra.IDs},
+		{kind: "stale", ids: t.Stale.IDs},
+	} {
+		for _, id := range drift.ids {
+			if len(examples) == maxExamples {
+				return strings.Join(examples, ", ") + ", ..."
+			}
+			examples = append(examples, fmt.Sprintf("%s (%s)", id, drift.kind))
+		}
+	}
+	return strings.Join(examples, ", ")
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signer signs http requests using the AWS Signature Version 4 signing
+// process.
+type Signer struct {
+	credentials Credentials
+	region      string
+	service     string
+	clock       func() time.Time
+}
+
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only required for temporary credentials.
+	SessionToken string
+}
+
+// EnvCredentials returns the credentials set in the standard AWS environment
+// variables: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func EnvCredentials() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+const (
+	algorithm         = "AWS4-HMAC-SHA256"
+	amzDateFormat     = "20060102T150405Z"
+	shortDateFormat   = "20060102"
+	serviceS3         = "s3"
+	headerAmzDate     = "X-Amz-Date"
+	headerContentHash = "X-Amz-Content-Sha256"
+	headerToken       = "X-Amz-Security-Token"
+	headerAuth        = "Authorization"
+)
+
+// headers that can be modified by proxies or the http client, and are
+// therefore not signed
+var ignoredHeaders = map[string]struct{}{
+	"authorization":   {},
+	"user-agent":      {},
+	"x-amzn-trace-id": {},
+	"content-length":  {},
+}
+
+func NewSigner(credentials Credentials, region, service string) *Signer {
+	return &Signer{
+		credentials: credentials,
+		region:      region,
+		service:     service,
+		clock:       time.Now,
+	}
+}
+
+// Sign adds the authorization headers to the request on input. The body must
+// be the request payload, or nil if there's none.
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	if s.credentials.AccessKeyID == "" || s.credentials.SecretAccessKey == "" {
+		return fmt.Errorf("sigv4: missing credentials")
+	}
+
+	now := s.clock().UTC()
+	amzDate := now.Format(amzDateFormat)
+	shortDate := now.Format(shortDateFormat)
+	payloadHash := hashHex(body)
+
+	req.Header.Set(headerAmzDate, amzDate)
+	req.Header.Set(headerContentHash, payloadHash)
+	if s.credentials.SessionToken != "" {
+		req.Header.Set(headerToken, s.credentials.SessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := s.canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{shortDate, s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		algorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(s.signingKey(shortDate), []byte(stringToSign)))
+	req.Header.Set(headerAuth, fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, s.credentials.AccessKeyID, scope, signedHeaders, signature))
+
+	return nil
+}
+
+func (s *Signer) signingKey(shortDate string) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.credentials.SecretAccessKey), []byte(shortDate))
+	key = hmacSHA256(key, []byte(s.region))
+	key = hmacSHA256(key, []byte(s.service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+// canonicalURI returns the URI encoded path. All services other than S3
+// require the path to be encoded twice.
+func (s *Signer) canonicalURI(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	path := uriEncode(u.Path, false)
+	if s.service == serviceS3 {
+		return path
+	}
+	return uriEncode(path, false)
+}
+
+func (s *Signer) canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": strings.TrimSpace(host)}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if _, ignored := ignoredHeaders[name]; ignored {
+			continue
+		}
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonical := &strings.Builder{}
+	for _, name := range names {
+		canonical.WriteString(name)
+		canonical.WriteString(":")
+		canonical.WriteString(headers[name])
+		canonical.WriteString("\n")
+	}
+
+	return strings.Join(names, ";"), canonical.String()
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	pairs := make([]string, 0, len(query))
+	for key, values := range query {
+		encodedKey := uriEncode(key, true)
+		for _, v := range values {
+			pairs = append(pairs, encodedKey+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes every byte except the unreserved characters, as required
+// by the signing process. The slash is only encoded when encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xataio/pgstream/internal/blobstore"
+)
+
+// Store is a blob store backed by a local filesystem directory. Each blob is
+// stored as a file, with the key as its path relative to the directory.
+type Store struct {
+	dir string
+}
+
+type Config struct {
+	// Dir is the directory where the blobs are stored. It's created if it
+	// doesn't exist.
+	Dir string
+}
+
+const (
+	dirPerm  = 0o750
+	filePerm = 0o640
+)
+
+func NewStore(cfg *Config) (*Store, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("local blob store: directory is required")
+	}
+	if err := os.MkdirAll(cfg.Dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("local blob store: creating directory: %w", err)
+	}
+	return &Store{dir: cfg.Dir}, nil
+}
+
+// Put writes the data for the key. The file is written to a temporary
+// location first and then renamed, so that partially written blobs are never
+// read.
+func (s *Store) Put(_ context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return fmt.Errorf("local blob store: creating directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("local blob store: creating file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("local blob store: writing file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("local blob store: closing file: %w", err)
+	}
+	if err := os.Chmod(tmpFile.Name(), filePerm); err != nil {
+		return fmt.Errorf("local blob store: setting file permissions: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return fmt.Errorf("local blob store: renaming file: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, blobstore.ErrNotFound
+		}
+		return nil, fmt.Errorf("local blob store: reading file: %w", err)
+	}
+	return data, nil
+}
+
+func (s *Store) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("local blob store: removing file: %w", err)
+	}
+	return nil
+}
+
+// path returns the file path for the key, making sure it doesn't point
+// outside of the store directory.
+func (s *Store) path(key string) (string, error) {
+	cleanKey := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(cleanKey) || cleanKey == ".." ||
+		strings.HasPrefix(cleanKey, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("local blob store: invalid key: %q", key)
+	}
+	return filepath.Join(s.dir, cleanKey), nil
+}
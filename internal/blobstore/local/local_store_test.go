@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package local
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/blobstore"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store, err := NewStore(&Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	const testKey = "public/test/1.json"
+	testData := []byte(`{"id":1}`)
+
+	_, err = store.Get(ctx, testKey)
+	require.ErrorIs(t, err, blobstore.ErrNotFound)
+
+	require.NoError(t, store.Put(ctx, testKey, testData))
+
+	data, err := store.Get(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, testData, data)
+
+	require.NoError(t, store.Delete(ctx, testKey))
+	_, err = store.Get(ctx, testKey)
+	require.ErrorIs(t, err, blobstore.ErrNotFound)
+
+	// deleting a missing blob is not an error
+	require.NoError(t, store.Delete(ctx, testKey))
+}
+
+func TestStore_path(t *testing.T) {
+	t.Parallel()
+
+	store := &Store{dir: "/blobs"}
+
+	tests := []struct {
+		name string
+		key  string
+
+		wantPath string
+		wantErr  bool
+	}{
+		{
+			name:     "ok",
+			key:      "public/test/1.json",
+			wantPath: "/blobs/public/test/1.json",
+		},
+		{
+			name:     "ok - relative path within dir",
+			key:      "public/../test.json",
+			wantPath: "/blobs/test.json",
+		},
+		{
+			name:    "error - empty key",
+			key:     "",
+			wantErr: true,
+		},
+		{
+			name:    "error - outside of dir",
+			key:     "../test.json",
+			wantErr: true,
+		},
+		{
+			name:    "error - absolute path",
+			key:     "/etc/passwd",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path, err := store.path(tc.key)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantPath, path)
+		})
+	}
+}
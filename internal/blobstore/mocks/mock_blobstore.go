@@ -0,0 +1,23 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import "context"
+
+type Store struct {
+	PutFn    func(ctx context.Context, key string, data []byte) error
+	GetFn    func(ctx context.Context, key string) ([]byte, error)
+	DeleteFn func(ctx context.Context, key string) error
+}
+
+func (m *Store) Put(ctx context.Context, key string, data []byte) error {
+	return m.PutFn(ctx, key, data)
+}
+
+func (m *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	return m.GetFn(ctx, key)
+}
+
+func (m *Store) Delete(ctx context.Context, key string) error {
+	return m.DeleteFn(ctx, key)
+}
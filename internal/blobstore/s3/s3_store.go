@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/internal/aws/sigv4"
+	"github.com/xataio/pgstream/internal/blobstore"
+	httplib "github.com/xataio/pgstream/internal/http"
+)
+
+// Store is a blob store backed by an S3 compatible object storage. Requests
+// are signed with AWS signature version 4.
+type Store struct {
+	client   httplib.Client
+	signer   *sigv4.Signer
+	endpoint *url.URL
+	bucket   string
+	// pathStyle addresses the bucket as part of the path instead of the host,
+	// which is required by most S3 compatible storages.
+	pathStyle bool
+}
+
+type Config struct {
+	// Endpoint is the URL of the S3 compatible storage. Defaults to the AWS
+	// S3 endpoint for the region.
+	Endpoint string
+	// Region of the bucket. Defaults to us-east-1.
+	Region string
+	Bucket string
+	// UsePathStyle enables the path style addressing of the bucket.
+	UsePathStyle    bool
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+const (
+	defaultRegion  = "us-east-1"
+	defaultTimeout = 30 * time.Second
+)
+
+func (c *Config) region() string {
+	if c.Region != "" {
+		return c.Region
+	}
+	return defaultRegion
+}
+
+func (c *Config) endpoint() string {
+	if c.Endpoint != "" {
+		return c.Endpoint
+	}
+	return fmt.Sprintf("https://s3.%s.amazonaws.com", c.region())
+}
+
+func NewStore(cfg *Config) (*Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 blob store: bucket is required")
+	}
+
+	endpoint, err := url.Parse(cfg.endpoint())
+	if err != nil {
+		return nil, fmt.Errorf("s3 blob store: parsing endpoint: %w", err)
+	}
+
+	return &Store{
+		client: &http.Client{Timeout: defaultTimeout},
+		signer: sigv4.NewSigner(sigv4.Credentials{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+		}, cfg.region(), "s3"),
+		endpoint:  endpoint,
+		bucket:    cfg.Bucket,
+		pathStyle: cfg.UsePathStyle,
+	}, nil
+}
+
+func (s *Store) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return fmt.Errorf("s3 blob store: put: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 blob store: put: %w", responseError(resp))
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("s3 blob store: get: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, blobstore.ErrNotFound
+	default:
+		return nil, fmt.Errorf("s3 blob store: get: %w", responseError(resp))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("s3 blob store: reading response body: %w", err)
+	}
+	return data, nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return fmt.Errorf("s3 blob store: delete: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// deleting a missing object is not an error for S3, but it can be for
+	// compatible storages
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 blob store: delete: %w", responseError(resp))
+	}
+	return nil
+}
+
+func (s *Store) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if err := s.signer.Sign(req, body); err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+func (s *Store) objectURL(key string) string {
+	u := *s.endpoint
+	key = strings.TrimPrefix(key, "/")
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return u.String()
+}
+
+func responseError(resp *http.Response) error {
+	// the error body is small, limit it in case the response is not the
+	// expected one
+	const maxErrorBodyBytes = 1024
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/blobstore"
+)
+
+// fakeS3 is a minimal in memory stand in for an S3 compatible storage, using
+// path style addressing.
+type fakeS3 struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") ||
+		r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+	case http.MethodGet:
+		data, found := f.objects[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx := context.Background()
+	store, err := NewStore(&Config{
+		Endpoint:        srv.URL,
+		Bucket:          "test-bucket",
+		UsePathStyle:    true,
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+	require.NoError(t, err)
+
+	const testKey = "public/test/1.json"
+	testData := []byte(`{"id":1}`)
+
+	_, err = store.Get(ctx, testKey)
+	require.ErrorIs(t, err, blobstore.ErrNotFound)
+
+	require.NoError(t, store.Put(ctx, testKey, testData))
+	require.Equal(t, testData, fake.objects["/test-bucket/public/test/1.json"])
+
+	data, err := store.Get(ctx, testKey)
+	require.NoError(t, err)
+	require.Equal(t, testData, data)
+
+	require.NoError(t, store.Delete(ctx, testKey))
+	_, err = store.Get(ctx, testKey)
+	require.ErrorIs(t, err, blobstore.ErrNotFound)
+
+	// invalid credentials
+	unauthorisedStore, err := NewStore(&Config{
+		Endpoint:        srv.URL,
+		Bucket:          "test-bucket",
+		UsePathStyle:    true,
+		AccessKeyID:     "other-key",
+		SecretAccessKey: "test-secret",
+	})
+	require.NoError(t, err)
+	err = unauthorisedStore.Put(ctx, testKey, testData)
+	require.ErrorContains(t, err, "unexpected status code 403")
+}
+
+func TestStore_objectURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  *Config
+		key  string
+
+		wantURL string
+	}{
+		{
+			name:    "virtual hosted style",
+			cfg:     &Config{Bucket: "test-bucket", Region: "eu-west-1"},
+			key:     "public/test/1.json",
+			wantURL: "https://test-bucket.s3.eu-west-1.amazonaws.com/public/test/1.json",
+		},
+		{
+			name:    "path style",
+			cfg:     &Config{Bucket: "test-bucket", Endpoint: "http://localhost:9000", UsePathStyle: true},
+			key:     "/public/test/1.json",
+			wantURL: "http://localhost:9000/test-bucket/public/test/1.json",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := NewStore(tc.cfg)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantURL, store.objectURL(tc.key))
+		})
+	}
+}
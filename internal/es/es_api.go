@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 )
 
 type SearchRequest struct {
@@ -17,6 +18,12 @@ type SearchRequest struct {
 	Sort           *string
 	SourceIncludes *string
 	Query          io.Reader
+	// TrackTotalHits makes the response total hits accurate, instead of
+	// capped to 10000.
+	TrackTotalHits *bool
+	// Scroll keeps the search context alive for the duration on input, so
+	// that the rest of the results can be retrieved with Scroll.
+	Scroll *time.Duration
 }
 
 type DeleteByQueryRequest struct {
@@ -25,6 +32,24 @@ type DeleteByQueryRequest struct {
 	Refresh bool
 }
 
+type UpdateByQueryRequest struct {
+	Index  []string
+	Query  map[string]any
+	Script *Script
+	// Conflicts can be set to "proceed" to continue updating when there are
+	// version conflicts, instead of aborting.
+	Conflicts string
+	Refresh   bool
+}
+
+type UpdateByQueryResponse struct {
+	Total            int               `json:"total"`
+	Updated          int               `json:"updated"`
+	VersionConflicts int               `json:"version_conflicts"`
+	TimedOut         bool              `json:"timed_out"`
+	Failures         []json.RawMessage `json:"failures"`
+}
+
 type IndexRequest struct {
 	Index   string
 	Body    []byte
@@ -38,6 +63,50 @@ type IndexWithIDRequest struct {
 	Refresh string
 }
 
+type ReindexRequest struct {
+	Source string
+	Dest   string
+	// DestVersionType is the version type used to write into the destination
+	// index. Using "external" keeps the source document versions.
+	DestVersionType string
+	// Conflicts can be set to "proceed" to continue reindexing when there are
+	// version conflicts, instead of aborting.
+	Conflicts string
+	Script    *Script
+	Refresh   bool
+}
+
+type ReindexResponse struct {
+	Total            int               `json:"total"`
+	Created          int               `json:"created"`
+	Updated          int               `json:"updated"`
+	Noops            int               `json:"noops"`
+	VersionConflicts int               `json:"version_conflicts"`
+	Failures         []json.RawMessage `json:"failures"`
+}
+
+// Task is the status of a long running task, such as a reindex. The response
+// is only set once the task is completed.
+type Task struct {
+	Completed bool             `json:"completed"`
+	Response  *ReindexResponse `json:"response,omitempty"`
+	Error     json.RawMessage  `json:"error,omitempty"`
+}
+
+type startTaskResponse struct {
+	Task string `json:"task"`
+}
+
+type AliasAction struct {
+	Add    *AliasActionTarget `json:"add,omitempty"`
+	Remove *AliasActionTarget `json:"remove,omitempty"`
+}
+
+type AliasActionTarget struct {
+	Index string `json:"index"`
+	Alias string `json:"alias"`
+}
+
 type BulkItem struct {
 	Index  *BulkIndex      `json:"index,omitempty"`
 	Delete *BulkIndex      `json:"delete,omitempty"`
@@ -129,8 +198,13 @@ type Function struct {
 	FieldValueFactor *FieldValueFactor      `json:"field_value_factor,omitempty"`
 	Weight           *float64               `json:"weight,omitempty"`
 	Exp              map[string]ExpFunction `json:"exp,omitempty"`
+	RandomScore      *RandomScore           `json:"random_score,omitempty"`
 }
 
+// RandomScore scores the documents randomly. Without a seed, the scores are
+// different on every request.
+type RandomScore struct{}
+
 type ExpFunction struct {
 	Origin *string `json:"origin,omitempty"`
 	Scale  string  `json:"scale"`
@@ -230,6 +304,7 @@ type Hits struct {
 }
 
 type SearchResponse struct {
+	ScrollID     string         `json:"_scroll_id"`
 	Hits         Hits           `json:"hits"`
 	Aggregations map[string]any `json:"aggregations"`
 }
@@ -254,6 +329,12 @@ type mappingResponse map[string]struct {
 	Mappings Mappings
 }
 
+type indicesMetaResponse map[string]struct {
+	Mappings struct {
+		Meta map[string]json.RawMessage `json:"_meta"`
+	} `json:"mappings"`
+}
+
 type indexStatsResponse struct {
 	Indices map[string]struct {
 		Primaries struct {
@@ -273,6 +354,39 @@ type countResponse struct {
 	Count int `json:"count"`
 }
 
+func (r *ReindexRequest) body() map[string]any {
+	dest := map[string]any{
+		"index": r.Dest,
+	}
+	if r.DestVersionType != "" {
+		dest["version_type"] = r.DestVersionType
+	}
+	body := map[string]any{
+		"source": map[string]any{
+			"index": r.Source,
+		},
+		"dest": dest,
+	}
+	if r.Conflicts != "" {
+		body["conflicts"] = r.Conflicts
+	}
+	if r.Script != nil {
+		body["script"] = r.Script
+	}
+	return body
+}
+
+func (r *UpdateByQueryRequest) body() map[string]any {
+	body := map[string]any{}
+	if r.Query != nil {
+		body["query"] = r.Query
+	}
+	if r.Script != nil {
+		body["script"] = r.Script
+	}
+	return body
+}
+
 func encodeBulkItems(buffer *bytes.Buffer, items []BulkItem) error {
 	encoder := json.NewEncoder(buffer)
 
@@ -11,12 +11,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
+	tlslib "github.com/xataio/pgstream/internal/tls"
 )
 
 type SearchClient interface {
+	ClearScroll(ctx context.Context, scrollID string) error
 	CloseIndex(ctx context.Context, index string) error
 	Count(ctx context.Context, index string) (int, error)
 	CreateIndex(ctx context.Context, index string, body map[string]any) error
@@ -24,7 +27,10 @@ type SearchClient interface {
 	DeleteIndex(ctx context.Context, index []string) error
 	GetIndexAlias(ctx context.Context, name string) (map[string]any, error)
 	GetIndexMappings(ctx context.Context, index string) (*Mappings, error)
+	GetIndicesFieldMapping(ctx context.Context, indexPattern, field string) (map[string]map[string]any, error)
+	GetIndicesMeta(ctx context.Context, indexPattern, field string) (map[string]json.RawMessage, error)
 	GetIndicesStats(ctx context.Context, indexPattern string) ([]IndexStats, error)
+	GetTask(ctx context.Context, taskID string) (*Task, error)
 	Index(ctx context.Context, req *IndexRequest) error
 	IndexWithID(ctx context.Context, req *IndexWithIDRequest) error
 	IndexExists(ctx context.Context, index string) (bool, error)
@@ -34,8 +40,12 @@ type SearchClient interface {
 	PutIndexMappings(ctx context.Context, index string, body map[string]any) error
 	PutIndexSettings(ctx context.Context, index string, body map[string]any) error
 	RefreshIndex(ctx context.Context, index string) error
+	Scroll(ctx context.Context, scrollID string, keepAlive time.Duration) (*SearchResponse, error)
 	Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error)
 	SendBulkRequest(ctx context.Context, items []BulkItem) ([]BulkItem, error)
+	StartReindex(ctx context.Context, req *ReindexRequest) (string, error)
+	UpdateAliases(ctx context.Context, actions []AliasAction) error
+	UpdateByQuery(ctx context.Context, req *UpdateByQueryRequest) error
 }
 
 type Client struct {
@@ -47,8 +57,23 @@ var (
 	errInvalidSearchEnvelope = errors.New("invalid search response")
 )
 
-func NewClient(url string) (*Client, error) {
-	es, err := newClient(url)
+type ClientConfig struct {
+	URL string
+	// Username and Password enable basic authentication.
+	Username string
+	Password string
+	// APIKey is the base64 encoded API key, used instead of basic
+	// authentication when set.
+	APIKey string
+	// TLS configures a custom CA and client certificates. Optional.
+	TLS *tlslib.Config
+	// AWSSigV4 enables the AWS Signature Version 4 signing of the requests.
+	// It can't be combined with basic or API key authentication. Optional.
+	AWSSigV4 *SigV4Config
+}
+
+func NewClient(cfg ClientConfig) (*Client, error) {
+	es, err := newClient(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("create elasticsearch client: %w", err)
 	}
@@ -139,6 +164,51 @@ func (ec *Client) DeleteByQuery(ctx context.Context, req *DeleteByQueryRequest)
 	return nil
 }
 
+// UpdateByQuery applies the request script to all the documents matching the
+// request query. It waits for the update to complete, and returns an error if
+// it timed out or any of the documents failed to update.
+func (ec *Client) UpdateByQuery(ctx context.Context, req *UpdateByQueryRequest) error {
+	reader, err := createReader(req.body())
+	if err != nil {
+		return err
+	}
+
+	opts := []func(*esapi.UpdateByQueryRequest){
+		ec.client.UpdateByQuery.WithContext(ctx),
+		ec.client.UpdateByQuery.WithBody(reader),
+		ec.client.UpdateByQuery.WithSlices("auto"),
+		ec.client.UpdateByQuery.WithWaitForCompletion(true),
+		ec.client.UpdateByQuery.WithRefresh(req.Refresh),
+	}
+	if req.Conflicts != "" {
+		opts = append(opts, ec.client.UpdateByQuery.WithConflicts(req.Conflicts))
+	}
+
+	res, err := ec.client.UpdateByQuery(req.Index, opts...)
+	if err != nil {
+		return fmt.Errorf("[UpdateByQuery] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := ec.isErrResponse(res); err != nil {
+		return fmt.Errorf("[UpdateByQuery] error response from Elasticsearch: %w", err)
+	}
+
+	var response UpdateByQueryResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return fmt.Errorf("[UpdateByQuery] decoding response body: %w", err)
+	}
+
+	if response.TimedOut {
+		return fmt.Errorf("[UpdateByQuery] timed out after updating %d of %d documents", response.Updated, response.Total)
+	}
+	if len(response.Failures) > 0 {
+		return fmt.Errorf("[UpdateByQuery] %d documents failed to update: %s", len(response.Failures), response.Failures[0])
+	}
+
+	return nil
+}
+
 func (ec *Client) DeleteIndex(ctx context.Context, index []string) error {
 	res, err := ec.client.Indices.Delete(
 		index,
@@ -258,6 +328,68 @@ func (ec *Client) GetIndexMappings(ctx context.Context, index string) (*Mappings
 	return &mappings.Mappings, nil
 }
 
+// GetIndicesFieldMapping returns the mapping of the top level field on input
+// of the indices matching the pattern, by index name. The indices without the
+// field are not included.
+func (ec *Client) GetIndicesFieldMapping(ctx context.Context, indexPattern, field string) (map[string]map[string]any, error) {
+	res, err := ec.client.Indices.GetMapping(
+		ec.client.Indices.GetMapping.WithIndex(indexPattern),
+		ec.client.Indices.GetMapping.WithFilterPath(fmt.Sprintf("*.mappings.properties.%s", field)),
+		ec.client.Indices.GetMapping.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("[GetIndicesFieldMapping] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := ec.isErrResponse(res); err != nil {
+		return nil, fmt.Errorf("[GetIndicesFieldMapping] error response from Elasticsearch: %w", err)
+	}
+
+	var response mappingResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("[GetIndicesFieldMapping] decoding response body: %w", err)
+	}
+
+	fieldMappings := make(map[string]map[string]any, len(response))
+	for index, r := range response {
+		if mapping, ok := r.Mappings.Properties[field].(map[string]any); ok {
+			fieldMappings[index] = mapping
+		}
+	}
+	return fieldMappings, nil
+}
+
+// GetIndicesMeta returns the value of the mapping metadata field on input
+// (`_meta.<field>`) of the indices matching the pattern, by index name. The
+// indices without the field are not included.
+func (ec *Client) GetIndicesMeta(ctx context.Context, indexPattern, field string) (map[string]json.RawMessage, error) {
+	res, err := ec.client.Indices.GetMapping(
+		ec.client.Indices.GetMapping.WithIndex(indexPattern),
+		ec.client.Indices.GetMapping.WithFilterPath(fmt.Sprintf("*.mappings._meta.%s", field)),
+		ec.client.Indices.GetMapping.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("[GetIndicesMeta] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := ec.isErrResponse(res); err != nil {
+		return nil, fmt.Errorf("[GetIndicesMeta] error response from Elasticsearch: %w", err)
+	}
+
+	var response indicesMetaResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("[GetIndicesMeta] decoding response body: %w", err)
+	}
+
+	meta := make(map[string]json.RawMessage, len(response))
+	for index, r := range response {
+		if value, found := r.Mappings.Meta[field]; found {
+			meta[index] = value
+		}
+	}
+	return meta, nil
+}
+
 // GetIndicesStats uses the index stats API to fetch statistics about indices. indexPattern is a
 // wildcard pattern used to select the indices we care about.
 func (ec *Client) GetIndicesStats(ctx context.Context, indexPattern string) ([]IndexStats, error) {
@@ -399,6 +531,62 @@ func (ec *Client) RefreshIndex(ctx context.Context, index string) error {
 	return nil
 }
 
+// StartReindex starts copying the documents from the source index into the
+// destination index, applying the request script if any. It doesn't wait for
+// the reindex to complete, and returns the id of the task that can be used to
+// track its progress.
+func (ec *Client) StartReindex(ctx context.Context, req *ReindexRequest) (string, error) {
+	reader, err := createReader(req.body())
+	if err != nil {
+		return "", err
+	}
+
+	res, err := ec.client.Reindex(
+		reader,
+		ec.client.Reindex.WithContext(ctx),
+		ec.client.Reindex.WithWaitForCompletion(false),
+		ec.client.Reindex.WithRefresh(req.Refresh),
+	)
+	if err != nil {
+		return "", fmt.Errorf("[StartReindex] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := ec.isErrResponse(res); err != nil {
+		return "", fmt.Errorf("[StartReindex] error response from Elasticsearch: %w", err)
+	}
+
+	var response startTaskResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("[StartReindex] decoding response body: %w", err)
+	}
+
+	return response.Task, nil
+}
+
+// GetTask returns the status of the task on input.
+func (ec *Client) GetTask(ctx context.Context, taskID string) (*Task, error) {
+	res, err := ec.client.Tasks.Get(
+		taskID,
+		ec.client.Tasks.Get.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[GetTask] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := ec.isErrResponse(res); err != nil {
+		return nil, fmt.Errorf("[GetTask] error response from Elasticsearch: %w", err)
+	}
+
+	var task Task
+	if err := json.NewDecoder(res.Body).Decode(&task); err != nil {
+		return nil, fmt.Errorf("[GetTask] decoding response body: %w", err)
+	}
+
+	return &task, nil
+}
+
 func (ec *Client) Perform(req *http.Request) (*http.Response, error) {
 	return ec.client.Transport.Perform(req)
 }
@@ -422,6 +610,46 @@ func (ec *Client) Search(ctx context.Context, req *SearchRequest) (*SearchRespon
 	return &response, nil
 }
 
+// Scroll returns the next page of results of a search started with a scroll
+// keep alive.
+func (ec *Client) Scroll(ctx context.Context, scrollID string, keepAlive time.Duration) (*SearchResponse, error) {
+	res, err := ec.client.Scroll(
+		ec.client.Scroll.WithContext(ctx),
+		ec.client.Scroll.WithScrollID(scrollID),
+		ec.client.Scroll.WithScroll(keepAlive),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("[Scroll] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+	if err := ec.isErrResponse(res); err != nil {
+		return nil, fmt.Errorf("[Scroll] error response from Elasticsearch: %w", err)
+	}
+
+	var response SearchResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("[Scroll] decoding response body: %w: %w", errInvalidSearchEnvelope, err)
+	}
+
+	return &response, nil
+}
+
+// ClearScroll releases the search context of the scroll on input.
+func (ec *Client) ClearScroll(ctx context.Context, scrollID string) error {
+	res, err := ec.client.ClearScroll(
+		ec.client.ClearScroll.WithContext(ctx),
+		ec.client.ClearScroll.WithScrollID(scrollID),
+	)
+	if err != nil {
+		return fmt.Errorf("[ClearScroll] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+	if err := ec.isErrResponse(res); err != nil {
+		return fmt.Errorf("[ClearScroll] error response from Elasticsearch: %w", err)
+	}
+	return nil
+}
+
 // SendBulkRequest can perform multiple indexing or delete operations in a single call
 func (ec *Client) SendBulkRequest(ctx context.Context, items []BulkItem) ([]BulkItem, error) {
 	buffer := new(bytes.Buffer)
@@ -453,6 +681,29 @@ func (ec *Client) SendBulkRequest(ctx context.Context, items []BulkItem) ([]Bulk
 	return verifyResponse(bodyBytes, items)
 }
 
+// UpdateAliases applies all the alias actions on input atomically.
+func (ec *Client) UpdateAliases(ctx context.Context, actions []AliasAction) error {
+	reader, err := createReader(map[string]any{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	res, err := ec.client.Indices.UpdateAliases(
+		reader,
+		ec.client.Indices.UpdateAliases.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("[UpdateAliases] error from Elasticsearch: %w", err)
+	}
+	defer res.Body.Close()
+
+	if err := ec.isErrResponse(res); err != nil {
+		return fmt.Errorf("[UpdateAliases] error response from Elasticsearch: %w", err)
+	}
+
+	return nil
+}
+
 func (ec *Client) parseSearchRequest(ctx context.Context, req *SearchRequest) []func(*esapi.SearchRequest) {
 	opts := []func(*esapi.SearchRequest){
 		ec.client.Search.WithContext(ctx),
@@ -478,6 +729,12 @@ func (ec *Client) parseSearchRequest(ctx context.Context, req *SearchRequest) []
 	if req.SourceIncludes != nil {
 		opts = append(opts, ec.client.Search.WithSourceIncludes(*req.SourceIncludes))
 	}
+	if req.TrackTotalHits != nil {
+		opts = append(opts, ec.client.Search.WithTrackTotalHits(*req.TrackTotalHits))
+	}
+	if req.Scroll != nil {
+		opts = append(opts, ec.client.Search.WithScroll(*req.Scroll))
+	}
 
 	return opts
 }
@@ -493,19 +750,27 @@ func (ec *Client) isErrResponse(res *esapi.Response) error {
 	return nil
 }
 
-func newClient(address string) (*elasticsearch.Client, error) {
-	if address == "" {
+func newClient(cfg ClientConfig) (*elasticsearch.Client, error) {
+	if cfg.URL == "" {
 		return nil, errors.New("no address provided")
 	}
 
-	cfg := elasticsearch.Config{
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	esCfg := elasticsearch.Config{
 		Addresses: []string{
-			address,
+			cfg.URL,
 		},
-		Transport: http.DefaultTransport,
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+		APIKey:    cfg.APIKey,
+		Transport: transport,
 	}
 
-	return elasticsearch.NewClient(cfg)
+	return elasticsearch.NewClient(esCfg)
 }
 
 // createReader returns a reader on the JSON representation of the given value.
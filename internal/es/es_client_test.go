@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package es
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_UpdateByQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response string
+
+		wantErr bool
+	}{
+		{
+			name:     "ok",
+			response: `{"total":2,"updated":2,"timed_out":false,"failures":[]}`,
+			wantErr:  false,
+		},
+		{
+			name:     "error - timed out",
+			response: `{"total":2,"updated":1,"timed_out":true,"failures":[]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "error - failures",
+			response: `{"total":2,"updated":1,"timed_out":false,"failures":[{"id":"1","cause":{"type":"mapper_parsing_exception"}}]}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "true", r.URL.Query().Get("wait_for_completion"))
+
+				w.Header().Set("X-Elastic-Product", "Elasticsearch")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, err := w.Write([]byte(tc.response))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(ClientConfig{URL: server.URL})
+			require.NoError(t, err)
+
+			err = client.UpdateByQuery(context.Background(), &UpdateByQueryRequest{
+				Index: []string{"index"},
+				Query: map[string]any{"match_all": map[string]any{}},
+			})
+			require.Equal(t, tc.wantErr, err != nil, err)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package es
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/xataio/pgstream/internal/aws/sigv4"
+	tlslib "github.com/xataio/pgstream/internal/tls"
+)
+
+// SigV4Config configures the AWS Signature Version 4 signing of the requests,
+// required by Amazon OpenSearch Service domains with IAM authentication.
+type SigV4Config struct {
+	// Region of the domain. Defaults to the AWS_REGION environment variable.
+	Region string
+	// Service is the signing name of the service. Defaults to "es", use
+	// "aoss" for OpenSearch Serverless collections.
+	Service string
+	// Static credentials. When not provided, the credentials are read from
+	// the standard AWS environment variables.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+const defaultSigV4Service = "es"
+
+var errInvalidAuthConfig = errors.New("invalid authentication config")
+
+func (c *SigV4Config) region() string {
+	if c.Region != "" {
+		return c.Region
+	}
+	return os.Getenv("AWS_REGION")
+}
+
+func (c *SigV4Config) service() string {
+	if c.Service != "" {
+		return c.Service
+	}
+	return defaultSigV4Service
+}
+
+func (c *SigV4Config) credentials() sigv4.Credentials {
+	if c.AccessKeyID != "" {
+		return sigv4.Credentials{
+			AccessKeyID:     c.AccessKeyID,
+			SecretAccessKey: c.SecretAccessKey,
+			SessionToken:    c.SessionToken,
+		}
+	}
+	return sigv4.EnvCredentials()
+}
+
+func (c *SigV4Config) signer() (*sigv4.Signer, error) {
+	region := c.region()
+	if region == "" {
+		return nil, fmt.Errorf("%w: sigv4 signing requires a region", errInvalidAuthConfig)
+	}
+	credentials := c.credentials()
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%w: sigv4 signing requires an access key id and secret access key", errInvalidAuthConfig)
+	}
+	return sigv4.NewSigner(credentials, region, c.service()), nil
+}
+
+// newTransport returns the http transport for the client configuration on
+// input, using the custom TLS configuration and signing the requests when
+// configured.
+func newTransport(cfg ClientConfig) (http.RoundTripper, error) {
+	var transport http.RoundTripper = http.DefaultTransport
+	if cfg.TLS != nil {
+		tlsConfig, err := tlslib.NewConfig(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("building tls config: %w", err)
+		}
+		if tlsConfig != nil {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.TLSClientConfig = tlsConfig
+			transport = t
+		}
+	}
+
+	if cfg.AWSSigV4 == nil {
+		return transport, nil
+	}
+
+	// the signature sets the authorization header, so it can't be combined
+	// with other authentication methods
+	if cfg.Username != "" || cfg.APIKey != "" {
+		return nil, fmt.Errorf("%w: sigv4 signing can't be combined with basic or API key authentication", errInvalidAuthConfig)
+	}
+	signer, err := cfg.AWSSigV4.signer()
+	if err != nil {
+		return nil, err
+	}
+	return &signingTransport{next: transport, signer: signer}, nil
+}
+
+// signingTransport signs the requests with AWS Signature Version 4 before
+// sending them with the wrapped transport.
+type signingTransport struct {
+	next   http.RoundTripper
+	signer *sigv4.Signer
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the round tripper must not modify the original request
+	signed := req.Clone(req.Context())
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+		signed.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		signed.ContentLength = int64(len(body))
+	}
+
+	if err := t.signer.Sign(signed, body); err != nil {
+		return nil, fmt.Errorf("signing request: %w", err)
+	}
+	return t.next.RoundTrip(signed)
+}
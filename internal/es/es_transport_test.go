@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package es
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_SigV4Signing(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"query":{"match_all":{}}}`)
+
+	tests := []struct {
+		name   string
+		config *SigV4Config
+
+		wantScope string
+	}{
+		{
+			name: "default service",
+			config: &SigV4Config{
+				Region:          "eu-west-1",
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "secret",
+				SessionToken:    "token",
+			},
+			wantScope: "/eu-west-1/es/aws4_request",
+		},
+		{
+			name: "serverless service",
+			config: &SigV4Config{
+				Region:          "us-east-1",
+				Service:         "aoss",
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "secret",
+				SessionToken:    "token",
+			},
+			wantScope: "/us-east-1/aoss/aws4_request",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotBody, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, body, gotBody)
+
+				payloadHash := sha256.Sum256(body)
+				require.Equal(t, hex.EncodeToString(payloadHash[:]), r.Header.Get("X-Amz-Content-Sha256"))
+				require.Equal(t, "token", r.Header.Get("X-Amz-Security-Token"))
+				require.NotEmpty(t, r.Header.Get("X-Amz-Date"))
+
+				auth := r.Header.Get("Authorization")
+				require.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), auth)
+				require.Contains(t, auth, tc.wantScope)
+				require.Contains(t, auth, "SignedHeaders=")
+				require.Contains(t, auth, "host")
+				require.Contains(t, auth, "Signature=")
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(ClientConfig{
+				URL:      server.URL,
+				AWSSigV4: tc.config,
+			})
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(http.MethodPost, "/index/_search", bytes.NewReader(body))
+			require.NoError(t, err)
+			res, err := client.Perform(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		})
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	t.Parallel()
+
+	sigV4Config := &SigV4Config{
+		Region:          "eu-west-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	}
+
+	tests := []struct {
+		name   string
+		config ClientConfig
+
+		wantSigning bool
+		wantErr     error
+	}{
+		{
+			name:   "no authentication",
+			config: ClientConfig{URL: "http://localhost:9200"},
+		},
+		{
+			name:   "basic authentication",
+			config: ClientConfig{URL: "http://localhost:9200", Username: "user", Password: "pass"},
+		},
+		{
+			name:        "sigv4 signing",
+			config:      ClientConfig{URL: "http://localhost:9200", AWSSigV4: sigV4Config},
+			wantSigning: true,
+		},
+		{
+			name:    "error - sigv4 with basic authentication",
+			config:  ClientConfig{URL: "http://localhost:9200", Username: "user", AWSSigV4: sigV4Config},
+			wantErr: errInvalidAuthConfig,
+		},
+		{
+			name:    "error - sigv4 with api key",
+			config:  ClientConfig{URL: "http://localhost:9200", APIKey: "key", AWSSigV4: sigV4Config},
+			wantErr: errInvalidAuthConfig,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transport, err := newTransport(tc.config)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			_, signing := transport.(*signingTransport)
+			require.Equal(t, tc.wantSigning, signing)
+		})
+	}
+}
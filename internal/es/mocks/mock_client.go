@@ -4,31 +4,45 @@ package mocks
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/xataio/pgstream/internal/es"
 )
 
 type Client struct {
-	CloseIndexFn       func(ctx context.Context, index string) error
-	CountFn            func(ctx context.Context, index string) (int, error)
-	CreateIndexFn      func(ctx context.Context, index string, body map[string]any) error
-	DeleteByQueryFn    func(ctx context.Context, req *es.DeleteByQueryRequest) error
-	DeleteIndexFn      func(ctx context.Context, index []string) error
-	GetIndexAliasFn    func(ctx context.Context, name string) (map[string]any, error)
-	GetIndexMappingsFn func(ctx context.Context, index string) (*es.Mappings, error)
-	GetIndicesStatsFn  func(ctx context.Context, pattern string) ([]es.IndexStats, error)
-	IndexFn            func(ctx context.Context, req *es.IndexRequest) error
-	IndexWithIDFn      func(ctx context.Context, req *es.IndexWithIDRequest) error
-	IndexExistsFn      func(ctx context.Context, index string) (bool, error)
-	ListIndicesFn      func(ctx context.Context, indices []string) ([]string, error)
-	PerformFn          func(req *http.Request) (*http.Response, error)
-	PutIndexAliasFn    func(ctx context.Context, index []string, name string) error
-	PutIndexMappingsFn func(ctx context.Context, index string, body map[string]any) error
-	PutIndexSettingsFn func(ctx context.Context, index string, body map[string]any) error
-	RefreshIndexFn     func(ctx context.Context, index string) error
-	SearchFn           func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error)
-	SendBulkRequestFn  func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error)
+	ClearScrollFn            func(ctx context.Context, scrollID string) error
+	CloseIndexFn             func(ctx context.Context, index string) error
+	CountFn                  func(ctx context.Context, index string) (int, error)
+	CreateIndexFn            func(ctx context.Context, index string, body map[string]any) error
+	DeleteByQueryFn          func(ctx context.Context, req *es.DeleteByQueryRequest) error
+	DeleteIndexFn            func(ctx context.Context, index []string) error
+	GetIndexAliasFn          func(ctx context.Context, name string) (map[string]any, error)
+	GetIndexMappingsFn       func(ctx context.Context, index string) (*es.Mappings, error)
+	GetIndicesFieldMappingFn func(ctx context.Context, pattern, field string) (map[string]map[string]any, error)
+	GetIndicesMetaFn         func(ctx context.Context, pattern, field string) (map[string]json.RawMessage, error)
+	GetIndicesStatsFn        func(ctx context.Context, pattern string) ([]es.IndexStats, error)
+	GetTaskFn                func(ctx context.Context, taskID string) (*es.Task, error)
+	IndexFn                  func(ctx context.Context, req *es.IndexRequest) error
+	IndexWithIDFn            func(ctx context.Context, req *es.IndexWithIDRequest) error
+	IndexExistsFn            func(ctx context.Context, index string) (bool, error)
+	ListIndicesFn            func(ctx context.Context, indices []string) ([]string, error)
+	PerformFn                func(req *http.Request) (*http.Response, error)
+	PutIndexAliasFn          func(ctx context.Context, index []string, name string) error
+	PutIndexMappingsFn       func(ctx context.Context, index string, body map[string]any) error
+	PutIndexSettingsFn       func(ctx context.Context, index string, body map[string]any) error
+	RefreshIndexFn           func(ctx context.Context, index string) error
+	ScrollFn                 func(ctx context.Context, scrollID string, keepAlive time.Duration) (*es.SearchResponse, error)
+	SearchFn                 func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error)
+	SendBulkRequestFn        func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error)
+	StartReindexFn           func(ctx context.Context, req *es.ReindexRequest) (string, error)
+	UpdateAliasesFn          func(ctx context.Context, actions []es.AliasAction) error
+	UpdateByQueryFn          func(ctx context.Context, req *es.UpdateByQueryRequest) error
+}
+
+func (m *Client) ClearScroll(ctx context.Context, scrollID string) error {
+	return m.ClearScrollFn(ctx, scrollID)
 }
 
 func (m *Client) CloseIndex(ctx context.Context, index string) error {
@@ -59,10 +73,22 @@ func (m *Client) GetIndexMappings(ctx context.Context, index string) (*es.Mappin
 	return m.GetIndexMappingsFn(ctx, index)
 }
 
+func (m *Client) GetIndicesFieldMapping(ctx context.Context, pattern, field string) (map[string]map[string]any, error) {
+	return m.GetIndicesFieldMappingFn(ctx, pattern, field)
+}
+
+func (m *Client) GetIndicesMeta(ctx context.Context, pattern, field string) (map[string]json.RawMessage, error) {
+	return m.GetIndicesMetaFn(ctx, pattern, field)
+}
+
 func (m *Client) GetIndicesStats(ctx context.Context, pattern string) ([]es.IndexStats, error) {
 	return m.GetIndicesStatsFn(ctx, pattern)
 }
 
+func (m *Client) GetTask(ctx context.Context, taskID string) (*es.Task, error) {
+	return m.GetTaskFn(ctx, taskID)
+}
+
 func (m *Client) Index(ctx context.Context, req *es.IndexRequest) error {
 	return m.IndexFn(ctx, req)
 }
@@ -99,6 +125,10 @@ func (m *Client) RefreshIndex(ctx context.Context, index string) error {
 	return m.RefreshIndexFn(ctx, index)
 }
 
+func (m *Client) Scroll(ctx context.Context, scrollID string, keepAlive time.Duration) (*es.SearchResponse, error) {
+	return m.ScrollFn(ctx, scrollID, keepAlive)
+}
+
 func (m *Client) Search(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 	return m.SearchFn(ctx, req)
 }
@@ -106,3 +136,15 @@ func (m *Client) Search(ctx context.Context, req *es.SearchRequest) (*es.SearchR
 func (m *Client) SendBulkRequest(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
 	return m.SendBulkRequestFn(ctx, items)
 }
+
+func (m *Client) StartReindex(ctx context.Context, req *es.ReindexRequest) (string, error) {
+	return m.StartReindexFn(ctx, req)
+}
+
+func (m *Client) UpdateAliases(ctx context.Context, actions []es.AliasAction) error {
+	return m.UpdateAliasesFn(ctx, actions)
+}
+
+func (m *Client) UpdateByQuery(ctx context.Context, req *es.UpdateByQueryRequest) error {
+	return m.UpdateByQueryFn(ctx, req)
+}
@@ -8,6 +8,9 @@ type ConnConfig struct {
 	Servers []string
 	Topic   TopicConfig
 	TLS     *tlslib.Config
+	// SASL authentication configuration. If not provided, no SASL
+	// authentication is used.
+	SASL *SASLConfig
 }
 
 type TopicConfig struct {
@@ -21,6 +24,24 @@ type TopicConfig struct {
 	AutoCreate bool
 }
 
+type SASLConfig struct {
+	// Mechanism is the SASL mechanism to use for authentication. One of PLAIN,
+	// SCRAM-SHA-256 or SCRAM-SHA-512.
+	Mechanism SASLMechanism
+	// Username used for the SASL authentication.
+	Username string
+	// Password used for the SASL authentication.
+	Password string
+}
+
+type SASLMechanism string
+
+const (
+	SASLMechanismPlain       SASLMechanism = "PLAIN"
+	SASLMechanismSCRAMSHA256 SASLMechanism = "SCRAM-SHA-256"
+	SASLMechanismSCRAMSHA512 SASLMechanism = "SCRAM-SHA-512"
+)
+
 const (
 	defaultNumPartitions     = 1
 	defaultReplicationFactor = 1
@@ -39,3 +60,7 @@ func (c *TopicConfig) replicationFactor() int {
 	}
 	return defaultReplicationFactor
 }
+
+func (c *ConnConfig) saslEnabled() bool {
+	return c.SASL != nil && c.SASL.Mechanism != ""
+}
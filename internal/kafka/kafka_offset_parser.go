@@ -15,6 +15,29 @@ type Offset struct {
 	Offset    int64
 }
 
+// LastOffsetsByPartition returns the highest offset per topic partition of the
+// offsets on input, in the order the partitions are first found.
+func LastOffsetsByPartition(offsets []*Offset) []*Offset {
+	lastOffsets := make(map[string]*Offset, len(offsets))
+	keys := make([]string, 0, len(offsets))
+	for _, o := range offsets {
+		key := topicPartitionKey(o)
+		last, found := lastOffsets[key]
+		if !found {
+			keys = append(keys, key)
+		}
+		if !found || last.Offset < o.Offset {
+			lastOffsets[key] = o
+		}
+	}
+
+	result := make([]*Offset, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, lastOffsets[key])
+	}
+	return result
+}
+
 type OffsetParser interface {
 	ToString(o *Offset) string
 	FromString(s string) (*Offset, error)
@@ -78,3 +78,22 @@ func TestParser_FromString(t *testing.T) {
 		})
 	}
 }
+
+func TestLastOffsetsByPartition(t *testing.T) {
+	t.Parallel()
+
+	offsets := []*Offset{
+		{Topic: "topic-1", Partition: 1, Offset: 5},
+		{Topic: "topic-1", Partition: 0, Offset: 3},
+		{Topic: "topic-1", Partition: 1, Offset: 7},
+		{Topic: "topic-2", Partition: 1, Offset: 2},
+		{Topic: "topic-1", Partition: 1, Offset: 6},
+	}
+
+	require.Equal(t, []*Offset{
+		{Topic: "topic-1", Partition: 1, Offset: 7},
+		{Topic: "topic-1", Partition: 0, Offset: 3},
+		{Topic: "topic-2", Partition: 1, Offset: 2},
+	}, LastOffsetsByPartition(offsets))
+	require.Empty(t, LastOffsetsByPartition(nil))
+}
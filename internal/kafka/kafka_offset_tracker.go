@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OffsetTracker keeps track of the fetched offsets per topic partition while
+// they're being processed, so that only the highest contiguous processed
+// offset is committed when partitions are processed concurrently. It is
+// concurrency safe.
+type OffsetTracker struct {
+	mutex      sync.Mutex
+	partitions map[string]*partitionOffsets
+}
+
+type partitionOffsets struct {
+	// inFlight contains the tracked offsets in the order they were fetched,
+	// starting from the oldest one that has not been processed yet.
+	inFlight []*trackedOffset
+	// watermark is the highest offset for which all the previously tracked
+	// offsets have been processed. -1 if none.
+	watermark int64
+}
+
+type trackedOffset struct {
+	offset    int64
+	processed bool
+}
+
+func NewOffsetTracker() *OffsetTracker {
+	return &OffsetTracker{
+		partitions: map[string]*partitionOffsets{},
+	}
+}
+
+// Track registers the offset as fetched. Offsets must be tracked in the order
+// they're fetched for a given partition.
+func (t *OffsetTracker) Track(o *Offset) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p := t.getPartition(o)
+	p.inFlight = append(p.inFlight, &trackedOffset{offset: o.Offset})
+}
+
+// MarkProcessed marks the tracked offset as processed, advancing the partition
+// watermark if all the offsets before it have been processed too.
+func (t *OffsetTracker) MarkProcessed(o *Offset) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p := t.getPartition(o)
+	for _, tracked := range p.inFlight {
+		if tracked.offset == o.Offset {
+			tracked.processed = true
+			break
+		}
+	}
+
+	// move the watermark forward while the oldest tracked offsets have been
+	// processed
+	i := 0
+	for ; i < len(p.inFlight) && p.inFlight[i].processed; i++ {
+		p.watermark = p.inFlight[i].offset
+	}
+	p.inFlight = p.inFlight[i:]
+}
+
+// Committable returns, for each of the topic partitions of the checkpointed
+// offsets on input, the highest offset that can be safely committed. That is
+// the highest checkpointed offset, capped at the highest contiguous processed
+// offset for the partition. Partitions with nothing to commit are not
+// included.
+func (t *OffsetTracker) Committable(checkpointed []*Offset) []*Offset {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	lastCheckpointed := LastOffsetsByPartition(checkpointed)
+	committable := make([]*Offset, 0, len(lastCheckpointed))
+	for _, last := range lastCheckpointed {
+		p := t.getPartition(last)
+		offset := min(last.Offset, p.watermark)
+		if offset < 0 {
+			continue
+		}
+		committable = append(committable, &Offset{
+			Topic:     last.Topic,
+			Partition: last.Partition,
+			Offset:    offset,
+		})
+	}
+
+	return committable
+}
+
+// Reset removes all the tracked partitions, once they've been revoked by a
+// consumer group rebalance, so that their offsets are not committed.
+func (t *OffsetTracker) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.partitions = map[string]*partitionOffsets{}
+}
+
+func (t *OffsetTracker) getPartition(o *Offset) *partitionOffsets {
+	key := topicPartitionKey(o)
+	p, found := t.partitions[key]
+	if !found {
+		p = &partitionOffsets{
+			watermark: -1,
+		}
+		t.partitions[key] = p
+	}
+	return p
+}
+
+func topicPartitionKey(o *Offset) string {
+	return fmt.Sprintf("%s-%d", o.Topic, o.Partition)
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	t.Parallel()
+
+	offset := func(partition int, o int64) *Offset {
+		return &Offset{Topic: "test-topic", Partition: partition, Offset: o}
+	}
+
+	tests := []struct {
+		name         string
+		tracked      []*Offset
+		processed    []*Offset
+		checkpointed []*Offset
+
+		wantCommittable []*Offset
+	}{
+		{
+			name:         "all processed",
+			tracked:      []*Offset{offset(0, 1), offset(0, 2), offset(0, 3)},
+			processed:    []*Offset{offset(0, 1), offset(0, 2), offset(0, 3)},
+			checkpointed: []*Offset{offset(0, 1), offset(0, 3), offset(0, 2)},
+
+			wantCommittable: []*Offset{offset(0, 3)},
+		},
+		{
+			name:         "gap in processed offsets",
+			tracked:      []*Offset{offset(0, 1), offset(0, 2), offset(0, 3)},
+			processed:    []*Offset{offset(0, 1), offset(0, 3)},
+			checkpointed: []*Offset{offset(0, 3)},
+
+			wantCommittable: []*Offset{offset(0, 1)},
+		},
+		{
+			name:         "checkpoint lower than watermark",
+			tracked:      []*Offset{offset(0, 1), offset(0, 2), offset(0, 3)},
+			processed:    []*Offset{offset(0, 1), offset(0, 2), offset(0, 3)},
+			checkpointed: []*Offset{offset(0, 2)},
+
+			wantCommittable: []*Offset{offset(0, 2)},
+		},
+		{
+			name:         "nothing processed",
+			tracked:      []*Offset{offset(0, 1), offset(0, 2)},
+			processed:    []*Offset{},
+			checkpointed: []*Offset{offset(0, 2)},
+
+			wantCommittable: []*Offset{},
+		},
+		{
+			name:         "multiple partitions",
+			tracked:      []*Offset{offset(0, 1), offset(1, 10), offset(0, 2), offset(1, 11)},
+			processed:    []*Offset{offset(1, 10), offset(0, 2), offset(1, 11)},
+			checkpointed: []*Offset{offset(1, 11), offset(0, 2)},
+
+			wantCommittable: []*Offset{offset(1, 11)},
+		},
+		{
+			name:         "non contiguous kafka offsets",
+			tracked:      []*Offset{offset(0, 1), offset(0, 5), offset(0, 9)},
+			processed:    []*Offset{offset(0, 5), offset(0, 1)},
+			checkpointed: []*Offset{offset(0, 9)},
+
+			wantCommittable: []*Offset{offset(0, 5)},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tracker := NewOffsetTracker()
+			for _, o := range tc.tracked {
+				tracker.Track(o)
+			}
+			for _, o := range tc.processed {
+				tracker.MarkProcessed(o)
+			}
+
+			committable := tracker.Committable(tc.checkpointed)
+			require.Equal(t, tc.wantCommittable, committable)
+		})
+	}
+}
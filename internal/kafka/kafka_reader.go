@@ -12,12 +12,19 @@ import (
 
 type Reader struct {
 	reader *kafka.Reader
+	// revoked is notified when the partitions assigned to the reader are
+	// revoked by a consumer group rebalance.
+	revoked chan struct{}
 }
 
 type ReaderConfig struct {
 	Conn                     ConnConfig
 	ConsumerGroupID          string
 	ConsumerGroupStartOffset string
+	// ReadCommitted sets the reader isolation level to read committed, so that
+	// only messages from committed transactions are consumed. Defaults to
+	// false (read uncommitted).
+	ReadCommitted bool
 }
 
 const (
@@ -31,6 +38,7 @@ func NewReader(config ReaderConfig, logger loglib.Logger) (*Reader, error) {
 	logger.Info("creating kafka reader", loglib.Fields{
 		"kafka_servers": config.Conn.Servers,
 		"tls_enabled":   config.Conn.TLS.Enabled,
+		"sasl_enabled":  config.Conn.saslEnabled(),
 	})
 
 	var startOffset int64
@@ -44,12 +52,19 @@ func NewReader(config ReaderConfig, logger loglib.Logger) (*Reader, error) {
 		return nil, fmt.Errorf("unsupported start offset [%s], must be one of [%s, %s]", config.ConsumerGroupStartOffset, earliestOffset, latestOffset)
 	}
 
-	dialer, err := buildDialer(config.Conn.TLS)
+	dialer, err := buildDialer(&config.Conn)
 	if err != nil {
 		return nil, err
 	}
 
+	isolationLevel := kafka.ReadUncommitted
+	if config.ReadCommitted {
+		isolationLevel = kafka.ReadCommitted
+	}
+
+	revoked := make(chan struct{}, 1)
 	return &Reader{
+		revoked: revoked,
 		reader: kafka.NewReader(kafka.ReaderConfig{
 			Brokers:        config.Conn.Servers,
 			Topic:          config.Conn.Topic.Name,
@@ -60,6 +75,12 @@ func NewReader(config ReaderConfig, logger loglib.Logger) (*Reader, error) {
 			Logger:         makeLogger(logger.Trace),
 			ErrorLogger:    makeErrLogger(logger.Error),
 			StartOffset:    startOffset,
+			IsolationLevel: isolationLevel,
+			// the default balancers, wrapped to be notified of the rebalances
+			GroupBalancers: []kafka.GroupBalancer{
+				&revokeNotifier{GroupBalancer: kafka.RangeGroupBalancer{}, revoked: revoked},
+				&revokeNotifier{GroupBalancer: kafka.RoundRobinGroupBalancer{}, revoked: revoked},
+			},
 		}),
 	}, nil
 }
@@ -94,6 +115,33 @@ func (r *Reader) CommitOffsets(ctx context.Context, offsets ...*Offset) error {
 	return r.reader.CommitMessages(ctx, kafkaMsgs...)
 }
 
+// PartitionsRevoked returns a channel notified when the partitions assigned to
+// the reader are revoked by a consumer group rebalance. Since the rebalances
+// are eager, all the partitions are revoked, and the ones assigned to the
+// reader in the new generation are consumed from their last committed offset.
+// The reader joining the group for the first time is notified too.
+func (r *Reader) PartitionsRevoked() <-chan struct{} {
+	return r.revoked
+}
+
 func (r *Reader) Close() error {
 	return r.reader.Close()
 }
+
+// revokeNotifier is a group balancer that notifies when the reader joins the
+// consumer group, which happens once its previous generation has ended and its
+// partitions have been revoked.
+type revokeNotifier struct {
+	kafka.GroupBalancer
+	revoked chan struct{}
+}
+
+func (n *revokeNotifier) UserData() ([]byte, error) {
+	// the notification is not blocking, pending notifications are not
+	// repeated
+	select {
+	case n.revoked <- struct{}{}:
+	default:
+	}
+	return n.GroupBalancer.UserData()
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	tlslib "github.com/xataio/pgstream/internal/tls"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+// TransactionalMessageWriter writes messages and commits consumer group offsets
+// atomically, within a single kafka transaction.
+type TransactionalMessageWriter interface {
+	WriteMessagesWithOffsets(ctx context.Context, offsets []*Offset, msgs ...Message) error
+	Close() error
+}
+
+// TransactionalWriter is a kafka writer that uses an idempotent, transactional
+// producer. The kafkago library doesn't support producing transactional record
+// batches, so the sarama library producer is used instead.
+//
+// The transactions don't provide exactly-once delivery when the consumer group
+// rebalances. Sarama commits the offsets without the consumer group
+// generation and member id, and the transactional id is per writer instance
+// rather than per input partition, so a writer whose partitions were revoked
+// is not fenced off and can still commit its in-flight transaction.
+type TransactionalWriter struct {
+	producer        txnProducer
+	topic           string
+	consumerGroupID string
+	logger          loglib.Logger
+}
+
+type TransactionalWriterConfig struct {
+	Conn ConnConfig
+	// TransactionalID identifies the producer across restarts. It must be
+	// stable and unique per writer instance, since it's used by kafka to fence
+	// off zombie producers.
+	TransactionalID string
+	// ConsumerGroupID is the consumer group whose offsets are committed as
+	// part of the transaction.
+	ConsumerGroupID string
+	// BatchBytes limits the maximum size of a message in bytes. Defaults to
+	// 1048576 bytes.
+	BatchBytes int64
+}
+
+// txnProducer is the subset of the sarama sync producer used by the
+// transactional writer.
+type txnProducer interface {
+	SendMessages(msgs []*sarama.ProducerMessage) error
+	TxnStatus() sarama.ProducerTxnStatusFlag
+	BeginTxn() error
+	CommitTxn() error
+	AbortTxn() error
+	AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupID string) error
+	Close() error
+}
+
+var errTransactionalIDRequired = errors.New("transactional id is required for the transactional kafka writer")
+
+// NewTransactionalWriter returns a kafka writer that produces messages to the
+// configured topic within kafka transactions. It uses the CRC32 hash function
+// to determine which partition to route messages to, consistent with the
+// non-transactional writer.
+//
+// If the topic auto create setting is enabled in the config, it will create it.
+func NewTransactionalWriter(config TransactionalWriterConfig, logger loglib.Logger) (*TransactionalWriter, error) {
+	logger.Info("creating kafka transactional writer", loglib.Fields{
+		"kafka_servers":    config.Conn.Servers,
+		"tls_enabled":      config.Conn.TLS.Enabled,
+		"sasl_enabled":     config.Conn.saslEnabled(),
+		"transactional_id": config.TransactionalID,
+	})
+
+	if config.TransactionalID == "" {
+		return nil, errTransactionalIDRequired
+	}
+
+	if config.Conn.Topic.AutoCreate {
+		if err := createTopic(&config.Conn); err != nil {
+			return nil, err
+		}
+	}
+
+	saramaCfg, err := buildSaramaConfig(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewSyncProducer(config.Conn.Servers, saramaCfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating transactional producer: %w", err)
+	}
+
+	return &TransactionalWriter{
+		producer:        producer,
+		topic:           config.Conn.Topic.Name,
+		consumerGroupID: config.ConsumerGroupID,
+		logger:          logger,
+	}, nil
+}
+
+// WriteMessagesWithOffsets writes the messages on input and commits the
+// consumer group offsets in a single transaction. The offsets are those of the
+// last processed messages, the committed offset will be the next one to be
+// consumed. If any step fails, the transaction is aborted. The offsets are
+// committed even if their partitions have been reassigned to another consumer
+// in the meantime, see TransactionalWriter.
+func (w *TransactionalWriter) WriteMessagesWithOffsets(_ context.Context, offsets []*Offset, msgs ...Message) error {
+	if err := w.producer.BeginTxn(); err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+
+	if len(msgs) > 0 {
+		if err := w.producer.SendMessages(w.toProducerMessages(msgs)); err != nil {
+			return w.abort(fmt.Errorf("producing messages: %w", err))
+		}
+	}
+
+	if len(offsets) > 0 {
+		if err := w.producer.AddOffsetsToTxn(toPartitionOffsets(offsets), w.consumerGroupID); err != nil {
+			return w.abort(fmt.Errorf("adding offsets to transaction: %w", err))
+		}
+	}
+
+	if err := w.producer.CommitTxn(); err != nil {
+		return w.abort(fmt.Errorf("committing transaction: %w", err))
+	}
+
+	return nil
+}
+
+func (w *TransactionalWriter) Close() error {
+	return w.producer.Close()
+}
+
+// abort aborts the ongoing transaction, unless the producer is in a fatal
+// state, in which case it can no longer be used.
+func (w *TransactionalWriter) abort(txnErr error) error {
+	if w.producer.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0 {
+		return fmt.Errorf("fatal transaction error: %w", txnErr)
+	}
+
+	w.logger.Warn(txnErr, "aborting kafka transaction")
+	if err := w.producer.AbortTxn(); err != nil {
+		return fmt.Errorf("aborting transaction: %w: %w", err, txnErr)
+	}
+	return txnErr
+}
+
+func (w *TransactionalWriter) toProducerMessages(msgs []Message) []*sarama.ProducerMessage {
+	producerMsgs := make([]*sarama.ProducerMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		topic := msg.Topic
+		if topic == "" {
+			topic = w.topic
+		}
+
+		headers := make([]sarama.RecordHeader, 0, len(msg.Headers))
+		for _, h := range msg.Headers {
+			headers = append(headers, sarama.RecordHeader{
+				Key:   []byte(h.Key),
+				Value: h.Value,
+			})
+		}
+
+		producerMsgs = append(producerMsgs, &sarama.ProducerMessage{
+			Topic:     topic,
+			Key:       sarama.ByteEncoder(msg.Key),
+			Value:     sarama.ByteEncoder(msg.Value),
+			Headers:   headers,
+			Timestamp: msg.Time,
+		})
+	}
+	return producerMsgs
+}
+
+func toPartitionOffsets(offsets []*Offset) map[string][]*sarama.PartitionOffsetMetadata {
+	partitionOffsets := make(map[string][]*sarama.PartitionOffsetMetadata, len(offsets))
+	for _, offset := range offsets {
+		partitionOffsets[offset.Topic] = append(partitionOffsets[offset.Topic], &sarama.PartitionOffsetMetadata{
+			Partition: int32(offset.Partition),
+			// the committed offset is the next one to be consumed
+			Offset:      offset.Offset + 1,
+			LeaderEpoch: -1,
+		})
+	}
+	return partitionOffsets
+}
+
+func buildSaramaConfig(config *TransactionalWriterConfig) (*sarama.Config, error) {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = "pgstream"
+	cfg.Version = sarama.V2_5_0_0
+	cfg.Net.MaxOpenRequests = 1
+	cfg.Producer.Idempotent = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Partitioner = sarama.NewConsistentCRCHashPartitioner
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Return.Errors = true
+	cfg.Producer.Transaction.ID = config.TransactionalID
+	if config.BatchBytes > 0 {
+		cfg.Producer.MaxMessageBytes = int(config.BatchBytes)
+	}
+
+	tlsConfig, err := tlslib.NewConfig(config.Conn.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("building TLS config: %w", err)
+	}
+	if tlsConfig != nil {
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsConfig
+	}
+
+	if config.Conn.saslEnabled() {
+		if err := applySaramaSASLConfig(cfg, config.Conn.SASL); err != nil {
+			return nil, fmt.Errorf("building SASL config: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
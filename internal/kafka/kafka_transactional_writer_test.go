@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+func TestTransactionalWriter_WriteMessagesWithOffsets(t *testing.T) {
+	t.Parallel()
+
+	testTopic := "test-topic"
+	testGroupID := "test-group"
+	testMsg := Message{
+		Key:   []byte("key"),
+		Value: []byte("value"),
+	}
+	testOffsets := []*Offset{
+		{Topic: "source-topic", Partition: 0, Offset: 9},
+		{Topic: "source-topic", Partition: 1, Offset: 4},
+	}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name     string
+		producer *mockTxnProducer
+		msgs     []Message
+
+		wantAborted bool
+		wantErr     error
+	}{
+		{
+			name:     "ok",
+			producer: &mockTxnProducer{},
+			msgs:     []Message{testMsg},
+
+			wantErr: nil,
+		},
+		{
+			name:     "ok - only offsets",
+			producer: &mockTxnProducer{},
+			msgs:     []Message{},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - beginning transaction",
+			producer: &mockTxnProducer{
+				beginErr: errTest,
+			},
+			msgs: []Message{testMsg},
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - producing messages",
+			producer: &mockTxnProducer{
+				sendErr: errTest,
+			},
+			msgs: []Message{testMsg},
+
+			wantAborted: true,
+			wantErr:     errTest,
+		},
+		{
+			name: "error - adding offsets",
+			producer: &mockTxnProducer{
+				offsetsErr: errTest,
+			},
+			msgs: []Message{testMsg},
+
+			wantAborted: true,
+			wantErr:     errTest,
+		},
+		{
+			name: "error - committing transaction",
+			producer: &mockTxnProducer{
+				commitErr: errTest,
+			},
+			msgs: []Message{testMsg},
+
+			wantAborted: true,
+			wantErr:     errTest,
+		},
+		{
+			name: "error - fatal error",
+			producer: &mockTxnProducer{
+				commitErr: errTest,
+				status:    sarama.ProducerTxnFlagFatalError,
+			},
+			msgs: []Message{testMsg},
+
+			wantAborted: false,
+			wantErr:     errTest,
+		},
+		{
+			name: "error - aborting transaction",
+			producer: &mockTxnProducer{
+				sendErr:  errTest,
+				abortErr: errors.New("abort error"),
+			},
+			msgs: []Message{testMsg},
+
+			wantAborted: true,
+			wantErr:     errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := &TransactionalWriter{
+				producer:        tc.producer,
+				topic:           testTopic,
+				consumerGroupID: testGroupID,
+				logger:          loglib.NewNoopLogger(),
+			}
+
+			err := w.WriteMessagesWithOffsets(context.Background(), testOffsets, tc.msgs...)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantAborted, tc.producer.aborted)
+			if tc.wantErr != nil {
+				require.False(t, tc.producer.committed)
+				return
+			}
+
+			require.True(t, tc.producer.committed)
+			require.Len(t, tc.producer.sentMsgs, len(tc.msgs))
+			for _, msg := range tc.producer.sentMsgs {
+				require.Equal(t, testTopic, msg.Topic)
+			}
+			require.Equal(t, testGroupID, tc.producer.groupID)
+			require.Equal(t, map[string][]*sarama.PartitionOffsetMetadata{
+				"source-topic": {
+					{Partition: 0, Offset: 10, LeaderEpoch: -1},
+					{Partition: 1, Offset: 5, LeaderEpoch: -1},
+				},
+			}, tc.producer.offsets)
+		})
+	}
+}
+
+type mockTxnProducer struct {
+	beginErr   error
+	sendErr    error
+	offsetsErr error
+	commitErr  error
+	abortErr   error
+	status     sarama.ProducerTxnStatusFlag
+
+	sentMsgs  []*sarama.ProducerMessage
+	offsets   map[string][]*sarama.PartitionOffsetMetadata
+	groupID   string
+	committed bool
+	aborted   bool
+}
+
+func (m *mockTxnProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	m.sentMsgs = msgs
+	return m.sendErr
+}
+
+func (m *mockTxnProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return m.status
+}
+
+func (m *mockTxnProducer) BeginTxn() error {
+	return m.beginErr
+}
+
+func (m *mockTxnProducer) CommitTxn() error {
+	if m.commitErr != nil {
+		return m.commitErr
+	}
+	m.committed = true
+	return nil
+}
+
+func (m *mockTxnProducer) AbortTxn() error {
+	m.aborted = true
+	return m.abortErr
+}
+
+func (m *mockTxnProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupID string) error {
+	m.offsets = offsets
+	m.groupID = groupID
+	return m.offsetsErr
+}
+
+func (m *mockTxnProducer) Close() error {
+	return nil
+}
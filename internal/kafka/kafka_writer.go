@@ -25,6 +25,9 @@ type Writer struct {
 // Message is a wrapper around the kafkago library message
 type Message kafka.Message
 
+// Header is a wrapper around the kafkago library message header
+type Header = kafka.Header
+
 type WriterConfig struct {
 	Conn ConnConfig
 	// BatchTimeout is the time limit on how often incomplete message batches
@@ -48,6 +51,7 @@ func NewWriter(config WriterConfig, logger loglib.Logger) (*Writer, error) {
 	logger.Info("creating kafka writer", loglib.Fields{
 		"kafka_servers": config.Conn.Servers,
 		"tls_enabled":   config.Conn.TLS.Enabled,
+		"sasl_enabled":  config.Conn.saslEnabled(),
 	})
 
 	if config.Conn.Topic.AutoCreate {
@@ -56,7 +60,7 @@ func NewWriter(config WriterConfig, logger loglib.Logger) (*Writer, error) {
 		}
 	}
 
-	transport, err := buildTransport(config.Conn.TLS)
+	transport, err := buildTransport(&config.Conn)
 	if err != nil {
 		return nil, err
 	}
@@ -108,14 +112,23 @@ func createTopic(cfg *ConnConfig) error {
 	})
 }
 
-func buildTransport(cfg *tlslib.Config) (kafka.RoundTripper, error) {
-	if cfg.Enabled {
-		tlsConfig, err := tlslib.NewConfig(cfg)
-		if err != nil {
-			return nil, fmt.Errorf("building TLS config: %w", err)
-		}
-		return &kafka.Transport{TLS: tlsConfig}, nil
+func buildTransport(cfg *ConnConfig) (kafka.RoundTripper, error) {
+	if !cfg.TLS.Enabled && !cfg.saslEnabled() {
+		return kafka.DefaultTransport, nil
+	}
+
+	tlsConfig, err := tlslib.NewConfig(cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("building TLS config: %w", err)
 	}
 
-	return kafka.DefaultTransport, nil
+	saslMechanism, err := buildSASLMechanism(cfg.SASL)
+	if err != nil {
+		return nil, fmt.Errorf("building SASL config: %w", err)
+	}
+
+	return &kafka.Transport{
+		TLS:  tlsConfig,
+		SASL: saslMechanism,
+	}, nil
 }
@@ -9,9 +9,10 @@ import (
 )
 
 type Reader struct {
-	FetchMessageFn  func(ctx context.Context) (*kafka.Message, error)
-	CommitOffsetsFn func(ctx context.Context, offsets ...*kafka.Offset) error
-	CloseFn         func() error
+	FetchMessageFn      func(ctx context.Context) (*kafka.Message, error)
+	CommitOffsetsFn     func(ctx context.Context, offsets ...*kafka.Offset) error
+	PartitionsRevokedFn func() <-chan struct{}
+	CloseFn             func() error
 }
 
 func (m *Reader) FetchMessage(ctx context.Context) (*kafka.Message, error) {
@@ -22,6 +23,10 @@ func (m *Reader) CommitOffsets(ctx context.Context, offsets ...*kafka.Offset) er
 	return m.CommitOffsetsFn(ctx, offsets...)
 }
 
+func (m *Reader) PartitionsRevoked() <-chan struct{} {
+	return m.PartitionsRevokedFn()
+}
+
 func (m *Reader) Close() error {
 	return m.CloseFn()
 }
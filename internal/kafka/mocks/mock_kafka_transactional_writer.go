@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+
+	"github.com/xataio/pgstream/internal/kafka"
+)
+
+type TransactionalWriter struct {
+	WriteMessagesWithOffsetsFn func(context.Context, []*kafka.Offset, ...kafka.Message) error
+	CloseFn                    func() error
+}
+
+func (m *TransactionalWriter) WriteMessagesWithOffsets(ctx context.Context, offsets []*kafka.Offset, msgs ...kafka.Message) error {
+	return m.WriteMessagesWithOffsetsFn(ctx, offsets, msgs...)
+}
+
+func (m *TransactionalWriter) Close() error {
+	return m.CloseFn()
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	xdgscram "github.com/xdg-go/scram"
+)
+
+var errUnsupportedSASLMechanism = errors.New("unsupported SASL mechanism")
+
+// buildSASLMechanism returns the kafka-go SASL mechanism for the configuration
+// on input. It returns a nil mechanism if SASL is not configured.
+func buildSASLMechanism(cfg *SASLConfig) (sasl.Mechanism, error) {
+	if cfg == nil || cfg.Mechanism == "" {
+		return nil, nil
+	}
+
+	switch cfg.Mechanism {
+	case SASLMechanismPlain:
+		return plain.Mechanism{
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}, nil
+	case SASLMechanismSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case SASLMechanismSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("%w: %s, must be one of [%s, %s, %s]", errUnsupportedSASLMechanism, cfg.Mechanism,
+			SASLMechanismPlain, SASLMechanismSCRAMSHA256, SASLMechanismSCRAMSHA512)
+	}
+}
+
+// applySaramaSASLConfig sets the SASL configuration on input in the sarama
+// config.
+func applySaramaSASLConfig(cfg *sarama.Config, saslCfg *SASLConfig) error {
+	cfg.Net.SASL.Enable = true
+	cfg.Net.SASL.Handshake = true
+	cfg.Net.SASL.User = saslCfg.Username
+	cfg.Net.SASL.Password = saslCfg.Password
+
+	switch saslCfg.Mechanism {
+	case SASLMechanismPlain:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case SASLMechanismSCRAMSHA256:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: xdgscram.SHA256}
+		}
+	case SASLMechanismSCRAMSHA512:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: xdgscram.SHA512}
+		}
+	default:
+		return fmt.Errorf("%w: %s, must be one of [%s, %s, %s]", errUnsupportedSASLMechanism, saslCfg.Mechanism,
+			SASLMechanismPlain, SASLMechanismSCRAMSHA256, SASLMechanismSCRAMSHA512)
+	}
+
+	return nil
+}
+
+// scramClient implements the sarama SCRAM client interface.
+type scramClient struct {
+	hashGenerator xdgscram.HashGeneratorFcn
+	conversation  *xdgscram.ClientConversation
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
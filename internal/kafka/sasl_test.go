@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_buildSASLMechanism(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  *SASLConfig
+
+		wantMechanismName string
+		wantErr           error
+	}{
+		{
+			name: "ok - no config",
+			cfg:  nil,
+
+			wantMechanismName: "",
+			wantErr:           nil,
+		},
+		{
+			name: "ok - no mechanism",
+			cfg:  &SASLConfig{},
+
+			wantMechanismName: "",
+			wantErr:           nil,
+		},
+		{
+			name: "ok - plain",
+			cfg: &SASLConfig{
+				Mechanism: SASLMechanismPlain,
+				Username:  "user",
+				Password:  "pass",
+			},
+
+			wantMechanismName: "PLAIN",
+			wantErr:           nil,
+		},
+		{
+			name: "ok - scram sha 256",
+			cfg: &SASLConfig{
+				Mechanism: SASLMechanismSCRAMSHA256,
+				Username:  "user",
+				Password:  "pass",
+			},
+
+			wantMechanismName: "SCRAM-SHA-256",
+			wantErr:           nil,
+		},
+		{
+			name: "ok - scram sha 512",
+			cfg: &SASLConfig{
+				Mechanism: SASLMechanismSCRAMSHA512,
+				Username:  "user",
+				Password:  "pass",
+			},
+
+			wantMechanismName: "SCRAM-SHA-512",
+			wantErr:           nil,
+		},
+		{
+			name: "error - unsupported mechanism",
+			cfg: &SASLConfig{
+				Mechanism: "GSSAPI",
+			},
+
+			wantErr: errUnsupportedSASLMechanism,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mechanism, err := buildSASLMechanism(tc.cfg)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantMechanismName == "" {
+				require.Nil(t, mechanism)
+				return
+			}
+			require.Equal(t, tc.wantMechanismName, mechanism.Name())
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+
+	"github.com/xataio/pgstream/internal/typesense"
+)
+
+type Client struct {
+	CreateCollectionFn func(ctx context.Context, schema *typesense.CollectionSchema) error
+	GetCollectionFn    func(ctx context.Context, name string) (*typesense.CollectionSchema, error)
+	UpdateCollectionFn func(ctx context.Context, name string, fields []typesense.Field) error
+	DeleteCollectionFn func(ctx context.Context, name string) error
+	ImportDocumentsFn  func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error)
+	DeleteDocumentsFn  func(ctx context.Context, collection, filterBy string) (int, error)
+	UpdateDocumentsFn  func(ctx context.Context, collection, filterBy string, fields map[string]any) (int, error)
+	SearchDocumentsFn  func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error)
+}
+
+func (m *Client) CreateCollection(ctx context.Context, schema *typesense.CollectionSchema) error {
+	return m.CreateCollectionFn(ctx, schema)
+}
+
+func (m *Client) GetCollection(ctx context.Context, name string) (*typesense.CollectionSchema, error) {
+	return m.GetCollectionFn(ctx, name)
+}
+
+func (m *Client) UpdateCollection(ctx context.Context, name string, fields []typesense.Field) error {
+	return m.UpdateCollectionFn(ctx, name, fields)
+}
+
+func (m *Client) DeleteCollection(ctx context.Context, name string) error {
+	return m.DeleteCollectionFn(ctx, name)
+}
+
+func (m *Client) ImportDocuments(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+	return m.ImportDocumentsFn(ctx, collection, docs, action)
+}
+
+func (m *Client) DeleteDocuments(ctx context.Context, collection, filterBy string) (int, error) {
+	return m.DeleteDocumentsFn(ctx, collection, filterBy)
+}
+
+func (m *Client) UpdateDocuments(ctx context.Context, collection, filterBy string, fields map[string]any) (int, error) {
+	return m.UpdateDocumentsFn(ctx, collection, filterBy, fields)
+}
+
+func (m *Client) SearchDocuments(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+	return m.SearchDocumentsFn(ctx, collection, req)
+}
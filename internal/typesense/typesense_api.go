@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CollectionSchema is the definition of a typesense collection.
+type CollectionSchema struct {
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields"`
+	// EnableNestedFields allows the object fields to be indexed.
+	EnableNestedFields bool `json:"enable_nested_fields,omitempty"`
+}
+
+// Field is the definition of a collection field. Fields not declared in the
+// collection schema are stored, but not indexed.
+type Field struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Facet    bool   `json:"facet,omitempty"`
+	// Index is set to false for fields that are stored but not indexed.
+	Index *bool `json:"index,omitempty"`
+	// NumDim is the number of dimensions of the vector fields.
+	NumDim int `json:"num_dim,omitempty"`
+	// Drop removes the field from the collection schema when updating it.
+	Drop bool `json:"drop,omitempty"`
+}
+
+// Field types supported by typesense. The array types use the `[]` suffix.
+const (
+	FieldTypeString = "string"
+	FieldTypeInt64  = "int64"
+	FieldTypeFloat  = "float"
+	FieldTypeBool   = "bool"
+	FieldTypeObject = "object"
+	FieldTypeAuto   = "auto"
+
+	arrayTypeSuffix = "[]"
+)
+
+// ArrayType returns the array variant of the field type on input.
+func ArrayType(fieldType string) string {
+	return fieldType + arrayTypeSuffix
+}
+
+// ImportAction is the write operation applied to the imported documents.
+type ImportAction string
+
+const (
+	// ImportCreate fails for the documents that already exist.
+	ImportCreate ImportAction = "create"
+	// ImportUpsert replaces the existing documents.
+	ImportUpsert ImportAction = "upsert"
+	// ImportUpdate sets the fields on input in the existing documents, and
+	// fails for the documents that don't exist.
+	ImportUpdate ImportAction = "update"
+)
+
+// ImportResult is the result of the import of a single document, in the same
+// order as the documents on input.
+type ImportResult struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	// Code is the http status code of the failed documents.
+	Code int `json:"code,omitempty"`
+}
+
+type SearchRequest struct {
+	FilterBy string
+	SortBy   string
+	// IncludeFields limits the document fields returned. All fields are
+	// returned when empty.
+	IncludeFields []string
+	Page          int
+	PerPage       int
+}
+
+type SearchResponse struct {
+	Found int         `json:"found"`
+	Page  int         `json:"page"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+type SearchHit struct {
+	Document map[string]any `json:"document"`
+}
+
+// MaxPerPage is the maximum number of documents returned by a search request.
+const MaxPerPage = 250
+
+// FilterValues returns the filter expression list with the values on input,
+// escaped with backticks so that they can contain commas and other special
+// characters.
+func FilterValues(values []string) string {
+	escaped := make([]string, 0, len(values))
+	for _, v := range values {
+		escaped = append(escaped, fmt.Sprintf("`%s`", v))
+	}
+	return "[" + strings.Join(escaped, ",") + "]"
+}
@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	httplib "github.com/xataio/pgstream/internal/http"
+)
+
+type Client interface {
+	CreateCollection(ctx context.Context, schema *CollectionSchema) error
+	GetCollection(ctx context.Context, name string) (*CollectionSchema, error)
+	// UpdateCollection adds or drops the fields on input from the collection
+	// schema.
+	UpdateCollection(ctx context.Context, name string, fields []Field) error
+	DeleteCollection(ctx context.Context, name string) error
+	// ImportDocuments writes the documents on input with the given action,
+	// returning the result of each of them.
+	ImportDocuments(ctx context.Context, collection string, docs []map[string]any, action ImportAction) ([]ImportResult, error)
+	// DeleteDocuments deletes the documents matching the filter, and returns
+	// the number of documents deleted.
+	DeleteDocuments(ctx context.Context, collection, filterBy string) (int, error)
+	// UpdateDocuments sets the fields on input in the documents matching the
+	// filter, and returns the number of documents updated.
+	UpdateDocuments(ctx context.Context, collection, filterBy string, fields map[string]any) (int, error)
+	SearchDocuments(ctx context.Context, collection string, req *SearchRequest) (*SearchResponse, error)
+}
+
+type HTTPClient struct {
+	client httplib.Client
+	url    *url.URL
+	apiKey string
+}
+
+type Config struct {
+	URL    string
+	APIKey string
+}
+
+const (
+	apiKeyHeader   = "X-TYPESENSE-API-KEY"
+	defaultTimeout = 30 * time.Second
+)
+
+func NewClient(cfg Config) (*HTTPClient, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("create typesense client: parsing url: %w", err)
+	}
+	return NewClientWithHTTPClient(u, cfg.APIKey, &http.Client{Timeout: defaultTimeout}), nil
+}
+
+func NewClientWithHTTPClient(u *url.URL, apiKey string, client httplib.Client) *HTTPClient {
+	return &HTTPClient{
+		client: client,
+		url:    u,
+		apiKey: apiKey,
+	}
+}
+
+func (c *HTTPClient) CreateCollection(ctx context.Context, schema *CollectionSchema) error {
+	if err := c.do(ctx, http.MethodPost, "/collections", nil, schema, nil); err != nil {
+		return fmt.Errorf("create collection %s: %w", schema.Name, err)
+	}
+	return nil
+}
+
+func (c *HTTPClient) GetCollection(ctx context.Context, name string) (*CollectionSchema, error) {
+	var schema CollectionSchema
+	if err := c.do(ctx, http.MethodGet, collectionPath(name), nil, nil, &schema); err != nil {
+		return nil, fmt.Errorf("get collection %s: %w", name, err)
+	}
+	return &schema, nil
+}
+
+func (c *HTTPClient) UpdateCollection(ctx context.Context, name string, fields []Field) error {
+	body := map[string]any{"fields": fields}
+	if err := c.do(ctx, http.MethodPatch, collectionPath(name), nil, body, nil); err != nil {
+		return fmt.Errorf("update collection %s: %w", name, err)
+	}
+	return nil
+}
+
+func (c *HTTPClient) DeleteCollection(ctx context.Context, name string) error {
+	if err := c.do(ctx, http.MethodDelete, collectionPath(name), nil, nil, nil); err != nil {
+		return fmt.Errorf("delete collection %s: %w", name, err)
+	}
+	return nil
+}
+
+func (c *HTTPClient) ImportDocuments(ctx context.Context, collection string, docs []map[string]any, action ImportAction) ([]ImportResult, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	// the documents are imported as JSON lines
+	body := &bytes.Buffer{}
+	encoder := json.NewEncoder(body)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, fmt.Errorf("import documents to %s: encoding document: %w", collection, err)
+		}
+	}
+
+	query := url.Values{"action": []string{string(action)}}
+	resp, err := c.send(ctx, http.MethodPost, collectionPath(collection)+"/documents/import", query, body, "text/plain")
+	if err != nil {
+		return nil, fmt.Errorf("import documents to %s: %w", collection, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("import documents to %s: %w", collection, responseError(resp))
+	}
+
+	results := make([]ImportResult, 0, len(docs))
+	scanner := bufio.NewScanner(resp.Body)
+	// the failed results include the document, which can be large
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var result ImportResult
+		if err := json.Unmarshal(line, &result); err != nil {
+			return nil, fmt.Errorf("import documents to %s: decoding result: %w", collection, err)
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("import documents to %s: reading results: %w", collection, err)
+	}
+	if len(results) != len(docs) {
+		return nil, fmt.Errorf("import documents to %s: got %d results for %d documents", collection, len(results), len(docs))
+	}
+	return results, nil
+}
+
+func (c *HTTPClient) DeleteDocuments(ctx context.Context, collection, filterBy string) (int, error) {
+	var res struct {
+		NumDeleted int `json:"num_deleted"`
+	}
+	query := url.Values{"filter_by": []string{filterBy}}
+	if err := c.do(ctx, http.MethodDelete, collectionPath(collection)+"/documents", query, nil, &res); err != nil {
+		return 0, fmt.Errorf("delete documents from %s: %w", collection, err)
+	}
+	return res.NumDeleted, nil
+}
+
+func (c *HTTPClient) UpdateDocuments(ctx context.Context, collection, filterBy string, fields map[string]any) (int, error) {
+	var res struct {
+		NumUpdated int `json:"num_updated"`
+	}
+	query := url.Values{"filter_by": []string{filterBy}}
+	if err := c.do(ctx, http.MethodPatch, collectionPath(collection)+"/documents", query, fields, &res); err != nil {
+		return 0, fmt.Errorf("update documents in %s: %w", collection, err)
+	}
+	return res.NumUpdated, nil
+}
+
+func (c *HTTPClient) SearchDocuments(ctx context.Context, collection string, req *SearchRequest) (*SearchResponse, error) {
+	query := url.Values{"q": []string{"*"}}
+	if req.FilterBy != "" {
+		query.Set("filter_by", req.FilterBy)
+	}
+	if req.SortBy != "" {
+		query.Set("sort_by", req.SortBy)
+	}
+	if len(req.IncludeFields) > 0 {
+		query.Set("include_fields", strings.Join(req.IncludeFields, ","))
+	}
+	if req.Page > 0 {
+		query.Set("page", strconv.Itoa(req.Page))
+	}
+	if req.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(req.PerPage))
+	}
+
+	var res SearchResponse
+	if err := c.do(ctx, http.MethodGet, collectionPath(collection)+"/documents/search", query, nil, &res); err != nil {
+		return nil, fmt.Errorf("search documents in %s: %w", collection, err)
+	}
+	return &res, nil
+}
+
+// do sends a request with the JSON body on input, and decodes the JSON
+// response into the result, unless it's nil.
+func (c *HTTPClient) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request body: %w", err)
+		}
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+
+	resp, err := c.send(ctx, method, path, query, reqBody, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return responseError(resp)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+func (c *HTTPClient) send(ctx context.Context, method, path string, query url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	u := *c.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(apiKeyHeader, c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// the server could be restarting or unreachable
+		return nil, RetryableError{Cause: err}
+	}
+	return resp, nil
+}
+
+func collectionPath(name string) string {
+	return "/collections/" + url.PathEscape(name)
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	httpmocks "github.com/xataio/pgstream/internal/http/mocks"
+)
+
+const testAPIKey = "test-key"
+
+func newTestClient(t *testing.T, doFn func(*http.Request) (*http.Response, error)) *HTTPClient {
+	u, err := url.Parse("http://localhost:8108")
+	require.NoError(t, err)
+	return NewClientWithHTTPClient(u, testAPIKey, &httpmocks.Client{DoFn: doFn})
+}
+
+func newTestResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestHTTPClient_ImportDocuments(t *testing.T) {
+	t.Parallel()
+
+	testDocs := []map[string]any{
+		{"id": "a", "_version": 1},
+		{"id": "b", "_version": 2},
+	}
+
+	tests := []struct {
+		name   string
+		doFn   func(*http.Request) (*http.Response, error)
+		docs   []map[string]any
+		action ImportAction
+
+		wantResults []ImportResult
+		wantErr     error
+	}{
+		{
+			name: "ok",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "/collections/public/documents/import", r.URL.Path)
+				require.Equal(t, "upsert", r.URL.Query().Get("action"))
+				require.Equal(t, testAPIKey, r.Header.Get(apiKeyHeader))
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, "{\"_version\":1,\"id\":\"a\"}\n{\"_version\":2,\"id\":\"b\"}\n", string(body))
+				return newTestResponse(http.StatusOK, "{\"success\":true}\n{\"success\":false,\"error\":\"bad field\",\"code\":400}"), nil
+			},
+			docs:   testDocs,
+			action: ImportUpsert,
+
+			wantResults: []ImportResult{
+				{Success: true},
+				{Success: false, Error: "bad field", Code: 400},
+			},
+		},
+		{
+			name: "ok - no documents",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return nil, errors.New("doFn: should not be called")
+			},
+			docs:   nil,
+			action: ImportUpsert,
+
+			wantResults: nil,
+		},
+		{
+			name: "error - collection not found",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return newTestResponse(http.StatusNotFound, `{"message":"Not found."}`), nil
+			},
+			docs:   testDocs,
+			action: ImportUpsert,
+
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "error - missing results",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return newTestResponse(http.StatusOK, `{"success":true}`), nil
+			},
+			docs:   testDocs,
+			action: ImportUpsert,
+
+			wantErr: errors.New("import documents to public: got 1 results for 2 documents"),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := newTestClient(t, tc.doFn)
+			results, err := client.ImportDocuments(context.Background(), "public", tc.docs, tc.action)
+			if !errors.Is(err, tc.wantErr) {
+				require.EqualError(t, err, tc.wantErr.Error())
+			}
+			require.Equal(t, tc.wantResults, results)
+		})
+	}
+}
+
+func TestHTTPClient_SearchDocuments(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, func(r *http.Request) (*http.Response, error) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/collections/public/documents/search", r.URL.Path)
+		require.Equal(t, url.Values{
+			"q":              []string{"*"},
+			"filter_by":      []string{"id:[`a`,`b`]"},
+			"sort_by":        []string{"_version:desc"},
+			"include_fields": []string{"id,_version"},
+			"page":           []string{"1"},
+			"per_page":       []string{"250"},
+		}, r.URL.Query())
+		return newTestResponse(http.StatusOK, `{"found":1,"page":1,"hits":[{"document":{"id":"a","_version":1}}]}`), nil
+	})
+
+	res, err := client.SearchDocuments(context.Background(), "public", &SearchRequest{
+		FilterBy:      "id:" + FilterValues([]string{"a", "b"}),
+		SortBy:        "_version:desc",
+		IncludeFields: []string{"id", "_version"},
+		Page:          1,
+		PerPage:       MaxPerPage,
+	})
+	require.NoError(t, err)
+	require.Equal(t, &SearchResponse{
+		Found: 1,
+		Page:  1,
+		Hits: []SearchHit{
+			{Document: map[string]any{"id": "a", "_version": float64(1)}},
+		},
+	}, res)
+}
+
+func TestHTTPClient_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		doFn func(*http.Request) (*http.Response, error)
+
+		wantErr        error
+		wantRetryable  bool
+		wantErrMessage string
+	}{
+		{
+			name: "already exists",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return newTestResponse(http.StatusConflict, `{"message":"A collection with name public already exists."}`), nil
+			},
+			wantErr:        ErrAlreadyExists,
+			wantErrMessage: "create collection public: typesense resource already exists: A collection with name public already exists.",
+		},
+		{
+			name: "service unavailable",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return newTestResponse(http.StatusServiceUnavailable, `{"message":"Not Ready or Lagging"}`), nil
+			},
+			wantRetryable:  true,
+			wantErrMessage: "create collection public: [503] Not Ready or Lagging",
+		},
+		{
+			name: "connection error",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			},
+			wantRetryable:  true,
+			wantErrMessage: "create collection public: connection refused",
+		},
+		{
+			name: "invalid request",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return newTestResponse(http.StatusBadRequest, `invalid`), nil
+			},
+			wantErrMessage: "create collection public: [400] invalid",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := newTestClient(t, tc.doFn)
+			err := client.CreateCollection(context.Background(), &CollectionSchema{Name: "public"})
+			require.EqualError(t, err, tc.wantErrMessage)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+			}
+			require.Equal(t, tc.wantRetryable, errors.As(err, &RetryableError{}))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrNotFound      = errors.New("typesense resource not found")
+	ErrAlreadyExists = errors.New("typesense resource already exists")
+)
+
+type RetryableError struct {
+	Cause error
+}
+
+func (r RetryableError) Error() string {
+	return fmt.Sprintf("%v", r.Cause)
+}
+
+func (r RetryableError) Unwrap() error {
+	return r.Cause
+}
+
+// the error body is small, limit it in case the response is not the expected
+// one
+const maxErrorBodyBytes = 1024
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	msg := string(body)
+	var errResp struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Message != "" {
+		msg = errResp.Message
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrNotFound, msg)
+	case resp.StatusCode == http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, msg)
+	case isRetryableStatus(resp.StatusCode):
+		return RetryableError{Cause: fmt.Errorf("[%d] %s", resp.StatusCode, msg)}
+	default:
+		return fmt.Errorf("[%d] %s", resp.StatusCode, msg)
+	}
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	default:
+		return statusCode >= http.StatusInternalServerError
+	}
+}
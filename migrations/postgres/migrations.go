@@ -16,6 +16,8 @@
 // migrations/postgres/6_create_pgstream_refresh_schema_function.up.sql
 // migrations/postgres/7_create_pgstream_event_triggers.down.sql
 // migrations/postgres/7_create_pgstream_event_triggers.up.sql
+// migrations/postgres/8_add_pgstream_get_schema_enum_columns.down.sql
+// migrations/postgres/8_add_pgstream_get_schema_enum_columns.up.sql
 package pgmigrations
 
 import (
@@ -372,6 +374,46 @@ func _7_create_pgstream_event_triggersUpSql() (*asset, error) {
 	return a, nil
 }
 
+var __8_add_pgstream_get_schema_enum_columnsDownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9d\x56\x6d\x6f\xe2\x38\x10\xfe\xce\xaf\x98\x0f\x5d\x05\x24\x1a\x69\xbf\x76\xd5\xd5\x71\x34\xed\x72\xea\xc1\x2e\xa4\xea\xae\xaa\x2a\x35\x89\x01\x6f\xf3\x76\xb1\xd3\x96\x7f\x7f\x63\x3b\x09\x49\x6c\xb8\x5e\xf3\x01\x12\x7b\xe6\xf1\xbc\xf9\x99\x39\x3f\x07\xb1\x63\x1c\x36\x65\x1a\x0a\x96\xa5\x80\xef\x21\x89\x63\x1a\x01\x25\xe1\x0e\x04\x4b\x28\x10\x08\x77\x24\xdd\x52\x10\x19\xbe\x6f\xd9\x0b\x4d\x81\x87\x3b\x9a\x10\x29\x9e\x90\x88\xba\x30\x13\xf0\xca\xe2\x18\xb8\xc8\x0a\x14\xdc\x51\x28\x28\x2f\x63\x01\xd9\x46\x7d\x55\xf2\x1a\x68\x70\x7e\x0e\xaf\x3b\x86\xf8\x4a\x07\xf7\x53\x58\x4b\x8d\x3c\x66\x21\x11\x34\x72\xc1\x47\x9d\xac\x14\x79\x29\x10\xb2\x28\x43\x51\x22\xac\x3a\x2d\xcf\xd1\x38\x96\x2a\xd4\x30\x8b\xe8\x9a\x70\x3a\x86\x3c\xa6\xf8\x0f\x82\x3c\xe3\x2a\x91\xb2\x1b\xa0\x11\x13\x2c\xdd\xba\x78\x9c\x3c\xf1\x9e\xc2\x8e\xbc\x68\xe3\x36\xac\xe0\x12\x99\xe6\xf0\xf4\xca\x04\x3a\x4a\xd6\x31\x0d\x32\x16\x71\x20\x1c\x86\xe0\xba\x2e\x8c\x9e\xe4\x39\x59\x11\xd1\x42\xba\xbe\x2d\xc8\x1a\x66\x57\x1c\x01\x88\xd0\x50\x24\x2e\x28\x89\xf6\x68\x3c\x7a\xb0\xa5\x29\x2d\xa4\xf5\x63\x20\x69\x24\x4f\x64\x29\xa7\x85\x40\xf9\x4c\x9a\x26\xb5\xa2\x2c\x75\x04\xec\x69\xa5\x8f\x68\x32\x74\x0e\x97\x1b\x14\x62\xf6\x4c\x75\x3e\xf0\xb8\x1d\x8d\x73\x50\xb6\xe5\xb4\xd8\x64\x45\x42\xd2\x90\xba\x83\xe9\xd2\x9b\xf8\x1e\x2c\x96\xb0\xf4\xbe\xdf\x4e\xa6\x1e\x5c\xdf\xcd\xa7\xfe\x6c\x31\x87\x7c\x8b\xa1\xa2\x24\x71\xb7\x54\x04\x3a\xe0\x43\xfd\x17\xa4\x04\xf3\xe8\x7b\x3f\xfd\x11\xaa\xf9\x77\xcb\xf9\x0a\x7e\xf3\x2c\x5d\x0f\x00\x9f\xdb\xc9\xfc\xe6\x6e\x72\xe3\xc1\xea\xc7\xad\x5a\x58\x79\x3e\x70\x4a\x8a\x70\x17\xe4\x04\x0d\xb8\x44\xe8\x00\x13\x43\xe2\x6c\x3b\xc6\x57\x41\x93\x5c\x09\x4e\x56\x70\x76\x36\xb8\x9f\xf9\xdf\xda\x01\xc4\xd5\xa1\xda\x56\x1b\xf4\x8d\x71\x99\x86\xde\x9e\x3e\xe7\xd6\x9b\xfa\x70\x35\x5b\xf9\x33\xf4\xa1\x59\x97\x0f\x1e\x23\x8d\xe6\x39\x41\xaf\x53\x9e\x2b\x0f\x50\xbb\xe5\xd0\xb8\xaf\x10\xc6\x84\x73\xb7\xa0\x71\x2d\xac\x8d\x3a\x21\x8b\x36\x1d\xe4\xf0\xa3\x91\xba\x5e\x2e\xfe\xee\xd8\xd0\xd1\x57\xcf\x72\x76\xf3\xcd\x87\xbf\x16\xb3\x79\x83\x07\x2a\x0b\x2d\xc3\x25\xfe\xa5\x61\x9a\xda\x83\xc9\xfc\xaa\xb3\xf3\xcc\xd2\x08\x10\x6c\xe8\x14\xce\x18\x9c\xdc\x19\x35\x67\xde\x7f\xf3\x96\x9e\x3d\x24\x97\xed\x88\x28\x85\xd1\xe0\x10\xdb\x06\xa1\x93\x05\xd7\x1a\xc4\xae\x88\x2d\x74\x36\x09\x7c\x3d\x08\x84\x19\x89\x29\x0f\xe9\xb0\x29\x45\x2d\x24\xc5\x51\xee\x50\xa1\x21\xfe\x0a\x1a\xe8\x5d\x79\xa7\x11\x75\x78\x04\x7e\x34\x3a\xa4\x08\x01\x82\x2a\x4b\x2a\x43\x1d\x95\x6e\x8a\x6e\xbd\xeb\x26\x3b\x7d\x63\x64\x9e\x8e\x9c\xa6\xd2\x65\x18\x2f\x4b\x63\x54\xf9\x19\x66\x71\x99\xa4\xbd\x52\xee\xc7\x5b\x3d\x87\x3b\xd1\x0a\xe7\xa9\xba\x34\x35\xfa\x05\xfa\x5f\xf2\x3a\x40\xfd\x80\xf5\xb4\x14\x9b\x88\xa1\xf3\x89\x9f\x7f\xe2\x58\x6b\x47\x50\x64\xbe\x02\x22\x44\xc1\xd6\xa5\xa0\x2e\xbe\xa5\x65\xa2\x92\xa1\x43\x60\x07\x37\x74\x2a\x9f\x2b\x1d\x8b\xd3\xda\x9c\x40\xec\x73\x59\x38\x5d\x6d\x5c\xb4\x19\x82\xcb\x49\x16\xb5\x6d\x91\xda\xa6\x25\x92\x09\xe9\x5b\x5e\xd4\xb8\x11\xdd\xb8\x24\x5a\xb3\xb4\x81\xd4\x2b\x78\xfd\x58\x07\x0e\x97\x09\xb6\xaf\x1e\xe2\x7c\xe1\x63\x6f\x30\x3c\xcc\x30\x30\xd8\xc7\x90\x93\x25\x39\xa2\x21\x2e\xfe\xc8\x7f\x2c\x26\x27\x72\xea\x9b\x5e\xef\x54\xf2\xed\xe3\xe4\xb7\x8c\x7c\xef\xbc\xa1\xf7\x13\xb9\xb1\x53\x67\x9d\x72\x83\xcf\xc6\x46\x4d\x5c\x61\x96\x62\x15\x13\x96\x0a\x43\x44\xb3\x09\x0a\x28\xaf\x35\x3f\x75\x1c\x52\xeb\x86\x9a\xf4\x62\xb2\x5c\x4e\x7e\x3d\x58\xca\xe2\xe2\x02\x4f\x7a\x84\x3f\xbe\x4a\xdc\x67\xba\x57\xdf\x0f\x8f\x56\x10\x94\xa8\x83\x53\x3a\x3d\x89\x91\x8c\xe2\xc7\xbd\x46\x0a\xa5\x6f\xc6\xae\x8d\xa3\x0f\xfc\x7f\xd9\x28\xba\xea\xd7\xee\xbd\x0e\x1a\x0a\xfc\xff\xa0\xa1\x12\xe3\x65\xca\xfe\x29\xcd\x2e\xf2\xce\xa0\xb6\x2d\x3c\x16\xdd\x51\xbb\xa0\xf4\x71\xe6\x85\xa8\xba\xb8\x8b\x62\x58\xe3\x3c\x2c\x58\x2e\xe7\xbe\xa1\x8d\x78\xc6\xc7\xae\x7f\x42\x05\x89\x10\xe8\x80\x5e\xc7\xbf\x11\x37\xdb\xa5\xce\x42\x6b\x4c\xd0\x79\x30\x63\x88\xb1\xb5\x59\x73\x0c\xb1\xba\x58\x36\x38\x45\x1e\x3a\x55\xea\xee\xd9\x51\xda\x8d\xa2\xa6\x84\x53\xc6\x99\xc4\x51\x5f\xf0\x7e\xb0\xfa\xc2\xb8\x74\x38\xbf\xe9\xe9\x86\xd6\xd7\x4b\xf8\x0c\x38\x3e\x62\x2b\x55\xc3\x66\x8a\x9f\x38\x13\xe2\x40\x4d\x8b\x17\x1c\x7e\x91\x2f\x81\xef\x71\x78\x4d\xe4\x5a\x56\x16\x21\xed\xf4\x3e\x69\x8d\x64\xaa\x3e\x36\xe3\x51\x91\xa9\xf1\x59\xce\xdf\x72\xf2\xc6\xa1\xfb\x09\xa7\x6b\xfa\x24\xc7\x65\x5d\x39\xf2\xa4\x35\xc5\xbe\x08\x95\xb0\x46\xae\x9b\xdf\x7a\xaf\x9b\xf6\x3b\xba\x5f\xd5\x27\xad\x93\x84\x45\xc0\x6c\x6e\xdd\xfd\x77\x75\x36\x35\xd4\x06\x64\xbb\x1d\xea\xb7\x75\xc9\xe2\x28\xc8\xd6\xbf\x69\x28\x4c\x3a\x51\x8f\x53\xb7\x7b\x44\xc3\x5e\x58\x1f\x7a\xaa\xc5\x35\xaa\xd2\x29\x53\xc7\xe2\x6a\xa3\x21\xeb\xd0\xd4\xb0\x34\xaf\x46\xa3\xea\x44\xa6\x92\xbd\x45\x1d\x6c\xab\x5a\x8a\xc5\x3e\x7b\xb3\x69\x34\x35\x77\x98\x7a\x56\x4e\x69\xb4\x6a\x52\x68\xe9\x99\x3c\x51\x3f\xed\x99\xae\x92\xee\xb7\xbe\x63\xe4\x3f\x5d\x4c\x6e\xbd\xd5\xd4\x53\x19\x56\xa9\xb6\x4d\x1c\x23\x9c\xa0\x1f\x1e\x9d\x8b\x0b\x29\x35\x3a\xdd\x2f\xc6\xa7\x99\x4b\x5d\x54\xab\xd3\xad\x6e\x60\xd4\xb2\xbc\x85\x56\xa5\x63\xb4\xf2\x71\x04\xcd\x34\x24\xdd\x0f\x7b\x6d\xc2\xf4\x5b\x3e\x4d\x4b\xca\x0b\x96\x90\x62\x6f\x74\x60\xcc\x4c\xb5\x15\x20\x48\x9d\x9f\x1e\xd9\x1b\xab\x37\xcb\xc5\xdd\x77\xf8\xf3\x57\x7b\xb8\x6d\xcd\xad\xe6\x00\xdf\x70\x0a\xe1\x81\xcc\xd2\x3b\x28\xe5\xfd\x97\x5a\x9d\x86\xb3\xad\x7d\xfb\x03\x34\xd1\x20\x67\x8a\x26\x6a\x1e\x3c\x4a\x5e\x86\x5e\x97\x66\x7a\xfa\x27\x69\xa6\x81\xa8\xe8\xa6\xa7\x7b\x82\x6e\x1a\xcd\x2a\x5b\xa6\xb2\xfd\xf2\x99\xc6\x9b\xf5\xd0\xc6\x3a\x59\x2e\xfd\x67\x64\x29\x4b\x55\x74\x2f\xbd\x12\xab\xe1\xab\x6a\x19\x54\x04\xf0\xa2\x77\xab\xaa\xf9\x32\x38\x3b\xfb\x32\xf8\x17\x6e\x90\xca\xb8\xc8\x12\x00\x00")
+
+func _8_add_pgstream_get_schema_enum_columnsDownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__8_add_pgstream_get_schema_enum_columnsDownSql,
+		"8_add_pgstream_get_schema_enum_columns.down.sql",
+	)
+}
+
+func _8_add_pgstream_get_schema_enum_columnsDownSql() (*asset, error) {
+	bytes, err := _8_add_pgstream_get_schema_enum_columnsDownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "8_add_pgstream_get_schema_enum_columns.down.sql", size: 4808, mode: os.FileMode(420), modTime: time.Unix(1792300000, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var __8_add_pgstream_get_schema_enum_columnsUpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x9d\x57\x4d\x6f\xdb\x38\x10\xbd\xfb\x57\xcc\x21\x85\x6d\xc0\x11\xd0\x6b\x8a\x14\xeb\x75\xd5\xd4\x8b\xac\xb3\xeb\x38\x68\x8b\xa2\x50\x68\x89\xb6\xd9\xc8\xa2\x56\xa4\x92\xfa\xdf\xef\x0c\x29\xc9\x92\x48\x7b\xb3\xd5\xc1\x1f\xe4\xbc\x37\xc3\xe1\xf0\x0d\x75\x79\x09\x7a\x27\x14\x6c\xca\x2c\xd6\x42\x66\x80\xbf\x63\x96\xa6\x3c\x01\xce\xe2\x1d\x68\xb1\xe7\xc0\x20\xde\xb1\x6c\xcb\x41\x4b\xfc\xbd\x15\xcf\x3c\x03\x15\xef\xf8\x9e\x91\xf9\x9e\x25\x3c\x80\xb9\x86\x17\x91\xa6\xa0\xb4\x2c\xd0\x70\xc7\xa1\xe0\xaa\x4c\x35\xc8\x8d\xf9\x57\xd9\x5b\xa2\xc1\xe5\x25\xbc\xec\x04\xf2\x1b\x0c\xce\x67\xb0\x26\x44\x9e\x8a\x98\x69\x9e\x04\xb0\x42\x8c\x2c\x75\x5e\x6a\xa4\x2c\xca\x58\x97\x48\x6b\xbc\xe5\x39\x06\x27\x32\xc3\x1a\xcb\x84\xaf\x99\xe2\x13\xc8\x53\x8e\xdf\xa0\xd9\x13\x8e\x32\xb2\xdd\x00\x4f\x84\x16\xd9\x36\x40\x77\xe4\x71\x26\xd3\x72\x9f\x29\xc4\xc6\x69\x99\x70\x8c\x80\x23\x47\x41\x44\x02\x3f\x0f\x39\x87\x91\x2c\x80\x15\x05\x3b\x00\x4f\xf9\x9e\x67\xda\x0c\x8f\xc9\x31\xcb\x80\x67\xe5\x7e\x02\x0a\xf1\x76\x85\x06\x92\x31\xcc\x10\x25\x4e\x2b\x90\x2f\x19\x24\x92\xab\x6c\xa8\xc9\x21\xe6\x51\xbe\x80\xe6\x69\x8a\x51\x18\x34\xd2\xe4\xac\xd0\xb0\x29\xe4\x1e\xa4\xf1\x5e\x2a\xfc\x48\xf8\x46\x64\xb8\x2c\x62\x54\x75\xbc\x9f\x39\xec\xd8\xb3\x75\xb5\x11\x85\xa2\x4c\xf0\x1c\x1e\x5f\x84\xc6\x8d\x61\xeb\x94\x47\x52\x24\x48\xa9\x60\x04\x41\x10\xc0\xf8\x91\xf2\x22\x8b\x84\x16\x25\x61\x5b\xb0\x35\xcc\x3f\x28\x24\x60\xda\x52\xb1\xb4\xe0\x2c\x39\x60\xb2\x31\xe3\x5b\x9e\xf1\x82\xb2\x3d\xc1\xc5\x25\xe4\x51\x64\x18\x0c\xae\x79\x27\x29\x95\x84\x4a\x24\xae\x05\x0e\xbc\xc2\x23\x1b\x6d\xf5\x50\xd1\x04\x87\x54\x3c\x71\x5b\x3f\xe8\x6e\xc7\xd3\x1c\x4c\x6c\x39\x2f\x36\xb2\xd8\x33\xcc\x53\x30\x98\x2d\xc3\xe9\x2a\x84\xbb\x25\x2c\xc3\xbf\x6e\xa7\xb3\x10\x3e\x3e\x2c\x66\xab\xf9\xdd\x02\xf2\x2d\x6e\x2d\x67\xfb\x60\xcb\x75\x64\x0b\x64\x64\xbf\x22\x93\xd5\x55\xf8\x65\x35\x46\xd8\xea\x61\xb9\xb8\x87\x1f\x4a\x66\xeb\x01\xe0\x73\x3b\x5d\xdc\x3c\x4c\x6f\x42\xb8\xff\xfb\xd6\x0c\xdc\x87\x2b\x50\x9c\x15\xf1\x2e\xca\x19\x06\x70\x8d\xd4\x11\x16\x12\x4b\xe5\x76\x82\x3f\x35\xdf\xe7\xc6\x70\x7a\x0f\x17\x17\x83\xcf\xf3\xd5\xa7\x76\x02\x71\x74\x64\xa6\xcd\x04\xff\x29\x14\x95\x4d\x6f\xce\xfa\xb9\x0d\x67\x2b\xf8\x30\xbf\x5f\xcd\x71\x0d\xcd\x38\x3d\xe8\x86\x82\x56\x39\xc3\x55\x67\x2a\x37\x2b\x40\x74\x6b\x41\x93\x3e\x20\x4e\x99\x52\x41\xc1\xd3\xda\xd8\x06\x75\xc6\x16\x63\x3a\xda\xe1\x9f\xc6\xea\xe3\xf2\xee\xcf\x4e\x0c\x1d\xbc\x79\x96\xf3\x9b\x4f\x2b\xf8\xe3\x6e\xbe\x68\xf8\xc0\xec\x42\x2b\x70\xe2\xbf\x76\x42\x33\x73\x30\x5d\x7c\xe8\xcc\x3c\x89\x2c\x01\x24\x1b\x0d\x8b\xe1\x04\x86\xf9\x70\xdc\xf8\xfc\xfc\x29\x5c\x86\xfe\x94\x5c\xb7\x33\x62\x00\xe3\xc1\x31\xb7\x0d\x43\x67\x17\x02\x6f\x12\xbb\x26\xbe\xd4\xf9\x2c\xf0\xe7\xd1\x20\x96\x2c\xe5\x2a\xe6\xa3\xa6\x14\xad\x11\x99\xa3\xdd\xb1\x42\x63\xfc\xd4\x3c\xb2\xb3\xa4\x41\xc8\x3a\x3a\x41\x3f\x1e\x1f\xb7\x08\x09\xa2\x6a\x97\xcc\x0e\x75\x20\xdd\x2d\xba\x0d\x3f\x36\xbb\xd3\x0f\x86\xf6\xe9\x84\x37\xb3\x5d\x4e\xf0\x54\x1a\xe3\x6a\x9d\x71\xa5\x7b\x9d\x52\xee\xe7\xdb\x3c\xc7\x33\xd1\x4a\xe7\xb9\xba\x74\x11\xfd\x02\xfd\x2f\x7b\x9b\xa0\x7e\xc2\x7a\x28\xa3\x26\x7a\x34\x7c\xa3\x2e\xdf\x28\xac\xb5\x13\x2c\xb4\x5f\x11\xd3\xba\x10\xeb\x52\xf3\x00\x7f\xa1\xe4\x9a\xcd\xb0\x29\xf0\x93\x3b\x98\x6a\xcd\x15\xc6\xb3\x68\x1b\x4e\x44\x62\x3d\xea\xa3\x71\xd0\x17\x08\x0e\xef\x65\xd2\x8e\x85\xd0\x6e\x24\xa4\x84\xfc\x67\x5e\xd4\xbc\xd8\x18\x02\x96\xac\x45\xd6\x50\xda\x11\x3c\x7e\xa2\x43\x87\xc3\x0c\xdb\x6d\x8f\x71\x71\xb7\xc2\xde\xe0\xac\x50\x62\x62\xb0\xef\xa2\x26\x93\x38\x62\x20\x01\x7e\x98\x6e\x76\x0d\xc3\x64\x58\x9f\xf4\x7a\xa6\xb2\x6f\xbb\xa3\xff\x94\xf9\x9e\xbf\x91\x87\x8f\x0f\xc9\x51\xeb\xa4\x45\x55\x6f\xf5\x58\x4e\x60\xc3\x52\xc5\xc7\x6d\x57\xa6\xeb\xf6\xdc\x84\x5f\x50\x82\x3b\xe5\xdc\xa9\x6a\x78\xeb\x4c\xd4\xfa\x18\xcb\x0c\x0f\x0b\x13\x99\x76\x4c\xac\x68\xa1\x81\x49\xae\x95\xc1\x4e\xde\xcc\xb8\x03\xa3\x64\x4d\x97\xcb\xe9\xd7\x6f\x9e\xea\xbb\xba\x42\x4f\xdf\xe1\xb7\xf7\xc4\xfb\xc4\x0f\xe6\xff\xb7\xef\x5e\x12\xb4\xa8\x33\x51\x0e\x7b\x16\x63\xca\xe1\xaf\xaf\x1a\x95\x9a\xff\x74\x66\x7d\xad\xe0\xd8\x66\xae\x1b\x60\x60\x3e\xfd\xab\xb7\x49\x43\x83\xff\x9f\x34\x04\x09\x55\x66\xe2\x9f\xd2\x6d\x56\xaf\x4c\x6a\x3b\xc2\x53\xd9\xed\x14\x93\x75\xe7\x9e\xbb\xea\xb2\x10\xa0\x19\x1e\x25\x15\x17\x22\xa7\xeb\xf0\xc8\xa7\x6f\x93\x53\x2a\xb3\xe7\x9a\x25\x48\x74\x64\xaf\xf3\xdf\x98\xbb\x5d\xd9\xee\x42\xeb\x36\x62\xf7\xc1\xcd\x21\xe6\xd6\x17\xcd\x29\xc6\xea\x24\xfa\xe8\x8c\x46\xd9\xad\x32\x47\xd0\xcf\xd2\xee\x47\x96\x0a\x97\xd8\x3b\xbd\x15\x7b\x7d\x90\xe9\xee\xbe\x95\xc5\x81\x4a\x78\xea\xc8\x08\x01\xad\xd7\x8e\x00\xbc\xc2\x7b\xa5\x7b\xe7\x52\xe3\xaa\x63\xed\xbe\xbf\x55\x7d\x63\x1c\x3a\xfa\x6f\x2e\x2e\x0e\xea\xfd\x35\xbc\x05\xbc\x23\xa3\x8a\x99\x1b\x75\x86\x7f\xf1\xe2\x8b\x6f\x39\xbc\x78\xc6\xab\x3b\x36\x05\x50\x07\xbc\xa1\xef\x69\x4c\x96\x45\xcc\x3b\x0d\x9e\xa2\x21\x39\xee\x73\x0b\x95\x14\xd2\xbc\xd3\xd0\x4b\x11\xbd\x0e\xe1\x9b\xd0\x23\xbe\xf2\xf0\x47\x7a\x87\xb1\x75\x4b\x9e\xd6\x9c\xde\x22\x2a\x63\xcb\x5c\x77\xf8\xf5\xc1\xde\x4c\x5e\xd1\xe2\xab\xcb\x80\xf7\xba\xe4\x31\x70\x3b\x78\x77\xfe\x55\xed\xdb\xdc\xdc\x23\xb6\xdd\x8e\xec\xaf\x75\x29\xd2\x24\x92\xeb\x1f\x3c\xd6\xae\x98\x99\x67\x58\xdf\x69\x90\x0d\xbb\x42\xed\xf4\x5c\x1f\x6f\xa0\xb4\x28\x17\xe3\x59\x6a\x83\xa0\x3a\x74\x11\x9e\x0e\xdd\x20\xaa\x76\xeb\x82\xfc\x7d\xf8\x18\x5b\xd5\x37\x3d\xf1\xf9\x3b\x6a\x83\xb4\xca\xe5\xe2\xbc\x8a\xd6\xa0\xa8\x79\xba\x18\x4f\x4b\x6d\x10\xb5\x88\xb5\x50\xae\xae\xd5\x4f\xfb\xaa\x5b\x59\xf7\x5b\xf5\xa9\x66\x35\xbb\x9b\xde\x86\xf7\xb3\xd0\xd4\x84\x29\x0e\xdf\x45\x6c\x8c\x2f\x16\xdf\xbe\x0f\xaf\xae\xc8\x6a\x7c\xbe\xbf\x4d\xce\x2b\xad\x39\xda\xde\x45\xb7\xba\x97\x53\xfd\x74\x6e\xbd\xa0\x53\x42\xf4\xeb\x0c\x56\x9b\x58\x76\x18\xf5\xda\x9a\xbb\x6e\x7a\x9a\x16\x9a\x17\x62\xcf\x8a\x83\x73\x63\x20\xb9\xb6\x53\x11\x92\xd4\xfb\xd3\x6b\x4e\xce\xe8\xcd\xf2\xee\xe1\x2f\xf8\xfd\x6b\xfb\xce\xdf\xba\xce\xbb\xef\x35\x8d\x0a\x31\x15\xd1\x2e\xbd\x42\x84\x5e\x2f\x03\xc6\x1b\x5e\xf9\xfd\xd3\xbf\x20\x2c\x0d\xb3\x34\xc2\x52\x2b\xe7\x49\xb9\x73\x70\x5d\x61\xea\xe1\xcf\x0a\x53\x43\x51\x09\x54\x0f\x7b\x46\xa0\x1a\x64\xb5\x5b\x2e\xd8\x7f\xf8\xdc\xe0\xdd\x7a\x68\x73\x9d\x2d\x97\xfe\x33\xf6\x94\xa5\x29\xba\xe7\x5e\x89\xd5\xf4\x55\xb5\x0c\x2a\x01\x78\xb6\xb3\x55\xd5\xbc\x1b\x5c\x5c\xbc\x1b\xfc\x0b\x8d\x6f\x64\x76\x8f\x14\x00\x00")
+
+func _8_add_pgstream_get_schema_enum_columnsUpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__8_add_pgstream_get_schema_enum_columnsUpSql,
+		"8_add_pgstream_get_schema_enum_columns.up.sql",
+	)
+}
+
+func _8_add_pgstream_get_schema_enum_columnsUpSql() (*asset, error) {
+	bytes, err := _8_add_pgstream_get_schema_enum_columnsUpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "8_add_pgstream_get_schema_enum_columns.up.sql", size: 5263, mode: os.FileMode(420), modTime: time.Unix(1792300000, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -438,6 +480,8 @@ var _bindata = map[string]func() (*asset, error){
 	"6_create_pgstream_refresh_schema_function.up.sql":   _6_create_pgstream_refresh_schema_functionUpSql,
 	"7_create_pgstream_event_triggers.down.sql":          _7_create_pgstream_event_triggersDownSql,
 	"7_create_pgstream_event_triggers.up.sql":            _7_create_pgstream_event_triggersUpSql,
+	"8_add_pgstream_get_schema_enum_columns.down.sql":    _8_add_pgstream_get_schema_enum_columnsDownSql,
+	"8_add_pgstream_get_schema_enum_columns.up.sql":      _8_add_pgstream_get_schema_enum_columnsUpSql,
 }
 
 // AssetDir returns the file names below a certain
@@ -495,6 +539,8 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"6_create_pgstream_refresh_schema_function.up.sql":   &bintree{_6_create_pgstream_refresh_schema_functionUpSql, map[string]*bintree{}},
 	"7_create_pgstream_event_triggers.down.sql":          &bintree{_7_create_pgstream_event_triggersDownSql, map[string]*bintree{}},
 	"7_create_pgstream_event_triggers.up.sql":            &bintree{_7_create_pgstream_event_triggersUpSql, map[string]*bintree{}},
+	"8_add_pgstream_get_schema_enum_columns.down.sql":    &bintree{_8_add_pgstream_get_schema_enum_columnsDownSql, map[string]*bintree{}},
+	"8_add_pgstream_get_schema_enum_columns.up.sql":      &bintree{_8_add_pgstream_get_schema_enum_columnsUpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory
@@ -102,6 +102,10 @@ func (m *LogEntry) GetTableByName(tableName string) *Table {
 	return m.Schema.getTableByName(tableName)
 }
 
+func (m *LogEntry) GetTableByID(pgstreamID string) *Table {
+	return m.Schema.getTableByID(pgstreamID)
+}
+
 // SchemaCreatedAtTimestamp is a wrapper around time.Time that allows us to parse to and from the PG timestamp format.
 type SchemaCreatedAtTimestamp struct {
 	time.Time
@@ -148,7 +148,11 @@ func TestLogEntry_Diff(t *testing.T) {
 					},
 				},
 			},
-			want: nopDiff,
+			want: &SchemaDiff{
+				ColumnsToRemove: []Column{
+					{Name: "col1", PgstreamID: "1-1", DataType: "text", Nullable: true, DefaultValue: ptr("a")},
+				},
+			},
 		},
 		"table and columns removed": {
 			old: &LogEntry{
@@ -204,7 +208,14 @@ func TestLogEntry_Diff(t *testing.T) {
 					},
 				},
 			},
-			want: nopDiff,
+			want: &SchemaDiff{
+				ColumnsToRename: []ColumnChange{
+					{
+						Old: Column{Name: "col1", PgstreamID: "1-1", DataType: "text", Nullable: true, DefaultValue: ptr("a")},
+						New: Column{Name: "col1-renamed", PgstreamID: "1-1", DataType: "text", Nullable: true, DefaultValue: ptr("a")},
+					},
+				},
+			},
 		},
 	}
 
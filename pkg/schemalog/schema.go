@@ -28,6 +28,9 @@ type Column struct {
 	DefaultValue *string `json:"default,omitempty"`
 	Nullable     bool    `json:"nullable"`
 	Unique       bool    `json:"unique"`
+	// Enum is set when the column type, or its array element type, is an
+	// enum.
+	Enum bool `json:"enum"`
 	// Metadata is NOT typed here because we don't fully control the content that is sent from the publisher.
 	Metadata   *string `json:"metadata"`
 	PgstreamID string  `json:"pgstream_id"`
@@ -79,6 +82,15 @@ func (s *Schema) Diff(previous *Schema) *SchemaDiff {
 	for i, table := range s.Tables {
 		if previousTable := previous.getTableByID(table.PgstreamID); previousTable != nil {
 			d.ColumnsToAdd = append(d.ColumnsToAdd, diffColumns(&s.Tables[i], previousTable)...)
+			d.ColumnsToRemove = append(d.ColumnsToRemove, diffColumns(previousTable, &s.Tables[i])...)
+			for _, change := range diffExistingColumns(&s.Tables[i], previousTable) {
+				if change.Old.Name != change.New.Name {
+					d.ColumnsToRename = append(d.ColumnsToRename, change)
+				}
+				if change.Old.DataType != change.New.DataType {
+					d.ColumnTypeChange = append(d.ColumnTypeChange, change)
+				}
+			}
 			if hasPrimaryKeyChanged(previousTable.PrimaryKeyColumns, table.PrimaryKeyColumns) {
 				d.PrimaryKeyChange = append(d.PrimaryKeyChange, table.Name)
 			}
@@ -180,19 +192,37 @@ func (c *Column) IsEqual(other *Column) bool {
 			c.PgstreamID == other.PgstreamID &&
 			c.DefaultValue == other.DefaultValue &&
 			c.Unique == other.Unique &&
+			c.Enum == other.Enum &&
 			c.Metadata == other.Metadata
 	}
 }
 
 type SchemaDiff struct {
-	TablesToRemove      []Table
-	ColumnsToAdd        []Column
+	TablesToRemove  []Table
+	ColumnsToAdd    []Column
+	ColumnsToRemove []Column
+	// ColumnsToRename are the columns with the same pgstream id, but a
+	// different name.
+	ColumnsToRename []ColumnChange
+	// ColumnTypeChange are the columns with the same pgstream id, but a
+	// different data type.
+	ColumnTypeChange    []ColumnChange
 	PrimaryKeyChange    []string
 	UniqueNotNullChange []string
 }
 
+// ColumnChange contains the previous and new definitions of a column.
+type ColumnChange struct {
+	Old Column
+	New Column
+}
+
 func (d *SchemaDiff) Empty() bool {
-	return len(d.TablesToRemove) == 0 && len(d.ColumnsToAdd) == 0
+	return len(d.TablesToRemove) == 0 &&
+		len(d.ColumnsToAdd) == 0 &&
+		len(d.ColumnsToRemove) == 0 &&
+		len(d.ColumnsToRename) == 0 &&
+		len(d.ColumnTypeChange) == 0
 }
 
 func unorderedColumnsEqual(a, b []Column) bool {
@@ -239,6 +269,24 @@ func diffColumns(new, old *Table) []Column {
 	return colsAdded
 }
 
+// diffExistingColumns returns the columns that exist in both tables, but have
+// a different name or data type.
+func diffExistingColumns(new, old *Table) []ColumnChange {
+	var changes []ColumnChange
+	for _, newCol := range new.Columns {
+		for _, oldCol := range old.Columns {
+			if newCol.PgstreamID != oldCol.PgstreamID {
+				continue
+			}
+			if newCol.Name != oldCol.Name || newCol.DataType != oldCol.DataType {
+				changes = append(changes, ColumnChange{Old: oldCol, New: newCol})
+			}
+			break
+		}
+	}
+	return changes
+}
+
 func hasPrimaryKeyChanged(old, new []string) bool {
 	slices.Sort(old)
 	slices.Sort(new)
@@ -79,6 +79,85 @@ func TestSchema_Diff(t *testing.T) {
 				TablesToRemove: []Table{testTable()},
 			},
 		},
+		{
+			name: "columns to remove",
+			schema: Schema{
+				Tables: []Table{testTable()},
+			},
+			oldSchema: Schema{
+				Tables: []Table{
+					{
+						PgstreamID: "1",
+						Name:       testTableName,
+						Columns: []Column{
+							{PgstreamID: "1_1", Name: "col-1"},
+							{PgstreamID: "1_2", Name: "col-2"},
+						},
+						PrimaryKeyColumns: []string{"col-1"},
+					},
+				},
+			},
+
+			wantDiff: &SchemaDiff{
+				ColumnsToRemove: []Column{
+					{PgstreamID: "1_2", Name: "col-2"},
+				},
+			},
+		},
+		{
+			name: "columns renamed and retyped",
+			schema: Schema{
+				Tables: []Table{
+					{
+						PgstreamID: "1",
+						Name:       testTableName,
+						Columns: []Column{
+							{PgstreamID: "1_1", Name: "col-1", DataType: "int8"},
+							{PgstreamID: "1_2", Name: "col-2-renamed", DataType: "text"},
+							{PgstreamID: "1_3", Name: "col-3-renamed", DataType: "int8"},
+						},
+						PrimaryKeyColumns: []string{"col-1"},
+					},
+				},
+			},
+			oldSchema: Schema{
+				Tables: []Table{
+					{
+						PgstreamID: "1",
+						Name:       testTableName,
+						Columns: []Column{
+							{PgstreamID: "1_1", Name: "col-1", DataType: "int4"},
+							{PgstreamID: "1_2", Name: "col-2", DataType: "text"},
+							{PgstreamID: "1_3", Name: "col-3", DataType: "text"},
+						},
+						PrimaryKeyColumns: []string{"col-1"},
+					},
+				},
+			},
+
+			wantDiff: &SchemaDiff{
+				ColumnsToRename: []ColumnChange{
+					{
+						Old: Column{PgstreamID: "1_2", Name: "col-2", DataType: "text"},
+						New: Column{PgstreamID: "1_2", Name: "col-2-renamed", DataType: "text"},
+					},
+					{
+						Old: Column{PgstreamID: "1_3", Name: "col-3", DataType: "text"},
+						New: Column{PgstreamID: "1_3", Name: "col-3-renamed", DataType: "int8"},
+					},
+				},
+				ColumnTypeChange: []ColumnChange{
+					{
+						Old: Column{PgstreamID: "1_1", Name: "col-1", DataType: "int4"},
+						New: Column{PgstreamID: "1_1", Name: "col-1", DataType: "int8"},
+					},
+					{
+						Old: Column{PgstreamID: "1_3", Name: "col-3", DataType: "text"},
+						New: Column{PgstreamID: "1_3", Name: "col-3-renamed", DataType: "int8"},
+					},
+				},
+			},
+		},
 		{
 			name: "primary key changed",
 			schema: Schema{
@@ -5,14 +5,16 @@ package schemalog
 import (
 	"context"
 	"fmt"
+	"sync"
 )
 
 // StoreCache is a wrapper around a schemalog Store that provides an in memory
-// caching mechanism to reduce the amount of calls to the database. It is not
+// caching mechanism to reduce the amount of calls to the database. It is
 // concurrency safe.
 type StoreCache struct {
-	store Store
-	cache map[string]*LogEntry
+	store     Store
+	cache     map[string]*LogEntry
+	cacheLock sync.RWMutex
 }
 
 func NewStoreCache(store Store) *StoreCache {
@@ -23,21 +25,27 @@ func NewStoreCache(store Store) *StoreCache {
 }
 
 func (s *StoreCache) Fetch(ctx context.Context, schemaName string, ackedOnly bool) (*LogEntry, error) {
+	s.cacheLock.RLock()
 	logEntry := s.cache[schemaName]
+	s.cacheLock.RUnlock()
 	if logEntry == nil {
 		var err error
 		logEntry, err = s.store.Fetch(ctx, schemaName, ackedOnly)
 		if err != nil {
 			return nil, fmt.Errorf("store cache fetch: %w", err)
 		}
+		s.cacheLock.Lock()
 		s.cache[schemaName] = logEntry
+		s.cacheLock.Unlock()
 	}
 
 	return logEntry, nil
 }
 
 func (s *StoreCache) Ack(ctx context.Context, entry *LogEntry) error {
+	s.cacheLock.Lock()
 	s.cache[entry.SchemaName] = entry
+	s.cacheLock.Unlock()
 	if err := s.store.Ack(ctx, entry); err != nil {
 		return fmt.Errorf("store cache ack: %w", err)
 	}
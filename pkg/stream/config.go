@@ -10,7 +10,13 @@ import (
 	kafkalistener "github.com/xataio/pgstream/pkg/wal/listener/kafka"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
+	deadletterfile "github.com/xataio/pgstream/pkg/wal/processor/search/deadletter/file"
+	deadletterpg "github.com/xataio/pgstream/pkg/wal/processor/search/deadletter/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/elasticsearch"
 	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
+	relationspg "github.com/xataio/pgstream/pkg/wal/processor/search/relations/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/typesense"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/verify"
 	"github.com/xataio/pgstream/pkg/wal/processor/translator"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/server"
@@ -48,9 +54,41 @@ type KafkaProcessorConfig struct {
 }
 
 type SearchProcessorConfig struct {
-	Indexer search.IndexerConfig
-	Store   opensearch.Config
-	Retrier *search.StoreRetryConfig
+	Indexer    search.IndexerConfig
+	Store      SearchStoreConfig
+	Retrier    *search.StoreRetryConfig
+	DeadLetter *SearchDeadLetterConfig
+	// Verify configures the consistency checks between the postgres tables
+	// and the search documents.
+	Verify *verify.Config
+	// Denormalisation configures the related rows embedded in the search
+	// documents.
+	Denormalisation *SearchDenormalisationConfig
+}
+
+// SearchStoreConfig configures the search store backend. Only one of them
+// can be configured.
+type SearchStoreConfig struct {
+	OpenSearch    *opensearch.Config
+	Elasticsearch *elasticsearch.Config
+	Typesense     *typesense.Config
+}
+
+// SearchDeadLetterConfig configures where the documents that can't be indexed
+// are kept. Only one of them can be configured.
+type SearchDeadLetterConfig struct {
+	File     *deadletterfile.Config
+	Postgres *deadletterpg.Config
+	// Index is the name of the search store index used to keep them.
+	Index string
+}
+
+// SearchDenormalisationConfig configures the relations embedded in the search
+// documents, and the postgres database the related rows are read from.
+type SearchDenormalisationConfig struct {
+	Postgres relationspg.Config
+	// ConfigFile is the JSON file with the relations.
+	ConfigFile string
 }
 
 type WebhookProcessorConfig struct {
@@ -74,5 +112,84 @@ func (c *Config) IsValid() error {
 		return errors.New("need at least one processor configured")
 	}
 
+	if c.Processor.Search != nil {
+		if err := c.Processor.Search.Store.IsValid(); err != nil {
+			return err
+		}
+		if err := c.Processor.Search.Indexer.SoftDelete.IsValid(); err != nil {
+			return err
+		}
+		if ts := c.Processor.Search.Store.Typesense; ts != nil {
+			if ts.IndexLayout != "" && opensearch.IndexLayout(ts.IndexLayout) != opensearch.IndexPerSchema {
+				return errors.New("the search index layout is not supported with the typesense store")
+			}
+			if ts.MappingConfigFile != "" {
+				return errors.New("the search mapping config is not supported with the typesense store")
+			}
+		}
+		if c.Processor.Search.DeadLetter != nil {
+			if err := c.Processor.Search.DeadLetter.IsValid(); err != nil {
+				return err
+			}
+			if c.Processor.Search.DeadLetter.Index != "" && c.Processor.Search.Store.Typesense != nil {
+				return errors.New("the search dead letter index is not supported with the typesense store")
+			}
+		}
+	}
+
+	if c.kafkaTransactionsEnabled() && c.Listener.Kafka == nil {
+		return errors.New("kafka processor transactions require a kafka listener")
+	}
+
 	return nil
 }
+
+// kafkaTransactionsEnabled returns true if the kafka processor is configured to
+// commit the listener offsets as part of its write transactions.
+func (c *Config) kafkaTransactionsEnabled() bool {
+	return c.Processor.Kafka != nil &&
+		c.Processor.Kafka.Writer != nil &&
+		c.Processor.Kafka.Writer.Transaction != nil
+}
+
+func (c *SearchStoreConfig) IsValid() error {
+	configured := 0
+	if c.OpenSearch != nil {
+		configured++
+	}
+	if c.Elasticsearch != nil {
+		configured++
+	}
+	if c.Typesense != nil {
+		configured++
+	}
+	switch configured {
+	case 0:
+		return errors.New("need a search store configured")
+	case 1:
+		return nil
+	default:
+		return errors.New("only one search store can be configured")
+	}
+}
+
+func (c *SearchDeadLetterConfig) IsValid() error {
+	configured := 0
+	if c.File != nil {
+		configured++
+	}
+	if c.Postgres != nil {
+		configured++
+	}
+	if c.Index != "" {
+		configured++
+	}
+	switch configured {
+	case 0:
+		return errors.New("need a search dead letter store configured")
+	case 1:
+		return nil
+	default:
+		return errors.New("only one search dead letter store can be configured")
+	}
+}
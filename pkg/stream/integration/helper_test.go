@@ -21,17 +21,30 @@ import (
 	kafkacheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/kafka"
 	kafkalistener "github.com/xataio/pgstream/pkg/wal/listener/kafka"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
-	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
 	"github.com/xataio/pgstream/pkg/wal/processor/translator"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/signature"
 	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
 )
 
 var (
-	pgurl        string
-	kafkaBrokers []string
-	searchURL    string
+	pgurl            string
+	kafkaBrokers     []string
+	kafkaSASLBrokers []string
+	searchURL        string
+	elasticsearchURL string
+	typesenseURL     string
+)
+
+const (
+	kafkaSASLUsername = "pgstream"
+	kafkaSASLPassword = "pgstream-secret"
+
+	elasticsearchUsername = "elastic"
+	elasticsearchPassword = "pgstream-secret"
+
+	typesenseAPIKey = "pgstream-secret"
 )
 
 type mockProcessor struct {
@@ -52,11 +65,15 @@ type mockWebhookServer struct {
 	dataChan chan *wal.Data
 }
 
-func newMockWebhookServer() *mockWebhookServer {
+func newMockWebhookServer(secret string) *mockWebhookServer {
 	mw := &mockWebhookServer{
 		dataChan: make(chan *wal.Data),
 	}
 	mw.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := signature.VerifyRequest(r, secret, signature.DefaultTolerance); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
 		payload := webhook.Payload{}
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -104,10 +121,10 @@ func testPostgresListenerCfg() stream.ListenerConfig {
 	}
 }
 
-func testKafkaListenerCfg() stream.ListenerConfig {
+func testKafkaListenerCfg(kafkaCfg kafkalib.ConnConfig) stream.ListenerConfig {
 	readerCfg := kafkalistener.ReaderConfig{
 		Kafka: kafkalib.ReaderConfig{
-			Conn:            testKafkaCfg(),
+			Conn:            kafkaCfg,
 			ConsumerGroupID: "integration-test-group",
 		},
 	}
@@ -121,11 +138,11 @@ func testKafkaListenerCfg() stream.ListenerConfig {
 	}
 }
 
-func testKafkaProcessorCfg() stream.ProcessorConfig {
+func testKafkaProcessorCfg(kafkaCfg kafkalib.ConnConfig) stream.ProcessorConfig {
 	return stream.ProcessorConfig{
 		Kafka: &stream.KafkaProcessorConfig{
 			Writer: &kafkaprocessor.Config{
-				Kafka: testKafkaCfg(),
+				Kafka: kafkaCfg,
 			},
 		},
 		Translator: &translator.Config{
@@ -136,12 +153,10 @@ func testKafkaProcessorCfg() stream.ProcessorConfig {
 	}
 }
 
-func testSearchProcessorCfg() stream.ProcessorConfig {
+func testSearchProcessorCfg(storeCfg stream.SearchStoreConfig) stream.ProcessorConfig {
 	return stream.ProcessorConfig{
 		Search: &stream.SearchProcessorConfig{
-			Store: opensearch.Config{
-				URL: searchURL,
-			},
+			Store: storeCfg,
 		},
 		Translator: &translator.Config{
 			Store: schemalogpg.Config{
@@ -179,3 +194,14 @@ func testKafkaCfg() kafkalib.ConnConfig {
 		},
 	}
 }
+
+func testKafkaSASLCfg() kafkalib.ConnConfig {
+	cfg := testKafkaCfg()
+	cfg.Servers = kafkaSASLBrokers
+	cfg.SASL = &kafkalib.SASLConfig{
+		Mechanism: kafkalib.SASLMechanismPlain,
+		Username:  kafkaSASLUsername,
+		Password:  kafkaSASLPassword,
+	}
+	return cfg
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	kafkalib "github.com/xataio/pgstream/internal/kafka"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/stream"
 	"github.com/xataio/pgstream/pkg/wal"
@@ -21,9 +22,21 @@ func Test_PostgresToKafka(t *testing.T) {
 		t.Skip("skipping integration test...")
 	}
 
+	runPostgresToKafkaTest(t, testKafkaCfg(), "pg2kafka_integration_test")
+}
+
+func Test_PostgresToKafka_SASL(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+
+	runPostgresToKafkaTest(t, testKafkaSASLCfg(), "pg2kafka_sasl_integration_test")
+}
+
+func runPostgresToKafkaTest(t *testing.T, kafkaCfg kafkalib.ConnConfig, testTable string) {
 	cfg := &stream.Config{
 		Listener:  testPostgresListenerCfg(),
-		Processor: testKafkaProcessorCfg(),
+		Processor: testKafkaProcessorCfg(kafkaCfg),
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -36,9 +49,7 @@ func Test_PostgresToKafka(t *testing.T) {
 		eventChan: make(chan *wal.Event),
 	}
 	defer mockProcessor.close()
-	startKafkaReader(t, ctx, mockProcessor.process)
-
-	testTable := "pg2kafka_integration_test"
+	startKafkaReader(t, ctx, kafkaCfg, mockProcessor.process)
 
 	tests := []struct {
 		name  string
@@ -97,8 +108,12 @@ func Test_PostgresToKafka(t *testing.T) {
 	}
 }
 
-func startKafkaReader(t *testing.T, ctx context.Context, processor func(context.Context, *wal.Event) error) {
-	reader, err := kafkalistener.NewReader(testKafkaListenerCfg().Kafka.Reader, processor)
+func startKafkaReader(t *testing.T, ctx context.Context, kafkaCfg kafkalib.ConnConfig, processor func(context.Context, *wal.Event) error) {
+	startKafkaReaderWithCfg(t, ctx, testKafkaListenerCfg(kafkaCfg), processor)
+}
+
+func startKafkaReaderWithCfg(t *testing.T, ctx context.Context, cfg stream.ListenerConfig, processor func(context.Context, *wal.Event) error) {
+	reader, err := kafkalistener.NewReader(cfg.Kafka.Reader, processor)
 	require.NoError(t, err)
 	go func() {
 		defer reader.Close()
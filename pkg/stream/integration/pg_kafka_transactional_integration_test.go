@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/stream"
+	"github.com/xataio/pgstream/pkg/wal"
+	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
+)
+
+func Test_PostgresToKafkaToKafka_Transactional(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+
+	sourceKafkaCfg := testKafkaCfg()
+	sourceKafkaCfg.Topic.Name = "integration-tests-tx-source"
+	sinkKafkaCfg := testKafkaCfg()
+	sinkKafkaCfg.Topic.Name = "integration-tests-tx-sink"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// postgres to kafka source topic
+	runStream(t, ctx, &stream.Config{
+		Listener:  testPostgresListenerCfg(),
+		Processor: testKafkaProcessorCfg(sourceKafkaCfg),
+	})
+
+	// kafka source topic to kafka sink topic, using transactions
+	listenerCfg := testKafkaListenerCfg(sourceKafkaCfg)
+	listenerCfg.Kafka.Reader.Kafka.ConsumerGroupID = "integration-test-tx-group"
+	runStream(t, ctx, &stream.Config{
+		Listener: listenerCfg,
+		Processor: stream.ProcessorConfig{
+			Kafka: &stream.KafkaProcessorConfig{
+				Writer: &kafkaprocessor.Config{
+					Kafka: sinkKafkaCfg,
+					Transaction: &kafkaprocessor.TransactionConfig{
+						ID:              "integration-test-tx-writer",
+						ConsumerGroupID: listenerCfg.Kafka.Reader.Kafka.ConsumerGroupID,
+					},
+				},
+			},
+		},
+	})
+
+	// use a mock processor and a read committed kafka reader to validate the
+	// kafka messages are properly sent to the sink topic
+	mockProcessor := &mockProcessor{
+		eventChan: make(chan *wal.Event),
+	}
+	defer mockProcessor.close()
+	sinkListenerCfg := testKafkaListenerCfg(sinkKafkaCfg)
+	sinkListenerCfg.Kafka.Reader.Kafka.ReadCommitted = true
+	startKafkaReaderWithCfg(t, ctx, sinkListenerCfg, mockProcessor.process)
+
+	testTable := "pg2kafka2kafka_tx_integration_test"
+	execQuery(t, ctx, fmt.Sprintf("create table %s(id serial primary key, name text)", testTable))
+	execQuery(t, ctx, fmt.Sprintf("insert into %s(name) values('a')", testTable))
+
+	timer := time.NewTimer(30 * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			cancel()
+			t.Error("timeout waiting for wal event")
+			return
+		case event := <-mockProcessor.eventChan:
+			require.NotNil(t, event.Data)
+			// skip the schema log event
+			if event.Data.Table != testTable {
+				continue
+			}
+			require.Equal(t, "I", event.Data.Action)
+			require.Equal(t, "public", event.Data.Schema)
+			return
+		}
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/xataio/pgstream/internal/es"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/stream"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/elasticsearch"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
 )
 
 func Test_PostgresToOpensearch(t *testing.T) {
@@ -22,22 +24,51 @@ func Test_PostgresToOpensearch(t *testing.T) {
 		t.Skip("skipping integration test...")
 	}
 
+	// use a dedicated schema for the opensearch tests to ensure there's no
+	// interference between the other integration tests by having a separate index.
+	runPostgresToSearchTest(t,
+		stream.SearchStoreConfig{
+			OpenSearch: &opensearch.Config{URL: searchURL},
+		},
+		es.ClientConfig{URL: searchURL},
+		"pg2os_integration_test")
+}
+
+func Test_PostgresToElasticsearch(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+
+	runPostgresToSearchTest(t,
+		stream.SearchStoreConfig{
+			Elasticsearch: &elasticsearch.Config{
+				URL:      elasticsearchURL,
+				Username: elasticsearchUsername,
+				Password: elasticsearchPassword,
+			},
+		},
+		es.ClientConfig{
+			URL:      elasticsearchURL,
+			Username: elasticsearchUsername,
+			Password: elasticsearchPassword,
+		},
+		"pg2es_integration_test")
+}
+
+func runPostgresToSearchTest(t *testing.T, storeCfg stream.SearchStoreConfig, clientCfg es.ClientConfig, testSchema string) {
 	cfg := &stream.Config{
 		Listener:  testPostgresListenerCfg(),
-		Processor: testSearchProcessorCfg(),
+		Processor: testSearchProcessorCfg(storeCfg),
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// create a dedicated schema for the opensearch tests to ensure there's no
-	// interference between the other integration tests by having a separate index.
-	testSchema := "pg2os_integration_test"
 	execQuery(t, ctx, fmt.Sprintf("create schema %s", testSchema))
 
 	runStream(t, ctx, cfg)
 
-	client, err := es.NewClient(searchURL)
+	client, err := es.NewClient(clientCfg)
 	require.NoError(t, err)
 
 	var testTablePgstreamID string
@@ -84,7 +115,7 @@ func Test_PostgresToOpensearch(t *testing.T) {
 			query: fmt.Sprintf("insert into %s.%s(name) values('a')", testSchema, testTable),
 
 			validation: func() bool {
-				resp := searchTable(t, ctx, client, testIndex, testTablePgstreamID)
+				resp := searchTable(t, ctx, client, testSchema, testTablePgstreamID)
 				if resp.Hits.Total.Value != 1 {
 					return false
 				}
@@ -95,6 +126,34 @@ func Test_PostgresToOpensearch(t *testing.T) {
 				return true
 			},
 		},
+		{
+			name:  "identity change event",
+			query: fmt.Sprintf("alter table %s.%s drop constraint %s_pkey, add primary key (name)", testSchema, testTable, testTable),
+
+			validation: func() bool {
+				resp := searchTable(t, ctx, client, testSchema, testTablePgstreamID)
+				if resp.Hits.Total.Value != 1 || resp.Hits.Hits[0].Index != fmt.Sprintf("%s-2", testSchema) {
+					return false
+				}
+				hit := resp.Hits.Hits[0]
+				require.Equal(t, fmt.Sprintf("%s_a", testTablePgstreamID), hit.ID)
+				require.Equal(t, "1", hit.Source[fmt.Sprintf("%s-1", testTablePgstreamID)])
+				return true
+			},
+		},
+		{
+			name:  "drop column event",
+			query: fmt.Sprintf("alter table %s.%s drop column id", testSchema, testTable),
+
+			validation: func() bool {
+				resp := searchTable(t, ctx, client, testSchema, testTablePgstreamID)
+				if resp.Hits.Total.Value != 1 {
+					return false
+				}
+				_, found := resp.Hits.Hits[0].Source[fmt.Sprintf("%s-1", testTablePgstreamID)]
+				return !found
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -111,10 +170,10 @@ func Test_PostgresToOpensearch(t *testing.T) {
 				select {
 				case <-timer.C:
 					cancel()
-					t.Error("timeout waiting for opensearch data")
+					t.Error("timeout waiting for search data")
 					return
 				case <-ticker.C:
-					exists, err := client.IndexExists(ctx, testIndex)
+					exists, err := client.IndexExists(ctx, testSchema)
 					require.NoError(t, err)
 					if exists && tc.validation() {
 						return
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/typesense"
+	"github.com/xataio/pgstream/pkg/stream"
+	typesensestore "github.com/xataio/pgstream/pkg/wal/processor/search/typesense"
+)
+
+func Test_PostgresToTypesense(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+
+	testSchema := "pg2typesense_integration_test"
+	cfg := &stream.Config{
+		Listener: testPostgresListenerCfg(),
+		Processor: testSearchProcessorCfg(stream.SearchStoreConfig{
+			Typesense: &typesensestore.Config{
+				URL:    typesenseURL,
+				APIKey: typesenseAPIKey,
+			},
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	execQuery(t, ctx, fmt.Sprintf("create schema %s", testSchema))
+
+	runStream(t, ctx, cfg)
+
+	client, err := typesense.NewClient(typesense.Config{
+		URL:    typesenseURL,
+		APIKey: typesenseAPIKey,
+	})
+	require.NoError(t, err)
+
+	var testTablePgstreamID string
+	testTable := "test"
+
+	tests := []struct {
+		name  string
+		query string
+
+		validation func() bool
+	}{
+		{
+			name:  "schema event",
+			query: fmt.Sprintf("create table %s.%s(id serial primary key, name text, created_at timestamptz)", testSchema, testTable),
+
+			validation: func() bool {
+				resp := searchTypesenseSchemaLog(t, ctx, client, testSchema)
+				if resp == nil || len(resp.Hits) == 0 {
+					return false
+				}
+				hit := resp.Hits[0].Document
+				testTablePgstreamID = getTablePgstreamID(t, hit, testTable)
+
+				require.Equal(t, false, hit["acked"])
+				require.Equal(t, testSchema, hit["schema_name"])
+				require.Equal(t, float64(2), hit["version"])
+
+				collection, err := client.GetCollection(ctx, testSchema)
+				require.NoError(t, err)
+				fields := map[string]string{}
+				for _, f := range collection.Fields {
+					fields[f.Name] = f.Type
+				}
+				require.Equal(t, "string", fields["_table"])
+				require.Equal(t, "int64", fields["_version"])
+				require.Equal(t, "int64", fields[fmt.Sprintf("%s-1", testTablePgstreamID)])
+				require.Equal(t, "string", fields[fmt.Sprintf("%s-2", testTablePgstreamID)])
+				require.Equal(t, "int64", fields[fmt.Sprintf("%s-3", testTablePgstreamID)])
+				return true
+			},
+		},
+		{
+			name:  "insert event",
+			query: fmt.Sprintf("insert into %s.%s(name, created_at) values('a', '2024-05-01 10:00:00+00')", testSchema, testTable),
+
+			validation: func() bool {
+				resp := searchTypesenseTable(t, ctx, client, testSchema, testTablePgstreamID)
+				if resp == nil || len(resp.Hits) != 1 {
+					return false
+				}
+				doc := resp.Hits[0].Document
+				require.Equal(t, fmt.Sprintf("%s_1", testTablePgstreamID), doc["id"])
+				require.Equal(t, "a", doc[fmt.Sprintf("%s-2", testTablePgstreamID)])
+				require.Equal(t, float64(1714557600000), doc[fmt.Sprintf("%s-3", testTablePgstreamID)])
+				return true
+			},
+		},
+		{
+			name:  "update event",
+			query: fmt.Sprintf("update %s.%s set name = 'b' where id = 1", testSchema, testTable),
+
+			validation: func() bool {
+				resp := searchTypesenseTable(t, ctx, client, testSchema, testTablePgstreamID)
+				if resp == nil || len(resp.Hits) != 1 {
+					return false
+				}
+				return resp.Hits[0].Document[fmt.Sprintf("%s-2", testTablePgstreamID)] == "b"
+			},
+		},
+		{
+			name:  "drop column event",
+			query: fmt.Sprintf("alter table %s.%s drop column name", testSchema, testTable),
+
+			validation: func() bool {
+				collection, err := client.GetCollection(ctx, testSchema)
+				require.NoError(t, err)
+				for _, f := range collection.Fields {
+					if f.Name == fmt.Sprintf("%s-2", testTablePgstreamID) {
+						return false
+					}
+				}
+				return true
+			},
+		},
+		{
+			name:  "delete event",
+			query: fmt.Sprintf("delete from %s.%s where id = 1", testSchema, testTable),
+
+			validation: func() bool {
+				resp := searchTypesenseTable(t, ctx, client, testSchema, testTablePgstreamID)
+				return resp != nil && len(resp.Hits) == 0
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			execQuery(t, ctx, tc.query)
+
+			timer := time.NewTimer(20 * time.Second)
+			defer timer.Stop()
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-timer.C:
+					cancel()
+					t.Error("timeout waiting for typesense data")
+					return
+				case <-ticker.C:
+					if tc.validation() {
+						return
+					}
+				}
+			}
+		})
+	}
+}
+
+// searchTypesenseSchemaLog returns the schema log entries of the schema, or
+// nil if the schema log collection has not been created yet.
+func searchTypesenseSchemaLog(t *testing.T, ctx context.Context, client *typesense.HTTPClient, schemaName string) *typesense.SearchResponse {
+	return searchTypesense(t, ctx, client, "pgstream_schema_log", &typesense.SearchRequest{
+		FilterBy: "schema_name:=" + typesense.FilterValues([]string{schemaName}),
+		SortBy:   "version:desc",
+	})
+}
+
+func searchTypesenseTable(t *testing.T, ctx context.Context, client *typesense.HTTPClient, collection, tableID string) *typesense.SearchResponse {
+	return searchTypesense(t, ctx, client, collection, &typesense.SearchRequest{
+		FilterBy: "_table:=" + typesense.FilterValues([]string{tableID}),
+	})
+}
+
+func searchTypesense(t *testing.T, ctx context.Context, client *typesense.HTTPClient, collection string, req *typesense.SearchRequest) *typesense.SearchResponse {
+	resp, err := client.SearchDocuments(ctx, collection, req)
+	if errors.Is(err, typesense.ErrNotFound) {
+		return nil
+	}
+	require.NoError(t, err)
+	return resp
+}
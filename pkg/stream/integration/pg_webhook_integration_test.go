@@ -33,13 +33,14 @@ func Test_PostgresToWebhook(t *testing.T) {
 	defer cancel()
 	runStream(t, ctx, cfg)
 
-	mockWebhookServer := newMockWebhookServer()
+	testSecret := "pg2webhook_integration_test_secret"
+	mockWebhookServer := newMockWebhookServer(testSecret)
 	defer mockWebhookServer.close()
 
 	testTable := "pg2webhook_integration_test"
-	// create a subscription to the test table with the mock server url
-	createSubscription(t, mockWebhookServer.URL, "public", testTable)
-	createSubscription(t, mockWebhookServer.URL, schemalog.SchemaName, schemalog.TableName)
+	// create a signed subscription to the test table with the mock server url
+	createSubscription(t, mockWebhookServer.URL, "public", testTable, testSecret)
+	createSubscription(t, mockWebhookServer.URL, schemalog.SchemaName, schemalog.TableName, testSecret)
 
 	tests := []struct {
 		name  string
@@ -94,11 +95,12 @@ func Test_PostgresToWebhook(t *testing.T) {
 	}
 }
 
-func createSubscription(t *testing.T, url, schema, table string) {
+func createSubscription(t *testing.T, url, schema, table, secret string) {
 	subscription := subscription.Subscription{
 		URL:    url,
 		Schema: schema,
 		Table:  table,
+		Secret: secret,
 	}
 	subscriptionBytes, err := json.Marshal(subscription)
 	require.NoError(t, err)
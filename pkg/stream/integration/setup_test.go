@@ -39,11 +39,29 @@ func TestMain(m *testing.M) {
 		}
 		defer kafkacleanup()
 
+		kafkasaslcleanup, err := setupKafkaSASLContainer(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer kafkasaslcleanup()
+
 		oscleanup, err := setupOpenSearchContainer(ctx)
 		if err != nil {
 			log.Fatal(err)
 		}
 		defer oscleanup()
+
+		escleanup, err := setupElasticsearchContainer(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer escleanup()
+
+		typesensecleanup, err := setupTypesenseContainer(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer typesensecleanup()
 	}
 
 	os.Exit(m.Run())
@@ -100,6 +118,40 @@ func setupKafkaContainer(ctx context.Context) (cleanup, error) {
 	}, nil
 }
 
+// setupKafkaSASLContainer starts a kafka container with SASL PLAIN
+// authentication enabled on the client listener.
+func setupKafkaSASLContainer(ctx context.Context) (cleanup, error) {
+	ctr, err := kafka.RunContainer(ctx,
+		kafka.WithClusterID("test-sasl-cluster"),
+		testcontainers.WithImage("confluentinc/confluent-local:7.5.0"),
+		testcontainers.WithEnv(map[string]string{
+			"KAFKA_LISTENER_SECURITY_PROTOCOL_MAP":                  "BROKER:PLAINTEXT,PLAINTEXT:SASL_PLAINTEXT,CONTROLLER:PLAINTEXT",
+			"KAFKA_SASL_ENABLED_MECHANISMS":                         "PLAIN",
+			"KAFKA_LISTENER_NAME_PLAINTEXT_SASL_ENABLED_MECHANISMS": "PLAIN",
+			"KAFKA_LISTENER_NAME_PLAINTEXT_PLAIN_SASL_JAAS_CONFIG": fmt.Sprintf(
+				`org.apache.kafka.common.security.plain.PlainLoginModule required username="%[1]s" password="%[2]s" user_%[1]s="%[2]s";`,
+				kafkaSASLUsername, kafkaSASLPassword),
+		}),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("Kafka Server started").
+				WithOccurrence(1).
+				WithStartupTimeout(5*time.Second),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start kafka sasl container: %w", err)
+	}
+
+	kafkaSASLBrokers, err = ctr.Brokers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving brokers for kafka sasl container: %w", err)
+	}
+
+	return func() error {
+		return ctr.Terminate(ctx)
+	}, nil
+}
+
 func setupOpenSearchContainer(ctx context.Context) (cleanup, error) {
 	ctr, err := opensearch.RunContainer(ctx,
 		testcontainers.WithImage("opensearchproject/opensearch:2.11.1"),
@@ -117,3 +169,74 @@ func setupOpenSearchContainer(ctx context.Context) (cleanup, error) {
 		return ctr.Terminate(ctx)
 	}, nil
 }
+
+// setupElasticsearchContainer starts an elasticsearch 8 container with basic
+// authentication enabled.
+func setupElasticsearchContainer(ctx context.Context) (cleanup, error) {
+	const httpPort = "9200/tcp"
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "docker.elastic.co/elasticsearch/elasticsearch:8.13.4",
+			ExposedPorts: []string{httpPort},
+			Env: map[string]string{
+				"discovery.type":                                    "single-node",
+				"xpack.security.enabled":                            "true",
+				"xpack.security.http.ssl.enabled":                   "false",
+				"ELASTIC_PASSWORD":                                  elasticsearchPassword,
+				"ES_JAVA_OPTS":                                      "-Xms512m -Xmx512m",
+				"cluster.routing.allocation.disk.threshold_enabled": "false",
+			},
+			WaitingFor: wait.ForHTTP("/_cluster/health").
+				WithPort(httpPort).
+				WithBasicAuth(elasticsearchUsername, elasticsearchPassword).
+				WithStartupTimeout(2 * time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start elasticsearch container: %w", err)
+	}
+
+	endpoint, err := ctr.PortEndpoint(ctx, httpPort, "http")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving url for elasticsearch container: %w", err)
+	}
+	elasticsearchURL = endpoint
+
+	return func() error {
+		return ctr.Terminate(ctx)
+	}, nil
+}
+
+// setupTypesenseContainer starts a typesense container with the test admin
+// API key.
+func setupTypesenseContainer(ctx context.Context) (cleanup, error) {
+	const httpPort = "8108/tcp"
+	ctr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "typesense/typesense:27.1",
+			ExposedPorts: []string{httpPort},
+			Env: map[string]string{
+				"TYPESENSE_API_KEY":  typesenseAPIKey,
+				"TYPESENSE_DATA_DIR": "/tmp",
+			},
+			WaitingFor: wait.ForHTTP("/health").
+				WithPort(httpPort).
+				WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start typesense container: %w", err)
+	}
+
+	endpoint, err := ctr.PortEndpoint(ctx, httpPort, "http")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving url for typesense container: %w", err)
+	}
+	typesenseURL = endpoint
+
+	return func() error {
+		return ctr.Terminate(ctx)
+	}, nil
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/xataio/pgstream/internal/kafka"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	kafkacheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/kafka"
@@ -17,8 +18,9 @@ import (
 	processinstrumentation "github.com/xataio/pgstream/pkg/wal/processor/instrumentation"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
-	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
 	"github.com/xataio/pgstream/pkg/wal/processor/translator"
+	pgretryqueue "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue/postgres"
+	pgdeliverystore "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store/postgres"
 	webhooknotifier "github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
 	subscriptionserver "github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/server"
 	webhookstore "github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/store"
@@ -62,12 +64,33 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, meter metric
 
 	// Checkpointer
 
+	// when the kafka listener processes partitions in parallel, the offsets
+	// need to be tracked so that the checkpointer only commits contiguous
+	// processed offsets. The transactional kafka writer doesn't need it, since
+	// it commits the offsets of the events it has written, which are processed
+	// in order within each partition.
+	var kafkaOffsetTracker *kafka.OffsetTracker
+	if config.Listener.Kafka != nil && config.Listener.Kafka.Reader.Parallel != nil && !config.kafkaTransactionsEnabled() {
+		kafkaOffsetTracker = kafka.NewOffsetTracker()
+	}
+
 	var checkpoint checkpointer.Checkpoint
 	switch {
+	case config.kafkaTransactionsEnabled():
+		// the kafka offsets are committed by the kafka batch writer as part of
+		// its write transactions
+		logger.Info("kafka offsets will be committed by the transactional kafka writer")
+
 	case config.Listener.Kafka != nil:
+		opts := []kafkacheckpoint.Option{
+			kafkacheckpoint.WithLogger(logger),
+		}
+		if kafkaOffsetTracker != nil {
+			opts = append(opts, kafkacheckpoint.WithOffsetTracker(kafkaOffsetTracker))
+		}
 		kafkaCheckpointer, err := kafkacheckpoint.New(ctx,
 			config.Listener.Kafka.Checkpointer,
-			kafkacheckpoint.WithLogger(logger))
+			opts...)
 		if err != nil {
 			return fmt.Errorf("error setting up kafka checkpointer:%w", err)
 		}
@@ -106,23 +129,36 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, meter metric
 			return kafkaWriter.Send(ctx)
 		})
 	case config.Processor.Search != nil:
-		var searchStore search.Store
-		var err error
-		searchStore, err = opensearch.NewStore(config.Processor.Search.Store, opensearch.WithLogger(logger))
+		searchStore, deadLetterStore, err := newSearchStores(ctx, logger, config.Processor.Search)
 		if err != nil {
 			return err
 		}
-		if config.Processor.Search.Retrier != nil {
-			logger.Debug("using retry logic with search store...")
-			searchStore = search.NewStoreRetrier(searchStore, config.Processor.Search.Retrier, search.WithStoreLogger(logger))
+
+		indexerOpts := []search.Option{
+			search.WithCheckpoint(checkpoint),
+			search.WithLogger(logger),
+		}
+		if deadLetterStore != nil {
+			defer deadLetterStore.Close()
+			indexerOpts = append(indexerOpts, search.WithDeadLetterStore(deadLetterStore))
+		}
+		if meter != nil {
+			indexerOpts = append(indexerOpts, search.WithInstrumentation(meter))
+		}
+		if config.Processor.Search.Denormalisation != nil {
+			denormalisationCfg, relatedRowReader, err := newSearchDenormalisation(ctx, config.Processor.Search.Denormalisation)
+			if err != nil {
+				return err
+			}
+			defer relatedRowReader.Close()
+			indexerOpts = append(indexerOpts, search.WithDenormalisation(denormalisationCfg, relatedRowReader))
 		}
 
 		searchIndexer := search.NewBatchIndexer(ctx,
 			config.Processor.Search.Indexer,
 			searchStore,
 			pgreplication.NewLSNParser(),
-			search.WithCheckpoint(checkpoint),
-			search.WithLogger(logger),
+			indexerOpts...,
 		)
 		defer searchIndexer.Close()
 		processor = searchIndexer
@@ -134,6 +170,19 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, meter metric
 			return searchIndexer.Send(ctx)
 		})
 
+		if verifyCfg := config.Processor.Search.Verify; verifyCfg != nil && verifyCfg.Interval > 0 {
+			verifier, err := newSearchVerifier(ctx, logger, config.Processor.Search)
+			if err != nil {
+				return err
+			}
+			defer verifier.Close()
+
+			eg.Go(func() error {
+				logger.Info("running search verifier...")
+				return verifier.Run(ctx)
+			})
+		}
+
 	case config.Processor.Webhook != nil:
 		var subscriptionStore webhookstore.Store
 		var err error
@@ -157,18 +206,45 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, meter metric
 			}
 		}
 
+		// the failed deliveries and the delivery log are kept in the
+		// subscription store database
+		retryQueue, err := pgretryqueue.NewRetryQueue(ctx,
+			config.Processor.Webhook.SubscriptionStore.URL,
+			pgretryqueue.WithLogger(logger),
+		)
+		if err != nil {
+			return err
+		}
+
+		deliveryStore, err := pgdeliverystore.NewDeliveryStore(ctx,
+			config.Processor.Webhook.SubscriptionStore.URL,
+			pgdeliverystore.WithLogger(logger),
+		)
+		if err != nil {
+			return err
+		}
+
+		notifierOpts := []webhooknotifier.Option{
+			webhooknotifier.WithLogger(logger),
+			webhooknotifier.WithCheckpoint(checkpoint),
+			webhooknotifier.WithRetryQueue(retryQueue),
+			webhooknotifier.WithDeliveryLog(deliveryStore),
+		}
+		if meter != nil {
+			notifierOpts = append(notifierOpts, webhooknotifier.WithInstrumentation(meter))
+		}
 		notifier := webhooknotifier.New(
 			&config.Processor.Webhook.Notifier,
 			subscriptionStore,
-			webhooknotifier.WithLogger(logger),
-			webhooknotifier.WithCheckpoint(checkpoint))
+			notifierOpts...)
 		defer notifier.Close()
 		processor = notifier
 
 		subscriptionServer := subscriptionserver.New(
 			&config.Processor.Webhook.SubscriptionServer,
 			subscriptionStore,
-			subscriptionserver.WithLogger(logger))
+			subscriptionserver.WithLogger(logger),
+			subscriptionserver.WithDeliveryLog(deliveryStore, retryQueue))
 
 		eg.Go(func() error {
 			logger.Info("running subscription server...")
@@ -180,6 +256,10 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, meter metric
 			logger.Info("running webhook notifier...")
 			return notifier.Notify(ctx)
 		})
+		eg.Go(func() error {
+			logger.Info("running webhook retry queue redrive...")
+			return notifier.Redrive(ctx)
+		})
 
 	default:
 		return errors.New("no processor found")
@@ -218,9 +298,19 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, meter metric
 		})
 	case config.Listener.Kafka != nil:
 		var err error
+		opts := []kafkalistener.Option{
+			kafkalistener.WithLogger(logger),
+			kafkalistener.WithProcessorName(processor.Name()),
+		}
+		if meter != nil {
+			opts = append(opts, kafkalistener.WithInstrumentation(meter))
+		}
+		if kafkaOffsetTracker != nil {
+			opts = append(opts, kafkalistener.WithOffsetTracker(kafkaOffsetTracker))
+		}
 		listener, err := kafkalistener.NewReader(config.Listener.Kafka.Reader,
 			processor.ProcessWALEvent,
-			kafkalistener.WithLogger(logger))
+			opts...)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+	deadletterfile "github.com/xataio/pgstream/pkg/wal/processor/search/deadletter/file"
+	deadletterpg "github.com/xataio/pgstream/pkg/wal/processor/search/deadletter/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/elasticsearch"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
+	relationspg "github.com/xataio/pgstream/pkg/wal/processor/search/relations/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/typesense"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/verify"
+)
+
+// RedriveSearchDeadLetters sends the documents in the configured search dead
+// letter store to the search store again, removing the ones that are indexed.
+func RedriveSearchDeadLetters(ctx context.Context, logger loglib.Logger, config *SearchProcessorConfig) (*search.RedriveResult, error) {
+	if config == nil {
+		return nil, fmt.Errorf("search processor not configured")
+	}
+	if err := config.Store.IsValid(); err != nil {
+		return nil, err
+	}
+	if config.DeadLetter == nil {
+		return nil, fmt.Errorf("search dead letter store not configured")
+	}
+	if err := config.DeadLetter.IsValid(); err != nil {
+		return nil, err
+	}
+
+	searchStore, deadLetterStore, err := newSearchStores(ctx, logger, config)
+	if err != nil {
+		return nil, err
+	}
+	defer deadLetterStore.Close()
+
+	redriver := search.NewRedriver(deadLetterStore, searchStore,
+		search.WithRedriverLogger(logger),
+		search.WithRedriveBatchSize(config.Indexer.BatchSize),
+	)
+	return redriver.Redrive(ctx)
+}
+
+// VerifySearch compares the postgres tables with the documents indexed in the
+// configured search store, reporting the missing, extra and stale documents.
+func VerifySearch(ctx context.Context, logger loglib.Logger, config *SearchProcessorConfig) (*verify.Report, error) {
+	if config == nil {
+		return nil, fmt.Errorf("search processor not configured")
+	}
+	if err := config.Store.IsValid(); err != nil {
+		return nil, err
+	}
+	if config.Verify == nil {
+		return nil, fmt.Errorf("search verification not configured")
+	}
+
+	verifier, err := newSearchVerifier(ctx, logger, config)
+	if err != nil {
+		return nil, err
+	}
+	defer verifier.Close()
+
+	return verifier.Verify(ctx)
+}
+
+func newSearchVerifier(ctx context.Context, logger loglib.Logger, config *SearchProcessorConfig) (*verify.Verifier, error) {
+	if config.Store.Typesense != nil {
+		return nil, errors.New("search verification is not supported with the typesense store")
+	}
+	store, err := newSearchStore(logger, config)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := verify.New(ctx, config.Verify, store, store, verify.WithLogger(logger))
+	if err != nil {
+		return nil, fmt.Errorf("creating search verifier: %w", err)
+	}
+	return verifier, nil
+}
+
+// newSearchDenormalisation returns the relations embedded in the search
+// documents, along with the reader of their related rows.
+func newSearchDenormalisation(ctx context.Context, config *SearchDenormalisationConfig) (*search.DenormalisationConfig, search.RelatedRowReader, error) {
+	cfg, err := search.LoadDenormalisationConfig(config.ConfigFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := relationspg.NewReader(ctx, config.Postgres)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating search related row reader: %w", err)
+	}
+	return cfg, reader, nil
+}
+
+// newSearchStores returns the configured search store, wrapped with the
+// retrier if configured, and the dead letter store, which is nil if not
+// configured.
+func newSearchStores(ctx context.Context, logger loglib.Logger, config *SearchProcessorConfig) (search.Store, search.DeadLetterStore, error) {
+	var searchStore search.Store
+	// store is the opensearch compatible store, used for the dead letter
+	// index. It's not set for the typesense store.
+	var store *opensearch.Store
+	if config.Store.Typesense != nil {
+		typesenseStore, err := typesense.NewStore(*config.Store.Typesense, typesense.WithLogger(logger))
+		if err != nil {
+			return nil, nil, err
+		}
+		searchStore = typesenseStore
+	} else {
+		var err error
+		if store, err = newSearchStore(logger, config); err != nil {
+			return nil, nil, err
+		}
+		searchStore = store
+	}
+
+	if config.Retrier != nil {
+		logger.Debug("using retry logic with search store...")
+		searchStore = search.NewStoreRetrier(searchStore, config.Retrier, search.WithStoreLogger(logger))
+	}
+
+	if config.DeadLetter == nil {
+		return searchStore, nil, nil
+	}
+
+	var deadLetterStore search.DeadLetterStore
+	var err error
+	switch {
+	case config.DeadLetter.File != nil:
+		deadLetterStore, err = deadletterfile.NewStore(*config.DeadLetter.File)
+	case config.DeadLetter.Postgres != nil:
+		deadLetterStore, err = deadletterpg.NewStore(ctx, *config.DeadLetter.Postgres)
+	case store == nil:
+		return nil, nil, errors.New("the search dead letter index is not supported with the typesense store")
+	default:
+		deadLetterStore = store.DeadLetterIndex(config.DeadLetter.Index)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating search dead letter store: %w", err)
+	}
+	return searchStore, deadLetterStore, nil
+}
+
+// newSearchStore returns the configured opensearch compatible search store
+// backend. The elasticsearch store relies on the opensearch store
+// implementation.
+func newSearchStore(logger loglib.Logger, config *SearchProcessorConfig) (*opensearch.Store, error) {
+	if config.Store.Elasticsearch != nil {
+		store, err := elasticsearch.NewStore(*config.Store.Elasticsearch, elasticsearch.WithLogger(logger))
+		if err != nil {
+			return nil, err
+		}
+		return store.Store, nil
+	}
+	return opensearch.NewStore(*config.Store.OpenSearch, opensearch.WithLogger(logger))
+}
@@ -20,6 +20,10 @@ type Checkpointer struct {
 	backoffProvider backoff.Provider
 	logger          loglib.Logger
 	offsetParser    kafka.OffsetParser
+	// offsetTracker is used when the kafka messages are processed
+	// concurrently, to make sure only the highest contiguous processed offset
+	// per partition is committed.
+	offsetTracker offsetTracker
 }
 
 type Config struct {
@@ -32,6 +36,10 @@ type msgCommitter interface {
 	Close() error
 }
 
+type offsetTracker interface {
+	Committable(checkpointed []*kafka.Offset) []*kafka.Offset
+}
+
 type Option func(c *Checkpointer)
 
 // New returns a kafka checkpointer that commits the message offsets to kafka by
@@ -62,25 +70,18 @@ func WithLogger(l loglib.Logger) Option {
 	}
 }
 
-func (c *Checkpointer) CommitOffsets(ctx context.Context, positions []wal.CommitPosition) error {
-	// keep track of the last offset per topic+partition
-	offsetMap := make(map[string]*kafka.Offset, len(positions))
-	for _, pos := range positions {
-		offset, err := c.offsetParser.FromString(string(pos))
-		if err != nil {
-			return err
-		}
-
-		topicPartition := fmt.Sprintf("%s-%d", offset.Topic, offset.Partition)
-		lastOffset, found := offsetMap[topicPartition]
-		if !found || lastOffset.Offset < offset.Offset {
-			offsetMap[topicPartition] = offset
-		}
+// WithOffsetTracker makes the checkpointer commit only the highest contiguous
+// processed offset per partition, as reported by the tracker.
+func WithOffsetTracker(t *kafka.OffsetTracker) Option {
+	return func(c *Checkpointer) {
+		c.offsetTracker = t
 	}
+}
 
-	offsets := make([]*kafka.Offset, 0, len(offsetMap))
-	for _, offset := range offsetMap {
-		offsets = append(offsets, offset)
+func (c *Checkpointer) CommitOffsets(ctx context.Context, positions []wal.CommitPosition) error {
+	offsets, err := c.offsetsToCommit(positions)
+	if err != nil {
+		return err
 	}
 
 	if err := c.commitOffsetsWithRetry(ctx, offsets); err != nil {
@@ -98,6 +99,24 @@ func (c *Checkpointer) CommitOffsets(ctx context.Context, positions []wal.Commit
 	return nil
 }
 
+func (c *Checkpointer) offsetsToCommit(positions []wal.CommitPosition) ([]*kafka.Offset, error) {
+	parsedOffsets := make([]*kafka.Offset, 0, len(positions))
+	for _, pos := range positions {
+		offset, err := c.offsetParser.FromString(string(pos))
+		if err != nil {
+			return nil, err
+		}
+		parsedOffsets = append(parsedOffsets, offset)
+	}
+
+	if c.offsetTracker != nil {
+		return c.offsetTracker.Committable(parsedOffsets), nil
+	}
+
+	// keep track of the last offset per topic+partition
+	return kafka.LastOffsetsByPartition(parsedOffsets), nil
+}
+
 func (c *Checkpointer) Close() error {
 	return c.committer.Close()
 }
@@ -64,6 +64,7 @@ func TestCheckpointer_CommitOffsets(t *testing.T) {
 		reader          *kafkamocks.Reader
 		backoffProvider backoff.Provider
 		parser          kafka.OffsetParser
+		tracker         func() *kafka.OffsetTracker
 
 		wantErr error
 	}{
@@ -87,6 +88,36 @@ func TestCheckpointer_CommitOffsets(t *testing.T) {
 
 			wantErr: nil,
 		},
+		{
+			name: "ok - with offset tracker",
+			reader: &kafkamocks.Reader{
+				CommitOffsetsFn: func(ctx context.Context, offsets ...*kafka.Offset) error {
+					require.ElementsMatch(t, offsets, []*kafka.Offset{
+						testOffsets[0], testOffsets[1],
+					})
+					return nil
+				},
+			},
+			backoffProvider: func(ctx context.Context) backoff.Backoff {
+				return &backoffmocks.Backoff{
+					RetryNotifyFn: func(o backoff.Operation, n backoff.Notify) error {
+						return o()
+					},
+				}
+			},
+			tracker: func() *kafka.OffsetTracker {
+				tracker := kafka.NewOffsetTracker()
+				for _, o := range testOffsets {
+					tracker.Track(o)
+				}
+				// the last offset for partition 1 is still being processed
+				tracker.MarkProcessed(testOffsets[0])
+				tracker.MarkProcessed(testOffsets[1])
+				return tracker
+			},
+
+			wantErr: nil,
+		},
 		{
 			name: "error - committing offsets",
 			reader: &kafkamocks.Reader{
@@ -139,6 +170,10 @@ func TestCheckpointer_CommitOffsets(t *testing.T) {
 				r.offsetParser = tc.parser
 			}
 
+			if tc.tracker != nil {
+				r.offsetTracker = tc.tracker()
+			}
+
 			err := r.CommitOffsets(context.Background(), testPositions)
 			require.ErrorIs(t, err, tc.wantErr)
 		})
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package claimcheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/rs/xid"
+	"github.com/xataio/pgstream/internal/blobstore"
+	"github.com/xataio/pgstream/internal/blobstore/local"
+	"github.com/xataio/pgstream/internal/blobstore/s3"
+)
+
+// ClaimCheck implements the claim check pattern for payloads that are too
+// large to be sent inline. The payload is stored in a blob store, and a small
+// reference is sent instead, which the consumer uses to retrieve it.
+//
+// The stored payloads are not removed once retrieved, since they can be
+// consumed more than once. Their retention should be managed by the blob store
+// (e.g. S3 bucket lifecycle rules).
+type ClaimCheck struct {
+	store     blobstore.Store
+	keyPrefix string
+	keyGen    func() string
+}
+
+type Config struct {
+	// Local configures a local filesystem blob store.
+	Local *local.Config
+	// S3 configures an S3 compatible blob store.
+	S3 *s3.Config
+	// KeyPrefix is prepended to the keys of the stored payloads.
+	KeyPrefix string
+}
+
+// Reference is sent instead of the payload. It contains the information
+// required to retrieve and validate the stored payload.
+type Reference struct {
+	Key    string `json:"key"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Header is the kafka header that identifies a message value as a claim check
+// reference.
+const Header = "pgstream-claim-check"
+
+var errChecksumMismatch = errors.New("stored payload checksum mismatch")
+
+func New(cfg *Config) (*ClaimCheck, error) {
+	store, err := newStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithStore(store, cfg.KeyPrefix), nil
+}
+
+func NewWithStore(store blobstore.Store, keyPrefix string) *ClaimCheck {
+	return &ClaimCheck{
+		store:     store,
+		keyPrefix: keyPrefix,
+		keyGen:    func() string { return xid.New().String() },
+	}
+}
+
+func newStore(cfg *Config) (blobstore.Store, error) {
+	switch {
+	case cfg.Local != nil && cfg.S3 != nil:
+		return nil, errors.New("claim check: only one blob store can be configured")
+	case cfg.Local != nil:
+		return local.NewStore(cfg.Local)
+	case cfg.S3 != nil:
+		return s3.NewStore(cfg.S3)
+	default:
+		return nil, errors.New("claim check: no blob store configured")
+	}
+}
+
+// Store saves the payload in the blob store, grouped by schema and table, and
+// returns the serialised reference to it.
+func (c *ClaimCheck) Store(ctx context.Context, schema, table string, payload []byte) ([]byte, error) {
+	ref := &Reference{
+		Key:    path.Join(c.keyPrefix, schema, table, c.keyGen()+".json"),
+		Size:   len(payload),
+		SHA256: checksum(payload),
+	}
+
+	if err := c.store.Put(ctx, ref.Key, payload); err != nil {
+		return nil, fmt.Errorf("claim check: storing payload: %w", err)
+	}
+
+	refBytes, err := json.Marshal(ref)
+	if err != nil {
+		return nil, fmt.Errorf("claim check: marshaling reference: %w", err)
+	}
+	return refBytes, nil
+}
+
+// Retrieve returns the stored payload for the serialised reference on input,
+// making sure it matches the original one.
+func (c *ClaimCheck) Retrieve(ctx context.Context, refBytes []byte) ([]byte, error) {
+	ref := &Reference{}
+	if err := json.Unmarshal(refBytes, ref); err != nil {
+		return nil, fmt.Errorf("claim check: unmarshaling reference: %w", err)
+	}
+
+	payload, err := c.store.Get(ctx, ref.Key)
+	if err != nil {
+		return nil, fmt.Errorf("claim check: retrieving payload %s: %w", ref.Key, err)
+	}
+
+	if len(payload) != ref.Size || checksum(payload) != ref.SHA256 {
+		return nil, fmt.Errorf("claim check: payload %s: %w", ref.Key, errChecksumMismatch)
+	}
+
+	return payload, nil
+}
+
+func checksum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package claimcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/blobstore"
+	"github.com/xataio/pgstream/internal/blobstore/mocks"
+)
+
+func TestClaimCheck_Store(t *testing.T) {
+	t.Parallel()
+
+	testPayload := []byte(`{"id":1}`)
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name  string
+		store *mocks.Store
+
+		wantRef string
+		wantErr error
+	}{
+		{
+			name: "ok",
+			store: &mocks.Store{
+				PutFn: func(ctx context.Context, key string, data []byte) error {
+					require.Equal(t, "prefix/public/test/1.json", key)
+					require.Equal(t, testPayload, data)
+					return nil
+				},
+			},
+
+			wantRef: `{"key":"prefix/public/test/1.json","size":8,"sha256":"` + checksum(testPayload) + `"}`,
+		},
+		{
+			name: "error - storing payload",
+			store: &mocks.Store{
+				PutFn: func(ctx context.Context, key string, data []byte) error {
+					return errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewWithStore(tc.store, "prefix")
+			c.keyGen = func() string { return "1" }
+
+			ref, err := c.Store(context.Background(), "public", "test", testPayload)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.JSONEq(t, tc.wantRef, string(ref))
+		})
+	}
+}
+
+func TestClaimCheck_Retrieve(t *testing.T) {
+	t.Parallel()
+
+	testPayload := []byte(`{"id":1}`)
+	testRef := []byte(`{"key":"public/test/1.json","size":8,"sha256":"` + checksum(testPayload) + `"}`)
+
+	getFn := func(payload []byte, err error) func(context.Context, string) ([]byte, error) {
+		return func(ctx context.Context, key string) ([]byte, error) {
+			require.Equal(t, "public/test/1.json", key)
+			return payload, err
+		}
+	}
+
+	tests := []struct {
+		name  string
+		ref   []byte
+		store *mocks.Store
+
+		wantPayload []byte
+		wantErr     error
+	}{
+		{
+			name:  "ok",
+			ref:   testRef,
+			store: &mocks.Store{GetFn: getFn(testPayload, nil)},
+
+			wantPayload: testPayload,
+		},
+		{
+			name:  "error - payload not found",
+			ref:   testRef,
+			store: &mocks.Store{GetFn: getFn(nil, blobstore.ErrNotFound)},
+
+			wantErr: blobstore.ErrNotFound,
+		},
+		{
+			name:  "error - checksum mismatch",
+			ref:   testRef,
+			store: &mocks.Store{GetFn: getFn([]byte(`{"id":2}`), nil)},
+
+			wantErr: errChecksumMismatch,
+		},
+		{
+			name:  "error - invalid reference",
+			ref:   []byte("invalid"),
+			store: &mocks.Store{},
+
+			wantErr: errors.New("claim check: unmarshaling reference: invalid character 'i' looking for beginning of value"),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewWithStore(tc.store, "")
+			payload, err := c.Retrieve(context.Background(), tc.ref)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					require.EqualError(t, err, tc.wantErr.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantPayload, payload)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xataio/pgstream/internal/kafka"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+type DeadLetterConfig struct {
+	// Kafka connection configuration for the dead letter topic.
+	Kafka kafka.ConnConfig
+}
+
+// deadLetterQueue writes the records that can't be processed by the reader to
+// the configured dead letter topic. The original key, value and headers are
+// preserved, and the error metadata is added as headers.
+type deadLetterQueue struct {
+	writer        kafka.MessageWriter
+	processorName string
+	clock         func() time.Time
+}
+
+// Headers added to the dead lettered records
+const (
+	DeadLetterReasonHeader          = "pgstream-dlq-reason"
+	DeadLetterErrorHeader           = "pgstream-dlq-error"
+	DeadLetterProcessorHeader       = "pgstream-dlq-processor"
+	DeadLetterTimestampHeader       = "pgstream-dlq-timestamp"
+	DeadLetterRetryCountHeader      = "pgstream-dlq-retry-count"
+	DeadLetterSourceTopicHeader     = "pgstream-dlq-source-topic"
+	DeadLetterSourcePartitionHeader = "pgstream-dlq-source-partition"
+	DeadLetterSourceOffsetHeader    = "pgstream-dlq-source-offset"
+)
+
+type deadLetterReason string
+
+const (
+	deadLetterReasonUnmarshal  deadLetterReason = "unmarshal"
+	deadLetterReasonProcessing deadLetterReason = "processing"
+	deadLetterReasonClaimCheck deadLetterReason = "claim_check"
+)
+
+func newDeadLetterQueue(cfg *DeadLetterConfig, processorName string, logger loglib.Logger) (*deadLetterQueue, error) {
+	// the dead letter writes are synchronous and not batched, so we don't
+	// want the kafka-go writer to wait for the default batch timeout.
+	const kafkaBatchTimeout = 10 * time.Millisecond
+	writer, err := kafka.NewWriter(kafka.WriterConfig{
+		Conn:         cfg.Kafka,
+		BatchTimeout: kafkaBatchTimeout,
+	}, logger)
+	if err != nil {
+		return nil, fmt.Errorf("creating dead letter writer: %w", err)
+	}
+
+	return &deadLetterQueue{
+		writer:        writer,
+		processorName: processorName,
+		clock:         time.Now,
+	}, nil
+}
+
+func (q *deadLetterQueue) send(ctx context.Context, msg *kafka.Message, reason deadLetterReason, cause error, retries uint) error {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+8)
+	headers = append(headers, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterReasonHeader, Value: []byte(reason)},
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(cause.Error())},
+		kafka.Header{Key: DeadLetterProcessorHeader, Value: []byte(q.processorName)},
+		kafka.Header{Key: DeadLetterTimestampHeader, Value: []byte(q.clock().UTC().Format(time.RFC3339Nano))},
+		kafka.Header{Key: DeadLetterRetryCountHeader, Value: []byte(strconv.FormatUint(uint64(retries), 10))},
+		kafka.Header{Key: DeadLetterSourceTopicHeader, Value: []byte(msg.Topic)},
+		kafka.Header{Key: DeadLetterSourcePartitionHeader, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: DeadLetterSourceOffsetHeader, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+
+	dlqMsg := kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+	if err := q.writer.WriteMessages(ctx, dlqMsg); err != nil {
+		return fmt.Errorf("writing to dead letter topic: %w", err)
+	}
+	return nil
+}
+
+func (q *deadLetterQueue) close() error {
+	return q.writer.Close()
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/kafka"
+	kafkamocks "github.com/xataio/pgstream/internal/kafka/mocks"
+)
+
+func TestDeadLetterQueue_send(t *testing.T) {
+	t.Parallel()
+
+	testTime := time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC)
+	testMsg := &kafka.Message{
+		Topic:     "test-topic",
+		Partition: 2,
+		Offset:    10,
+		Key:       []byte("test-key"),
+		Value:     []byte("test-value"),
+		Headers: []kafka.Header{
+			{Key: "original", Value: []byte("header")},
+		},
+	}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		writer *kafkamocks.Writer
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			writer: &kafkamocks.Writer{
+				WriteMessagesFn: func(ctx context.Context, i uint64, msgs ...kafka.Message) error {
+					require.Equal(t, []kafka.Message{
+						{
+							Key:   testMsg.Key,
+							Value: testMsg.Value,
+							Headers: []kafka.Header{
+								{Key: "original", Value: []byte("header")},
+								{Key: DeadLetterReasonHeader, Value: []byte("processing")},
+								{Key: DeadLetterErrorHeader, Value: []byte("oh noes")},
+								{Key: DeadLetterProcessorHeader, Value: []byte("test-processor")},
+								{Key: DeadLetterTimestampHeader, Value: []byte("2024-05-01T10:00:00Z")},
+								{Key: DeadLetterRetryCountHeader, Value: []byte("3")},
+								{Key: DeadLetterSourceTopicHeader, Value: []byte("test-topic")},
+								{Key: DeadLetterSourcePartitionHeader, Value: []byte("2")},
+								{Key: DeadLetterSourceOffsetHeader, Value: []byte("10")},
+							},
+						},
+					}, msgs)
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - writing message",
+			writer: &kafkamocks.Writer{
+				WriteMessagesFn: func(ctx context.Context, i uint64, msgs ...kafka.Message) error {
+					return errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := &deadLetterQueue{
+				writer:        tc.writer,
+				processorName: "test-processor",
+				clock:         func() time.Time { return testTime },
+			}
+
+			err := q.send(context.Background(), testMsg, deadLetterReasonProcessing, errTest, 3)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
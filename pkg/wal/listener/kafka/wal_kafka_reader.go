@@ -8,10 +8,16 @@ import (
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"time"
 
+	"github.com/xataio/pgstream/internal/backoff"
 	"github.com/xataio/pgstream/internal/kafka"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/claimcheck"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Reader is a kafka reader that listens to wal events.
@@ -23,14 +29,57 @@ type Reader struct {
 
 	// processRecord is called for a new record.
 	processRecord payloadProcessor
+	// backoffProvider is used to retry the processing of a record when it
+	// fails, before it's dead lettered or dropped.
+	backoffProvider backoff.Provider
+	// deadLetter is used to keep the records that can't be processed. If not
+	// configured, unmarshaling errors stop the reader, and processing errors
+	// are logged and the record is dropped.
+	deadLetter    *deadLetterQueue
+	processorName string
+	metrics       *readerMetrics
+
+	// parallel enables the per partition processing of the records. If not
+	// configured, records are processed sequentially.
+	parallel *ParallelConfig
+	// offsetTracker keeps track of the records being processed concurrently,
+	// so that only contiguous processed offsets are committed.
+	offsetTracker *kafka.OffsetTracker
+
+	// claimCheck is used to retrieve the payload of the records that were too
+	// large to be sent inline.
+	claimCheck *claimcheck.ClaimCheck
 }
 
 type ReaderConfig struct {
 	Kafka kafka.ReaderConfig
+	// RetryBackoff is the retry policy applied when the processing of a record
+	// fails. Defaults to no retries.
+	RetryBackoff backoff.Config
+	// DeadLetter configures the dead letter topic for records that can't be
+	// unmarshaled or processed. Disabled by default.
+	DeadLetter *DeadLetterConfig
+	// Parallel enables the processing of each partition in its own worker
+	// goroutine, preserving the order within the partition. Disabled by
+	// default.
+	Parallel *ParallelConfig
+	// ClaimCheck configures the blob store used to retrieve the payload of the
+	// records sent as a claim check reference. Required if the writer has the
+	// claim check enabled.
+	ClaimCheck *claimcheck.Config
+}
+
+var errClaimCheckNotConfigured = errors.New("claim check store not configured")
+
+type readerMetrics struct {
+	deadLetterMessages metric.Int64Counter
+	deadLetterBytes    metric.Int64Counter
+	processingRetries  metric.Int64Counter
 }
 
 type kafkaReader interface {
 	FetchMessage(context.Context) (*kafka.Message, error)
+	PartitionsRevoked() <-chan struct{}
 	Close() error
 }
 
@@ -42,10 +91,12 @@ type Option func(*Reader)
 // processor on input.
 func NewReader(config ReaderConfig, processRecord payloadProcessor, opts ...Option) (*Reader, error) {
 	r := &Reader{
-		logger:        loglib.NewNoopLogger(),
-		processRecord: processRecord,
-		unmarshaler:   json.Unmarshal,
-		offsetParser:  kafka.NewOffsetParser(),
+		logger:          loglib.NewNoopLogger(),
+		processRecord:   processRecord,
+		unmarshaler:     json.Unmarshal,
+		offsetParser:    kafka.NewOffsetParser(),
+		backoffProvider: backoff.NewProvider(&config.RetryBackoff),
+		parallel:        config.Parallel,
 	}
 
 	for _, opt := range opts {
@@ -53,6 +104,20 @@ func NewReader(config ReaderConfig, processRecord payloadProcessor, opts ...Opti
 	}
 
 	var err error
+	if config.ClaimCheck != nil {
+		r.claimCheck, err = claimcheck.New(config.ClaimCheck)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.DeadLetter != nil {
+		r.deadLetter, err = newDeadLetterQueue(config.DeadLetter, r.processorName, r.logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	r.reader, err = kafka.NewReader(config.Kafka, r.logger)
 	if err != nil {
 		return nil, err
@@ -69,54 +134,214 @@ func WithLogger(logger loglib.Logger) Option {
 	}
 }
 
+// WithProcessorName sets the name of the processor the records are sent to,
+// which is added to the dead lettered records metadata.
+func WithProcessorName(name string) Option {
+	return func(r *Reader) {
+		r.processorName = name
+	}
+}
+
+// WithOffsetTracker sets the tracker used to report the fetched and processed
+// offsets when the records are processed in parallel. The same tracker must be
+// used by the checkpointer.
+func WithOffsetTracker(t *kafka.OffsetTracker) Option {
+	return func(r *Reader) {
+		r.offsetTracker = t
+	}
+}
+
+func WithInstrumentation(meter metric.Meter) Option {
+	return func(r *Reader) {
+		metrics, err := newReaderMetrics(meter)
+		if err != nil {
+			r.logger.Error(err, "initialising kafka reader instrumentation")
+			return
+		}
+		r.metrics = metrics
+	}
+}
+
 func (r *Reader) Listen(ctx context.Context) error {
+	if r.parallel != nil {
+		return r.listenParallel(ctx)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			msg, err := r.reader.FetchMessage(ctx)
+			msg, offset, err := r.fetchMessage(ctx)
 			if err != nil {
-				return fmt.Errorf("reading from kafka: %w", err)
+				return err
 			}
 
-			r.logger.Trace("received", loglib.Fields{
-				"topic":     msg.Topic,
-				"partition": msg.Partition,
-				"offset":    msg.Offset,
-				"key":       msg.Key,
-				"wal_data":  msg.Value,
-			})
-
-			offset := &kafka.Offset{
-				Topic:     msg.Topic,
-				Partition: msg.Partition,
-				Offset:    msg.Offset,
+			if err := r.handleMessage(ctx, msg, offset); err != nil {
+				return err
 			}
+		}
+	}
+}
 
-			event := &wal.Event{
-				CommitPosition: wal.CommitPosition(r.offsetParser.ToString(offset)),
-			}
-			event.Data = &wal.Data{}
-			if err := r.unmarshaler(msg.Value, event.Data); err != nil {
-				return fmt.Errorf("error unmarshaling message value into wal data: %w", err)
-			}
+func (r *Reader) fetchMessage(ctx context.Context) (*kafka.Message, *kafka.Offset, error) {
+	msg, err := r.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading from kafka: %w", err)
+	}
 
-			if err = r.processRecord(ctx, event); err != nil {
-				if errors.Is(err, context.Canceled) {
-					return fmt.Errorf("canceled: %w", err)
-				}
+	r.logger.Trace("received", loglib.Fields{
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+		"key":       msg.Key,
+		"wal_data":  msg.Value,
+	})
 
-				r.logger.Error(err, "processing kafka msg", loglib.Fields{
-					"severity": "DATALOSS",
-					"wal_data": msg.Value,
-				})
-			}
+	offset := &kafka.Offset{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}
+
+	return msg, offset, nil
+}
+
+func (r *Reader) handleMessage(ctx context.Context, msg *kafka.Message, offset *kafka.Offset) error {
+	event := &wal.Event{
+		CommitPosition: wal.CommitPosition(r.offsetParser.ToString(offset)),
+	}
+	value, err := r.messageValue(ctx, msg)
+	if err != nil {
+		err = fmt.Errorf("error retrieving claim check payload: %w", err)
+		// don't dead letter the record if the retrieval was interrupted because
+		// the context was canceled
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+		if r.deadLetter == nil {
+			return err
+		}
+		return r.sendToDeadLetter(ctx, msg, deadLetterReasonClaimCheck, err, 0)
+	}
+
+	event.Data = &wal.Data{}
+	if err := r.unmarshaler(value, event.Data); err != nil {
+		err = fmt.Errorf("error unmarshaling message value into wal data: %w", err)
+		if r.deadLetter == nil {
+			return err
+		}
+		return r.sendToDeadLetter(ctx, msg, deadLetterReasonUnmarshal, err, 0)
+	}
+
+	retries, err := r.processRecordWithRetry(ctx, event)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("canceled: %w", err)
 		}
+
+		if r.deadLetter != nil {
+			return r.sendToDeadLetter(ctx, msg, deadLetterReasonProcessing, err, retries)
+		}
+
+		r.logger.Error(err, "processing kafka msg", loglib.Fields{
+			"severity": "DATALOSS",
+			"wal_data": msg.Value,
+		})
 	}
+
+	return nil
+}
+
+// messageValue returns the value of the message on input, retrieving it from
+// the claim check store if the message is a reference to it.
+func (r *Reader) messageValue(ctx context.Context, msg *kafka.Message) ([]byte, error) {
+	if !isClaimCheck(msg) {
+		return msg.Value, nil
+	}
+	if r.claimCheck == nil {
+		return nil, errClaimCheckNotConfigured
+	}
+	return r.claimCheck.Retrieve(ctx, msg.Value)
+}
+
+func isClaimCheck(msg *kafka.Message) bool {
+	for _, h := range msg.Headers {
+		if h.Key == claimcheck.Header {
+			return true
+		}
+	}
+	return false
+}
+
+// processRecordWithRetry processes the event on input, retrying on failure as
+// per the configured retry policy. It returns the number of retries performed.
+func (r *Reader) processRecordWithRetry(ctx context.Context, event *wal.Event) (uint, error) {
+	var retries uint
+	bo := r.backoffProvider(ctx)
+	err := bo.RetryNotify(
+		func() error {
+			err := r.processRecord(ctx, event)
+			if errors.Is(err, context.Canceled) {
+				return fmt.Errorf("%w: %w", backoff.ErrPermanent, err)
+			}
+			return err
+		},
+		func(err error, d time.Duration) {
+			retries++
+			r.recordProcessingRetry(ctx)
+			r.logger.Warn(err, fmt.Sprintf("kafka reader: failed to process record, retrying in %v", d))
+		})
+	// make sure the record is not dead lettered if the retries were
+	// interrupted because the context was canceled
+	if err != nil && ctx.Err() != nil {
+		return retries, fmt.Errorf("%w: %w", err, ctx.Err())
+	}
+	return retries, err
+}
+
+// sendToDeadLetter writes the message to the dead letter topic. If the write
+// fails, the error is returned so that the record is not lost.
+func (r *Reader) sendToDeadLetter(ctx context.Context, msg *kafka.Message, reason deadLetterReason, cause error, retries uint) error {
+	r.logger.Warn(cause, "sending kafka msg to dead letter topic", loglib.Fields{
+		"reason":    reason,
+		"retries":   retries,
+		"topic":     msg.Topic,
+		"partition": msg.Partition,
+		"offset":    msg.Offset,
+	})
+
+	if err := r.deadLetter.send(ctx, msg, reason, cause, retries); err != nil {
+		return fmt.Errorf("kafka reader: %w", err)
+	}
+
+	r.recordDeadLetter(ctx, msg, reason)
+	return nil
+}
+
+func (r *Reader) recordDeadLetter(ctx context.Context, msg *kafka.Message, reason deadLetterReason) {
+	if r.metrics == nil {
+		return
+	}
+	attrs := metric.WithAttributes(attribute.String("reason", string(reason)))
+	r.metrics.deadLetterMessages.Add(ctx, 1, attrs)
+	r.metrics.deadLetterBytes.Add(ctx, int64(len(msg.Value)), attrs)
+}
+
+func (r *Reader) recordProcessingRetry(ctx context.Context) {
+	if r.metrics == nil {
+		return
+	}
+	r.metrics.processingRetries.Add(ctx, 1)
 }
 
 func (r *Reader) Close() error {
+	if r.deadLetter != nil {
+		if err := r.deadLetter.close(); err != nil {
+			r.logger.Error(err, "error closing dead letter writer")
+		}
+	}
+
 	// Cleanly closing the connection to Kafka is important
 	// in order for the consumer's partitions to be re-allocated
 	// quickly.
@@ -128,3 +353,30 @@ func (r *Reader) Close() error {
 	}
 	return nil
 }
+
+func newReaderMetrics(meter metric.Meter) (*readerMetrics, error) {
+	metrics := &readerMetrics{}
+	var err error
+	metrics.deadLetterMessages, err = meter.Int64Counter("pgstream.kafka.reader.dead_letter.messages",
+		metric.WithUnit("messages"),
+		metric.WithDescription("Number of messages sent to the dead letter topic by the kafka reader"))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.deadLetterBytes, err = meter.Int64Counter("pgstream.kafka.reader.dead_letter.bytes",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("Volume of message bytes sent to the dead letter topic by the kafka reader"))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.processingRetries, err = meter.Int64Counter("pgstream.kafka.reader.processing.retries",
+		metric.WithUnit("retries"),
+		metric.WithDescription("Number of retries performed by the kafka reader when processing a message fails"))
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
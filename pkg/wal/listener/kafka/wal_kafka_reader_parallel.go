@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/xataio/pgstream/internal/kafka"
+	synclib "github.com/xataio/pgstream/internal/sync"
+	loglib "github.com/xataio/pgstream/pkg/log"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type ParallelConfig struct {
+	// MaxInFlightMessages is the max number of fetched messages that have not
+	// been processed yet, across all partitions. Defaults to 1000.
+	MaxInFlightMessages int
+}
+
+type partitionMsg struct {
+	msg    *kafka.Message
+	offset *kafka.Offset
+}
+
+const defaultMaxInFlightMessages = 1000
+
+func (c *ParallelConfig) maxInFlightMessages() int {
+	if c.MaxInFlightMessages > 0 {
+		return c.MaxInFlightMessages
+	}
+	return defaultMaxInFlightMessages
+}
+
+// partitionWorkers are the workers of the partitions assigned to the reader in
+// a consumer group generation.
+type partitionWorkers struct {
+	workers map[string]chan *partitionMsg
+	// revoked is closed once the partitions have been revoked, so that the
+	// workers drop their pending messages
+	revoked chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newPartitionWorkers() *partitionWorkers {
+	return &partitionWorkers{
+		workers: map[string]chan *partitionMsg{},
+		revoked: make(chan struct{}),
+	}
+}
+
+// stop stops the workers, and waits for them to finish the message they're
+// processing, if any. Their pending messages are dropped if the partitions
+// have been revoked.
+func (pw *partitionWorkers) stop(revoked bool) {
+	if revoked {
+		close(pw.revoked)
+	}
+	for _, worker := range pw.workers {
+		close(worker)
+	}
+	pw.wg.Wait()
+}
+
+// listenParallel fetches the messages and dispatches them to a worker
+// goroutine per topic partition, which processes them in order. The number of
+// messages in flight is bounded, so the fetching blocks when the limit is
+// reached until the workers catch up. When the partitions are revoked by a
+// consumer group rebalance, the workers are stopped, and their pending
+// messages dropped, since they'll be consumed again by the reader the
+// partitions are assigned to.
+func (r *Reader) listenParallel(ctx context.Context) error {
+	maxInFlight := r.parallel.maxInFlightMessages()
+	inFlightSema := synclib.NewWeightedSemaphore(int64(maxInFlight))
+
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		workers := newPartitionWorkers()
+		defer func() {
+			workers.stop(false)
+		}()
+
+		for {
+			if err := inFlightSema.Acquire(ctx, 1); err != nil {
+				return err
+			}
+
+			msg, offset, err := r.fetchMessage(ctx)
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-r.reader.PartitionsRevoked():
+				if len(workers.workers) > 0 {
+					r.logger.Info("kafka partitions revoked, stopping partition workers", loglib.Fields{
+						"partition_workers": len(workers.workers),
+					})
+				}
+				workers.stop(true)
+				// the offsets of the revoked partitions can't be committed
+				// anymore
+				if r.offsetTracker != nil {
+					r.offsetTracker.Reset()
+				}
+				workers = newPartitionWorkers()
+			default:
+			}
+
+			if r.offsetTracker != nil {
+				r.offsetTracker.Track(offset)
+			}
+
+			topicPartition := fmt.Sprintf("%s-%d", offset.Topic, offset.Partition)
+			worker, found := workers.workers[topicPartition]
+			if !found {
+				r.logger.Debug("starting kafka partition worker", loglib.Fields{
+					"topic":     offset.Topic,
+					"partition": offset.Partition,
+				})
+				// the channel capacity matches the in flight limit, so that
+				// dispatching to a busy partition never blocks the others
+				worker = make(chan *partitionMsg, maxInFlight)
+				workers.workers[topicPartition] = worker
+				workers.wg.Add(1)
+				revoked := workers.revoked
+				eg.Go(func() error {
+					defer workers.wg.Done()
+					return r.partitionWorker(ctx, worker, revoked, inFlightSema)
+				})
+			}
+
+			worker <- &partitionMsg{msg: msg, offset: offset}
+		}
+	})
+
+	return eg.Wait()
+}
+
+func (r *Reader) partitionWorker(ctx context.Context, msgChan <-chan *partitionMsg, revoked <-chan struct{}, inFlightSema synclib.WeightedSemaphore) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case pmsg, ok := <-msgChan:
+			if !ok {
+				return nil
+			}
+
+			select {
+			case <-revoked:
+				inFlightSema.Release(1)
+				continue
+			default:
+			}
+
+			err := r.handleMessage(ctx, pmsg.msg, pmsg.offset)
+			inFlightSema.Release(1)
+			if err != nil {
+				return err
+			}
+
+			if r.offsetTracker != nil {
+				r.offsetTracker.MarkProcessed(pmsg.offset)
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/backoff"
+	"github.com/xataio/pgstream/internal/kafka"
+	kafkamocks "github.com/xataio/pgstream/internal/kafka/mocks"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestReader_listenParallel(t *testing.T) {
+	t.Parallel()
+
+	const (
+		partitions       = 3
+		msgsPerPartition = 20
+	)
+
+	testMsgs := []*kafka.Message{}
+	for i := 0; i < msgsPerPartition; i++ {
+		for p := 0; p < partitions; p++ {
+			testMsgs = append(testMsgs, &kafka.Message{
+				Topic:     "test-topic",
+				Partition: p,
+				Offset:    int64(i),
+				Value:     []byte(fmt.Sprintf(`{"action":"I","schema":"test_schema","table":"table_%d"}`, p)),
+			})
+		}
+	}
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name          string
+		processRecord func(processed map[string][]wal.CommitPosition, mutex *sync.Mutex) payloadProcessor
+
+		wantErr            error
+		wantAllProcessed   bool
+		wantCommittableLen int
+	}{
+		{
+			name: "ok",
+			processRecord: func(processed map[string][]wal.CommitPosition, mutex *sync.Mutex) payloadProcessor {
+				return func(ctx context.Context, e *wal.Event) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+					processed[e.Data.Table] = append(processed[e.Data.Table], e.CommitPosition)
+					return nil
+				}
+			},
+
+			wantErr:            context.Canceled,
+			wantAllProcessed:   true,
+			wantCommittableLen: partitions,
+		},
+		{
+			name: "error - processing message context canceled",
+			processRecord: func(processed map[string][]wal.CommitPosition, mutex *sync.Mutex) payloadProcessor {
+				return func(ctx context.Context, e *wal.Event) error {
+					return fmt.Errorf("%w: %w", context.Canceled, errTest)
+				}
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			processed := map[string][]wal.CommitPosition{}
+			mutex := &sync.Mutex{}
+
+			fetchMutex := &sync.Mutex{}
+			next := 0
+			tracker := kafka.NewOffsetTracker()
+			r := &Reader{
+				logger: loglib.NewNoopLogger(),
+				reader: &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						fetchMutex.Lock()
+						defer fetchMutex.Unlock()
+						if next >= len(testMsgs) {
+							<-ctx.Done()
+							return nil, ctx.Err()
+						}
+						msg := testMsgs[next]
+						next++
+						return msg, nil
+					},
+					PartitionsRevokedFn: func() <-chan struct{} { return nil },
+				},
+				processRecord: tc.processRecord(processed, mutex),
+				unmarshaler:   json.Unmarshal,
+				offsetParser:  kafka.NewOffsetParser(),
+				backoffProvider: func(ctx context.Context) backoff.Backoff {
+					return backoff.NewStopBackoff()
+				},
+				parallel: &ParallelConfig{
+					MaxInFlightMessages: 5,
+				},
+				offsetTracker: tracker,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- r.Listen(ctx)
+			}()
+
+			if tc.wantAllProcessed {
+				require.Eventually(t, func() bool {
+					mutex.Lock()
+					defer mutex.Unlock()
+					total := 0
+					for _, positions := range processed {
+						total += len(positions)
+					}
+					return total == len(testMsgs)
+				}, 5*time.Second, 10*time.Millisecond)
+				cancel()
+			}
+
+			err := <-errChan
+			require.ErrorIs(t, err, tc.wantErr)
+
+			if !tc.wantAllProcessed {
+				return
+			}
+
+			// the order within each partition is preserved
+			parser := kafka.NewOffsetParser()
+			checkpointed := []*kafka.Offset{}
+			for p := 0; p < partitions; p++ {
+				positions := processed[fmt.Sprintf("table_%d", p)]
+				require.Len(t, positions, msgsPerPartition)
+				for i, pos := range positions {
+					offset, err := parser.FromString(string(pos))
+					require.NoError(t, err)
+					require.Equal(t, p, offset.Partition)
+					require.Equal(t, int64(i), offset.Offset)
+					checkpointed = append(checkpointed, offset)
+				}
+			}
+
+			committable := tracker.Committable(checkpointed)
+			require.Len(t, committable, tc.wantCommittableLen)
+			for _, o := range committable {
+				require.Equal(t, int64(msgsPerPartition-1), o.Offset)
+			}
+		})
+	}
+}
+
+func TestReader_listenParallel_partitionsRevoked(t *testing.T) {
+	t.Parallel()
+
+	testMsg := func(partition int, offset int64) *kafka.Message {
+		return &kafka.Message{
+			Topic:     "test-topic",
+			Partition: partition,
+			Offset:    offset,
+			Value:     []byte(fmt.Sprintf(`{"action":"I","schema":"test_schema","table":"table_%d"}`, partition)),
+		}
+	}
+
+	revoked := make(chan struct{}, 1)
+	// partition 0 is assigned again after the rebalance, and consumed from its
+	// last committed offset, while partition 1 is assigned to another reader
+	testMsgs := []*kafka.Message{testMsg(0, 0), testMsg(1, 0), testMsg(0, 0), testMsg(0, 1)}
+	next := 0
+	var processed atomic.Int32
+	tracker := kafka.NewOffsetTracker()
+	parser := kafka.NewOffsetParser()
+
+	r := &Reader{
+		logger: loglib.NewNoopLogger(),
+		reader: &kafkamocks.Reader{
+			FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+				if next >= len(testMsgs) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				if next == 2 {
+					// wait for the messages of the first generation to be
+					// processed before the rebalance
+					require.Eventually(t, func() bool { return processed.Load() == 2 }, 5*time.Second, time.Millisecond)
+					revoked <- struct{}{}
+				}
+				msg := testMsgs[next]
+				next++
+				return msg, nil
+			},
+			PartitionsRevokedFn: func() <-chan struct{} { return revoked },
+		},
+		processRecord: func(ctx context.Context, e *wal.Event) error {
+			processed.Add(1)
+			return nil
+		},
+		unmarshaler:  json.Unmarshal,
+		offsetParser: parser,
+		backoffProvider: func(ctx context.Context) backoff.Backoff {
+			return backoff.NewStopBackoff()
+		},
+		parallel:      &ParallelConfig{MaxInFlightMessages: 5},
+		offsetTracker: tracker,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- r.Listen(ctx)
+	}()
+
+	require.Eventually(t, func() bool { return processed.Load() == int32(len(testMsgs)) }, 5*time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-errChan, context.Canceled)
+
+	// the offsets of the revoked partition are not committed, and the ones of
+	// the reassigned partition only from the new generation
+	committable := tracker.Committable([]*kafka.Offset{
+		{Topic: "test-topic", Partition: 0, Offset: 1},
+		{Topic: "test-topic", Partition: 1, Offset: 0},
+	})
+	require.Equal(t, []*kafka.Offset{{Topic: "test-topic", Partition: 0, Offset: 1}}, committable)
+}
@@ -4,6 +4,8 @@ package kafka
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"sync"
@@ -11,10 +13,14 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/backoff"
+	"github.com/xataio/pgstream/internal/blobstore"
+	blobstoremocks "github.com/xataio/pgstream/internal/blobstore/mocks"
 	"github.com/xataio/pgstream/internal/kafka"
 	kafkamocks "github.com/xataio/pgstream/internal/kafka/mocks"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/claimcheck"
 )
 
 func TestReader_Listen(t *testing.T) {
@@ -38,7 +44,19 @@ func TestReader_Listen(t *testing.T) {
 		CommitPosition: wal.CommitPosition(testOffsetStr),
 	}
 
+	testValueChecksum := sha256.Sum256(testMessage.Value)
+	testClaimCheckMessage := &kafka.Message{
+		Topic:     "test-topic",
+		Partition: 0,
+		Offset:    1,
+		Key:       []byte("test-key"),
+		Value: []byte(fmt.Sprintf(`{"key":"test_schema/test_table/1.json","size":%d,"sha256":"%s"}`,
+			len(testMessage.Value), hex.EncodeToString(testValueChecksum[:]))),
+		Headers: []kafka.Header{{Key: claimcheck.Header, Value: []byte("true")}},
+	}
+
 	errTest := errors.New("oh noes")
+	errDeadLetter := errors.New("dead letter error")
 
 	testUnmarshaler := func(b []byte, a any) error {
 		require.Equal(t, []byte("test-value"), b)
@@ -53,6 +71,9 @@ func TestReader_Listen(t *testing.T) {
 		reader        func(doneChan chan struct{}) *kafkamocks.Reader
 		processRecord payloadProcessor
 		unmarshaler   func(b []byte, a any) error
+		deadLetter    *kafkamocks.Writer
+		backoff       backoff.Provider
+		claimCheck    *blobstoremocks.Store
 
 		wantErr error
 	}{
@@ -143,6 +164,150 @@ func TestReader_Listen(t *testing.T) {
 
 			wantErr: errTest,
 		},
+		{
+			name: "ok - unmarshaling error sent to dead letter",
+			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
+				return &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						return testMessage, nil
+					},
+				}
+			},
+			processRecord: func(ctx context.Context, d *wal.Event) error {
+				return errors.New("processRecord: should not be called")
+			},
+			unmarshaler: func(b []byte, a any) error { return errTest },
+			deadLetter: &kafkamocks.Writer{
+				WriteMessagesFn: func(ctx context.Context, i uint64, msgs ...kafka.Message) error {
+					require.Len(t, msgs, 1)
+					require.Equal(t, testMessage.Value, msgs[0].Value)
+					require.Equal(t, "unmarshal", headerValue(msgs[0], DeadLetterReasonHeader))
+					require.Equal(t, "0", headerValue(msgs[0], DeadLetterRetryCountHeader))
+					return nil
+				},
+			},
+
+			wantErr: context.Canceled,
+		},
+		{
+			name: "ok - processing error retried and sent to dead letter",
+			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
+				return &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						return testMessage, nil
+					},
+				}
+			},
+			processRecord: func(ctx context.Context, d *wal.Event) error {
+				return errTest
+			},
+			backoff: func(ctx context.Context) backoff.Backoff {
+				return backoff.NewConstantBackoff(ctx, &backoff.ConstantConfig{
+					Interval:   time.Millisecond,
+					MaxRetries: 2,
+				})
+			},
+			deadLetter: &kafkamocks.Writer{
+				WriteMessagesFn: func(ctx context.Context, i uint64, msgs ...kafka.Message) error {
+					require.Len(t, msgs, 1)
+					require.Equal(t, "processing", headerValue(msgs[0], DeadLetterReasonHeader))
+					require.Equal(t, "2", headerValue(msgs[0], DeadLetterRetryCountHeader))
+					return nil
+				},
+			},
+
+			wantErr: context.Canceled,
+		},
+		{
+			name: "error - writing to dead letter",
+			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
+				return &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						return testMessage, nil
+					},
+				}
+			},
+			processRecord: func(ctx context.Context, d *wal.Event) error {
+				return errTest
+			},
+			deadLetter: &kafkamocks.Writer{
+				WriteMessagesFn: func(ctx context.Context, i uint64, msgs ...kafka.Message) error {
+					return errDeadLetter
+				},
+			},
+
+			wantErr: errDeadLetter,
+		},
+		{
+			name: "ok - claim check payload retrieved",
+			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
+				var once sync.Once
+				return &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						defer once.Do(func() { doneChan <- struct{}{} })
+						return testClaimCheckMessage, nil
+					},
+				}
+			},
+			processRecord: func(ctx context.Context, d *wal.Event) error {
+				require.Equal(t, &testWalEvent, d)
+				return nil
+			},
+			claimCheck: &blobstoremocks.Store{
+				GetFn: func(ctx context.Context, key string) ([]byte, error) {
+					require.Equal(t, "test_schema/test_table/1.json", key)
+					return testMessage.Value, nil
+				},
+			},
+
+			wantErr: context.Canceled,
+		},
+		{
+			name: "error - claim check not configured",
+			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
+				var once sync.Once
+				return &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						defer once.Do(func() { doneChan <- struct{}{} })
+						return testClaimCheckMessage, nil
+					},
+				}
+			},
+			processRecord: func(ctx context.Context, d *wal.Event) error {
+				return errors.New("processRecord: should not be called")
+			},
+
+			wantErr: errClaimCheckNotConfigured,
+		},
+		{
+			name: "ok - claim check retrieval error sent to dead letter",
+			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
+				return &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						return testClaimCheckMessage, nil
+					},
+				}
+			},
+			processRecord: func(ctx context.Context, d *wal.Event) error {
+				return errors.New("processRecord: should not be called")
+			},
+			claimCheck: &blobstoremocks.Store{
+				GetFn: func(ctx context.Context, key string) ([]byte, error) {
+					return nil, blobstore.ErrNotFound
+				},
+			},
+			deadLetter: &kafkamocks.Writer{
+				WriteMessagesFn: func(ctx context.Context, i uint64, msgs ...kafka.Message) error {
+					require.Len(t, msgs, 1)
+					require.Equal(t, testClaimCheckMessage.Value, msgs[0].Value)
+					require.Equal(t, "true", headerValue(msgs[0], claimcheck.Header))
+					require.Equal(t, "claim_check", headerValue(msgs[0], DeadLetterReasonHeader))
+					return nil
+				},
+			},
+
+			wantErr: context.Canceled,
+		},
 	}
 
 	for _, tc := range tests {
@@ -158,6 +323,9 @@ func TestReader_Listen(t *testing.T) {
 				reader:        tc.reader(doneChan),
 				processRecord: tc.processRecord,
 				unmarshaler:   testUnmarshaler,
+				backoffProvider: func(ctx context.Context) backoff.Backoff {
+					return backoff.NewStopBackoff()
+				},
 				offsetParser: &kafkamocks.OffsetParser{
 					ToStringFn: func(o *kafka.Offset) string { return testOffsetStr },
 				},
@@ -167,6 +335,28 @@ func TestReader_Listen(t *testing.T) {
 				r.unmarshaler = tc.unmarshaler
 			}
 
+			if tc.backoff != nil {
+				r.backoffProvider = tc.backoff
+			}
+
+			if tc.claimCheck != nil {
+				r.claimCheck = claimcheck.NewWithStore(tc.claimCheck, "")
+			}
+
+			doneOnce := sync.Once{}
+			if tc.deadLetter != nil {
+				writeFn := tc.deadLetter.WriteMessagesFn
+				tc.deadLetter.WriteMessagesFn = func(ctx context.Context, i uint64, msgs ...kafka.Message) error {
+					defer doneOnce.Do(func() { doneChan <- struct{}{} })
+					return writeFn(ctx, i, msgs...)
+				}
+				r.deadLetter = &deadLetterQueue{
+					writer:        tc.deadLetter,
+					processorName: "test-processor",
+					clock:         time.Now,
+				}
+			}
+
 			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 			defer cancel()
 
@@ -191,3 +381,12 @@ func TestReader_Listen(t *testing.T) {
 		})
 	}
 }
+
+func headerValue(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/xataio/pgstream/internal/kafka"
+	"github.com/xataio/pgstream/pkg/wal/claimcheck"
 )
 
 type Config struct {
@@ -23,6 +24,26 @@ type Config struct {
 	// MaxQueueBytes is the max memory used by the batch writer for inflight
 	// batches. Defaults to 100MiB
 	MaxQueueBytes int64
+	// Transaction enables the transactional mode of the writer, where each
+	// batch is written and the kafka offsets of the events it contains are
+	// committed within a single kafka transaction. It requires the events to
+	// be consumed from kafka. Batches are not duplicated on restarts or write
+	// failures, but they can be when the consumer group rebalances, since the
+	// offsets are committed without the group generation. Disabled by default.
+	Transaction *TransactionConfig
+	// ClaimCheck enables storing the events larger than the batch bytes in a
+	// blob store, sending a reference to them instead. If not configured,
+	// those events are skipped.
+	ClaimCheck *claimcheck.Config
+}
+
+type TransactionConfig struct {
+	// ID is the kafka transactional id for the writer. It must be stable
+	// across restarts and unique per pgstream instance.
+	ID string
+	// ConsumerGroupID is the consumer group of the kafka listener the events
+	// are consumed by. Its offsets are committed as part of the transaction.
+	ConsumerGroupID string
 }
 
 const (
@@ -16,6 +16,7 @@ import (
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/claimcheck"
 	"github.com/xataio/pgstream/pkg/wal/processor"
 	"go.opentelemetry.io/otel/metric"
 )
@@ -26,6 +27,12 @@ type BatchWriter struct {
 	writer kafka.MessageWriter
 	logger loglib.Logger
 
+	// txWriter is used instead of the writer when the transactional mode is
+	// enabled. The commit positions of the batch are committed as part of the
+	// same transaction, so the checkpointer is not used.
+	txWriter     kafka.TransactionalMessageWriter
+	offsetParser kafka.OffsetParser
+
 	// queueBytesSema is used to limit the amount of memory used by the
 	// unbuffered msg channel, optimising the channel performance for variable
 	// size messages, while preventing the process from running oom
@@ -40,6 +47,11 @@ type BatchWriter struct {
 	checkpointer checkpointer.Checkpoint
 
 	serialiser func(any) ([]byte, error)
+
+	// claimCheck is used to store the events that are larger than the max
+	// batch bytes, sending a reference to them instead. If not configured,
+	// those events are skipped.
+	claimCheck *claimcheck.ClaimCheck
 }
 
 type Option func(*BatchWriter)
@@ -66,6 +78,29 @@ func NewBatchWriter(config *Config, opts ...Option) (*BatchWriter, error) {
 		opt(w)
 	}
 
+	if config.ClaimCheck != nil {
+		w.claimCheck, err = claimcheck.New(config.ClaimCheck)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the transactional writer commits the batch positions along with the
+	// messages, and is used instead of the kafka-go writer
+	if config.Transaction != nil {
+		w.offsetParser = kafka.NewOffsetParser()
+		w.txWriter, err = kafka.NewTransactionalWriter(kafka.TransactionalWriterConfig{
+			Conn:            config.Kafka,
+			TransactionalID: config.Transaction.ID,
+			ConsumerGroupID: config.Transaction.ConsumerGroupID,
+			BatchBytes:      config.batchBytes(),
+		}, w.logger)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	}
+
 	// Since the batch kafka writer handles the batching, we don't want to have
 	// a timeout configured in the underlying kafka-go writer or the latency for
 	// the send will increase unnecessarily. Instead, we set the kafka-go writer
@@ -138,22 +173,38 @@ func (w *BatchWriter) ProcessWALEvent(ctx context.Context, walEvent *wal.Event)
 		if err != nil {
 			return fmt.Errorf("marshalling event: %w", err)
 		}
+		var headers []kafka.Header
 		// check if walEventBytes is larger than the Kafka accepted max message size
 		if len(walDataBytes) > int(w.maxBatchBytes) {
-			w.logger.Warn(errRecordTooLarge,
-				"kafka batch writer: wal event is larger than max bytes",
-				loglib.Fields{
-					"max_bytes": w.maxBatchBytes,
-					"size":      len(walDataBytes),
-					"table":     walEvent.Data.Table,
-					"schema":    walEvent.Data.Schema,
-				})
-			return nil
+			if w.claimCheck == nil {
+				w.logger.Warn(errRecordTooLarge,
+					"kafka batch writer: wal event is larger than max bytes",
+					loglib.Fields{
+						"max_bytes": w.maxBatchBytes,
+						"size":      len(walDataBytes),
+						"table":     walEvent.Data.Table,
+						"schema":    walEvent.Data.Schema,
+					})
+				return nil
+			}
+
+			w.logger.Debug("kafka batch writer: wal event is larger than max bytes, using claim check", loglib.Fields{
+				"max_bytes": w.maxBatchBytes,
+				"size":      len(walDataBytes),
+				"table":     walEvent.Data.Table,
+				"schema":    walEvent.Data.Schema,
+			})
+			walDataBytes, err = w.claimCheck.Store(ctx, walEvent.Data.Schema, walEvent.Data.Table, walDataBytes)
+			if err != nil {
+				return fmt.Errorf("kafka batch writer: %w", err)
+			}
+			headers = []kafka.Header{{Key: claimcheck.Header, Value: []byte("true")}}
 		}
 
 		kafkaMsg.msg = kafka.Message{
-			Key:   w.getMessageKey(walEvent.Data),
-			Value: walDataBytes,
+			Key:     w.getMessageKey(walEvent.Data),
+			Value:   walDataBytes,
+			Headers: headers,
 		}
 	}
 
@@ -234,6 +285,9 @@ func (w *BatchWriter) Name() string {
 
 func (w *BatchWriter) Close() error {
 	close(w.msgChan)
+	if w.txWriter != nil {
+		return w.txWriter.Close()
+	}
 	return w.writer.Close()
 }
 
@@ -243,6 +297,10 @@ func (w *BatchWriter) sendBatch(ctx context.Context, batch *msgBatch) error {
 		"batch_commit_positions": len(batch.positions),
 	})
 
+	if w.txWriter != nil {
+		return w.sendBatchInTransaction(ctx, batch)
+	}
+
 	if len(batch.msgs) > 0 {
 		// This call will block until it either reaches the writer configured batch
 		// size or the batch timeout. This batching feature is useful when sharing a
@@ -268,6 +326,41 @@ func (w *BatchWriter) sendBatch(ctx context.Context, batch *msgBatch) error {
 	return nil
 }
 
+// sendBatchInTransaction writes the batch messages and commits the kafka
+// offsets of its positions atomically, so that the events are not duplicated
+// if the process crashes between the write and the commit.
+func (w *BatchWriter) sendBatchInTransaction(ctx context.Context, batch *msgBatch) error {
+	if len(batch.msgs) == 0 && len(batch.positions) == 0 {
+		return nil
+	}
+
+	offsets, err := w.lastOffsetsByPartition(batch.positions)
+	if err != nil {
+		return fmt.Errorf("kafka batch writer: parsing commit positions: %w", err)
+	}
+
+	if err := w.txWriter.WriteMessagesWithOffsets(ctx, offsets, batch.msgs...); err != nil {
+		w.logger.Error(err, "failed to write to kafka in transaction")
+		return fmt.Errorf("kafka batch writer: writing to kafka in transaction: %w", err)
+	}
+
+	return nil
+}
+
+// lastOffsetsByPartition returns the highest kafka offset per topic partition
+// for the commit positions on input.
+func (w *BatchWriter) lastOffsetsByPartition(positions []wal.CommitPosition) ([]*kafka.Offset, error) {
+	offsets := make([]*kafka.Offset, 0, len(positions))
+	for _, pos := range positions {
+		offset, err := w.offsetParser.FromString(string(pos))
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, offset)
+	}
+	return kafka.LastOffsetsByPartition(offsets), nil
+}
+
 // getMessageKey returns the key to be used in a kafka message for the wal event
 // on input. The message key determines which partition the event is routed to,
 // and therefore which order the events will be executed in. For schema logs,
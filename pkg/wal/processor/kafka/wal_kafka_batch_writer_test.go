@@ -4,6 +4,7 @@ package kafka
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	blobstoremocks "github.com/xataio/pgstream/internal/blobstore/mocks"
 	"github.com/xataio/pgstream/internal/kafka"
 	kafkamocks "github.com/xataio/pgstream/internal/kafka/mocks"
 	synclib "github.com/xataio/pgstream/internal/sync"
@@ -20,6 +22,7 @@ import (
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/claimcheck"
 
 	"golang.org/x/sync/semaphore"
 )
@@ -215,6 +218,93 @@ func TestBatchKafkaWriter_ProcessWALEvent(t *testing.T) {
 	}
 }
 
+func TestBatchKafkaWriter_ProcessWALEvent_claimCheck(t *testing.T) {
+	t.Parallel()
+
+	testWalEvent := &wal.Event{
+		Data: &wal.Data{
+			Action: "I",
+			LSN:    testLSNStr,
+			Schema: testSchema,
+			Table:  testTable,
+		},
+		CommitPosition: wal.CommitPosition(testLSNStr),
+	}
+	largeBytes := []byte(strings.Repeat("a", 101))
+
+	tests := []struct {
+		name  string
+		putFn func(ctx context.Context, key string, data []byte) error
+
+		wantMsgs int
+		wantErr  error
+	}{
+		{
+			name: "ok - wal event too large, claim check reference sent",
+			putFn: func(ctx context.Context, key string, data []byte) error {
+				require.True(t, strings.HasPrefix(key, "test_schema/test_table/"))
+				require.Equal(t, largeBytes, data)
+				return nil
+			},
+
+			wantMsgs: 1,
+		},
+		{
+			name: "error - storing claim check payload",
+			putFn: func(ctx context.Context, key string, data []byte) error {
+				return errTest
+			},
+
+			wantMsgs: 0,
+			wantErr:  errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var storedKey string
+			writer := &BatchWriter{
+				logger:         loglib.NewNoopLogger(),
+				msgChan:        make(chan *msg),
+				maxBatchBytes:  100,
+				queueBytesSema: semaphore.NewWeighted(defaultMaxQueueBytes),
+				serialiser:     func(any) ([]byte, error) { return largeBytes, nil },
+				claimCheck: claimcheck.NewWithStore(&blobstoremocks.Store{
+					PutFn: func(ctx context.Context, key string, data []byte) error {
+						storedKey = key
+						return tc.putFn(ctx, key, data)
+					},
+				}, ""),
+			}
+
+			go func() {
+				defer close(writer.msgChan)
+				err := writer.ProcessWALEvent(context.Background(), testWalEvent)
+				require.ErrorIs(t, err, tc.wantErr)
+			}()
+
+			msgs := []*msg{}
+			for msg := range writer.msgChan {
+				msgs = append(msgs, msg)
+			}
+			require.Len(t, msgs, tc.wantMsgs)
+			if tc.wantMsgs == 0 {
+				return
+			}
+
+			ref := &claimcheck.Reference{}
+			require.NoError(t, json.Unmarshal(msgs[0].msg.Value, ref))
+			require.Equal(t, storedKey, ref.Key)
+			require.Equal(t, len(largeBytes), ref.Size)
+			require.Equal(t, []kafka.Header{{Key: claimcheck.Header, Value: []byte("true")}}, msgs[0].msg.Headers)
+			require.Equal(t, []byte(testSchema), msgs[0].msg.Key)
+		})
+	}
+}
+
 func TestBatchKafkaWriter_SendThread(t *testing.T) {
 	t.Parallel()
 
@@ -512,3 +602,120 @@ func TestBatchKafkaWriter_sendBatch(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchKafkaWriter_sendBatchInTransaction(t *testing.T) {
+	t.Parallel()
+
+	testBytes := []byte("test")
+	testMsgs := []kafka.Message{
+		{
+			Key:   []byte(testSchema),
+			Value: testBytes,
+		},
+	}
+	testBatch := &msgBatch{
+		msgs: testMsgs,
+		positions: []wal.CommitPosition{
+			"test-topic/0/1",
+			"test-topic/1/5",
+			"test-topic/0/3",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		txWriter *kafkamocks.TransactionalWriter
+		batch    *msgBatch
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			txWriter: &kafkamocks.TransactionalWriter{
+				WriteMessagesWithOffsetsFn: func(ctx context.Context, offsets []*kafka.Offset, msgs ...kafka.Message) error {
+					require.Equal(t, testMsgs, msgs)
+					require.Equal(t, []*kafka.Offset{
+						{Topic: "test-topic", Partition: 0, Offset: 3},
+						{Topic: "test-topic", Partition: 1, Offset: 5},
+					}, offsets)
+					return nil
+				},
+			},
+			batch: testBatch,
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - only positions",
+			txWriter: &kafkamocks.TransactionalWriter{
+				WriteMessagesWithOffsetsFn: func(ctx context.Context, offsets []*kafka.Offset, msgs ...kafka.Message) error {
+					require.Empty(t, msgs)
+					require.Equal(t, []*kafka.Offset{
+						{Topic: "test-topic", Partition: 0, Offset: 1},
+					}, offsets)
+					return nil
+				},
+			},
+			batch: &msgBatch{
+				positions: []wal.CommitPosition{"test-topic/0/1"},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - empty batch",
+			txWriter: &kafkamocks.TransactionalWriter{
+				WriteMessagesWithOffsetsFn: func(ctx context.Context, offsets []*kafka.Offset, msgs ...kafka.Message) error {
+					return errors.New("WriteMessagesWithOffsetsFn: should not be called")
+				},
+			},
+			batch: &msgBatch{},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - invalid commit position",
+			txWriter: &kafkamocks.TransactionalWriter{
+				WriteMessagesWithOffsetsFn: func(ctx context.Context, offsets []*kafka.Offset, msgs ...kafka.Message) error {
+					return errors.New("WriteMessagesWithOffsetsFn: should not be called")
+				},
+			},
+			batch: &msgBatch{
+				msgs:      testMsgs,
+				positions: []wal.CommitPosition{wal.CommitPosition(testLSNStr)},
+			},
+
+			wantErr: kafka.ErrInvalidOffsetFormat,
+		},
+		{
+			name: "error - writing messages in transaction",
+			txWriter: &kafkamocks.TransactionalWriter{
+				WriteMessagesWithOffsetsFn: func(ctx context.Context, offsets []*kafka.Offset, msgs ...kafka.Message) error {
+					return errTest
+				},
+			},
+			batch: testBatch,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			writer := &BatchWriter{
+				logger:       loglib.NewNoopLogger(),
+				txWriter:     tc.txWriter,
+				offsetParser: kafka.NewOffsetParser(),
+				checkpointer: func(_ context.Context, commitPos []wal.CommitPosition) error {
+					return errors.New("checkpoint: should not be called")
+				},
+			}
+
+			err := writer.sendBatchInTransaction(context.Background(), tc.batch)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
@@ -3,6 +3,9 @@
 package search
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/xataio/pgstream/internal/backoff"
@@ -21,12 +24,38 @@ type IndexerConfig struct {
 	// CleanupBackoff is the retry policy to follow for the async index
 	// deletion. If no config is provided, no retry policy is applied.
 	CleanupBackoff backoff.Config
+	// DeadLetterIgnored sends the documents ignored by the search store (i.e.
+	// out of order versions) to the dead letter store too, when one is
+	// configured. Defaults to false.
+	DeadLetterIgnored bool
+	// BulkConcurrency is the max number of bulk requests sent to the search
+	// store concurrently. The documents are partitioned across them by id, so
+	// that the same document is never in flight twice. Defaults to 1.
+	BulkConcurrency int
+	// SoftDelete configures the tables where deletes mark the documents as
+	// deleted instead of removing them. Disabled by default.
+	SoftDelete SoftDeleteConfig
+}
+
+type SoftDeleteConfig struct {
+	// Tables where deletes and truncates set the `_deleted` and `_deleted_at`
+	// fields of the documents instead of removing them, in the format
+	// <schema>.<table>. A `*` table matches all the tables of the schema.
+	Tables []string
+	// Retention is how long the soft deleted documents are kept before being
+	// purged. If not set, they are never purged.
+	Retention time.Duration
+	// PurgeInterval is how often the soft deleted documents older than the
+	// retention are purged. Defaults to 1h.
+	PurgeInterval time.Duration
 }
 
 const (
-	defaultMaxQueueBytes = int64(100 * 1024 * 1024) // 100MiB
-	defaultBatchSize     = 100
-	defaultBatchTime     = time.Second
+	defaultMaxQueueBytes   = int64(100 * 1024 * 1024) // 100MiB
+	defaultBatchSize       = 100
+	defaultBatchTime       = time.Second
+	defaultBulkConcurrency = 1
+	defaultPurgeInterval   = time.Hour
 )
 
 func (c *IndexerConfig) batchSize() int {
@@ -50,3 +79,31 @@ func (c *IndexerConfig) maxQueueBytes() int64 {
 
 	return defaultMaxQueueBytes
 }
+
+func (c *IndexerConfig) bulkConcurrency() int {
+	if c.BulkConcurrency > 0 {
+		return c.BulkConcurrency
+	}
+	return defaultBulkConcurrency
+}
+
+func (c *SoftDeleteConfig) purgeInterval() time.Duration {
+	if c.PurgeInterval > 0 {
+		return c.PurgeInterval
+	}
+	return defaultPurgeInterval
+}
+
+var errInvalidSoftDeleteTable = errors.New("invalid soft delete table, expected format <schema>.<table>")
+
+// IsValid returns an error if any of the soft delete tables is not in the
+// expected format.
+func (c *SoftDeleteConfig) IsValid() error {
+	for _, t := range c.Tables {
+		schema, table, found := strings.Cut(t, ".")
+		if !found || schema == "" || table == "" {
+			return fmt.Errorf("%w: %q", errInvalidSoftDeleteTable, t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// Store is a dead letter store that appends the entries to a file, one JSON
+// entry per line. It's meant for single process deployments, since the file
+// is not locked across processes.
+type Store struct {
+	path string
+	lock sync.Mutex
+}
+
+type Config struct {
+	// Path of the dead letter file. It's created if it doesn't exist.
+	Path string
+}
+
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("dead letter file path is required")
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening dead letter file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("closing dead letter file: %w", err)
+	}
+	return &Store{path: cfg.Path}, nil
+}
+
+func (s *Store) Put(ctx context.Context, entries []search.DeadLetterEntry) error {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			return fmt.Errorf("marshaling dead letter entry: %w", err)
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening dead letter file: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("writing dead letter file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("syncing dead letter file: %w", err)
+	}
+	return f.Close()
+}
+
+func (s *Store) List(ctx context.Context, afterID string, limit int) ([]search.DeadLetterEntry, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries := []search.DeadLetterEntry{}
+	err := s.readEntries(func(e search.DeadLetterEntry, _ []byte) {
+		if e.ID > afterID {
+			entries = append(entries, e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the entries written by different processes are not necessarily in ID
+	// order
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// Delete removes the entries from the file, by rewriting it without them.
+func (s *Store) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	deleted := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		deleted[id] = struct{}{}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	kept := &bytes.Buffer{}
+	err := s.readEntries(func(e search.DeadLetterEntry, line []byte) {
+		if _, found := deleted[e.ID]; !found {
+			kept.Write(line)
+			kept.WriteByte('\n')
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that the entries are not lost if the
+	// write fails
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary dead letter file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(kept.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temporary dead letter file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary dead letter file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("replacing dead letter file: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// readEntries calls fn for each of the entries in the file, in the order they
+// were written.
+func (s *Store) readEntries(fn func(e search.DeadLetterEntry, line []byte)) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("opening dead letter file: %w", err)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			entry, unmarshalErr := unmarshalEntry(line)
+			if unmarshalErr != nil {
+				return unmarshalErr
+			}
+			fn(*entry, line)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("reading dead letter file: %w", err)
+		}
+	}
+}
+
+// unmarshalEntry decodes the entry keeping the document numbers as they were
+// written, so that integers don't lose precision.
+func unmarshalEntry(line []byte) (*search.DeadLetterEntry, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	entry := &search.DeadLetterEntry{}
+	if err := decoder.Decode(entry); err != nil {
+		return nil, fmt.Errorf("unmarshaling dead letter entry: %w", err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newEntry := func(id string) search.DeadLetterEntry {
+		return search.DeadLetterEntry{
+			ID: id,
+			Document: search.Document{
+				ID:      "doc-" + id,
+				Schema:  "test_schema",
+				Version: 1,
+				Data:    map[string]any{"col-1": json.Number("9007199254740993")},
+			},
+			Severity:  "DATALOSS",
+			Error:     "oh noes",
+			LSN:       "0/1",
+			CreatedAt: now,
+		}
+	}
+
+	store, err := NewStore(Config{Path: filepath.Join(t.TempDir(), "dead_letters.jsonl")})
+	require.NoError(t, err)
+
+	entries, err := store.List(ctx, "", 10)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.NoError(t, store.Put(ctx, []search.DeadLetterEntry{newEntry("b"), newEntry("c")}))
+	// entries written out of order
+	require.NoError(t, store.Put(ctx, []search.DeadLetterEntry{newEntry("a")}))
+
+	entries, err = store.List(ctx, "", 2)
+	require.NoError(t, err)
+	require.Equal(t, []search.DeadLetterEntry{newEntry("a"), newEntry("b")}, entries)
+
+	entries, err = store.List(ctx, "b", 2)
+	require.NoError(t, err)
+	require.Equal(t, []search.DeadLetterEntry{newEntry("c")}, entries)
+
+	require.NoError(t, store.Delete(ctx, []string{"a", "c"}))
+
+	entries, err = store.List(ctx, "", 10)
+	require.NoError(t, err)
+	require.Equal(t, []search.DeadLetterEntry{newEntry("b")}, entries)
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// Store is a dead letter store that keeps the entries in a postgres table.
+type Store struct {
+	conn pglib.Querier
+}
+
+type Config struct {
+	// URL of the postgres database where the dead letter table is created.
+	URL string
+}
+
+const deadLettersTable = "search_dead_letters"
+
+func NewStore(ctx context.Context, cfg Config) (*Store, error) {
+	pgpool, err := pglib.NewConnPool(ctx, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("create postgres connection pool: %w", err)
+	}
+	s := &Store{
+		conn: pgpool,
+	}
+
+	// create dead letters table if it doesn't exist
+	if err := s.createTable(ctx); err != nil {
+		return nil, fmt.Errorf("creating dead letters table: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *Store) Put(ctx context.Context, entries []search.DeadLetterEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	query, params, err := buildPutQuery(entries)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Exec(ctx, query, params...)
+	return err
+}
+
+func (s *Store) List(ctx context.Context, afterID string, limit int) ([]search.DeadLetterEntry, error) {
+	query := fmt.Sprintf(`SELECT id, document, severity, error, lsn, created_at FROM %s WHERE id > $1 ORDER BY id LIMIT $2`, deadLettersTable)
+	rows, err := s.conn.Query(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying dead letters table: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []search.DeadLetterEntry{}
+	for rows.Next() {
+		entry := search.DeadLetterEntry{}
+		var document []byte
+		if err := rows.Scan(&entry.ID, &document, &entry.Severity, &entry.Error, &entry.LSN, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning dead letter row: %w", err)
+		}
+		// keep the document numbers as they were written, so that integers
+		// don't lose precision
+		decoder := json.NewDecoder(bytes.NewReader(document))
+		decoder.UseNumber()
+		if err := decoder.Decode(&entry.Document); err != nil {
+			return nil, fmt.Errorf("unmarshaling dead letter document: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *Store) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = ANY($1)`, deadLettersTable)
+	_, err := s.conn.Exec(ctx, query, ids)
+	return err
+}
+
+func (s *Store) Close() error {
+	return s.conn.Close(context.Background())
+}
+
+func (s *Store) createTable(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+	id TEXT PRIMARY KEY,
+	schema_name TEXT NOT NULL,
+	document_id TEXT NOT NULL,
+	document JSONB NOT NULL,
+	severity TEXT NOT NULL,
+	error TEXT NOT NULL,
+	lsn TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL)`, deadLettersTable)
+	_, err := s.conn.Exec(ctx, query)
+	return err
+}
+
+func buildPutQuery(entries []search.DeadLetterEntry) (string, []any, error) {
+	const columns = 8
+	values := make([]string, 0, len(entries))
+	params := make([]any, 0, len(entries)*columns)
+	for i, e := range entries {
+		document, err := json.Marshal(e.Document)
+		if err != nil {
+			return "", nil, fmt.Errorf("marshaling dead letter document: %w", err)
+		}
+		placeholders := make([]string, 0, columns)
+		for j := 1; j <= columns; j++ {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i*columns+j))
+		}
+		values = append(values, "("+strings.Join(placeholders, ", ")+")")
+		params = append(params, e.ID, e.Document.Schema, e.Document.ID, document, e.Severity, e.Error, e.LSN, e.CreatedAt)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s(id, schema_name, document_id, document, severity, error, lsn, created_at) VALUES %s ON CONFLICT (id) DO NOTHING`,
+		deadLettersTable, strings.Join(values, ", "))
+	return query, params, nil
+}
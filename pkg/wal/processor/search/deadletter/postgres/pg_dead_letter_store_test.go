@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestStore_buildPutQuery(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	entries := []search.DeadLetterEntry{
+		{
+			ID:        "a",
+			Document:  search.Document{ID: "doc-1", Schema: "test_schema", Version: 1, Data: map[string]any{"col-1": 1}},
+			Severity:  "DATALOSS",
+			Error:     "oh noes",
+			LSN:       "0/1",
+			CreatedAt: now,
+		},
+		{
+			ID:        "b",
+			Document:  search.Document{ID: "doc-2", Schema: "test_schema", Version: 2, Delete: true},
+			Severity:  "RETRIABLE",
+			Error:     "oh noes",
+			LSN:       "0/2",
+			CreatedAt: now,
+		},
+	}
+
+	query, params, err := buildPutQuery(entries)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprintf("INSERT INTO %s(id, schema_name, document_id, document, severity, error, lsn, created_at) VALUES "+
+		"($1, $2, $3, $4, $5, $6, $7, $8), ($9, $10, $11, $12, $13, $14, $15, $16) ON CONFLICT (id) DO NOTHING", deadLettersTable), query)
+	require.Equal(t, []any{
+		"a", "test_schema", "doc-1", []byte(`{"id":"doc-1","schema":"test_schema","data":{"col-1":1},"version":1,"delete":false}`), "DATALOSS", "oh noes", "0/1", now,
+		"b", "test_schema", "doc-2", []byte(`{"id":"doc-2","schema":"test_schema","data":null,"version":2,"delete":true}`), "RETRIABLE", "oh noes", "0/2", now,
+	}, params)
+}
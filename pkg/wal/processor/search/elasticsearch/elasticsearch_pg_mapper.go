@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package elasticsearch
+
+import (
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
+)
+
+// Mapper maps between postgres and elasticsearch types. The elasticsearch and
+// opensearch types are the same, except for the vector types, which use
+// `dense_vector` instead of the opensearch k-NN plugin `knn_vector`.
+type Mapper struct {
+	*opensearch.Mapper
+}
+
+const defaultVectorSimilarity = "cosine"
+
+// NewPostgresMapper returns a mapper that maps between postgres and
+// elasticsearch types
+func NewPostgresMapper() *Mapper {
+	return &Mapper{
+		Mapper: opensearch.NewPostgresMapper(),
+	}
+}
+
+// ColumnToSearchMapping maps the column on input into the equivalent search mapping
+func (m *Mapper) ColumnToSearchMapping(column schemalog.Column) (map[string]any, error) {
+	mapping, err := m.Mapper.ColumnToSearchMapping(column)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping["type"] == "knn_vector" {
+		return map[string]any{
+			"type":       "dense_vector",
+			"dims":       mapping["dimension"],
+			"index":      true,
+			"similarity": defaultVectorSimilarity,
+		}, nil
+	}
+
+	return mapping, nil
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package elasticsearch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestMapper_ColumnToSearchMapping(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dataType string
+
+		wantMapping map[string]any
+		wantErr     error
+	}{
+		{
+			name:     "vector",
+			dataType: "vector(3)",
+
+			wantMapping: map[string]any{
+				"type":       "dense_vector",
+				"dims":       3,
+				"index":      true,
+				"similarity": "cosine",
+			},
+		},
+		{
+			name:     "vector with schema",
+			dataType: "extensions.vector(1536)",
+
+			wantMapping: map[string]any{
+				"type":       "dense_vector",
+				"dims":       1536,
+				"index":      true,
+				"similarity": "cosine",
+			},
+		},
+		{
+			name:     "integer",
+			dataType: "int8",
+
+			wantMapping: map[string]any{"type": "long"},
+		},
+		{
+			name:     "unknown type",
+			dataType: "unknown",
+
+			wantErr: search.ErrTypeInvalid{Input: "unknown"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapper := NewPostgresMapper()
+			mapping, err := mapper.ColumnToSearchMapping(schemalog.Column{DataType: tc.dataType})
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantMapping, mapping)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package elasticsearch
+
+import (
+	"fmt"
+
+	"github.com/xataio/pgstream/internal/es"
+	tlslib "github.com/xataio/pgstream/internal/tls"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
+)
+
+// Store is an Elasticsearch 8 search store. Elasticsearch and OpenSearch share
+// the document and index APIs used by the store, so it relies on the
+// opensearch store implementation, with its own mapper and index settings.
+type Store struct {
+	*opensearch.Store
+}
+
+type Config struct {
+	URL string
+	// Username and Password enable basic authentication.
+	Username string
+	Password string
+	// APIKey is the base64 encoded API key, used instead of basic
+	// authentication when set.
+	APIKey string
+	// TLS configures a custom CA and client certificates. Optional.
+	TLS *tlslib.Config
+	// IndexLayout defines how the schema tables are distributed across
+	// indices. Defaults to an index per schema.
+	IndexLayout opensearch.IndexLayout
+	// MappingConfigFile is the path to an optional JSON file with the column
+	// mapping overrides and index settings. See opensearch.MappingConfig.
+	MappingConfigFile string
+}
+
+type Option = opensearch.Option
+
+func NewStore(cfg Config, opts ...Option) (*Store, error) {
+	client, err := es.NewClient(es.ClientConfig{
+		URL:      cfg.URL,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		APIKey:   cfg.APIKey,
+		TLS:      cfg.TLS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create elasticsearch client: %w", err)
+	}
+
+	layoutOpts, err := opensearch.LayoutOptions(cfg.IndexLayout, cfg.MappingConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStoreWithClient(client, append(layoutOpts, opts...)...), nil
+}
+
+func NewStoreWithClient(client es.SearchClient, opts ...Option) *Store {
+	storeOpts := []Option{
+		opensearch.WithMapper(NewPostgresMapper()),
+		opensearch.WithIndexSettings(map[string]any{
+			"number_of_shards":                 1,
+			"number_of_replicas":               1,
+			"index.mapping.total_fields.limit": 2000,
+		}),
+		opensearch.WithFlattenedFieldType("flattened"),
+	}
+	storeOpts = append(storeOpts, opts...)
+
+	s := opensearch.NewStoreWithClient(client)
+	for _, opt := range storeOpts {
+		opt(s)
+	}
+
+	return &Store{Store: s}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return opensearch.WithLogger(l)
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/schemalog"
+)
+
+func TestStore_ApplySchemaChange(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testLogEntry := &schemalog.LogEntry{
+		ID:         xid.New(),
+		SchemaName: testSchemaName,
+		Version:    1,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					Name:       "test_table",
+					PgstreamID: "t1",
+					Columns: []schemalog.Column{
+						{Name: "id", DataType: "int8", PgstreamID: "t1-1"},
+						{Name: "embedding", DataType: "vector(3)", PgstreamID: "t1-2"},
+					},
+				},
+			},
+		},
+	}
+
+	createdIndices := []string{}
+	client := &esmocks.Client{
+		GetIndicesMetaFn: func(ctx context.Context, pattern, field string) (map[string]json.RawMessage, error) {
+			return map[string]json.RawMessage{}, nil
+		},
+		SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+			return &es.SearchResponse{}, nil
+		},
+		IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
+			return len(createdIndices) > 0, nil
+		},
+		CreateIndexFn: func(ctx context.Context, index string, body map[string]any) error {
+			createdIndices = append(createdIndices, index)
+			require.Equal(t, "test_schema-1", index)
+			require.Equal(t, map[string]any{
+				"number_of_shards":                 1,
+				"number_of_replicas":               1,
+				"index.mapping.total_fields.limit": 2000,
+			}, body["settings"])
+			return nil
+		},
+		PutIndexAliasFn: func(ctx context.Context, index []string, name string) error {
+			require.Equal(t, []string{"test_schema-1"}, index)
+			require.Equal(t, testSchemaName, name)
+			return nil
+		},
+		PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+			require.Equal(t, testSchemaName, index)
+			require.Equal(t, map[string]any{
+				"properties": map[string]any{
+					"t1-1": map[string]any{"type": "long"},
+					"t1-2": map[string]any{
+						"type":       "dense_vector",
+						"dims":       3,
+						"index":      true,
+						"similarity": "cosine",
+					},
+				},
+			}, body)
+			return nil
+		},
+		IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+			require.Equal(t, "pgstream", req.Index)
+			require.Equal(t, testLogEntry.ID.String(), req.ID)
+			return nil
+		},
+	}
+
+	store := NewStoreWithClient(client)
+	err := store.ApplySchemaChange(context.Background(), testLogEntry)
+	require.NoError(t, err)
+	require.Equal(t, []string{"test_schema-1"}, createdIndices)
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/xid"
@@ -25,9 +26,14 @@ type mockStore struct {
 	getMapperFn            func() Mapper
 	applySchemaChangeFn    func(ctx context.Context, le *schemalog.LogEntry) error
 	deleteSchemaFn         func(ctx context.Context, schemaName string) error
+	completeMigrationFn    func(ctx context.Context, schemaName string) error
+	resumeMigrationsFn     func(ctx context.Context) ([]string, error)
 	deleteTableDocumentsFn func(ctx context.Context, schemaName string, tableIDs []string) error
 	sendDocumentsFn        func(ctx context.Context, i uint, docs []Document) ([]DocumentError, error)
-	sendDocumentsCalls     uint
+	sendDocumentsCalls     uint64
+	updateTableDocumentsFn func(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error
+	softDeleteTableDocsFn  func(ctx context.Context, schemaName string, tableIDs []string, deletedAt time.Time) error
+	purgeDeletedDocsFn     func(ctx context.Context, schemaName string, deletedBefore time.Time) error
 }
 
 func (m *mockStore) GetMapper() Mapper {
@@ -42,25 +48,89 @@ func (m *mockStore) DeleteSchema(ctx context.Context, schemaName string) error {
 	return m.deleteSchemaFn(ctx, schemaName)
 }
 
+func (m *mockStore) CompleteSchemaMigration(ctx context.Context, schemaName string) error {
+	return m.completeMigrationFn(ctx, schemaName)
+}
+
+func (m *mockStore) ResumeSchemaMigrations(ctx context.Context) ([]string, error) {
+	return m.resumeMigrationsFn(ctx)
+}
+
 func (m *mockStore) DeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string) error {
 	return m.deleteTableDocumentsFn(ctx, schemaName, tableIDs)
 }
 
 func (m *mockStore) SendDocuments(ctx context.Context, docs []Document) ([]DocumentError, error) {
-	m.sendDocumentsCalls++
-	return m.sendDocumentsFn(ctx, m.sendDocumentsCalls, docs)
+	calls := atomic.AddUint64(&m.sendDocumentsCalls, 1)
+	return m.sendDocumentsFn(ctx, uint(calls), docs)
+}
+
+func (m *mockStore) UpdateTableDocuments(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error {
+	return m.updateTableDocumentsFn(ctx, schemaName, tableID, docIDs, fields)
+}
+
+func (m *mockStore) SoftDeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string, deletedAt time.Time) error {
+	return m.softDeleteTableDocsFn(ctx, schemaName, tableIDs, deletedAt)
+}
+
+func (m *mockStore) PurgeDeletedDocuments(ctx context.Context, schemaName string, deletedBefore time.Time) error {
+	return m.purgeDeletedDocsFn(ctx, schemaName, deletedBefore)
+}
+
+type mockDeadLetterStore struct {
+	putFn    func(ctx context.Context, entries []DeadLetterEntry) error
+	listFn   func(ctx context.Context, afterID string, limit int) ([]DeadLetterEntry, error)
+	deleteFn func(ctx context.Context, ids []string) error
+}
+
+func (m *mockDeadLetterStore) Put(ctx context.Context, entries []DeadLetterEntry) error {
+	return m.putFn(ctx, entries)
+}
+
+func (m *mockDeadLetterStore) List(ctx context.Context, afterID string, limit int) ([]DeadLetterEntry, error) {
+	return m.listFn(ctx, afterID, limit)
+}
+
+func (m *mockDeadLetterStore) Delete(ctx context.Context, ids []string) error {
+	return m.deleteFn(ctx, ids)
+}
+
+func (m *mockDeadLetterStore) Close() error {
+	return nil
+}
+
+type mockRelatedRowReader struct {
+	parentRowsFn       func(ctx context.Context, relation *Relation, keys []string) (map[string]map[string]any, error)
+	childDocumentIDsFn func(ctx context.Context, relation *Relation, keys []string) (string, map[string][]string, error)
+}
+
+func (m *mockRelatedRowReader) ParentRows(ctx context.Context, relation *Relation, keys []string) (map[string]map[string]any, error) {
+	return m.parentRowsFn(ctx, relation, keys)
+}
+
+func (m *mockRelatedRowReader) ChildDocumentIDs(ctx context.Context, relation *Relation, keys []string) (string, map[string][]string, error) {
+	return m.childDocumentIDsFn(ctx, relation, keys)
+}
+
+func (m *mockRelatedRowReader) Close() error {
+	return nil
 }
 
 type mockCleaner struct {
-	deleteSchemaFn func(context.Context, string) error
-	startFn        func(context.Context)
-	stopFn         func()
+	deleteSchemaFn            func(context.Context, string) error
+	completeSchemaMigrationFn func(context.Context, string) error
+	startFn                   func(context.Context)
+	stopFn                    func()
 }
 
 func (m *mockCleaner) deleteSchema(ctx context.Context, schema string) error {
 	return m.deleteSchemaFn(ctx, schema)
 }
 
+func (m *mockCleaner) completeSchemaMigration(ctx context.Context, schema string) error {
+	return m.completeSchemaMigrationFn(ctx, schema)
+}
+
 func (m *mockCleaner) start(ctx context.Context) {
 	m.startFn(ctx)
 }
@@ -132,6 +202,26 @@ func withID(id string) testDocOption {
 	}
 }
 
+func withVersion(version int) testDocOption {
+	return func(d *Document) {
+		d.Version = version
+	}
+}
+
+func withDelete() testDocOption {
+	return func(d *Document) {
+		d.Delete = true
+	}
+}
+
+func withSoftDelete(deletedAt time.Time) testDocOption {
+	return func(d *Document) {
+		d.SoftDelete = true
+		d.Data[DeletedField] = true
+		d.Data[DeletedAtField] = deletedAt.UTC().Format(deletedAtFormat)
+	}
+}
+
 func newTestDocument(opts ...testDocOption) *Document {
 	doc := &Document{
 		Schema:  testSchemaName,
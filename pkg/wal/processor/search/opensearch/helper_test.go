@@ -3,6 +3,9 @@
 package opensearch
 
 import (
+	"context"
+	"encoding/json"
+
 	"github.com/xataio/pgstream/internal/es"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
@@ -35,3 +38,9 @@ func (m *mockAdapter) SearchDocToBulkItem(docs search.Document) es.BulkItem {
 func (m *mockAdapter) BulkItemsToSearchDocErrs(items []es.BulkItem) []search.DocumentError {
 	return m.bulkItemsToSearchDocErrsFn(items)
 }
+
+// noIndexMigrations mocks the lookup of the persisted index migrations when
+// there are none.
+func noIndexMigrations(ctx context.Context, pattern, field string) (map[string]json.RawMessage, error) {
+	return map[string]json.RawMessage{}, nil
+}
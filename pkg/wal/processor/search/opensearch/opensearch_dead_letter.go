@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/xataio/pgstream/internal/es"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// DeadLetterIndex is a dead letter store that keeps the documents that
+// couldn't be indexed in a dedicated search store index. The documents are
+// kept in the entry source, but not mapped, so that they can't fail to be
+// indexed again.
+type DeadLetterIndex struct {
+	client es.SearchClient
+	index  string
+
+	indexLock    sync.Mutex
+	indexCreated bool
+}
+
+// DefaultDeadLetterIndexName is the name of the dead letter index used when
+// none is provided.
+const DefaultDeadLetterIndexName = "pgstream-dead-letters"
+
+// NewDeadLetterIndex returns a dead letter store backed by the search store
+// index on input, which is created on the first write if it doesn't exist.
+func NewDeadLetterIndex(client es.SearchClient, index string) *DeadLetterIndex {
+	if index == "" {
+		index = DefaultDeadLetterIndexName
+	}
+	return &DeadLetterIndex{
+		client: client,
+		index:  index,
+	}
+}
+
+// DeadLetterIndex returns a dead letter store backed by an index of the same
+// search store.
+func (s *Store) DeadLetterIndex(index string) *DeadLetterIndex {
+	return NewDeadLetterIndex(s.client, index)
+}
+
+func (d *DeadLetterIndex) Put(ctx context.Context, entries []search.DeadLetterEntry) error {
+	if err := d.ensureIndex(ctx); err != nil {
+		return fmt.Errorf("creating dead letter index: %w", mapError(err))
+	}
+
+	items := make([]es.BulkItem, 0, len(entries))
+	for _, e := range entries {
+		doc, err := deadLetterEntryToDoc(e)
+		if err != nil {
+			return err
+		}
+		items = append(items, es.BulkItem{
+			Index: &es.BulkIndex{Index: d.index, ID: e.ID},
+			Doc:   doc,
+		})
+	}
+
+	failed, err := d.client.SendBulkRequest(ctx, items)
+	if err != nil {
+		return mapError(err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to index %d dead letter entries: %s", len(failed), failed[0].Error)
+	}
+	return nil
+}
+
+func (d *DeadLetterIndex) List(ctx context.Context, afterID string, limit int) ([]search.DeadLetterEntry, error) {
+	query := es.QueryBody{}
+	if afterID != "" {
+		query.Query = &es.Query{
+			Bool: &es.BoolFilter{
+				Filter: []es.Condition{
+					{Range: map[string]any{"id": map[string]any{"gt": afterID}}},
+				},
+			},
+		}
+	}
+	bodyJSON, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal to JSON: %+v, %w", query, err)
+	}
+
+	res, err := d.client.Search(ctx, &es.SearchRequest{
+		Index: es.Ptr(d.index),
+		Size:  es.Ptr(limit),
+		Sort:  es.Ptr("id:asc"),
+		Query: bytes.NewBuffer(bodyJSON),
+	})
+	if err != nil {
+		// no entries have been dead lettered yet
+		if errors.Is(err, es.ErrResourceNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("searching dead letter index: %w", mapError(err))
+	}
+
+	entries := make([]search.DeadLetterEntry, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		entry, err := docToDeadLetterEntry(hit.Source)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+func (d *DeadLetterIndex) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := d.client.DeleteByQuery(ctx, &es.DeleteByQueryRequest{
+		Index: []string{d.index},
+		Query: map[string]any{
+			"query": map[string]any{
+				"terms": map[string]any{
+					"id": ids,
+				},
+			},
+		},
+		Refresh: true,
+	})
+	if err != nil {
+		return fmt.Errorf("deleting dead letter entries: %w", mapError(err))
+	}
+	return nil
+}
+
+func (d *DeadLetterIndex) Close() error {
+	return nil
+}
+
+func (d *DeadLetterIndex) ensureIndex(ctx context.Context) error {
+	d.indexLock.Lock()
+	defer d.indexLock.Unlock()
+
+	if d.indexCreated {
+		return nil
+	}
+
+	exists, err := d.client.IndexExists(ctx, d.index)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err := d.client.CreateIndex(ctx, d.index, map[string]any{
+			"mappings": map[string]any{
+				// the failed documents are only kept in the source
+				"dynamic": false,
+				"properties": map[string]any{
+					"id":         map[string]any{"type": "keyword"},
+					"severity":   map[string]any{"type": "keyword"},
+					"error":      map[string]any{"type": "text"},
+					"lsn":        map[string]any{"type": "keyword"},
+					"created_at": map[string]any{"type": "date"},
+					"document": map[string]any{
+						"type":    "object",
+						"enabled": false,
+					},
+				},
+			},
+		})
+		if err != nil && !errors.As(err, &es.ErrResourceAlreadyExists{}) {
+			return err
+		}
+	}
+
+	d.indexCreated = true
+	return nil
+}
+
+func deadLetterEntryToDoc(e search.DeadLetterEntry) (map[string]any, error) {
+	entryBytes, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling dead letter entry: %w", err)
+	}
+	doc := map[string]any{}
+	if err := json.Unmarshal(entryBytes, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshaling dead letter entry: %w", err)
+	}
+	return doc, nil
+}
+
+func docToDeadLetterEntry(doc map[string]any) (*search.DeadLetterEntry, error) {
+	docBytes, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling dead letter document: %w", err)
+	}
+	entry := &search.DeadLetterEntry{}
+	if err := json.Unmarshal(docBytes, entry); err != nil {
+		return nil, fmt.Errorf("unmarshaling dead letter document: %w", err)
+	}
+	return entry, nil
+}
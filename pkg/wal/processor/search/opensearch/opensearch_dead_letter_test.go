@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestDeadLetterIndex(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testEntry := search.DeadLetterEntry{
+		ID: "a",
+		Document: search.Document{
+			ID:      "doc-1",
+			Schema:  "test_schema",
+			Version: 1,
+			Data:    map[string]any{"col-1": "value"},
+		},
+		Severity:  "DATALOSS",
+		Error:     "oh noes",
+		LSN:       "0/1",
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	testEntryDoc := map[string]any{
+		"id": "a",
+		"document": map[string]any{
+			"id":      "doc-1",
+			"schema":  "test_schema",
+			"version": float64(1),
+			"delete":  false,
+			"data":    map[string]any{"col-1": "value"},
+		},
+		"severity":   "DATALOSS",
+		"error":      "oh noes",
+		"lsn":        "0/1",
+		"created_at": "2024-01-01T00:00:00Z",
+	}
+
+	createIndexCalls := 0
+	client := &esmocks.Client{
+		IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
+			require.Equal(t, DefaultDeadLetterIndexName, index)
+			return false, nil
+		},
+		CreateIndexFn: func(ctx context.Context, index string, body map[string]any) error {
+			createIndexCalls++
+			require.Equal(t, DefaultDeadLetterIndexName, index)
+			return nil
+		},
+		SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
+			require.Equal(t, []es.BulkItem{
+				{
+					Index: &es.BulkIndex{Index: DefaultDeadLetterIndexName, ID: "a"},
+					Doc:   testEntryDoc,
+				},
+			}, items)
+			return nil, nil
+		},
+		SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+			require.Equal(t, DefaultDeadLetterIndexName, *req.Index)
+			require.Equal(t, "id:asc", *req.Sort)
+			require.Equal(t, 10, *req.Size)
+			query, err := io.ReadAll(req.Query)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"query":{"bool":{"filter":[{"range":{"id":{"gt":"0"}}}]}}}`, string(query))
+			return &es.SearchResponse{
+				Hits: es.Hits{Hits: []es.Hit{{Source: testEntryDoc}}},
+			}, nil
+		},
+		DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+			require.Equal(t, []string{DefaultDeadLetterIndexName}, req.Index)
+			require.Equal(t, map[string]any{
+				"query": map[string]any{"terms": map[string]any{"id": []string{"a"}}},
+			}, req.Query)
+			return nil
+		},
+	}
+
+	d := NewDeadLetterIndex(client, "")
+	require.NoError(t, d.Put(ctx, []search.DeadLetterEntry{testEntry}))
+	// the index is only created once
+	require.NoError(t, d.Put(ctx, []search.DeadLetterEntry{testEntry}))
+	require.Equal(t, 1, createIndexCalls)
+
+	entries, err := d.List(ctx, "0", 10)
+	require.NoError(t, err)
+	require.Equal(t, []search.DeadLetterEntry{testEntry}, entries)
+
+	require.NoError(t, d.Delete(ctx, []string{"a"}))
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xataio/pgstream/internal/es"
+)
+
+// The methods in this file read back the documents indexed for a table, so that
+// they can be compared with the table rows. Only the document ids and versions
+// are retrieved.
+
+const scanKeepAlive = time.Minute
+
+// CountTableDocuments returns the number of documents indexed for the table
+// with the pgstream id on input.
+func (s *Store) CountTableDocuments(ctx context.Context, schemaName, tableID string) (int, error) {
+	if s.indexLayout != IndexPerSchema {
+		index, err := s.tableIndex(ctx, schemaName, tableID)
+		if err != nil {
+			if errors.Is(err, errTableIndexNotFound) {
+				return 0, nil
+			}
+			return 0, err
+		}
+		count, err := s.client.Count(ctx, index.Name())
+		if err != nil {
+			if errors.Is(err, es.ErrResourceNotFound) {
+				return 0, nil
+			}
+			return 0, mapError(err)
+		}
+		return count, nil
+	}
+
+	// the schema index is shared by all the schema tables, so the documents
+	// need to be filtered by table
+	res, err := s.searchTableDocuments(ctx, schemaName, tableID, nil, &es.SearchRequest{
+		Size:           es.Ptr(0),
+		TrackTotalHits: es.Ptr(true),
+	})
+	if err != nil || res == nil {
+		return 0, err
+	}
+	return res.Hits.Total.Value, nil
+}
+
+// GetTableDocumentVersions returns the versions of the table documents with the
+// ids on input, by document id. The documents that don't exist are not
+// included.
+func (s *Store) GetTableDocumentVersions(ctx context.Context, schemaName, tableID string, ids []string) (map[string]int, error) {
+	if len(ids) == 0 {
+		return map[string]int{}, nil
+	}
+
+	res, err := s.searchTableDocuments(ctx, schemaName, tableID, []es.Condition{
+		{IDs: map[string]any{"values": ids}},
+	}, &es.SearchRequest{
+		Size: es.Ptr(len(ids)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hitVersions(res), nil
+}
+
+// SampleTableDocuments returns the versions of up to size random documents of
+// the table, by document id.
+func (s *Store) SampleTableDocuments(ctx context.Context, schemaName, tableID string, size int) (map[string]int, error) {
+	index, filter, err := s.tableDocumentsFilter(ctx, schemaName, tableID)
+	if err != nil {
+		if errors.Is(err, errTableIndexNotFound) {
+			return map[string]int{}, nil
+		}
+		return nil, err
+	}
+
+	query := es.QueryBody{
+		Query: &es.Query{
+			FunctionScore: &es.FunctionScore{
+				Query:     &es.Query{Bool: &es.BoolFilter{Filter: filter}},
+				Functions: []es.Function{{RandomScore: &es.RandomScore{}}},
+				BoostMode: "replace",
+			},
+		},
+	}
+	res, err := s.search(ctx, query, &es.SearchRequest{
+		Index: es.Ptr(index),
+		Size:  es.Ptr(size),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hitVersions(res), nil
+}
+
+// ScanTableDocuments calls fn with the versions of all the table documents, by
+// document id, in batches of up to batchSize documents. The documents are
+// scrolled, so the changes indexed after the scan starts are not visible.
+func (s *Store) ScanTableDocuments(ctx context.Context, schemaName, tableID string, batchSize int, fn func(versions map[string]int) error) error {
+	res, err := s.searchTableDocuments(ctx, schemaName, tableID, nil, &es.SearchRequest{
+		Size:   es.Ptr(batchSize),
+		Sort:   es.Ptr("_doc"),
+		Scroll: es.Ptr(scanKeepAlive),
+	})
+	if err != nil || res == nil {
+		return err
+	}
+
+	scrollID := res.ScrollID
+	defer func() {
+		if scrollID == "" {
+			return
+		}
+		if err := s.client.ClearScroll(context.Background(), scrollID); err != nil {
+			s.logger.Warn(err, "clearing table documents scroll")
+		}
+	}()
+
+	for len(res.Hits.Hits) > 0 {
+		if err := fn(hitVersions(res)); err != nil {
+			return err
+		}
+
+		res, err = s.client.Scroll(ctx, scrollID, scanKeepAlive)
+		if err != nil {
+			return fmt.Errorf("scrolling table documents: %w", mapError(err))
+		}
+		if res.ScrollID != "" {
+			scrollID = res.ScrollID
+		}
+	}
+	return nil
+}
+
+// searchTableDocuments runs the search request on input against the table
+// documents, filtered by the conditions on input. It returns a nil response if
+// the table has no index.
+func (s *Store) searchTableDocuments(ctx context.Context, schemaName, tableID string, conditions []es.Condition, req *es.SearchRequest) (*es.SearchResponse, error) {
+	index, filter, err := s.tableDocumentsFilter(ctx, schemaName, tableID)
+	if err != nil {
+		if errors.Is(err, errTableIndexNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	query := es.QueryBody{
+		Query: &es.Query{
+			Bool: &es.BoolFilter{
+				Filter: append(filter, conditions...),
+			},
+		},
+	}
+	req.Index = es.Ptr(index)
+	return s.search(ctx, query, req)
+}
+
+// tableDocumentsFilter returns the index of the table documents and the filter
+// that selects them within it.
+func (s *Store) tableDocumentsFilter(ctx context.Context, schemaName, tableID string) (string, []es.Condition, error) {
+	filter := []es.Condition{
+		{Term: map[string]any{"_table": tableID}},
+	}
+	if s.indexLayout == IndexPerSchema {
+		return s.adapter.SchemaNameToIndex(schemaName).Name(), filter, nil
+	}
+
+	index, err := s.tableIndex(ctx, schemaName, tableID)
+	if err != nil {
+		return "", nil, err
+	}
+	return index.Name(), filter, nil
+}
+
+// search runs the query on input, returning only the document ids and
+// versions. A missing index returns an empty response.
+func (s *Store) search(ctx context.Context, query es.QueryBody, req *es.SearchRequest) (*es.SearchResponse, error) {
+	bodyJSON, err := s.marshaler(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal to JSON: %+v, %w", query, err)
+	}
+	req.Query = bytes.NewBuffer(bodyJSON)
+	req.ReturnVersion = es.Ptr(true)
+	req.SourceIncludes = es.Ptr("_table")
+
+	res, err := s.client.Search(ctx, req)
+	if err != nil {
+		if errors.Is(err, es.ErrResourceNotFound) {
+			return &es.SearchResponse{}, nil
+		}
+		return nil, fmt.Errorf("searching table documents: %w", mapError(err))
+	}
+	return res, nil
+}
+
+func hitVersions(res *es.SearchResponse) map[string]int {
+	versions := map[string]int{}
+	if res == nil {
+		return versions
+	}
+	for _, hit := range res.Hits.Hits {
+		versions[hit.ID] = hit.Version
+	}
+	return versions
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+)
+
+func TestStore_CountTableDocuments(t *testing.T) {
+	t.Parallel()
+
+	testSchema := "test_schema"
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		layout IndexLayout
+		client *esmocks.Client
+
+		wantCount int
+		wantErr   error
+	}{
+		{
+			name:   "ok - schema index",
+			layout: IndexPerSchema,
+			client: &esmocks.Client{
+				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+					require.Equal(t, testSchema, *req.Index)
+					require.Equal(t, 0, *req.Size)
+					require.True(t, *req.TrackTotalHits)
+					require.Equal(t, map[string]any{
+						"query": map[string]any{
+							"bool": map[string]any{
+								"filter": []any{
+									map[string]any{"term": map[string]any{"_table": "t1"}},
+								},
+							},
+						},
+					}, decodeQuery(t, req.Query))
+
+					res := &es.SearchResponse{}
+					res.Hits.Total.Value = 5
+					return res, nil
+				},
+			},
+
+			wantCount: 5,
+		},
+		{
+			name:   "ok - table index",
+			layout: IndexPerTable,
+			client: &esmocks.Client{
+				CountFn: func(ctx context.Context, index string) (int, error) {
+					require.Equal(t, "test_schema.t1", index)
+					return 3, nil
+				},
+			},
+
+			wantCount: 3,
+		},
+		{
+			name:   "ok - index not found",
+			layout: IndexPerSchema,
+			client: &esmocks.Client{
+				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+					return nil, es.ErrResourceNotFound
+				},
+			},
+
+			wantCount: 0,
+		},
+		{
+			name:   "error - counting table index documents",
+			layout: IndexPerTable,
+			client: &esmocks.Client{
+				CountFn: func(ctx context.Context, index string) (int, error) {
+					return 0, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewStoreWithClient(tc.client)
+			s.indexLayout = tc.layout
+
+			count, err := s.CountTableDocuments(context.Background(), testSchema, "t1")
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantCount, count)
+		})
+	}
+}
+
+func TestStore_ScanTableDocuments(t *testing.T) {
+	t.Parallel()
+
+	hits := func(ids ...string) es.Hits {
+		h := es.Hits{}
+		for i, id := range ids {
+			h.Hits = append(h.Hits, es.Hit{ID: id, Version: i + 1})
+		}
+		return h
+	}
+
+	scrolls := 0
+	cleared := ""
+	s := NewStoreWithClient(&esmocks.Client{
+		SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+			require.Equal(t, 2, *req.Size)
+			require.Equal(t, time.Minute, *req.Scroll)
+			require.True(t, *req.ReturnVersion)
+			return &es.SearchResponse{ScrollID: "scroll-1", Hits: hits("t1_1", "t1_2")}, nil
+		},
+		ScrollFn: func(ctx context.Context, scrollID string, keepAlive time.Duration) (*es.SearchResponse, error) {
+			scrolls++
+			if scrolls == 1 {
+				require.Equal(t, "scroll-1", scrollID)
+				return &es.SearchResponse{ScrollID: "scroll-2", Hits: hits("t1_3")}, nil
+			}
+			require.Equal(t, "scroll-2", scrollID)
+			return &es.SearchResponse{ScrollID: "scroll-2"}, nil
+		},
+		ClearScrollFn: func(ctx context.Context, scrollID string) error {
+			cleared = scrollID
+			return nil
+		},
+	})
+
+	batches := []map[string]int{}
+	err := s.ScanTableDocuments(context.Background(), "test_schema", "t1", 2, func(versions map[string]int) error {
+		batches = append(batches, versions)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []map[string]int{
+		{"t1_1": 1, "t1_2": 2},
+		{"t1_3": 1},
+	}, batches)
+	require.Equal(t, "scroll-2", cleared)
+}
+
+func decodeQuery(t *testing.T, query io.Reader) map[string]any {
+	body := map[string]any{}
+	require.NoError(t, json.NewDecoder(query).Decode(&body))
+	return body
+}
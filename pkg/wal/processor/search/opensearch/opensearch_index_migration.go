@@ -0,0 +1,876 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xataio/pgstream/internal/es"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+)
+
+// identityChange describes a table whose identity columns have changed, and
+// therefore whose documents need to be reindexed with a new ID.
+type identityChange struct {
+	tableID string
+	// columns are the pgstream ids of the new identity columns, in table column
+	// order, which is the order used to build the document ID.
+	columns []string
+	// previousIDColumn is the pgstream id of the previous identity column when
+	// it was a single column. Its value is not stored in the document, so it
+	// needs to be extracted from the previous document ID.
+	previousIDColumn string
+}
+
+// migrationChanges are the schema changes that can only be applied by
+// migrating the schema documents into a new index version.
+type migrationChanges struct {
+	// schemaVersion is the schema log version the changes belong to.
+	schemaVersion   int64
+	identityChanges []identityChange
+	// retypedColumns are the new search mappings of the columns whose type
+	// change is not compatible with their existing mapping, by pgstream id.
+	retypedColumns map[string]any
+	// removedColumns are the pgstream ids of the columns whose values need to
+	// be removed from the documents as part of the migration.
+	removedColumns []string
+	// indexSettings are the settings of the new index version.
+	indexSettings map[string]any
+}
+
+// required returns true if the changes need a new index version. Removed
+// columns on their own can be applied to the existing index.
+func (c *migrationChanges) required() bool {
+	return len(c.identityChanges) > 0 || len(c.retypedColumns) > 0
+}
+
+// reindexScript rebuilds the ID of the documents for the tables whose identity
+// has changed, using the same format as the search adapter
+// (<table_id>_<id_value>[-<id_value>...]). Documents that don't have a value
+// for all the new identity columns are skipped, since they can't be addressed.
+// It also removes the values of the retyped and removed columns.
+const reindexScript = `
+def table = params.tables.get(ctx._source._table);
+if (table != null) {
+	String previousID = null;
+	if (table.previous_id_column != null) {
+		previousID = ctx._id.substring(ctx._source._table.length() + 1);
+	}
+
+	List values = new ArrayList();
+	for (def column : table.columns) {
+		def value = ctx._source.get(column);
+		if (value == null && column == table.previous_id_column) {
+			value = previousID;
+		}
+		if (value == null) {
+			ctx.op = 'noop';
+			return;
+		}
+		if (value instanceof Double) {
+			double d = (double) value;
+			if (d == Math.floor(d) && !Double.isInfinite(d)) {
+				value = (long) d;
+			}
+		}
+		values.add(value.toString());
+	}
+
+	// single identity column values are only kept in the document id, so the
+	// previous one needs to be added to the document now that it's a regular
+	// column or part of a composite identity.
+	if (previousID != null && table.previous_id_mapped && ctx._source.get(table.previous_id_column) == null) {
+		ctx._source.put(table.previous_id_column, previousID);
+	}
+	if (table.columns.size() == 1) {
+		ctx._source.remove(table.columns.get(0));
+	}
+
+	ctx._id = ctx._source._table + '_' + String.join('-', values);
+}
+
+for (def field : params.remove_fields) {
+	ctx._source.remove(field);
+}
+`
+
+// removeFieldsScript removes the fields on input from the documents.
+const removeFieldsScript = `
+for (def field : params.fields) {
+	ctx._source.remove(field);
+}
+`
+
+// setFieldsScript sets the fields on input in the documents.
+const setFieldsScript = `
+for (def field : params.fields.entrySet()) {
+	ctx._source[field.getKey()] = field.getValue();
+}
+`
+
+// getIdentityChanges returns the tables with changed identity columns between
+// the previous and the new schema log entries.
+func getIdentityChanges(newEntry, previousEntry *schemalog.LogEntry, diff *schemalog.SchemaDiff) []identityChange {
+	if previousEntry == nil || diff == nil {
+		return nil
+	}
+
+	tableNames := append(slices.Clone(diff.PrimaryKeyChange), diff.UniqueNotNullChange...)
+	changes := make([]identityChange, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		newTable := newEntry.GetTableByName(tableName)
+		if newTable == nil {
+			continue
+		}
+		// skip duplicates, a table can have both identity changes
+		if slices.ContainsFunc(changes, func(c identityChange) bool { return c.tableID == newTable.PgstreamID }) {
+			continue
+		}
+
+		change := identityChange{
+			tableID: newTable.PgstreamID,
+			columns: identityColumns(newTable),
+		}
+		for i := range previousEntry.Schema.Tables {
+			previousTable := &previousEntry.Schema.Tables[i]
+			if previousTable.PgstreamID != newTable.PgstreamID {
+				continue
+			}
+			if previousColumns := identityColumns(previousTable); len(previousColumns) == 1 {
+				change.previousIDColumn = previousColumns[0]
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// identityColumns returns the pgstream ids of the identity columns of the
+// table. It follows the same logic as the default WAL translator identity
+// finder, using the primary key if any, or the first not null unique column
+// otherwise.
+func identityColumns(table *schemalog.Table) []string {
+	if len(table.PrimaryKeyColumns) == 0 {
+		if col := table.GetFirstUniqueNotNullColumn(); col != nil {
+			return []string{col.PgstreamID}
+		}
+		return []string{}
+	}
+
+	columns := []string{}
+	for _, col := range table.Columns {
+		if slices.Contains(table.PrimaryKeyColumns, col.Name) {
+			columns = append(columns, col.PgstreamID)
+		}
+	}
+	return columns
+}
+
+// indexMigration tracks the migration of an index into a new version. While
+// in progress, documents are written to both index versions, and the documents
+// of the previous version are backfilled into the new one. Once the backfill
+// is completed, the index alias is moved to the new version. The migration
+// state is persisted in the new index version mapping metadata, so that it can
+// be resumed after a restart.
+type indexMigration struct {
+	from   IndexName
+	to     IndexName
+	taskID string
+	// schemaVersion is the schema log version the migration applies.
+	schemaVersion int64
+	// script is the reindex script of the backfill, and attempts the number
+	// of backfills started.
+	script   *es.Script
+	attempts int
+
+	// completeLock serialises the completion of the migration, which can be
+	// triggered by the schema cleaner and by the store itself.
+	completeLock sync.Mutex
+	aliasSwapped bool
+
+	// changesTypes is set when the new index version maps some columns with a
+	// different type. The previous version is not able to index the values of
+	// the new types, so the write failures on it are expected.
+	changesTypes bool
+}
+
+// migrationState is the persisted state of an index migration, stored in the
+// new index version mapping metadata.
+type migrationState struct {
+	Schema        string     `json:"schema"`
+	TableID       string     `json:"table_id,omitempty"`
+	Alias         string     `json:"alias"`
+	FromVersion   int        `json:"from_version"`
+	SchemaVersion int64      `json:"schema_version"`
+	TaskID        string     `json:"task_id,omitempty"`
+	Attempts      int        `json:"attempts"`
+	ChangesTypes  bool       `json:"changes_types,omitempty"`
+	Script        *es.Script `json:"script,omitempty"`
+}
+
+const (
+	defaultBackfillPollInterval = time.Second
+	// maxBackfillAttempts is the number of times the backfill of a migration
+	// is started before giving up on it.
+	maxBackfillAttempts = 3
+	// migrationMetaField is the mapping metadata field where the migration
+	// state is persisted.
+	migrationMetaField = "pgstream_migration"
+)
+
+// startMigration creates a new version of the index, with the same mapping as
+// the current one, and starts backfilling it from the current version in the
+// background. The document IDs of the tables with identity
+// changes are updated to use their new identity columns as part of the
+// backfill, and the retyped columns are mapped with their new type. Any
+// documents sent while the migration is in progress are written to both index
+// versions.
+func (s *Store) startMigration(ctx context.Context, index IndexName, changes migrationChanges) error {
+	if err := s.discardRetriedMigration(ctx, index.Name(), changes.schemaVersion); err != nil {
+		return err
+	}
+
+	// migrations are applied in order, so that the new one starts from the
+	// latest index version
+	if err := s.completeMigration(ctx, index.Name()); err != nil {
+		return fmt.Errorf("completing previous migration: %w", err)
+	}
+
+	current, err := s.currentIndex(ctx, index)
+	if err != nil {
+		return fmt.Errorf("getting current index: %w", err)
+	}
+	next := indexWithVersion(current, current.Version()+1)
+
+	mappings, err := s.client.GetIndexMappings(ctx, current.NameWithVersion())
+	if err != nil {
+		return fmt.Errorf("getting index mappings: %w", mapError(err))
+	}
+
+	// the next index version can exist if a previous migration was started
+	// without persisting its state, in which case it's incomplete
+	exists, err := s.client.IndexExists(ctx, next.NameWithVersion())
+	if err != nil {
+		return mapError(err)
+	}
+	if exists {
+		if err := s.client.DeleteIndex(ctx, []string{next.NameWithVersion()}); err != nil {
+			return fmt.Errorf("deleting incomplete index %s: %w", next.NameWithVersion(), mapError(err))
+		}
+	}
+
+	properties := make(map[string]any, len(mappings.Properties))
+	for field, mapping := range mappings.Properties {
+		properties[field] = mapping
+	}
+	for field, mapping := range changes.retypedColumns {
+		properties[field] = mapping
+	}
+
+	m := &indexMigration{
+		from:          current,
+		to:            next,
+		schemaVersion: changes.schemaVersion,
+		script:        migrationScript(changes, mappings),
+		changesTypes:  len(changes.retypedColumns) > 0,
+	}
+
+	if err := s.client.CreateIndex(ctx, next.NameWithVersion(), map[string]any{
+		"mappings": map[string]any{
+			"dynamic":    mappings.Dynamic,
+			"properties": properties,
+			"_meta":      map[string]any{migrationMetaField: m.state()},
+		},
+		"settings": migrationIndexSettings(changes.indexSettings),
+	}); err != nil {
+		return fmt.Errorf("creating index %s: %w", next.NameWithVersion(), mapError(err))
+	}
+
+	if err := s.startBackfill(ctx, m); err != nil {
+		if deleteErr := s.client.DeleteIndex(ctx, []string{next.NameWithVersion()}); deleteErr != nil {
+			s.logger.Error(deleteErr, "deleting index after failed backfill start", loglib.Fields{"index": next.NameWithVersion()})
+		}
+		return err
+	}
+
+	s.setMigration(index.Name(), m)
+
+	s.logger.Info("started schema index migration", loglib.Fields{
+		"source":      current.NameWithVersion(),
+		"destination": next.NameWithVersion(),
+		"task_id":     m.taskID,
+	})
+	return nil
+}
+
+// discardRetriedMigration deletes the migration in progress of the index with
+// the name on input if it applies the same schema log version. It was started
+// by a previous attempt to apply the schema change, whose schema log was not
+// stored, and completing it would apply the changes twice.
+func (s *Store) discardRetriedMigration(ctx context.Context, name string, schemaVersion int64) error {
+	if err := s.loadMigrations(ctx); err != nil {
+		return err
+	}
+	m := s.getMigration(name)
+	if m == nil || m.aliasSwapped || m.schemaVersion < schemaVersion {
+		return nil
+	}
+
+	m.completeLock.Lock()
+	defer m.completeLock.Unlock()
+	if s.getMigration(name) != m {
+		return nil
+	}
+
+	if err := s.client.DeleteIndex(ctx, []string{m.to.NameWithVersion()}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+		return fmt.Errorf("deleting index %s of retried migration: %w", m.to.NameWithVersion(), mapError(err))
+	}
+	s.removeMigration(name)
+	s.logger.Info("discarded schema index migration of retried schema change", loglib.Fields{
+		"index":          m.to.NameWithVersion(),
+		"schema_version": schemaVersion,
+	})
+	return nil
+}
+
+// startBackfill starts backfilling the new index version of the migration from
+// the previous one, and persists the backfill task in the migration state.
+// Starting it again after a failure is safe, since the document versions are
+// kept.
+func (s *Store) startBackfill(ctx context.Context, m *indexMigration) error {
+	taskID, err := s.client.StartReindex(ctx, &es.ReindexRequest{
+		Source: m.from.NameWithVersion(),
+		Dest:   m.to.NameWithVersion(),
+		// keep the document versions, so that the documents written while the
+		// migration is in progress are not overwritten by older versions
+		DestVersionType: "external",
+		Conflicts:       "proceed",
+		Script:          m.script,
+		Refresh:         true,
+	})
+	if err != nil {
+		return fmt.Errorf("starting backfill of %s into %s: %w", m.from.NameWithVersion(), m.to.NameWithVersion(), mapError(err))
+	}
+	m.taskID = taskID
+	m.attempts++
+
+	// if the task is not persisted, the backfill is started again when the
+	// migration is resumed
+	if err := s.client.PutIndexMappings(ctx, m.to.NameWithVersion(), map[string]any{
+		"_meta": map[string]any{migrationMetaField: m.state()},
+	}); err != nil {
+		s.logger.Warn(err, "persisting schema index migration state", loglib.Fields{
+			"index":   m.to.NameWithVersion(),
+			"task_id": taskID,
+		})
+	}
+	return nil
+}
+
+// state returns the persisted state of the migration.
+func (m *indexMigration) state() migrationState {
+	state := migrationState{
+		Schema:        m.to.SchemaName(),
+		Alias:         m.to.Name(),
+		FromVersion:   m.from.Version(),
+		SchemaVersion: m.schemaVersion,
+		TaskID:        m.taskID,
+		Attempts:      m.attempts,
+		ChangesTypes:  m.changesTypes,
+		Script:        m.script,
+	}
+	if i, ok := m.to.(*tableIndexName); ok {
+		state.TableID = i.tableID
+	}
+	return state
+}
+
+// migration returns the index migration of the persisted state, into the
+// index version on input.
+func (st *migrationState) migration(index string) (*indexMigration, error) {
+	version, err := parseIndexVersion(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var to IndexName = newIndexName(st.Schema, version)
+	if st.TableID != "" {
+		to = &tableIndexName{
+			schemaName: st.Schema,
+			tableID:    st.TableID,
+			alias:      st.Alias,
+			version:    version,
+		}
+	}
+	if to.NameWithVersion() != index {
+		return nil, fmt.Errorf("migration state doesn't match index %s", index)
+	}
+
+	return &indexMigration{
+		from:          indexWithVersion(to, st.FromVersion),
+		to:            to,
+		taskID:        st.TaskID,
+		schemaVersion: st.SchemaVersion,
+		script:        st.Script,
+		attempts:      st.Attempts,
+		changesTypes:  st.ChangesTypes,
+	}, nil
+}
+
+// ResumeSchemaMigrations loads the index migrations that were in progress when
+// the store was last stopped, and returns the names of their schemas, sorted.
+func (s *Store) ResumeSchemaMigrations(ctx context.Context) ([]string, error) {
+	if err := s.loadMigrations(ctx); err != nil {
+		return nil, err
+	}
+
+	s.migrationsLock.RLock()
+	defer s.migrationsLock.RUnlock()
+	schemas := []string{}
+	for _, m := range s.migrations {
+		if !slices.Contains(schemas, m.to.SchemaName()) {
+			schemas = append(schemas, m.to.SchemaName())
+		}
+	}
+	slices.Sort(schemas)
+	return schemas, nil
+}
+
+// loadMigrations loads the persisted index migrations the first time it's
+// called, so that the documents keep being written to both index versions
+// until they're completed. The new index versions whose alias has moved to a
+// different version are deleted.
+func (s *Store) loadMigrations(ctx context.Context) error {
+	s.migrationsLoadLock.Lock()
+	defer s.migrationsLoadLock.Unlock()
+	if s.migrationsLoaded {
+		return nil
+	}
+
+	states, err := s.client.GetIndicesMeta(ctx, "*", migrationMetaField)
+	if err != nil {
+		return fmt.Errorf("loading index migrations: %w", mapError(err))
+	}
+
+	indices := make([]string, 0, len(states))
+	for index := range states {
+		indices = append(indices, index)
+	}
+	slices.Sort(indices)
+	for _, index := range indices {
+		var state migrationState
+		if err := json.Unmarshal(states[index], &state); err != nil {
+			return fmt.Errorf("loading index migration of %s: %w", index, err)
+		}
+		m, err := state.migration(index)
+		if err != nil {
+			return fmt.Errorf("loading index migration of %s: %w", index, err)
+		}
+
+		aliases, err := s.client.GetIndexAlias(ctx, m.to.Name())
+		if err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+			return fmt.Errorf("loading index migration of %s: %w", index, mapError(err))
+		}
+		_, fromAliased := aliases[m.from.NameWithVersion()]
+		_, m.aliasSwapped = aliases[m.to.NameWithVersion()]
+		if !fromAliased && !m.aliasSwapped {
+			s.logger.Warn(nil, "deleting index of stale schema index migration", loglib.Fields{"index": index})
+			if err := s.client.DeleteIndex(ctx, []string{index}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+				return fmt.Errorf("deleting index %s: %w", index, mapError(err))
+			}
+			continue
+		}
+
+		s.setMigration(m.to.Name(), m)
+		s.logger.Info("resumed schema index migration", loglib.Fields{
+			"source":      m.from.NameWithVersion(),
+			"destination": m.to.NameWithVersion(),
+			"task_id":     m.taskID,
+		})
+	}
+
+	s.migrationsLoaded = true
+	return nil
+}
+
+// CompleteSchemaMigration waits for the in progress index migrations of the
+// schema to finish backfilling, moves the index aliases to the new index
+// versions and deletes the previous versions. It's a no-op if there are no
+// migrations in progress for the schema.
+func (s *Store) CompleteSchemaMigration(ctx context.Context, schemaName string) error {
+	if err := s.loadMigrations(ctx); err != nil {
+		return err
+	}
+	for _, name := range s.schemaMigrations(schemaName) {
+		if err := s.completeMigration(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeMigration completes the in progress migration of the index with the
+// name on input, if any.
+func (s *Store) completeMigration(ctx context.Context, name string) error {
+	if err := s.loadMigrations(ctx); err != nil {
+		return err
+	}
+	m := s.getMigration(name)
+	if m == nil {
+		return nil
+	}
+
+	m.completeLock.Lock()
+	defer m.completeLock.Unlock()
+
+	if s.getMigration(name) != m {
+		// completed while waiting for the lock
+		return nil
+	}
+
+	if !m.aliasSwapped {
+		resp, err := s.waitForBackfill(ctx, m)
+		for errors.Is(err, errBackfillFailed) {
+			if m.attempts < maxBackfillAttempts {
+				s.logger.Warn(err, "restarting schema index backfill", loglib.Fields{
+					"source":      m.from.NameWithVersion(),
+					"destination": m.to.NameWithVersion(),
+					"attempts":    m.attempts,
+				})
+				if err := s.startBackfill(ctx, m); err != nil {
+					return err
+				}
+				resp, err = s.waitForBackfill(ctx, m)
+				continue
+			}
+
+			if !m.changesTypes {
+				s.abortMigration(ctx, name, m, err)
+				return nil
+			}
+			// the previous index version can't index the new column types,
+			// so the migration is completed with the documents backfilled so
+			// far
+			s.logger.Error(err, "schema index backfill incomplete, completing migration", loglib.Fields{
+				"severity":    "DATALOSS",
+				"source":      m.from.NameWithVersion(),
+				"destination": m.to.NameWithVersion(),
+			})
+			resp, err = &es.ReindexResponse{}, nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s.logger.Info("schema index backfill completed", loglib.Fields{
+			"source":            m.from.NameWithVersion(),
+			"destination":       m.to.NameWithVersion(),
+			"total":             resp.Total,
+			"created":           resp.Created,
+			"version_conflicts": resp.VersionConflicts,
+		})
+		if m.changesTypes {
+			s.logger.Warn(nil, "the values of the retyped columns were removed from the existing documents, and will be indexed again when the rows are updated", loglib.Fields{
+				"destination": m.to.NameWithVersion(),
+			})
+		}
+		if resp.Noops > 0 {
+			s.logger.Warn(nil, "documents without values for the new identity columns were not reindexed", loglib.Fields{
+				"destination": m.to.NameWithVersion(),
+				"skipped":     resp.Noops,
+			})
+		}
+
+		if err := s.client.UpdateAliases(ctx, []es.AliasAction{
+			{Remove: &es.AliasActionTarget{Index: m.from.NameWithVersion(), Alias: m.from.Name()}},
+			{Add: &es.AliasActionTarget{Index: m.to.NameWithVersion(), Alias: m.to.Name()}},
+		}); err != nil {
+			return fmt.Errorf("moving alias %s to %s: %w", m.to.Name(), m.to.NameWithVersion(), mapError(err))
+		}
+		m.aliasSwapped = true
+
+		// restore the default deleted documents retention once the backfill
+		// is completed
+		if err := s.client.PutIndexSettings(ctx, m.to.NameWithVersion(), map[string]any{
+			"index.gc_deletes": nil,
+		}); err != nil {
+			s.logger.Warn(err, "restoring index settings after migration", loglib.Fields{"index": m.to.NameWithVersion()})
+		}
+	}
+
+	if err := s.client.DeleteIndex(ctx, []string{m.from.NameWithVersion()}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+		return fmt.Errorf("deleting previous index version %s: %w", m.from.NameWithVersion(), mapError(err))
+	}
+
+	// a migration resumed with the alias already moved is completed again,
+	// which is a no-op
+	if err := s.client.PutIndexMappings(ctx, m.to.NameWithVersion(), map[string]any{
+		"_meta": map[string]any{},
+	}); err != nil {
+		s.logger.Warn(err, "clearing schema index migration state", loglib.Fields{"index": m.to.NameWithVersion()})
+	}
+
+	s.removeMigration(name)
+	s.logger.Info("schema index migration completed", loglib.Fields{"index": m.to.NameWithVersion()})
+	return nil
+}
+
+var errBackfillFailed = errors.New("backfill failed")
+
+func (s *Store) waitForBackfill(ctx context.Context, m *indexMigration) (*es.ReindexResponse, error) {
+	// the task is not persisted if the store stopped right after starting it
+	if m.taskID == "" {
+		return nil, fmt.Errorf("%w: missing backfill task", errBackfillFailed)
+	}
+
+	ticker := time.NewTicker(s.backfillPollInterval)
+	defer ticker.Stop()
+	for {
+		task, err := s.client.GetTask(ctx, m.taskID)
+		if err != nil {
+			if errors.Is(err, es.ErrResourceNotFound) {
+				return nil, fmt.Errorf("%w: backfill task %s not found", errBackfillFailed, m.taskID)
+			}
+			return nil, fmt.Errorf("getting backfill task %s: %w", m.taskID, mapError(err))
+		}
+
+		if task.Completed {
+			switch {
+			case len(task.Error) > 0:
+				return nil, fmt.Errorf("%w: %s", errBackfillFailed, task.Error)
+			case task.Response == nil:
+				return nil, fmt.Errorf("%w: missing task response", errBackfillFailed)
+			case len(task.Response.Failures) > 0:
+				return nil, fmt.Errorf("%w: %d documents failed, first failure: %s", errBackfillFailed, len(task.Response.Failures), task.Response.Failures[0])
+			}
+			return task.Response, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// abortMigration stops writing to the new index version and deletes it. The
+// index alias keeps pointing to the previous version. It's only used for
+// migrations that don't change column types, since the previous version can't
+// index the values of the new types.
+func (s *Store) abortMigration(ctx context.Context, name string, m *indexMigration, err error) {
+	s.removeMigration(name)
+	s.logger.Error(err, "schema index migration aborted", loglib.Fields{
+		"severity":    "DATALOSS",
+		"source":      m.from.NameWithVersion(),
+		"destination": m.to.NameWithVersion(),
+	})
+	if err := s.client.DeleteIndex(ctx, []string{m.to.NameWithVersion()}); err != nil {
+		s.logger.Error(err, "deleting index after aborted migration", loglib.Fields{"index": m.to.NameWithVersion()})
+	}
+}
+
+// migrationIndexSettings returns the settings for a new index version. The
+// deleted documents are kept for longer than the default (60s), so that the
+// documents deleted while the migration is in progress are not recreated by
+// the backfill.
+func migrationIndexSettings(indexSettings map[string]any) map[string]any {
+	settings := make(map[string]any, len(indexSettings)+1)
+	for k, v := range indexSettings {
+		settings[k] = v
+	}
+	settings["index.gc_deletes"] = migrationGCDeletes
+	return settings
+}
+
+const migrationGCDeletes = "12h"
+
+// migrationScript returns the reindex script that updates the document IDs of
+// the tables with identity changes and removes the values of the retyped and
+// removed columns, or nil if there are no document changes required.
+//
+// The values of the retyped columns are not kept, since postgres can convert
+// them when the type changes (i.e. with a USING expression), and the previous
+// values might not be valid for the new mapping.
+func migrationScript(changes migrationChanges, mappings *es.Mappings) *es.Script {
+	retypedFields := make([]string, 0, len(changes.retypedColumns))
+	for field := range changes.retypedColumns {
+		retypedFields = append(retypedFields, field)
+	}
+	slices.Sort(retypedFields)
+	removeFields := append(slices.Clone(changes.removedColumns), retypedFields...)
+	if len(changes.identityChanges) == 0 && len(removeFields) == 0 {
+		return nil
+	}
+
+	tables := make(map[string]any, len(changes.identityChanges))
+	for _, change := range changes.identityChanges {
+		table := map[string]any{
+			"columns": change.columns,
+		}
+		if change.previousIDColumn != "" {
+			// the index mapping is strict, so the previous identity column can
+			// only be added to the documents if it's mapped
+			_, mapped := mappings.Properties[change.previousIDColumn]
+			table["previous_id_column"] = change.previousIDColumn
+			table["previous_id_mapped"] = mapped
+		}
+		tables[change.tableID] = table
+	}
+
+	return &es.Script{
+		Source: reindexScript,
+		Lang:   "painless",
+		Params: map[string]any{
+			"tables":        tables,
+			"remove_fields": removeFields,
+		},
+	}
+}
+
+// migrationBulkItems returns the bulk items on input, with an additional copy
+// for the new index version of the indices with migrations in progress. It
+// also returns the alias for the new index versions, used to report failures.
+func (s *Store) migrationBulkItems(items []es.BulkItem) ([]es.BulkItem, map[string]string) {
+	s.migrationsLock.RLock()
+	defer s.migrationsLock.RUnlock()
+	if len(s.migrations) == 0 {
+		return items, nil
+	}
+
+	aliases := map[string]string{}
+	allItems := make([]es.BulkItem, 0, len(items))
+	for _, item := range items {
+		allItems = append(allItems, item)
+		m, found := s.migrations[bulkItemIndex(item)]
+		if !found {
+			continue
+		}
+		aliases[m.to.NameWithVersion()] = m.to.Name()
+		allItems = append(allItems, withIndex(item, m.to.NameWithVersion()))
+	}
+	return allItems, aliases
+}
+
+// migrationFailures updates the failed bulk items on input to report the new
+// index version failures using the schema alias. Failures writing into the
+// previous index version of migrations that change column types are
+// discarded, since it can't index the new types and it will be replaced.
+func (s *Store) migrationFailures(failed []es.BulkItem, aliases map[string]string) []es.BulkItem {
+	if len(aliases) == 0 {
+		return failed
+	}
+
+	s.migrationsLock.RLock()
+	defer s.migrationsLock.RUnlock()
+	failures := make([]es.BulkItem, 0, len(failed))
+	for _, item := range failed {
+		discard := false
+		for _, bi := range []*es.BulkIndex{item.Index, item.Delete} {
+			if bi == nil {
+				continue
+			}
+			if alias, found := aliases[bi.Index]; found {
+				bi.Index = alias
+				continue
+			}
+			if m, found := s.migrations[bi.Index]; found && m.changesTypes {
+				discard = true
+			}
+		}
+		if !discard {
+			failures = append(failures, item)
+		}
+	}
+	return failures
+}
+
+func withIndex(item es.BulkItem, index string) es.BulkItem {
+	copyBulkIndex := func(bi *es.BulkIndex) *es.BulkIndex {
+		if bi == nil {
+			return nil
+		}
+		c := *bi
+		c.Index = index
+		return &c
+	}
+	item.Index = copyBulkIndex(item.Index)
+	item.Delete = copyBulkIndex(item.Delete)
+	return item
+}
+
+func bulkItemIndex(item es.BulkItem) string {
+	if item.Delete != nil {
+		return item.Delete.Index
+	}
+	if item.Index != nil {
+		return item.Index.Index
+	}
+	return ""
+}
+
+// getMigration returns the migration in progress for the index with the name
+// (alias) on input, if any.
+func (s *Store) getMigration(name string) *indexMigration {
+	s.migrationsLock.RLock()
+	defer s.migrationsLock.RUnlock()
+	return s.migrations[name]
+}
+
+func (s *Store) setMigration(name string, m *indexMigration) {
+	s.migrationsLock.Lock()
+	defer s.migrationsLock.Unlock()
+	s.migrations[name] = m
+}
+
+func (s *Store) removeMigration(name string) {
+	s.migrationsLock.Lock()
+	defer s.migrationsLock.Unlock()
+	delete(s.migrations, name)
+}
+
+// schemaMigrations returns the names of the indices of the schema with
+// migrations in progress, sorted.
+func (s *Store) schemaMigrations(schemaName string) []string {
+	s.migrationsLock.RLock()
+	defer s.migrationsLock.RUnlock()
+	names := []string{}
+	for name, m := range s.migrations {
+		if m.to.SchemaName() == schemaName {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// currentIndex returns the index version the alias of the index on input
+// points to. It defaults to the first version if the alias doesn't exist.
+func (s *Store) currentIndex(ctx context.Context, index IndexName) (IndexName, error) {
+	aliases, err := s.client.GetIndexAlias(ctx, index.Name())
+	if err != nil {
+		if errors.Is(err, es.ErrResourceNotFound) {
+			return index, nil
+		}
+		return nil, mapError(err)
+	}
+
+	current := index
+	for name := range aliases {
+		version, err := parseIndexVersion(name)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected index %s for alias %s: %w", name, index.Name(), err)
+		}
+		if version > current.Version() {
+			current = indexWithVersion(index, version)
+		}
+	}
+	return current, nil
+}
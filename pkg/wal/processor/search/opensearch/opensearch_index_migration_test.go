@@ -0,0 +1,907 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func Test_getIdentityChanges(t *testing.T) {
+	t.Parallel()
+
+	testColumns := []schemalog.Column{
+		{Name: "id", PgstreamID: "t1-1"},
+		{Name: "email", PgstreamID: "t1-2", Unique: true},
+		{Name: "tenant", PgstreamID: "t1-3"},
+	}
+	newLogEntry := func(pk []string, uniqueNotNull bool) *schemalog.LogEntry {
+		columns := make([]schemalog.Column, len(testColumns))
+		copy(columns, testColumns)
+		columns[1].Nullable = !uniqueNotNull
+		return &schemalog.LogEntry{
+			Schema: schemalog.Schema{
+				Tables: []schemalog.Table{
+					{Name: "test", PgstreamID: "t1", Columns: columns, PrimaryKeyColumns: pk},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		newEntry      *schemalog.LogEntry
+		previousEntry *schemalog.LogEntry
+
+		wantChanges []identityChange
+	}{
+		{
+			name:          "no previous entry",
+			newEntry:      newLogEntry([]string{"id"}, false),
+			previousEntry: nil,
+
+			wantChanges: nil,
+		},
+		{
+			name:          "no identity changes",
+			newEntry:      newLogEntry([]string{"id"}, false),
+			previousEntry: newLogEntry([]string{"id"}, false),
+
+			wantChanges: []identityChange{},
+		},
+		{
+			name:          "single primary key to composite primary key",
+			newEntry:      newLogEntry([]string{"tenant", "id"}, false),
+			previousEntry: newLogEntry([]string{"id"}, false),
+
+			wantChanges: []identityChange{
+				{tableID: "t1", columns: []string{"t1-1", "t1-3"}, previousIDColumn: "t1-1"},
+			},
+		},
+		{
+			name:          "composite primary key to unique not null column",
+			newEntry:      newLogEntry(nil, true),
+			previousEntry: newLogEntry([]string{"id", "tenant"}, true),
+
+			wantChanges: []identityChange{
+				{tableID: "t1", columns: []string{"t1-2"}},
+			},
+		},
+		{
+			name:          "unique not null column to primary key",
+			newEntry:      newLogEntry([]string{"id"}, true),
+			previousEntry: newLogEntry(nil, true),
+
+			wantChanges: []identityChange{
+				{tableID: "t1", columns: []string{"t1-1"}, previousIDColumn: "t1-2"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			changes := getIdentityChanges(tc.newEntry, tc.previousEntry, tc.newEntry.Diff(tc.previousEntry))
+			require.Equal(t, tc.wantChanges, changes)
+		})
+	}
+}
+
+func TestStore_startMigration(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testChanges := migrationChanges{
+		schemaVersion: 5,
+		identityChanges: []identityChange{
+			{tableID: "t1", columns: []string{"t1-1", "t1-3"}, previousIDColumn: "t1-1"},
+		},
+		retypedColumns: map[string]any{
+			"t1-4": map[string]any{"type": "long"},
+		},
+		removedColumns: []string{"t1-5"},
+	}
+	testMappings := &es.Mappings{
+		Dynamic: "strict",
+		Properties: map[string]any{
+			"_table": map[string]any{"type": "keyword"},
+			"t1-1":   map[string]any{"type": "long"},
+			"t1-3":   map[string]any{"type": "keyword"},
+			"t1-4":   map[string]any{"type": "keyword"},
+			"t1-5":   map[string]any{"type": "keyword"},
+		},
+	}
+	testScript := migrationScript(testChanges, testMappings)
+	testState := migrationState{
+		Schema:        testSchemaName,
+		Alias:         testSchemaName,
+		FromVersion:   2,
+		SchemaVersion: 5,
+		ChangesTypes:  true,
+		Script:        testScript,
+	}
+	errTest := errors.New("oh noes")
+
+	getIndexAliasFn := func(ctx context.Context, name string) (map[string]any, error) {
+		require.Equal(t, testSchemaName, name)
+		return map[string]any{"test_schema-2": map[string]any{}}, nil
+	}
+	getIndexMappingsFn := func(ctx context.Context, index string) (*es.Mappings, error) {
+		require.Equal(t, "test_schema-2", index)
+		return testMappings, nil
+	}
+	indexExistsFn := func(exists bool) func(context.Context, string) (bool, error) {
+		return func(ctx context.Context, index string) (bool, error) {
+			require.Equal(t, "test_schema-3", index)
+			return exists, nil
+		}
+	}
+	createIndexFn := func(ctx context.Context, index string, body map[string]any) error {
+		require.Equal(t, "test_schema-3", index)
+		require.Equal(t, map[string]any{
+			"dynamic": "strict",
+			"properties": map[string]any{
+				"_table": map[string]any{"type": "keyword"},
+				"t1-1":   map[string]any{"type": "long"},
+				"t1-3":   map[string]any{"type": "keyword"},
+				"t1-4":   map[string]any{"type": "long"},
+				"t1-5":   map[string]any{"type": "keyword"},
+			},
+			"_meta": map[string]any{migrationMetaField: testState},
+		}, body["mappings"])
+		require.Equal(t, migrationGCDeletes, body["settings"].(map[string]any)["index.gc_deletes"])
+		return nil
+	}
+	startReindexFn := func(ctx context.Context, req *es.ReindexRequest) (string, error) {
+		require.Equal(t, "test_schema-2", req.Source)
+		require.Equal(t, "test_schema-3", req.Dest)
+		require.Equal(t, "external", req.DestVersionType)
+		require.Equal(t, "proceed", req.Conflicts)
+		require.Equal(t, map[string]any{
+			"tables": map[string]any{
+				"t1": map[string]any{
+					"columns":            []string{"t1-1", "t1-3"},
+					"previous_id_column": "t1-1",
+					"previous_id_mapped": true,
+				},
+			},
+			"remove_fields": []string{"t1-5", "t1-4"},
+		}, req.Script.Params)
+		return "task-1", nil
+	}
+	putIndexMappingsFn := func(ctx context.Context, index string, body map[string]any) error {
+		require.Equal(t, "test_schema-3", index)
+		state := testState
+		state.TaskID = "task-1"
+		state.Attempts = 1
+		require.Equal(t, map[string]any{
+			"_meta": map[string]any{migrationMetaField: state},
+		}, body)
+		return nil
+	}
+	wantMigration := &indexMigration{
+		from:          newIndexName(testSchemaName, 2),
+		to:            newIndexName(testSchemaName, 3),
+		taskID:        "task-1",
+		schemaVersion: 5,
+		script:        testScript,
+		attempts:      1,
+		changesTypes:  true,
+	}
+
+	tests := []struct {
+		name      string
+		client    func(deletedIndices *[]string) *esmocks.Client
+		migration *indexMigration
+
+		wantMigration      *indexMigration
+		wantDeletedIndices []string
+		wantErr            error
+	}{
+		{
+			name: "ok",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn:   noIndexMigrations,
+					GetIndexAliasFn:    getIndexAliasFn,
+					GetIndexMappingsFn: getIndexMappingsFn,
+					IndexExistsFn:      indexExistsFn(false),
+					CreateIndexFn:      createIndexFn,
+					StartReindexFn:     startReindexFn,
+					PutIndexMappingsFn: putIndexMappingsFn,
+				}
+			},
+
+			wantMigration:      wantMigration,
+			wantDeletedIndices: []string{},
+		},
+		{
+			name: "ok - migration of retried schema change discarded",
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn:   noIndexMigrations,
+					GetIndexAliasFn:    getIndexAliasFn,
+					GetIndexMappingsFn: getIndexMappingsFn,
+					IndexExistsFn:      indexExistsFn(false),
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+					CreateIndexFn:      createIndexFn,
+					StartReindexFn:     startReindexFn,
+					PutIndexMappingsFn: putIndexMappingsFn,
+				}
+			},
+			migration: &indexMigration{
+				from:          newIndexName(testSchemaName, 2),
+				to:            newIndexName(testSchemaName, 3),
+				taskID:        "task-0",
+				schemaVersion: 5,
+			},
+
+			wantMigration:      wantMigration,
+			wantDeletedIndices: []string{"test_schema-3"},
+		},
+		{
+			name: "ok - incomplete index from previous migration",
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn:   noIndexMigrations,
+					GetIndexAliasFn:    getIndexAliasFn,
+					GetIndexMappingsFn: getIndexMappingsFn,
+					IndexExistsFn:      indexExistsFn(true),
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+					CreateIndexFn:      createIndexFn,
+					StartReindexFn:     startReindexFn,
+					PutIndexMappingsFn: putIndexMappingsFn,
+				}
+			},
+
+			wantMigration:      wantMigration,
+			wantDeletedIndices: []string{"test_schema-3"},
+		},
+		{
+			name: "error - creating index",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn:   noIndexMigrations,
+					GetIndexAliasFn:    getIndexAliasFn,
+					GetIndexMappingsFn: getIndexMappingsFn,
+					IndexExistsFn:      indexExistsFn(false),
+					CreateIndexFn: func(ctx context.Context, index string, body map[string]any) error {
+						return errTest
+					},
+				}
+			},
+
+			wantDeletedIndices: []string{},
+			wantErr:            errTest,
+		},
+		{
+			name: "error - starting backfill",
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn:   noIndexMigrations,
+					GetIndexAliasFn:    getIndexAliasFn,
+					GetIndexMappingsFn: getIndexMappingsFn,
+					IndexExistsFn:      indexExistsFn(false),
+					CreateIndexFn:      createIndexFn,
+					StartReindexFn: func(ctx context.Context, req *es.ReindexRequest) (string, error) {
+						return "", errTest
+					},
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+				}
+			},
+
+			wantDeletedIndices: []string{"test_schema-3"},
+			wantErr:            errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			deletedIndices := []string{}
+			s := NewStoreWithClient(tc.client(&deletedIndices))
+			if tc.migration != nil {
+				s.setMigration(testSchemaName, tc.migration)
+			}
+			err := s.startMigration(context.Background(), newDefaultIndexName(testSchemaName), testChanges)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantDeletedIndices, deletedIndices)
+			require.Equal(t, tc.wantMigration, s.getMigration(testSchemaName))
+		})
+	}
+}
+
+func TestStore_CompleteSchemaMigration(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	errTest := errors.New("oh noes")
+
+	newTestMigration := func() *indexMigration {
+		return &indexMigration{
+			from:     newIndexName(testSchemaName, 1),
+			to:       newIndexName(testSchemaName, 2),
+			taskID:   "task-1",
+			attempts: 1,
+		}
+	}
+	exhaustedMigration := func(changesTypes bool) *indexMigration {
+		m := newTestMigration()
+		m.attempts = maxBackfillAttempts
+		m.changesTypes = changesTypes
+		return m
+	}
+	failedTask := &es.Task{
+		Completed: true,
+		Response: &es.ReindexResponse{
+			Failures: []json.RawMessage{[]byte(`{"cause":"oh noes"}`)},
+		},
+	}
+
+	getTaskFn := func(task *es.Task) func(context.Context, string) (*es.Task, error) {
+		calls := 0
+		return func(ctx context.Context, taskID string) (*es.Task, error) {
+			require.Equal(t, "task-1", taskID)
+			calls++
+			// the first call returns the task as in progress
+			if calls == 1 {
+				return &es.Task{Completed: false}, nil
+			}
+			return task, nil
+		}
+	}
+	updateAliasesFn := func(ctx context.Context, actions []es.AliasAction) error {
+		require.Equal(t, []es.AliasAction{
+			{Remove: &es.AliasActionTarget{Index: "test_schema-1", Alias: testSchemaName}},
+			{Add: &es.AliasActionTarget{Index: "test_schema-2", Alias: testSchemaName}},
+		}, actions)
+		return nil
+	}
+	putIndexSettingsFn := func(ctx context.Context, index string, body map[string]any) error {
+		require.Equal(t, "test_schema-2", index)
+		require.Equal(t, map[string]any{"index.gc_deletes": nil}, body)
+		return nil
+	}
+	clearStateFn := func(ctx context.Context, index string, body map[string]any) error {
+		require.Equal(t, "test_schema-2", index)
+		require.Equal(t, map[string]any{"_meta": map[string]any{}}, body)
+		return nil
+	}
+
+	tests := []struct {
+		name      string
+		migration *indexMigration
+		client    func(deletedIndices *[]string) *esmocks.Client
+
+		wantMigration      bool
+		wantDeletedIndices []string
+		wantErr            error
+	}{
+		{
+			name:      "ok - no migration in progress",
+			migration: nil,
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{GetIndicesMetaFn: noIndexMigrations}
+			},
+
+			wantDeletedIndices: []string{},
+		},
+		{
+			name:      "ok",
+			migration: newTestMigration(),
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn: getTaskFn(&es.Task{
+						Completed: true,
+						Response:  &es.ReindexResponse{Total: 2, Created: 2},
+					}),
+					UpdateAliasesFn:    updateAliasesFn,
+					PutIndexSettingsFn: putIndexSettingsFn,
+					PutIndexMappingsFn: clearStateFn,
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+				}
+			},
+
+			wantDeletedIndices: []string{"test_schema-1"},
+		},
+		{
+			name:      "ok - backfill failed, backfill restarted",
+			migration: newTestMigration(),
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn: func(ctx context.Context, taskID string) (*es.Task, error) {
+						if taskID == "task-1" {
+							return failedTask, nil
+						}
+						require.Equal(t, "task-2", taskID)
+						return &es.Task{Completed: true, Response: &es.ReindexResponse{Total: 2, Created: 2}}, nil
+					},
+					StartReindexFn: func(ctx context.Context, req *es.ReindexRequest) (string, error) {
+						require.Equal(t, "test_schema-1", req.Source)
+						require.Equal(t, "test_schema-2", req.Dest)
+						require.Equal(t, "external", req.DestVersionType)
+						return "task-2", nil
+					},
+					PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						require.Equal(t, "test_schema-2", index)
+						meta := body["_meta"].(map[string]any)
+						if state, found := meta[migrationMetaField]; found {
+							require.Equal(t, "task-2", state.(migrationState).TaskID)
+							require.Equal(t, 2, state.(migrationState).Attempts)
+						}
+						return nil
+					},
+					UpdateAliasesFn:    updateAliasesFn,
+					PutIndexSettingsFn: putIndexSettingsFn,
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+				}
+			},
+
+			wantDeletedIndices: []string{"test_schema-1"},
+		},
+		{
+			name:      "ok - backfill failed after max attempts, migration aborted",
+			migration: exhaustedMigration(false),
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn:        getTaskFn(failedTask),
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+				}
+			},
+
+			wantDeletedIndices: []string{"test_schema-2"},
+		},
+		{
+			name:      "ok - backfill failed after max attempts, retyped migration completed",
+			migration: exhaustedMigration(true),
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn:   noIndexMigrations,
+					GetTaskFn:          getTaskFn(failedTask),
+					UpdateAliasesFn:    updateAliasesFn,
+					PutIndexSettingsFn: putIndexSettingsFn,
+					PutIndexMappingsFn: clearStateFn,
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+				}
+			},
+
+			wantDeletedIndices: []string{"test_schema-1"},
+		},
+		{
+			name:      "ok - backfill task not found, backfill restarted",
+			migration: newTestMigration(),
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn: func(ctx context.Context, taskID string) (*es.Task, error) {
+						if taskID == "task-1" {
+							return nil, es.ErrResourceNotFound
+						}
+						return &es.Task{Completed: true, Response: &es.ReindexResponse{}}, nil
+					},
+					StartReindexFn: func(ctx context.Context, req *es.ReindexRequest) (string, error) {
+						return "task-2", nil
+					},
+					PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						return nil
+					},
+					UpdateAliasesFn:    updateAliasesFn,
+					PutIndexSettingsFn: putIndexSettingsFn,
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+				}
+			},
+
+			wantDeletedIndices: []string{"test_schema-1"},
+		},
+		{
+			name:      "error - restarting backfill",
+			migration: newTestMigration(),
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn:        getTaskFn(failedTask),
+					StartReindexFn: func(ctx context.Context, req *es.ReindexRequest) (string, error) {
+						return "", errTest
+					},
+				}
+			},
+
+			wantMigration:      true,
+			wantDeletedIndices: []string{},
+			wantErr:            errTest,
+		},
+		{
+			name:      "error - getting task",
+			migration: newTestMigration(),
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn: func(ctx context.Context, taskID string) (*es.Task, error) {
+						return nil, errTest
+					},
+				}
+			},
+
+			wantMigration:      true,
+			wantDeletedIndices: []string{},
+			wantErr:            errTest,
+		},
+		{
+			name:      "error - moving alias",
+			migration: newTestMigration(),
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn: getTaskFn(&es.Task{
+						Completed: true,
+						Response:  &es.ReindexResponse{},
+					}),
+					UpdateAliasesFn: func(ctx context.Context, actions []es.AliasAction) error {
+						return errTest
+					},
+				}
+			},
+
+			wantMigration:      true,
+			wantDeletedIndices: []string{},
+			wantErr:            errTest,
+		},
+		{
+			name:      "error - deleting previous index version",
+			migration: newTestMigration(),
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn: getTaskFn(&es.Task{
+						Completed: true,
+						Response:  &es.ReindexResponse{},
+					}),
+					UpdateAliasesFn:    updateAliasesFn,
+					PutIndexSettingsFn: putIndexSettingsFn,
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						return errTest
+					},
+				}
+			},
+
+			wantMigration:      true,
+			wantDeletedIndices: []string{},
+			wantErr:            errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			deletedIndices := []string{}
+			s := NewStoreWithClient(tc.client(&deletedIndices))
+			s.backfillPollInterval = time.Millisecond
+			if tc.migration != nil {
+				s.setMigration(testSchemaName, tc.migration)
+			}
+
+			err := s.CompleteSchemaMigration(context.Background(), testSchemaName)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantDeletedIndices, deletedIndices)
+			require.Equal(t, tc.wantMigration, s.getMigration(testSchemaName) != nil)
+		})
+	}
+}
+
+func TestStore_ResumeSchemaMigrations(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	errTest := errors.New("oh noes")
+
+	testState := func(st migrationState) map[string]json.RawMessage {
+		index := newIndexName(st.Schema, 2).NameWithVersion()
+		if st.TableID != "" {
+			index = (&tableIndexName{schemaName: st.Schema, tableID: st.TableID, alias: st.Alias, version: 2}).NameWithVersion()
+		}
+		raw, err := json.Marshal(st)
+		require.NoError(t, err)
+		return map[string]json.RawMessage{index: raw}
+	}
+	schemaState := migrationState{
+		Schema:        testSchemaName,
+		Alias:         testSchemaName,
+		FromVersion:   1,
+		SchemaVersion: 3,
+		TaskID:        "task-1",
+		Attempts:      1,
+	}
+	getIndicesMetaFn := func(states map[string]json.RawMessage) func(context.Context, string, string) (map[string]json.RawMessage, error) {
+		return func(ctx context.Context, pattern, field string) (map[string]json.RawMessage, error) {
+			require.Equal(t, "*", pattern)
+			require.Equal(t, migrationMetaField, field)
+			return states, nil
+		}
+	}
+	getIndexAliasFn := func(index string) func(context.Context, string) (map[string]any, error) {
+		return func(ctx context.Context, name string) (map[string]any, error) {
+			return map[string]any{index: map[string]any{}}, nil
+		}
+	}
+
+	tests := []struct {
+		name   string
+		client func(deletedIndices *[]string) *esmocks.Client
+
+		wantSchemas        []string
+		wantMigrations     map[string]*indexMigration
+		wantDeletedIndices []string
+		wantErr            error
+	}{
+		{
+			name: "ok - no migrations",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{GetIndicesMetaFn: noIndexMigrations}
+			},
+
+			wantSchemas:        []string{},
+			wantMigrations:     map[string]*indexMigration{},
+			wantDeletedIndices: []string{},
+		},
+		{
+			name: "ok - migration in progress",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: getIndicesMetaFn(testState(schemaState)),
+					GetIndexAliasFn:  getIndexAliasFn("test_schema-1"),
+				}
+			},
+
+			wantSchemas: []string{testSchemaName},
+			wantMigrations: map[string]*indexMigration{
+				testSchemaName: {
+					from:          newIndexName(testSchemaName, 1),
+					to:            newIndexName(testSchemaName, 2),
+					taskID:        "task-1",
+					schemaVersion: 3,
+					attempts:      1,
+				},
+			},
+			wantDeletedIndices: []string{},
+		},
+		{
+			name: "ok - migration with alias moved",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: getIndicesMetaFn(testState(schemaState)),
+					GetIndexAliasFn:  getIndexAliasFn("test_schema-2"),
+				}
+			},
+
+			wantSchemas: []string{testSchemaName},
+			wantMigrations: map[string]*indexMigration{
+				testSchemaName: {
+					from:          newIndexName(testSchemaName, 1),
+					to:            newIndexName(testSchemaName, 2),
+					taskID:        "task-1",
+					schemaVersion: 3,
+					attempts:      1,
+					aliasSwapped:  true,
+				},
+			},
+			wantDeletedIndices: []string{},
+		},
+		{
+			name: "ok - table index migration",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: getIndicesMetaFn(testState(migrationState{
+						Schema:       testSchemaName,
+						TableID:      "t1",
+						Alias:        "test_schema.users",
+						FromVersion:  1,
+						TaskID:       "task-1",
+						Attempts:     1,
+						ChangesTypes: true,
+					})),
+					GetIndexAliasFn: func(ctx context.Context, name string) (map[string]any, error) {
+						require.Equal(t, "test_schema.users", name)
+						return map[string]any{"test_schema.t1-1": map[string]any{}}, nil
+					},
+				}
+			},
+
+			wantSchemas: []string{testSchemaName},
+			wantMigrations: map[string]*indexMigration{
+				"test_schema.users": {
+					from:         &tableIndexName{schemaName: testSchemaName, tableID: "t1", alias: "test_schema.users", version: 1},
+					to:           &tableIndexName{schemaName: testSchemaName, tableID: "t1", alias: "test_schema.users", version: 2},
+					taskID:       "task-1",
+					attempts:     1,
+					changesTypes: true,
+				},
+			},
+			wantDeletedIndices: []string{},
+		},
+		{
+			name: "ok - stale migration index deleted",
+			client: func(deletedIndices *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: getIndicesMetaFn(testState(schemaState)),
+					GetIndexAliasFn:  getIndexAliasFn("test_schema-3"),
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						*deletedIndices = append(*deletedIndices, index...)
+						return nil
+					},
+				}
+			},
+
+			wantSchemas:        []string{},
+			wantMigrations:     map[string]*indexMigration{},
+			wantDeletedIndices: []string{"test_schema-2"},
+		},
+		{
+			name: "error - getting migration states",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: func(ctx context.Context, pattern, field string) (map[string]json.RawMessage, error) {
+						return nil, errTest
+					},
+				}
+			},
+
+			wantMigrations:     map[string]*indexMigration{},
+			wantDeletedIndices: []string{},
+			wantErr:            errTest,
+		},
+		{
+			name: "error - getting index alias",
+			client: func(_ *[]string) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: getIndicesMetaFn(testState(schemaState)),
+					GetIndexAliasFn: func(ctx context.Context, name string) (map[string]any, error) {
+						return nil, errTest
+					},
+				}
+			},
+
+			wantMigrations:     map[string]*indexMigration{},
+			wantDeletedIndices: []string{},
+			wantErr:            errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			deletedIndices := []string{}
+			s := NewStoreWithClient(tc.client(&deletedIndices))
+			schemas, err := s.ResumeSchemaMigrations(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantSchemas, schemas)
+			require.Equal(t, tc.wantMigrations, s.migrations)
+			require.Equal(t, tc.wantDeletedIndices, deletedIndices)
+		})
+	}
+}
+
+func TestStore_SendDocuments_migration(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testDocs := []search.Document{
+		{ID: "doc-1", Schema: testSchemaName, Version: 1},
+		{ID: "doc-2", Schema: "other_schema", Version: 1, Delete: true},
+	}
+	testDocErr := search.DocumentError{
+		Document: search.Document{ID: "doc-1", Schema: testSchemaName, Version: 1},
+		Severity: search.SeverityDataLoss,
+		Error:    "oh noes",
+	}
+
+	// failedItem returns the index of the bulk item that fails
+	sendBulkRequestFn := func(failedItem int) func(context.Context, []es.BulkItem) ([]es.BulkItem, error) {
+		return func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
+			require.Len(t, items, 3)
+			require.Equal(t, testSchemaName, items[0].Index.Index)
+			require.Equal(t, "test_schema-2", items[1].Index.Index)
+			require.Equal(t, "other_schema", items[2].Delete.Index)
+			return []es.BulkItem{
+				{
+					Index:  items[failedItem].Index,
+					Status: http.StatusBadRequest,
+					Error:  []byte("oh noes"),
+				},
+			}, nil
+		}
+	}
+
+	tests := []struct {
+		name         string
+		failedItem   int
+		changesTypes bool
+
+		wantFailed []search.DocumentError
+	}{
+		{
+			name:       "new index version failure",
+			failedItem: 1,
+
+			wantFailed: []search.DocumentError{testDocErr},
+		},
+		{
+			name:       "previous index version failure",
+			failedItem: 0,
+
+			wantFailed: []search.DocumentError{testDocErr},
+		},
+		{
+			name:         "previous index version failure with type changes",
+			failedItem:   0,
+			changesTypes: true,
+
+			wantFailed: []search.DocumentError{},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewStoreWithClient(&esmocks.Client{
+				GetIndicesMetaFn:  noIndexMigrations,
+				SendBulkRequestFn: sendBulkRequestFn(tc.failedItem),
+			})
+			s.setMigration(testSchemaName, &indexMigration{
+				from:         newIndexName(testSchemaName, 1),
+				to:           newIndexName(testSchemaName, 2),
+				changesTypes: tc.changesTypes,
+			})
+
+			failed, err := s.SendDocuments(context.Background(), testDocs)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantFailed, failed)
+		})
+	}
+}
@@ -4,6 +4,8 @@ package opensearch
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // IndexName represents an opensearch index name constructed from a schema name.
@@ -20,9 +22,13 @@ type indexName struct {
 }
 
 func newDefaultIndexName(schemaName string) IndexName {
+	return newIndexName(schemaName, 1)
+}
+
+func newIndexName(schemaName string, version int) IndexName {
 	return &indexName{
 		schemaName: schemaName,
-		version:    1,
+		version:    version,
 	}
 }
 
@@ -44,3 +50,72 @@ func (i *indexName) Name() string {
 func (i *indexName) Version() int {
 	return i.version
 }
+
+// tableIndexName is the name of the index of a table, used when the store has
+// an index per table. The index versions are always named after the table
+// pgstream id, which doesn't change when the table is renamed, while the name
+// used for querying can be based on the table name.
+type tableIndexName struct {
+	schemaName string
+	tableID    string
+	alias      string
+	version    int
+}
+
+func newTableIndexName(schemaName, tableID, alias string) IndexName {
+	return &tableIndexName{
+		schemaName: schemaName,
+		tableID:    tableID,
+		alias:      alias,
+		version:    1,
+	}
+}
+
+func (i *tableIndexName) SchemaName() string {
+	return i.schemaName
+}
+
+// NameWithVersion returns the name of the index version, with format
+// <schema>.<table_id>-<version>.
+func (i *tableIndexName) NameWithVersion() string {
+	return fmt.Sprintf("%s-%d", tableIndexPrefix(i.schemaName, i.tableID), i.version)
+}
+
+// Name returns the name we should use for querying the index, with format
+// <schema>.<table_id> or <schema>.<table_name>, depending on the index layout.
+func (i *tableIndexName) Name() string {
+	return i.alias
+}
+
+func (i *tableIndexName) Version() int {
+	return i.version
+}
+
+func tableIndexPrefix(schemaName, table string) string {
+	return fmt.Sprintf("%s.%s", schemaName, table)
+}
+
+// indexWithVersion returns a copy of the index name on input, with the given
+// version.
+func indexWithVersion(index IndexName, version int) IndexName {
+	if i, ok := index.(*tableIndexName); ok {
+		c := *i
+		c.version = version
+		return &c
+	}
+	return newIndexName(index.SchemaName(), version)
+}
+
+// parseIndexVersion returns the version of the index name on input, with
+// format <name>-<version>.
+func parseIndexVersion(name string) (int, error) {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return 0, fmt.Errorf("missing version in index name %s", name)
+	}
+	version, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return 0, fmt.Errorf("invalid version in index name %s: %w", name, err)
+	}
+	return version, nil
+}
@@ -0,0 +1,415 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xataio/pgstream/internal/es"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+const (
+	defaultJSONMaxDepth  = 10
+	defaultJSONMaxFields = 100
+
+	// jsonFlattenedField is the subfield of the object and nested JSON
+	// columns where the values that exceed their limits are indexed.
+	jsonFlattenedField = "_flattened"
+
+	// openSearchFlattenedType is the OpenSearch type for flattened objects.
+	// Elasticsearch uses `flattened`.
+	openSearchFlattenedType = "flat_object"
+)
+
+var errUnsupportedJSONMode = errors.New("unsupported json mode")
+
+func (m *JSONMapping) validate() error {
+	switch m.Mode {
+	case "", JSONAsText, JSONAsFlattened:
+		if len(m.Fields) > 0 {
+			return fmt.Errorf("subfields are only supported with the %q and %q json modes", JSONAsObject, JSONAsNested)
+		}
+	case JSONAsObject, JSONAsNested:
+	default:
+		return fmt.Errorf("%w: %q", errUnsupportedJSONMode, m.Mode)
+	}
+	if m.MaxDepth < 0 || m.MaxFields < 0 {
+		return errors.New("json limits can't be negative")
+	}
+	return nil
+}
+
+func (m *JSONMapping) structured() bool {
+	return m.Mode == JSONAsObject || m.Mode == JSONAsNested
+}
+
+func (m *JSONMapping) maxDepth() int {
+	if m.MaxDepth == 0 {
+		return defaultJSONMaxDepth
+	}
+	return m.MaxDepth
+}
+
+func (m *JSONMapping) maxFields() int {
+	if m.MaxFields == 0 {
+		return defaultJSONMaxFields
+	}
+	return m.MaxFields
+}
+
+// searchMapping returns the search mapping for the JSON column. The object and
+// nested mappings are dynamic, with the known subfields mapped explicitly,
+// and a flattened subfield for the values that exceed the limits.
+func (m *JSONMapping) searchMapping(flattenedType string) map[string]any {
+	switch m.Mode {
+	case JSONAsFlattened:
+		return map[string]any{"type": flattenedType}
+	case JSONAsObject, JSONAsNested:
+		properties := map[string]any{
+			jsonFlattenedField: map[string]any{"type": flattenedType},
+		}
+		for path, mapping := range m.Fields {
+			addSubfieldMapping(properties, strings.Split(path, "."), mapping)
+		}
+		return map[string]any{
+			"type":       string(m.Mode),
+			"dynamic":    true,
+			"properties": properties,
+		}
+	default:
+		return map[string]any{"type": "text"}
+	}
+}
+
+func addSubfieldMapping(properties map[string]any, path []string, mapping map[string]any) {
+	if len(path) == 1 {
+		properties[path[0]] = mapping
+		return
+	}
+	parent, ok := properties[path[0]].(map[string]any)
+	if !ok {
+		parent = map[string]any{}
+		properties[path[0]] = parent
+	}
+	parentProperties, ok := parent["properties"].(map[string]any)
+	if !ok {
+		parentProperties = map[string]any{}
+		parent["properties"] = parentProperties
+	}
+	addSubfieldMapping(parentProperties, path[1:], mapping)
+}
+
+func isJSONType(dataType string) bool {
+	return dataType == "json" || dataType == "jsonb"
+}
+
+// hasJSONMappings returns true if any of the column overrides indexes JSON
+// columns in a mode other than text.
+func (s *Store) hasJSONMappings() bool {
+	if s.mappingConfig == nil {
+		return false
+	}
+	for _, o := range s.mappingConfig.Columns {
+		if o.JSON != nil && o.JSON.Mode != "" && o.JSON.Mode != JSONAsText {
+			return true
+		}
+	}
+	return false
+}
+
+// mapJSONColumns returns the document on input with the values of its JSON
+// columns converted according to their mapping. The document data is copied
+// if any values change, since documents can be sent more than once.
+func (s *Store) mapJSONColumns(ctx context.Context, doc search.Document) (search.Document, error) {
+	if doc.Delete || !s.hasJSONMappings() {
+		return doc, nil
+	}
+
+	columns, err := s.schemaJSONColumns(ctx, doc.Schema)
+	if err != nil {
+		return doc, err
+	}
+
+	var data map[string]any
+	for columnID, mapping := range columns {
+		value, found := doc.Data[columnID]
+		if !found || value == nil {
+			continue
+		}
+		if data == nil {
+			data = make(map[string]any, len(doc.Data))
+			for k, v := range doc.Data {
+				data[k] = v
+			}
+		}
+
+		if err := s.loadJSONFields(ctx, doc, columnID, mapping); err != nil {
+			return doc, err
+		}
+
+		mapped, err := s.mapJSONValue(doc.Schema, columnID, mapping, value)
+		if err != nil {
+			s.logger.Warn(err, "opensearch store: skipping json column value", loglib.Fields{
+				"schema":    doc.Schema,
+				"id":        doc.ID,
+				"column_id": columnID,
+			})
+			delete(data, columnID)
+			continue
+		}
+		data[columnID] = mapped
+	}
+
+	if data != nil {
+		doc.Data = data
+	}
+	return doc, nil
+}
+
+func (s *Store) mapJSONValue(schemaName, columnID string, mapping *JSONMapping, value any) (any, error) {
+	// json values are received as text
+	if text, ok := value.(string); ok {
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return nil, fmt.Errorf("parsing json value: %w", err)
+		}
+	}
+
+	if !isJSONObject(value) {
+		return nil, fmt.Errorf("json value of type %T can't be indexed as %s", value, mapping.Mode)
+	}
+
+	if !mapping.structured() {
+		return value, nil
+	}
+
+	paths := map[string]struct{}{}
+	depth := jsonLeafPaths(value, "", 0, paths)
+	if depth > mapping.maxDepth() || !s.trackJSONFields(schemaName, columnID, mapping, paths) {
+		return map[string]any{jsonFlattenedField: value}, nil
+	}
+	return value, nil
+}
+
+// isJSONObject returns true if the JSON value is an object or an array of
+// objects, which are the values that can be indexed in object, nested and
+// flattened fields.
+func isJSONObject(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return true
+	case []any:
+		for _, e := range v {
+			if _, ok := e.(map[string]any); !ok {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonLeafPaths adds the dotted paths of the leaf values of the JSON value on
+// input to the paths map, and returns its object depth. Arrays don't add to
+// the depth, since their values are mapped to the same field.
+func jsonLeafPaths(value any, prefix string, depth int, paths map[string]struct{}) int {
+	switch v := value.(type) {
+	case map[string]any:
+		maxDepth := depth + 1
+		for key, child := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			if d := jsonLeafPaths(child, path, depth+1, paths); d > maxDepth {
+				maxDepth = d
+			}
+		}
+		return maxDepth
+	case []any:
+		maxDepth := depth
+		for _, child := range v {
+			if d := jsonLeafPaths(child, prefix, depth, paths); d > maxDepth {
+				maxDepth = d
+			}
+		}
+		return maxDepth
+	default:
+		if prefix != "" {
+			paths[prefix] = struct{}{}
+		}
+		return depth
+	}
+}
+
+// loadJSONFields seeds the fields tracked for the object or nested JSON column
+// with the subfields already mapped in the document index, the first time the
+// column is seen (i.e. after a restart), so that its max fields apply to the
+// index mapping and not only to the fields mapped since the store started.
+func (s *Store) loadJSONFields(ctx context.Context, doc search.Document, columnID string, mapping *JSONMapping) error {
+	if !mapping.structured() {
+		return nil
+	}
+
+	key := jsonFieldsKey(doc.Schema, columnID)
+	s.jsonFieldsLock.Lock()
+	_, found := s.jsonFields[key]
+	s.jsonFieldsLock.Unlock()
+	if found {
+		return nil
+	}
+
+	var index IndexName = s.adapter.SchemaNameToIndex(doc.Schema)
+	if s.indexLayout != IndexPerSchema {
+		var err error
+		index, err = s.documentTableIndex(ctx, doc)
+		if err != nil {
+			// the document is skipped when it's sent
+			if errors.Is(err, errTableIndexNotFound) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	// the index alias covers all the rollover table buckets
+	indexMappings, err := s.client.GetIndicesFieldMapping(ctx, index.Name(), columnID)
+	if err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+		return fmt.Errorf("getting json column %s mapping: %w", columnID, mapError(err))
+	}
+
+	fields := make(map[string]struct{}, len(mapping.Fields))
+	for path := range mapping.Fields {
+		fields[path] = struct{}{}
+	}
+	for _, columnMapping := range indexMappings {
+		properties, _ := columnMapping["properties"].(map[string]any)
+		delete(properties, jsonFlattenedField)
+		mappedJSONFields(properties, "", fields)
+	}
+
+	s.jsonFieldsLock.Lock()
+	defer s.jsonFieldsLock.Unlock()
+	if _, found := s.jsonFields[key]; !found {
+		s.jsonFields[key] = fields
+	}
+	return nil
+}
+
+// mappedJSONFields adds the dotted paths of the leaf fields of the mapping
+// properties on input to the fields map. Multi-fields are not included, since
+// they're not part of the properties.
+func mappedJSONFields(properties map[string]any, prefix string, fields map[string]struct{}) {
+	for name, value := range properties {
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		mapping, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		if children, ok := mapping["properties"].(map[string]any); ok {
+			mappedJSONFields(children, path, fields)
+			continue
+		}
+		if fieldType := mapping["type"]; fieldType == "object" || fieldType == "nested" {
+			continue
+		}
+		fields[path] = struct{}{}
+	}
+}
+
+func jsonFieldsKey(schemaName, columnID string) string {
+	return schemaName + "/" + columnID
+}
+
+// trackJSONFields adds the paths on input to the fields mapped for the
+// column, and returns false if that would exceed the column max fields, in
+// which case they are not added. The mapped fields are seeded from the index
+// mapping by loadJSONFields, along with the known subfields.
+func (s *Store) trackJSONFields(schemaName, columnID string, mapping *JSONMapping, paths map[string]struct{}) bool {
+	s.jsonFieldsLock.Lock()
+	defer s.jsonFieldsLock.Unlock()
+
+	key := jsonFieldsKey(schemaName, columnID)
+	fields, found := s.jsonFields[key]
+	if !found {
+		fields = make(map[string]struct{}, len(mapping.Fields))
+		for path := range mapping.Fields {
+			fields[path] = struct{}{}
+		}
+		s.jsonFields[key] = fields
+	}
+
+	newFields := 0
+	for path := range paths {
+		if _, found := fields[path]; !found {
+			newFields++
+		}
+	}
+	if len(fields)+newFields > mapping.maxFields() {
+		return false
+	}
+	for path := range paths {
+		fields[path] = struct{}{}
+	}
+	return true
+}
+
+// schemaJSONColumns returns the JSON mappings of the schema json columns, by
+// pgstream id. They are loaded from the latest schema log entry if they're
+// not known yet (i.e. after a restart).
+func (s *Store) schemaJSONColumns(ctx context.Context, schemaName string) (map[string]*JSONMapping, error) {
+	s.jsonColumnsLock.RLock()
+	columns, found := s.jsonColumns[schemaName]
+	s.jsonColumnsLock.RUnlock()
+	if found {
+		return columns, nil
+	}
+
+	logEntry, err := s.getLastSchemaLogEntry(ctx, schemaName)
+	if err != nil {
+		if !errors.As(err, &search.ErrSchemaNotFound{}) {
+			return nil, err
+		}
+		logEntry = &schemalog.LogEntry{SchemaName: schemaName}
+	}
+	return s.setJSONColumns(logEntry), nil
+}
+
+// updateJSONColumns refreshes the JSON mappings of the schema on input after a
+// schema change.
+func (s *Store) updateJSONColumns(logEntry *schemalog.LogEntry) {
+	if s.hasJSONMappings() {
+		s.setJSONColumns(logEntry)
+	}
+}
+
+func (s *Store) setJSONColumns(logEntry *schemalog.LogEntry) map[string]*JSONMapping {
+	columns := map[string]*JSONMapping{}
+	overrides := s.schemaIndexOverrides(logEntry)
+	for i := range logEntry.Schema.Tables {
+		for _, c := range logEntry.Schema.Tables[i].Columns {
+			o, found := overrides.columns[c.PgstreamID]
+			if !found || o.JSON == nil || !isJSONType(c.DataType) {
+				continue
+			}
+			if o.JSON.Mode != "" && o.JSON.Mode != JSONAsText {
+				columns[c.PgstreamID] = o.JSON
+			}
+		}
+	}
+
+	s.jsonColumnsLock.Lock()
+	defer s.jsonColumnsLock.Unlock()
+	s.jsonColumns[logEntry.SchemaName] = columns
+	return columns
+}
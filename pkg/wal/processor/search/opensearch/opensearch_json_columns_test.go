@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestJSONMapping_searchMapping(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		mapping *JSONMapping
+
+		wantMapping map[string]any
+	}{
+		{
+			name:    "text",
+			mapping: &JSONMapping{Mode: JSONAsText},
+
+			wantMapping: map[string]any{"type": "text"},
+		},
+		{
+			name:    "flattened",
+			mapping: &JSONMapping{Mode: JSONAsFlattened},
+
+			wantMapping: map[string]any{"type": "flat_object"},
+		},
+		{
+			name: "nested with subfields",
+			mapping: &JSONMapping{
+				Mode: JSONAsNested,
+				Fields: map[string]map[string]any{
+					"name":         {"type": "keyword"},
+					"address.zip":  {"type": "keyword"},
+					"address.geo":  {"type": "geo_point"},
+					"address.city": {"type": "text"},
+				},
+			},
+
+			wantMapping: map[string]any{
+				"type":    "nested",
+				"dynamic": true,
+				"properties": map[string]any{
+					"_flattened": map[string]any{"type": "flat_object"},
+					"name":       map[string]any{"type": "keyword"},
+					"address": map[string]any{
+						"properties": map[string]any{
+							"zip":  map[string]any{"type": "keyword"},
+							"geo":  map[string]any{"type": "geo_point"},
+							"city": map[string]any{"type": "text"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantMapping, tc.mapping.searchMapping(openSearchFlattenedType))
+		})
+	}
+}
+
+func TestStore_mapJSONColumns(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testMappingConfig := &MappingConfig{
+		Columns: []ColumnMappingOverride{
+			{Schema: testSchemaName, Table: "t", Column: "obj", JSON: &JSONMapping{Mode: JSONAsObject, MaxDepth: 2, MaxFields: 3}},
+			{Schema: testSchemaName, Table: "t", Column: "flat", JSON: &JSONMapping{Mode: JSONAsFlattened}},
+		},
+	}
+
+	// the index already maps two of the column subfields
+	testFieldMapping := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"_flattened": map[string]any{"type": "flat_object"},
+			"x":          map[string]any{"type": "long"},
+			"y": map[string]any{
+				"properties": map[string]any{
+					"z": map[string]any{
+						"type":   "text",
+						"fields": map[string]any{"keyword": map[string]any{"type": "keyword"}},
+					},
+				},
+			},
+		},
+	}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name            string
+		doc             search.Document
+		fieldMapping    map[string]any
+		fieldMappingErr error
+
+		wantData map[string]any
+		wantErr  error
+	}{
+		{
+			name: "ok - json values",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-1": "id",
+					"t1-2": `{"a": 1, "b": {"c": "d"}}`,
+					"t1-3": `[{"a": 1}, {"b": [1, 2]}]`,
+				},
+			},
+
+			wantData: map[string]any{
+				"t1-1": "id",
+				"t1-2": map[string]any{"a": float64(1), "b": map[string]any{"c": "d"}},
+				"t1-3": []any{map[string]any{"a": float64(1)}, map[string]any{"b": []any{float64(1), float64(2)}}},
+			},
+		},
+		{
+			name: "ok - max depth exceeded",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-2": `{"a": {"b": {"c": 1}}}`,
+				},
+			},
+
+			wantData: map[string]any{
+				"t1-2": map[string]any{
+					"_flattened": map[string]any{"a": map[string]any{"b": map[string]any{"c": float64(1)}}},
+				},
+			},
+		},
+		{
+			name: "ok - max fields exceeded",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-2": `{"a": 1, "b": 2, "c": 3, "d": 4}`,
+				},
+			},
+
+			wantData: map[string]any{
+				"t1-2": map[string]any{
+					"_flattened": map[string]any{"a": float64(1), "b": float64(2), "c": float64(3), "d": float64(4)},
+				},
+			},
+		},
+		{
+			name: "ok - max fields exceeded with the index mapped fields",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-2": `{"a": 1, "b": 2}`,
+				},
+			},
+			fieldMapping: testFieldMapping,
+
+			wantData: map[string]any{
+				"t1-2": map[string]any{
+					"_flattened": map[string]any{"a": float64(1), "b": float64(2)},
+				},
+			},
+		},
+		{
+			name: "ok - index mapped fields reused",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-2": `{"x": 1, "y": {"z": "v"}, "a": 2}`,
+				},
+			},
+			fieldMapping: testFieldMapping,
+
+			wantData: map[string]any{
+				"t1-2": map[string]any{"x": float64(1), "y": map[string]any{"z": "v"}, "a": float64(2)},
+			},
+		},
+		{
+			name: "ok - index not found",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-2": `{"a": 1}`,
+				},
+			},
+			fieldMappingErr: es.ErrResourceNotFound,
+
+			wantData: map[string]any{
+				"t1-2": map[string]any{"a": float64(1)},
+			},
+		},
+		{
+			name: "ok - scalar and invalid values skipped",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-1": "id",
+					"t1-2": `"text"`,
+					"t1-3": `{invalid`,
+				},
+			},
+
+			wantData: map[string]any{
+				"t1-1": "id",
+			},
+		},
+		{
+			name: "ok - null values",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-2": nil,
+				},
+			},
+
+			wantData: map[string]any{
+				"t1-2": nil,
+			},
+		},
+		{
+			name: "error - getting index mapping",
+			doc: search.Document{
+				Schema: testSchemaName,
+				Data: map[string]any{
+					"t1-2": `{"a": 1}`,
+				},
+			},
+			fieldMappingErr: errTest,
+
+			wantData: map[string]any{
+				"t1-2": `{"a": 1}`,
+			},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &esmocks.Client{
+				GetIndicesFieldMappingFn: func(ctx context.Context, pattern, field string) (map[string]map[string]any, error) {
+					require.Equal(t, testSchemaName, pattern)
+					require.Equal(t, "t1-2", field)
+					if tc.fieldMapping == nil {
+						return nil, tc.fieldMappingErr
+					}
+					return map[string]map[string]any{"test_schema-1": tc.fieldMapping}, nil
+				},
+			}
+
+			s := NewStoreWithClient(client)
+			WithMappingConfig(testMappingConfig)(s)
+			s.jsonColumns[testSchemaName] = map[string]*JSONMapping{
+				"t1-2": testMappingConfig.Columns[0].JSON,
+				"t1-3": testMappingConfig.Columns[1].JSON,
+			}
+
+			doc, err := s.mapJSONColumns(context.Background(), tc.doc)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantData, doc.Data)
+		})
+	}
+}
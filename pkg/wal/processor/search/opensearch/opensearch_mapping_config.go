@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/xataio/pgstream/pkg/schemalog"
+)
+
+// MappingConfig customises the mappings and settings of the indices created by
+// the store, on top of the ones produced by the mapper and the default index
+// settings. It only applies to new indices and new columns, existing mappings
+// are not updated when the configuration changes.
+type MappingConfig struct {
+	// Columns are the mapping overrides for specific postgres columns.
+	Columns []ColumnMappingOverride `json:"columns"`
+	// Indices are the index settings for specific schemas or tables.
+	Indices []IndexSettingsOverride `json:"indices"`
+	// Rollover are the tables routed to time bucketed indices. They require a
+	// table index layout.
+	Rollover []RolloverConfig `json:"rollover"`
+}
+
+// ColumnMappingOverride overrides the search mapping of a postgres column,
+// identified by its schema, table and column names.
+type ColumnMappingOverride struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	// Type replaces the mapping produced by the mapper with a mapping of the
+	// given type. The mapper mapping is kept if empty.
+	Type string `json:"type,omitempty"`
+	// Analyzer sets the analyzer of text fields. Custom analyzers can be
+	// defined in the index settings.
+	Analyzer string `json:"analyzer,omitempty"`
+	// Index can be set to false to make the field not searchable.
+	Index *bool `json:"index,omitempty"`
+	// DocValues can be set to false to disable sorting and aggregations on
+	// the field, reducing the disk usage.
+	DocValues *bool `json:"doc_values,omitempty"`
+	// JSON sets how json and jsonb columns are indexed. It's ignored for
+	// other column types.
+	JSON *JSONMapping `json:"json,omitempty"`
+}
+
+// JSONMode defines how the json and jsonb column values are indexed.
+type JSONMode string
+
+const (
+	// JSONAsText indexes the JSON values as text. This is the default.
+	JSONAsText JSONMode = "text"
+	// JSONAsObject maps the fields of the JSON values, so that they can be
+	// used in queries.
+	JSONAsObject JSONMode = "object"
+	// JSONAsNested maps the fields of the JSON values like JSONAsObject, but
+	// the objects in arrays are indexed as separate documents, so that they
+	// can be queried independently.
+	JSONAsNested JSONMode = "nested"
+	// JSONAsFlattened indexes all the JSON leaf values as keywords of a single
+	// field, with no risk of mapping explosion.
+	JSONAsFlattened JSONMode = "flattened"
+)
+
+// JSONMapping configures the mapping of a json or jsonb column.
+type JSONMapping struct {
+	Mode JSONMode `json:"mode"`
+	// MaxDepth and MaxFields limit the depth and the number of fields mapped
+	// for the column with the object and nested modes. The values that would
+	// exceed them are indexed in the flattened `_flattened` subfield instead.
+	// They default to 10 and 100 respectively.
+	MaxDepth  int `json:"max_depth,omitempty"`
+	MaxFields int `json:"max_fields,omitempty"`
+	// Fields are the mappings of known subfields, by dotted path (i.e.
+	// `address.zip`), for the object and nested modes. Other subfields are
+	// mapped from the first value received.
+	Fields map[string]map[string]any `json:"fields,omitempty"`
+}
+
+// IndexSettingsOverride sets the index settings for a schema, or for a table
+// when the store uses a table index layout. The settings (i.e. `analysis`,
+// `number_of_shards`, `number_of_replicas`, `refresh_interval`) replace the
+// default settings with the same key. Table settings take precedence over the
+// schema ones.
+type IndexSettingsOverride struct {
+	Schema   string         `json:"schema"`
+	Table    string         `json:"table,omitempty"`
+	Settings map[string]any `json:"settings"`
+}
+
+var errInvalidMappingConfig = errors.New("invalid mapping config")
+
+// LoadMappingConfig reads the JSON mapping configuration from the file on
+// input.
+func LoadMappingConfig(path string) (*MappingConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading mapping config file: %w", err)
+	}
+
+	cfg := &MappingConfig{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parsing mapping config file: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate returns an error if any of the overrides doesn't identify its
+// column or index.
+func (c *MappingConfig) Validate() error {
+	for _, o := range c.Columns {
+		if o.Schema == "" || o.Table == "" || o.Column == "" {
+			return fmt.Errorf("%w: column overrides require schema, table and column names", errInvalidMappingConfig)
+		}
+		if o.JSON != nil {
+			if err := o.JSON.validate(); err != nil {
+				return fmt.Errorf("%w: column %s.%s.%s: %w", errInvalidMappingConfig, o.Schema, o.Table, o.Column, err)
+			}
+			if o.Type != "" {
+				return fmt.Errorf("%w: column %s.%s.%s: type and json mapping can't be combined", errInvalidMappingConfig, o.Schema, o.Table, o.Column)
+			}
+		}
+	}
+	for _, o := range c.Indices {
+		if o.Schema == "" {
+			return fmt.Errorf("%w: index settings require a schema name", errInvalidMappingConfig)
+		}
+	}
+	tables := make(map[string]struct{}, len(c.Rollover))
+	for _, r := range c.Rollover {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("%w: rollover table %s.%s: %w", errInvalidMappingConfig, r.Schema, r.Table, err)
+		}
+		key := r.Schema + "." + r.Table
+		if _, found := tables[key]; found {
+			return fmt.Errorf("%w: rollover table %s is configured more than once", errInvalidMappingConfig, key)
+		}
+		tables[key] = struct{}{}
+	}
+	return nil
+}
+
+// ValidateLayout returns an error if the configuration can't be used with the
+// index layout on input.
+func (c *MappingConfig) ValidateLayout(layout IndexLayout) error {
+	// the tables of a schema share the same index with the schema layout
+	if len(c.Rollover) > 0 && (layout == "" || layout == IndexPerSchema) {
+		return fmt.Errorf("%w: rollover tables require a table index layout", errInvalidMappingConfig)
+	}
+	return nil
+}
+
+// indexOverrides are the overrides that apply to a specific index.
+type indexOverrides struct {
+	// columns are the column overrides, by pgstream id.
+	columns map[string]*ColumnMappingOverride
+	// settings are the settings used when creating new versions of the index.
+	settings map[string]any
+}
+
+// schemaIndexOverrides returns the overrides for the index of the schema on
+// input, which contains all its tables.
+func (s *Store) schemaIndexOverrides(logEntry *schemalog.LogEntry) *indexOverrides {
+	overrides := &indexOverrides{
+		columns:  map[string]*ColumnMappingOverride{},
+		settings: s.indexSettingsFor(logEntry.SchemaName, ""),
+	}
+	for i := range logEntry.Schema.Tables {
+		s.addColumnOverrides(overrides, logEntry.SchemaName, &logEntry.Schema.Tables[i])
+	}
+	return overrides
+}
+
+// tableIndexOverrides returns the overrides for the index of the schema table
+// on input.
+func (s *Store) tableIndexOverrides(schemaName string, table *schemalog.Table) *indexOverrides {
+	overrides := &indexOverrides{
+		columns:  map[string]*ColumnMappingOverride{},
+		settings: s.indexSettingsFor(schemaName, table.Name),
+	}
+	s.addColumnOverrides(overrides, schemaName, table)
+	return overrides
+}
+
+func (s *Store) addColumnOverrides(overrides *indexOverrides, schemaName string, table *schemalog.Table) {
+	if s.mappingConfig == nil {
+		return
+	}
+	for i := range s.mappingConfig.Columns {
+		o := &s.mappingConfig.Columns[i]
+		if o.Schema != schemaName || o.Table != table.Name {
+			continue
+		}
+		if c := table.GetColumnByName(o.Column); c != nil {
+			overrides.columns[c.PgstreamID] = o
+		}
+	}
+}
+
+// indexSettingsFor returns the settings for the index of the schema on input,
+// or of the schema table if the table name is not empty.
+func (s *Store) indexSettingsFor(schemaName, tableName string) map[string]any {
+	settings := make(map[string]any, len(s.indexSettings))
+	for k, v := range s.indexSettings {
+		settings[k] = v
+	}
+	if s.mappingConfig == nil {
+		return settings
+	}
+
+	// schema settings are applied first, so that the table ones take
+	// precedence
+	for _, table := range []string{"", tableName} {
+		for _, o := range s.mappingConfig.Indices {
+			if o.Schema != schemaName || o.Table != table {
+				continue
+			}
+			for k, v := range o.Settings {
+				settings[k] = v
+			}
+		}
+		if tableName == "" {
+			break
+		}
+	}
+	return settings
+}
+
+// apply returns a copy of the mapping on input with the override applied.
+func (o *ColumnMappingOverride) apply(mapping map[string]any) map[string]any {
+	// the type specific parameters of the mapper mapping (i.e. subfields,
+	// formats) don't necessarily apply to the new type
+	if o.Type != "" {
+		mapping = map[string]any{"type": o.Type}
+	}
+
+	overridden := make(map[string]any, len(mapping)+3)
+	for k, v := range mapping {
+		overridden[k] = v
+	}
+	if o.Analyzer != "" {
+		overridden["analyzer"] = o.Analyzer
+	}
+	if o.Index != nil {
+		overridden["index"] = *o.Index
+	}
+	if o.DocValues != nil {
+		overridden["doc_values"] = *o.DocValues
+	}
+	return overridden
+}
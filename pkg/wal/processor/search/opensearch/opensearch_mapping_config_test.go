@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	searchmocks "github.com/xataio/pgstream/pkg/wal/processor/search/mocks"
+)
+
+func TestLoadMappingConfig(t *testing.T) {
+	t.Parallel()
+
+	boolPtr := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name    string
+		content string
+
+		wantConfig *MappingConfig
+		wantErr    error
+	}{
+		{
+			name: "ok",
+			content: `{
+				"columns": [
+					{"schema": "public", "table": "t", "column": "c", "type": "text", "analyzer": "english", "index": false, "doc_values": false}
+				],
+				"indices": [
+					{"schema": "public", "settings": {"number_of_shards": 3}}
+				]
+			}`,
+
+			wantConfig: &MappingConfig{
+				Columns: []ColumnMappingOverride{
+					{Schema: "public", Table: "t", Column: "c", Type: "text", Analyzer: "english", Index: boolPtr(false), DocValues: boolPtr(false)},
+				},
+				Indices: []IndexSettingsOverride{
+					{Schema: "public", Settings: map[string]any{"number_of_shards": float64(3)}},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "ok - json mapping",
+			content: `{
+				"columns": [
+					{"schema": "public", "table": "t", "column": "c", "json": {"mode": "object", "max_depth": 3, "fields": {"address.zip": {"type": "keyword"}}}}
+				]
+			}`,
+
+			wantConfig: &MappingConfig{
+				Columns: []ColumnMappingOverride{
+					{Schema: "public", Table: "t", Column: "c", JSON: &JSONMapping{
+						Mode:     JSONAsObject,
+						MaxDepth: 3,
+						Fields:   map[string]map[string]any{"address.zip": {"type": "keyword"}},
+					}},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "ok - rollover",
+			content: `{"rollover": [{"schema": "public", "table": "events", "timestamp_column": "created_at", "interval": "daily", "retention": 30}]}`,
+
+			wantConfig: &MappingConfig{
+				Rollover: []RolloverConfig{
+					{Schema: "public", Table: "events", TimestampColumn: "created_at", Interval: RolloverDaily, Retention: 30},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "error - unsupported rollover interval",
+			content: `{"rollover": [{"schema": "public", "table": "events", "timestamp_column": "created_at", "interval": "weekly"}]}`,
+
+			wantConfig: nil,
+			wantErr:    errUnsupportedRolloverInterval,
+		},
+		{
+			name:    "error - duplicated rollover table",
+			content: `{"rollover": [{"schema": "public", "table": "events", "timestamp_column": "created_at", "interval": "daily"}, {"schema": "public", "table": "events", "timestamp_column": "updated_at", "interval": "monthly"}]}`,
+
+			wantConfig: nil,
+			wantErr:    errInvalidMappingConfig,
+		},
+		{
+			name:    "error - unsupported json mode",
+			content: `{"columns": [{"schema": "public", "table": "t", "column": "c", "json": {"mode": "array"}}]}`,
+
+			wantConfig: nil,
+			wantErr:    errUnsupportedJSONMode,
+		},
+		{
+			name:    "error - json subfields with flattened mode",
+			content: `{"columns": [{"schema": "public", "table": "t", "column": "c", "json": {"mode": "flattened", "fields": {"a": {"type": "keyword"}}}}]}`,
+
+			wantConfig: nil,
+			wantErr:    errInvalidMappingConfig,
+		},
+		{
+			name:    "error - json mapping with type",
+			content: `{"columns": [{"schema": "public", "table": "t", "column": "c", "type": "keyword", "json": {"mode": "object"}}]}`,
+
+			wantConfig: nil,
+			wantErr:    errInvalidMappingConfig,
+		},
+		{
+			name:    "error - column override without table",
+			content: `{"columns": [{"schema": "public", "column": "c", "type": "text"}]}`,
+
+			wantConfig: nil,
+			wantErr:    errInvalidMappingConfig,
+		},
+		{
+			name:    "error - index settings without schema",
+			content: `{"indices": [{"table": "t", "settings": {"number_of_shards": 3}}]}`,
+
+			wantConfig: nil,
+			wantErr:    errInvalidMappingConfig,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "mapping.json")
+			require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+			cfg, err := LoadMappingConfig(path)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantConfig, cfg)
+		})
+	}
+}
+
+func TestLayoutOptions(t *testing.T) {
+	t.Parallel()
+
+	testRolloverConfig := `{"rollover": [{"schema": "public", "table": "events", "timestamp_column": "created_at", "interval": "daily"}]}`
+
+	tests := []struct {
+		name          string
+		indexLayout   IndexLayout
+		mappingConfig string
+
+		wantLayout        IndexLayout
+		wantMappingConfig bool
+		wantErr           error
+	}{
+		{
+			name: "ok - defaults",
+
+			wantLayout: IndexPerSchema,
+		},
+		{
+			name:          "ok - layout and mapping config",
+			indexLayout:   IndexPerTable,
+			mappingConfig: testRolloverConfig,
+
+			wantLayout:        IndexPerTable,
+			wantMappingConfig: true,
+		},
+		{
+			name:        "error - invalid layout",
+			indexLayout: "index",
+
+			wantErr: errUnsupportedIndexLayout,
+		},
+		{
+			name:          "error - mapping config not supported by the layout",
+			indexLayout:   IndexPerSchema,
+			mappingConfig: testRolloverConfig,
+
+			wantErr: errInvalidMappingConfig,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := ""
+			if tc.mappingConfig != "" {
+				path = filepath.Join(t.TempDir(), "mapping.json")
+				require.NoError(t, os.WriteFile(path, []byte(tc.mappingConfig), 0o600))
+			}
+
+			opts, err := LayoutOptions(tc.indexLayout, path)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			s := NewStoreWithClient(nil)
+			for _, opt := range opts {
+				opt(s)
+			}
+			require.Equal(t, tc.wantLayout, s.indexLayout)
+			require.Equal(t, tc.wantMappingConfig, s.mappingConfig != nil)
+		})
+	}
+}
+
+func TestStore_indexSettingsFor(t *testing.T) {
+	t.Parallel()
+
+	s := &Store{
+		indexSettings: map[string]any{
+			"number_of_shards":   1,
+			"number_of_replicas": 1,
+		},
+		mappingConfig: &MappingConfig{
+			Indices: []IndexSettingsOverride{
+				{Schema: "test_schema", Table: "test_table", Settings: map[string]any{"number_of_shards": 5}},
+				{Schema: "test_schema", Settings: map[string]any{"number_of_shards": 3, "refresh_interval": "5s"}},
+				{Schema: "other_schema", Settings: map[string]any{"number_of_replicas": 0}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		schema    string
+		tableName string
+
+		wantSettings map[string]any
+	}{
+		{
+			name:   "schema index",
+			schema: "test_schema",
+
+			wantSettings: map[string]any{
+				"number_of_shards":   3,
+				"number_of_replicas": 1,
+				"refresh_interval":   "5s",
+			},
+		},
+		{
+			name:      "table index",
+			schema:    "test_schema",
+			tableName: "test_table",
+
+			wantSettings: map[string]any{
+				"number_of_shards":   5,
+				"number_of_replicas": 1,
+				"refresh_interval":   "5s",
+			},
+		},
+		{
+			name:   "no overrides",
+			schema: "unknown_schema",
+
+			wantSettings: map[string]any{
+				"number_of_shards":   1,
+				"number_of_replicas": 1,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantSettings, s.indexSettingsFor(tc.schema, tc.tableName))
+		})
+	}
+}
+
+func TestStore_updateMapping_mappingOverrides(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testLogEntry := &schemalog.LogEntry{
+		ID:         xid.New(),
+		Version:    1,
+		SchemaName: testSchemaName,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					Name:       "test_table",
+					PgstreamID: "t1",
+					Columns: []schemalog.Column{
+						{Name: "id", DataType: "int8", PgstreamID: "t1-1"},
+						{Name: "description", DataType: "text", PgstreamID: "t1-2"},
+						{Name: "notes", DataType: "text", PgstreamID: "t1-3"},
+						{Name: "attributes", DataType: "jsonb", PgstreamID: "t1-4"},
+					},
+				},
+			},
+		},
+	}
+	testMappingConfig := &MappingConfig{
+		Columns: []ColumnMappingOverride{
+			{Schema: testSchemaName, Table: "test_table", Column: "description", Type: "text", Analyzer: "english"},
+			{Schema: testSchemaName, Table: "test_table", Column: "notes", Index: func(b bool) *bool { return &b }(false)},
+			{Schema: testSchemaName, Table: "test_table", Column: "attributes", JSON: &JSONMapping{Mode: JSONAsObject}},
+			// different table with the same column name
+			{Schema: testSchemaName, Table: "other_table", Column: "id", Type: "keyword"},
+		},
+	}
+
+	putMappingsCalls := 0
+	s := NewStoreWithClient(&esmocks.Client{
+		PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+			putMappingsCalls++
+			require.Equal(t, testSchemaName, index)
+			require.Equal(t, map[string]any{
+				"properties": map[string]any{
+					"t1-1": map[string]any{"type": "long"},
+					"t1-2": map[string]any{"type": "text", "analyzer": "english"},
+					"t1-3": map[string]any{
+						"type":   "text",
+						"fields": map[string]any{"raw": map[string]any{"type": "keyword"}},
+						"index":  false,
+					},
+					"t1-4": map[string]any{
+						"type":    "object",
+						"dynamic": true,
+						"properties": map[string]any{
+							"_flattened": map[string]any{"type": "flat_object"},
+						},
+					},
+				},
+			}, body)
+			return nil
+		},
+		IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+			return nil
+		},
+		DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+			return errors.New("DeleteByQueryFn: should not be called")
+		},
+	})
+	WithMappingConfig(testMappingConfig)(s)
+	s.mapper = &searchmocks.Mapper{
+		ColumnToSearchMappingFn: func(column schemalog.Column) (map[string]any, error) {
+			switch column.DataType {
+			case "int8":
+				return map[string]any{"type": "long"}, nil
+			default:
+				return map[string]any{
+					"type":   "text",
+					"fields": map[string]any{"raw": map[string]any{"type": "keyword"}},
+				}, nil
+			}
+		},
+	}
+
+	err := s.updateMapping(context.Background(), testSchemaName, testLogEntry, testLogEntry.Diff(&schemalog.LogEntry{}), nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, putMappingsCalls)
+}
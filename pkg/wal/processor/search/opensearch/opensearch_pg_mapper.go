@@ -33,6 +33,11 @@ const (
 	searchTypeJSON
 	searchTypeText
 	searchTypePGVector
+	searchTypeEnum
+	searchTypeGeoPoint
+	searchTypeGeoShape
+	searchTypeRange
+	searchTypeHstore
 )
 
 type searchField struct {
@@ -43,6 +48,11 @@ type searchField struct {
 
 type metadata struct {
 	vectorDimension int
+	// scalingFactor is set for numeric types with a precision that can be
+	// stored as a scaled float.
+	scalingFactor int
+	// rangeType is the postgres range type name.
+	rangeType string
 }
 
 const (
@@ -55,6 +65,12 @@ const (
 	timestampTZFormat = "2006-01-02T15:04:05.000Z"
 	timestampFormat   = "2006-01-02T15:04:05.000"
 	dateFormat        = "2006-01-02"
+
+	dateTimeMappingFormat = "yyyy-MM-dd HH:mm:ss[.SSS][x]||yyyy-MM-dd HH:mm:ss[.SS][x]||yyyy-MM-dd HH:mm:ss[.S][x]||yyyy-MM-dd'T'HH:mm:ss[.SSS][X]"
+
+	// scaled floats are stored as longs, so the numeric values multiplied by
+	// the scaling factor need to fit in 63 bits
+	maxScaledFloatPrecision = 18
 )
 
 // NewPostgresMapper returns a mapper that maps between postgres and opensearch
@@ -76,6 +92,12 @@ func (m *Mapper) ColumnToSearchMapping(column schemalog.Column) (map[string]any,
 	case searchTypeInteger:
 		return map[string]any{"type": "long"}, nil
 	case searchTypeFloat:
+		if searchField.metadata.scalingFactor > 0 {
+			return map[string]any{
+				"type":           "scaled_float",
+				"scaling_factor": searchField.metadata.scalingFactor,
+			}, nil
+		}
 		return map[string]any{"type": "double"}, nil
 	case searchTypeBool:
 		return map[string]any{"type": "boolean"}, nil
@@ -104,8 +126,19 @@ func (m *Mapper) ColumnToSearchMapping(column schemalog.Column) (map[string]any,
 	case searchTypeDateTime, searchTypeDateTimeTZ:
 		return map[string]any{
 			"type":   "date",
-			"format": "yyyy-MM-dd HH:mm:ss[.SSS][x]||yyyy-MM-dd HH:mm:ss[.SS][x]||yyyy-MM-dd HH:mm:ss[.S][x]||yyyy-MM-dd'T'HH:mm:ss[.SSS][X]",
+			"format": dateTimeMappingFormat,
 		}, nil
+	case searchTypeEnum:
+		return map[string]any{"type": "keyword"}, nil
+	case searchTypeGeoPoint:
+		return map[string]any{"type": "geo_point"}, nil
+	case searchTypeGeoShape:
+		return map[string]any{"type": "geo_shape"}, nil
+	case searchTypeRange:
+		return rangeMapping(searchField.metadata.rangeType), nil
+	case searchTypeHstore:
+		// the hstore keys are dynamic, unlike the rest of the document
+		return map[string]any{"type": "object", "dynamic": true}, nil
 	case searchTypePGVector:
 		vectorSettings := map[string]any{
 			"type":      "knn_vector",
@@ -139,6 +172,17 @@ func (m *Mapper) MapColumnValue(column schemalog.Column, value any) (any, error)
 			return m.mapDateTime(searchField, value)
 		}
 	case searchTypeDate:
+		if searchField.isArray {
+			var a pgtype.FlatArray[pgtype.Date]
+			if err := m.pgTypeMap.SQLScanner(&a).Scan(value); err != nil {
+				return nil, fmt.Errorf("mapping date array from pg to ES failed: %w (value: %s)", err, value)
+			}
+			dates := make([]string, len(a))
+			for i := range a {
+				dates[i] = a[i].Time.Format(dateFormat)
+			}
+			return dates, nil
+		}
 		var d pgtype.Date
 		if err := d.Scan(value); err != nil {
 			return nil, fmt.Errorf("mapping date from pg to ES failed: %w (value: %s)", err, value)
@@ -156,6 +200,24 @@ func (m *Mapper) MapColumnValue(column schemalog.Column, value any) (any, error)
 			return nil, fmt.Errorf("vector value is not array: %w", err)
 		}
 		return array, nil
+	case searchTypeGeoPoint, searchTypeGeoShape:
+		return mapGeometry(searchField, value)
+	case searchTypeRange:
+		return m.mapRange(searchField, value)
+	case searchTypeHstore:
+		var h pgtype.Hstore
+		if err := h.Scan(value); err != nil {
+			return nil, fmt.Errorf("mapping hstore from pg to ES failed: %w (value: %s)", err, value)
+		}
+		object := make(map[string]any, len(h))
+		for k, v := range h {
+			if v == nil {
+				object[k] = nil
+				continue
+			}
+			object[k] = *v
+		}
+		return object, nil
 	default:
 		if searchField.isArray { // catches all other array types
 			// handle arrays
@@ -172,7 +234,7 @@ func (m *Mapper) MapColumnValue(column schemalog.Column, value any) (any, error)
 				var a pgtype.FlatArray[bool]
 				err := m.pgTypeMap.SQLScanner(&a).Scan(value)
 				return []bool(a), err
-			case searchTypeString:
+			case searchTypeString, searchTypeEnum, searchTypeTime:
 				var a pgtype.FlatArray[string]
 				err := m.pgTypeMap.SQLScanner(&a).Scan(value)
 				return []string(a), err
@@ -191,19 +253,31 @@ func (m *Mapper) MapColumnValue(column schemalog.Column, value any) (any, error)
 
 func (m *Mapper) columnToSearchField(column schemalog.Column) (*searchField, error) {
 	pgTypeName := column.DataType
-	typeName, isArray, err := m.parsePGType(pgTypeName)
+	typeName, params, isArray, err := m.parsePGType(pgTypeName)
 	if err != nil {
 		return nil, fmt.Errorf("pg to search type: failed to parse pg type: %w", err)
 	}
 
+	// the enum type names can't be told apart from other user defined types
+	if column.Enum {
+		return &searchField{
+			searchType: searchTypeEnum,
+			isArray:    isArray,
+		}, nil
+	}
+
 	metadata := metadata{}
 
 	var searchType searchType
-	switch typeName {
+	// the extension types (i.e. PostGIS, hstore) include the schema
+	switch unqualifiedType(typeName) {
 	case "int8", "int2", "int4", "integer", "smallint", "bigint":
 		searchType = searchTypeInteger
-	case "float4", "float8", "real", "double precision", "float", "numeric":
+	case "float4", "float8", "real", "double precision", "float":
+		searchType = searchTypeFloat
+	case "numeric":
 		searchType = searchTypeFloat
+		metadata.scalingFactor = numericScalingFactor(params)
 	case "boolean":
 		searchType = searchTypeBool
 	case "bytea", "char", "name", "text", "varchar", "bpchar", "xml", "uuid", "character varying", "character", "cidr", "inet", "macaddr", "macaddr8", "interval":
@@ -218,11 +292,23 @@ func (m *Mapper) columnToSearchField(column schemalog.Column) (*searchField, err
 		searchType = searchTypeDateTime
 	case "timestamptz", "timetz", "timestamp with time zone":
 		searchType = searchTypeDateTimeTZ
+	case "geometry", "geography":
+		searchType = searchTypeGeoShape
+		if len(params) > 0 && isPointGeometry(params[0]) {
+			searchType = searchTypeGeoPoint
+		}
+	case "int4range", "int8range", "numrange", "daterange", "tsrange", "tstzrange":
+		searchType = searchTypeRange
+		metadata.rangeType = typeName
+	case "hstore":
+		searchType = searchTypeHstore
 	default:
-		// pgvector includes the schema (sometimes? seems only a problem when testing locally)
-		if isPGVector(typeName) {
+		// pgvector includes the schema (sometimes? seems only a problem when
+		// testing locally). The dimension parameter is stripped from the type
+		// name, so use the original one.
+		if isPGVector(pgTypeName) {
 			searchType = searchTypePGVector
-			metadata.vectorDimension, err = getPGVectorDimension(typeName)
+			metadata.vectorDimension, err = getPGVectorDimension(pgTypeName)
 			if err != nil {
 				return nil, search.ErrTypeInvalid{Input: pgTypeName}
 			}
@@ -231,6 +317,15 @@ func (m *Mapper) columnToSearchField(column schemalog.Column) (*searchField, err
 		}
 	}
 
+	// arrays of geometries, ranges and objects don't have a search
+	// equivalent
+	switch searchType {
+	case searchTypeGeoPoint, searchTypeGeoShape, searchTypeRange, searchTypeHstore:
+		if isArray {
+			return nil, search.ErrTypeInvalid{Input: pgTypeName}
+		}
+	}
+
 	return &searchField{
 		searchType: searchType,
 		isArray:    isArray,
@@ -290,7 +385,7 @@ func (m *Mapper) mapDateTime(searchField *searchField, value any) (any, error) {
 	return value, nil
 }
 
-func (m *Mapper) parsePGType(name string) (typeName string, isArray bool, err error) {
+func (m *Mapper) parsePGType(name string) (typeName string, params []string, isArray bool, err error) {
 	inputName := name
 
 	if strings.HasSuffix(name, "[]") { // detect and strip array suffix. this is always last.
@@ -301,25 +396,166 @@ func (m *Mapper) parsePGType(name string) (typeName string, isArray bool, err er
 	if strings.HasSuffix(name, ")") { // detect and strip parameters suffix. this is always last.
 		openingBracketIndex := strings.LastIndex(name, "(")
 		if openingBracketIndex == -1 {
-			return "", false, search.ErrTypeInvalid{Input: inputName}
+			return "", nil, false, search.ErrTypeInvalid{Input: inputName}
+		}
+		for _, param := range strings.Split(name[openingBracketIndex+1:len(name)-1], ",") {
+			params = append(params, strings.TrimSpace(param))
 		}
 		name = name[:openingBracketIndex]
 	}
 
-	return name, isArray, nil
+	return name, params, isArray, nil
 }
 
-func isPGVector(colType string) bool {
-	// pgvector includes the schema (sometimes? seems only a problem when
-	// testing locally), make sure we remove it before checking for the type
-	parts := strings.Split(colType, ".")
-	if len(parts) > 1 {
-		colType = parts[1]
+// numericScalingFactor returns the scaling factor for the numeric type
+// parameters on input (precision and optional scale), or 0 if the values can't
+// be stored as a scaled float.
+func numericScalingFactor(params []string) int {
+	if len(params) == 0 {
+		return 0
+	}
+	precision, err := strconv.Atoi(params[0])
+	if err != nil || precision > maxScaledFloatPrecision {
+		return 0
+	}
+	scale := 0
+	if len(params) > 1 {
+		if scale, err = strconv.Atoi(params[1]); err != nil || scale < 0 {
+			return 0
+		}
+	}
+
+	factor := 1
+	for i := 0; i < scale; i++ {
+		factor *= 10
 	}
-	return strings.HasPrefix(colType, "vector(")
+	return factor
+}
+
+// isPointGeometry returns true if the PostGIS geometry subtype on input is a
+// point, with any dimensions (i.e. Point, PointZ, PointZM).
+func isPointGeometry(subtype string) bool {
+	return strings.HasPrefix(strings.ToLower(subtype), "point")
+}
+
+// rangeMapping returns the search range mapping for the postgres range type.
+func rangeMapping(rangeType string) map[string]any {
+	switch rangeType {
+	case "int4range":
+		return map[string]any{"type": "integer_range"}
+	case "int8range":
+		return map[string]any{"type": "long_range"}
+	case "numrange":
+		return map[string]any{"type": "double_range"}
+	case "daterange":
+		return map[string]any{"type": "date_range", "format": "date"}
+	default:
+		return map[string]any{"type": "date_range", "format": dateTimeMappingFormat}
+	}
+}
+
+// mapRange maps the postgres range on input into a search range value, with
+// the gt(e)/lt(e) bounds. Unbounded sides are omitted, and empty ranges are
+// mapped to nil.
+func (m *Mapper) mapRange(searchField *searchField, value any) (any, error) {
+	var rangeValue map[string]any
+	var err error
+	switch searchField.metadata.rangeType {
+	case "int4range", "int8range":
+		rangeValue, err = scanRange(m.pgTypeMap, value, func(v pgtype.Int8) any { return v.Int64 })
+	case "numrange":
+		rangeValue, err = scanRange(m.pgTypeMap, value, func(v pgtype.Float8) any { return v.Float64 })
+	case "daterange":
+		rangeValue, err = scanRange(m.pgTypeMap, value, func(v pgtype.Date) any { return v.Time.Format(dateFormat) })
+	case "tsrange":
+		rangeValue, err = scanRange(m.pgTypeMap, value, func(v pgtype.Timestamp) any {
+			return v.Time.Truncate(time.Millisecond).Format(timestampFormat)
+		})
+	case "tstzrange":
+		rangeValue, err = scanRange(m.pgTypeMap, value, func(v pgtype.Timestamptz) any {
+			return v.Time.Truncate(time.Millisecond).Format(timestampTZFormat)
+		})
+	default:
+		return nil, search.ErrTypeInvalid{Input: searchField.metadata.rangeType}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mapping %s from pg to ES failed: %w (value: %v)", searchField.metadata.rangeType, err, value)
+	}
+	if rangeValue == nil {
+		return nil, nil
+	}
+	return rangeValue, nil
+}
+
+func scanRange[T any](typeMap *pgtype.Map, value any, boundValue func(T) any) (map[string]any, error) {
+	var r pgtype.Range[T]
+	if err := typeMap.SQLScanner(&r).Scan(value); err != nil {
+		return nil, err
+	}
+	if !r.Valid || r.LowerType == pgtype.Empty {
+		return nil, nil
+	}
+
+	rangeValue := map[string]any{}
+	switch r.LowerType {
+	case pgtype.Inclusive:
+		rangeValue["gte"] = boundValue(r.Lower)
+	case pgtype.Exclusive:
+		rangeValue["gt"] = boundValue(r.Lower)
+	}
+	switch r.UpperType {
+	case pgtype.Inclusive:
+		rangeValue["lte"] = boundValue(r.Upper)
+	case pgtype.Exclusive:
+		rangeValue["lt"] = boundValue(r.Upper)
+	}
+	return rangeValue, nil
+}
+
+// mapGeometry maps the hex encoded (E)WKB geometry on input into a geo point
+// ({"lat", "lon"}) or a GeoJSON geo shape, depending on the search type.
+func mapGeometry(searchField *searchField, value any) (any, error) {
+	stringContent, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected value type for geometry column: %T", value)
+	}
+	geometry, err := decodeWKBHex(stringContent)
+	if err != nil {
+		return nil, fmt.Errorf("mapping geometry from pg to ES failed: %w", err)
+	}
+	if geometry == nil {
+		return nil, nil
+	}
+
+	if searchField.searchType == searchTypeGeoPoint {
+		coordinates, ok := geometry["coordinates"].([]float64)
+		if geometry["type"] != "Point" || !ok {
+			return nil, fmt.Errorf("mapping geo point from pg to ES failed: unexpected geometry type %v", geometry["type"])
+		}
+		return map[string]any{
+			"lat": coordinates[1],
+			"lon": coordinates[0],
+		}, nil
+	}
+	return geometry, nil
+}
+
+func isPGVector(colType string) bool {
+	return strings.HasPrefix(unqualifiedType(colType), "vector(")
 }
 
 func getPGVectorDimension(colType string) (int, error) {
-	dimensionStr := strings.TrimSuffix(strings.TrimPrefix(colType, "vector("), ")")
+	dimensionStr := strings.TrimSuffix(strings.TrimPrefix(unqualifiedType(colType), "vector("), ")")
 	return strconv.Atoi(dimensionStr)
 }
+
+// unqualifiedType removes the schema from the type name. pgvector includes the
+// schema (sometimes? seems only a problem when testing locally), make sure we
+// remove it before checking for the type
+func unqualifiedType(colType string) string {
+	parts := strings.Split(colType, ".")
+	if len(parts) > 1 {
+		return parts[1]
+	}
+	return colType
+}
@@ -16,6 +16,7 @@ import (
 func TestMapper_ColumnToSearchMapping(t *testing.T) {
 	tests := map[string]struct {
 		pg             string
+		enum           bool
 		columnMetadata *string
 		mapping        map[string]any
 	}{
@@ -49,7 +50,15 @@ func TestMapper_ColumnToSearchMapping(t *testing.T) {
 		},
 		"numeric(5,2)": {
 			pg:      "numeric(5,2)",
-			mapping: map[string]any{"type": "double"},
+			mapping: map[string]any{"type": "scaled_float", "scaling_factor": 100},
+		},
+		"numeric(10)": {
+			pg:      "numeric(10)",
+			mapping: map[string]any{"type": "scaled_float", "scaling_factor": 1},
+		},
+		"numeric(10,2)[]": {
+			pg:      "numeric(10,2)[]",
+			mapping: map[string]any{"type": "scaled_float", "scaling_factor": 100},
 		},
 		"numeric(100,2)[]": {
 			pg:      "numeric(100,2)[]",
@@ -170,6 +179,71 @@ func TestMapper_ColumnToSearchMapping(t *testing.T) {
 				},
 			},
 		},
+		"enum": {
+			pg:      "public.mood",
+			enum:    true,
+			mapping: map[string]any{"type": "keyword"},
+		},
+		"enum[]": {
+			pg:      "mood[]",
+			enum:    true,
+			mapping: map[string]any{"type": "keyword"},
+		},
+		"geometry point": {
+			pg:      "public.geometry(Point,4326)",
+			mapping: map[string]any{"type": "geo_point"},
+		},
+		"geography pointz": {
+			pg:      "geography(PointZ,4326)",
+			mapping: map[string]any{"type": "geo_point"},
+		},
+		"geometry polygon": {
+			pg:      "public.geometry(Polygon,4326)",
+			mapping: map[string]any{"type": "geo_shape"},
+		},
+		"geometry": {
+			pg:      "geometry",
+			mapping: map[string]any{"type": "geo_shape"},
+		},
+		"int4range": {
+			pg:      "int4range",
+			mapping: map[string]any{"type": "integer_range"},
+		},
+		"int8range": {
+			pg:      "int8range",
+			mapping: map[string]any{"type": "long_range"},
+		},
+		"numrange": {
+			pg:      "numrange",
+			mapping: map[string]any{"type": "double_range"},
+		},
+		"daterange": {
+			pg:      "daterange",
+			mapping: map[string]any{"type": "date_range", "format": "date"},
+		},
+		"tstzrange": {
+			pg: "tstzrange",
+			mapping: map[string]any{
+				"type":   "date_range",
+				"format": "yyyy-MM-dd HH:mm:ss[.SSS][x]||yyyy-MM-dd HH:mm:ss[.SS][x]||yyyy-MM-dd HH:mm:ss[.S][x]||yyyy-MM-dd'T'HH:mm:ss[.SSS][X]",
+			},
+		},
+		"hstore": {
+			pg:      "public.hstore",
+			mapping: map[string]any{"type": "object", "dynamic": true},
+		},
+		"date[]": {
+			pg:      "date[]",
+			mapping: map[string]any{"type": "date", "format": "date"},
+		},
+		"vector": {
+			pg:      "vector(3)",
+			mapping: map[string]any{"type": "knn_vector", "dimension": 3},
+		},
+		"vector with schema": {
+			pg:      "extensions.vector(1536)",
+			mapping: map[string]any{"type": "knn_vector", "dimension": 1536},
+		},
 	}
 
 	for name, test := range tests {
@@ -177,6 +251,7 @@ func TestMapper_ColumnToSearchMapping(t *testing.T) {
 			m := NewPostgresMapper()
 			mapping, err := m.ColumnToSearchMapping(schemalog.Column{
 				DataType: test.pg,
+				Enum:     test.enum,
 				Metadata: test.columnMetadata,
 			})
 			require.NoError(t, err)
@@ -193,6 +268,12 @@ func TestMapper_ColumnToSearchMapping(t *testing.T) {
 		"badly formatted parameters": {
 			pg: "numeric)[]",
 		},
+		"range array": {
+			pg: "int4range[]",
+		},
+		"geometry array": {
+			pg: "public.geometry(Point,4326)[]",
+		},
 	}
 
 	for name, test := range errorTests {
@@ -264,6 +345,140 @@ func TestMapper_MapColumnValue(t *testing.T) {
 			wantValue: []string{tsNow},
 			wantErr:   nil,
 		},
+		{
+			name:   "date array",
+			column: schemalog.Column{DataType: "date[]"},
+			value:  "{2024-03-12,2024-03-13}",
+
+			wantValue: []string{"2024-03-12", "2024-03-13"},
+			wantErr:   nil,
+		},
+		{
+			name:   "time array",
+			column: schemalog.Column{DataType: "time[]"},
+			value:  "{10:00:00,11:30:00}",
+
+			wantValue: []string{"10:00:00", "11:30:00"},
+			wantErr:   nil,
+		},
+		{
+			name:   "uuid array",
+			column: schemalog.Column{DataType: "uuid[]"},
+			value:  "{a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11,b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a12}",
+
+			wantValue: []string{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a12"},
+			wantErr:   nil,
+		},
+		{
+			name:   "enum",
+			column: schemalog.Column{DataType: "public.mood", Enum: true},
+			value:  "happy",
+
+			wantValue: "happy",
+			wantErr:   nil,
+		},
+		{
+			name:   "enum array",
+			column: schemalog.Column{DataType: "public.mood[]", Enum: true},
+			value:  "{happy,sad}",
+
+			wantValue: []string{"happy", "sad"},
+			wantErr:   nil,
+		},
+		{
+			name:   "numeric with precision",
+			column: schemalog.Column{DataType: "numeric(10,2)"},
+			value:  12.34,
+
+			wantValue: 12.34,
+			wantErr:   nil,
+		},
+		{
+			name:   "int4range",
+			column: schemalog.Column{DataType: "int4range"},
+			value:  "[1,10)",
+
+			wantValue: map[string]any{"gte": int64(1), "lt": int64(10)},
+			wantErr:   nil,
+		},
+		{
+			name:   "numrange unbounded",
+			column: schemalog.Column{DataType: "numrange"},
+			value:  "(1.5,)",
+
+			wantValue: map[string]any{"gt": 1.5},
+			wantErr:   nil,
+		},
+		{
+			name:   "daterange",
+			column: schemalog.Column{DataType: "daterange"},
+			value:  "[2024-03-12,2024-04-01)",
+
+			wantValue: map[string]any{"gte": "2024-03-12", "lt": "2024-04-01"},
+			wantErr:   nil,
+		},
+		{
+			name:   "tstzrange",
+			column: schemalog.Column{DataType: "tstzrange"},
+			value:  `["2024-03-12 10:00:00+00","2024-03-12 11:00:00+00"]`,
+
+			wantValue: map[string]any{"gte": "2024-03-12T10:00:00.000Z", "lte": "2024-03-12T11:00:00.000Z"},
+			wantErr:   nil,
+		},
+		{
+			name:   "empty range",
+			column: schemalog.Column{DataType: "int8range"},
+			value:  "empty",
+
+			wantValue: nil,
+			wantErr:   nil,
+		},
+		{
+			name:   "hstore",
+			column: schemalog.Column{DataType: "public.hstore"},
+			value:  `"a"=>"1", "b"=>NULL`,
+
+			wantValue: map[string]any{"a": "1", "b": nil},
+			wantErr:   nil,
+		},
+		{
+			name:   "geo point",
+			column: schemalog.Column{DataType: "public.geography(Point,4326)"},
+			value:  "0101000020E61000009A99999999990DC03333333333334440",
+
+			wantValue: map[string]any{"lat": 40.4, "lon": -3.7},
+			wantErr:   nil,
+		},
+		{
+			name:   "geo shape polygon",
+			column: schemalog.Column{DataType: "public.geometry(Polygon,4326)"},
+			value:  "0103000000010000000400000000000000000000000000000000000000000000000000F03F0000000000000000000000000000F03F000000000000F03F00000000000000000000000000000000",
+
+			wantValue: map[string]any{
+				"type":        "Polygon",
+				"coordinates": [][][]float64{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "geo shape multipoint with z",
+			column: schemalog.Column{DataType: "public.geometry"},
+			value:  "0104000080020000000101000080000000000000F03F000000000000004000000000000022400101000080000000000000084000000000000010400000000000002240",
+
+			wantValue: map[string]any{
+				"type":        "MultiPoint",
+				"coordinates": []any{[]float64{1, 2}, []float64{3, 4}},
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "invalid geometry",
+			column: schemalog.Column{DataType: "public.geometry"},
+			value:  "0101000020E6",
+
+			wantValue: nil,
+			wantErr:   errInvalidWKB,
+		},
 		{
 			name:   "unknonwn column type",
 			column: schemalog.Column{DataType: "custom_type"},
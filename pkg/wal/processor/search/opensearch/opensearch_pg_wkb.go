@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// wkbReader decodes the (E)WKB geometries emitted by PostGIS for geometry and
+// geography columns into GeoJSON, which is the format used by the search store
+// geo_shape fields. The Z and M coordinates, and the SRID, are discarded.
+type wkbReader struct {
+	data []byte
+	pos  int
+}
+
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+
+	ewkbZFlag    = 0x80000000
+	ewkbMFlag    = 0x40000000
+	ewkbSRIDFlag = 0x20000000
+)
+
+var errInvalidWKB = errors.New("invalid wkb geometry")
+
+// decodeWKBHex returns the GeoJSON geometry for the hex encoded (E)WKB on
+// input. Empty points are returned as nil, since they can't be represented in
+// GeoJSON.
+func decodeWKBHex(s string) (map[string]any, error) {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidWKB, err)
+	}
+
+	r := &wkbReader{data: data}
+	geometry, err := r.readGeometry()
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(r.data) {
+		return nil, fmt.Errorf("%w: unexpected trailing bytes", errInvalidWKB)
+	}
+	return geometry, nil
+}
+
+func (r *wkbReader) readGeometry() (map[string]any, error) {
+	if r.pos >= len(r.data) {
+		return nil, fmt.Errorf("%w: unexpected end of geometry", errInvalidWKB)
+	}
+	var order binary.ByteOrder
+	switch r.data[r.pos] {
+	case 0:
+		order = binary.BigEndian
+	case 1:
+		order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("%w: unknown byte order %d", errInvalidWKB, r.data[r.pos])
+	}
+	r.pos++
+
+	geometryType, err := r.readUint32(order)
+	if err != nil {
+		return nil, err
+	}
+
+	dimensions := 2
+	if geometryType&ewkbZFlag != 0 {
+		dimensions++
+	}
+	if geometryType&ewkbMFlag != 0 {
+		dimensions++
+	}
+	if geometryType&ewkbSRIDFlag != 0 {
+		if _, err := r.readUint32(order); err != nil {
+			return nil, err
+		}
+	}
+	geometryType &= 0x0fffffff
+	// ISO WKB encodes the Z and M dimensions in the type thousands
+	switch geometryType / 1000 {
+	case 1, 2:
+		dimensions++
+	case 3:
+		dimensions += 2
+	}
+	geometryType %= 1000
+
+	switch geometryType {
+	case wkbPoint:
+		point, err := r.readPoint(order, dimensions)
+		if err != nil || point == nil {
+			return nil, err
+		}
+		return geoJSON("Point", point), nil
+	case wkbLineString:
+		points, err := r.readPoints(order, dimensions)
+		if err != nil {
+			return nil, err
+		}
+		return geoJSON("LineString", points), nil
+	case wkbPolygon:
+		rings, err := r.readRings(order, dimensions)
+		if err != nil {
+			return nil, err
+		}
+		return geoJSON("Polygon", rings), nil
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		n, err := r.readCount(order)
+		if err != nil {
+			return nil, err
+		}
+		geometries := make([]any, 0, n)
+		coordinates := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			geometry, err := r.readGeometry()
+			if err != nil {
+				return nil, err
+			}
+			if geometry == nil {
+				continue
+			}
+			geometries = append(geometries, geometry)
+			coordinates = append(coordinates, geometry["coordinates"])
+		}
+
+		switch geometryType {
+		case wkbMultiPoint:
+			return geoJSON("MultiPoint", coordinates), nil
+		case wkbMultiLineString:
+			return geoJSON("MultiLineString", coordinates), nil
+		case wkbMultiPolygon:
+			return geoJSON("MultiPolygon", coordinates), nil
+		default:
+			return map[string]any{
+				"type":       "GeometryCollection",
+				"geometries": geometries,
+			}, nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported geometry type %d", errInvalidWKB, geometryType)
+	}
+}
+
+// readPoint returns the x and y coordinates of the point, or nil if the point
+// is empty.
+func (r *wkbReader) readPoint(order binary.ByteOrder, dimensions int) ([]float64, error) {
+	coordinates := make([]float64, 0, dimensions)
+	for i := 0; i < dimensions; i++ {
+		v, err := r.readUint64(order)
+		if err != nil {
+			return nil, err
+		}
+		coordinates = append(coordinates, math.Float64frombits(v))
+	}
+	if math.IsNaN(coordinates[0]) && math.IsNaN(coordinates[1]) {
+		return nil, nil
+	}
+	return coordinates[:2], nil
+}
+
+func (r *wkbReader) readPoints(order binary.ByteOrder, dimensions int) ([][]float64, error) {
+	n, err := r.readCount(order)
+	if err != nil {
+		return nil, err
+	}
+	points := make([][]float64, 0, n)
+	for i := 0; i < n; i++ {
+		point, err := r.readPoint(order, dimensions)
+		if err != nil {
+			return nil, err
+		}
+		if point == nil {
+			return nil, fmt.Errorf("%w: empty point in line", errInvalidWKB)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+func (r *wkbReader) readRings(order binary.ByteOrder, dimensions int) ([][][]float64, error) {
+	n, err := r.readCount(order)
+	if err != nil {
+		return nil, err
+	}
+	rings := make([][][]float64, 0, n)
+	for i := 0; i < n; i++ {
+		ring, err := r.readPoints(order, dimensions)
+		if err != nil {
+			return nil, err
+		}
+		rings = append(rings, ring)
+	}
+	return rings, nil
+}
+
+// readCount reads the number of elements of a geometry, making sure there are
+// enough bytes left for them so that invalid input doesn't cause big
+// allocations.
+func (r *wkbReader) readCount(order binary.ByteOrder) (int, error) {
+	n, err := r.readUint32(order)
+	if err != nil {
+		return 0, err
+	}
+	if int(n) > len(r.data)-r.pos {
+		return 0, fmt.Errorf("%w: element count %d exceeds geometry size", errInvalidWKB, n)
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) readUint32(order binary.ByteOrder) (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, fmt.Errorf("%w: unexpected end of geometry", errInvalidWKB)
+	}
+	v := order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+func (r *wkbReader) readUint64(order binary.ByteOrder) (uint64, error) {
+	if len(r.data)-r.pos < 8 {
+		return 0, fmt.Errorf("%w: unexpected end of geometry", errInvalidWKB)
+	}
+	v := order.Uint64(r.data[r.pos:])
+	r.pos += 8
+	return v, nil
+}
+
+func geoJSON(geometryType string, coordinates any) map[string]any {
+	return map[string]any{
+		"type":        geometryType,
+		"coordinates": coordinates,
+	}
+}
@@ -0,0 +1,565 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/internal/es"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// RolloverConfig routes the documents of an append-only table (i.e. logs,
+// audit trails) to time bucketed indices, based on the value of a timestamp
+// column. All the buckets are queried through the table index alias.
+type RolloverConfig struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// TimestampColumn is the timestamp or date column that determines the
+	// bucket of the documents. Its value is not expected to change once the
+	// row is inserted.
+	TimestampColumn string           `json:"timestamp_column"`
+	Interval        RolloverInterval `json:"interval"`
+	// Retention is the number of buckets kept, including the current one.
+	// The older buckets are deleted once per interval, on the first write or
+	// schema change of the table. All the buckets are kept if 0.
+	Retention int `json:"retention,omitempty"`
+}
+
+// RolloverInterval is the time interval covered by each bucket index.
+type RolloverInterval string
+
+const (
+	RolloverDaily   RolloverInterval = "daily"
+	RolloverMonthly RolloverInterval = "monthly"
+)
+
+var (
+	errUnsupportedRolloverInterval = errors.New("unsupported rollover interval")
+	errRolloverTimestampNotFound   = errors.New("rollover timestamp not found in document")
+	errInvalidRolloverTimestamp    = errors.New("invalid rollover timestamp")
+	errRolloverBucketExpired       = errors.New("rollover bucket is older than the retention")
+)
+
+func (c *RolloverConfig) validate() error {
+	if c.Schema == "" || c.Table == "" || c.TimestampColumn == "" {
+		return errors.New("rollover requires schema, table and timestamp column names")
+	}
+	if err := c.Interval.validate(); err != nil {
+		return err
+	}
+	if c.Retention < 0 {
+		return errors.New("retention can't be negative")
+	}
+	return nil
+}
+
+func (i RolloverInterval) validate() error {
+	switch i {
+	case RolloverDaily, RolloverMonthly:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errUnsupportedRolloverInterval, i)
+	}
+}
+
+// layout is the time layout of the bucket names. They can't contain '-',
+// which separates the index version.
+func (i RolloverInterval) layout() string {
+	if i == RolloverMonthly {
+		return "2006.01"
+	}
+	return "2006.01.02"
+}
+
+// start returns the start of the bucket the time on input belongs to, in UTC.
+func (i RolloverInterval) start(t time.Time) time.Time {
+	t = t.UTC()
+	if i == RolloverMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// add returns the start of the bucket n intervals after the bucket start on
+// input.
+func (i RolloverInterval) add(start time.Time, n int) time.Time {
+	if i == RolloverMonthly {
+		return start.AddDate(0, n, 0)
+	}
+	return start.AddDate(0, 0, n)
+}
+
+// rolloverIndexName is the name of a bucket index of a rollover table, with
+// format <schema>.<table_id>.<bucket>-<version>. All the buckets of the table
+// share the table index alias.
+type rolloverIndexName struct {
+	tableIndexName
+	bucket string
+}
+
+func (i *rolloverIndexName) NameWithVersion() string {
+	return fmt.Sprintf("%s.%s-%d", tableIndexPrefix(i.schemaName, i.tableID), i.bucket, i.version)
+}
+
+// rolloverTable is a table routed to bucket indices, along with its latest
+// schema.
+type rolloverTable struct {
+	config *RolloverConfig
+	index  IndexName
+	table  *schemalog.Table
+	// timestampColumnID is the pgstream id of the timestamp column, used as
+	// the document field name.
+	timestampColumnID string
+}
+
+func (t *rolloverTable) bucketIndex(start time.Time) IndexName {
+	tableIndex := t.index.(*tableIndexName)
+	return &rolloverIndexName{
+		tableIndexName: *tableIndex,
+		bucket:         start.Format(t.config.Interval.layout()),
+	}
+}
+
+// bucketsPattern is the index pattern that matches all the table buckets.
+func (t *rolloverTable) bucketsPattern() string {
+	tableIndex := t.index.(*tableIndexName)
+	return tableIndexPrefix(tableIndex.schemaName, tableIndex.tableID) + ".*"
+}
+
+// retentionStart returns the start of the oldest bucket kept by the retention
+// at the time on input, or the zero time if all the buckets are kept.
+func (t *rolloverTable) retentionStart(now time.Time) time.Time {
+	if t.config.Retention == 0 {
+		return time.Time{}
+	}
+	interval := t.config.Interval
+	return interval.add(interval.start(now), 1-t.config.Retention)
+}
+
+// rolloverConfig returns the rollover configuration of the schema table on
+// input, or nil if it's not routed to bucket indices.
+func (s *Store) rolloverConfig(schemaName, tableName string) *RolloverConfig {
+	if s.mappingConfig == nil || s.indexLayout == IndexPerSchema {
+		return nil
+	}
+	for i := range s.mappingConfig.Rollover {
+		r := &s.mappingConfig.Rollover[i]
+		if r.Schema == schemaName && r.Table == tableName {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *Store) hasRollover(schemaName string) bool {
+	if s.mappingConfig == nil || s.indexLayout == IndexPerSchema {
+		return false
+	}
+	for _, r := range s.mappingConfig.Rollover {
+		if r.Schema == schemaName {
+			return true
+		}
+	}
+	return false
+}
+
+// newRolloverTable returns the rollover table for the schema table on input,
+// or nil if it's not configured for rollover. Tables without the timestamp
+// column use a regular table index.
+func (s *Store) newRolloverTable(schemaName string, table *schemalog.Table) *rolloverTable {
+	cfg := s.rolloverConfig(schemaName, table.Name)
+	if cfg == nil {
+		return nil
+	}
+	c := table.GetColumnByName(cfg.TimestampColumn)
+	if c == nil {
+		s.logger.Warn(nil, "opensearch store: rollover timestamp column not found, using a regular table index", loglib.Fields{
+			"schema": schemaName,
+			"table":  table.Name,
+			"column": cfg.TimestampColumn,
+		})
+		return nil
+	}
+	return &rolloverTable{
+		config:            cfg,
+		index:             s.tableIndexName(schemaName, table),
+		table:             table,
+		timestampColumnID: c.PgstreamID,
+	}
+}
+
+func (s *Store) setRolloverTables(logEntry *schemalog.LogEntry) {
+	if !s.hasRollover(logEntry.SchemaName) {
+		return
+	}
+
+	tables := map[string]*rolloverTable{}
+	for i := range logEntry.Schema.Tables {
+		table := &logEntry.Schema.Tables[i]
+		if rt := s.newRolloverTable(logEntry.SchemaName, table); rt != nil {
+			tables[table.PgstreamID] = rt
+		}
+	}
+
+	s.rolloverTablesLock.Lock()
+	defer s.rolloverTablesLock.Unlock()
+	s.rolloverTables[logEntry.SchemaName] = tables
+}
+
+// getRolloverTable returns the rollover table with the pgstream id on input,
+// or nil if the table is not routed to bucket indices. The rollover tables
+// are loaded from the latest schema log entry if they're not known yet (i.e.
+// after a restart).
+func (s *Store) getRolloverTable(ctx context.Context, schemaName, tableID string) (*rolloverTable, error) {
+	if !s.hasRollover(schemaName) {
+		return nil, nil
+	}
+
+	s.rolloverTablesLock.RLock()
+	tables, found := s.rolloverTables[schemaName]
+	s.rolloverTablesLock.RUnlock()
+	if found {
+		return tables[tableID], nil
+	}
+
+	logEntry, err := s.getLastSchemaLogEntry(ctx, schemaName)
+	if err != nil {
+		if errors.As(err, &search.ErrSchemaNotFound{}) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	s.setRolloverTables(logEntry)
+
+	s.rolloverTablesLock.RLock()
+	defer s.rolloverTablesLock.RUnlock()
+	return s.rolloverTables[schemaName][tableID], nil
+}
+
+// documentIndexName returns the name of the index the document of a table
+// index is written to. It's the bucket index for the rollover tables, and the
+// table index alias otherwise.
+func (s *Store) documentIndexName(ctx context.Context, doc search.Document, index IndexName) (string, error) {
+	tableID, _ := doc.Data["_table"].(string)
+	rt, err := s.getRolloverTable(ctx, doc.Schema, tableID)
+	if err != nil {
+		return "", err
+	}
+	if rt == nil {
+		return index.Name(), nil
+	}
+
+	ts, err := rolloverTimestamp(doc.Data[rt.timestampColumnID])
+	if err != nil {
+		return "", err
+	}
+	start := rt.config.Interval.start(ts)
+	if start.Before(rt.retentionStart(s.clock())) {
+		return "", fmt.Errorf("%s: %w", rt.bucketIndex(start).NameWithVersion(), errRolloverBucketExpired)
+	}
+
+	bucket, err := s.ensureRolloverBucket(ctx, rt, start)
+	if err != nil {
+		return "", err
+	}
+	return bucket.NameWithVersion(), nil
+}
+
+// rolloverTimestamp parses the timestamp column value of a document, as
+// formatted by the mapper.
+func rolloverTimestamp(value any) (time.Time, error) {
+	if value == nil {
+		return time.Time{}, errRolloverTimestampNotFound
+	}
+	str, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: unexpected value type %T", errInvalidRolloverTimestamp, value)
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", time.DateOnly} {
+		if ts, err := time.Parse(layout, str); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q", errInvalidRolloverTimestamp, str)
+}
+
+// ensureRolloverBucket makes sure the bucket index starting at the time on
+// input exists, and returns it. New buckets are created with the latest table
+// mapping. The table retention is applied first, if it's due.
+func (s *Store) ensureRolloverBucket(ctx context.Context, rt *rolloverTable, start time.Time) (IndexName, error) {
+	if err := s.applyRolloverRetention(ctx, rt); err != nil {
+		return nil, err
+	}
+
+	bucket := rt.bucketIndex(start)
+	s.rolloverBucketsLock.Lock()
+	_, found := s.rolloverBuckets[bucket.NameWithVersion()]
+	s.rolloverBucketsLock.Unlock()
+	if found {
+		return bucket, nil
+	}
+
+	created, err := s.createRolloverBucket(ctx, rt, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("creating rollover bucket %s: %w", bucket.NameWithVersion(), err)
+	}
+
+	s.rolloverBucketsLock.Lock()
+	s.rolloverBuckets[bucket.NameWithVersion()] = struct{}{}
+	s.rolloverBucketsLock.Unlock()
+
+	if created {
+		s.logger.Info("opensearch store: rollover bucket created", loglib.Fields{
+			"schema": rt.index.SchemaName(),
+			"table":  rt.table.Name,
+			"index":  bucket.NameWithVersion(),
+		})
+	}
+	return bucket, nil
+}
+
+func (s *Store) createRolloverBucket(ctx context.Context, rt *rolloverTable, bucket IndexName) (bool, error) {
+	overrides := s.tableIndexOverrides(bucket.SchemaName(), rt.table)
+	properties := map[string]any{
+		"_table": map[string]any{
+			"type": "keyword",
+		},
+	}
+	for _, c := range rt.table.Columns {
+		mapping, err := s.columnMapping(bucket, overrides, c)
+		if err != nil {
+			return false, err
+		}
+		if mapping != nil {
+			properties[c.PgstreamID] = mapping
+		}
+	}
+
+	created := true
+	err := s.client.CreateIndex(ctx, bucket.NameWithVersion(), map[string]any{
+		"mappings": map[string]any{
+			"dynamic":    "strict",
+			"properties": properties,
+		},
+		"settings": overrides.settings,
+	})
+	if err != nil {
+		if !errors.As(err, &es.ErrResourceAlreadyExists{}) {
+			return false, mapError(err)
+		}
+		created = false
+	}
+	// the soft delete fields are added to the alias mappings on the next soft
+	// delete, so that they include the new bucket
+	s.forgetSoftDeleteMapping(bucket.Name())
+
+	// the alias is added for existing buckets too, in case a previous
+	// creation failed before adding it
+	if err := s.client.PutIndexAlias(ctx, []string{bucket.NameWithVersion()}, bucket.Name()); err != nil {
+		return false, mapError(err)
+	}
+	return created, nil
+}
+
+// applyRolloverRetention deletes the buckets of the table that are older than
+// its retention. It's a no-op if the retention has already been applied since
+// the start of the current bucket.
+func (s *Store) applyRolloverRetention(ctx context.Context, rt *rolloverTable) error {
+	retentionStart := rt.retentionStart(s.clock())
+	if retentionStart.IsZero() {
+		return nil
+	}
+
+	s.rolloverBucketsLock.Lock()
+	applied := s.rolloverRetention[rt.bucketsPattern()]
+	s.rolloverBucketsLock.Unlock()
+	if applied.Equal(retentionStart) {
+		return nil
+	}
+
+	if err := s.deleteExpiredRolloverBuckets(ctx, rt, retentionStart); err != nil {
+		return err
+	}
+
+	s.rolloverBucketsLock.Lock()
+	s.rolloverRetention[rt.bucketsPattern()] = retentionStart
+	s.rolloverBucketsLock.Unlock()
+	return nil
+}
+
+// deleteExpiredRolloverBuckets deletes the buckets of the table that start
+// before the retention start on input.
+func (s *Store) deleteExpiredRolloverBuckets(ctx context.Context, rt *rolloverTable, retentionStart time.Time) error {
+	indices, err := s.client.ListIndices(ctx, []string{rt.bucketsPattern()})
+	if err != nil {
+		return mapError(err)
+	}
+
+	prefix := strings.TrimSuffix(rt.bucketsPattern(), "*")
+	expired := []string{}
+	for _, index := range indices {
+		i := strings.LastIndex(index, "-")
+		if !strings.HasPrefix(index, prefix) || i < len(prefix) {
+			continue
+		}
+		start, err := time.Parse(rt.config.Interval.layout(), index[len(prefix):i])
+		if err != nil {
+			// not a bucket of this table
+			continue
+		}
+		if start.Before(retentionStart) {
+			expired = append(expired, index)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+
+	if err := s.client.DeleteIndex(ctx, expired); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+		return mapError(err)
+	}
+	s.forgetRolloverBuckets(expired...)
+	s.logger.Info("opensearch store: expired rollover buckets deleted", loglib.Fields{
+		"schema":  rt.index.SchemaName(),
+		"table":   rt.table.Name,
+		"indices": expired,
+	})
+	return nil
+}
+
+// updateRolloverIndices applies the schema change on input to the bucket
+// indices of the rollover table. The current bucket is created if it doesn't
+// exist, and the new columns are added to the mapping of all the buckets,
+// since documents can be written to any bucket within the retention. Future
+// buckets are created with the latest table mapping, so column type and
+// identity changes only apply to them.
+func (s *Store) updateRolloverIndices(ctx context.Context, rt *rolloverTable, previousTable *schemalog.Table, exists bool) error {
+	if exists && previousTable != nil {
+		previousIndex := s.tableIndexName(rt.index.SchemaName(), previousTable)
+		if previousIndex.Name() != rt.index.Name() {
+			if err := s.client.UpdateAliases(ctx, []es.AliasAction{
+				{Remove: &es.AliasActionTarget{Index: rt.bucketsPattern(), Alias: previousIndex.Name()}},
+				{Add: &es.AliasActionTarget{Index: rt.bucketsPattern(), Alias: rt.index.Name()}},
+			}); err != nil {
+				return mapError(err)
+			}
+		}
+	}
+
+	bucket, err := s.ensureRolloverBucket(ctx, rt, rt.config.Interval.start(s.clock()))
+	if err != nil {
+		return err
+	}
+	// the buckets of a new table are created with its latest mapping
+	if previousTable == nil {
+		return nil
+	}
+
+	diff := tableDiff(rt.table, previousTable)
+	overrides := s.tableIndexOverrides(bucket.SchemaName(), rt.table)
+	typeChanges, err := s.getColumnTypeChanges(bucket, overrides, diff.ColumnTypeChange)
+	if err != nil {
+		return fmt.Errorf("failed to get column type changes: %w", err)
+	}
+	if len(typeChanges.retyped) > 0 {
+		s.logger.Warn(nil, "opensearch store: rollover column type changes only apply to the future buckets", loglib.Fields{
+			"schema": bucket.SchemaName(),
+			"table":  rt.table.Name,
+			"index":  bucket.NameWithVersion(),
+		})
+	}
+
+	properties := map[string]any{}
+	for _, c := range append(diff.ColumnsToAdd, typeChanges.added...) {
+		mapping, err := s.columnMapping(bucket, overrides, c)
+		if err != nil {
+			return err
+		}
+		if mapping != nil {
+			properties[c.PgstreamID] = mapping
+		}
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+	if err := s.client.PutIndexMappings(ctx, rt.bucketsPattern(), map[string]any{
+		"properties": properties,
+	}); err != nil {
+		return fmt.Errorf("failed to add new columns: %w", mapError(err))
+	}
+	return nil
+}
+
+// deleteRolloverIndices deletes all the bucket indices of the rollover table.
+func (s *Store) deleteRolloverIndices(ctx context.Context, rt *rolloverTable) error {
+	indices, err := s.client.ListIndices(ctx, []string{rt.bucketsPattern()})
+	if err != nil {
+		return mapError(err)
+	}
+	if len(indices) == 0 {
+		return nil
+	}
+	if err := s.client.DeleteIndex(ctx, indices); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+		return mapError(err)
+	}
+	s.forgetRolloverBuckets(indices...)
+	s.forgetSoftDeleteMapping(rt.index.Name())
+	return nil
+}
+
+// deleteRolloverDocuments deletes the documents with the ids on input from all
+// the buckets of their table, by table index alias. It's used for the deletes
+// that don't include the timestamp column (i.e. tables without full replica
+// identity), whose bucket is unknown.
+func (s *Store) deleteRolloverDocuments(ctx context.Context, docIDs map[string][]string) error {
+	for alias, ids := range docIDs {
+		if err := s.client.DeleteByQuery(ctx, &es.DeleteByQueryRequest{
+			Index: []string{alias},
+			Query: map[string]any{
+				"query": map[string]any{
+					"ids": map[string]any{"values": ids},
+				},
+			},
+		}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) forgetRolloverBuckets(indices ...string) {
+	s.rolloverBucketsLock.Lock()
+	defer s.rolloverBucketsLock.Unlock()
+	for _, index := range indices {
+		delete(s.rolloverBuckets, index)
+	}
+}
+
+// forgetRolloverSchema removes the rollover tables and buckets of the schema
+// from the store caches.
+func (s *Store) forgetRolloverSchema(schemaName string) {
+	s.rolloverTablesLock.Lock()
+	delete(s.rolloverTables, schemaName)
+	s.rolloverTablesLock.Unlock()
+
+	prefix := tableIndexPrefix(schemaName, "")
+	s.rolloverBucketsLock.Lock()
+	defer s.rolloverBucketsLock.Unlock()
+	for index := range s.rolloverBuckets {
+		if strings.HasPrefix(index, prefix) {
+			delete(s.rolloverBuckets, index)
+		}
+	}
+	for pattern := range s.rolloverRetention {
+		if strings.HasPrefix(pattern, prefix) {
+			delete(s.rolloverRetention, pattern)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+	searchmocks "github.com/xataio/pgstream/pkg/wal/processor/search/mocks"
+)
+
+func TestRolloverTimestamp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value any
+
+		wantTime time.Time
+		wantErr  error
+	}{
+		{
+			name:     "timestamptz",
+			value:    "2026-10-18T10:30:00.000Z",
+			wantTime: time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "timestamp",
+			value:    "2026-10-18T10:30:00.000",
+			wantTime: time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "date",
+			value:    "2026-10-18",
+			wantTime: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "null",
+			value:   nil,
+			wantErr: errRolloverTimestampNotFound,
+		},
+		{
+			name:    "invalid",
+			value:   int64(1),
+			wantErr: errInvalidRolloverTimestamp,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts, err := rolloverTimestamp(tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.True(t, tc.wantTime.Equal(ts), ts)
+		})
+	}
+}
+
+func TestRolloverTable_buckets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)
+	index := newTableIndexName("test_schema", "t1", "test_schema.events")
+
+	daily := &rolloverTable{
+		config: &RolloverConfig{Interval: RolloverDaily, Retention: 7},
+		index:  index,
+	}
+	require.Equal(t, "test_schema.t1.2026.10.18-1", daily.bucketIndex(RolloverDaily.start(now)).NameWithVersion())
+	require.Equal(t, "test_schema.events", daily.bucketIndex(RolloverDaily.start(now)).Name())
+	require.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), daily.retentionStart(now))
+
+	monthly := &rolloverTable{
+		config: &RolloverConfig{Interval: RolloverMonthly},
+		index:  index,
+	}
+	require.Equal(t, "test_schema.t1.2026.10-1", monthly.bucketIndex(RolloverMonthly.start(now)).NameWithVersion())
+	require.Equal(t, "test_schema.t1.*", monthly.bucketsPattern())
+	require.True(t, monthly.retentionStart(now).IsZero())
+}
+
+func TestStore_SendDocuments_rollover(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testNow := time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)
+	testLogEntry := &schemalog.LogEntry{
+		SchemaName: testSchemaName,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					PgstreamID: "t1",
+					Name:       "events",
+					Columns: []schemalog.Column{
+						{PgstreamID: "t1-1", Name: "id", DataType: "int8"},
+						{PgstreamID: "t1-2", Name: "created_at", DataType: "timestamptz"},
+					},
+				},
+				{PgstreamID: "t2", Name: "users"},
+			},
+		},
+	}
+	testLogEntryBytes, err := json.Marshal(testLogEntry)
+	require.NoError(t, err)
+	testLogEntryRecord := map[string]any{}
+	require.NoError(t, json.Unmarshal(testLogEntryBytes, &testLogEntryRecord))
+
+	testDocs := []search.Document{
+		{ID: "t1_1", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t1", "t1-2": "2026-10-18T10:00:00.000Z"}},
+		{ID: "t1_2", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t1", "t1-2": "2026-10-17T23:59:59.999Z"}},
+		// older than the retention
+		{ID: "t1_3", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t1", "t1-2": "2026-10-10T00:00:00.000Z"}},
+		// write without timestamp
+		{ID: "t1_4", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t1"}},
+		// delete without timestamp
+		{ID: "t1_5", Schema: testSchemaName, Version: 2, Data: map[string]any{"_table": "t1"}, Delete: true},
+		{ID: "t2_1", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t2"}},
+	}
+
+	calls := []string{}
+	record := func(format string, args ...any) {
+		calls = append(calls, fmt.Sprintf(format, args...))
+	}
+	client := &esmocks.Client{
+		GetIndicesMetaFn: noIndexMigrations,
+		SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+			return &es.SearchResponse{
+				Hits: es.Hits{Hits: []es.Hit{{Source: testLogEntryRecord}}},
+			}, nil
+		},
+		CreateIndexFn: func(ctx context.Context, index string, body map[string]any) error {
+			record("create %s", index)
+			mappings, ok := body["mappings"].(map[string]any)
+			require.True(t, ok)
+			require.Len(t, mappings["properties"], 3)
+			if index == "test_schema.t1.2026.10.17-1" {
+				return es.ErrResourceAlreadyExists{}
+			}
+			return nil
+		},
+		PutIndexAliasFn: func(ctx context.Context, index []string, name string) error {
+			record("put alias %v %s", index, name)
+			return nil
+		},
+		ListIndicesFn: func(ctx context.Context, indices []string) ([]string, error) {
+			record("list %v", indices)
+			return []string{"test_schema.t1.2026.10.16-1", "test_schema.t1.2026.10.17-1", "test_schema.t1.2026.10.18-1"}, nil
+		},
+		DeleteIndexFn: func(ctx context.Context, index []string) error {
+			record("delete %v", index)
+			return nil
+		},
+		DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+			record("delete by query %v %v", req.Index, req.Query)
+			return nil
+		},
+		SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
+			indices := []string{}
+			for _, item := range items {
+				indices = append(indices, bulkItemIndex(item))
+			}
+			record("bulk %v", indices)
+			return nil, nil
+		},
+	}
+
+	s := NewStoreWithClient(client)
+	s.indexLayout = IndexPerTable
+	s.mappingConfig = &MappingConfig{
+		Rollover: []RolloverConfig{
+			{Schema: testSchemaName, Table: "events", TimestampColumn: "created_at", Interval: RolloverDaily, Retention: 2},
+		},
+	}
+	s.clock = func() time.Time { return testNow }
+	s.mapper = &searchmocks.Mapper{
+		ColumnToSearchMappingFn: func(column schemalog.Column) (map[string]any, error) {
+			return map[string]any{"type": "keyword"}, nil
+		},
+	}
+
+	failed, err := s.SendDocuments(context.Background(), testDocs)
+	require.NoError(t, err)
+	require.Len(t, failed, 2)
+	require.Equal(t, testDocs[2], failed[0].Document)
+	require.Equal(t, search.SeverityIgnored, failed[0].Severity)
+	require.Equal(t, testDocs[3], failed[1].Document)
+	require.Equal(t, search.SeverityDataLoss, failed[1].Severity)
+
+	require.Equal(t, []string{
+		"list [test_schema.t1.*]",
+		"delete [test_schema.t1.2026.10.16-1]",
+		"create test_schema.t1.2026.10.18-1",
+		"put alias [test_schema.t1.2026.10.18-1] test_schema.t1",
+		"create test_schema.t1.2026.10.17-1",
+		"put alias [test_schema.t1.2026.10.17-1] test_schema.t1",
+		"delete by query [test_schema.t1] map[query:map[ids:map[values:[t1_5]]]]",
+		"bulk [test_schema.t1.2026.10.18-1 test_schema.t1.2026.10.17-1 test_schema.t2]",
+	}, calls)
+
+	// the existing buckets are not created again
+	calls = calls[:0]
+	_, err = s.SendDocuments(context.Background(), testDocs[:2])
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"bulk [test_schema.t1.2026.10.18-1 test_schema.t1.2026.10.17-1]",
+	}, calls)
+}
+
+func TestStore_updateTableIndices_rollover(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testNow := time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)
+	eventsTable := func(columns ...schemalog.Column) schemalog.Table {
+		return schemalog.Table{
+			PgstreamID: "t1",
+			Name:       "events",
+			Columns: append([]schemalog.Column{
+				{PgstreamID: "t1-1", Name: "id", DataType: "int8"},
+				{PgstreamID: "t1-2", Name: "created_at", DataType: "timestamptz"},
+			}, columns...),
+			PrimaryKeyColumns: []string{"id"},
+		}
+	}
+	newEntry := func(version int64, tables ...schemalog.Table) *schemalog.LogEntry {
+		return &schemalog.LogEntry{
+			ID:         xid.New(),
+			Version:    version,
+			SchemaName: testSchemaName,
+			Schema:     schemalog.Schema{Tables: tables},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		previousEntry *schemalog.LogEntry
+		newEntry      *schemalog.LogEntry
+		indices       []string
+		retention     int
+
+		wantCalls []string
+	}{
+		{
+			name:     "new table",
+			newEntry: newEntry(1, eventsTable()),
+			indices:  []string{},
+
+			wantCalls: []string{
+				"list [test_schema.*]",
+				"create test_schema.t1.2026.10.18-1 [_table t1-1 t1-2]",
+				"put alias [test_schema.t1.2026.10.18-1] test_schema.t1",
+				"index schema log pgstream",
+			},
+		},
+		{
+			name:          "new column",
+			previousEntry: newEntry(1, eventsTable()),
+			newEntry:      newEntry(2, eventsTable(schemalog.Column{PgstreamID: "t1-3", Name: "payload", DataType: "text"})),
+			indices:       []string{"test_schema.t1.2026.10.17-1", "test_schema.t1.2026.10.18-1"},
+
+			wantCalls: []string{
+				"list [test_schema.*]",
+				"create test_schema.t1.2026.10.18-1 [_table t1-1 t1-2 t1-3]",
+				"put alias [test_schema.t1.2026.10.18-1] test_schema.t1",
+				"put mapping test_schema.t1.* [t1-3]",
+				"index schema log pgstream",
+			},
+		},
+		{
+			name:          "retention applied with existing bucket",
+			previousEntry: newEntry(1, eventsTable()),
+			newEntry:      newEntry(2, eventsTable()),
+			indices:       []string{"test_schema.t1.2026.10.16-1", "test_schema.t1.2026.10.17-1", "test_schema.t1.2026.10.18-1"},
+			retention:     2,
+
+			wantCalls: []string{
+				"list [test_schema.*]",
+				"list [test_schema.t1.*]",
+				"delete [test_schema.t1.2026.10.16-1]",
+				"create test_schema.t1.2026.10.18-1 [_table t1-1 t1-2]",
+				"put alias [test_schema.t1.2026.10.18-1] test_schema.t1",
+				"index schema log pgstream",
+			},
+		},
+		{
+			name:          "dropped table",
+			previousEntry: newEntry(1, eventsTable()),
+			newEntry:      newEntry(2),
+			indices:       []string{"test_schema.t1.2026.10.17-1", "test_schema.t1.2026.10.18-1"},
+
+			wantCalls: []string{
+				"list [test_schema.*]",
+				"list [test_schema.t1.*]",
+				"delete [test_schema.t1.2026.10.17-1 test_schema.t1.2026.10.18-1]",
+				"index schema log pgstream",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := []string{}
+			record := func(format string, args ...any) {
+				calls = append(calls, fmt.Sprintf(format, args...))
+			}
+			client := &esmocks.Client{
+				ListIndicesFn: func(ctx context.Context, indices []string) ([]string, error) {
+					record("list %v", indices)
+					return tc.indices, nil
+				},
+				CreateIndexFn: func(ctx context.Context, index string, body map[string]any) error {
+					properties := body["mappings"].(map[string]any)["properties"].(map[string]any)
+					record("create %s %v", index, mapKeys(properties))
+					for _, existing := range tc.indices {
+						if existing == index {
+							return es.ErrResourceAlreadyExists{}
+						}
+					}
+					return nil
+				},
+				PutIndexAliasFn: func(ctx context.Context, index []string, name string) error {
+					record("put alias %v %s", index, name)
+					return nil
+				},
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					if index == schemalogIndexName {
+						return nil
+					}
+					record("put mapping %s %v", index, mapKeys(body["properties"].(map[string]any)))
+					return nil
+				},
+				DeleteIndexFn: func(ctx context.Context, index []string) error {
+					record("delete %v", index)
+					return nil
+				},
+				IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+					record("index schema log %s", req.Index)
+					return nil
+				},
+			}
+
+			s := NewStoreWithClient(client)
+			s.indexLayout = IndexPerTable
+			s.mappingConfig = &MappingConfig{
+				Rollover: []RolloverConfig{
+					{Schema: testSchemaName, Table: "events", TimestampColumn: "created_at", Interval: RolloverDaily, Retention: tc.retention},
+				},
+			}
+			s.clock = func() time.Time { return testNow }
+			s.mapper = &searchmocks.Mapper{
+				ColumnToSearchMappingFn: func(column schemalog.Column) (map[string]any, error) {
+					return map[string]any{"type": "keyword"}, nil
+				},
+			}
+
+			err := s.updateTableIndices(context.Background(), tc.newEntry, tc.previousEntry)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantCalls, calls)
+		})
+	}
+}
+
+func TestMappingConfig_ValidateLayout(t *testing.T) {
+	t.Parallel()
+
+	cfg := &MappingConfig{
+		Rollover: []RolloverConfig{
+			{Schema: "public", Table: "events", TimestampColumn: "created_at", Interval: RolloverDaily},
+		},
+	}
+	require.ErrorIs(t, cfg.ValidateLayout(""), errInvalidMappingConfig)
+	require.ErrorIs(t, cfg.ValidateLayout(IndexPerSchema), errInvalidMappingConfig)
+	require.NoError(t, cfg.ValidateLayout(IndexPerTable))
+	require.NoError(t, (&MappingConfig{}).ValidateLayout(IndexPerSchema))
+}
+
+func mapKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/internal/es"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// softDeleteProperties are the mappings of the fields set on the soft deleted
+// documents. They're added to the index mappings on the first soft delete,
+// since the mappings are strict.
+var softDeleteProperties = map[string]any{
+	search.DeletedField: map[string]any{
+		"type": "boolean",
+	},
+	search.DeletedAtField: map[string]any{
+		"type": "date",
+	},
+}
+
+// softDeleteScript sets the soft delete fields of each document, by document
+// id. Documents with a version higher than the delete were written again
+// after it, so they're not updated.
+const softDeleteScript = `
+def doc = params.docs[ctx._id];
+if (doc == null || ctx._version >= doc.version) {
+	ctx.op = 'noop';
+} else {
+	for (def field : doc.fields.entrySet()) {
+		ctx._source[field.getKey()] = field.getValue();
+	}
+}
+`
+
+// softDelete is the soft delete of a document, as used by the soft delete
+// script.
+type softDelete struct {
+	Version int            `json:"version"`
+	Fields  map[string]any `json:"fields"`
+}
+
+func newSoftDelete(doc search.Document) softDelete {
+	return softDelete{
+		Version: doc.Version,
+		Fields: map[string]any{
+			search.DeletedField:   doc.Data[search.DeletedField],
+			search.DeletedAtField: doc.Data[search.DeletedAtField],
+		},
+	}
+}
+
+// softDeleteIndex returns the index alias of the soft deleted document. With
+// rollover tables, the alias covers all the table buckets, so the document
+// timestamp is not required.
+func (s *Store) softDeleteIndex(ctx context.Context, doc search.Document) (IndexName, error) {
+	if s.indexLayout == IndexPerSchema {
+		return s.adapter.SchemaNameToIndex(doc.Schema), nil
+	}
+	return s.documentTableIndex(ctx, doc)
+}
+
+// softDeleteDocuments applies the soft deletes on input, by index alias and
+// document id. The documents that don't exist are ignored.
+func (s *Store) softDeleteDocuments(ctx context.Context, docs map[string]map[string]softDelete) error {
+	for alias, deletes := range docs {
+		if err := s.ensureSoftDeleteMapping(ctx, alias); err != nil {
+			// nothing to update if the index has not been created yet
+			if errors.Is(err, es.ErrResourceNotFound) {
+				continue
+			}
+			return err
+		}
+
+		ids := make([]string, 0, len(deletes))
+		for id := range deletes {
+			ids = append(ids, id)
+		}
+		// documents written to the new index version of a migration in
+		// progress need to be updated too
+		indices := []string{alias}
+		if m := s.getMigration(alias); m != nil {
+			indices = append(indices, m.to.NameWithVersion())
+		}
+		if err := s.client.UpdateByQuery(ctx, &es.UpdateByQueryRequest{
+			Index: indices,
+			Query: map[string]any{
+				"ids": map[string]any{"values": ids},
+			},
+			Script: &es.Script{
+				Source: softDeleteScript,
+				Lang:   "painless",
+				Params: map[string]any{"docs": deletes},
+			},
+			// documents written in the meantime are more recent than the
+			// delete
+			Conflicts: "proceed",
+		}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// SoftDeleteTableDocuments marks all the documents of the tables on input as
+// deleted at the time on input. Documents already deleted keep their original
+// deletion time.
+func (s *Store) SoftDeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string, deletedAt time.Time) error {
+	if len(tableIDs) == 0 {
+		return nil
+	}
+
+	fields := map[string]any{
+		search.DeletedField:   true,
+		search.DeletedAtField: deletedAt.UTC().Truncate(time.Millisecond).Format(timestampTZFormat),
+	}
+	notDeleted := []map[string]any{{"term": map[string]any{search.DeletedField: true}}}
+
+	if s.indexLayout == IndexPerSchema {
+		index := s.adapter.SchemaNameToIndex(schemaName)
+		return s.softDeleteIndexDocuments(ctx, index, map[string]any{
+			"bool": map[string]any{
+				"filter":   []map[string]any{{"terms": map[string]any{"_table": tableIDs}}},
+				"must_not": notDeleted,
+			},
+		}, fields)
+	}
+
+	for _, tableID := range tableIDs {
+		index, err := s.tableIndex(ctx, schemaName, tableID)
+		if err != nil {
+			return mapError(err)
+		}
+		if err := s.softDeleteIndexDocuments(ctx, index, map[string]any{
+			"bool": map[string]any{
+				"must_not": notDeleted,
+			},
+		}, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// softDeleteIndexDocuments sets the soft delete fields on input in the index
+// documents matching the query.
+func (s *Store) softDeleteIndexDocuments(ctx context.Context, index IndexName, query, fields map[string]any) error {
+	// the backfill of an index migration in progress could bring back the
+	// documents that are not deleted, so it needs to be completed first
+	if err := s.completeMigration(ctx, index.Name()); err != nil {
+		return err
+	}
+
+	if err := s.ensureSoftDeleteMapping(ctx, index.Name()); err != nil {
+		// nothing to delete if the index has not been created yet
+		if errors.Is(err, es.ErrResourceNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.client.UpdateByQuery(ctx, &es.UpdateByQueryRequest{
+		Index: []string{index.Name()},
+		Query: query,
+		Script: &es.Script{
+			Source: setFieldsScript,
+			Lang:   "painless",
+			Params: map[string]any{"fields": fields},
+		},
+		Conflicts: "proceed",
+		Refresh:   true,
+	}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+		return mapError(err)
+	}
+	return nil
+}
+
+// PurgeDeletedDocuments removes the documents of the schema that were soft
+// deleted before the time on input.
+func (s *Store) PurgeDeletedDocuments(ctx context.Context, schemaName string, deletedBefore time.Time) error {
+	index := s.adapter.SchemaNameToIndex(schemaName).Name()
+	if s.indexLayout != IndexPerSchema {
+		// matches all the table indices of the schema, including the rollover
+		// buckets
+		index = tableIndexPrefix(schemaName, "*")
+	}
+
+	err := s.client.DeleteByQuery(ctx, &es.DeleteByQueryRequest{
+		Index: []string{index},
+		Query: map[string]any{
+			"query": map[string]any{
+				"bool": map[string]any{
+					"filter": []map[string]any{
+						{"term": map[string]any{search.DeletedField: true}},
+						{"range": map[string]any{search.DeletedAtField: map[string]any{"lt": deletedBefore.UTC().Truncate(time.Millisecond).Format(timestampTZFormat)}}},
+					},
+				},
+			},
+		},
+	})
+	if err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+		return mapError(err)
+	}
+	return nil
+}
+
+// ensureSoftDeleteMapping adds the soft delete fields to the mappings of the
+// index alias on input, unless they were already added.
+func (s *Store) ensureSoftDeleteMapping(ctx context.Context, alias string) error {
+	s.softDeleteIndicesLock.Lock()
+	defer s.softDeleteIndicesLock.Unlock()
+	if _, found := s.softDeleteIndices[alias]; found {
+		return nil
+	}
+
+	mapping := map[string]any{
+		"properties": softDeleteProperties,
+	}
+	if err := s.client.PutIndexMappings(ctx, alias, mapping); err != nil {
+		return fmt.Errorf("adding soft delete fields to %s: %w", alias, mapError(err))
+	}
+	// the new index version of a migration in progress needs to be able to
+	// receive the soft deletes too
+	if m := s.getMigration(alias); m != nil {
+		if err := s.client.PutIndexMappings(ctx, m.to.NameWithVersion(), mapping); err != nil {
+			return fmt.Errorf("adding soft delete fields to %s: %w", m.to.NameWithVersion(), mapError(err))
+		}
+	}
+	s.softDeleteIndices[alias] = struct{}{}
+	return nil
+}
+
+// forgetSoftDeleteMapping removes the index alias from the ones known to have
+// the soft delete fields, i.e. when its indices are deleted or a new index is
+// added to it.
+func (s *Store) forgetSoftDeleteMapping(alias string) {
+	s.softDeleteIndicesLock.Lock()
+	defer s.softDeleteIndicesLock.Unlock()
+	delete(s.softDeleteIndices, alias)
+}
+
+// forgetSoftDeleteSchema removes the table index aliases of the schema from
+// the ones known to have the soft delete fields.
+func (s *Store) forgetSoftDeleteSchema(schemaName string) {
+	prefix := tableIndexPrefix(schemaName, "")
+	s.softDeleteIndicesLock.Lock()
+	defer s.softDeleteIndicesLock.Unlock()
+	for alias := range s.softDeleteIndices {
+		if strings.HasPrefix(alias, prefix) {
+			delete(s.softDeleteIndices, alias)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestStore_SendDocuments_softDelete(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testDeletedAt := "2024-05-01T10:00:00.000Z"
+	softDeletedDoc := func(id string) search.Document {
+		return search.Document{
+			ID:         id,
+			Schema:     testSchemaName,
+			Version:    2,
+			Delete:     true,
+			SoftDelete: true,
+			Data: map[string]any{
+				"_table":              "t1",
+				search.DeletedField:   true,
+				search.DeletedAtField: testDeletedAt,
+			},
+		}
+	}
+	wantSoftDelete := softDelete{
+		Version: 2,
+		Fields: map[string]any{
+			search.DeletedField:   true,
+			search.DeletedAtField: testDeletedAt,
+		},
+	}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		client func(putMappingCalls *uint64) *esmocks.Client
+		layout IndexLayout
+		docs   []search.Document
+
+		wantPutMappingCalls uint64
+		wantMappingCached   bool
+		wantFailed          []search.DocumentError
+		wantErr             error
+	}{
+		{
+			name: "ok - schema index",
+			client: func(putMappingCalls *uint64) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						atomic.AddUint64(putMappingCalls, 1)
+						require.Equal(t, testSchemaName, index)
+						require.Equal(t, map[string]any{"properties": softDeleteProperties}, body)
+						return nil
+					},
+					UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+						require.Equal(t, []string{testSchemaName}, req.Index)
+						require.Equal(t, map[string]any{
+							"ids": map[string]any{"values": []string{"t1_1"}},
+						}, req.Query)
+						require.Equal(t, &es.Script{
+							Source: softDeleteScript,
+							Lang:   "painless",
+							Params: map[string]any{"docs": map[string]softDelete{"t1_1": wantSoftDelete}},
+						}, req.Script)
+						require.Equal(t, "proceed", req.Conflicts)
+						return nil
+					},
+					SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
+						return nil, errors.New("SendBulkRequestFn: should not be called")
+					},
+				}
+			},
+			layout: IndexPerSchema,
+			docs:   []search.Document{softDeletedDoc("t1_1")},
+
+			wantPutMappingCalls: 1,
+			wantMappingCached:   true,
+		},
+		{
+			name: "ok - table index with writes",
+			client: func(putMappingCalls *uint64) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						atomic.AddUint64(putMappingCalls, 1)
+						require.Equal(t, "test_schema.t1", index)
+						return nil
+					},
+					UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+						require.Equal(t, []string{"test_schema.t1"}, req.Index)
+						require.Len(t, req.Script.Params["docs"], 2)
+						return nil
+					},
+					SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
+						require.Len(t, items, 1)
+						require.Equal(t, "t1_3", items[0].Index.ID)
+						return nil, nil
+					},
+				}
+			},
+			layout: IndexPerTable,
+			docs: []search.Document{
+				softDeletedDoc("t1_1"),
+				softDeletedDoc("t1_2"),
+				{ID: "t1_3", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t1"}},
+			},
+
+			wantPutMappingCalls: 1,
+			wantMappingCached:   true,
+		},
+		{
+			name: "ok - index not found",
+			client: func(putMappingCalls *uint64) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						atomic.AddUint64(putMappingCalls, 1)
+						return es.ErrResourceNotFound
+					},
+					UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+						return errors.New("UpdateByQueryFn: should not be called")
+					},
+				}
+			},
+			layout: IndexPerSchema,
+			docs:   []search.Document{softDeletedDoc("t1_1")},
+
+			wantPutMappingCalls: 1,
+		},
+		{
+			name: "ok - document without table",
+			client: func(putMappingCalls *uint64) *esmocks.Client {
+				return &esmocks.Client{GetIndicesMetaFn: noIndexMigrations}
+			},
+			layout: IndexPerTable,
+			docs: []search.Document{
+				{ID: "t1_1", Schema: testSchemaName, Version: 2, Delete: true, SoftDelete: true, Data: map[string]any{}},
+			},
+
+			wantFailed: []search.DocumentError{
+				{
+					Document: search.Document{ID: "t1_1", Schema: testSchemaName, Version: 2, Delete: true, SoftDelete: true, Data: map[string]any{}},
+					Severity: search.SeverityDataLoss,
+					Error:    "document without table: table index not found",
+				},
+			},
+		},
+		{
+			name: "error - updating by query",
+			client: func(putMappingCalls *uint64) *esmocks.Client {
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						atomic.AddUint64(putMappingCalls, 1)
+						return nil
+					},
+					UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+						return errTest
+					},
+				}
+			},
+			layout: IndexPerSchema,
+			docs:   []search.Document{softDeletedDoc("t1_1")},
+
+			wantPutMappingCalls: 1,
+			wantErr:             errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var putMappingCalls uint64
+			s := NewStoreWithClient(tc.client(&putMappingCalls))
+			s.indexLayout = tc.layout
+			failed, err := s.SendDocuments(context.Background(), tc.docs)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantFailed, failed)
+			require.Equal(t, tc.wantPutMappingCalls, atomic.LoadUint64(&putMappingCalls))
+
+			// the soft delete fields are only mapped once per index
+			if tc.wantMappingCached {
+				_, err = s.SendDocuments(context.Background(), tc.docs)
+				require.NoError(t, err)
+				require.Equal(t, tc.wantPutMappingCalls, atomic.LoadUint64(&putMappingCalls))
+			}
+		})
+	}
+}
+
+func TestStore_SoftDeleteTableDocuments(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testDeletedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	wantScript := &es.Script{
+		Source: setFieldsScript,
+		Lang:   "painless",
+		Params: map[string]any{"fields": map[string]any{
+			search.DeletedField:   true,
+			search.DeletedAtField: "2024-05-01T10:00:00.000Z",
+		}},
+	}
+	notDeleted := []map[string]any{{"term": map[string]any{search.DeletedField: true}}}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		client es.SearchClient
+		layout IndexLayout
+
+		wantErr error
+	}{
+		{
+			name: "ok - schema index",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					require.Equal(t, testSchemaName, index)
+					return nil
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					require.Equal(t, []string{testSchemaName}, req.Index)
+					require.Equal(t, map[string]any{
+						"bool": map[string]any{
+							"filter":   []map[string]any{{"terms": map[string]any{"_table": []string{"t1"}}}},
+							"must_not": notDeleted,
+						},
+					}, req.Query)
+					require.Equal(t, wantScript, req.Script)
+					require.True(t, req.Refresh)
+					return nil
+				},
+			},
+			layout: IndexPerSchema,
+		},
+		{
+			name: "ok - table index",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					require.Equal(t, "test_schema.t1", index)
+					return nil
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					require.Equal(t, []string{"test_schema.t1"}, req.Index)
+					require.Equal(t, map[string]any{
+						"bool": map[string]any{"must_not": notDeleted},
+					}, req.Query)
+					require.Equal(t, wantScript, req.Script)
+					return nil
+				},
+			},
+			layout: IndexPerTable,
+		},
+		{
+			name: "ok - index not found",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					return es.ErrResourceNotFound
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return errors.New("UpdateByQueryFn: should not be called")
+				},
+			},
+			layout: IndexPerSchema,
+		},
+		{
+			name: "error - adding soft delete fields",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					return errTest
+				},
+			},
+			layout: IndexPerSchema,
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - updating by query",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					return nil
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return errTest
+				},
+			},
+			layout: IndexPerTable,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewStoreWithClient(tc.client)
+			s.indexLayout = tc.layout
+			err := s.SoftDeleteTableDocuments(context.Background(), testSchemaName, []string{"t1"}, testDeletedAt)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestStore_PurgeDeletedDocuments(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testDeletedBefore := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		client es.SearchClient
+		layout IndexLayout
+
+		wantErr error
+	}{
+		{
+			name: "ok - schema index",
+			client: &esmocks.Client{
+				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+					require.Equal(t, []string{testSchemaName}, req.Index)
+					require.Equal(t, map[string]any{
+						"query": map[string]any{
+							"bool": map[string]any{
+								"filter": []map[string]any{
+									{"term": map[string]any{search.DeletedField: true}},
+									{"range": map[string]any{search.DeletedAtField: map[string]any{"lt": "2024-05-01T10:00:00.000Z"}}},
+								},
+							},
+						},
+					}, req.Query)
+					return nil
+				},
+			},
+			layout: IndexPerSchema,
+		},
+		{
+			name: "ok - table indices",
+			client: &esmocks.Client{
+				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+					require.Equal(t, []string{"test_schema.*"}, req.Index)
+					return nil
+				},
+			},
+			layout: IndexPerTableName,
+		},
+		{
+			name: "ok - index not found",
+			client: &esmocks.Client{
+				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+					return es.ErrResourceNotFound
+				},
+			},
+			layout: IndexPerSchema,
+		},
+		{
+			name: "error - deleting by query",
+			client: &esmocks.Client{
+				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+					return errTest
+				},
+			},
+			layout: IndexPerSchema,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewStoreWithClient(tc.client)
+			s.indexLayout = tc.layout
+			err := s.PurgeDeletedDocuments(context.Background(), testSchemaName, testDeletedBefore)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
@@ -8,8 +8,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/xataio/pgstream/internal/es"
+	tlslib "github.com/xataio/pgstream/internal/tls"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
@@ -21,10 +26,113 @@ type Store struct {
 	mapper    search.Mapper
 	adapter   Adapter
 	marshaler func(any) ([]byte, error)
+	// indexSettings are the settings used when creating the schema indices.
+	indexSettings map[string]any
+	// mappingConfig holds the optional column mapping overrides and index
+	// settings.
+	mappingConfig *MappingConfig
+
+	// migrations keeps track of the index migrations in progress, by index
+	// name (alias).
+	migrationsLock       sync.RWMutex
+	migrations           map[string]*indexMigration
+	backfillPollInterval time.Duration
+	// migrationsLoaded is set once the migrations persisted by a previous run
+	// have been loaded.
+	migrationsLoadLock sync.Mutex
+	migrationsLoaded   bool
+
+	indexLayout IndexLayout
+	// tableIndices keeps the index names of the schema tables, by schema name
+	// and table pgstream id. It's only used with the table name index layout,
+	// where the index name can't be derived from the document.
+	tableIndicesLock sync.RWMutex
+	tableIndices     map[string]map[string]IndexName
+	// schemaLogMappingUpdated is set once the schema log index mapping has
+	// been updated to track the table index layout.
+	schemaLogMappingLock    sync.Mutex
+	schemaLogMappingUpdated bool
+	// rolloverTables keeps the tables routed to time bucketed indices, by
+	// schema name and table pgstream id, rolloverBuckets the bucket indices
+	// known to exist, and rolloverRetention the retention start last applied
+	// to the tables, by buckets pattern.
+	rolloverTablesLock  sync.RWMutex
+	rolloverTables      map[string]map[string]*rolloverTable
+	rolloverBucketsLock sync.Mutex
+	rolloverBuckets     map[string]struct{}
+	rolloverRetention   map[string]time.Time
+	clock               func() time.Time
+	// softDeleteIndices keeps the index aliases known to have the soft delete
+	// fields mapped.
+	softDeleteIndicesLock sync.Mutex
+	softDeleteIndices     map[string]struct{}
+
+	// flattenedFieldType is the search type used for the flattened JSON
+	// columns.
+	flattenedFieldType string
+	// jsonColumns keeps the mappings of the JSON columns that are not indexed
+	// as text, by schema name and column pgstream id.
+	jsonColumnsLock sync.RWMutex
+	jsonColumns     map[string]map[string]*JSONMapping
+	// jsonFields keeps the subfields mapped for the object and nested JSON
+	// columns, by schema name and column pgstream id, to enforce their max
+	// fields.
+	jsonFieldsLock sync.Mutex
+	jsonFields     map[string]map[string]struct{}
 }
 
 type Config struct {
 	URL string
+	// Username and Password enable basic authentication.
+	Username string
+	Password string
+	// APIKey is the base64 encoded API key, used instead of basic
+	// authentication when set.
+	APIKey string
+	// TLS configures a custom CA and client certificates. Optional.
+	TLS *tlslib.Config
+	// AWSSigV4 signs the requests with AWS Signature Version 4, for Amazon
+	// OpenSearch Service domains with IAM authentication. It can't be
+	// combined with basic or API key authentication. Optional.
+	AWSSigV4 *es.SigV4Config
+	// IndexLayout defines how the schema tables are distributed across
+	// indices. Defaults to an index per schema.
+	IndexLayout IndexLayout
+	// MappingConfigFile is the path to an optional JSON file with the column
+	// mapping overrides and index settings. See MappingConfig.
+	MappingConfigFile string
+}
+
+// IndexLayout defines how the tables of a schema are distributed across the
+// search store indices.
+type IndexLayout string
+
+const (
+	// IndexPerSchema stores all the tables of a schema in the same index,
+	// distinguished by the `_table` field. The index is queried through an
+	// alias with the schema name.
+	IndexPerSchema IndexLayout = "schema"
+	// IndexPerTable stores each table in its own index, queried through an
+	// alias with format <schema>.<table_pgstream_id>.
+	IndexPerTable IndexLayout = "table"
+	// IndexPerTableName stores each table in its own index, queried through
+	// an alias with format <schema>.<table_name>, where the table name is
+	// lowercased. Renaming a table moves the alias. Schema changes that
+	// would give two tables the same alias (i.e. names that only differ in
+	// case) are rejected.
+	IndexPerTableName IndexLayout = "table_name"
+)
+
+var errUnsupportedIndexLayout = errors.New("unsupported index layout")
+
+// Validate returns an error if the index layout is not supported.
+func (l IndexLayout) Validate() error {
+	switch l {
+	case IndexPerSchema, IndexPerTable, IndexPerTableName:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errUnsupportedIndexLayout, l)
+	}
 }
 
 type Option func(*Store)
@@ -43,19 +151,54 @@ const (
 )
 
 func NewStore(cfg Config, opts ...Option) (*Store, error) {
-	os, err := es.NewClient(cfg.URL)
+	os, err := es.NewClient(es.ClientConfig{
+		URL:      cfg.URL,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		APIKey:   cfg.APIKey,
+		TLS:      cfg.TLS,
+		AWSSigV4: cfg.AWSSigV4,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("create elasticsearch client: %w", err)
 	}
 
+	layoutOpts, err := LayoutOptions(cfg.IndexLayout, cfg.MappingConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
 	s := NewStoreWithClient(os)
-	for _, opt := range opts {
+	for _, opt := range append(layoutOpts, opts...) {
 		opt(s)
 	}
 
 	return s, nil
 }
 
+// LayoutOptions returns the store options for the index layout and the mapping
+// config file on input, once validated against each other. Both are optional.
+func LayoutOptions(indexLayout IndexLayout, mappingConfigFile string) ([]Option, error) {
+	opts := []Option{}
+	if indexLayout != "" {
+		if err := indexLayout.Validate(); err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithIndexLayout(indexLayout))
+	}
+	if mappingConfigFile != "" {
+		mappingConfig, err := LoadMappingConfig(mappingConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := mappingConfig.ValidateLayout(indexLayout); err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithMappingConfig(mappingConfig))
+	}
+	return opts, nil
+}
+
 func NewStoreWithClient(client es.SearchClient) *Store {
 	return &Store{
 		logger:    loglib.NewNoopLogger(),
@@ -63,6 +206,25 @@ func NewStoreWithClient(client es.SearchClient) *Store {
 		adapter:   newDefaultAdapter(),
 		mapper:    NewPostgresMapper(),
 		marshaler: json.Marshal,
+		indexSettings: map[string]any{
+			"number_of_shards":                 1,
+			"number_of_replicas":               1,
+			"index.mapping.total_fields.limit": 2000,
+			"index.knn":                        true,
+			"knn.algo_param.ef_search":         openSearchDefaultEFSearch,
+		},
+		migrations:           map[string]*indexMigration{},
+		backfillPollInterval: defaultBackfillPollInterval,
+		indexLayout:          IndexPerSchema,
+		tableIndices:         map[string]map[string]IndexName{},
+		flattenedFieldType:   openSearchFlattenedType,
+		jsonColumns:          map[string]map[string]*JSONMapping{},
+		jsonFields:           map[string]map[string]struct{}{},
+		rolloverTables:       map[string]map[string]*rolloverTable{},
+		rolloverBuckets:      map[string]struct{}{},
+		rolloverRetention:    map[string]time.Time{},
+		softDeleteIndices:    map[string]struct{}{},
+		clock:                time.Now,
 	}
 }
 
@@ -72,6 +234,46 @@ func WithLogger(l loglib.Logger) Option {
 	}
 }
 
+// WithMapper sets the mapper used to convert the postgres columns and values
+// into search mappings and values. Defaults to the opensearch postgres mapper.
+func WithMapper(m search.Mapper) Option {
+	return func(s *Store) {
+		s.mapper = m
+	}
+}
+
+// WithIndexSettings sets the settings used when creating the schema indices.
+// Defaults to the opensearch settings, with k-NN enabled.
+func WithIndexSettings(settings map[string]any) Option {
+	return func(s *Store) {
+		s.indexSettings = settings
+	}
+}
+
+// WithIndexLayout sets how the tables of a schema are distributed across
+// indices. Defaults to an index per schema.
+func WithIndexLayout(layout IndexLayout) Option {
+	return func(s *Store) {
+		s.indexLayout = layout
+	}
+}
+
+// WithMappingConfig sets the column mapping overrides and index settings
+// applied on top of the mapper mappings and the default index settings.
+func WithMappingConfig(cfg *MappingConfig) Option {
+	return func(s *Store) {
+		s.mappingConfig = cfg
+	}
+}
+
+// WithFlattenedFieldType sets the search type used for the JSON columns indexed
+// as flattened objects. Defaults to the opensearch `flat_object` type.
+func WithFlattenedFieldType(fieldType string) Option {
+	return func(s *Store) {
+		s.flattenedFieldType = fieldType
+	}
+}
+
 func (s *Store) GetMapper() search.Mapper {
 	return s.mapper
 }
@@ -80,24 +282,39 @@ func (s *Store) ApplySchemaChange(ctx context.Context, newEntry *schemalog.LogEn
 	if newEntry == nil {
 		return nil
 	}
-	existingLogEntry, err := s.getLastSchemaLogEntry(ctx, newEntry.SchemaName)
+	if err := s.loadMigrations(ctx); err != nil {
+		return err
+	}
+	existingLogEntry, existingLayout, err := s.getLastSchemaLog(ctx, newEntry.SchemaName)
 	if err != nil {
 		// if there's no schemalog, this is a new schema and we need to create
 		// it
 		if errors.As(err, &search.ErrSchemaNotFound{}) {
-			if err := s.ensureSchema(ctx, newEntry.SchemaName); err != nil {
-				return fmt.Errorf("ensuring schema existence: %w", err)
+			existingLayout = s.indexLayout
+			if s.indexLayout == IndexPerSchema {
+				if err := s.ensureSchema(ctx, newEntry.SchemaName); err != nil {
+					return fmt.Errorf("ensuring schema existence: %w", err)
+				}
 			}
 		} else {
 			return fmt.Errorf("get latest schema: %w", err)
 		}
 	}
 
+	// the documents of an existing schema would be spread across the indices
+	// of both layouts
+	if existingLayout != s.indexLayout {
+		return fmt.Errorf("schema %s uses the %q index layout, and can't be changed to %q without deleting it", newEntry.SchemaName, existingLayout, s.indexLayout)
+	}
+
 	// make sure the index and the mapping for the schema exist, and if it
 	// doesn't, align it with latest schema log mapping. This check will allow
-	// us to self recover in case of schema OS index deletion
-	if err := s.ensureSchemaMapping(ctx, newEntry.SchemaName, existingLogEntry); err != nil {
-		return fmt.Errorf("ensuring schema mapping: %w", err)
+	// us to self recover in case of schema OS index deletion. The table
+	// indices are checked as part of their update.
+	if s.indexLayout == IndexPerSchema {
+		if err := s.ensureSchemaMapping(ctx, newEntry.SchemaName, existingLogEntry); err != nil {
+			return fmt.Errorf("ensuring schema mapping: %w", err)
+		}
 	}
 
 	// older schema should not be possible to receive
@@ -113,56 +330,158 @@ func (s *Store) ApplySchemaChange(ctx context.Context, newEntry *schemalog.LogEn
 		}
 	}
 
-	changes := newEntry.Diff(existingLogEntry)
-	// for now only log a warning when a reindexing is required, since the data
-	// can still be indexed, old data will need a reindex to take into account
-	// the new identity for search to be effective
-	//
-	// TODO: in the future, we will trigger a reindex automatically in this
-	// situation
-	for _, tbl := range changes.PrimaryKeyChange {
-		s.logger.Warn(nil, fmt.Sprintf("primary key identity column changed for table %s, reindexing required", tbl))
-	}
-	for _, tbl := range changes.UniqueNotNullChange {
-		s.logger.Warn(nil, fmt.Sprintf("unique not null identity column changed for table %s, reindexing required", tbl))
+	if s.indexLayout != IndexPerSchema {
+		if err := s.updateTableIndices(ctx, newEntry, existingLogEntry); err != nil {
+			return fmt.Errorf("update table indices for schema: %w", err)
+		}
+		s.updateJSONColumns(newEntry)
+		return nil
 	}
 
-	if err := s.updateMapping(ctx, newEntry.SchemaName, newEntry, changes); err != nil {
+	changes := newEntry.Diff(existingLogEntry)
+	// the documents of tables whose identity has changed are keyed by the old
+	// identity, so they need to be migrated to a new index version with the
+	// new one.
+	identityChanges := getIdentityChanges(newEntry, existingLogEntry, changes)
+	if err := s.updateMapping(ctx, newEntry.SchemaName, newEntry, changes, identityChanges); err != nil {
 		return fmt.Errorf("update mapping for schema: %w", err)
 	}
+	s.updateJSONColumns(newEntry)
 	return nil
 }
 
 func (s *Store) SendDocuments(ctx context.Context, docs []search.Document) ([]search.DocumentError, error) {
+	// the documents need to be written to both index versions of the
+	// migrations resumed from a previous run
+	if err := s.loadMigrations(ctx); err != nil {
+		return nil, err
+	}
 	items := make([]es.BulkItem, 0, len(docs))
+	// tableIndexSchemas keeps the schema of the table indices, used to report
+	// failures
+	tableIndexSchemas := map[string]string{}
+	// skipped are the documents that can't be sent to the search store, which
+	// are reported along with the bulk request failures
+	var skipped []search.DocumentError
+	// rolloverDeletes are the ids of the rollover table documents deleted
+	// without their timestamp, by table index alias
+	rolloverDeletes := map[string][]string{}
+	// softDeletes are the documents marked as deleted, by index alias and
+	// document id
+	softDeletes := map[string]map[string]softDelete{}
 	for _, doc := range docs {
 		if len(doc.ID) > osIDFieldLengthLimit {
-			s.logger.Error(errors.New("ID is longer than 512 bytes"), "opensearch store adapter: error processing document, skipping", loglib.Fields{
+			err := errors.New("ID is longer than 512 bytes")
+			s.logger.Error(err, "opensearch store adapter: error processing document, skipping", loglib.Fields{
 				"severity": "DATALOSS",
 				"id":       doc.ID,
 			})
+			skipped = append(skipped, search.DocumentError{Document: doc, Severity: search.SeverityDataLoss, Error: err.Error()})
 			continue
 		}
-		items = append(items, s.adapter.SearchDocToBulkItem(doc))
+		if doc.SoftDelete {
+			index, err := s.softDeleteIndex(ctx, doc)
+			if err != nil {
+				if errors.Is(err, errTableIndexNotFound) {
+					s.logger.Error(err, "opensearch store: error processing document, skipping", loglib.Fields{
+						"severity": "DATALOSS",
+						"schema":   doc.Schema,
+						"id":       doc.ID,
+					})
+					skipped = append(skipped, search.DocumentError{Document: doc, Severity: search.SeverityDataLoss, Error: err.Error()})
+					continue
+				}
+				return nil, err
+			}
+			if softDeletes[index.Name()] == nil {
+				softDeletes[index.Name()] = map[string]softDelete{}
+			}
+			softDeletes[index.Name()][doc.ID] = newSoftDelete(doc)
+			continue
+		}
+		doc, err := s.mapJSONColumns(ctx, doc)
+		if err != nil {
+			return nil, fmt.Errorf("mapping json columns: %w", err)
+		}
+		item := s.adapter.SearchDocToBulkItem(doc)
+		if s.indexLayout != IndexPerSchema {
+			index, err := s.documentTableIndex(ctx, doc)
+			if err != nil {
+				if errors.Is(err, errTableIndexNotFound) {
+					s.logger.Error(err, "opensearch store: error processing document, skipping", loglib.Fields{
+						"severity": "DATALOSS",
+						"schema":   doc.Schema,
+						"id":       doc.ID,
+					})
+					skipped = append(skipped, search.DocumentError{Document: doc, Severity: search.SeverityDataLoss, Error: err.Error()})
+					continue
+				}
+				return nil, err
+			}
+			name, err := s.documentIndexName(ctx, doc, index)
+			if err != nil {
+				switch {
+				case errors.Is(err, errRolloverTimestampNotFound) && doc.Delete:
+					// the bucket of the document is unknown, so it's deleted
+					// from all the table buckets
+					rolloverDeletes[index.Name()] = append(rolloverDeletes[index.Name()], doc.ID)
+				case errors.Is(err, errRolloverBucketExpired):
+					skipped = append(skipped, search.DocumentError{Document: doc, Severity: search.SeverityIgnored, Error: err.Error()})
+				case errors.Is(err, errRolloverTimestampNotFound), errors.Is(err, errInvalidRolloverTimestamp):
+					s.logger.Error(err, "opensearch store: error processing document, skipping", loglib.Fields{
+						"severity": "DATALOSS",
+						"schema":   doc.Schema,
+						"id":       doc.ID,
+					})
+					skipped = append(skipped, search.DocumentError{Document: doc, Severity: search.SeverityDataLoss, Error: err.Error()})
+				default:
+					return nil, err
+				}
+				continue
+			}
+			item = withIndex(item, name)
+			tableIndexSchemas[name] = doc.Schema
+		}
+		items = append(items, item)
+	}
+
+	if err := s.deleteRolloverDocuments(ctx, rolloverDeletes); err != nil {
+		return nil, fmt.Errorf("deleting rollover documents: %w", err)
+	}
+	if err := s.softDeleteDocuments(ctx, softDeletes); err != nil {
+		return nil, fmt.Errorf("soft deleting documents: %w", err)
 	}
+	if len(items) == 0 {
+		return skipped, nil
+	}
+
+	// documents are written to both index versions while an index migration
+	// is in progress
+	items, migrationAliases := s.migrationBulkItems(items)
 	failed, err := s.client.SendBulkRequest(ctx, items)
 	if err != nil {
 		return nil, mapError(err)
 	}
+	failed = s.migrationFailures(failed, migrationAliases)
+	s.tableIndexFailures(failed, tableIndexSchemas)
 
+	if len(skipped) > 0 {
+		return append(skipped, s.adapter.BulkItemsToSearchDocErrs(failed)...), nil
+	}
 	return s.adapter.BulkItemsToSearchDocErrs(failed), nil
 }
 
 func (s *Store) DeleteSchema(ctx context.Context, schemaName string) error {
-	index := s.adapter.SchemaNameToIndex(schemaName)
-	exists, err := s.client.IndexExists(ctx, index.NameWithVersion())
-	if err != nil {
-		return mapError(err)
+	if err := s.loadMigrations(ctx); err != nil {
+		return err
 	}
-
-	if exists {
-		if err := s.client.DeleteIndex(ctx, []string{index.NameWithVersion()}); err != nil {
-			return mapError(err)
+	if s.indexLayout == IndexPerSchema {
+		if err := s.deleteIndex(ctx, s.adapter.SchemaNameToIndex(schemaName)); err != nil {
+			return err
+		}
+	} else {
+		if err := s.deleteSchemaTableIndices(ctx, schemaName); err != nil {
+			return err
 		}
 	}
 
@@ -172,7 +491,7 @@ func (s *Store) DeleteSchema(ctx context.Context, schemaName string) error {
 		Query: map[string]any{
 			"query": map[string]any{
 				"term": map[string]any{
-					"schema_name": index.SchemaName(),
+					"schema_name": schemaName,
 				},
 			},
 		},
@@ -183,7 +502,47 @@ func (s *Store) DeleteSchema(ctx context.Context, schemaName string) error {
 	return nil
 }
 
+// deleteIndex deletes the current version of the index on input, as well as
+// the index versions of a migration in progress.
+func (s *Store) deleteIndex(ctx context.Context, indexName IndexName) error {
+	index, err := s.currentIndex(ctx, indexName)
+	if err != nil {
+		return err
+	}
+
+	// remove both index versions of a migration in progress, since the alias
+	// could point to either of them
+	if m := s.getMigration(indexName.Name()); m != nil {
+		for _, i := range []IndexName{m.from, m.to} {
+			if err := s.client.DeleteIndex(ctx, []string{i.NameWithVersion()}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+				return mapError(err)
+			}
+		}
+		s.removeMigration(indexName.Name())
+	}
+
+	exists, err := s.client.IndexExists(ctx, index.NameWithVersion())
+	if err != nil {
+		return mapError(err)
+	}
+
+	if exists {
+		if err := s.client.DeleteIndex(ctx, []string{index.NameWithVersion()}); err != nil {
+			return mapError(err)
+		}
+	}
+	s.forgetSoftDeleteMapping(indexName.Name())
+	return nil
+}
+
 func (s *Store) DeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string) error {
+	if s.indexLayout != IndexPerSchema {
+		if err := s.deleteTableIndexDocuments(ctx, schemaName, tableIDs); err != nil {
+			return mapError(err)
+		}
+		return nil
+	}
+
 	index := s.adapter.SchemaNameToIndex(schemaName)
 	if err := s.deleteTableDocuments(ctx, index, tableIDs); err != nil {
 		return mapError(err)
@@ -191,10 +550,63 @@ func (s *Store) DeleteTableDocuments(ctx context.Context, schemaName string, tab
 	return nil
 }
 
+// UpdateTableDocuments sets the fields on input in the existing documents of
+// the table with the ids on input. The documents that don't exist are ignored.
+// Any index migration in progress is completed before the update.
+func (s *Store) UpdateTableDocuments(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+
+	index := s.adapter.SchemaNameToIndex(schemaName)
+	if s.indexLayout != IndexPerSchema {
+		var err error
+		if index, err = s.tableIndex(ctx, schemaName, tableID); err != nil {
+			return mapError(err)
+		}
+	}
+
+	// the backfill of an index migration in progress could overwrite the
+	// updated documents with their previous version, so it needs to be
+	// completed first
+	if err := s.completeMigration(ctx, index.Name()); err != nil {
+		return err
+	}
+
+	err := s.client.UpdateByQuery(ctx, &es.UpdateByQueryRequest{
+		Index: []string{index.Name()},
+		Query: map[string]any{
+			"ids": map[string]any{"values": docIDs},
+		},
+		Script: &es.Script{
+			Source: setFieldsScript,
+			Lang:   "painless",
+			Params: map[string]any{"fields": fields},
+		},
+		// documents written in the meantime are more recent than the update
+		Conflicts: "proceed",
+	})
+	if err != nil {
+		// nothing to update if the table has not been indexed yet
+		if errors.Is(err, es.ErrResourceNotFound) {
+			return nil
+		}
+		return mapError(err)
+	}
+	return nil
+}
+
 // getLastSchemaLogEntry will return the last version of the schemalog for the
 // schema on input. A nil LogEntry will be returned when there's no existing
 // associated logs
 func (s *Store) getLastSchemaLogEntry(ctx context.Context, schemaName string) (*schemalog.LogEntry, error) {
+	logEntry, _, err := s.getLastSchemaLog(ctx, schemaName)
+	return logEntry, err
+}
+
+// getLastSchemaLog returns the last version of the schemalog for the schema on
+// input, along with the index layout it was indexed with.
+func (s *Store) getLastSchemaLog(ctx context.Context, schemaName string) (*schemalog.LogEntry, IndexLayout, error) {
 	query := es.QueryBody{
 		Query: &es.Query{
 			Bool: &es.BoolFilter{
@@ -211,7 +623,7 @@ func (s *Store) getLastSchemaLogEntry(ctx context.Context, schemaName string) (*
 
 	bodyJSON, err := s.marshaler(query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal to JSON: %+v, %w", query, err)
+		return nil, "", fmt.Errorf("failed to marshal to JSON: %+v, %w", query, err)
 	}
 
 	res, err := s.client.Search(ctx, &es.SearchRequest{
@@ -226,23 +638,35 @@ func (s *Store) getLastSchemaLogEntry(ctx context.Context, schemaName string) (*
 			// Create the pgstream index if it was not found.
 			err = s.createSchemaLogIndex(ctx, schemalogIndexName)
 			if err != nil {
-				return nil, mapError(err)
+				return nil, "", mapError(err)
 			}
-			return nil, search.ErrSchemaNotFound{SchemaName: schemaName}
+			return nil, "", search.ErrSchemaNotFound{SchemaName: schemaName}
 		}
-		return nil, fmt.Errorf("get latest schema, failed to search os: %w", mapError(err))
+		return nil, "", fmt.Errorf("get latest schema, failed to search os: %w", mapError(err))
 	}
 
 	if len(res.Hits.Hits) == 0 {
-		return nil, search.ErrSchemaNotFound{SchemaName: schemaName}
+		return nil, "", search.ErrSchemaNotFound{SchemaName: schemaName}
 	}
 
-	return s.adapter.RecordToLogEntry(res.Hits.Hits[0].Source)
+	logEntry, err := s.adapter.RecordToLogEntry(res.Hits.Hits[0].Source)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// schema logs without layout were indexed before the table layouts were
+	// supported
+	layout := IndexPerSchema
+	if l, ok := res.Hits.Hits[0].Source[schemaLogIndexLayoutField].(string); ok && l != "" {
+		layout = IndexLayout(l)
+	}
+	return logEntry, layout, nil
 }
 
 func (s *Store) schemaExists(ctx context.Context, schemaName string) (bool, error) {
+	// check the alias, since the index version can change on reindex
 	indexName := s.adapter.SchemaNameToIndex(schemaName)
-	exists, err := s.client.IndexExists(ctx, indexName.NameWithVersion())
+	exists, err := s.client.IndexExists(ctx, indexName.Name())
 	if err != nil {
 		return false, mapError(err)
 	}
@@ -250,7 +674,20 @@ func (s *Store) schemaExists(ctx context.Context, schemaName string) (bool, erro
 }
 
 func (s *Store) createSchema(ctx context.Context, schemaName string) error {
-	index := s.adapter.SchemaNameToIndex(schemaName)
+	if err := s.createIndex(ctx, s.adapter.SchemaNameToIndex(schemaName), s.indexSettingsFor(schemaName, "")); err != nil {
+		if errors.As(err, &es.ErrResourceAlreadyExists{}) {
+			return &search.ErrSchemaAlreadyExists{
+				SchemaName: schemaName,
+			}
+		}
+		return mapError(err)
+	}
+	return nil
+}
+
+// createIndex creates the first version of the index on input with the given
+// settings, along with its alias.
+func (s *Store) createIndex(ctx context.Context, index IndexName, settings map[string]any) error {
 	err := s.client.CreateIndex(ctx, index.NameWithVersion(), map[string]any{
 		"mappings": map[string]any{
 			"dynamic": "strict",
@@ -260,33 +697,47 @@ func (s *Store) createSchema(ctx context.Context, schemaName string) error {
 				},
 			},
 		},
-		"settings": map[string]any{
-			"number_of_shards":                 1,
-			"number_of_replicas":               1,
-			"index.mapping.total_fields.limit": 2000,
-			"index.knn":                        true,
-			"knn.algo_param.ef_search":         openSearchDefaultEFSearch,
-		},
+		"settings": settings,
 	})
 	if err != nil {
-		if errors.As(err, &es.ErrResourceAlreadyExists{}) {
-			return &search.ErrSchemaAlreadyExists{
-				SchemaName: schemaName,
-			}
-		}
-		return mapError(err)
+		return err
 	}
 
-	if err := s.client.PutIndexAlias(ctx, []string{index.NameWithVersion()}, index.Name()); err != nil {
-		return mapError(err)
+	return s.client.PutIndexAlias(ctx, []string{index.NameWithVersion()}, index.Name())
+}
+
+func (s *Store) updateMapping(ctx context.Context, schemaName string, logEntry *schemalog.LogEntry, diff *schemalog.SchemaDiff, identityChanges []identityChange) error {
+	index := s.adapter.SchemaNameToIndex(schemaName)
+	if err := s.updateIndexMapping(ctx, index, logEntry.Version, s.schemaIndexOverrides(logEntry), diff, identityChanges); err != nil {
+		return err
 	}
+
+	if err := s.insertNewSchemaLog(ctx, logEntry); err != nil {
+		return fmt.Errorf("failed to insert new schema log: %w", mapError(err))
+	}
+
 	return nil
 }
 
-func (s *Store) updateMapping(ctx context.Context, schemaName string, logEntry *schemalog.LogEntry, diff *schemalog.SchemaDiff) error {
-	index := s.adapter.SchemaNameToIndex(schemaName)
+// updateIndexMapping applies the schema diff of the schema log version on
+// input to the index, using the index overrides for the new column mappings
+// and index versions.
+func (s *Store) updateIndexMapping(ctx context.Context, index IndexName, schemaVersion int64, overrides *indexOverrides, diff *schemalog.SchemaDiff, identityChanges []identityChange) error {
+	migration := migrationChanges{
+		schemaVersion:   schemaVersion,
+		identityChanges: identityChanges,
+		indexSettings:   overrides.settings,
+	}
 	if diff != nil {
-		if err := s.updateMappingAddNewColumns(ctx, index, diff.ColumnsToAdd); err != nil {
+		// renamed columns keep their pgstream id, which is used as the
+		// document field name, so they don't require any changes.
+		typeChanges, err := s.getColumnTypeChanges(index, overrides, diff.ColumnTypeChange)
+		if err != nil {
+			return fmt.Errorf("failed to get column type changes: %w", err)
+		}
+		migration.retypedColumns = typeChanges.retyped
+
+		if err := s.updateMappingAddNewColumns(ctx, index, overrides, append(slices.Clone(diff.ColumnsToAdd), typeChanges.added...)); err != nil {
 			return fmt.Errorf("failed to add new columns: %w", mapError(err))
 		}
 
@@ -299,10 +750,26 @@ func (s *Store) updateMapping(ctx context.Context, schemaName string, logEntry *
 				return fmt.Errorf("failed to delete table documents: %w", mapError(err))
 			}
 		}
+
+		removedColumns := make([]string, 0, len(diff.ColumnsToRemove)+len(typeChanges.removed))
+		for _, c := range append(slices.Clone(diff.ColumnsToRemove), typeChanges.removed...) {
+			removedColumns = append(removedColumns, c.PgstreamID)
+		}
+		// the removed columns are part of the migration backfill if there is
+		// one, otherwise the backfill could bring them back.
+		if migration.required() {
+			migration.removedColumns = removedColumns
+		} else if err := s.removeColumnFields(ctx, index, removedColumns); err != nil {
+			return fmt.Errorf("failed to remove column fields: %w", mapError(err))
+		}
 	}
 
-	if err := s.insertNewSchemaLog(ctx, logEntry); err != nil {
-		return fmt.Errorf("failed to insert new schema log: %w", mapError(err))
+	// this needs to happen before the new schema log is stored, so that it's
+	// retried on failure
+	if migration.required() {
+		if err := s.startMigration(ctx, index, migration); err != nil {
+			return fmt.Errorf("failed to start index migration: %w", err)
+		}
 	}
 
 	return nil
@@ -313,6 +780,12 @@ func (s *Store) deleteTableDocuments(ctx context.Context, index IndexName, table
 		return nil
 	}
 
+	// the backfill of an index migration in progress could bring back the
+	// deleted documents, so it needs to be completed first
+	if err := s.completeMigration(ctx, index.Name()); err != nil {
+		return err
+	}
+
 	req := &es.DeleteByQueryRequest{
 		Index: []string{index.Name()},
 		Query: map[string]any{
@@ -328,6 +801,114 @@ func (s *Store) deleteTableDocuments(ctx context.Context, index IndexName, table
 	return s.client.DeleteByQuery(ctx, req)
 }
 
+// removeColumnFields removes the fields on input from all the documents of the
+// index. The fields are kept in the index mapping, since mapped fields
+// can't be removed.
+func (s *Store) removeColumnFields(ctx context.Context, index IndexName, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// the backfill of an index migration in progress could bring back the
+	// removed fields, so it needs to be completed first
+	if err := s.completeMigration(ctx, index.Name()); err != nil {
+		return err
+	}
+
+	exists := make([]map[string]any, 0, len(fields))
+	for _, field := range fields {
+		exists = append(exists, map[string]any{
+			"exists": map[string]any{"field": field},
+		})
+	}
+
+	return s.client.UpdateByQuery(ctx, &es.UpdateByQueryRequest{
+		Index: []string{index.Name()},
+		Query: map[string]any{
+			"bool": map[string]any{
+				"should":               exists,
+				"minimum_should_match": 1,
+			},
+		},
+		Script: &es.Script{
+			Source: removeFieldsScript,
+			Lang:   "painless",
+			Params: map[string]any{"fields": fields},
+		},
+		// documents updated in the meantime are written without the removed
+		// fields
+		Conflicts: "proceed",
+		Refresh:   true,
+	})
+}
+
+// columnTypeChanges classifies the columns with a type change by the effect it
+// has on their search mapping.
+type columnTypeChanges struct {
+	// retyped are the new mappings of the columns with a different mapping,
+	// by pgstream id.
+	retyped map[string]any
+	// added are the columns that were not mapped before.
+	added []schemalog.Column
+	// removed are the columns that are no longer mapped.
+	removed []schemalog.Column
+}
+
+func (s *Store) getColumnTypeChanges(index IndexName, overrides *indexOverrides, changes []schemalog.ColumnChange) (*columnTypeChanges, error) {
+	typeChanges := &columnTypeChanges{}
+	for _, change := range changes {
+		oldMapping, err := s.columnMapping(index, overrides, change.Old)
+		if err != nil {
+			return nil, err
+		}
+		newMapping, err := s.columnMapping(index, overrides, change.New)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case reflect.DeepEqual(oldMapping, newMapping):
+			// compatible type change (i.e. varchar(10) to varchar(20))
+		case oldMapping == nil:
+			typeChanges.added = append(typeChanges.added, change.New)
+		case newMapping == nil:
+			typeChanges.removed = append(typeChanges.removed, change.New)
+		default:
+			if typeChanges.retyped == nil {
+				typeChanges.retyped = map[string]any{}
+			}
+			typeChanges.retyped[change.New.PgstreamID] = newMapping
+		}
+	}
+	return typeChanges, nil
+}
+
+// columnMapping returns the search mapping for the column, with its override
+// applied, or nil if its type is not supported.
+func (s *Store) columnMapping(index IndexName, overrides *indexOverrides, c schemalog.Column) (map[string]any, error) {
+	mapping, err := s.mapper.ColumnToSearchMapping(c)
+	if err != nil {
+		if errors.As(err, &search.ErrTypeInvalid{}) {
+			s.logger.Warn(err, "unknown column type", loglib.Fields{
+				"column": map[string]any{
+					"type": c.DataType,
+					"id":   c.PgstreamID,
+				},
+				"schema": index.SchemaName(),
+			})
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to convert column to search mapping: %w", err)
+	}
+	if o, found := overrides.columns[c.PgstreamID]; found && mapping != nil {
+		if o.JSON != nil && isJSONType(c.DataType) {
+			mapping = o.JSON.searchMapping(s.flattenedFieldType)
+		}
+		return o.apply(mapping), nil
+	}
+	return mapping, nil
+}
+
 func (s *Store) createSchemaLogIndex(ctx context.Context, name string) error {
 	exists, err := s.client.IndexExists(ctx, schemalogIndexName)
 	if err != nil {
@@ -360,12 +941,14 @@ func (s *Store) createSchemaLogIndex(ctx context.Context, name string) error {
 				"acked": map[string]any{
 					"type": "boolean",
 				},
+				schemaLogIndexLayoutField: schemaLogLayoutProperties[schemaLogIndexLayoutField],
+				schemaLogIndicesField:     schemaLogLayoutProperties[schemaLogIndicesField],
 			},
 		},
 	})
 }
 
-func (s *Store) updateMappingAddNewColumns(ctx context.Context, indexName IndexName, newColumns []schemalog.Column) error {
+func (s *Store) updateMappingAddNewColumns(ctx context.Context, indexName IndexName, overrides *indexOverrides, newColumns []schemalog.Column) error {
 	if len(newColumns) == 0 {
 		return nil
 	}
@@ -373,19 +956,9 @@ func (s *Store) updateMappingAddNewColumns(ctx context.Context, indexName IndexN
 	properties := map[string]any{}
 
 	for _, c := range newColumns {
-		mapping, err := s.mapper.ColumnToSearchMapping(c)
+		mapping, err := s.columnMapping(indexName, overrides, c)
 		if err != nil {
-			if errors.As(err, &search.ErrTypeInvalid{}) {
-				s.logger.Warn(err, "unknown column type", loglib.Fields{
-					"column": map[string]any{
-						"type": c.DataType,
-						"id":   c.PgstreamID,
-					},
-					"schema": indexName.SchemaName(),
-				})
-			} else {
-				return fmt.Errorf("failed to convert column to search mapping: %w", err)
-			}
+			return err
 		}
 
 		if mapping != nil {
@@ -393,13 +966,34 @@ func (s *Store) updateMappingAddNewColumns(ctx context.Context, indexName IndexN
 		}
 	}
 
-	return s.client.PutIndexMappings(ctx, indexName.Name(), map[string]any{
+	mapping := map[string]any{
 		"properties": properties,
-	})
+	}
+	if err := s.client.PutIndexMappings(ctx, indexName.Name(), mapping); err != nil {
+		return err
+	}
+	// the new index version of a migration in progress needs to be able to
+	// receive the new columns too
+	if m := s.getMigration(indexName.Name()); m != nil {
+		return s.client.PutIndexMappings(ctx, m.to.NameWithVersion(), mapping)
+	}
+	return nil
 }
 
 func (s *Store) insertNewSchemaLog(ctx context.Context, m *schemalog.LogEntry) error {
-	logBytes, err := json.Marshal(m)
+	var record any = m
+	if s.indexLayout != IndexPerSchema {
+		if err := s.updateSchemaLogMapping(ctx); err != nil {
+			return fmt.Errorf("insert schema log, failed to update schema log mapping: %w", err)
+		}
+		record = &tableLayoutSchemaLog{
+			LogEntry:    m,
+			IndexLayout: s.indexLayout,
+			Indices:     s.schemaTableIndexNames(m),
+		}
+	}
+
+	logBytes, err := json.Marshal(record)
 	if err != nil {
 		return fmt.Errorf("insert schema log, failed to marshal es doc: %w", err)
 	}
@@ -436,7 +1030,7 @@ func (s *Store) ensureSchemaMapping(ctx context.Context, schemaName string, meta
 		// if the schema didn't exist, but there's a log entry in the schemalog,
 		// we need to reset it to the latest known mapping
 		if metadata != nil && !metadata.IsEmpty() {
-			if err := s.updateMapping(ctx, schemaName, metadata, metadata.Diff(&schemalog.LogEntry{})); err != nil {
+			if err := s.updateMapping(ctx, schemaName, metadata, metadata.Diff(&schemalog.LogEntry{}), nil); err != nil {
 				return fmt.Errorf("updating mapping for missing schema: %w", err)
 			}
 		}
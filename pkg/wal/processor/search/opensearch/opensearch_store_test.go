@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/rs/xid"
@@ -69,7 +70,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 	}{
 		{
 			name:     "ok - nil entry",
-			client:   &esmocks.Client{},
+			client:   &esmocks.Client{GetIndicesMetaFn: noIndexMigrations},
 			logEntry: nil,
 
 			wantErr: nil,
@@ -77,6 +78,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 		{
 			name: "ok",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 					return testSearchResponse, nil
 				},
@@ -92,6 +94,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 		{
 			name: "ok - index doesn't exist",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 					return nil, es.ErrResourceNotFound
 				},
@@ -109,6 +112,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 		{
 			name: "error - ensuring schema exists",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 					return nil, es.ErrResourceNotFound
 				},
@@ -127,6 +131,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 		{
 			name: "error - getting last schema",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 					return nil, errTest
 				},
@@ -138,6 +143,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 		{
 			name: "error - schema out of order",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 					return testSearchResponse, nil
 				},
@@ -158,6 +164,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 		{
 			name: "error - updating mapping",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 					return testSearchResponse, nil
 				},
@@ -171,6 +178,7 @@ func TestStore_ApplySchemaChange(t *testing.T) {
 		{
 			name: "error - ensuring schema mapping",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
 					return testSearchResponse, nil
 				},
@@ -209,10 +217,15 @@ func TestStore_SendDocuments(t *testing.T) {
 		},
 	}
 	errTest := errors.New("oh noes")
+	testLongIDDoc := search.Document{
+		ID:     strings.Repeat("a", osIDFieldLengthLimit+1),
+		Schema: testSchemaName,
+	}
 
 	tests := []struct {
 		name   string
 		client es.SearchClient
+		docs   []search.Document
 
 		wantErrDocs []search.DocumentError
 		wantErr     error
@@ -220,6 +233,7 @@ func TestStore_SendDocuments(t *testing.T) {
 		{
 			name: "ok - no failed documents",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
 					return nil, nil
 				},
@@ -231,6 +245,7 @@ func TestStore_SendDocuments(t *testing.T) {
 		{
 			name: "ok - with failed documents",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
 					return []es.BulkItem{
 						{
@@ -257,9 +272,30 @@ func TestStore_SendDocuments(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "ok - document id too long",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
+					require.Len(t, items, 1)
+					return nil, nil
+				},
+			},
+			docs: []search.Document{testDocs[0], testLongIDDoc},
+
+			wantErrDocs: []search.DocumentError{
+				{
+					Document: testLongIDDoc,
+					Severity: search.SeverityDataLoss,
+					Error:    "ID is longer than 512 bytes",
+				},
+			},
+			wantErr: nil,
+		},
 		{
 			name: "error - sending bulk request",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
 					return nil, errTest
 				},
@@ -277,7 +313,11 @@ func TestStore_SendDocuments(t *testing.T) {
 
 			s := NewStoreWithClient(tc.client)
 
-			errDocs, err := s.SendDocuments(context.Background(), testDocs)
+			docs := testDocs
+			if tc.docs != nil {
+				docs = tc.docs
+			}
+			errDocs, err := s.SendDocuments(context.Background(), docs)
 			require.ErrorIs(t, err, tc.wantErr)
 			require.Equal(t, tc.wantErrDocs, errDocs)
 		})
@@ -288,9 +328,16 @@ func TestStore_DeleteSchema(t *testing.T) {
 	t.Parallel()
 
 	testSchemaName := "test_schema"
-	testIndexWithVersion := "test_schema-1"
+	testIndexWithVersion := "test_schema-2"
 	errTest := errors.New("oh noes")
 
+	getIndexAliasFn := func(ctx context.Context, name string) (map[string]any, error) {
+		require.Equal(t, testSchemaName, name)
+		return map[string]any{
+			testIndexWithVersion: map[string]any{"aliases": map[string]any{testSchemaName: map[string]any{}}},
+		}, nil
+	}
+
 	tests := []struct {
 		name   string
 		client es.SearchClient
@@ -300,6 +347,8 @@ func TestStore_DeleteSchema(t *testing.T) {
 		{
 			name: "ok",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn:  getIndexAliasFn,
 				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
 					require.Equal(t, testIndexWithVersion, index)
 					return true, nil
@@ -327,6 +376,8 @@ func TestStore_DeleteSchema(t *testing.T) {
 		{
 			name: "ok - index doesn't exist",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn:  getIndexAliasFn,
 				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
 					require.Equal(t, testIndexWithVersion, index)
 					return false, nil
@@ -350,9 +401,46 @@ func TestStore_DeleteSchema(t *testing.T) {
 
 			wantErr: nil,
 		},
+		{
+			name: "ok - alias doesn't exist",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn: func(ctx context.Context, name string) (map[string]any, error) {
+					return nil, es.ErrResourceNotFound
+				},
+				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
+					require.Equal(t, "test_schema-1", index)
+					return false, nil
+				},
+				DeleteIndexFn: func(ctx context.Context, index []string) error {
+					return errors.New("DeleteIndexFn: should not be called")
+				},
+				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - getting index alias",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn: func(ctx context.Context, name string) (map[string]any, error) {
+					return nil, errTest
+				},
+				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
+					return false, errors.New("IndexExistsFn: should not be called")
+				},
+			},
+
+			wantErr: errTest,
+		},
 		{
 			name: "error - checking index exists",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn:  getIndexAliasFn,
 				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
 					return false, errTest
 				},
@@ -369,6 +457,8 @@ func TestStore_DeleteSchema(t *testing.T) {
 		{
 			name: "error - deleting index",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn:  getIndexAliasFn,
 				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
 					return true, nil
 				},
@@ -385,6 +475,8 @@ func TestStore_DeleteSchema(t *testing.T) {
 		{
 			name: "error - deleting schema from schema log",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn:  getIndexAliasFn,
 				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
 					return false, nil
 				},
@@ -429,6 +521,7 @@ func TestStore_DeleteTableDocuments(t *testing.T) {
 		{
 			name: "ok",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
 					require.Equal(t, []string{testSchemaName}, req.Index)
 					require.Equal(t, map[string]any{
@@ -449,6 +542,7 @@ func TestStore_DeleteTableDocuments(t *testing.T) {
 		{
 			name: "ok - no tables",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
 					require.Equal(t, []string{testSchemaName}, req.Index)
 					require.Equal(t, map[string]any{
@@ -469,6 +563,7 @@ func TestStore_DeleteTableDocuments(t *testing.T) {
 		{
 			name: "error - deleting by query",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
 					return errTest
 				},
@@ -491,6 +586,167 @@ func TestStore_DeleteTableDocuments(t *testing.T) {
 	}
 }
 
+func TestStore_UpdateTableDocuments(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testDocIDs := []string{"t1_1", "t1_2"}
+	testFields := map[string]any{"customer": map[string]any{"name": "a"}}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name      string
+		client    es.SearchClient
+		layout    IndexLayout
+		docIDs    []string
+		migration *indexMigration
+
+		wantErr error
+	}{
+		{
+			name: "ok - schema index",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					require.Equal(t, []string{testSchemaName}, req.Index)
+					require.Equal(t, map[string]any{
+						"ids": map[string]any{"values": testDocIDs},
+					}, req.Query)
+					require.Equal(t, &es.Script{
+						Source: setFieldsScript,
+						Lang:   "painless",
+						Params: map[string]any{"fields": testFields},
+					}, req.Script)
+					require.Equal(t, "proceed", req.Conflicts)
+					return nil
+				},
+			},
+			layout: IndexPerSchema,
+			docIDs: testDocIDs,
+		},
+		{
+			name: "ok - table index",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					require.Equal(t, []string{"test_schema.t1"}, req.Index)
+					return nil
+				},
+			},
+			layout: IndexPerTable,
+			docIDs: testDocIDs,
+		},
+		{
+			name: "ok - migration in progress completed first",
+			client: func() *esmocks.Client {
+				aliasSwapped := false
+				return &esmocks.Client{
+					GetIndicesMetaFn: noIndexMigrations,
+					GetTaskFn: func(ctx context.Context, taskID string) (*es.Task, error) {
+						return &es.Task{Completed: true, Response: &es.ReindexResponse{Total: 2, Created: 2}}, nil
+					},
+					UpdateAliasesFn: func(ctx context.Context, actions []es.AliasAction) error {
+						aliasSwapped = true
+						return nil
+					},
+					PutIndexSettingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						return nil
+					},
+					PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+						return nil
+					},
+					DeleteIndexFn: func(ctx context.Context, index []string) error {
+						require.Equal(t, []string{"test_schema-1"}, index)
+						return nil
+					},
+					UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+						require.True(t, aliasSwapped, "documents updated before the migration completed")
+						require.Equal(t, []string{testSchemaName}, req.Index)
+						return nil
+					},
+				}
+			}(),
+			layout: IndexPerSchema,
+			docIDs: testDocIDs,
+			migration: &indexMigration{
+				from:   newIndexName(testSchemaName, 1),
+				to:     newIndexName(testSchemaName, 2),
+				taskID: "task-1",
+			},
+		},
+		{
+			name: "error - completing migration",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetTaskFn: func(ctx context.Context, taskID string) (*es.Task, error) {
+					return nil, errTest
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return errors.New("UpdateByQueryFn: should not be called")
+				},
+			},
+			layout: IndexPerSchema,
+			docIDs: testDocIDs,
+			migration: &indexMigration{
+				from:   newIndexName(testSchemaName, 1),
+				to:     newIndexName(testSchemaName, 2),
+				taskID: "task-1",
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name: "ok - no documents",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return errors.New("UpdateByQueryFn: should not be called")
+				},
+			},
+			layout: IndexPerSchema,
+		},
+		{
+			name: "ok - index not found",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return es.ErrResourceNotFound
+				},
+			},
+			layout: IndexPerSchema,
+			docIDs: testDocIDs,
+		},
+		{
+			name: "error - updating by query",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return errTest
+				},
+			},
+			layout: IndexPerSchema,
+			docIDs: testDocIDs,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewStoreWithClient(tc.client)
+			s.indexLayout = tc.layout
+			if tc.migration != nil {
+				s.setMigration(testSchemaName, tc.migration)
+			}
+			err := s.UpdateTableDocuments(context.Background(), testSchemaName, "t1", tc.docIDs, testFields)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
 func TestStore_getLastSchemaLogEntry(t *testing.T) {
 	t.Parallel()
 
@@ -762,6 +1018,18 @@ func TestStore_updateMapping(t *testing.T) {
 	testMapping := map[string]any{
 		"test": "mapping",
 	}
+	testTypeMapper := &searchmocks.Mapper{
+		ColumnToSearchMappingFn: func(column schemalog.Column) (map[string]any, error) {
+			switch column.DataType {
+			case "int4", "int8":
+				return map[string]any{"type": "long"}, nil
+			case "text":
+				return map[string]any{"type": "text"}, nil
+			default:
+				return nil, search.ErrTypeInvalid{Input: column.DataType}
+			}
+		},
+	}
 
 	errTest := errors.New("oh noes")
 
@@ -776,6 +1044,7 @@ func TestStore_updateMapping(t *testing.T) {
 		{
 			name: "ok - no diff",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
 					return errors.New("PutIndexMappingsFn: should not be called")
 				},
@@ -792,6 +1061,7 @@ func TestStore_updateMapping(t *testing.T) {
 		{
 			name: "ok - diff with columns to add",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
 					require.Equal(t, testIndexName, index)
 					require.Equal(t, map[string]any{
@@ -824,6 +1094,7 @@ func TestStore_updateMapping(t *testing.T) {
 		{
 			name: "ok - diff with tables to remove",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
 					return errors.New("PutIndexMappingsFn: should not be called")
 				},
@@ -852,9 +1123,163 @@ func TestStore_updateMapping(t *testing.T) {
 
 			wantErr: nil,
 		},
+		{
+			name: "ok - diff with columns to remove",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					return errors.New("PutIndexMappingsFn: should not be called")
+				},
+				IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+					return nil
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					require.Equal(t, []string{testIndexName}, req.Index)
+					require.Equal(t, map[string]any{
+						"bool": map[string]any{
+							"should": []map[string]any{
+								{"exists": map[string]any{"field": "pgstreamid-1"}},
+								{"exists": map[string]any{"field": "pgstreamid-2"}},
+							},
+							"minimum_should_match": 1,
+						},
+					}, req.Query)
+					require.Equal(t, map[string]any{"fields": []string{"pgstreamid-1", "pgstreamid-2"}}, req.Script.Params)
+					require.Equal(t, "proceed", req.Conflicts)
+					require.True(t, req.Refresh)
+					return nil
+				},
+			},
+			diff: &schemalog.SchemaDiff{
+				ColumnsToRemove: []schemalog.Column{
+					{Name: "col-1", PgstreamID: "pgstreamid-1"},
+				},
+				// no longer supported type
+				ColumnTypeChange: []schemalog.ColumnChange{
+					{
+						Old: schemalog.Column{Name: "col-2", PgstreamID: "pgstreamid-2", DataType: "text"},
+						New: schemalog.Column{Name: "col-2", PgstreamID: "pgstreamid-2", DataType: "unsupported"},
+					},
+				},
+			},
+			mapper: testTypeMapper,
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - diff with renamed and compatible retyped columns",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					return errors.New("PutIndexMappingsFn: should not be called")
+				},
+				IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+					return nil
+				},
+			},
+			diff: &schemalog.SchemaDiff{
+				ColumnsToRename: []schemalog.ColumnChange{
+					{
+						Old: schemalog.Column{Name: "col-1", PgstreamID: "pgstreamid-1", DataType: "int4"},
+						New: schemalog.Column{Name: "col-1-renamed", PgstreamID: "pgstreamid-1", DataType: "int4"},
+					},
+				},
+				ColumnTypeChange: []schemalog.ColumnChange{
+					{
+						Old: schemalog.Column{Name: "col-2", PgstreamID: "pgstreamid-2", DataType: "int4"},
+						New: schemalog.Column{Name: "col-2", PgstreamID: "pgstreamid-2", DataType: "int8"},
+					},
+				},
+			},
+			mapper: testTypeMapper,
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - diff with retyped columns",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				GetIndexAliasFn: func(ctx context.Context, name string) (map[string]any, error) {
+					return map[string]any{"test_schema-1": map[string]any{}}, nil
+				},
+				GetIndexMappingsFn: func(ctx context.Context, index string) (*es.Mappings, error) {
+					return &es.Mappings{
+						Dynamic: "strict",
+						Properties: map[string]any{
+							"pgstreamid-1": map[string]any{"type": "text"},
+							"pgstreamid-2": map[string]any{"type": "text"},
+						},
+					}, nil
+				},
+				IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
+					return false, nil
+				},
+				CreateIndexFn: func(ctx context.Context, index string, body map[string]any) error {
+					require.Equal(t, "test_schema-2", index)
+					mappings := body["mappings"].(map[string]any)
+					require.Equal(t, map[string]any{
+						"pgstreamid-1": map[string]any{"type": "text"},
+						"pgstreamid-2": map[string]any{"type": "long"},
+					}, mappings["properties"])
+					require.Contains(t, mappings["_meta"], migrationMetaField)
+					return nil
+				},
+				StartReindexFn: func(ctx context.Context, req *es.ReindexRequest) (string, error) {
+					// the removed columns are part of the backfill
+					require.Equal(t, []string{"pgstreamid-1", "pgstreamid-2"}, req.Script.Params["remove_fields"])
+					return "task-1", nil
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return errors.New("UpdateByQueryFn: should not be called")
+				},
+				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+					// only the migration state is persisted
+					require.Equal(t, "test_schema-2", index)
+					require.Contains(t, body, "_meta")
+					return nil
+				},
+				IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+					return nil
+				},
+			},
+			diff: &schemalog.SchemaDiff{
+				ColumnsToRemove: []schemalog.Column{
+					{Name: "col-1", PgstreamID: "pgstreamid-1"},
+				},
+				ColumnTypeChange: []schemalog.ColumnChange{
+					{
+						Old: schemalog.Column{Name: "col-2", PgstreamID: "pgstreamid-2", DataType: "text"},
+						New: schemalog.Column{Name: "col-2", PgstreamID: "pgstreamid-2", DataType: "int8"},
+					},
+				},
+			},
+			mapper: testTypeMapper,
+
+			wantErr: nil,
+		},
+		{
+			name: "error - removing columns",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+					return errors.New("IndexWithIDFn: should not be called")
+				},
+				UpdateByQueryFn: func(ctx context.Context, req *es.UpdateByQueryRequest) error {
+					return errTest
+				},
+			},
+			diff: &schemalog.SchemaDiff{
+				ColumnsToRemove: []schemalog.Column{
+					{Name: "col-1", PgstreamID: "pgstreamid-1"},
+				},
+			},
+
+			wantErr: errTest,
+		},
 		{
 			name: "error - updating mapping",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
 					return errTest
 				},
@@ -876,6 +1301,7 @@ func TestStore_updateMapping(t *testing.T) {
 		{
 			name: "error - deleting tables",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
 					return errors.New("PutIndexMappingsFn: should not be called")
 				},
@@ -898,6 +1324,7 @@ func TestStore_updateMapping(t *testing.T) {
 		{
 			name: "error - inserting schemalog",
 			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
 				PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
 					return errors.New("PutIndexMappingsFn: should not be called")
 				},
@@ -923,7 +1350,7 @@ func TestStore_updateMapping(t *testing.T) {
 				s.mapper = tc.mapper
 			}
 
-			err := s.updateMapping(context.Background(), testSchemaName, testLogEntry, tc.diff)
+			err := s.updateMapping(context.Background(), testSchemaName, testLogEntry, tc.diff, nil)
 			require.ErrorIs(t, err, tc.wantErr)
 		})
 	}
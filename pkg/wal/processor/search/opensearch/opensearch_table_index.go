@@ -0,0 +1,383 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xataio/pgstream/internal/es"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+const (
+	// schemaLogIndexLayoutField and schemaLogIndicesField are the schema log
+	// fields that track the table index layout of the schema, and the index
+	// names of its tables by pgstream id.
+	schemaLogIndexLayoutField = "index_layout"
+	schemaLogIndicesField     = "indices"
+)
+
+var schemaLogLayoutProperties = map[string]any{
+	schemaLogIndexLayoutField: map[string]any{
+		"type": "keyword",
+	},
+	schemaLogIndicesField: map[string]any{
+		// the keys are the table pgstream ids, so they're stored but not
+		// mapped
+		"type":    "object",
+		"enabled": false,
+	},
+}
+
+// tableLayoutSchemaLog is the schema log document used with the table index
+// layouts.
+type tableLayoutSchemaLog struct {
+	*schemalog.LogEntry
+	IndexLayout IndexLayout       `json:"index_layout"`
+	Indices     map[string]string `json:"indices"`
+}
+
+var (
+	errTableIndexNotFound      = errors.New("table index not found")
+	errTableIndexNameCollision = errors.New("table index name collision")
+)
+
+// updateTableIndices applies the schema change on input to the indices of the
+// schema tables. New tables get their own index, dropped tables have their
+// index deleted, and the mapping changes are applied to each table index.
+func (s *Store) updateTableIndices(ctx context.Context, newEntry, previousEntry *schemalog.LogEntry) error {
+	if previousEntry == nil {
+		previousEntry = &schemalog.LogEntry{SchemaName: newEntry.SchemaName}
+	}
+
+	if err := s.checkTableIndexNames(newEntry); err != nil {
+		return err
+	}
+
+	existing, err := s.existingTableIndices(ctx, newEntry.SchemaName)
+	if err != nil {
+		return fmt.Errorf("listing table indices: %w", err)
+	}
+
+	for _, table := range newEntry.Diff(previousEntry).TablesToRemove {
+		if rt := s.newRolloverTable(newEntry.SchemaName, &table); rt != nil {
+			if err := s.deleteRolloverIndices(ctx, rt); err != nil {
+				return fmt.Errorf("deleting rollover indices for table %s: %w", table.Name, err)
+			}
+			continue
+		}
+		index := s.tableIndexName(newEntry.SchemaName, &table)
+		if err := s.deleteIndex(ctx, index); err != nil {
+			return fmt.Errorf("deleting index for table %s: %w", table.Name, err)
+		}
+	}
+
+	for i := range newEntry.Schema.Tables {
+		table := &newEntry.Schema.Tables[i]
+		index := s.tableIndexName(newEntry.SchemaName, table)
+		overrides := s.tableIndexOverrides(newEntry.SchemaName, table)
+		previousTable := previousEntry.GetTableByID(table.PgstreamID)
+
+		if rt := s.newRolloverTable(newEntry.SchemaName, table); rt != nil {
+			if err := s.updateRolloverIndices(ctx, rt, previousTable, existing[table.PgstreamID]); err != nil {
+				return fmt.Errorf("updating rollover indices for table %s: %w", table.Name, err)
+			}
+			continue
+		}
+
+		switch {
+		case !existing[table.PgstreamID]:
+			// new table, or missing index, which is created with the latest
+			// table mapping. This allows us to self recover in case of table
+			// index deletion.
+			if err := s.createTableIndex(ctx, index, overrides.settings); err != nil {
+				return fmt.Errorf("creating index for table %s: %w", table.Name, err)
+			}
+			previousTable = nil
+		case previousTable != nil:
+			previousIndex := s.tableIndexName(newEntry.SchemaName, previousTable)
+			if previousIndex.Name() != index.Name() {
+				if err := s.renameTableIndex(ctx, previousIndex, index); err != nil {
+					return fmt.Errorf("renaming index for table %s: %w", table.Name, err)
+				}
+			}
+		}
+
+		diff := tableDiff(table, previousTable)
+		identityChanges := getIdentityChanges(newEntry, previousEntry, diff)
+		if err := s.updateIndexMapping(ctx, index, newEntry.Version, overrides, diff, identityChanges); err != nil {
+			return fmt.Errorf("updating mapping for table %s: %w", table.Name, err)
+		}
+	}
+
+	if err := s.insertNewSchemaLog(ctx, newEntry); err != nil {
+		return fmt.Errorf("failed to insert new schema log: %w", mapError(err))
+	}
+	s.setTableIndices(newEntry)
+	s.setRolloverTables(newEntry)
+
+	return nil
+}
+
+// checkTableIndexNames returns an error if two tables of the schema have the
+// same index name. This can happen with the table name layout, since the table
+// names are lowercased, and the tables would otherwise share the same alias.
+func (s *Store) checkTableIndexNames(logEntry *schemalog.LogEntry) error {
+	if s.indexLayout != IndexPerTableName {
+		return nil
+	}
+
+	tables := make(map[string]string, len(logEntry.Schema.Tables))
+	for i := range logEntry.Schema.Tables {
+		table := &logEntry.Schema.Tables[i]
+		name := s.tableIndexName(logEntry.SchemaName, table).Name()
+		if other, found := tables[name]; found {
+			return fmt.Errorf("%w: tables %q and %q have the same index name %s", errTableIndexNameCollision, other, table.Name, name)
+		}
+		tables[name] = table.Name
+	}
+	return nil
+}
+
+// tableDiff returns the diff between the two versions of the table on input.
+// The previous table can be nil if it didn't exist.
+func tableDiff(table, previousTable *schemalog.Table) *schemalog.SchemaDiff {
+	previous := &schemalog.Schema{}
+	if previousTable != nil {
+		previous.Tables = []schemalog.Table{*previousTable}
+	}
+	return (&schemalog.Schema{Tables: []schemalog.Table{*table}}).Diff(previous)
+}
+
+// existingTableIndices returns the pgstream ids of the schema tables that have
+// an index.
+func (s *Store) existingTableIndices(ctx context.Context, schemaName string) (map[string]bool, error) {
+	prefix := tableIndexPrefix(schemaName, "")
+	indices, err := s.client.ListIndices(ctx, []string{prefix + "*"})
+	if err != nil {
+		return nil, mapError(err)
+	}
+
+	tableIDs := make(map[string]bool, len(indices))
+	for _, index := range indices {
+		i := strings.LastIndex(index, "-")
+		if !strings.HasPrefix(index, prefix) || i < len(prefix) {
+			continue
+		}
+		// the bucket indices of rollover tables have format
+		// <schema>.<table_id>.<bucket>-<version>
+		tableID, _, _ := strings.Cut(index[len(prefix):i], ".")
+		tableIDs[tableID] = true
+	}
+	return tableIDs, nil
+}
+
+func (s *Store) createTableIndex(ctx context.Context, index IndexName, settings map[string]any) error {
+	if err := s.createIndex(ctx, index, settings); err != nil && !errors.As(err, &es.ErrResourceAlreadyExists{}) {
+		return mapError(err)
+	}
+	return nil
+}
+
+// renameTableIndex moves the alias of the table index, and completes any
+// migration in progress so that its alias is moved too.
+func (s *Store) renameTableIndex(ctx context.Context, from, to IndexName) error {
+	if err := s.completeMigration(ctx, from.Name()); err != nil {
+		return err
+	}
+
+	current, err := s.currentIndex(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.UpdateAliases(ctx, []es.AliasAction{
+		{Remove: &es.AliasActionTarget{Index: current.NameWithVersion(), Alias: from.Name()}},
+		{Add: &es.AliasActionTarget{Index: current.NameWithVersion(), Alias: to.Name()}},
+	}); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+// deleteSchemaTableIndices deletes the indices of all the schema tables.
+func (s *Store) deleteSchemaTableIndices(ctx context.Context, schemaName string) error {
+	for _, name := range s.schemaMigrations(schemaName) {
+		if m := s.getMigration(name); m != nil {
+			for _, i := range []IndexName{m.from, m.to} {
+				if err := s.client.DeleteIndex(ctx, []string{i.NameWithVersion()}); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+					return mapError(err)
+				}
+			}
+		}
+		s.removeMigration(name)
+	}
+
+	indices, err := s.client.ListIndices(ctx, []string{tableIndexPrefix(schemaName, "*")})
+	if err != nil {
+		return mapError(err)
+	}
+	if len(indices) > 0 {
+		if err := s.client.DeleteIndex(ctx, indices); err != nil && !errors.Is(err, es.ErrResourceNotFound) {
+			return mapError(err)
+		}
+	}
+
+	s.forgetRolloverSchema(schemaName)
+	s.forgetSoftDeleteSchema(schemaName)
+
+	s.tableIndicesLock.Lock()
+	defer s.tableIndicesLock.Unlock()
+	delete(s.tableIndices, schemaName)
+	return nil
+}
+
+// deleteTableIndexDocuments deletes all the documents from the indices of the
+// tables on input.
+func (s *Store) deleteTableIndexDocuments(ctx context.Context, schemaName string, tableIDs []string) error {
+	for _, tableID := range tableIDs {
+		index, err := s.tableIndex(ctx, schemaName, tableID)
+		if err != nil {
+			return err
+		}
+
+		// the backfill of an index migration in progress could bring back
+		// the deleted documents, so it needs to be completed first
+		if err := s.completeMigration(ctx, index.Name()); err != nil {
+			return err
+		}
+
+		if err := s.client.DeleteByQuery(ctx, &es.DeleteByQueryRequest{
+			Index: []string{index.Name()},
+			Query: map[string]any{
+				"query": map[string]any{
+					"match_all": map[string]any{},
+				},
+			},
+			Refresh: true,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentTableIndex returns the index of the table the document belongs to.
+func (s *Store) documentTableIndex(ctx context.Context, doc search.Document) (IndexName, error) {
+	tableID, ok := doc.Data["_table"].(string)
+	if !ok || tableID == "" {
+		return nil, fmt.Errorf("document without table: %w", errTableIndexNotFound)
+	}
+	return s.tableIndex(ctx, doc.Schema, tableID)
+}
+
+// tableIndex returns the index of the schema table with the pgstream id on
+// input. With the table name index layout, the index names are taken from the
+// latest schema log entry.
+func (s *Store) tableIndex(ctx context.Context, schemaName, tableID string) (IndexName, error) {
+	if s.indexLayout != IndexPerTableName {
+		return s.tableIndexName(schemaName, &schemalog.Table{PgstreamID: tableID}), nil
+	}
+
+	if index := s.getTableIndex(schemaName, tableID); index != nil {
+		return index, nil
+	}
+
+	// the table indices are not known after a restart until the schema log is
+	// loaded
+	logEntry, err := s.getLastSchemaLogEntry(ctx, schemaName)
+	if err != nil {
+		if errors.As(err, &search.ErrSchemaNotFound{}) {
+			return nil, fmt.Errorf("schema %s: %w", schemaName, errTableIndexNotFound)
+		}
+		return nil, err
+	}
+	s.setTableIndices(logEntry)
+
+	if index := s.getTableIndex(schemaName, tableID); index != nil {
+		return index, nil
+	}
+	return nil, fmt.Errorf("table %s in schema %s: %w", tableID, schemaName, errTableIndexNotFound)
+}
+
+// tableIndexName returns the index name of the table on input, based on the
+// store index layout.
+func (s *Store) tableIndexName(schemaName string, table *schemalog.Table) IndexName {
+	if s.indexLayout == IndexPerTableName {
+		return newTableIndexName(schemaName, table.PgstreamID, tableIndexPrefix(schemaName, strings.ToLower(table.Name)))
+	}
+	return newTableIndexName(schemaName, table.PgstreamID, tableIndexPrefix(schemaName, table.PgstreamID))
+}
+
+func (s *Store) getTableIndex(schemaName, tableID string) IndexName {
+	s.tableIndicesLock.RLock()
+	defer s.tableIndicesLock.RUnlock()
+	return s.tableIndices[schemaName][tableID]
+}
+
+func (s *Store) setTableIndices(logEntry *schemalog.LogEntry) {
+	if s.indexLayout != IndexPerTableName {
+		return
+	}
+
+	indices := make(map[string]IndexName, len(logEntry.Schema.Tables))
+	for i := range logEntry.Schema.Tables {
+		table := &logEntry.Schema.Tables[i]
+		indices[table.PgstreamID] = s.tableIndexName(logEntry.SchemaName, table)
+	}
+
+	s.tableIndicesLock.Lock()
+	defer s.tableIndicesLock.Unlock()
+	s.tableIndices[logEntry.SchemaName] = indices
+}
+
+// schemaTableIndexNames returns the index names of the schema tables, by table
+// pgstream id.
+func (s *Store) schemaTableIndexNames(logEntry *schemalog.LogEntry) map[string]string {
+	names := make(map[string]string, len(logEntry.Schema.Tables))
+	for i := range logEntry.Schema.Tables {
+		table := &logEntry.Schema.Tables[i]
+		names[table.PgstreamID] = s.tableIndexName(logEntry.SchemaName, table).Name()
+	}
+	return names
+}
+
+// tableIndexFailures updates the failed bulk items on input to report the
+// table index failures using the schema index name, so that they can be
+// mapped back to their schema.
+func (s *Store) tableIndexFailures(failed []es.BulkItem, tableIndexSchemas map[string]string) {
+	for _, item := range failed {
+		for _, bi := range []*es.BulkIndex{item.Index, item.Delete} {
+			if bi == nil {
+				continue
+			}
+			if schemaName, found := tableIndexSchemas[bi.Index]; found {
+				bi.Index = s.adapter.SchemaNameToIndex(schemaName).Name()
+			}
+		}
+	}
+}
+
+// updateSchemaLogMapping adds the table index layout fields to the schema log
+// index mapping, which can be missing if it was created by an older version.
+func (s *Store) updateSchemaLogMapping(ctx context.Context) error {
+	s.schemaLogMappingLock.Lock()
+	defer s.schemaLogMappingLock.Unlock()
+	if s.schemaLogMappingUpdated {
+		return nil
+	}
+
+	if err := s.client.PutIndexMappings(ctx, schemalogIndexName, map[string]any{
+		"properties": schemaLogLayoutProperties,
+	}); err != nil {
+		return mapError(err)
+	}
+	s.schemaLogMappingUpdated = true
+	s.logger.Debug("schema log mapping updated", loglib.Fields{"index": schemalogIndexName})
+	return nil
+}
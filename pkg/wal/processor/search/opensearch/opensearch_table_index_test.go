@@ -0,0 +1,512 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/es"
+	esmocks "github.com/xataio/pgstream/internal/es/mocks"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+	searchmocks "github.com/xataio/pgstream/pkg/wal/processor/search/mocks"
+)
+
+func TestStore_updateTableIndices(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testPreviousEntry := &schemalog.LogEntry{
+		ID:         xid.New(),
+		Version:    1,
+		SchemaName: testSchemaName,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					PgstreamID:        "t1",
+					Name:              "Table1",
+					Columns:           []schemalog.Column{{PgstreamID: "t1-1", Name: "id", DataType: "int8"}},
+					PrimaryKeyColumns: []string{"id"},
+				},
+				{
+					PgstreamID:        "t2",
+					Name:              "table2",
+					Columns:           []schemalog.Column{{PgstreamID: "t2-1", Name: "id", DataType: "int8"}},
+					PrimaryKeyColumns: []string{"id"},
+				},
+			},
+		},
+	}
+	// table1 is renamed and has a new column, table2 is dropped and table3 is
+	// created
+	testNewEntry := &schemalog.LogEntry{
+		ID:         xid.New(),
+		Version:    2,
+		SchemaName: testSchemaName,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					PgstreamID: "t1",
+					Name:       "renamed",
+					Columns: []schemalog.Column{
+						{PgstreamID: "t1-1", Name: "id", DataType: "int8"},
+						{PgstreamID: "t1-2", Name: "name", DataType: "text"},
+					},
+					PrimaryKeyColumns: []string{"id"},
+				},
+				{
+					PgstreamID:        "t3",
+					Name:              "table3",
+					Columns:           []schemalog.Column{{PgstreamID: "t3-1", Name: "id", DataType: "int8"}},
+					PrimaryKeyColumns: []string{"id"},
+				},
+			},
+		},
+	}
+	// the table names only differ in case
+	testCollisionEntry := &schemalog.LogEntry{
+		ID:         xid.New(),
+		Version:    2,
+		SchemaName: testSchemaName,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{PgstreamID: "t1", Name: "Table1"},
+				{PgstreamID: "t2", Name: "table1"},
+			},
+		},
+	}
+	testMapping := map[string]any{"type": "keyword"}
+	errTest := errors.New("oh noes")
+
+	// newClient returns a client that records the calls it receives
+	newClient := func(t *testing.T, calls *[]string) *esmocks.Client {
+		record := func(format string, args ...any) {
+			*calls = append(*calls, fmt.Sprintf(format, args...))
+		}
+		return &esmocks.Client{
+			GetIndicesMetaFn: noIndexMigrations,
+			ListIndicesFn: func(ctx context.Context, indices []string) ([]string, error) {
+				record("list %v", indices)
+				return []string{"test_schema.t1-1", "test_schema.t2-1"}, nil
+			},
+			GetIndexAliasFn: func(ctx context.Context, name string) (map[string]any, error) {
+				record("get alias %s", name)
+				switch name {
+				case "test_schema.t1", "test_schema.table1":
+					return map[string]any{"test_schema.t1-1": map[string]any{}}, nil
+				case "test_schema.t2", "test_schema.table2":
+					return map[string]any{"test_schema.t2-1": map[string]any{}}, nil
+				}
+				return nil, es.ErrResourceNotFound
+			},
+			IndexExistsFn: func(ctx context.Context, index string) (bool, error) {
+				record("exists %s", index)
+				return true, nil
+			},
+			DeleteIndexFn: func(ctx context.Context, index []string) error {
+				record("delete %v", index)
+				return nil
+			},
+			CreateIndexFn: func(ctx context.Context, index string, body map[string]any) error {
+				record("create %s", index)
+				return nil
+			},
+			PutIndexAliasFn: func(ctx context.Context, index []string, name string) error {
+				record("put alias %v %s", index, name)
+				return nil
+			},
+			UpdateAliasesFn: func(ctx context.Context, actions []es.AliasAction) error {
+				record("move alias %s %s -> %s", actions[0].Remove.Index, actions[0].Remove.Alias, actions[1].Add.Alias)
+				return nil
+			},
+			PutIndexMappingsFn: func(ctx context.Context, index string, body map[string]any) error {
+				properties, ok := body["properties"].(map[string]any)
+				require.True(t, ok)
+				if index == schemalogIndexName {
+					require.Equal(t, schemaLogLayoutProperties, properties)
+					record("put mapping %s layout", index)
+					return nil
+				}
+				require.Len(t, properties, 1)
+				for field := range properties {
+					record("put mapping %s %s", index, field)
+				}
+				return nil
+			},
+			IndexWithIDFn: func(ctx context.Context, req *es.IndexWithIDRequest) error {
+				record("index schema log %s", req.Index)
+				schemaLog := map[string]any{}
+				require.NoError(t, json.Unmarshal(req.Body, &schemaLog))
+				require.NotEmpty(t, schemaLog["index_layout"])
+				require.Len(t, schemaLog["indices"], 2)
+				return nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		layout      IndexLayout
+		newEntry    *schemalog.LogEntry
+		clientErrFn func(*esmocks.Client)
+
+		wantCalls []string
+		wantErr   error
+	}{
+		{
+			name:   "ok - table layout",
+			layout: IndexPerTable,
+
+			wantCalls: []string{
+				"list [test_schema.*]",
+				"get alias test_schema.t2",
+				"exists test_schema.t2-1",
+				"delete [test_schema.t2-1]",
+				"put mapping test_schema.t1 t1-2",
+				"create test_schema.t3-1",
+				"put alias [test_schema.t3-1] test_schema.t3",
+				"put mapping test_schema.t3 t3-1",
+				"put mapping pgstream layout",
+				"index schema log pgstream",
+			},
+		},
+		{
+			name:   "ok - table name layout",
+			layout: IndexPerTableName,
+
+			wantCalls: []string{
+				"list [test_schema.*]",
+				"get alias test_schema.table2",
+				"exists test_schema.t2-1",
+				"delete [test_schema.t2-1]",
+				"get alias test_schema.table1",
+				"move alias test_schema.t1-1 test_schema.table1 -> test_schema.renamed",
+				"put mapping test_schema.renamed t1-2",
+				"create test_schema.t3-1",
+				"put alias [test_schema.t3-1] test_schema.table3",
+				"put mapping test_schema.table3 t3-1",
+				"put mapping pgstream layout",
+				"index schema log pgstream",
+			},
+		},
+		{
+			name:     "error - table name layout index collision",
+			layout:   IndexPerTableName,
+			newEntry: testCollisionEntry,
+
+			wantCalls: []string{},
+			wantErr:   errTableIndexNameCollision,
+		},
+		{
+			name:   "error - listing indices",
+			layout: IndexPerTable,
+			clientErrFn: func(c *esmocks.Client) {
+				c.ListIndicesFn = func(ctx context.Context, indices []string) ([]string, error) {
+					return nil, errTest
+				}
+			},
+
+			wantCalls: []string{},
+			wantErr:   errTest,
+		},
+		{
+			name:   "error - creating index",
+			layout: IndexPerTable,
+			clientErrFn: func(c *esmocks.Client) {
+				c.CreateIndexFn = func(ctx context.Context, index string, body map[string]any) error {
+					return errTest
+				}
+			},
+
+			wantCalls: []string{
+				"list [test_schema.*]",
+				"get alias test_schema.t2",
+				"exists test_schema.t2-1",
+				"delete [test_schema.t2-1]",
+				"put mapping test_schema.t1 t1-2",
+			},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := []string{}
+			client := newClient(t, &calls)
+			if tc.clientErrFn != nil {
+				tc.clientErrFn(client)
+			}
+			s := NewStoreWithClient(client)
+			s.indexLayout = tc.layout
+			s.mapper = &searchmocks.Mapper{
+				ColumnToSearchMappingFn: func(column schemalog.Column) (map[string]any, error) {
+					return testMapping, nil
+				},
+			}
+
+			newEntry := testNewEntry
+			if tc.newEntry != nil {
+				newEntry = tc.newEntry
+			}
+
+			err := s.updateTableIndices(context.Background(), newEntry, testPreviousEntry)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantCalls, calls)
+
+			if tc.layout == IndexPerTableName && tc.wantErr == nil {
+				require.Equal(t, "test_schema.renamed", s.getTableIndex(testSchemaName, "t1").Name())
+				require.Equal(t, "test_schema.table3", s.getTableIndex(testSchemaName, "t3").Name())
+				require.Nil(t, s.getTableIndex(testSchemaName, "t2"))
+			}
+		})
+	}
+}
+
+func TestStore_SendDocuments_tableIndex(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	testDocs := []search.Document{
+		{ID: "t1_1", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t1"}},
+		{ID: "t2_1", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t2"}, Delete: true},
+		// unknown table
+		{ID: "t3_1", Schema: testSchemaName, Version: 1, Data: map[string]any{"_table": "t3"}},
+	}
+	testLogEntry := &schemalog.LogEntry{
+		SchemaName: testSchemaName,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{PgstreamID: "t1", Name: "Table1"},
+				{PgstreamID: "t2", Name: "table2"},
+			},
+		},
+	}
+	testLogEntryBytes, err := json.Marshal(testLogEntry)
+	require.NoError(t, err)
+	testLogEntryRecord := map[string]any{}
+	require.NoError(t, json.Unmarshal(testLogEntryBytes, &testLogEntryRecord))
+
+	testBulkFailure := search.DocumentError{
+		Document: search.Document{ID: "t1_1", Schema: testSchemaName, Version: 1},
+		Severity: search.SeverityDataLoss,
+		Error:    "oh noes",
+	}
+
+	tests := []struct {
+		name   string
+		layout IndexLayout
+
+		wantIndices []string
+		wantFailed  []search.DocumentError
+	}{
+		{
+			name:        "table layout",
+			layout:      IndexPerTable,
+			wantIndices: []string{"test_schema.t1", "test_schema.t2", "test_schema.t3"},
+			wantFailed:  []search.DocumentError{testBulkFailure},
+		},
+		{
+			name:        "table name layout",
+			layout:      IndexPerTableName,
+			wantIndices: []string{"test_schema.table1", "test_schema.table2"},
+			wantFailed: []search.DocumentError{
+				{
+					Document: testDocs[2],
+					Severity: search.SeverityDataLoss,
+					Error:    "table t3 in schema test_schema: table index not found",
+				},
+				testBulkFailure,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+					return &es.SearchResponse{
+						Hits: es.Hits{Hits: []es.Hit{{Source: testLogEntryRecord}}},
+					}, nil
+				},
+				SendBulkRequestFn: func(ctx context.Context, items []es.BulkItem) ([]es.BulkItem, error) {
+					indices := []string{}
+					for _, item := range items {
+						indices = append(indices, bulkItemIndex(item))
+					}
+					require.Equal(t, tc.wantIndices, indices)
+					// the write to the first table fails
+					return []es.BulkItem{
+						{
+							Index:  items[0].Index,
+							Status: http.StatusBadRequest,
+							Error:  []byte("oh noes"),
+						},
+					}, nil
+				},
+			}
+
+			s := NewStoreWithClient(client)
+			s.indexLayout = tc.layout
+
+			failed, err := s.SendDocuments(context.Background(), testDocs)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantFailed, failed)
+		})
+	}
+}
+
+func TestStore_DeleteSchema_tableIndex(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		client *esmocks.Client
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				ListIndicesFn: func(ctx context.Context, indices []string) ([]string, error) {
+					require.Equal(t, []string{"test_schema.*"}, indices)
+					return []string{"test_schema.t1-1", "test_schema.t2-3"}, nil
+				},
+				DeleteIndexFn: func(ctx context.Context, index []string) error {
+					require.Equal(t, []string{"test_schema.t1-1", "test_schema.t2-3"}, index)
+					return nil
+				},
+				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+					require.Equal(t, []string{schemalogIndexName}, req.Index)
+					return nil
+				},
+			},
+		},
+		{
+			name: "ok - no table indices",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				ListIndicesFn: func(ctx context.Context, indices []string) ([]string, error) {
+					return []string{}, nil
+				},
+				DeleteIndexFn: func(ctx context.Context, index []string) error {
+					return errors.New("DeleteIndexFn: should not be called")
+				},
+				DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+					return nil
+				},
+			},
+		},
+		{
+			name: "error - listing indices",
+			client: &esmocks.Client{
+				GetIndicesMetaFn: noIndexMigrations,
+				ListIndicesFn: func(ctx context.Context, indices []string) ([]string, error) {
+					return nil, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewStoreWithClient(tc.client)
+			s.indexLayout = IndexPerTable
+
+			err := s.DeleteSchema(context.Background(), testSchemaName)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestStore_DeleteTableDocuments_tableIndex(t *testing.T) {
+	t.Parallel()
+
+	s := NewStoreWithClient(&esmocks.Client{
+		GetIndicesMetaFn: noIndexMigrations,
+		DeleteByQueryFn: func(ctx context.Context, req *es.DeleteByQueryRequest) error {
+			require.Equal(t, []string{"test_schema.t1"}, req.Index)
+			require.Equal(t, map[string]any{
+				"query": map[string]any{
+					"match_all": map[string]any{},
+				},
+			}, req.Query)
+			return nil
+		},
+	})
+	s.indexLayout = IndexPerTable
+
+	err := s.DeleteTableDocuments(context.Background(), "test_schema", []string{"t1"})
+	require.NoError(t, err)
+}
+
+func TestStore_ApplySchemaChange_indexLayoutChange(t *testing.T) {
+	t.Parallel()
+
+	testSchemaName := "test_schema"
+	s := NewStoreWithClient(&esmocks.Client{
+		GetIndicesMetaFn: noIndexMigrations,
+		SearchFn: func(ctx context.Context, req *es.SearchRequest) (*es.SearchResponse, error) {
+			// schema log indexed with the schema layout
+			return &es.SearchResponse{
+				Hits: es.Hits{Hits: []es.Hit{{Source: map[string]any{"schema_name": testSchemaName, "version": 1}}}},
+			}, nil
+		},
+	})
+	s.indexLayout = IndexPerTable
+
+	err := s.ApplySchemaChange(context.Background(), &schemalog.LogEntry{
+		SchemaName: testSchemaName,
+		Version:    2,
+	})
+	require.ErrorContains(t, err, `uses the "schema" index layout`)
+}
+
+func Test_parseIndexVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+
+		wantVersion int
+		wantErr     bool
+	}{
+		{name: "test_schema-2", wantVersion: 2},
+		{name: "test-schema.t1-10", wantVersion: 10},
+		{name: "test_schema", wantErr: true},
+		{name: "test_schema-a", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			version, err := parseIndexVersion(tc.name)
+			require.Equal(t, tc.wantErr, err != nil)
+			require.Equal(t, tc.wantVersion, version)
+		})
+	}
+}
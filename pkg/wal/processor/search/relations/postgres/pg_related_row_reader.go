@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogpg "github.com/xataio/pgstream/pkg/schemalog/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// Reader reads the related rows embedded in the search documents from the
+// source postgres database. The table columns are taken from the latest schema
+// log of the relation schema.
+type Reader struct {
+	conn           pglib.Querier
+	schemaLogStore schemalog.Store
+}
+
+type Config struct {
+	// URL of the source postgres database.
+	URL string
+}
+
+var (
+	errTableNotFound  = errors.New("table not found in schema log")
+	errColumnNotFound = errors.New("column not found in schema log")
+	errNoIDColumns    = errors.New("table has no primary key or unique not null column")
+)
+
+func NewReader(ctx context.Context, cfg Config) (*Reader, error) {
+	pool, err := pglib.NewConnPool(ctx, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("create postgres connection pool: %w", err)
+	}
+	return &Reader{
+		conn:           pool,
+		schemaLogStore: schemalogpg.NewStoreWithQuerier(pool),
+	}, nil
+}
+
+// ParentRows returns the rows of the relation parent table with the keys on
+// input, as JSON objects of the relation columns, by key.
+func (r *Reader) ParentRows(ctx context.Context, relation *search.Relation, keys []string) (map[string]map[string]any, error) {
+	parentRows := make(map[string]map[string]any, len(keys))
+	if len(keys) == 0 {
+		return parentRows, nil
+	}
+
+	parent, err := r.table(ctx, relation.Schema, relation.Parent)
+	if err != nil {
+		return nil, err
+	}
+	keyColumns, err := tableColumns(parent, relation.ParentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s, %s FROM %s AS t WHERE %s`,
+		keyExpression(keyColumns), rowExpression(relation.Columns),
+		pgx.Identifier{relation.Schema, relation.Parent}.Sanitize(), keyCondition(keyColumns))
+	rows, err := r.conn.Query(ctx, query, keys)
+	if err != nil {
+		return nil, fmt.Errorf("querying parent rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		row := map[string]any{}
+		if err := rows.Scan(&key, &row); err != nil {
+			return nil, fmt.Errorf("scanning parent row: %w", err)
+		}
+		parentRows[key] = row
+	}
+	return parentRows, rows.Err()
+}
+
+// ChildDocumentIDs returns the pgstream id of the relation child table, along
+// with the ids of the documents of the child rows that reference the parent
+// keys on input, by parent key.
+func (r *Reader) ChildDocumentIDs(ctx context.Context, relation *search.Relation, keys []string) (string, map[string][]string, error) {
+	child, err := r.table(ctx, relation.Schema, relation.Table)
+	if err != nil {
+		return "", nil, err
+	}
+
+	docIDs := make(map[string][]string, len(keys))
+	if len(keys) == 0 {
+		return child.PgstreamID, docIDs, nil
+	}
+
+	keyColumns, err := tableColumns(child, relation.ForeignKey)
+	if err != nil {
+		return "", nil, err
+	}
+	idColumns := documentIDColumns(child)
+	if len(idColumns) == 0 {
+		return "", nil, fmt.Errorf("%s.%s: %w", relation.Schema, relation.Table, errNoIDColumns)
+	}
+
+	query := fmt.Sprintf(`SELECT %s, %s || '_' || %s FROM %s AS t WHERE %s`,
+		keyExpression(keyColumns), quoteLiteral(child.PgstreamID), keyExpression(idColumns),
+		pgx.Identifier{relation.Schema, relation.Table}.Sanitize(), keyCondition(keyColumns))
+	rows, err := r.conn.Query(ctx, query, keys)
+	if err != nil {
+		return "", nil, fmt.Errorf("querying child rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, docID string
+		if err := rows.Scan(&key, &docID); err != nil {
+			return "", nil, fmt.Errorf("scanning child row: %w", err)
+		}
+		docIDs[key] = append(docIDs[key], docID)
+	}
+	return child.PgstreamID, docIDs, rows.Err()
+}
+
+func (r *Reader) Close() error {
+	return r.conn.Close(context.Background())
+}
+
+func (r *Reader) table(ctx context.Context, schemaName, tableName string) (*schemalog.Table, error) {
+	logEntry, err := r.schemaLogStore.Fetch(ctx, schemaName, false)
+	if err != nil {
+		return nil, fmt.Errorf("fetching schema log for schema %s: %w", schemaName, err)
+	}
+	table := logEntry.GetTableByName(tableName)
+	if table == nil {
+		return nil, fmt.Errorf("%s.%s: %w", schemaName, tableName, errTableNotFound)
+	}
+	return table, nil
+}
+
+// tableColumns returns the table columns with the names on input, in the same
+// order.
+func tableColumns(table *schemalog.Table, names []string) ([]schemalog.Column, error) {
+	columns := make([]schemalog.Column, 0, len(names))
+	for _, name := range names {
+		c := table.GetColumnByName(name)
+		if c == nil {
+			return nil, fmt.Errorf("%s.%s: %w", table.Name, name, errColumnNotFound)
+		}
+		columns = append(columns, *c)
+	}
+	return columns, nil
+}
+
+// documentIDColumns returns the columns that make up the table document ids.
+// They are the same used by the WAL translator by default: the primary key, or
+// the first unique not null column if there's none, in table column order.
+func documentIDColumns(table *schemalog.Table) []schemalog.Column {
+	idColumns := []schemalog.Column{}
+	uniqueNotNull := table.GetFirstUniqueNotNullColumn()
+	for _, c := range table.Columns {
+		switch {
+		case len(table.PrimaryKeyColumns) > 0 && slices.Contains(table.PrimaryKeyColumns, c.Name):
+			idColumns = append(idColumns, c)
+		case len(table.PrimaryKeyColumns) == 0 && uniqueNotNull != nil && c.Name == uniqueNotNull.Name:
+			idColumns = append(idColumns, c)
+		}
+	}
+	return idColumns
+}
+
+// keyExpression returns the expression of the key made of the columns on
+// input, with their values joined by '-' like the document ids.
+func keyExpression(columns []schemalog.Column) string {
+	if len(columns) == 1 {
+		return "t." + pgx.Identifier{columns[0].Name}.Sanitize() + "::text"
+	}
+	cols := make([]string, 0, len(columns))
+	for _, c := range columns {
+		cols = append(cols, "t."+pgx.Identifier{c.Name}.Sanitize()+"::text")
+	}
+	return fmt.Sprintf("concat_ws('-', %s)", strings.Join(cols, ", "))
+}
+
+// keyCondition returns the condition that matches the rows with the keys in
+// the first query parameter. Single key columns are compared in their own
+// type, so that the column index can be used.
+func keyCondition(columns []schemalog.Column) string {
+	if len(columns) == 1 {
+		c := columns[0]
+		return fmt.Sprintf("t.%s = ANY($1::text[]::%s[])", pgx.Identifier{c.Name}.Sanitize(), c.DataType)
+	}
+	return fmt.Sprintf("%s = ANY($1::text[])", keyExpression(columns))
+}
+
+// rowExpression returns the JSON object expression of the row with the
+// columns on input, or with all its columns if none are provided.
+func rowExpression(columns []string) string {
+	if len(columns) == 0 {
+		return "to_jsonb(t)"
+	}
+	fields := make([]string, 0, len(columns))
+	for _, c := range columns {
+		fields = append(fields, fmt.Sprintf("%s, t.%s", quoteLiteral(c), pgx.Identifier{c}.Sanitize()))
+	}
+	return fmt.Sprintf("jsonb_build_object(%s)", strings.Join(fields, ", "))
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+)
+
+func TestDocumentIDColumns(t *testing.T) {
+	t.Parallel()
+
+	idCol := schemalog.Column{Name: "id", DataType: "integer"}
+	tenantCol := schemalog.Column{Name: "tenant", DataType: "text"}
+	emailCol := schemalog.Column{Name: "email", DataType: "text", Unique: true}
+
+	tests := []struct {
+		name  string
+		table *schemalog.Table
+
+		wantColumns []schemalog.Column
+	}{
+		{
+			name: "composite primary key in column order",
+			table: &schemalog.Table{
+				Columns:           []schemalog.Column{tenantCol, idCol, emailCol},
+				PrimaryKeyColumns: []string{"id", "tenant"},
+			},
+			wantColumns: []schemalog.Column{tenantCol, idCol},
+		},
+		{
+			name: "unique not null column",
+			table: &schemalog.Table{
+				Columns: []schemalog.Column{tenantCol, emailCol},
+			},
+			wantColumns: []schemalog.Column{emailCol},
+		},
+		{
+			name: "no id columns",
+			table: &schemalog.Table{
+				Columns: []schemalog.Column{tenantCol},
+			},
+			wantColumns: []schemalog.Column{},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantColumns, documentIDColumns(tc.table))
+		})
+	}
+}
+
+func TestQueryExpressions(t *testing.T) {
+	t.Parallel()
+
+	idCol := schemalog.Column{Name: "id", DataType: "uuid"}
+	tenantCol := schemalog.Column{Name: "tenant", DataType: "text"}
+
+	require.Equal(t, `t."id"::text`, keyExpression([]schemalog.Column{idCol}))
+	require.Equal(t, `t."id" = ANY($1::text[]::uuid[])`, keyCondition([]schemalog.Column{idCol}))
+
+	require.Equal(t, `concat_ws('-', t."tenant"::text, t."id"::text)`, keyExpression([]schemalog.Column{tenantCol, idCol}))
+	require.Equal(t, `concat_ws('-', t."tenant"::text, t."id"::text) = ANY($1::text[])`, keyCondition([]schemalog.Column{tenantCol, idCol}))
+
+	require.Equal(t, `to_jsonb(t)`, rowExpression(nil))
+	require.Equal(t, `jsonb_build_object('name', t."name", 'it''s', t."it's")`, rowExpression([]string{"name", "it's"}))
+}
+
+func TestTableColumns(t *testing.T) {
+	t.Parallel()
+
+	table := &schemalog.Table{
+		Name: "orders",
+		Columns: []schemalog.Column{
+			{Name: "id", DataType: "integer"},
+			{Name: "customer_id", DataType: "integer"},
+		},
+	}
+
+	columns, err := tableColumns(table, []string{"customer_id", "id"})
+	require.NoError(t, err)
+	require.Equal(t, []schemalog.Column{table.Columns[1], table.Columns[0]}, columns)
+
+	_, err = tableColumns(table, []string{"tenant"})
+	require.ErrorIs(t, err, errColumnNotFound)
+}
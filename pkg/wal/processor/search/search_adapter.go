@@ -82,11 +82,13 @@ func (a *adapter) walEventToMsg(e *wal.Event) (*msg, error) {
 			write:     doc,
 			bytesSize: size,
 			pos:       e.CommitPosition,
+			lsn:       e.Data.LSN,
 		}, nil
 
 	case "T":
 		truncateItem := &truncateItem{
 			schemaName: e.Data.Schema,
+			tableName:  e.Data.Table,
 			tableID:    e.Data.Metadata.TablePgstreamID,
 		}
 		return &msg{
@@ -153,6 +155,7 @@ func (a *adapter) walDataToDocument(data *wal.Data) (*Document, error) {
 					Name:       col.Name,
 					DataType:   col.Type,
 					PgstreamID: col.ID,
+					Enum:       data.Metadata.IsEnumColumn(col.ID),
 				}, col.Value)
 				if err != nil {
 					// we do not map unsupported types
@@ -198,6 +201,7 @@ func (a *adapter) parseColumns(columns []wal.Column, metadata wal.Metadata, lsnS
 				Name:       col.Name,
 				DataType:   col.Type,
 				PgstreamID: col.ID,
+				Enum:       metadata.IsEnumColumn(col.ID),
 			}, col.Value)
 			if err != nil {
 				// we do not map unsupported types
@@ -92,6 +92,7 @@ func TestAdapter_walEventToMsg(t *testing.T) {
 			wantMsg: &msg{
 				truncate: &truncateItem{
 					schemaName: testSchemaName,
+					tableName:  testTableName,
 					tableID:    testTableID,
 				},
 				bytesSize: len(testSchemaName) + len(testTableID),
@@ -497,6 +498,34 @@ func TestAdapter_parseColumns(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name:    "ok - enum column",
+			columns: testColumns,
+			metadata: wal.Metadata{
+				TablePgstreamID:    testTableID,
+				InternalColIDs:     []string{"col-1"},
+				InternalColVersion: "col-2",
+				EnumColIDs:         []string{"col-3"},
+			},
+			mapper: &searchmocks.Mapper{
+				MapColumnValueFn: func(column schemalog.Column, value any) (any, error) {
+					if !column.Enum {
+						return nil, ErrTypeInvalid{}
+					}
+					return value, nil
+				},
+			},
+
+			wantDoc: &Document{
+				ID:      fmt.Sprintf("%s_id-1", testTableID),
+				Version: 0,
+				Data: map[string]any{
+					"col-3":  "a",
+					"_table": testTableID,
+				},
+			},
+			wantErr: nil,
+		},
 		{
 			name: "ok - version not found, default to use lsn",
 			columns: []wal.Column{
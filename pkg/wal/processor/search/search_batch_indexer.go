@@ -16,6 +16,8 @@ import (
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor"
 	"github.com/xataio/pgstream/pkg/wal/replication"
+
+	"go.opentelemetry.io/otel/metric"
 )
 
 // BatchIndexer is the environment for ingesting the WAL logical
@@ -33,6 +35,7 @@ type BatchIndexer struct {
 
 	batchSize         int
 	batchSendInterval time.Duration
+	bulkConcurrency   int
 
 	skipSchema func(schemaName string) bool
 
@@ -40,6 +43,24 @@ type BatchIndexer struct {
 	checkpoint checkpointer.Checkpoint
 
 	cleaner cleaner
+
+	// deadLetter keeps the documents that can't be indexed, if set
+	deadLetter        DeadLetterStore
+	deadLetterIgnored bool
+
+	// denormaliser embeds the related rows in the documents, if set
+	denormaliser *denormaliser
+
+	// softDeleter turns the deletes of the configured tables into soft
+	// deletes, if set
+	softDeleter *softDeleter
+
+	metrics *indexerMetrics
+}
+
+type indexerMetrics struct {
+	documentsSent      metric.Int64Counter
+	documentsCoalesced metric.Int64Counter
 }
 
 type Option func(*BatchIndexer)
@@ -54,8 +75,10 @@ func NewBatchIndexer(ctx context.Context, config IndexerConfig, store Store, lsn
 		skipSchema:        func(string) bool { return false },
 		batchSize:         config.batchSize(),
 		batchSendInterval: config.batchTime(),
+		bulkConcurrency:   config.bulkConcurrency(),
 		adapter:           newAdapter(store.GetMapper(), lsnParser),
 		msgChan:           make(chan *msg),
+		deadLetterIgnored: config.DeadLetterIgnored,
 	}
 
 	// this allows us to bound and configure the memory used by the internal msg
@@ -70,6 +93,13 @@ func NewBatchIndexer(ctx context.Context, config IndexerConfig, store Store, lsn
 	// start a goroutine for processing schema deletes asynchronously.
 	// routine ends when the internal channel is closed.
 	go indexer.cleaner.start(ctx)
+
+	if len(config.SoftDelete.Tables) > 0 {
+		indexer.softDeleter = newSoftDeleter(&config.SoftDelete, store, indexer.logger)
+		// start a goroutine for purging the soft deleted documents
+		// periodically. Routine ends when the indexer is closed.
+		go indexer.softDeleter.start(ctx)
+	}
 	return indexer
 }
 
@@ -81,6 +111,33 @@ func WithLogger(l loglib.Logger) Option {
 	}
 }
 
+// WithDeadLetterStore sets the store where the documents that can't be indexed
+// are sent. By default they are only logged.
+func WithDeadLetterStore(s DeadLetterStore) Option {
+	return func(i *BatchIndexer) {
+		i.deadLetter = s
+	}
+}
+
+// WithDenormalisation embeds the parent rows of the relations on input in the
+// child table documents, reading them with the related row reader.
+func WithDenormalisation(cfg *DenormalisationConfig, reader RelatedRowReader) Option {
+	return func(i *BatchIndexer) {
+		i.denormaliser = newDenormaliser(cfg, reader, i.store, i.logger)
+	}
+}
+
+func WithInstrumentation(meter metric.Meter) Option {
+	return func(i *BatchIndexer) {
+		metrics, err := newIndexerMetrics(meter)
+		if err != nil {
+			i.logger.Error(err, "initialising search batch indexer instrumentation")
+			return
+		}
+		i.metrics = metrics
+	}
+}
+
 func WithCheckpoint(c checkpointer.Checkpoint) Option {
 	return func(i *BatchIndexer) {
 		i.checkpoint = c
@@ -120,6 +177,15 @@ func (i *BatchIndexer) ProcessWALEvent(ctx context.Context, event *wal.Event) (e
 		return nil
 	}
 
+	if i.softDeleter != nil && msg.write != nil && msg.write.Delete &&
+		i.softDeleter.enabled(event.Data.Schema, event.Data.Table) {
+		i.softDeleter.markDeleted(msg.write, event.Data)
+	}
+
+	if i.denormaliser != nil && msg.write != nil {
+		i.denormaliser.addRelatedKeys(msg, event.Data)
+	}
+
 	// make sure we don't reach the queue memory limit before adding the new
 	// message to the channel. This will block until messages have been read
 	// from the channel and their size is released
@@ -144,15 +210,10 @@ func (i *BatchIndexer) Send(ctx context.Context) error {
 	sendErrChan := make(chan error, 1)
 	go func() {
 		defer close(sendErrChan)
-		for batch := range batchChan {
-			// If the send fails, this goroutine returns an error over the error channel and shuts down.
-			err := i.sendBatch(ctx, batch)
-			i.queueBytesSema.Release(int64(batch.totalBytes))
-			if err != nil {
-				i.logger.Error(err, "search batch indexer")
-				sendErrChan <- err
-				return
-			}
+		// If the send fails, this goroutine returns an error over the error channel and shuts down.
+		if err := i.sendBatches(ctx, batchChan); err != nil {
+			i.logger.Error(err, "search batch indexer")
+			sendErrChan <- err
 		}
 	}()
 
@@ -192,6 +253,26 @@ func (i *BatchIndexer) Name() string {
 func (i *BatchIndexer) Close() error {
 	close(i.msgChan)
 	i.cleaner.stop()
+	if i.softDeleter != nil {
+		i.softDeleter.stop()
+	}
+	return nil
+}
+
+// sendBatches sends the batches on input to the search store until the channel
+// is closed, one at a time unless concurrent bulk requests are configured.
+func (i *BatchIndexer) sendBatches(ctx context.Context, batchChan <-chan *msgBatch) error {
+	if i.bulkConcurrency > 1 {
+		return newConcurrentSender(i, i.bulkConcurrency).send(ctx, batchChan)
+	}
+
+	for batch := range batchChan {
+		err := i.sendBatch(ctx, batch)
+		i.queueBytesSema.Release(int64(batch.totalBytes))
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -200,62 +281,236 @@ func (i *BatchIndexer) sendBatch(ctx context.Context, batch *msgBatch) error {
 		return nil
 	}
 
+	complete, err := i.processBatch(ctx, batch, &serialWriter{indexer: i})
+	if err != nil || !complete {
+		return err
+	}
+
+	if i.checkpoint != nil {
+		if err := i.checkpoint(ctx, batch.positions); err != nil {
+			return fmt.Errorf("checkpointing positions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// batchWriter sends the document writes of a batch to the search store.
+type batchWriter interface {
+	// write sends the documents on input, along with the LSNs of their writes.
+	// It can return before they have been sent.
+	write(ctx context.Context, docs []Document, lsns []string) error
+	// wait waits for all the documents written so far to be sent, returning
+	// the first error, if any.
+	wait() error
+}
+
+// processBatch sends the batch messages to the search store using the writer
+// on input. The writes are coalesced and flushed before the schema changes and
+// truncates, which are applied once all the writes before them have been sent.
+// It returns false if the batch can't be checkpointed, since a schema change
+// failed and the rest of the batch was dropped.
+func (i *BatchIndexer) processBatch(ctx context.Context, batch *msgBatch, writer batchWriter) (bool, error) {
+	if err := i.embedParents(ctx, batch.msgs); err != nil {
+		return false, err
+	}
+
 	// we'll mostly process writes, so pre-allocate the "max" amount
 	writes := make([]Document, 0, len(batch.msgs))
+	lsns := make([]string, 0, len(batch.msgs))
 	flushWrites := func() error {
 		if len(writes) > 0 {
-			failed, err := i.store.SendDocuments(ctx, writes)
-			if err != nil {
+			// the writes are flushed before schema changes and truncates, so
+			// they are only coalesced between them
+			docs, docLSNs, coalesced := coalesceWrites(writes, lsns)
+			i.recordWrites(ctx, len(docs), coalesced)
+			if err := writer.write(ctx, docs, docLSNs); err != nil {
 				return err
 			}
-			if len(failed) > 0 {
-				i.logger.Error(nil, "failed to send documents", loglib.Fields{
-					"failed_documents": failed,
-				})
-			}
 			writes = writes[:0]
+			lsns = lsns[:0]
 		}
 		return nil
 	}
+	// barrier waits for all the writes before it to be sent
+	barrier := func() error {
+		if err := flushWrites(); err != nil {
+			return err
+		}
+		return writer.wait()
+	}
 
 	for _, msg := range batch.msgs {
 		switch {
 		case msg.write != nil:
 			writes = append(writes, *msg.write)
+			lsns = append(lsns, msg.lsn)
 		case msg.schemaChange != nil:
-			if err := flushWrites(); err != nil {
-				return err
+			if err := barrier(); err != nil {
+				return false, err
 			}
 			if err := i.applySchemaChange(ctx, msg.schemaChange); err != nil {
 				i.logDataLoss(msg.schemaChange, err)
-				return nil
+				return false, nil
 			}
 		case msg.truncate != nil:
-			if err := flushWrites(); err != nil {
-				return err
+			if err := barrier(); err != nil {
+				return false, err
 			}
 			if err := i.truncateTable(ctx, msg.truncate); err != nil {
-				return err
+				return false, err
 			}
 		default:
-			return errEmptyQueueMsg
+			return false, errEmptyQueueMsg
 		}
 	}
 
 	if err := flushWrites(); err != nil {
+		return false, err
+	}
+
+	// the child documents are updated once the writes before them have been
+	// sent, so that they're not overwritten
+	if hasChildren(batch.msgs) {
+		if err := barrier(); err != nil {
+			return false, err
+		}
+		if err := i.updateChildren(ctx, batch.msgs); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// serialWriter sends the batch writes one bulk request at a time.
+type serialWriter struct {
+	indexer *BatchIndexer
+}
+
+func (w *serialWriter) write(ctx context.Context, docs []Document, lsns []string) error {
+	return w.indexer.sendDocuments(ctx, docs, lsns)
+}
+
+func (w *serialWriter) wait() error {
+	return nil
+}
+
+// sendDocuments sends the documents on input to the search store, along with
+// the LSNs of their writes. The documents that fail are sent to the dead
+// letter store, if configured.
+func (i *BatchIndexer) sendDocuments(ctx context.Context, docs []Document, lsns []string) error {
+	failed, err := i.store.SendDocuments(ctx, docs)
+	if err != nil {
 		return err
 	}
+	if len(failed) > 0 {
+		i.logFailedDocuments(failed)
+		if err := i.sendToDeadLetter(ctx, docs, lsns, failed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if i.checkpoint != nil {
-		if err := i.checkpoint(ctx, batch.positions); err != nil {
-			return fmt.Errorf("checkpointing positions: %w", err)
+// logFailedDocuments logs the documents that failed to be sent. The ignored
+// ones, such as out of order writes, are expected and only logged at debug
+// level.
+func (i *BatchIndexer) logFailedDocuments(failed []DocumentError) {
+	errored := []DocumentError{}
+	ignored := []DocumentError{}
+	for _, f := range failed {
+		switch f.Severity {
+		case SeverityNone, SeverityIgnored:
+			ignored = append(ignored, f)
+		default:
+			errored = append(errored, f)
 		}
 	}
 
+	if len(errored) > 0 {
+		i.logger.Error(nil, "failed to send documents", loglib.Fields{
+			"failed_documents": errored,
+		})
+	}
+	if len(ignored) > 0 {
+		i.logger.Debug("ignored documents", loglib.Fields{
+			"ignored_documents": ignored,
+		})
+	}
+}
+
+// sendToDeadLetter writes the failed documents to the dead letter store, if
+// configured. The original documents are used, since the failed ones are
+// rebuilt by the store and may not keep all their fields. If the write fails,
+// the error is returned so that the batch is not checkpointed.
+func (i *BatchIndexer) sendToDeadLetter(ctx context.Context, writes []Document, lsns []string, failed []DocumentError) error {
+	if i.deadLetter == nil {
+		return nil
+	}
+
+	type write struct {
+		doc Document
+		lsn string
+	}
+	writesByKey := make(map[string]write, len(writes))
+	for idx, doc := range writes {
+		writesByKey[documentKey(doc)] = write{doc: doc, lsn: lsns[idx]}
+	}
+
+	entries := make([]DeadLetterEntry, 0, len(failed))
+	for _, f := range failed {
+		switch f.Severity {
+		case SeverityNone:
+			continue
+		case SeverityIgnored:
+			if !i.deadLetterIgnored {
+				continue
+			}
+		}
+		lsn := ""
+		if w, found := writesByKey[documentKey(f.Document)]; found {
+			f.Document = w.doc
+			lsn = w.lsn
+		}
+		entries = append(entries, NewDeadLetterEntry(f, lsn))
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if err := i.deadLetter.Put(ctx, entries); err != nil {
+		return fmt.Errorf("writing documents to dead letter store: %w", err)
+	}
+	i.logger.Warn(nil, "search batch indexer: documents sent to dead letter store", loglib.Fields{
+		"docs_dead_lettered": len(entries),
+	})
 	return nil
 }
 
+// embedParents embeds the related parent rows in the documents of the batch
+// writes, if denormalisation is configured.
+func (i *BatchIndexer) embedParents(ctx context.Context, msgs []*msg) error {
+	if i.denormaliser == nil {
+		return nil
+	}
+	return i.denormaliser.embedParents(ctx, msgs)
+}
+
+// updateChildren updates the documents that embed the rows of the batch
+// writes, if denormalisation is configured. It must be called once the batch
+// writes have been sent, so that they don't overwrite the updates.
+func (i *BatchIndexer) updateChildren(ctx context.Context, msgs []*msg) error {
+	if i.denormaliser == nil {
+		return nil
+	}
+	return i.denormaliser.updateChildren(ctx, msgs)
+}
+
 func (i *BatchIndexer) truncateTable(ctx context.Context, item *truncateItem) error {
+	if i.softDeleter != nil && i.softDeleter.enabled(item.schemaName, item.tableName) {
+		return i.softDeleter.truncateTable(ctx, item)
+	}
 	return i.store.DeleteTableDocuments(ctx, item.schemaName, []string{item.tableID})
 }
 
@@ -286,9 +541,23 @@ func (i *BatchIndexer) applySchemaChange(ctx context.Context, new *schemalog.Log
 		return fmt.Errorf("applying schema change: %w", err)
 	}
 
+	// the schema change can start an index migration, which is completed
+	// asynchronously so that it doesn't block the processing of events
+	if err := i.cleaner.completeSchemaMigration(ctx, new.SchemaName); err != nil {
+		i.logger.Warn(err, "search batch indexer: register schema migration completion", loglib.Fields{"schema_name": new.SchemaName})
+	}
+
 	return nil
 }
 
+func (i *BatchIndexer) recordWrites(ctx context.Context, sent, coalesced int) {
+	if i.metrics == nil {
+		return
+	}
+	i.metrics.documentsSent.Add(ctx, int64(sent))
+	i.metrics.documentsCoalesced.Add(ctx, int64(coalesced))
+}
+
 func (i *BatchIndexer) logDataLoss(logEntry *schemalog.LogEntry, err error) {
 	i.logger.Error(err, "search batch indexer", loglib.Fields{
 		"severity": "DATALOSS",
@@ -299,3 +568,23 @@ func (i *BatchIndexer) logDataLoss(logEntry *schemalog.LogEntry, err error) {
 		},
 	})
 }
+
+func newIndexerMetrics(meter metric.Meter) (*indexerMetrics, error) {
+	metrics := &indexerMetrics{}
+	var err error
+	metrics.documentsSent, err = meter.Int64Counter("pgstream.search.indexer.documents.sent",
+		metric.WithUnit("documents"),
+		metric.WithDescription("Number of document writes sent to the search store by the search batch indexer"))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.documentsCoalesced, err = meter.Int64Counter("pgstream.search.indexer.documents.coalesced",
+		metric.WithUnit("documents"),
+		metric.WithDescription("Number of document writes not sent to the search store by the search batch indexer, because a newer version of the document was in the same batch"))
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
@@ -346,6 +346,9 @@ func TestBatchIndexer_Send(t *testing.T) {
 				queueBytesSema: &syncmocks.WeightedSemaphore{
 					ReleaseFn: func(_ uint64, _ int64) {},
 				},
+				cleaner: &mockCleaner{
+					completeSchemaMigrationFn: func(context.Context, string) error { return nil },
+				},
 			}
 
 			if tc.semaphore != nil {
@@ -396,12 +399,14 @@ func TestBatchIndexer_sendBatch(t *testing.T) {
 	testDocument2 := newTestDocument(withID("2"))
 
 	tests := []struct {
-		name       string
-		store      Store
-		checkpoint checkpointer.Checkpoint
-		batch      *msgBatch
-		skipSchema func(string) bool
-		cleaner    cleaner
+		name        string
+		store       Store
+		checkpoint  checkpointer.Checkpoint
+		batch       *msgBatch
+		skipSchema  func(string) bool
+		cleaner     cleaner
+		deadLetter  DeadLetterStore
+		softDeleter *softDeleter
 
 		wantErr error
 	}{
@@ -411,6 +416,64 @@ func TestBatchIndexer_sendBatch(t *testing.T) {
 
 			wantErr: nil,
 		},
+		{
+			name: "ok - failed documents sent to dead letter store",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{write: testDocument1, lsn: "0/1"},
+					{write: testDocument2, lsn: "0/2"},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+					return []DocumentError{
+						// the store failures don't keep all the document fields
+						{Document: Document{ID: "1"}, Severity: SeverityDataLoss, Error: errTest.Error()},
+						{Document: Document{ID: "2"}, Severity: SeverityIgnored, Error: errTest.Error()},
+					}, nil
+				},
+			},
+			deadLetter: &mockDeadLetterStore{
+				putFn: func(ctx context.Context, entries []DeadLetterEntry) error {
+					require.Len(t, entries, 1)
+					require.NotEmpty(t, entries[0].ID)
+					require.Equal(t, *testDocument1, entries[0].Document)
+					require.Equal(t, "DATALOSS", entries[0].Severity)
+					require.Equal(t, errTest.Error(), entries[0].Error)
+					require.Equal(t, "0/1", entries[0].LSN)
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - sending to dead letter store",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{write: testDocument1, lsn: "0/1"},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+					return []DocumentError{
+						{Document: Document{ID: "1"}, Severity: SeverityDataLoss, Error: errTest.Error()},
+					}, nil
+				},
+			},
+			checkpoint: func(ctx context.Context, positions []wal.CommitPosition) error {
+				return errors.New("checkpoint: should not be called")
+			},
+			deadLetter: &mockDeadLetterStore{
+				putFn: func(ctx context.Context, entries []DeadLetterEntry) error {
+					return errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
 		{
 			name: "ok - write only batch",
 			batch: &msgBatch{
@@ -429,6 +492,132 @@ func TestBatchIndexer_sendBatch(t *testing.T) {
 
 			wantErr: nil,
 		},
+		{
+			name: "ok - writes to the same document coalesced",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{write: newTestDocument(withID("1"), withVersion(1))},
+					{write: newTestDocument(withID("2"), withVersion(2))},
+					{write: newTestDocument(withID("1"), withVersion(3))},
+					{write: newTestDocument(withID("2"), withVersion(4), withDelete())},
+					{write: newTestDocument(withID("1"), withVersion(5))},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+					require.Equal(t, []Document{
+						*newTestDocument(withID("1"), withVersion(5)),
+						*newTestDocument(withID("2"), withVersion(4), withDelete()),
+					}, docs)
+					return nil, nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - soft delete merged into the previous write",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{write: newTestDocument(withID("1"), withVersion(1))},
+					{write: newTestDocument(withID("1"), withVersion(2), withDelete(), withSoftDelete(now))},
+					{write: newTestDocument(withID("2"), withVersion(2), withDelete(), withSoftDelete(now))},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+					wantDoc := newTestDocument(withID("1"), withVersion(2))
+					wantDoc.Data[DeletedField] = true
+					wantDoc.Data[DeletedAtField] = now.UTC().Format(deletedAtFormat)
+					require.Equal(t, []Document{
+						*wantDoc,
+						*newTestDocument(withID("2"), withVersion(2), withDelete(), withSoftDelete(now)),
+					}, docs)
+					return nil, nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - soft deleted truncate",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{truncate: &truncateItem{schemaName: testSchemaName, tableName: testTableName, tableID: testTableID}},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				softDeleteTableDocsFn: func(ctx context.Context, schemaName string, tableIDs []string, deletedAt time.Time) error {
+					require.Equal(t, testSchemaName, schemaName)
+					require.Equal(t, []string{testTableID}, tableIDs)
+					require.Equal(t, now, deletedAt)
+					return nil
+				},
+			},
+			softDeleter: &softDeleter{
+				tables: map[string]map[string]struct{}{testSchemaName: nil},
+				clock:  func() time.Time { return now },
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - writes not coalesced across schema changes",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{write: newTestDocument(withID("1"), withVersion(1))},
+					{schemaChange: testLogEntry},
+					{write: newTestDocument(withID("1"), withVersion(2))},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, i uint, docs []Document) ([]DocumentError, error) {
+					require.Equal(t, []Document{*newTestDocument(withID("1"), withVersion(int(i)))}, docs)
+					return nil, nil
+				},
+				applySchemaChangeFn: func(ctx context.Context, le *schemalog.LogEntry) error {
+					return nil
+				},
+			},
+			cleaner: &mockCleaner{
+				completeSchemaMigrationFn: func(ctx context.Context, s string) error {
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - coalesced document sent to dead letter store with its lsn",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{write: newTestDocument(withID("1"), withVersion(1)), lsn: "0/1"},
+					{write: newTestDocument(withID("1"), withVersion(2)), lsn: "0/2"},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+					return []DocumentError{
+						{Document: Document{ID: "1", Version: 2}, Severity: SeverityDataLoss, Error: errTest.Error()},
+					}, nil
+				},
+			},
+			deadLetter: &mockDeadLetterStore{
+				putFn: func(ctx context.Context, entries []DeadLetterEntry) error {
+					require.Len(t, entries, 1)
+					require.Equal(t, *newTestDocument(withID("1"), withVersion(2)), entries[0].Document)
+					require.Equal(t, "0/2", entries[0].LSN)
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
 		{
 			name: "ok - write and schema change batch",
 			batch: &msgBatch{
@@ -456,6 +645,33 @@ func TestBatchIndexer_sendBatch(t *testing.T) {
 					return nil
 				},
 			},
+			cleaner: &mockCleaner{
+				completeSchemaMigrationFn: func(ctx context.Context, s string) error {
+					require.Equal(t, testSchemaName, s)
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - error registering schema migration completion",
+			batch: &msgBatch{
+				msgs: []*msg{
+					{schemaChange: testLogEntry},
+				},
+				positions: []wal.CommitPosition{testCommitPos},
+			},
+			store: &mockStore{
+				applySchemaChangeFn: func(ctx context.Context, le *schemalog.LogEntry) error {
+					return nil
+				},
+			},
+			cleaner: &mockCleaner{
+				completeSchemaMigrationFn: func(ctx context.Context, s string) error {
+					return errTest
+				},
+			},
 
 			wantErr: nil,
 		},
@@ -674,6 +890,7 @@ func TestBatchIndexer_sendBatch(t *testing.T) {
 				store:      tc.store,
 				skipSchema: func(schemaName string) bool { return false },
 				checkpoint: tc.checkpoint,
+				deadLetter: tc.deadLetter,
 			}
 
 			if tc.skipSchema != nil {
@@ -684,6 +901,11 @@ func TestBatchIndexer_sendBatch(t *testing.T) {
 				indexer.cleaner = tc.cleaner
 			}
 
+			if tc.softDeleter != nil {
+				tc.softDeleter.store = tc.store
+				indexer.softDeleter = tc.softDeleter
+			}
+
 			err := indexer.sendBatch(context.Background(), tc.batch)
 			require.ErrorIs(t, err, tc.wantErr)
 		})
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// concurrentSender sends the batch writes to the search store using multiple
+// concurrent bulk requests. The documents are partitioned across the workers
+// by id, so that the writes to the same document are always sent in order by
+// the same worker. Schema changes and truncates act as barriers, and are only
+// applied once all the writes before them have been sent. The batch positions
+// are checkpointed once the batch and all the batches before it have been
+// sent.
+type concurrentSender struct {
+	indexer *BatchIndexer
+	workers []chan *bulkTask
+
+	workersWg sync.WaitGroup
+	// inflight tracks the tasks dispatched to the workers that have not
+	// completed yet
+	inflight sync.WaitGroup
+
+	mu sync.Mutex
+	// pending keeps the batches that have not been checkpointed yet, in the
+	// order they were received
+	pending []*pendingBatch
+
+	failOnce sync.Once
+	failed   chan struct{}
+	err      error
+}
+
+type pendingBatch struct {
+	positions []wal.CommitPosition
+	bytes     int
+	// tasks is the number of tasks of the batch that have not completed yet,
+	// including its dispatch
+	tasks int
+}
+
+type bulkTask struct {
+	docs  []Document
+	lsns  []string
+	batch *pendingBatch
+}
+
+const workerQueueSize = 8
+
+func newConcurrentSender(indexer *BatchIndexer, concurrency int) *concurrentSender {
+	s := &concurrentSender{
+		indexer: indexer,
+		workers: make([]chan *bulkTask, concurrency),
+		failed:  make(chan struct{}),
+	}
+	for idx := range s.workers {
+		s.workers[idx] = make(chan *bulkTask, workerQueueSize)
+	}
+	return s
+}
+
+// send dispatches the batches on input to the workers until the channel is
+// closed or a send fails, in which case the first error is returned.
+func (s *concurrentSender) send(ctx context.Context, batchChan <-chan *msgBatch) error {
+	for _, tasks := range s.workers {
+		s.workersWg.Add(1)
+		go s.work(ctx, tasks)
+	}
+	defer func() {
+		for _, tasks := range s.workers {
+			close(tasks)
+		}
+		s.workersWg.Wait()
+	}()
+
+	for {
+		select {
+		case <-s.failed:
+			return s.err
+		case batch, ok := <-batchChan:
+			if !ok {
+				s.inflight.Wait()
+				return s.failure()
+			}
+			if err := s.dispatch(ctx, batch); err != nil {
+				s.fail(err)
+				return s.failure()
+			}
+		}
+	}
+}
+
+func (s *concurrentSender) dispatch(ctx context.Context, batch *msgBatch) error {
+	if batch.isEmpty() {
+		return nil
+	}
+
+	pb := &pendingBatch{
+		positions: batch.positions,
+		bytes:     batch.totalBytes,
+		tasks:     1,
+	}
+	s.mu.Lock()
+	s.pending = append(s.pending, pb)
+	s.mu.Unlock()
+
+	complete, err := s.indexer.processBatch(ctx, batch, &concurrentWriter{sender: s, batch: pb})
+	if err != nil {
+		return err
+	}
+	if !complete {
+		// same as when sending batches sequentially, the batch positions are
+		// not checkpointed
+		s.mu.Lock()
+		pb.positions = nil
+		s.mu.Unlock()
+	}
+
+	// the dispatch of the batch is complete
+	s.done(ctx, pb)
+	return nil
+}
+
+// concurrentWriter dispatches the batch writes to the sender workers.
+type concurrentWriter struct {
+	sender *concurrentSender
+	batch  *pendingBatch
+}
+
+func (w *concurrentWriter) write(ctx context.Context, docs []Document, lsns []string) error {
+	s := w.sender
+	for worker, task := range s.partition(docs, lsns, w.batch) {
+		if task == nil {
+			continue
+		}
+		s.mu.Lock()
+		w.batch.tasks++
+		s.mu.Unlock()
+		s.inflight.Add(1)
+		select {
+		case s.workers[worker] <- task:
+		case <-ctx.Done():
+			s.inflight.Done()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (w *concurrentWriter) wait() error {
+	w.sender.inflight.Wait()
+	return w.sender.failure()
+}
+
+// partition splits the documents by worker, based on the hash of their id. It
+// returns a task per worker, nil if the worker has no documents.
+func (s *concurrentSender) partition(docs []Document, lsns []string, batch *pendingBatch) []*bulkTask {
+	tasks := make([]*bulkTask, len(s.workers))
+	for idx, doc := range docs {
+		h := fnv.New32a()
+		h.Write([]byte(doc.Schema))
+		h.Write([]byte(doc.ID))
+		worker := int(h.Sum32() % uint32(len(s.workers)))
+		if tasks[worker] == nil {
+			tasks[worker] = &bulkTask{batch: batch}
+		}
+		tasks[worker].docs = append(tasks[worker].docs, doc)
+		tasks[worker].lsns = append(tasks[worker].lsns, lsns[idx])
+	}
+	return tasks
+}
+
+func (s *concurrentSender) work(ctx context.Context, tasks <-chan *bulkTask) {
+	defer s.workersWg.Done()
+	for task := range tasks {
+		// once a send has failed, the remaining tasks are drained without
+		// being sent, since their batches will not be checkpointed
+		if s.failure() == nil {
+			if err := s.indexer.sendDocuments(ctx, task.docs, task.lsns); err != nil {
+				s.fail(err)
+			} else {
+				s.done(ctx, task.batch)
+			}
+		}
+		s.inflight.Done()
+	}
+}
+
+// done marks a task of the batch as completed. Once all the tasks of the batch
+// are completed, its queue bytes are released, and the positions of the
+// completed batches at the head of the pending queue are checkpointed.
+func (s *concurrentSender) done(ctx context.Context, batch *pendingBatch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch.tasks--
+	if batch.tasks > 0 {
+		return
+	}
+	s.indexer.queueBytesSema.Release(int64(batch.bytes))
+
+	positions := []wal.CommitPosition{}
+	for len(s.pending) > 0 && s.pending[0].tasks == 0 {
+		positions = append(positions, s.pending[0].positions...)
+		s.pending = s.pending[1:]
+	}
+	if len(positions) == 0 || s.indexer.checkpoint == nil {
+		return
+	}
+
+	// the checkpoint is done while holding the lock to guarantee the
+	// positions are checkpointed in order
+	if err := s.indexer.checkpoint(ctx, positions); err != nil {
+		s.fail(fmt.Errorf("checkpointing positions: %w", err))
+	}
+}
+
+func (s *concurrentSender) fail(err error) {
+	s.failOnce.Do(func() {
+		s.err = err
+		close(s.failed)
+	})
+}
+
+// failure returns the first error of the sender, if any.
+func (s *concurrentSender) failure() error {
+	select {
+	case <-s.failed:
+		return s.err
+	default:
+		return nil
+	}
+}
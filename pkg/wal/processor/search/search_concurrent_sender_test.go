@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	syncmocks "github.com/xataio/pgstream/internal/sync/mocks"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestConcurrentSender_partition(t *testing.T) {
+	t.Parallel()
+
+	s := newConcurrentSender(&BatchIndexer{}, 4)
+
+	docs := []Document{}
+	lsns := []string{}
+	for idx := 0; idx < 20; idx++ {
+		docs = append(docs, *newTestDocument(withID(fmt.Sprintf("t1_%d", idx))))
+		lsns = append(lsns, fmt.Sprintf("0/%d", idx))
+	}
+	batch := &pendingBatch{}
+
+	tasks := s.partition(docs, lsns, batch)
+	require.Len(t, tasks, 4)
+
+	// the same document always goes to the same worker
+	workers := map[string]int{}
+	total := 0
+	for worker, task := range tasks {
+		if task == nil {
+			continue
+		}
+		require.Equal(t, batch, task.batch)
+		require.Len(t, task.lsns, len(task.docs))
+		for _, doc := range task.docs {
+			workers[doc.ID] = worker
+		}
+		total += len(task.docs)
+	}
+	require.Equal(t, len(docs), total)
+
+	for worker, task := range s.partition(docs, lsns, batch) {
+		if task == nil {
+			continue
+		}
+		for _, doc := range task.docs {
+			require.Equal(t, workers[doc.ID], worker)
+		}
+	}
+}
+
+func TestConcurrentSender_send(t *testing.T) {
+	t.Parallel()
+
+	pos1 := wal.CommitPosition("test_topic/0/1")
+	pos2 := wal.CommitPosition("test_topic/0/2")
+
+	// documentsByWorker returns two documents that are sent by different
+	// workers
+	documentsByWorker := func(s *concurrentSender) (*Document, *Document) {
+		first := newTestDocument(withID("t1_0"))
+		for idx := 1; ; idx++ {
+			doc := newTestDocument(withID(fmt.Sprintf("t1_%d", idx)))
+			tasks := s.partition([]Document{*first, *doc}, []string{"", ""}, nil)
+			if tasks[0] != nil && tasks[1] != nil {
+				return first, doc
+			}
+		}
+	}
+
+	newSemaphore := func() *syncmocks.WeightedSemaphore {
+		return &syncmocks.WeightedSemaphore{
+			ReleaseFn: func(_ uint64, i int64) {},
+		}
+	}
+
+	run := func(indexer *BatchIndexer, s *concurrentSender, batches ...*msgBatch) error {
+		batchChan := make(chan *msgBatch)
+		go func() {
+			defer close(batchChan)
+			for _, batch := range batches {
+				batchChan <- batch
+			}
+		}()
+		return s.send(context.Background(), batchChan)
+	}
+
+	t.Run("ok - positions checkpointed in order", func(t *testing.T) {
+		t.Parallel()
+
+		indexer := &BatchIndexer{
+			logger:         loglib.NewNoopLogger(),
+			queueBytesSema: newSemaphore(),
+		}
+		s := newConcurrentSender(indexer, 2)
+		doc1, doc2 := documentsByWorker(s)
+
+		// the first batch completes after the second one
+		doc2Sent := make(chan struct{})
+		indexer.store = &mockStore{
+			sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+				require.Len(t, docs, 1)
+				switch docs[0].ID {
+				case doc1.ID:
+					<-doc2Sent
+				case doc2.ID:
+					close(doc2Sent)
+				}
+				return nil, nil
+			},
+		}
+		checkpoints := [][]wal.CommitPosition{}
+		indexer.checkpoint = func(ctx context.Context, positions []wal.CommitPosition) error {
+			checkpoints = append(checkpoints, positions)
+			return nil
+		}
+
+		err := run(indexer, s,
+			&msgBatch{msgs: []*msg{{write: doc1}}, positions: []wal.CommitPosition{pos1}},
+			&msgBatch{msgs: []*msg{{write: doc2}}, positions: []wal.CommitPosition{pos2}},
+		)
+		require.NoError(t, err)
+		require.Equal(t, [][]wal.CommitPosition{{pos1, pos2}}, checkpoints)
+	})
+
+	t.Run("ok - truncate waits for previous writes", func(t *testing.T) {
+		t.Parallel()
+
+		indexer := &BatchIndexer{
+			logger:         loglib.NewNoopLogger(),
+			queueBytesSema: newSemaphore(),
+		}
+		s := newConcurrentSender(indexer, 2)
+		doc1, doc2 := documentsByWorker(s)
+
+		mu := sync.Mutex{}
+		sent := map[string]bool{}
+		indexer.store = &mockStore{
+			sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, doc := range docs {
+					sent[doc.ID] = true
+				}
+				return nil, nil
+			},
+			deleteTableDocumentsFn: func(ctx context.Context, schemaName string, tableIDs []string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				require.Equal(t, map[string]bool{doc1.ID: true, doc2.ID: true}, sent)
+				return nil
+			},
+		}
+
+		err := run(indexer, s, &msgBatch{
+			msgs: []*msg{
+				{write: doc1},
+				{write: doc2},
+				{truncate: &truncateItem{schemaName: testSchemaName, tableID: testTableID}},
+			},
+			positions: []wal.CommitPosition{pos1},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("error - sending documents", func(t *testing.T) {
+		t.Parallel()
+
+		indexer := &BatchIndexer{
+			logger:         loglib.NewNoopLogger(),
+			queueBytesSema: newSemaphore(),
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, _ uint, docs []Document) ([]DocumentError, error) {
+					return nil, errTest
+				},
+			},
+			checkpoint: func(ctx context.Context, positions []wal.CommitPosition) error {
+				return errors.New("checkpoint: should not be called")
+			},
+		}
+		s := newConcurrentSender(indexer, 2)
+
+		err := run(indexer, s, &msgBatch{
+			msgs:      []*msg{{write: newTestDocument()}},
+			positions: []wal.CommitPosition{pos1},
+		})
+		require.ErrorIs(t, err, errTest)
+	})
+}
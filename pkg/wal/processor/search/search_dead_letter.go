@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/xid"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+// DeadLetterStore persists the documents that couldn't be indexed, so that
+// they can be re-driven once the cause of the failure is fixed.
+type DeadLetterStore interface {
+	Put(ctx context.Context, entries []DeadLetterEntry) error
+	// List returns up to limit entries with an ID greater than afterID,
+	// ordered by ID. An empty afterID returns the first entries.
+	List(ctx context.Context, afterID string, limit int) ([]DeadLetterEntry, error)
+	Delete(ctx context.Context, ids []string) error
+	Close() error
+}
+
+// DeadLetterEntry is a document that couldn't be indexed, along with the
+// failure details.
+type DeadLetterEntry struct {
+	ID        string    `json:"id"`
+	Document  Document  `json:"document"`
+	Severity  string    `json:"severity"`
+	Error     string    `json:"error"`
+	LSN       string    `json:"lsn"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewDeadLetterEntry returns a dead letter entry for the document error on
+// input. The entry IDs are sortable by creation time.
+func NewDeadLetterEntry(docErr DocumentError, lsn string) DeadLetterEntry {
+	return DeadLetterEntry{
+		ID:        xid.New().String(),
+		Document:  docErr.Document,
+		Severity:  docErr.Severity.String(),
+		Error:     docErr.Error,
+		LSN:       lsn,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Redriver sends the dead lettered documents to the search store again.
+type Redriver struct {
+	deadLetter DeadLetterStore
+	store      Store
+	logger     loglib.Logger
+	batchSize  int
+}
+
+// RedriveResult summarises the outcome of a re-drive.
+type RedriveResult struct {
+	// Indexed is the number of documents successfully indexed.
+	Indexed int
+	// Superseded is the number of documents ignored by the store, because a
+	// newer version of the document has been indexed since they failed.
+	Superseded int
+	// Failed is the number of documents that failed again. They are kept in
+	// the dead letter store.
+	Failed int
+}
+
+type RedriverOption func(*Redriver)
+
+const defaultRedriveBatchSize = 100
+
+func NewRedriver(deadLetter DeadLetterStore, store Store, opts ...RedriverOption) *Redriver {
+	r := &Redriver{
+		deadLetter: deadLetter,
+		store:      store,
+		logger:     loglib.NewNoopLogger(),
+		batchSize:  defaultRedriveBatchSize,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func WithRedriverLogger(l loglib.Logger) RedriverOption {
+	return func(r *Redriver) {
+		r.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ServiceField: "search_dead_letter_redriver",
+		})
+	}
+}
+
+// WithRedriveBatchSize sets the number of dead letter entries sent to the
+// store at once. Defaults to 100.
+func WithRedriveBatchSize(size int) RedriverOption {
+	return func(r *Redriver) {
+		if size > 0 {
+			r.batchSize = size
+		}
+	}
+}
+
+// Redrive sends all the entries in the dead letter store to the search store,
+// removing the ones that no longer fail. The documents are sent in the order
+// they were dead lettered, and the document versions prevent them from
+// overwriting newer versions.
+func (r *Redriver) Redrive(ctx context.Context) (*RedriveResult, error) {
+	result := &RedriveResult{}
+	afterID := ""
+	for {
+		entries, err := r.deadLetter.List(ctx, afterID, r.batchSize)
+		if err != nil {
+			return result, fmt.Errorf("listing dead letter entries: %w", err)
+		}
+		if len(entries) == 0 {
+			return result, nil
+		}
+		afterID = entries[len(entries)-1].ID
+
+		if err := r.redriveEntries(ctx, entries, result); err != nil {
+			return result, err
+		}
+	}
+}
+
+func (r *Redriver) redriveEntries(ctx context.Context, entries []DeadLetterEntry, result *RedriveResult) error {
+	docs := make([]Document, 0, len(entries))
+	for _, e := range entries {
+		docs = append(docs, e.Document)
+	}
+
+	failed, err := r.store.SendDocuments(ctx, docs)
+	if err != nil {
+		return fmt.Errorf("sending dead letter documents: %w", err)
+	}
+
+	failures := make(map[string]DocumentError, len(failed))
+	for _, f := range failed {
+		failures[documentKey(f.Document)] = f
+	}
+
+	resolved := make([]string, 0, len(entries))
+	for _, e := range entries {
+		f, found := failures[documentKey(e.Document)]
+		switch {
+		case !found || f.Severity == SeverityNone:
+			result.Indexed++
+		case f.Severity == SeverityIgnored:
+			result.Superseded++
+		default:
+			result.Failed++
+			r.logger.Warn(nil, "dead letter document failed again", loglib.Fields{
+				"dead_letter_id": e.ID,
+				"schema":         e.Document.Schema,
+				"doc_id":         e.Document.ID,
+				"severity":       f.Severity.String(),
+				"error":          f.Error,
+			})
+			continue
+		}
+		resolved = append(resolved, e.ID)
+	}
+
+	if len(resolved) == 0 {
+		return nil
+	}
+	if err := r.deadLetter.Delete(ctx, resolved); err != nil {
+		return fmt.Errorf("deleting re-driven dead letter entries: %w", err)
+	}
+	return nil
+}
+
+// documentKey identifies a document write within a batch. The document errors
+// returned by the stores don't necessarily keep all the original document
+// fields.
+func documentKey(doc Document) string {
+	return fmt.Sprintf("%s/%d/%t", doc.ID, doc.Version, doc.Delete)
+}
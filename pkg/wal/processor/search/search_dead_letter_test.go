@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+func TestRedriver_Redrive(t *testing.T) {
+	t.Parallel()
+
+	testEntries := []DeadLetterEntry{
+		{ID: "a", Document: *newTestDocument(withID("1"))},
+		{ID: "b", Document: *newTestDocument(withID("2"))},
+		{ID: "c", Document: *newTestDocument(withID("3"))},
+	}
+
+	// the dead letter store returns the test entries in batches of 2
+	listFn := func(ctx context.Context, afterID string, limit int) ([]DeadLetterEntry, error) {
+		require.Equal(t, 2, limit)
+		switch afterID {
+		case "":
+			return testEntries[:2], nil
+		case "b":
+			return testEntries[2:], nil
+		case "c":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("listFn: unexpected after id %q", afterID)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		store      *mockStore
+		deadLetter func(deleted *[]string) *mockDeadLetterStore
+
+		wantResult  *RedriveResult
+		wantDeleted []string
+		wantErr     error
+	}{
+		{
+			name: "ok - all documents indexed",
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, i uint, docs []Document) ([]DocumentError, error) {
+					return nil, nil
+				},
+			},
+			deadLetter: func(deleted *[]string) *mockDeadLetterStore {
+				return &mockDeadLetterStore{
+					listFn: listFn,
+					deleteFn: func(ctx context.Context, ids []string) error {
+						*deleted = append(*deleted, ids...)
+						return nil
+					},
+				}
+			},
+
+			wantResult:  &RedriveResult{Indexed: 3},
+			wantDeleted: []string{"a", "b", "c"},
+			wantErr:     nil,
+		},
+		{
+			name: "ok - superseded and failed documents",
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, i uint, docs []Document) ([]DocumentError, error) {
+					switch i {
+					case 1:
+						require.Equal(t, []Document{testEntries[0].Document, testEntries[1].Document}, docs)
+						return []DocumentError{
+							{Document: Document{ID: "1"}, Severity: SeverityIgnored, Error: errTest.Error()},
+							{Document: Document{ID: "2"}, Severity: SeverityDataLoss, Error: errTest.Error()},
+						}, nil
+					case 2:
+						require.Equal(t, []Document{testEntries[2].Document}, docs)
+						return nil, nil
+					default:
+						return nil, fmt.Errorf("sendDocumentsFn: unexpected call %d", i)
+					}
+				},
+			},
+			deadLetter: func(deleted *[]string) *mockDeadLetterStore {
+				return &mockDeadLetterStore{
+					listFn: listFn,
+					deleteFn: func(ctx context.Context, ids []string) error {
+						*deleted = append(*deleted, ids...)
+						return nil
+					},
+				}
+			},
+
+			wantResult:  &RedriveResult{Indexed: 1, Superseded: 1, Failed: 1},
+			wantDeleted: []string{"a", "c"},
+			wantErr:     nil,
+		},
+		{
+			name: "error - sending documents",
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, i uint, docs []Document) ([]DocumentError, error) {
+					return nil, errTest
+				},
+			},
+			deadLetter: func(deleted *[]string) *mockDeadLetterStore {
+				return &mockDeadLetterStore{
+					listFn: listFn,
+					deleteFn: func(ctx context.Context, ids []string) error {
+						return fmt.Errorf("deleteFn: should not be called")
+					},
+				}
+			},
+
+			wantResult:  &RedriveResult{},
+			wantDeleted: nil,
+			wantErr:     errTest,
+		},
+		{
+			name:  "error - listing entries",
+			store: &mockStore{},
+			deadLetter: func(deleted *[]string) *mockDeadLetterStore {
+				return &mockDeadLetterStore{
+					listFn: func(ctx context.Context, afterID string, limit int) ([]DeadLetterEntry, error) {
+						return nil, errTest
+					},
+				}
+			},
+
+			wantResult:  &RedriveResult{},
+			wantDeleted: nil,
+			wantErr:     errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var deleted []string
+			redriver := &Redriver{
+				deadLetter: tc.deadLetter(&deleted),
+				store:      tc.store,
+				logger:     loglib.NewNoopLogger(),
+				batchSize:  2,
+			}
+
+			result, err := redriver.Redrive(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantResult, result)
+			require.Equal(t, tc.wantDeleted, deleted)
+		})
+	}
+}
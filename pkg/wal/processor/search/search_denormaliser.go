@@ -0,0 +1,311 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// DenormalisationConfig declares the related rows embedded in the search
+// documents.
+type DenormalisationConfig struct {
+	Relations []Relation `json:"relations"`
+}
+
+// Relation embeds the row of a parent table, referenced by a foreign key, in
+// the search documents of a child table. Both tables must be in the same
+// schema.
+type Relation struct {
+	Schema string `json:"schema"`
+	// Table is the child table, whose documents embed the parent row.
+	Table string `json:"table"`
+	// ForeignKey are the child table columns that reference the parent row.
+	ForeignKey []string `json:"foreign_key"`
+	// Parent is the referenced table, and ParentKey its referenced columns,
+	// in the same order as the foreign key columns.
+	Parent    string   `json:"parent"`
+	ParentKey []string `json:"parent_key"`
+	// Columns are the parent table columns embedded in the child documents.
+	// All the columns are embedded if empty.
+	Columns []string `json:"columns,omitempty"`
+	// Field is the child document field the parent row is embedded in, by
+	// column name. Defaults to the parent table name.
+	Field string `json:"field,omitempty"`
+}
+
+// RelatedRowReader reads the related rows embedded in the search documents.
+// The rows are identified by their key, made of the values of the relation
+// key columns joined by '-', like the document ids.
+type RelatedRowReader interface {
+	// ParentRows returns the rows of the relation parent table with the keys
+	// on input, by key.
+	ParentRows(ctx context.Context, relation *Relation, keys []string) (map[string]map[string]any, error)
+	// ChildDocumentIDs returns the pgstream id of the relation child table,
+	// along with the ids of the documents of the child rows that reference
+	// the parent keys on input, by parent key.
+	ChildDocumentIDs(ctx context.Context, relation *Relation, keys []string) (string, map[string][]string, error)
+	Close() error
+}
+
+var errInvalidDenormalisationConfig = errors.New("invalid denormalisation config")
+
+// LoadDenormalisationConfig reads the JSON denormalisation configuration from
+// the file on input.
+func LoadDenormalisationConfig(path string) (*DenormalisationConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading denormalisation config file: %w", err)
+	}
+
+	cfg := &DenormalisationConfig{}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parsing denormalisation config file: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate returns an error if any of the relations doesn't identify its
+// tables and keys.
+func (c *DenormalisationConfig) Validate() error {
+	for _, r := range c.Relations {
+		if r.Schema == "" || r.Table == "" || r.Parent == "" {
+			return fmt.Errorf("%w: relations require schema, table and parent names", errInvalidDenormalisationConfig)
+		}
+		if len(r.ForeignKey) == 0 || len(r.ForeignKey) != len(r.ParentKey) {
+			return fmt.Errorf("%w: relation %s.%s: foreign key and parent key must have the same columns", errInvalidDenormalisationConfig, r.Schema, r.Table)
+		}
+		// the document fields starting with _ are reserved for the store
+		if strings.HasPrefix(r.field(), "_") {
+			return fmt.Errorf("%w: relation %s.%s: field %q can't start with _", errInvalidDenormalisationConfig, r.Schema, r.Table, r.field())
+		}
+	}
+	return nil
+}
+
+func (r *Relation) field() string {
+	if r.Field != "" {
+		return r.Field
+	}
+	return r.Parent
+}
+
+// maxUpdateDocuments is the max number of child documents updated per
+// request when a parent row changes.
+const maxUpdateDocuments = 1000
+
+// denormaliser embeds the parent rows of the configured relations in the
+// child documents, and updates the child documents when the parent rows
+// change. The rows are read from postgres when the batch is sent, so the
+// documents always embed the latest version of the parent rows.
+type denormaliser struct {
+	relations []Relation
+	reader    RelatedRowReader
+	store     Store
+	logger    loglib.Logger
+}
+
+// relatedKey identifies the parent row of a relation.
+type relatedKey struct {
+	relation *Relation
+	// key is empty when the child row doesn't reference any parent row
+	key string
+}
+
+func newDenormaliser(cfg *DenormalisationConfig, reader RelatedRowReader, store Store, logger loglib.Logger) *denormaliser {
+	return &denormaliser{
+		relations: cfg.Relations,
+		reader:    reader,
+		store:     store,
+		logger:    logger,
+	}
+}
+
+// addRelatedKeys sets the keys of the parent rows to embed in the document of
+// the write message on input, and the keys of the child documents to update
+// when the write row is a parent row.
+func (d *denormaliser) addRelatedKeys(m *msg, data *wal.Data) {
+	for idx := range d.relations {
+		r := &d.relations[idx]
+		if r.Schema != data.Schema {
+			continue
+		}
+
+		if r.Table == data.Table && !m.write.Delete {
+			if key, found := relationKey(data.Columns, r.ForeignKey); found {
+				m.parents = append(m.parents, relatedKey{relation: r, key: key})
+			}
+		}
+
+		if r.Parent == data.Table && (data.IsUpdate() || data.Action == "D") {
+			cols := data.Columns
+			if data.Action == "D" {
+				cols = data.Identity
+			}
+			if key, found := relationKey(cols, r.ParentKey); found && key != "" {
+				m.children = append(m.children, relatedKey{relation: r, key: key})
+			}
+		}
+	}
+}
+
+// embedParents reads the parent rows of the write messages on input, and
+// embeds them in their documents. The documents of child rows without a
+// parent row embed a null value.
+func (d *denormaliser) embedParents(ctx context.Context, msgs []*msg) error {
+	if !hasParents(msgs) {
+		return nil
+	}
+
+	keys := map[*Relation][]string{}
+	for _, m := range msgs {
+		for _, p := range m.parents {
+			if p.key != "" {
+				keys[p.relation] = append(keys[p.relation], p.key)
+			}
+		}
+	}
+
+	rows := make(map[*Relation]map[string]map[string]any, len(keys))
+	for r, relationKeys := range keys {
+		var err error
+		if rows[r], err = d.reader.ParentRows(ctx, r, uniqueKeys(relationKeys)); err != nil {
+			return fmt.Errorf("reading %s.%s parent rows: %w", r.Schema, r.Parent, err)
+		}
+	}
+
+	for _, m := range msgs {
+		for _, p := range m.parents {
+			var value any
+			if row, found := rows[p.relation][p.key]; found {
+				value = row
+			}
+			m.write.Data[p.relation.field()] = value
+		}
+	}
+	return nil
+}
+
+// updateChildren updates the parent rows embedded in the child documents of
+// the write messages on input, with their latest version. The parent rows
+// that no longer exist are set to null.
+func (d *denormaliser) updateChildren(ctx context.Context, msgs []*msg) error {
+	keys := map[*Relation][]string{}
+	for _, m := range msgs {
+		for _, c := range m.children {
+			keys[c.relation] = append(keys[c.relation], c.key)
+		}
+	}
+
+	for r, relationKeys := range keys {
+		relationKeys = uniqueKeys(relationKeys)
+		rows, err := d.reader.ParentRows(ctx, r, relationKeys)
+		if err != nil {
+			return fmt.Errorf("reading %s.%s parent rows: %w", r.Schema, r.Parent, err)
+		}
+		tableID, docIDs, err := d.reader.ChildDocumentIDs(ctx, r, relationKeys)
+		if err != nil {
+			return fmt.Errorf("reading %s.%s child documents: %w", r.Schema, r.Table, err)
+		}
+
+		for _, key := range relationKeys {
+			var value any
+			if row, found := rows[key]; found {
+				value = row
+			}
+			fields := map[string]any{r.field(): value}
+			ids := docIDs[key]
+			for len(ids) > 0 {
+				n := min(len(ids), maxUpdateDocuments)
+				if err := d.store.UpdateTableDocuments(ctx, r.Schema, tableID, ids[:n], fields); err != nil {
+					return fmt.Errorf("updating %s.%s child documents: %w", r.Schema, r.Table, err)
+				}
+				ids = ids[n:]
+			}
+		}
+
+		d.logger.Debug("search batch indexer: updated child documents", loglib.Fields{
+			"schema":      r.Schema,
+			"table":       r.Table,
+			"parent":      r.Parent,
+			"parent_keys": len(relationKeys),
+		})
+	}
+	return nil
+}
+
+func hasParents(msgs []*msg) bool {
+	for _, m := range msgs {
+		if len(m.parents) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasChildren(msgs []*msg) bool {
+	for _, m := range msgs {
+		if len(m.children) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// relationKey returns the key made of the values of the columns on input,
+// joined by '-' like the document ids. The key is empty if any of the values
+// is null. It returns false if any of the columns is not found.
+func relationKey(cols []wal.Column, names []string) (string, bool) {
+	values := make([]string, 0, len(names))
+	for _, name := range names {
+		var col *wal.Column
+		for idx := range cols {
+			if cols[idx].Name == name {
+				col = &cols[idx]
+				break
+			}
+		}
+		if col == nil {
+			return "", false
+		}
+
+		switch v := col.Value.(type) {
+		case nil:
+			return "", true
+		case string:
+			values = append(values, v)
+		case int64:
+			values = append(values, strconv.FormatInt(v, 10))
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			values = append(values, fmt.Sprintf("%v", v))
+		}
+	}
+	return strings.Join(values, "-"), true
+}
+
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, found := seen[key]; found {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, key)
+	}
+	return unique
+}
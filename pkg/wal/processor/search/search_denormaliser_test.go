@@ -0,0 +1,359 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestDenormalisationConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	validRelation := Relation{
+		Schema:     testSchemaName,
+		Table:      "orders",
+		ForeignKey: []string{"customer_id"},
+		Parent:     "customers",
+		ParentKey:  []string{"id"},
+	}
+
+	tests := []struct {
+		name     string
+		relation func(r Relation) Relation
+
+		wantErr error
+	}{
+		{
+			name:     "ok",
+			relation: func(r Relation) Relation { return r },
+		},
+		{
+			name: "error - missing parent",
+			relation: func(r Relation) Relation {
+				r.Parent = ""
+				return r
+			},
+			wantErr: errInvalidDenormalisationConfig,
+		},
+		{
+			name: "error - key columns mismatch",
+			relation: func(r Relation) Relation {
+				r.ParentKey = []string{"id", "tenant"}
+				return r
+			},
+			wantErr: errInvalidDenormalisationConfig,
+		},
+		{
+			name: "error - reserved field",
+			relation: func(r Relation) Relation {
+				r.Field = "_table"
+				return r
+			},
+			wantErr: errInvalidDenormalisationConfig,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &DenormalisationConfig{Relations: []Relation{tc.relation(validRelation)}}
+			require.ErrorIs(t, cfg.Validate(), tc.wantErr)
+		})
+	}
+}
+
+func TestDenormaliser_addRelatedKeys(t *testing.T) {
+	t.Parallel()
+
+	cfg := &DenormalisationConfig{
+		Relations: []Relation{
+			{
+				Schema:     testSchemaName,
+				Table:      "orders",
+				ForeignKey: []string{"tenant", "customer_id"},
+				Parent:     "customers",
+				ParentKey:  []string{"tenant", "id"},
+			},
+		},
+	}
+	relation := &cfg.Relations[0]
+
+	tests := []struct {
+		name string
+		data *wal.Data
+
+		wantParents  []relatedKey
+		wantChildren []relatedKey
+	}{
+		{
+			name: "child write",
+			data: &wal.Data{
+				Action: "I",
+				Schema: testSchemaName,
+				Table:  "orders",
+				Columns: []wal.Column{
+					{Name: "id", Value: int64(1)},
+					{Name: "tenant", Value: "a"},
+					{Name: "customer_id", Value: int64(7)},
+				},
+			},
+			wantParents: []relatedKey{{relation: relation, key: "a-7"}},
+		},
+		{
+			name: "child write without parent",
+			data: &wal.Data{
+				Action: "U",
+				Schema: testSchemaName,
+				Table:  "orders",
+				Columns: []wal.Column{
+					{Name: "tenant", Value: "a"},
+					{Name: "customer_id", Value: nil},
+				},
+			},
+			wantParents: []relatedKey{{relation: relation, key: ""}},
+		},
+		{
+			name: "parent update",
+			data: &wal.Data{
+				Action: "U",
+				Schema: testSchemaName,
+				Table:  "customers",
+				Columns: []wal.Column{
+					{Name: "tenant", Value: "a"},
+					{Name: "id", Value: float64(7)},
+				},
+			},
+			wantChildren: []relatedKey{{relation: relation, key: "a-7"}},
+		},
+		{
+			name: "parent delete",
+			data: &wal.Data{
+				Action: "D",
+				Schema: testSchemaName,
+				Table:  "customers",
+				Identity: []wal.Column{
+					{Name: "tenant", Value: "a"},
+					{Name: "id", Value: int64(7)},
+				},
+			},
+			wantChildren: []relatedKey{{relation: relation, key: "a-7"}},
+		},
+		{
+			name: "parent insert",
+			data: &wal.Data{
+				Action: "I",
+				Schema: testSchemaName,
+				Table:  "customers",
+				Columns: []wal.Column{
+					{Name: "tenant", Value: "a"},
+					{Name: "id", Value: int64(7)},
+				},
+			},
+		},
+		{
+			name: "other schema",
+			data: &wal.Data{
+				Action: "I",
+				Schema: "other_schema",
+				Table:  "orders",
+				Columns: []wal.Column{
+					{Name: "tenant", Value: "a"},
+					{Name: "customer_id", Value: int64(7)},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newDenormaliser(cfg, &mockRelatedRowReader{}, &mockStore{}, loglib.NewNoopLogger())
+			m := &msg{write: &Document{Delete: tc.data.Action == "D"}}
+			d.addRelatedKeys(m, tc.data)
+			require.Equal(t, tc.wantParents, m.parents)
+			require.Equal(t, tc.wantChildren, m.children)
+		})
+	}
+}
+
+func TestDenormaliser_embedParents(t *testing.T) {
+	t.Parallel()
+
+	cfg := &DenormalisationConfig{
+		Relations: []Relation{
+			{
+				Schema:     testSchemaName,
+				Table:      "orders",
+				ForeignKey: []string{"customer_id"},
+				Parent:     "customers",
+				ParentKey:  []string{"id"},
+				Field:      "customer",
+			},
+		},
+	}
+	relation := &cfg.Relations[0]
+
+	newMsgs := func() []*msg {
+		return []*msg{
+			{write: newTestDocument(withID("t1_1")), parents: []relatedKey{{relation: relation, key: "7"}}},
+			{write: newTestDocument(withID("t1_2")), parents: []relatedKey{{relation: relation, key: "7"}}},
+			{write: newTestDocument(withID("t1_3")), parents: []relatedKey{{relation: relation, key: "8"}}},
+			{write: newTestDocument(withID("t1_4")), parents: []relatedKey{{relation: relation, key: ""}}},
+			{write: newTestDocument(withID("t2_1"))},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		reader *mockRelatedRowReader
+
+		wantCustomers []any
+		wantErr       error
+	}{
+		{
+			name: "ok",
+			reader: &mockRelatedRowReader{
+				parentRowsFn: func(ctx context.Context, r *Relation, keys []string) (map[string]map[string]any, error) {
+					require.Equal(t, relation, r)
+					require.Equal(t, []string{"7", "8"}, keys)
+					return map[string]map[string]any{
+						"7": {"id": 7, "name": "a"},
+					}, nil
+				},
+			},
+
+			wantCustomers: []any{
+				map[string]any{"id": 7, "name": "a"},
+				map[string]any{"id": 7, "name": "a"},
+				nil,
+				nil,
+			},
+		},
+		{
+			name: "error - reading parent rows",
+			reader: &mockRelatedRowReader{
+				parentRowsFn: func(ctx context.Context, r *Relation, keys []string) (map[string]map[string]any, error) {
+					return nil, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newDenormaliser(cfg, tc.reader, &mockStore{}, loglib.NewNoopLogger())
+			msgs := newMsgs()
+			err := d.embedParents(context.Background(), msgs)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			for idx, want := range tc.wantCustomers {
+				customer, found := msgs[idx].write.Data["customer"]
+				require.True(t, found)
+				require.Equal(t, want, customer)
+			}
+			_, found := msgs[len(msgs)-1].write.Data["customer"]
+			require.False(t, found)
+		})
+	}
+}
+
+func TestDenormaliser_updateChildren(t *testing.T) {
+	t.Parallel()
+
+	cfg := &DenormalisationConfig{
+		Relations: []Relation{
+			{
+				Schema:     testSchemaName,
+				Table:      "orders",
+				ForeignKey: []string{"customer_id"},
+				Parent:     "customers",
+				ParentKey:  []string{"id"},
+			},
+		},
+	}
+	relation := &cfg.Relations[0]
+
+	msgs := []*msg{
+		{write: newTestDocument(withID("t2_7")), children: []relatedKey{{relation: relation, key: "7"}}},
+		{write: newTestDocument(withID("t2_8"), withDelete()), children: []relatedKey{{relation: relation, key: "8"}}},
+		{write: newTestDocument(withID("t2_7")), children: []relatedKey{{relation: relation, key: "7"}}},
+	}
+
+	reader := &mockRelatedRowReader{
+		parentRowsFn: func(ctx context.Context, r *Relation, keys []string) (map[string]map[string]any, error) {
+			require.Equal(t, []string{"7", "8"}, keys)
+			return map[string]map[string]any{"7": {"id": 7, "name": "b"}}, nil
+		},
+		childDocumentIDsFn: func(ctx context.Context, r *Relation, keys []string) (string, map[string][]string, error) {
+			require.Equal(t, []string{"7", "8"}, keys)
+			return "t1", map[string][]string{"7": {"t1_1", "t1_2"}, "8": {"t1_3"}}, nil
+		},
+	}
+
+	tests := []struct {
+		name  string
+		store *mockStore
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			store: &mockStore{
+				updateTableDocumentsFn: func(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error {
+					require.Equal(t, testSchemaName, schemaName)
+					require.Equal(t, "t1", tableID)
+					switch docIDs[0] {
+					case "t1_1":
+						require.Equal(t, []string{"t1_1", "t1_2"}, docIDs)
+						require.Equal(t, map[string]any{"customers": map[string]any{"id": 7, "name": "b"}}, fields)
+					case "t1_3":
+						require.Equal(t, []string{"t1_3"}, docIDs)
+						require.Equal(t, map[string]any{"customers": nil}, fields)
+					default:
+						return errors.New("unexpected documents")
+					}
+					return nil
+				},
+			},
+		},
+		{
+			name: "error - updating documents",
+			store: &mockStore{
+				updateTableDocumentsFn: func(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error {
+					return errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newDenormaliser(cfg, reader, tc.store, loglib.NewNoopLogger())
+			err := d.updateChildren(context.Background(), msgs)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
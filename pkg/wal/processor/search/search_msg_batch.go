@@ -19,10 +19,19 @@ type msg struct {
 	schemaChange *schemalog.LogEntry
 	bytesSize    int
 	pos          wal.CommitPosition
+	// lsn is the LSN of the write, used to report the documents that can't
+	// be indexed.
+	lsn string
+	// parents are the keys of the related rows embedded in the write
+	// document, and children the keys of the write row in the relations
+	// where it's embedded in other documents.
+	parents  []relatedKey
+	children []relatedKey
 }
 
 type truncateItem struct {
 	schemaName string
+	tableName  string
 	tableID    string
 }
 
@@ -72,3 +81,54 @@ func (m *msgBatch) size() int {
 func (m *msgBatch) isEmpty() bool {
 	return len(m.msgs) == 0 && len(m.positions) == 0
 }
+
+// coalesceWrites keeps only the highest version write of each document, along
+// with its LSN, since the lower versions would be overwritten by it anyway.
+// Deletes are kept when they are the highest version, so that the document is
+// deleted, and soft deletes are merged into the write they follow. It returns
+// the number of writes dropped.
+func coalesceWrites(writes []Document, lsns []string) ([]Document, []string, int) {
+	type docKey struct {
+		schema string
+		id     string
+	}
+	positions := make(map[docKey]int, len(writes))
+	coalesced := make([]Document, 0, len(writes))
+	coalescedLSNs := make([]string, 0, len(lsns))
+	for idx, doc := range writes {
+		key := docKey{schema: doc.Schema, id: doc.ID}
+		pos, found := positions[key]
+		if !found {
+			positions[key] = len(coalesced)
+			coalesced = append(coalesced, doc)
+			coalescedLSNs = append(coalescedLSNs, lsns[idx])
+			continue
+		}
+		// on version ties, the later write wins, as it would when indexed in
+		// order
+		if doc.Version >= coalesced[pos].Version {
+			// soft deletes only update the existing document, so the previous
+			// write needs to be kept, marked as deleted
+			if doc.SoftDelete && !coalesced[pos].Delete {
+				doc = softDeletedWrite(coalesced[pos], doc)
+			}
+			coalesced[pos] = doc
+			coalescedLSNs[pos] = lsns[idx]
+		}
+	}
+	return coalesced, coalescedLSNs, len(writes) - len(coalesced)
+}
+
+// softDeletedWrite returns the write on input with the soft delete fields set,
+// using the soft delete version.
+func softDeletedWrite(write, softDelete Document) Document {
+	data := make(map[string]any, len(write.Data)+2)
+	for k, v := range write.Data {
+		data[k] = v
+	}
+	data[DeletedField] = softDelete.Data[DeletedField]
+	data[DeletedAtField] = softDelete.Data[DeletedAtField]
+	write.Data = data
+	write.Version = softDelete.Version
+	return write
+}
@@ -14,22 +14,27 @@ import (
 
 type cleaner interface {
 	deleteSchema(context.Context, string) error
+	completeSchemaMigration(context.Context, string) error
 	start(context.Context)
 	stop()
 }
 
 type store interface {
 	DeleteSchema(ctx context.Context, schemaName string) error
+	CompleteSchemaMigration(ctx context.Context, schemaName string) error
+	ResumeSchemaMigrations(ctx context.Context) ([]string, error)
 }
 
-// schemaCleaner takes care of deleting schemas from the search store
+// schemaCleaner takes care of deleting schemas and completing schema index
+// migrations, cleaning up the previous index versions, from the search store
 // asynchronously
 type schemaCleaner struct {
-	logger              loglib.Logger
-	deleteSchemaQueue   chan string
-	store               store
-	backoffProvider     backoff.Provider
-	registrationTimeout time.Duration
+	logger                 loglib.Logger
+	deleteSchemaQueue      chan string
+	completeMigrationQueue chan string
+	store                  store
+	backoffProvider        backoff.Provider
+	registrationTimeout    time.Duration
 }
 
 const (
@@ -41,53 +46,91 @@ var errRegistrationTimeout = errors.New("timeout registering schema for clean up
 
 func newSchemaCleaner(cfg *backoff.Config, store store, logger loglib.Logger) *schemaCleaner {
 	return &schemaCleaner{
-		logger:              logger,
-		deleteSchemaQueue:   make(chan string, maxDeleteQueueSize),
-		store:               store,
-		registrationTimeout: defaultRegistrationTimeout,
-		backoffProvider:     backoff.NewProvider(cfg),
+		logger:            logger,
+		deleteSchemaQueue: make(chan string, maxDeleteQueueSize),
+		// schema migrations are completed in the order they're registered
+		completeMigrationQueue: make(chan string, maxDeleteQueueSize),
+		store:                  store,
+		registrationTimeout:    defaultRegistrationTimeout,
+		backoffProvider:        backoff.NewProvider(cfg),
 	}
 }
 
 // deleteSchema writes a delete schema item to the delete queue. Times out and returns an error after 5 seconds.
 func (sc *schemaCleaner) deleteSchema(_ context.Context, schemaName string) error {
+	return sc.register(sc.deleteSchemaQueue, schemaName)
+}
+
+// completeSchemaMigration writes a complete schema migration item to the
+// migration queue. Times out and returns an error after 5 seconds.
+func (sc *schemaCleaner) completeSchemaMigration(_ context.Context, schemaName string) error {
+	return sc.register(sc.completeMigrationQueue, schemaName)
+}
+
+func (sc *schemaCleaner) register(queue chan string, schemaName string) error {
 	select {
-	case sc.deleteSchemaQueue <- schemaName:
+	case queue <- schemaName:
 		return nil
 	case <-time.After(sc.registrationTimeout):
 		return errRegistrationTimeout
 	}
 }
 
-// start will continuously process schema items from the local delete queue
+// start will continuously process schema items from the local delete and
+// migration queues, once the migrations in progress from a previous run have
+// been completed
 func (sc *schemaCleaner) start(ctx context.Context) {
+	sc.resumeMigrations(ctx)
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case schema := <-sc.deleteSchemaQueue:
-			bo := sc.backoffProvider(ctx)
-			err := bo.RetryNotify(
-				func() error {
-					return getRetryError(sc.store.DeleteSchema(ctx, schema))
-				},
-				func(err error, duration time.Duration) {
-					sc.logger.Warn(err, "search schema cleaner: delete schema retry failed", loglib.Fields{
-						"backoff": duration,
-						"schema":  schema,
-					})
-				})
-			if err != nil {
-				sc.logger.Error(err, "search schema cleaner: delete schema", loglib.Fields{"schema": schema})
-			}
+			sc.retry(ctx, "delete schema", schema, sc.store.DeleteSchema)
+		case schema := <-sc.completeMigrationQueue:
+			sc.retry(ctx, "complete schema migration", schema, sc.store.CompleteSchemaMigration)
 		}
 	}
 }
 
-// stop will stop the processing of delete items from the queue and release
-// internal resources
+// resumeMigrations completes the schema index migrations that were in progress
+// when the store was last stopped, since they won't be registered again.
+func (sc *schemaCleaner) resumeMigrations(ctx context.Context) {
+	var schemas []string
+	sc.retry(ctx, "resume schema migrations", "", func(ctx context.Context, _ string) error {
+		var err error
+		schemas, err = sc.store.ResumeSchemaMigrations(ctx)
+		return err
+	})
+
+	for _, schema := range schemas {
+		sc.retry(ctx, "complete schema migration", schema, sc.store.CompleteSchemaMigration)
+	}
+}
+
+func (sc *schemaCleaner) retry(ctx context.Context, operation, schema string, fn func(context.Context, string) error) {
+	bo := sc.backoffProvider(ctx)
+	err := bo.RetryNotify(
+		func() error {
+			return getRetryError(fn(ctx, schema))
+		},
+		func(err error, duration time.Duration) {
+			sc.logger.Warn(err, fmt.Sprintf("search schema cleaner: %s retry failed", operation), loglib.Fields{
+				"backoff": duration,
+				"schema":  schema,
+			})
+		})
+	if err != nil {
+		sc.logger.Error(err, fmt.Sprintf("search schema cleaner: %s", operation), loglib.Fields{"schema": schema})
+	}
+}
+
+// stop will stop the processing of items from the queues and release internal
+// resources
 func (sc schemaCleaner) stop() {
 	close(sc.deleteSchemaQueue)
+	close(sc.completeMigrationQueue)
 }
 
 // getRetryError returns a backoff permanent error if the given error is not
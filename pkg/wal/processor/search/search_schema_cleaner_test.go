@@ -46,14 +46,18 @@ func TestSchemaCleaner_deleteSchema(t *testing.T) {
 			t.Parallel()
 
 			schemaCleaner := &schemaCleaner{
-				logger:              loglib.NewNoopLogger(),
-				registrationTimeout: time.Second,
-				deleteSchemaQueue:   make(chan string, tc.queueSize),
+				logger:                 loglib.NewNoopLogger(),
+				registrationTimeout:    time.Second,
+				deleteSchemaQueue:      make(chan string, tc.queueSize),
+				completeMigrationQueue: make(chan string, tc.queueSize),
 			}
 			defer schemaCleaner.stop()
 
 			err := schemaCleaner.deleteSchema(context.Background(), testSchemaName)
 			require.ErrorIs(t, err, tc.wantErr)
+
+			err = schemaCleaner.completeSchemaMigration(context.Background(), testSchemaName)
+			require.ErrorIs(t, err, tc.wantErr)
 		})
 	}
 }
@@ -67,22 +71,94 @@ func TestSchemaCleaner_start(t *testing.T) {
 	tests := []struct {
 		name            string
 		store           store
+		migration       bool
+		resumed         bool
 		backoffProvider func(doneChan chan struct{}) backoff.Provider
 	}{
 		{
 			name: "ok",
 			store: &mockStore{
+				resumeMigrationsFn: func(ctx context.Context) ([]string, error) {
+					return nil, nil
+				},
 				deleteSchemaFn: func(ctx context.Context, schemaName string) error {
 					require.Equal(t, testSchemaName, schemaName)
 					return nil
 				},
 			},
 			backoffProvider: func(doneChan chan struct{}) backoff.Provider {
-				once := sync.Once{}
 				return func(ctx context.Context) backoff.Backoff {
 					return &mocks.Backoff{
 						RetryNotifyFn: func(o backoff.Operation, n backoff.Notify) error {
-							defer once.Do(func() { doneChan <- struct{}{} })
+							defer func() { doneChan <- struct{}{} }()
+							return o()
+						},
+					}
+				}
+			},
+		},
+		{
+			name: "ok - complete schema migration",
+			store: &mockStore{
+				resumeMigrationsFn: func(ctx context.Context) ([]string, error) {
+					return nil, nil
+				},
+				completeMigrationFn: func(ctx context.Context, schemaName string) error {
+					require.Equal(t, testSchemaName, schemaName)
+					return nil
+				},
+			},
+			migration: true,
+			backoffProvider: func(doneChan chan struct{}) backoff.Provider {
+				return func(ctx context.Context) backoff.Backoff {
+					return &mocks.Backoff{
+						RetryNotifyFn: func(o backoff.Operation, n backoff.Notify) error {
+							defer func() { doneChan <- struct{}{} }()
+							return o()
+						},
+					}
+				}
+			},
+		},
+		{
+			name: "ok - resume schema migrations",
+			store: &mockStore{
+				resumeMigrationsFn: func(ctx context.Context) ([]string, error) {
+					return []string{testSchemaName}, nil
+				},
+				completeMigrationFn: func(ctx context.Context, schemaName string) error {
+					require.Equal(t, testSchemaName, schemaName)
+					return nil
+				},
+			},
+			resumed: true,
+			backoffProvider: func(doneChan chan struct{}) backoff.Provider {
+				return func(ctx context.Context) backoff.Backoff {
+					return &mocks.Backoff{
+						RetryNotifyFn: func(o backoff.Operation, n backoff.Notify) error {
+							defer func() { doneChan <- struct{}{} }()
+							return o()
+						},
+					}
+				}
+			},
+		},
+		{
+			name: "error resuming schema migrations",
+			store: &mockStore{
+				resumeMigrationsFn: func(ctx context.Context) ([]string, error) {
+					return nil, errTest
+				},
+				deleteSchemaFn: func(ctx context.Context, schemaName string) error {
+					require.Equal(t, testSchemaName, schemaName)
+					return nil
+				},
+			},
+			backoffProvider: func(doneChan chan struct{}) backoff.Provider {
+				return func(ctx context.Context) backoff.Backoff {
+					return &mocks.Backoff{
+						RetryNotifyFn: func(o backoff.Operation, n backoff.Notify) error {
+							defer func() { doneChan <- struct{}{} }()
 							return o()
 						},
 					}
@@ -92,16 +168,18 @@ func TestSchemaCleaner_start(t *testing.T) {
 		{
 			name: "error deleting schema",
 			store: &mockStore{
+				resumeMigrationsFn: func(ctx context.Context) ([]string, error) {
+					return nil, nil
+				},
 				deleteSchemaFn: func(ctx context.Context, schemaName string) error {
 					return errTest
 				},
 			},
 			backoffProvider: func(doneChan chan struct{}) backoff.Provider {
-				once := sync.Once{}
 				return func(ctx context.Context) backoff.Backoff {
 					return &mocks.Backoff{
 						RetryNotifyFn: func(o backoff.Operation, n backoff.Notify) error {
-							defer once.Do(func() { doneChan <- struct{}{} })
+							defer func() { doneChan <- struct{}{} }()
 							err := o()
 							if err != nil {
 								n(err, 50*time.Millisecond)
@@ -120,14 +198,14 @@ func TestSchemaCleaner_start(t *testing.T) {
 			t.Parallel()
 
 			doneChan := make(chan struct{}, 1)
-			defer close(doneChan)
 
 			schemaCleaner := &schemaCleaner{
-				logger:              loglib.NewNoopLogger(),
-				store:               tc.store,
-				backoffProvider:     tc.backoffProvider(doneChan),
-				registrationTimeout: defaultRegistrationTimeout,
-				deleteSchemaQueue:   make(chan string, 100),
+				logger:                 loglib.NewNoopLogger(),
+				store:                  tc.store,
+				backoffProvider:        tc.backoffProvider(doneChan),
+				registrationTimeout:    defaultRegistrationTimeout,
+				deleteSchemaQueue:      make(chan string, 100),
+				completeMigrationQueue: make(chan string, 100),
 			}
 			defer schemaCleaner.stop()
 
@@ -141,20 +219,27 @@ func TestSchemaCleaner_start(t *testing.T) {
 				schemaCleaner.start(ctx)
 			}()
 
-			schemaCleaner.deleteSchemaQueue <- testSchemaName
+			switch {
+			case tc.resumed:
+			case tc.migration:
+				schemaCleaner.completeMigrationQueue <- testSchemaName
+			default:
+				schemaCleaner.deleteSchemaQueue <- testSchemaName
+			}
 
-			for {
+			// the migrations are resumed before processing the queued item
+			for calls := 0; calls < 2; {
 				select {
 				case <-ctx.Done():
 					t.Errorf("test timeout reached")
 					wg.Wait()
 					return
 				case <-doneChan:
-					cancel()
-					wg.Wait()
-					return
+					calls++
 				}
 			}
+			cancel()
+			wg.Wait()
 		})
 	}
 }
@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// softDeleter turns the deletes and truncates of the configured tables into
+// soft deletes, and periodically purges the soft deleted documents older than
+// the retention.
+type softDeleter struct {
+	logger loglib.Logger
+	store  Store
+	// tables are the soft delete table names by schema name. Schemas with all
+	// their tables configured have a nil set.
+	tables        map[string]map[string]struct{}
+	retention     time.Duration
+	purgeInterval time.Duration
+	clock         func() time.Time
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+const (
+	allSchemaTables = "*"
+	// deletedAtFormat matches the format of the timestamp with time zone
+	// values of the documents.
+	deletedAtFormat = "2006-01-02T15:04:05.000Z"
+)
+
+func newSoftDeleter(cfg *SoftDeleteConfig, store Store, logger loglib.Logger) *softDeleter {
+	tables := make(map[string]map[string]struct{}, len(cfg.Tables))
+	for _, t := range cfg.Tables {
+		schema, table, _ := strings.Cut(t, ".")
+		if table == allSchemaTables {
+			tables[schema] = nil
+			continue
+		}
+		schemaTables, found := tables[schema]
+		if found && schemaTables == nil {
+			continue
+		}
+		if schemaTables == nil {
+			schemaTables = map[string]struct{}{}
+			tables[schema] = schemaTables
+		}
+		schemaTables[table] = struct{}{}
+	}
+
+	return &softDeleter{
+		logger:        logger,
+		store:         store,
+		tables:        tables,
+		retention:     cfg.Retention,
+		purgeInterval: cfg.purgeInterval(),
+		clock:         time.Now,
+		done:          make(chan struct{}),
+	}
+}
+
+// enabled returns true if the deletes of the table on input are soft deletes.
+func (sd *softDeleter) enabled(schemaName, tableName string) bool {
+	schemaTables, found := sd.tables[schemaName]
+	if !found {
+		return false
+	}
+	if schemaTables == nil {
+		return true
+	}
+	_, found = schemaTables[tableName]
+	return found
+}
+
+// markDeleted turns the delete document on input into a soft delete, deleted
+// at the time of the wal event, or the current time if it's not available.
+func (sd *softDeleter) markDeleted(doc *Document, data *wal.Data) {
+	deletedAt, err := data.GetTimestamp()
+	if err != nil {
+		deletedAt = sd.clock()
+	}
+	doc.SoftDelete = true
+	doc.Data[DeletedField] = true
+	doc.Data[DeletedAtField] = deletedAt.UTC().Format(deletedAtFormat)
+}
+
+// truncateTable marks all the documents of the truncated table as deleted.
+func (sd *softDeleter) truncateTable(ctx context.Context, item *truncateItem) error {
+	return sd.store.SoftDeleteTableDocuments(ctx, item.schemaName, []string{item.tableID}, sd.clock())
+}
+
+// start purges the soft deleted documents older than the retention on every
+// purge interval, until the context is cancelled or the soft deleter is
+// stopped. Documents are never purged if there's no retention configured.
+func (sd *softDeleter) start(ctx context.Context) {
+	if sd.retention <= 0 || len(sd.tables) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(sd.purgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sd.done:
+			return
+		case <-ticker.C:
+			sd.purge(ctx)
+		}
+	}
+}
+
+// purge removes the soft deleted documents older than the retention from the
+// schemas with soft delete tables. Failures are logged and retried on the next
+// purge.
+func (sd *softDeleter) purge(ctx context.Context) {
+	schemas := make([]string, 0, len(sd.tables))
+	for schema := range sd.tables {
+		schemas = append(schemas, schema)
+	}
+	slices.Sort(schemas)
+
+	deletedBefore := sd.clock().Add(-sd.retention)
+	for _, schema := range schemas {
+		if err := sd.store.PurgeDeletedDocuments(ctx, schema, deletedBefore); err != nil {
+			sd.logger.Error(err, "search soft deleter: purging deleted documents", loglib.Fields{
+				"schema":         schema,
+				"deleted_before": deletedBefore,
+			})
+		}
+	}
+}
+
+func (sd *softDeleter) stop() {
+	sd.stopOnce.Do(func() { close(sd.done) })
+}
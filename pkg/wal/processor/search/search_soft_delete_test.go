@@ -0,0 +1,188 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestSoftDeleteConfig_IsValid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		tables []string
+
+		wantErr error
+	}{
+		{
+			name:   "ok",
+			tables: []string{"public.users", "audit.*"},
+		},
+		{
+			name:   "ok - no tables",
+			tables: nil,
+		},
+		{
+			name:    "error - missing schema",
+			tables:  []string{"users"},
+			wantErr: errInvalidSoftDeleteTable,
+		},
+		{
+			name:    "error - empty table",
+			tables:  []string{"public."},
+			wantErr: errInvalidSoftDeleteTable,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &SoftDeleteConfig{Tables: tc.tables}
+			require.ErrorIs(t, cfg.IsValid(), tc.wantErr)
+		})
+	}
+}
+
+func TestSoftDeleter_enabled(t *testing.T) {
+	t.Parallel()
+
+	sd := newSoftDeleter(&SoftDeleteConfig{
+		Tables: []string{"public.users", "public.orders", "audit.*", "audit.events"},
+	}, &mockStore{}, loglib.NewNoopLogger())
+
+	require.True(t, sd.enabled("public", "users"))
+	require.True(t, sd.enabled("public", "orders"))
+	require.False(t, sd.enabled("public", "products"))
+	require.True(t, sd.enabled("audit", "events"))
+	require.True(t, sd.enabled("audit", "logins"))
+	require.False(t, sd.enabled("private", "users"))
+}
+
+func TestSoftDeleter_markDeleted(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		data *wal.Data
+
+		wantDeletedAt string
+	}{
+		{
+			name:          "wal event timestamp",
+			data:          &wal.Data{Timestamp: "2024-04-30 08:15:30.123456+00"},
+			wantDeletedAt: "2024-04-30T08:15:30.123Z",
+		},
+		{
+			name:          "invalid wal event timestamp",
+			data:          &wal.Data{Timestamp: "yesterday"},
+			wantDeletedAt: "2024-05-01T10:00:00.000Z",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sd := &softDeleter{clock: func() time.Time { return now }}
+			doc := newTestDocument(withDelete())
+			sd.markDeleted(doc, tc.data)
+
+			require.True(t, doc.Delete)
+			require.True(t, doc.SoftDelete)
+			require.Equal(t, true, doc.Data[DeletedField])
+			require.Equal(t, tc.wantDeletedAt, doc.Data[DeletedAtField])
+			require.Equal(t, testTableID, doc.Data["_table"])
+		})
+	}
+}
+
+func TestSoftDeleter_purge(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	retention := 24 * time.Hour
+	purgedSchemas := []string{}
+
+	sd := newSoftDeleter(&SoftDeleteConfig{
+		Tables:    []string{"public.users", "audit.*", "public.orders"},
+		Retention: retention,
+	}, &mockStore{
+		purgeDeletedDocsFn: func(ctx context.Context, schemaName string, deletedBefore time.Time) error {
+			require.Equal(t, now.Add(-retention), deletedBefore)
+			purgedSchemas = append(purgedSchemas, schemaName)
+			// failures don't stop the purge of the rest of schemas
+			return errTest
+		},
+	}, loglib.NewNoopLogger())
+	sd.clock = func() time.Time { return now }
+
+	sd.purge(context.Background())
+	require.Equal(t, []string{"audit", "public"}, purgedSchemas)
+}
+
+func TestSoftDeleter_start(t *testing.T) {
+	t.Parallel()
+
+	t.Run("purges on every interval", func(t *testing.T) {
+		t.Parallel()
+
+		purged := make(chan string, 10)
+		sd := newSoftDeleter(&SoftDeleteConfig{
+			Tables:        []string{"public.users"},
+			Retention:     time.Hour,
+			PurgeInterval: 10 * time.Millisecond,
+		}, &mockStore{
+			purgeDeletedDocsFn: func(ctx context.Context, schemaName string, deletedBefore time.Time) error {
+				purged <- schemaName
+				return nil
+			},
+		}, loglib.NewNoopLogger())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go sd.start(ctx)
+
+		for i := 0; i < 2; i++ {
+			select {
+			case schema := <-purged:
+				require.Equal(t, "public", schema)
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for purge")
+			}
+		}
+		sd.stop()
+	})
+
+	t.Run("no retention", func(t *testing.T) {
+		t.Parallel()
+
+		sd := newSoftDeleter(&SoftDeleteConfig{
+			Tables:        []string{"public.users"},
+			PurgeInterval: time.Millisecond,
+		}, &mockStore{}, loglib.NewNoopLogger())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			sd.start(context.Background())
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("soft deleter started without retention")
+		}
+	})
+}
@@ -69,15 +69,40 @@ func (s *StoreRetrier) DeleteSchema(ctx context.Context, schemaName string) erro
 	return s.inner.DeleteSchema(ctx, schemaName)
 }
 
+func (s *StoreRetrier) CompleteSchemaMigration(ctx context.Context, schemaName string) error {
+	return s.inner.CompleteSchemaMigration(ctx, schemaName)
+}
+
+func (s *StoreRetrier) ResumeSchemaMigrations(ctx context.Context) ([]string, error) {
+	return s.inner.ResumeSchemaMigrations(ctx)
+}
+
 func (s *StoreRetrier) DeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string) error {
 	return s.inner.DeleteTableDocuments(ctx, schemaName, tableIDs)
 }
 
+func (s *StoreRetrier) UpdateTableDocuments(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error {
+	return s.inner.UpdateTableDocuments(ctx, schemaName, tableID, docIDs, fields)
+}
+
+func (s *StoreRetrier) SoftDeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string, deletedAt time.Time) error {
+	return s.inner.SoftDeleteTableDocuments(ctx, schemaName, tableIDs, deletedAt)
+}
+
+func (s *StoreRetrier) PurgeDeletedDocuments(ctx context.Context, schemaName string, deletedBefore time.Time) error {
+	return s.inner.PurgeDeletedDocuments(ctx, schemaName, deletedBefore)
+}
+
 // SendDocuments will go over failed documents, identifying any with retriable
-// errors and retrying them with the configured backoff policy.
+// errors and retrying them with the configured backoff policy. The documents
+// that failed with non retriable errors in any of the attempts are returned,
+// along with the retriable ones that couldn't be sent.
 func (s *StoreRetrier) SendDocuments(ctx context.Context, docs []Document) ([]DocumentError, error) {
 	docsToSend := docs
 	failedDocs := []DocumentError{}
+	// droppedDocs keeps the documents that failed with non retriable errors,
+	// since they're not part of the following attempts
+	droppedDocs := []DocumentError{}
 	send := func(ctx context.Context) error {
 		total := len(docsToSend)
 		var err error
@@ -86,7 +111,9 @@ func (s *StoreRetrier) SendDocuments(ctx context.Context, docs []Document) ([]Do
 			return err
 		}
 
-		docsToSend = s.getRetriableDocs(failedDocs)
+		var dropped []DocumentError
+		docsToSend, dropped = s.getRetriableDocs(failedDocs)
+		droppedDocs = append(droppedDocs, dropped...)
 		// nothing to retry
 		if len(docsToSend) == 0 {
 			return nil
@@ -114,23 +141,32 @@ func (s *StoreRetrier) SendDocuments(ctx context.Context, docs []Document) ([]Do
 	if err != nil {
 		// some documents failed to send - return back whatever failed
 		if errors.Is(err, errPartialDocumentSend) {
-			return failedDocs, nil
+			return append(droppedDocs, retriableFailures(failedDocs)...), nil
 		}
 		// internal search store error points to something wrong, return the error
 		// along with the failed documents
-		return failedDocs, err
+		if len(droppedDocs) == 0 {
+			return failedDocs, err
+		}
+		return append(droppedDocs, failedDocs...), err
 	}
 
-	return nil, nil
+	if len(droppedDocs) == 0 {
+		return nil, nil
+	}
+	return droppedDocs, nil
 }
 
-func (s *StoreRetrier) getRetriableDocs(failedDocs []DocumentError) []Document {
+// getRetriableDocs returns the failed documents that can be retried, and the
+// failures that can't.
+func (s *StoreRetrier) getRetriableDocs(failedDocs []DocumentError) ([]Document, []DocumentError) {
 	if len(failedDocs) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	dropped := 0
 	docsToRetry := make([]Document, 0, len(failedDocs))
+	notRetried := make([]DocumentError, 0, len(failedDocs))
 	for _, f := range failedDocs {
 		switch f.Severity {
 		case SeverityDataLoss:
@@ -138,6 +174,9 @@ func (s *StoreRetrier) getRetriableDocs(failedDocs []DocumentError) []Document {
 		case SeverityRetriable:
 			docsToRetry = append(docsToRetry, f.Document)
 		}
+		if f.Severity != SeverityRetriable {
+			notRetried = append(notRetried, f)
+		}
 		if f.Severity != SeverityNone {
 			s.logFailure(f)
 		}
@@ -150,7 +189,17 @@ func (s *StoreRetrier) getRetriableDocs(failedDocs []DocumentError) []Document {
 		})
 	}
 
-	return docsToRetry
+	return docsToRetry, notRetried
+}
+
+func retriableFailures(failedDocs []DocumentError) []DocumentError {
+	retriable := make([]DocumentError, 0, len(failedDocs))
+	for _, f := range failedDocs {
+		if f.Severity == SeverityRetriable {
+			retriable = append(retriable, f)
+		}
+	}
+	return retriable
 }
 
 func (s *StoreRetrier) logFailure(docErr DocumentError) {
@@ -82,7 +82,29 @@ func TestStoreRetrier_SendDocuments(t *testing.T) {
 					}
 				},
 			},
-			wantFailedDocs: nil,
+			wantFailedDocs: failedDocs(SeverityDataLoss),
+			wantErr:        nil,
+		},
+		{
+			name: "ok - dropped documents from previous attempts",
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, i uint, docs []Document) ([]DocumentError, error) {
+					switch i {
+					case 1:
+						require.Equal(t, testDocs, docs)
+						return []DocumentError{
+							failedDocs(SeverityDataLoss)[0],
+							{Document: *newTestDocument(withID("2")), Severity: SeverityRetriable, Error: errTest.Error()},
+						}, nil
+					case 2:
+						require.Equal(t, []Document{*newTestDocument(withID("2"))}, docs)
+						return nil, nil
+					default:
+						return nil, fmt.Errorf("sendDocumentsFn: unexpected call %d", i)
+					}
+				},
+			},
+			wantFailedDocs: failedDocs(SeverityDataLoss),
 			wantErr:        nil,
 		},
 		{
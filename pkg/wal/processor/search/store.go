@@ -4,6 +4,7 @@ package search
 
 import (
 	"context"
+	"time"
 
 	"github.com/xataio/pgstream/pkg/schemalog"
 )
@@ -13,9 +14,25 @@ type Store interface {
 	// schema operations
 	ApplySchemaChange(ctx context.Context, logEntry *schemalog.LogEntry) error
 	DeleteSchema(ctx context.Context, schemaName string) error
+	// CompleteSchemaMigration waits for any in progress schema index migration
+	// to complete, and cleans up the previous index versions.
+	CompleteSchemaMigration(ctx context.Context, schemaName string) error
+	// ResumeSchemaMigrations loads the schema index migrations that were in
+	// progress when the store was last stopped, and returns the names of
+	// their schemas, so that they can be completed.
+	ResumeSchemaMigrations(ctx context.Context) ([]string, error)
 	// data operations
 	DeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string) error
 	SendDocuments(ctx context.Context, docs []Document) ([]DocumentError, error)
+	// UpdateTableDocuments sets the fields on input in the existing documents
+	// of the table with the ids on input.
+	UpdateTableDocuments(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error
+	// SoftDeleteTableDocuments marks all the documents of the tables on input
+	// as deleted at the time on input.
+	SoftDeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string, deletedAt time.Time) error
+	// PurgeDeletedDocuments removes the documents of the schema that were
+	// soft deleted before the time on input.
+	PurgeDeletedDocuments(ctx context.Context, schemaName string, deletedBefore time.Time) error
 }
 
 type Mapper interface {
@@ -24,13 +41,23 @@ type Mapper interface {
 }
 
 type Document struct {
-	ID      string
-	Schema  string
-	Data    map[string]any
-	Version int
-	Delete  bool
+	ID      string         `json:"id"`
+	Schema  string         `json:"schema"`
+	Data    map[string]any `json:"data"`
+	Version int            `json:"version"`
+	Delete  bool           `json:"delete"`
+	// SoftDelete is set on deletes that mark the document as deleted, with
+	// the DeletedField and DeletedAtField fields, instead of removing it.
+	SoftDelete bool `json:"soft_delete,omitempty"`
 }
 
+const (
+	// DeletedField and DeletedAtField are the fields set on the soft deleted
+	// documents.
+	DeletedField   = "_deleted"
+	DeletedAtField = "_deleted_at"
+)
+
 type DocumentError struct {
 	Document Document
 	Severity Severity
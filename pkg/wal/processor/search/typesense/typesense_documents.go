@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/xataio/pgstream/internal/typesense"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+var (
+	errVersionConflict  = errors.New("version conflict: document has an equal or higher version")
+	errDocumentNotFound = errors.New("document not found")
+)
+
+// SendDocuments writes the documents on input to their schema collections.
+// Documents with a version lower or equal to the one already stored are
+// ignored, as with the opensearch external versioning. Unlike opensearch, no
+// tombstones are kept for the deleted documents, so a write older than the
+// delete received after it would create the document again.
+func (s *Store) SendDocuments(ctx context.Context, docs []search.Document) ([]search.DocumentError, error) {
+	// documents by schema, keeping the order of the schemas
+	schemaDocs := map[string][]search.Document{}
+	schemas := []string{}
+	for _, doc := range docs {
+		if _, found := schemaDocs[doc.Schema]; !found {
+			schemas = append(schemas, doc.Schema)
+		}
+		schemaDocs[doc.Schema] = append(schemaDocs[doc.Schema], doc)
+	}
+
+	var failed []search.DocumentError
+	for _, schema := range schemas {
+		docErrs, err := s.sendCollectionDocuments(ctx, schema, schemaDocs[schema])
+		if err != nil {
+			return nil, err
+		}
+		failed = append(failed, docErrs...)
+	}
+	return failed, nil
+}
+
+func (s *Store) sendCollectionDocuments(ctx context.Context, collection string, docs []search.Document) ([]search.DocumentError, error) {
+	var failed []search.DocumentError
+	// only the latest version of each document is written
+	latest := make(map[string]search.Document, len(docs))
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		prev, found := latest[doc.ID]
+		switch {
+		case !found:
+			ids = append(ids, doc.ID)
+		case prev.Version >= doc.Version:
+			failed = append(failed, ignoredDocument(doc, errVersionConflict))
+			continue
+		default:
+			failed = append(failed, ignoredDocument(prev, errVersionConflict))
+		}
+		latest[doc.ID] = doc
+	}
+
+	versions, err := s.documentVersions(ctx, collection, ids)
+	if err != nil {
+		if errors.Is(err, typesense.ErrNotFound) {
+			// the schema collection has not been created
+			for _, id := range ids {
+				doc := latest[id]
+				failed = append(failed, search.DocumentError{
+					Document: doc,
+					Severity: parseSeverity(http.StatusNotFound, doc.Delete),
+					Error:    err.Error(),
+				})
+			}
+			return failed, nil
+		}
+		return nil, mapError(err)
+	}
+
+	var upserts, softDeletes []search.Document
+	var deletes []string
+	for _, id := range ids {
+		doc := latest[id]
+		version, found := versions[id]
+		switch {
+		case found && int64(doc.Version) <= version:
+			failed = append(failed, ignoredDocument(doc, errVersionConflict))
+		case doc.Delete && !found:
+			// nothing to delete, likely event out of order
+			failed = append(failed, ignoredDocument(doc, errDocumentNotFound))
+		case doc.SoftDelete:
+			softDeletes = append(softDeletes, doc)
+		case doc.Delete:
+			deletes = append(deletes, doc.ID)
+		default:
+			upserts = append(upserts, doc)
+		}
+	}
+
+	docErrs, err := s.importDocuments(ctx, collection, upserts, typesense.ImportUpsert, upsertRecord)
+	if err != nil {
+		return nil, err
+	}
+	failed = append(failed, docErrs...)
+
+	docErrs, err = s.importDocuments(ctx, collection, softDeletes, typesense.ImportUpdate, softDeleteRecord)
+	if err != nil {
+		return nil, err
+	}
+	failed = append(failed, docErrs...)
+
+	for _, chunk := range chunks(deletes, typesense.MaxPerPage) {
+		if _, err := s.client.DeleteDocuments(ctx, collection, "id:"+typesense.FilterValues(chunk)); err != nil && !errors.Is(err, typesense.ErrNotFound) {
+			return nil, mapError(err)
+		}
+	}
+
+	return failed, nil
+}
+
+// documentVersions returns the versions of the stored documents with the ids
+// on input, by document id.
+func (s *Store) documentVersions(ctx context.Context, collection string, ids []string) (map[string]int64, error) {
+	versions := make(map[string]int64, len(ids))
+	for _, chunk := range chunks(ids, typesense.MaxPerPage) {
+		res, err := s.client.SearchDocuments(ctx, collection, &typesense.SearchRequest{
+			FilterBy:      "id:" + typesense.FilterValues(chunk),
+			IncludeFields: []string{"id", versionField},
+			PerPage:       typesense.MaxPerPage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range res.Hits {
+			id, _ := hit.Document["id"].(string)
+			version, err := int64Value(hit.Document[versionField])
+			if err != nil {
+				return nil, fmt.Errorf("document %s version: %w", id, err)
+			}
+			versions[id] = version
+		}
+	}
+	return versions, nil
+}
+
+// importDocuments imports the records of the documents on input with the
+// given action, and returns the documents that failed.
+func (s *Store) importDocuments(ctx context.Context, collection string, docs []search.Document, action typesense.ImportAction, toRecord func(search.Document) (map[string]any, error)) ([]search.DocumentError, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var failed []search.DocumentError
+	records := make([]map[string]any, 0, len(docs))
+	recordDocs := make([]search.Document, 0, len(docs))
+	for _, doc := range docs {
+		record, err := toRecord(doc)
+		if err != nil {
+			failed = append(failed, search.DocumentError{Document: doc, Severity: search.SeverityDataLoss, Error: err.Error()})
+			continue
+		}
+		records = append(records, record)
+		recordDocs = append(recordDocs, doc)
+	}
+
+	results, err := s.client.ImportDocuments(ctx, collection, records, action)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	for i, res := range results {
+		if res.Success {
+			continue
+		}
+		doc := recordDocs[i]
+		failed = append(failed, search.DocumentError{
+			Document: doc,
+			Severity: parseSeverity(res.Code, doc.Delete),
+			Error:    res.Error,
+		})
+	}
+	return failed, nil
+}
+
+// upsertRecord returns the typesense record of the document on input, with
+// its id and version.
+func upsertRecord(doc search.Document) (map[string]any, error) {
+	record := make(map[string]any, len(doc.Data)+3)
+	for k, v := range doc.Data {
+		record[k] = v
+	}
+	record["id"] = doc.ID
+	record[versionField] = doc.Version
+	if _, found := record[search.DeletedField]; !found {
+		record[search.DeletedField] = false
+	}
+	// writes merged with a soft delete have the deletion time in the search
+	// date format
+	if deletedAt, ok := record[search.DeletedAtField].(string); ok {
+		millis, err := parseEpochMillis(deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		record[search.DeletedAtField] = millis
+	}
+	return record, nil
+}
+
+// softDeleteRecord returns the partial typesense record that marks the
+// document on input as deleted.
+func softDeleteRecord(doc search.Document) (map[string]any, error) {
+	deletedAt, _ := doc.Data[search.DeletedAtField].(string)
+	millis, err := parseEpochMillis(deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"id":                  doc.ID,
+		versionField:          doc.Version,
+		search.DeletedField:   true,
+		search.DeletedAtField: millis,
+	}, nil
+}
+
+func ignoredDocument(doc search.Document, err error) search.DocumentError {
+	return search.DocumentError{
+		Document: doc,
+		Severity: search.SeverityIgnored,
+		Error:    err.Error(),
+	}
+}
+
+func parseSeverity(code int, delete bool) search.Severity {
+	switch code {
+	case http.StatusBadRequest: // the document is invalid. We drop it.
+		return search.SeverityDataLoss
+	case http.StatusConflict: // ignore, likely event out of order
+		return search.SeverityIgnored
+	case http.StatusNotFound:
+		if delete { // ignore, likely event out of order
+			return search.SeverityIgnored
+		}
+		return search.SeverityDataLoss
+	case http.StatusTooManyRequests: // retry events, likely search store overloaded
+		return search.SeverityRetriable
+	default:
+		if code >= http.StatusInternalServerError {
+			return search.SeverityRetriable
+		}
+		return search.SeverityDataLoss
+	}
+}
+
+func int64Value(v any) (int64, error) {
+	switch n := v.(type) {
+	case float64:
+		return int64(n), nil
+	case json.Number:
+		return n.Int64()
+	case int64:
+		return n, nil
+	case int:
+		return int64(n), nil
+	default:
+		return 0, fmt.Errorf("unexpected version type %T", v)
+	}
+}
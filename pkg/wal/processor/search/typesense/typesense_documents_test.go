@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/typesense"
+	typesensemocks "github.com/xataio/pgstream/internal/typesense/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestStore_SendDocuments(t *testing.T) {
+	t.Parallel()
+
+	testDoc := func(id string, version int) search.Document {
+		return search.Document{
+			ID:      id,
+			Schema:  testSchemaName,
+			Version: version,
+			Data:    map[string]any{"_table": "t1", "t1-1": "a"},
+		}
+	}
+	deleteDoc := func(id string, version int) search.Document {
+		doc := testDoc(id, version)
+		doc.Delete = true
+		return doc
+	}
+	softDeleteDoc := func(id string, version int) search.Document {
+		doc := deleteDoc(id, version)
+		doc.SoftDelete = true
+		doc.Data[search.DeletedField] = true
+		doc.Data[search.DeletedAtField] = "2024-05-01T10:00:00.000Z"
+		return doc
+	}
+
+	// stored document versions
+	storedVersions := func(versions map[string]int) func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+		return func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+			require.Equal(t, testSchemaName, collection)
+			require.Equal(t, []string{"id", versionField}, req.IncludeFields)
+			res := &typesense.SearchResponse{}
+			for id, v := range versions {
+				res.Hits = append(res.Hits, typesense.SearchHit{Document: map[string]any{"id": id, versionField: float64(v)}})
+			}
+			return res, nil
+		}
+	}
+
+	tests := []struct {
+		name   string
+		client *typesensemocks.Client
+		docs   []search.Document
+
+		wantFailed []search.DocumentError
+		wantErr    error
+	}{
+		{
+			name: "ok - upserts, deletes and soft deletes",
+			client: &typesensemocks.Client{
+				SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+					require.Equal(t, "id:[`doc-1`,`doc-2`,`doc-3`,`doc-4`]", req.FilterBy)
+					return storedVersions(map[string]int{"doc-2": 1, "doc-3": 1})(ctx, collection, req)
+				},
+				ImportDocumentsFn: func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+					switch action {
+					case typesense.ImportUpsert:
+						require.Equal(t, []map[string]any{
+							{"id": "doc-1", "_version": 1, "_table": "t1", "t1-1": "a", "_deleted": false},
+						}, docs)
+					case typesense.ImportUpdate:
+						require.Equal(t, []map[string]any{
+							{"id": "doc-3", "_version": 2, "_deleted": true, "_deleted_at": int64(1714557600000)},
+						}, docs)
+					default:
+						return nil, fmt.Errorf("unexpected action: %s", action)
+					}
+					return []typesense.ImportResult{{Success: true}}, nil
+				},
+				DeleteDocumentsFn: func(ctx context.Context, collection, filterBy string) (int, error) {
+					require.Equal(t, testSchemaName, collection)
+					require.Equal(t, "id:[`doc-2`]", filterBy)
+					return 1, nil
+				},
+			},
+			docs: []search.Document{
+				testDoc("doc-1", 1),
+				deleteDoc("doc-2", 2),
+				softDeleteDoc("doc-3", 2),
+				// not found
+				deleteDoc("doc-4", 2),
+			},
+
+			wantFailed: []search.DocumentError{
+				{Document: deleteDoc("doc-4", 2), Severity: search.SeverityIgnored, Error: errDocumentNotFound.Error()},
+			},
+		},
+		{
+			name: "ok - version conflicts",
+			client: &typesensemocks.Client{
+				SearchDocumentsFn: storedVersions(map[string]int{"doc-1": 3}),
+				ImportDocumentsFn: func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+					require.Equal(t, []map[string]any{
+						{"id": "doc-2", "_version": 5, "_table": "t1", "t1-1": "a", "_deleted": false},
+					}, docs)
+					return []typesense.ImportResult{{Success: true}}, nil
+				},
+			},
+			docs: []search.Document{
+				testDoc("doc-1", 3),
+				testDoc("doc-2", 5),
+				// older version in the same batch
+				testDoc("doc-2", 4),
+			},
+
+			wantFailed: []search.DocumentError{
+				{Document: testDoc("doc-2", 4), Severity: search.SeverityIgnored, Error: errVersionConflict.Error()},
+				{Document: testDoc("doc-1", 3), Severity: search.SeverityIgnored, Error: errVersionConflict.Error()},
+			},
+		},
+		{
+			name: "ok - failed imports",
+			client: &typesensemocks.Client{
+				SearchDocumentsFn: storedVersions(nil),
+				ImportDocumentsFn: func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+					return []typesense.ImportResult{
+						{Success: false, Code: 400, Error: "invalid field"},
+						{Success: false, Code: 503, Error: "not ready"},
+					}, nil
+				},
+			},
+			docs: []search.Document{
+				testDoc("doc-1", 1),
+				testDoc("doc-2", 1),
+			},
+
+			wantFailed: []search.DocumentError{
+				{Document: testDoc("doc-1", 1), Severity: search.SeverityDataLoss, Error: "invalid field"},
+				{Document: testDoc("doc-2", 1), Severity: search.SeverityRetriable, Error: "not ready"},
+			},
+		},
+		{
+			name: "ok - collection not found",
+			client: &typesensemocks.Client{
+				SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+					return nil, typesense.ErrNotFound
+				},
+			},
+			docs: []search.Document{
+				testDoc("doc-1", 1),
+				deleteDoc("doc-2", 1),
+			},
+
+			wantFailed: []search.DocumentError{
+				{Document: testDoc("doc-1", 1), Severity: search.SeverityDataLoss, Error: typesense.ErrNotFound.Error()},
+				{Document: deleteDoc("doc-2", 1), Severity: search.SeverityIgnored, Error: typesense.ErrNotFound.Error()},
+			},
+		},
+		{
+			name: "error - getting document versions",
+			client: &typesensemocks.Client{
+				SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+					return nil, typesense.RetryableError{Cause: errTest}
+				},
+			},
+			docs: []search.Document{testDoc("doc-1", 1)},
+
+			wantErr: search.ErrRetriable,
+		},
+		{
+			name: "error - importing documents",
+			client: &typesensemocks.Client{
+				SearchDocumentsFn: storedVersions(nil),
+				ImportDocumentsFn: func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+					return nil, errTest
+				},
+			},
+			docs: []search.Document{testDoc("doc-1", 1)},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewStoreWithClient(tc.client)
+			failed, err := store.SendDocuments(context.Background(), tc.docs)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantFailed, failed)
+		})
+	}
+}
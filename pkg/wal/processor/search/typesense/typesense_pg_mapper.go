@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/internal/typesense"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+	"github.com/xataio/pgstream/pkg/wal/processor/search/opensearch"
+)
+
+// Mapper maps between postgres and typesense types. It relies on the
+// opensearch mapper to parse the postgres values, and converts the resulting
+// values into the typesense types. Dates and timestamps are stored as unix
+// epoch milliseconds, since typesense has no date type.
+type Mapper struct {
+	mapper *opensearch.Mapper
+}
+
+type valueKind uint
+
+const (
+	valueDefault valueKind = iota
+	valueDateTime
+	valueJSON
+)
+
+type field struct {
+	fieldType string
+	numDim    int
+	kind      valueKind
+}
+
+// dateTimeFormats are the formats of the date and timestamp values returned by
+// the opensearch mapper.
+var dateTimeFormats = []string{
+	"2006-01-02T15:04:05.000Z",
+	"2006-01-02T15:04:05.000",
+	time.DateOnly,
+}
+
+// NewPostgresMapper returns a mapper that maps between postgres and typesense
+// types
+func NewPostgresMapper() *Mapper {
+	return &Mapper{
+		mapper: opensearch.NewPostgresMapper(),
+	}
+}
+
+// ColumnToSearchMapping maps the column on input into the equivalent typesense
+// field definition, with the `type` and, for vectors, the `num_dim` keys.
+func (m *Mapper) ColumnToSearchMapping(column schemalog.Column) (map[string]any, error) {
+	f, err := m.columnToField(column)
+	if err != nil {
+		return nil, err
+	}
+	mapping := map[string]any{"type": f.fieldType}
+	if f.numDim > 0 {
+		mapping["num_dim"] = f.numDim
+	}
+	return mapping, nil
+}
+
+// MapColumnValue maps a value emitted from PG into a value that typesense can
+// handle.
+func (m *Mapper) MapColumnValue(column schemalog.Column, value any) (any, error) {
+	f, err := m.columnToField(column)
+	if err != nil {
+		return nil, err
+	}
+
+	mapped, err := m.mapper.MapColumnValue(column, value)
+	if err != nil || mapped == nil {
+		return mapped, err
+	}
+
+	switch f.kind {
+	case valueDateTime:
+		switch v := mapped.(type) {
+		case string:
+			return parseEpochMillis(v)
+		case []string:
+			millis := make([]int64, 0, len(v))
+			for _, s := range v {
+				ms, err := parseEpochMillis(s)
+				if err != nil {
+					return nil, err
+				}
+				millis = append(millis, ms)
+			}
+			return millis, nil
+		}
+	case valueJSON:
+		if _, ok := mapped.(string); ok {
+			return mapped, nil
+		}
+		jsonValue, err := json.Marshal(mapped)
+		if err != nil {
+			return nil, fmt.Errorf("mapping json value to typesense string: %w", err)
+		}
+		return string(jsonValue), nil
+	}
+	return mapped, nil
+}
+
+func (m *Mapper) columnToField(column schemalog.Column) (*field, error) {
+	mapping, err := m.mapper.ColumnToSearchMapping(column)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &field{}
+	switch mapping["type"] {
+	case "long":
+		f.fieldType = typesense.FieldTypeInt64
+	case "double", "scaled_float":
+		f.fieldType = typesense.FieldTypeFloat
+	case "boolean":
+		f.fieldType = typesense.FieldTypeBool
+	case "keyword":
+		f.fieldType = typesense.FieldTypeString
+	case "text":
+		f.fieldType = typesense.FieldTypeString
+		if isJSONType(column.DataType) {
+			// the JSON values, including the arrays, are indexed as their
+			// string representation
+			return &field{
+				fieldType: typesense.FieldTypeString,
+				kind:      valueJSON,
+			}, nil
+		}
+	case "date":
+		format, _ := mapping["format"].(string)
+		if strings.HasPrefix(format, "HH") {
+			// time of day values keep their postgres string format
+			f.fieldType = typesense.FieldTypeString
+			break
+		}
+		f.fieldType = typesense.FieldTypeInt64
+		f.kind = valueDateTime
+	case "object":
+		f.fieldType = typesense.FieldTypeObject
+	case "knn_vector":
+		dimension, _ := mapping["dimension"].(int)
+		return &field{
+			fieldType: typesense.ArrayType(typesense.FieldTypeFloat),
+			numDim:    dimension,
+		}, nil
+	default:
+		// geometries and ranges have no typesense equivalent
+		return nil, search.ErrTypeInvalid{Input: column.DataType}
+	}
+
+	if strings.HasSuffix(column.DataType, "[]") {
+		f.fieldType = typesense.ArrayType(f.fieldType)
+	}
+	return f, nil
+}
+
+func parseEpochMillis(value string) (int64, error) {
+	for _, format := range dateTimeFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("mapping date time to typesense epoch millis: unexpected format: %s", value)
+}
+
+func isJSONType(dataType string) bool {
+	dataType = strings.TrimSuffix(dataType, "[]")
+	return dataType == "json" || dataType == "jsonb"
+}
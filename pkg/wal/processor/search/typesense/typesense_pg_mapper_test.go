@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestMapper_ColumnToSearchMapping(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dataType string
+
+		wantMapping map[string]any
+		wantErr     error
+	}{
+		{
+			name:        "integer",
+			dataType:    "int8",
+			wantMapping: map[string]any{"type": "int64"},
+		},
+		{
+			name:        "integer array",
+			dataType:    "int4[]",
+			wantMapping: map[string]any{"type": "int64[]"},
+		},
+		{
+			name:        "numeric",
+			dataType:    "numeric(10,2)",
+			wantMapping: map[string]any{"type": "float"},
+		},
+		{
+			name:        "boolean",
+			dataType:    "boolean",
+			wantMapping: map[string]any{"type": "bool"},
+		},
+		{
+			name:        "text",
+			dataType:    "text",
+			wantMapping: map[string]any{"type": "string"},
+		},
+		{
+			name:        "jsonb array",
+			dataType:    "jsonb[]",
+			wantMapping: map[string]any{"type": "string"},
+		},
+		{
+			name:        "timestamp with time zone",
+			dataType:    "timestamptz",
+			wantMapping: map[string]any{"type": "int64"},
+		},
+		{
+			name:        "date array",
+			dataType:    "date[]",
+			wantMapping: map[string]any{"type": "int64[]"},
+		},
+		{
+			name:        "time",
+			dataType:    "time",
+			wantMapping: map[string]any{"type": "string"},
+		},
+		{
+			name:        "vector",
+			dataType:    "vector(3)",
+			wantMapping: map[string]any{"type": "float[]", "num_dim": 3},
+		},
+		{
+			name:        "hstore",
+			dataType:    "hstore",
+			wantMapping: map[string]any{"type": "object"},
+		},
+		{
+			name:     "geometry",
+			dataType: "geometry(Point,4326)",
+			wantErr:  search.ErrTypeInvalid{Input: "geometry(Point,4326)"},
+		},
+		{
+			name:     "unknown type",
+			dataType: "unknown",
+			wantErr:  search.ErrTypeInvalid{Input: "unknown"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapper := NewPostgresMapper()
+			mapping, err := mapper.ColumnToSearchMapping(schemalog.Column{DataType: tc.dataType})
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantMapping, mapping)
+		})
+	}
+}
+
+func TestMapper_MapColumnValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dataType string
+		value    any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "timestamp with time zone",
+			dataType:  "timestamptz",
+			value:     "2024-04-30 08:15:30.123456+00",
+			wantValue: int64(1714464930123),
+		},
+		{
+			name:      "timestamp",
+			dataType:  "timestamp",
+			value:     "2024-04-30 08:15:30.123456",
+			wantValue: int64(1714464930123),
+		},
+		{
+			name:      "date array",
+			dataType:  "date[]",
+			value:     "{2024-04-30,2024-05-01}",
+			wantValue: []int64{1714435200000, 1714521600000},
+		},
+		{
+			name:      "json object",
+			dataType:  "jsonb",
+			value:     map[string]any{"a": 1},
+			wantValue: `{"a":1}`,
+		},
+		{
+			name:      "json string",
+			dataType:  "jsonb",
+			value:     `{"a":1}`,
+			wantValue: `{"a":1}`,
+		},
+		{
+			name:      "integer",
+			dataType:  "int8",
+			value:     int64(5),
+			wantValue: int64(5),
+		},
+		{
+			name:      "nil",
+			dataType:  "timestamptz",
+			value:     nil,
+			wantValue: nil,
+		},
+		{
+			name:     "unsupported type",
+			dataType: "int4range",
+			value:    "[1,5)",
+			wantErr:  search.ErrTypeInvalid{Input: "int4range"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mapper := NewPostgresMapper()
+			value, err := mapper.MapColumnValue(schemalog.Column{DataType: tc.dataType}, tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
@@ -0,0 +1,503 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xataio/pgstream/internal/typesense"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// Store is a typesense search store. Each schema is stored in a collection
+// with the schema name, with the documents of its tables distinguished by the
+// `_table` field. Typesense has no document versioning, so the document
+// versions are kept in the `_version` field and checked before writing them.
+type Store struct {
+	logger    loglib.Logger
+	client    typesense.Client
+	mapper    search.Mapper
+	marshaler func(any) ([]byte, error)
+}
+
+type Config struct {
+	URL    string
+	APIKey string
+	// IndexLayout and MappingConfigFile are the search store settings of the
+	// opensearch and elasticsearch stores. They're not supported, since each
+	// schema is stored in a single collection mapped by the store, and are
+	// only kept so that the configuration validation can reject them.
+	IndexLayout       string
+	MappingConfigFile string
+}
+
+type Option func(*Store)
+
+const (
+	schemalogCollectionName = "pgstream_schema_log"
+
+	tableField   = "_table"
+	versionField = "_version"
+)
+
+func NewStore(cfg Config, opts ...Option) (*Store, error) {
+	client, err := typesense.NewClient(typesense.Config{
+		URL:    cfg.URL,
+		APIKey: cfg.APIKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create typesense client: %w", err)
+	}
+
+	return NewStoreWithClient(client, opts...), nil
+}
+
+func NewStoreWithClient(client typesense.Client, opts ...Option) *Store {
+	s := &Store{
+		logger:    loglib.NewNoopLogger(),
+		client:    client,
+		mapper:    NewPostgresMapper(),
+		marshaler: json.Marshal,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *Store) {
+		s.logger = loglib.NewLogger(l)
+	}
+}
+
+func (s *Store) GetMapper() search.Mapper {
+	return s.mapper
+}
+
+func (s *Store) ApplySchemaChange(ctx context.Context, newEntry *schemalog.LogEntry) error {
+	if newEntry == nil {
+		return nil
+	}
+
+	existingLogEntry, err := s.getLastSchemaLogEntry(ctx, newEntry.SchemaName)
+	if err != nil && !errors.As(err, &search.ErrSchemaNotFound{}) {
+		return fmt.Errorf("get latest schema: %w", err)
+	}
+
+	// make sure the collection for the schema exists, and if it doesn't,
+	// create it with the latest schema log fields. This allows us to self
+	// recover in case of collection deletion.
+	collection, err := s.ensureCollection(ctx, newEntry.SchemaName, existingLogEntry)
+	if err != nil {
+		return fmt.Errorf("ensuring schema collection: %w", err)
+	}
+
+	// older schema should not be possible to receive
+	// We compare version field rather than id because XID is not actually sortable.
+	if existingLogEntry != nil && newEntry.Version <= existingLogEntry.Version {
+		return search.ErrSchemaUpdateOutOfOrder{
+			SchemaName:       newEntry.SchemaName,
+			SchemaID:         newEntry.ID.String(),
+			NewVersion:       int(newEntry.Version),
+			NewCreatedAt:     newEntry.CreatedAt.Time,
+			CurrentVersion:   int(existingLogEntry.Version),
+			CurrentCreatedAt: existingLogEntry.CreatedAt.Time,
+		}
+	}
+
+	diff := newEntry.Diff(existingLogEntry)
+	if err := s.updateCollection(ctx, collection, newEntry, existingLogEntry, diff); err != nil {
+		return fmt.Errorf("update collection for schema: %w", err)
+	}
+
+	if len(diff.TablesToRemove) > 0 {
+		tableIDs := make([]string, 0, len(diff.TablesToRemove))
+		for _, t := range diff.TablesToRemove {
+			tableIDs = append(tableIDs, t.PgstreamID)
+		}
+		if err := s.DeleteTableDocuments(ctx, newEntry.SchemaName, tableIDs); err != nil {
+			return fmt.Errorf("deleting removed tables documents: %w", err)
+		}
+	}
+
+	if err := s.insertSchemaLog(ctx, newEntry); err != nil {
+		return fmt.Errorf("failed to insert new schema log: %w", mapError(err))
+	}
+	return nil
+}
+
+func (s *Store) DeleteSchema(ctx context.Context, schemaName string) error {
+	if err := s.client.DeleteCollection(ctx, schemaName); err != nil && !errors.Is(err, typesense.ErrNotFound) {
+		return mapError(err)
+	}
+
+	// delete the schema from the schema log collection
+	if _, err := s.client.DeleteDocuments(ctx, schemalogCollectionName, "schema_name:="+typesense.FilterValues([]string{schemaName})); err != nil && !errors.Is(err, typesense.ErrNotFound) {
+		return mapError(err)
+	}
+	return nil
+}
+
+// CompleteSchemaMigration is a no-op, since the typesense collections are
+// updated in place.
+func (s *Store) CompleteSchemaMigration(ctx context.Context, schemaName string) error {
+	return nil
+}
+
+// ResumeSchemaMigrations is a no-op, since there are no typesense collection
+// migrations.
+func (s *Store) ResumeSchemaMigrations(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (s *Store) DeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string) error {
+	if len(tableIDs) == 0 {
+		return nil
+	}
+
+	if _, err := s.client.DeleteDocuments(ctx, schemaName, tableField+":="+typesense.FilterValues(tableIDs)); err != nil {
+		// nothing to delete if the schema has not been indexed yet
+		if errors.Is(err, typesense.ErrNotFound) {
+			return nil
+		}
+		return mapError(err)
+	}
+	return nil
+}
+
+// UpdateTableDocuments sets the fields on input in the existing documents of
+// the table with the ids on input. The documents that don't exist are ignored.
+func (s *Store) UpdateTableDocuments(ctx context.Context, schemaName, tableID string, docIDs []string, fields map[string]any) error {
+	for _, ids := range chunks(docIDs, typesense.MaxPerPage) {
+		filter := fmt.Sprintf("id:%s && %s:=%s", typesense.FilterValues(ids), tableField, typesense.FilterValues([]string{tableID}))
+		if _, err := s.client.UpdateDocuments(ctx, schemaName, filter, fields); err != nil {
+			// nothing to update if the schema has not been indexed yet
+			if errors.Is(err, typesense.ErrNotFound) {
+				return nil
+			}
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// SoftDeleteTableDocuments marks all the documents of the tables on input as
+// deleted at the time on input. Documents already deleted keep their original
+// deletion time.
+func (s *Store) SoftDeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string, deletedAt time.Time) error {
+	if len(tableIDs) == 0 {
+		return nil
+	}
+
+	filter := fmt.Sprintf("%s:=%s && %s:false", tableField, typesense.FilterValues(tableIDs), search.DeletedField)
+	if _, err := s.client.UpdateDocuments(ctx, schemaName, filter, map[string]any{
+		search.DeletedField:   true,
+		search.DeletedAtField: deletedAt.UnixMilli(),
+	}); err != nil {
+		// nothing to delete if the schema has not been indexed yet
+		if errors.Is(err, typesense.ErrNotFound) {
+			return nil
+		}
+		return mapError(err)
+	}
+	return nil
+}
+
+// PurgeDeletedDocuments removes the documents of the schema that were soft
+// deleted before the time on input.
+func (s *Store) PurgeDeletedDocuments(ctx context.Context, schemaName string, deletedBefore time.Time) error {
+	filter := fmt.Sprintf("%s:true && %s:<%d", search.DeletedField, search.DeletedAtField, deletedBefore.UnixMilli())
+	if _, err := s.client.DeleteDocuments(ctx, schemaName, filter); err != nil && !errors.Is(err, typesense.ErrNotFound) {
+		return mapError(err)
+	}
+	return nil
+}
+
+// getLastSchemaLogEntry will return the last version of the schemalog for the
+// schema on input. A search.ErrSchemaNotFound error is returned when there's
+// no existing associated logs.
+func (s *Store) getLastSchemaLogEntry(ctx context.Context, schemaName string) (*schemalog.LogEntry, error) {
+	res, err := s.client.SearchDocuments(ctx, schemalogCollectionName, &typesense.SearchRequest{
+		FilterBy: "schema_name:=" + typesense.FilterValues([]string{schemaName}),
+		SortBy:   "version:desc",
+		PerPage:  1,
+	})
+	if err != nil {
+		if errors.Is(err, typesense.ErrNotFound) {
+			s.logger.Warn(err, "collection not found, trying to create it", loglib.Fields{"collection": schemalogCollectionName})
+			if err := s.createSchemaLogCollection(ctx); err != nil {
+				return nil, mapError(err)
+			}
+			return nil, search.ErrSchemaNotFound{SchemaName: schemaName}
+		}
+		return nil, fmt.Errorf("get latest schema, failed to search typesense: %w", mapError(err))
+	}
+
+	if len(res.Hits) == 0 {
+		return nil, search.ErrSchemaNotFound{SchemaName: schemaName}
+	}
+
+	recBytes, err := s.marshaler(res.Hits[0].Document)
+	if err != nil {
+		return nil, fmt.Errorf("typesense record to schemalog entry: failed to marshal document: %w", err)
+	}
+	var logEntry schemalog.LogEntry
+	if err := json.Unmarshal(recBytes, &logEntry); err != nil {
+		return nil, fmt.Errorf("typesense record to schemalog entry: failed to unmarshal document: %w", err)
+	}
+	return &logEntry, nil
+}
+
+func (s *Store) createSchemaLogCollection(ctx context.Context) error {
+	// only the fields used to query the schema log are indexed, the rest are
+	// stored with the document
+	err := s.client.CreateCollection(ctx, &typesense.CollectionSchema{
+		Name: schemalogCollectionName,
+		Fields: []typesense.Field{
+			{Name: "schema_name", Type: typesense.FieldTypeString, Facet: true},
+			{Name: "version", Type: typesense.FieldTypeInt64},
+		},
+	})
+	if err != nil && !errors.Is(err, typesense.ErrAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) insertSchemaLog(ctx context.Context, logEntry *schemalog.LogEntry) error {
+	logBytes, err := s.marshaler(logEntry)
+	if err != nil {
+		return fmt.Errorf("insert schema log, failed to marshal document: %w", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(logBytes, &doc); err != nil {
+		return fmt.Errorf("insert schema log, failed to unmarshal document: %w", err)
+	}
+
+	results, err := s.client.ImportDocuments(ctx, schemalogCollectionName, []map[string]any{doc}, typesense.ImportUpsert)
+	if err != nil {
+		return fmt.Errorf("insert schema log: %w", err)
+	}
+	if len(results) > 0 && !results[0].Success {
+		return fmt.Errorf("insert schema log: %s", results[0].Error)
+	}
+	return nil
+}
+
+// ensureCollection returns the collection of the schema on input, creating it
+// with the fields of the schema log on input if it doesn't exist.
+func (s *Store) ensureCollection(ctx context.Context, schemaName string, logEntry *schemalog.LogEntry) (*typesense.CollectionSchema, error) {
+	collection, err := s.client.GetCollection(ctx, schemaName)
+	if err == nil {
+		return collection, nil
+	}
+	if !errors.Is(err, typesense.ErrNotFound) {
+		return nil, mapError(err)
+	}
+
+	collection = &typesense.CollectionSchema{
+		Name:               schemaName,
+		Fields:             baseFields(),
+		EnableNestedFields: true,
+	}
+	if logEntry != nil {
+		for _, table := range logEntry.Schema.Tables {
+			for _, c := range table.Columns {
+				f, err := s.columnField(schemaName, c)
+				if err != nil {
+					return nil, err
+				}
+				if f != nil {
+					collection.Fields = append(collection.Fields, *f)
+				}
+			}
+		}
+	}
+
+	if err := s.client.CreateCollection(ctx, collection); err != nil {
+		// the collection has been created in the meantime
+		if errors.Is(err, typesense.ErrAlreadyExists) {
+			collection, err := s.client.GetCollection(ctx, schemaName)
+			if err != nil {
+				return nil, mapError(err)
+			}
+			return collection, nil
+		}
+		return nil, mapError(err)
+	}
+	return collection, nil
+}
+
+// baseFields are the fields of every schema collection. The `_deleted` field
+// is set on all the documents, so that the ones not deleted can be filtered.
+func baseFields() []typesense.Field {
+	return []typesense.Field{
+		{Name: tableField, Type: typesense.FieldTypeString, Facet: true},
+		{Name: versionField, Type: typesense.FieldTypeInt64},
+		{Name: search.DeletedField, Type: typesense.FieldTypeBool},
+		{Name: search.DeletedAtField, Type: typesense.FieldTypeInt64, Optional: true},
+	}
+}
+
+// updateCollection applies the schema diff on input to the collection fields.
+// The values of the removed and retyped columns are cleared from the table
+// documents, since typesense validates the existing documents against the new
+// field types.
+func (s *Store) updateCollection(ctx context.Context, collection *typesense.CollectionSchema, newEntry, existingEntry *schemalog.LogEntry, diff *schemalog.SchemaDiff) error {
+	existingFields := make(map[string]typesense.Field, len(collection.Fields))
+	for _, f := range collection.Fields {
+		existingFields[f.Name] = f
+	}
+
+	var addFields, dropFields []typesense.Field
+	// clearedColumns are the column ids whose values are removed from the
+	// documents, by table pgstream id
+	clearedColumns := map[string][]string{}
+
+	addColumn := func(c schemalog.Column) error {
+		f, err := s.columnField(collection.Name, c)
+		if err != nil {
+			return err
+		}
+		existing, found := existingFields[c.PgstreamID]
+		switch {
+		case found && f != nil && existing.Type == f.Type:
+		case found:
+			dropFields = append(dropFields, typesense.Field{Name: c.PgstreamID, Drop: true})
+			if tableID := columnTableID(newEntry, c.PgstreamID); tableID != "" {
+				clearedColumns[tableID] = append(clearedColumns[tableID], c.PgstreamID)
+			}
+			if f != nil {
+				addFields = append(addFields, *f)
+			}
+		case f != nil:
+			addFields = append(addFields, *f)
+		}
+		return nil
+	}
+
+	for _, c := range diff.ColumnsToAdd {
+		if err := addColumn(c); err != nil {
+			return err
+		}
+	}
+	// renamed columns keep their pgstream id, which is used as the document
+	// field name, so they don't require any changes.
+	for _, change := range diff.ColumnTypeChange {
+		if err := addColumn(change.New); err != nil {
+			return err
+		}
+	}
+	for _, c := range diff.ColumnsToRemove {
+		if _, found := existingFields[c.PgstreamID]; !found {
+			continue
+		}
+		dropFields = append(dropFields, typesense.Field{Name: c.PgstreamID, Drop: true})
+		if tableID := columnTableID(existingEntry, c.PgstreamID); tableID != "" {
+			clearedColumns[tableID] = append(clearedColumns[tableID], c.PgstreamID)
+		}
+	}
+	// the documents of the removed tables are deleted, so their values don't
+	// need to be cleared
+	for _, t := range diff.TablesToRemove {
+		for _, c := range t.Columns {
+			if _, found := existingFields[c.PgstreamID]; found {
+				dropFields = append(dropFields, typesense.Field{Name: c.PgstreamID, Drop: true})
+			}
+		}
+	}
+
+	if len(dropFields) > 0 {
+		if err := s.client.UpdateCollection(ctx, collection.Name, dropFields); err != nil {
+			return fmt.Errorf("failed to drop fields: %w", mapError(err))
+		}
+	}
+	for tableID, columns := range clearedColumns {
+		fields := make(map[string]any, len(columns))
+		for _, c := range columns {
+			fields[c] = nil
+		}
+		filter := tableField + ":=" + typesense.FilterValues([]string{tableID})
+		if _, err := s.client.UpdateDocuments(ctx, collection.Name, filter, fields); err != nil {
+			return fmt.Errorf("failed to clear column values: %w", mapError(err))
+		}
+	}
+	if len(addFields) > 0 {
+		if err := s.client.UpdateCollection(ctx, collection.Name, addFields); err != nil {
+			return fmt.Errorf("failed to add new fields: %w", mapError(err))
+		}
+	}
+	return nil
+}
+
+// columnField returns the collection field for the column on input, or nil if
+// the column type is not supported.
+func (s *Store) columnField(schemaName string, c schemalog.Column) (*typesense.Field, error) {
+	mapping, err := s.mapper.ColumnToSearchMapping(c)
+	if err != nil {
+		if errors.As(err, &search.ErrTypeInvalid{}) {
+			s.logger.Warn(err, "unknown column type", loglib.Fields{
+				"column": map[string]any{
+					"type": c.DataType,
+					"id":   c.PgstreamID,
+				},
+				"schema": schemaName,
+			})
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to convert column to search mapping: %w", err)
+	}
+
+	fieldType, _ := mapping["type"].(string)
+	numDim, _ := mapping["num_dim"].(int)
+	return &typesense.Field{
+		Name: c.PgstreamID,
+		Type: fieldType,
+		// the columns are not set on the documents of other tables, and can
+		// be null
+		Optional: true,
+		NumDim:   numDim,
+	}, nil
+}
+
+// columnTableID returns the pgstream id of the table with the column on input
+// in the schema log, or an empty string if it's not found.
+func columnTableID(logEntry *schemalog.LogEntry, columnID string) string {
+	if logEntry == nil {
+		return ""
+	}
+	for _, t := range logEntry.Schema.Tables {
+		for _, c := range t.Columns {
+			if c.PgstreamID == columnID {
+				return t.PgstreamID
+			}
+		}
+	}
+	return ""
+}
+
+func chunks(values []string, size int) [][]string {
+	var result [][]string
+	for len(values) > size {
+		result = append(result, values[:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		result = append(result, values)
+	}
+	return result
+}
+
+func mapError(err error) error {
+	if errors.As(err, &typesense.RetryableError{}) {
+		return fmt.Errorf("%w: %v", search.ErrRetriable, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,370 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typesense
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/typesense"
+	typesensemocks "github.com/xataio/pgstream/internal/typesense/mocks"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+const testSchemaName = "test_schema"
+
+var errTest = errors.New("oh noes")
+
+func testSchemaLogHit(t *testing.T, entry *schemalog.LogEntry) *typesense.SearchResponse {
+	b, err := json.Marshal(entry)
+	require.NoError(t, err)
+	doc := map[string]any{}
+	require.NoError(t, json.Unmarshal(b, &doc))
+	return &typesense.SearchResponse{Found: 1, Hits: []typesense.SearchHit{{Document: doc}}}
+}
+
+func TestStore_ApplySchemaChange(t *testing.T) {
+	t.Parallel()
+
+	id := xid.New()
+	existingEntry := &schemalog.LogEntry{
+		ID:         id,
+		SchemaName: testSchemaName,
+		Version:    1,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					Name:       "users",
+					PgstreamID: "t1",
+					Columns: []schemalog.Column{
+						{Name: "name", DataType: "text", PgstreamID: "t1-1"},
+						{Name: "age", DataType: "int4", PgstreamID: "t1-2"},
+						{Name: "nickname", DataType: "text", PgstreamID: "t1-3"},
+					},
+				},
+				{
+					Name:       "orders",
+					PgstreamID: "t2",
+					Columns: []schemalog.Column{
+						{Name: "total", DataType: "float8", PgstreamID: "t2-1"},
+					},
+				},
+			},
+		},
+	}
+	newEntry := &schemalog.LogEntry{
+		ID:         xid.New(),
+		SchemaName: testSchemaName,
+		Version:    2,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					Name:       "users",
+					PgstreamID: "t1",
+					Columns: []schemalog.Column{
+						{Name: "name", DataType: "text", PgstreamID: "t1-1"},
+						// retyped
+						{Name: "age", DataType: "text", PgstreamID: "t1-2"},
+						// added
+						{Name: "created_at", DataType: "timestamptz", PgstreamID: "t1-4"},
+					},
+				},
+			},
+		},
+	}
+	existingCollection := &typesense.CollectionSchema{
+		Name: testSchemaName,
+		Fields: append(baseFields(),
+			typesense.Field{Name: "t1-1", Type: "string", Optional: true},
+			typesense.Field{Name: "t1-2", Type: "int64", Optional: true},
+			typesense.Field{Name: "t1-3", Type: "string", Optional: true},
+			typesense.Field{Name: "t2-1", Type: "float", Optional: true},
+		),
+	}
+
+	tests := []struct {
+		name     string
+		client   func(t *testing.T) *typesensemocks.Client
+		logEntry *schemalog.LogEntry
+
+		wantErr error
+	}{
+		{
+			name: "ok - nil entry",
+			client: func(t *testing.T) *typesensemocks.Client {
+				return &typesensemocks.Client{}
+			},
+			logEntry: nil,
+		},
+		{
+			name: "ok - new schema",
+			client: func(t *testing.T) *typesensemocks.Client {
+				return &typesensemocks.Client{
+					SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+						require.Equal(t, schemalogCollectionName, collection)
+						require.Equal(t, "schema_name:=[`test_schema`]", req.FilterBy)
+						require.Equal(t, "version:desc", req.SortBy)
+						return nil, typesense.ErrNotFound
+					},
+					CreateCollectionFn: func(ctx context.Context, schema *typesense.CollectionSchema) error {
+						switch schema.Name {
+						case schemalogCollectionName:
+						case testSchemaName:
+							require.Equal(t, &typesense.CollectionSchema{
+								Name:               testSchemaName,
+								Fields:             baseFields(),
+								EnableNestedFields: true,
+							}, schema)
+						default:
+							return fmt.Errorf("unexpected collection: %s", schema.Name)
+						}
+						return nil
+					},
+					GetCollectionFn: func(ctx context.Context, name string) (*typesense.CollectionSchema, error) {
+						return nil, typesense.ErrNotFound
+					},
+					UpdateCollectionFn: func(ctx context.Context, name string, fields []typesense.Field) error {
+						require.Equal(t, testSchemaName, name)
+						require.ElementsMatch(t, []typesense.Field{
+							{Name: "t1-1", Type: "string", Optional: true},
+							{Name: "t1-2", Type: "int64", Optional: true},
+							{Name: "t1-3", Type: "string", Optional: true},
+							{Name: "t2-1", Type: "float", Optional: true},
+						}, fields)
+						return nil
+					},
+					ImportDocumentsFn: func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+						require.Equal(t, schemalogCollectionName, collection)
+						require.Equal(t, typesense.ImportUpsert, action)
+						require.Len(t, docs, 1)
+						require.Equal(t, id.String(), docs[0]["id"])
+						return []typesense.ImportResult{{Success: true}}, nil
+					},
+				}
+			},
+			logEntry: existingEntry,
+		},
+		{
+			name: "ok - schema update",
+			client: func(t *testing.T) *typesensemocks.Client {
+				updateCalls := 0
+				return &typesensemocks.Client{
+					SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+						return testSchemaLogHit(t, existingEntry), nil
+					},
+					GetCollectionFn: func(ctx context.Context, name string) (*typesense.CollectionSchema, error) {
+						return existingCollection, nil
+					},
+					UpdateCollectionFn: func(ctx context.Context, name string, fields []typesense.Field) error {
+						defer func() { updateCalls++ }()
+						switch updateCalls {
+						case 0:
+							require.ElementsMatch(t, []typesense.Field{
+								{Name: "t1-2", Drop: true},
+								{Name: "t1-3", Drop: true},
+								{Name: "t2-1", Drop: true},
+							}, fields)
+						case 1:
+							require.ElementsMatch(t, []typesense.Field{
+								{Name: "t1-2", Type: "string", Optional: true},
+								{Name: "t1-4", Type: "int64", Optional: true},
+							}, fields)
+						default:
+							return fmt.Errorf("unexpected update collection call")
+						}
+						return nil
+					},
+					UpdateDocumentsFn: func(ctx context.Context, collection, filterBy string, fields map[string]any) (int, error) {
+						require.Equal(t, testSchemaName, collection)
+						require.Equal(t, "_table:=[`t1`]", filterBy)
+						require.Equal(t, map[string]any{"t1-2": nil, "t1-3": nil}, fields)
+						return 1, nil
+					},
+					DeleteDocumentsFn: func(ctx context.Context, collection, filterBy string) (int, error) {
+						require.Equal(t, testSchemaName, collection)
+						require.Equal(t, "_table:=[`t2`]", filterBy)
+						return 1, nil
+					},
+					ImportDocumentsFn: func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+						return []typesense.ImportResult{{Success: true}}, nil
+					},
+				}
+			},
+			logEntry: newEntry,
+		},
+		{
+			name: "error - schema update out of order",
+			client: func(t *testing.T) *typesensemocks.Client {
+				return &typesensemocks.Client{
+					SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+						return testSchemaLogHit(t, newEntry), nil
+					},
+					GetCollectionFn: func(ctx context.Context, name string) (*typesense.CollectionSchema, error) {
+						return existingCollection, nil
+					},
+				}
+			},
+			logEntry: existingEntry,
+
+			wantErr: search.ErrSchemaUpdateOutOfOrder{
+				SchemaName:     testSchemaName,
+				SchemaID:       id.String(),
+				NewVersion:     1,
+				CurrentVersion: 2,
+			},
+		},
+		{
+			name: "error - getting last schema log",
+			client: func(t *testing.T) *typesensemocks.Client {
+				return &typesensemocks.Client{
+					SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+						return nil, typesense.RetryableError{Cause: errTest}
+					},
+				}
+			},
+			logEntry: existingEntry,
+
+			wantErr: search.ErrRetriable,
+		},
+		{
+			name: "error - inserting schema log",
+			client: func(t *testing.T) *typesensemocks.Client {
+				return &typesensemocks.Client{
+					SearchDocumentsFn: func(ctx context.Context, collection string, req *typesense.SearchRequest) (*typesense.SearchResponse, error) {
+						return testSchemaLogHit(t, existingEntry), nil
+					},
+					GetCollectionFn: func(ctx context.Context, name string) (*typesense.CollectionSchema, error) {
+						return existingCollection, nil
+					},
+					UpdateCollectionFn: func(ctx context.Context, name string, fields []typesense.Field) error { return nil },
+					UpdateDocumentsFn: func(ctx context.Context, collection, filterBy string, fields map[string]any) (int, error) {
+						return 0, nil
+					},
+					DeleteDocumentsFn: func(ctx context.Context, collection, filterBy string) (int, error) { return 0, nil },
+					ImportDocumentsFn: func(ctx context.Context, collection string, docs []map[string]any, action typesense.ImportAction) ([]typesense.ImportResult, error) {
+						return nil, errTest
+					},
+				}
+			},
+			logEntry: newEntry,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewStoreWithClient(tc.client(t))
+			err := store.ApplySchemaChange(context.Background(), tc.logEntry)
+			if outOfOrderErr := (search.ErrSchemaUpdateOutOfOrder{}); errors.As(err, &outOfOrderErr) {
+				// the created at timestamps are not relevant for the test
+				outOfOrderErr.NewCreatedAt = time.Time{}
+				outOfOrderErr.CurrentCreatedAt = time.Time{}
+				require.Equal(t, tc.wantErr, outOfOrderErr)
+				return
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestStore_DeleteSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		client *typesensemocks.Client
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			client: &typesensemocks.Client{
+				DeleteCollectionFn: func(ctx context.Context, name string) error {
+					require.Equal(t, testSchemaName, name)
+					return nil
+				},
+				DeleteDocumentsFn: func(ctx context.Context, collection, filterBy string) (int, error) {
+					require.Equal(t, schemalogCollectionName, collection)
+					require.Equal(t, "schema_name:=[`test_schema`]", filterBy)
+					return 2, nil
+				},
+			},
+		},
+		{
+			name: "ok - collections not found",
+			client: &typesensemocks.Client{
+				DeleteCollectionFn: func(ctx context.Context, name string) error {
+					return typesense.ErrNotFound
+				},
+				DeleteDocumentsFn: func(ctx context.Context, collection, filterBy string) (int, error) {
+					return 0, typesense.ErrNotFound
+				},
+			},
+		},
+		{
+			name: "error - deleting collection",
+			client: &typesensemocks.Client{
+				DeleteCollectionFn: func(ctx context.Context, name string) error {
+					return errTest
+				},
+			},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := NewStoreWithClient(tc.client)
+			err := store.DeleteSchema(context.Background(), testSchemaName)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestStore_SoftDeleteTableDocuments(t *testing.T) {
+	t.Parallel()
+
+	deletedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := NewStoreWithClient(&typesensemocks.Client{
+		UpdateDocumentsFn: func(ctx context.Context, collection, filterBy string, fields map[string]any) (int, error) {
+			require.Equal(t, testSchemaName, collection)
+			require.Equal(t, "_table:=[`t1`,`t2`] && _deleted:false", filterBy)
+			require.Equal(t, map[string]any{
+				search.DeletedField:   true,
+				search.DeletedAtField: deletedAt.UnixMilli(),
+			}, fields)
+			return 1, nil
+		},
+	})
+	err := store.SoftDeleteTableDocuments(context.Background(), testSchemaName, []string{"t1", "t2"}, deletedAt)
+	require.NoError(t, err)
+}
+
+func TestStore_PurgeDeletedDocuments(t *testing.T) {
+	t.Parallel()
+
+	deletedBefore := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := NewStoreWithClient(&typesensemocks.Client{
+		DeleteDocumentsFn: func(ctx context.Context, collection, filterBy string) (int, error) {
+			require.Equal(t, testSchemaName, collection)
+			require.Equal(t, "_deleted:true && _deleted_at:<1714557600000", filterBy)
+			return 0, typesense.ErrNotFound
+		},
+	})
+	err := store.PurgeDeletedDocuments(context.Background(), testSchemaName, deletedBefore)
+	require.NoError(t, err)
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+type mockRowSource struct {
+	schemaLogsFn   func(ctx context.Context, schemas []string) ([]*schemalog.LogEntry, error)
+	countRowsFn    func(ctx context.Context, t *table) (int, error)
+	sampleRowsFn   func(ctx context.Context, t *table, size int) ([]row, error)
+	scanRowsFn     func(ctx context.Context, t *table, batchSize int, fn func([]row) error) error
+	existingRowsFn func(ctx context.Context, t *table, docIDs []string) (map[string]struct{}, error)
+	touchRowsFn    func(ctx context.Context, t *table, docIDs []string) (int, error)
+}
+
+func (m *mockRowSource) schemaLogs(ctx context.Context, schemas []string) ([]*schemalog.LogEntry, error) {
+	return m.schemaLogsFn(ctx, schemas)
+}
+
+func (m *mockRowSource) countRows(ctx context.Context, t *table) (int, error) {
+	return m.countRowsFn(ctx, t)
+}
+
+func (m *mockRowSource) sampleRows(ctx context.Context, t *table, size int) ([]row, error) {
+	return m.sampleRowsFn(ctx, t, size)
+}
+
+func (m *mockRowSource) scanRows(ctx context.Context, t *table, batchSize int, fn func([]row) error) error {
+	return m.scanRowsFn(ctx, t, batchSize, fn)
+}
+
+func (m *mockRowSource) existingRows(ctx context.Context, t *table, docIDs []string) (map[string]struct{}, error) {
+	return m.existingRowsFn(ctx, t, docIDs)
+}
+
+func (m *mockRowSource) touchRows(ctx context.Context, t *table, docIDs []string) (int, error) {
+	return m.touchRowsFn(ctx, t, docIDs)
+}
+
+func (m *mockRowSource) close() error {
+	return nil
+}
+
+type mockSearchReader struct {
+	countTableDocumentsFn      func(ctx context.Context, schemaName, tableID string) (int, error)
+	getTableDocumentVersionsFn func(ctx context.Context, schemaName, tableID string, ids []string) (map[string]int, error)
+	sampleTableDocumentsFn     func(ctx context.Context, schemaName, tableID string, size int) (map[string]int, error)
+	scanTableDocumentsFn       func(ctx context.Context, schemaName, tableID string, batchSize int, fn func(map[string]int) error) error
+}
+
+func (m *mockSearchReader) CountTableDocuments(ctx context.Context, schemaName, tableID string) (int, error) {
+	return m.countTableDocumentsFn(ctx, schemaName, tableID)
+}
+
+func (m *mockSearchReader) GetTableDocumentVersions(ctx context.Context, schemaName, tableID string, ids []string) (map[string]int, error) {
+	return m.getTableDocumentVersionsFn(ctx, schemaName, tableID, ids)
+}
+
+func (m *mockSearchReader) SampleTableDocuments(ctx context.Context, schemaName, tableID string, size int) (map[string]int, error) {
+	return m.sampleTableDocumentsFn(ctx, schemaName, tableID, size)
+}
+
+func (m *mockSearchReader) ScanTableDocuments(ctx context.Context, schemaName, tableID string, batchSize int, fn func(map[string]int) error) error {
+	return m.scanTableDocumentsFn(ctx, schemaName, tableID, batchSize, fn)
+}
+
+// mockStore only implements the search store document operations used by the
+// verifier.
+type mockStore struct {
+	search.Store
+	sendDocumentsFn func(ctx context.Context, docs []search.Document) ([]search.DocumentError, error)
+}
+
+func (m *mockStore) SendDocuments(ctx context.Context, docs []search.Document) ([]search.DocumentError, error) {
+	return m.sendDocumentsFn(ctx, docs)
+}
+
+var errTest = errors.New("oh noes")
+
+func intPtr(i int) *int {
+	return &i
+}
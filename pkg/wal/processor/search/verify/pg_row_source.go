@@ -0,0 +1,289 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogpg "github.com/xataio/pgstream/pkg/schemalog/postgres"
+)
+
+// pgRowSource reads the rows of the source postgres tables, and the schema log
+// the search documents have been indexed with.
+type pgRowSource struct {
+	conn           pglib.Querier
+	schemaLogStore schemalog.Store
+}
+
+// table is a source table, along with the columns that make up the search
+// document ids and versions.
+type table struct {
+	schema string
+	name   string
+	// id is the table pgstream id, which prefixes the document ids
+	id        string
+	idColumns []schemalog.Column
+	// versionColumn is nil when the documents are versioned with the WAL LSN
+	versionColumn *schemalog.Column
+}
+
+// row is the search document id and version of a table row.
+type row struct {
+	docID   string
+	version *int
+}
+
+func newPGRowSource(ctx context.Context, url string) (*pgRowSource, error) {
+	pool, err := pglib.NewConnPool(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("create postgres connection pool: %w", err)
+	}
+	return &pgRowSource{
+		conn:           pool,
+		schemaLogStore: schemalogpg.NewStoreWithQuerier(pool),
+	}, nil
+}
+
+// schemaLogs returns the latest acked schema log of the schemas on input, or of
+// all the schemas in the schema log if none are provided. The acked schema log
+// is the one the search store has applied.
+func (s *pgRowSource) schemaLogs(ctx context.Context, schemas []string) ([]*schemalog.LogEntry, error) {
+	if len(schemas) == 0 {
+		var err error
+		if schemas, err = s.schemaNames(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	logEntries := make([]*schemalog.LogEntry, 0, len(schemas))
+	for _, schema := range schemas {
+		logEntry, err := s.schemaLogStore.Fetch(ctx, schema, true)
+		if err != nil {
+			if errors.Is(err, schemalog.ErrNoRows) {
+				continue
+			}
+			return nil, err
+		}
+		logEntries = append(logEntries, logEntry)
+	}
+	return logEntries, nil
+}
+
+func (s *pgRowSource) schemaNames(ctx context.Context) ([]string, error) {
+	query := fmt.Sprintf(`SELECT DISTINCT schema_name FROM %s.%s WHERE acked ORDER BY schema_name`, schemalog.SchemaName, schemalog.TableName)
+	rows, err := s.conn.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("querying schema log schemas: %w", err)
+	}
+	defer rows.Close()
+
+	schemas := []string{}
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, fmt.Errorf("scanning schema log schema: %w", err)
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+func (s *pgRowSource) countRows(ctx context.Context, t *table) (int, error) {
+	var count int
+	query := fmt.Sprintf(`SELECT count(*) FROM %s`, t.quotedName())
+	if err := s.conn.QueryRow(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting rows: %w", err)
+	}
+	return count, nil
+}
+
+// sampleRows returns up to size random rows of the table.
+func (s *pgRowSource) sampleRows(ctx context.Context, t *table, size int) ([]row, error) {
+	var rows []row
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY random() LIMIT %d`, t.rowColumns(), t.quotedName(), size)
+	err := s.queryRows(ctx, query, nil, size, func(batch []row) error {
+		rows = batch
+		return nil
+	})
+	return rows, err
+}
+
+// scanRows calls fn with all the table rows, in batches of up to batchSize
+// rows.
+func (s *pgRowSource) scanRows(ctx context.Context, t *table, batchSize int, fn func([]row) error) error {
+	query := fmt.Sprintf(`SELECT %s FROM %s`, t.rowColumns(), t.quotedName())
+	return s.queryRows(ctx, query, nil, batchSize, fn)
+}
+
+// existingRows returns the document ids on input that belong to an existing
+// row of the table.
+func (s *pgRowSource) existingRows(ctx context.Context, t *table, docIDs []string) (map[string]struct{}, error) {
+	existing := map[string]struct{}{}
+	ids := t.rowIDs(docIDs)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s`, t.rowColumns(), t.quotedName(), t.idCondition())
+	err := s.queryRows(ctx, query, []any{ids}, len(ids), func(batch []row) error {
+		for _, r := range batch {
+			existing[r.docID] = struct{}{}
+		}
+		return nil
+	})
+	return existing, err
+}
+
+// touchRows updates the table rows with the document ids on input without
+// modifying them, so that their WAL events are emitted again and the
+// documents re-indexed. It returns the number of rows updated.
+func (s *pgRowSource) touchRows(ctx context.Context, t *table, docIDs []string) (int, error) {
+	ids := t.rowIDs(docIDs)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	col := pgx.Identifier{t.idColumns[0].Name}.Sanitize()
+	query := fmt.Sprintf(`UPDATE %s SET %s = %s WHERE %s`, t.quotedName(), col, col, t.idCondition())
+	tag, err := s.conn.Exec(ctx, query, ids)
+	if err != nil {
+		return 0, fmt.Errorf("touching rows: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (s *pgRowSource) close() error {
+	return s.conn.Close(context.Background())
+}
+
+func (s *pgRowSource) queryRows(ctx context.Context, query string, args []any, batchSize int, fn func([]row) error) error {
+	rows, err := s.conn.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("querying rows: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]row, 0, batchSize)
+	for rows.Next() {
+		r := row{}
+		var version *int64
+		if err := rows.Scan(&r.docID, &version); err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+		if version != nil {
+			v := int(*version)
+			r.version = &v
+		}
+		batch = append(batch, r)
+
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]row, 0, batchSize)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading rows: %w", err)
+	}
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return fn(batch)
+}
+
+// newTable returns the table on input along with its document id and version
+// columns. The id columns are the same used by the WAL translator by default:
+// the primary key, or the first unique not null column if there's none. It
+// returns nil if the table has no id columns, since its rows are not indexed.
+func newTable(schema string, t *schemalog.Table, versionColumn string) *table {
+	idColumns := []schemalog.Column{}
+	uniqueNotNull := t.GetFirstUniqueNotNullColumn()
+	for _, c := range t.Columns {
+		switch {
+		case len(t.PrimaryKeyColumns) > 0 && slices.Contains(t.PrimaryKeyColumns, c.Name):
+			idColumns = append(idColumns, c)
+		case len(t.PrimaryKeyColumns) == 0 && uniqueNotNull != nil && c.Name == uniqueNotNull.Name:
+			idColumns = append(idColumns, c)
+		}
+	}
+	if len(idColumns) == 0 {
+		return nil
+	}
+
+	tbl := &table{
+		schema:    schema,
+		name:      t.Name,
+		id:        t.PgstreamID,
+		idColumns: idColumns,
+	}
+	if versionColumn != "" {
+		tbl.versionColumn = t.GetColumnByName(versionColumn)
+	}
+	return tbl
+}
+
+func (t *table) quotedName() string {
+	return pgx.Identifier{t.schema, t.name}.Sanitize()
+}
+
+// rowColumns returns the select expressions of the row document id and
+// version. The id matches the one built by the search adapter, with the id
+// column values joined by '-' and prefixed by the table pgstream id.
+func (t *table) rowColumns() string {
+	version := "NULL::bigint"
+	if t.versionColumn != nil {
+		// the search adapter rounds decimal versions
+		version = fmt.Sprintf("round(%s::numeric)::bigint", pgx.Identifier{t.versionColumn.Name}.Sanitize())
+	}
+	return fmt.Sprintf("%s || '_' || %s, %s", quoteLiteral(t.id), t.idExpression(), version)
+}
+
+// idExpression returns the expression of the row id, without the table
+// prefix.
+func (t *table) idExpression() string {
+	if len(t.idColumns) == 1 {
+		return pgx.Identifier{t.idColumns[0].Name}.Sanitize() + "::text"
+	}
+	cols := make([]string, 0, len(t.idColumns))
+	for _, c := range t.idColumns {
+		cols = append(cols, pgx.Identifier{c.Name}.Sanitize()+"::text")
+	}
+	return fmt.Sprintf("concat_ws('-', %s)", strings.Join(cols, ", "))
+}
+
+// idCondition returns the condition that matches the rows with the ids in the
+// first query parameter. Single id columns are compared in their own type, so
+// that the column index can be used.
+func (t *table) idCondition() string {
+	if len(t.idColumns) == 1 {
+		c := t.idColumns[0]
+		return fmt.Sprintf("%s = ANY($1::text[]::%s[])", pgx.Identifier{c.Name}.Sanitize(), c.DataType)
+	}
+	return fmt.Sprintf("%s = ANY($1::text[])", t.idExpression())
+}
+
+// rowIDs returns the row ids of the document ids on input, ignoring the ones
+// that don't belong to the table.
+func (t *table) rowIDs(docIDs []string) []string {
+	prefix := t.id + "_"
+	ids := make([]string, 0, len(docIDs))
+	for _, docID := range docIDs {
+		if id, found := strings.CutPrefix(docID, prefix); found {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
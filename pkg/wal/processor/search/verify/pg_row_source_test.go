@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+)
+
+func TestNewTable(t *testing.T) {
+	t.Parallel()
+
+	idCol := schemalog.Column{Name: "id", DataType: "uuid", PgstreamID: "t1-1"}
+	tenantCol := schemalog.Column{Name: "tenant", DataType: "text", PgstreamID: "t1-2"}
+	emailCol := schemalog.Column{Name: "email", DataType: "text", Unique: true, PgstreamID: "t1-3"}
+	versionCol := schemalog.Column{Name: "version", DataType: "bigint", PgstreamID: "t1-4"}
+
+	tests := []struct {
+		name          string
+		table         *schemalog.Table
+		versionColumn string
+
+		wantTable       *table
+		wantRowColumns  string
+		wantIDCondition string
+	}{
+		{
+			name: "primary key",
+			table: &schemalog.Table{
+				Name:              "users",
+				PgstreamID:        "t1",
+				Columns:           []schemalog.Column{idCol, emailCol, versionCol},
+				PrimaryKeyColumns: []string{"id"},
+			},
+			versionColumn: "version",
+
+			wantTable: &table{
+				schema:        "public",
+				name:          "users",
+				id:            "t1",
+				idColumns:     []schemalog.Column{idCol},
+				versionColumn: &versionCol,
+			},
+			wantRowColumns:  `'t1' || '_' || "id"::text, round("version"::numeric)::bigint`,
+			wantIDCondition: `"id" = ANY($1::text[]::uuid[])`,
+		},
+		{
+			name: "composite primary key in column order",
+			table: &schemalog.Table{
+				Name:              "users",
+				PgstreamID:        "t1",
+				Columns:           []schemalog.Column{tenantCol, idCol, emailCol},
+				PrimaryKeyColumns: []string{"id", "tenant"},
+			},
+
+			wantTable: &table{
+				schema:    "public",
+				name:      "users",
+				id:        "t1",
+				idColumns: []schemalog.Column{tenantCol, idCol},
+			},
+			wantRowColumns:  `'t1' || '_' || concat_ws('-', "tenant"::text, "id"::text), NULL::bigint`,
+			wantIDCondition: `concat_ws('-', "tenant"::text, "id"::text) = ANY($1::text[])`,
+		},
+		{
+			name: "unique not null column",
+			table: &schemalog.Table{
+				Name:       "users",
+				PgstreamID: "t1",
+				Columns:    []schemalog.Column{tenantCol, emailCol},
+			},
+
+			wantTable: &table{
+				schema:    "public",
+				name:      "users",
+				id:        "t1",
+				idColumns: []schemalog.Column{emailCol},
+			},
+			wantRowColumns:  `'t1' || '_' || "email"::text, NULL::bigint`,
+			wantIDCondition: `"email" = ANY($1::text[]::text[])`,
+		},
+		{
+			name: "no id columns",
+			table: &schemalog.Table{
+				Name:       "users",
+				PgstreamID: "t1",
+				Columns:    []schemalog.Column{tenantCol},
+			},
+
+			wantTable: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tbl := newTable("public", tc.table, tc.versionColumn)
+			require.Equal(t, tc.wantTable, tbl)
+			if tbl == nil {
+				return
+			}
+			require.Equal(t, tc.wantRowColumns, tbl.rowColumns())
+			require.Equal(t, tc.wantIDCondition, tbl.idCondition())
+		})
+	}
+}
+
+func TestTable_rowIDs(t *testing.T) {
+	t.Parallel()
+
+	tbl := &table{id: "t1"}
+	ids := tbl.rowIDs([]string{"t1_1", "t1_a-b", "t2_1", "t1"})
+	require.Equal(t, []string{"1", "a-b"}, ids)
+}
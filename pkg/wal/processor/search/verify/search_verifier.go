@@ -0,0 +1,415 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+// Verifier compares the postgres tables with the documents indexed in the
+// search store, to detect the documents that drifted from the table rows.
+type Verifier struct {
+	source rowSource
+	reader SearchReader
+	store  search.Store
+	logger loglib.Logger
+
+	schemas       []string
+	sampleSize    int
+	batchSize     int
+	versionColumn string
+	repair        bool
+	interval      time.Duration
+}
+
+type Config struct {
+	// PostgresURL is the URL of the source database, where the schema log is
+	// kept.
+	PostgresURL string
+	// Schemas are the schemas to verify. Defaults to all the schemas in the
+	// schema log.
+	Schemas []string
+	// SampleSize is the number of random rows, and of random documents,
+	// compared per table. All of them are compared when 0.
+	SampleSize int
+	// BatchSize is the number of documents looked up at once when comparing
+	// all the rows and documents. Defaults to 500.
+	BatchSize int
+	// VersionColumn is the name of the column used as document version, if
+	// any. Stale documents can only be detected when it's set, since the
+	// documents are versioned with the WAL LSN otherwise, which the rows
+	// don't keep.
+	VersionColumn string
+	// Repair re-emits the WAL events of the rows with missing or stale
+	// documents, by updating them without changes, and deletes the documents
+	// without a row.
+	Repair bool
+	// Interval is how often the verification runs in the background. It's
+	// disabled when 0.
+	Interval time.Duration
+}
+
+// SearchReader reads back the ids and versions of the documents indexed for a
+// table.
+type SearchReader interface {
+	CountTableDocuments(ctx context.Context, schemaName, tableID string) (int, error)
+	GetTableDocumentVersions(ctx context.Context, schemaName, tableID string, ids []string) (map[string]int, error)
+	SampleTableDocuments(ctx context.Context, schemaName, tableID string, size int) (map[string]int, error)
+	ScanTableDocuments(ctx context.Context, schemaName, tableID string, batchSize int, fn func(versions map[string]int) error) error
+}
+
+type rowSource interface {
+	schemaLogs(ctx context.Context, schemas []string) ([]*schemalog.LogEntry, error)
+	countRows(ctx context.Context, t *table) (int, error)
+	sampleRows(ctx context.Context, t *table, size int) ([]row, error)
+	scanRows(ctx context.Context, t *table, batchSize int, fn func([]row) error) error
+	existingRows(ctx context.Context, t *table, docIDs []string) (map[string]struct{}, error)
+	touchRows(ctx context.Context, t *table, docIDs []string) (int, error)
+	close() error
+}
+
+// Report is the outcome of a verification.
+type Report struct {
+	Tables []TableReport
+}
+
+// TableReport is the outcome of the verification of a table.
+type TableReport struct {
+	Schema  string
+	Table   string
+	TableID string
+	// Rows and Documents are the number of table rows and indexed documents.
+	Rows      int
+	Documents int
+	// Missing are the rows without a document.
+	Missing Drift
+	// Extra are the documents without a row.
+	Extra Drift
+	// Stale are the documents with an older version than their row.
+	Stale Drift
+	// Repaired is the number of rows re-emitted and documents deleted.
+	Repaired int
+	// Skipped is the reason the table was not verified, if any.
+	Skipped string
+	// Err is the error that interrupted the table verification, if any.
+	Err error
+}
+
+// Drift is a set of drifted documents.
+type Drift struct {
+	Count int
+	// IDs are up to 20 of the drifted document ids, as examples.
+	IDs []string
+}
+
+type Option func(*Verifier)
+
+const (
+	defaultBatchSize = 500
+	maxDriftIDs      = 20
+)
+
+// New returns a verifier of the documents indexed in the search store. The
+// search store is only used to delete the extra documents on repair.
+func New(ctx context.Context, cfg *Config, reader SearchReader, store search.Store, opts ...Option) (*Verifier, error) {
+	source, err := newPGRowSource(ctx, cfg.PostgresURL)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Verifier{
+		source:        source,
+		reader:        reader,
+		store:         store,
+		logger:        loglib.NewNoopLogger(),
+		schemas:       cfg.Schemas,
+		sampleSize:    cfg.SampleSize,
+		batchSize:     defaultBatchSize,
+		versionColumn: cfg.VersionColumn,
+		repair:        cfg.Repair,
+		interval:      cfg.Interval,
+	}
+	if cfg.BatchSize > 0 {
+		v.batchSize = cfg.BatchSize
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(v *Verifier) {
+		v.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ServiceField: "search_verifier",
+		})
+	}
+}
+
+// Run verifies the search documents periodically, logging the drifted tables,
+// until the context is cancelled. This call is blocking.
+func (v *Verifier) Run(ctx context.Context) error {
+	if v.interval <= 0 {
+		return errors.New("search verification interval not configured")
+	}
+
+	ticker := time.NewTicker(v.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			report, err := v.Verify(ctx)
+			if err != nil {
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+				v.logger.Error(err, "verifying search documents")
+				continue
+			}
+			v.logReport(report)
+		}
+	}
+}
+
+// Verify compares the tables of the configured schemas with their search
+// documents. The changes still in flight in the stream at the time of the
+// verification can be reported as drift.
+func (v *Verifier) Verify(ctx context.Context) (*Report, error) {
+	logEntries, err := v.source.schemaLogs(ctx, v.schemas)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving schema logs: %w", err)
+	}
+
+	report := &Report{}
+	for _, logEntry := range logEntries {
+		if logEntry.Schema.Dropped {
+			continue
+		}
+		for i := range logEntry.Schema.Tables {
+			tableReport := v.verifyTable(ctx, logEntry.SchemaName, &logEntry.Schema.Tables[i])
+			if errors.Is(tableReport.Err, context.Canceled) {
+				return report, tableReport.Err
+			}
+			report.Tables = append(report.Tables, tableReport)
+		}
+	}
+	return report, nil
+}
+
+func (v *Verifier) Close() error {
+	return v.source.close()
+}
+
+func (v *Verifier) verifyTable(ctx context.Context, schemaName string, schemaTable *schemalog.Table) TableReport {
+	report := TableReport{
+		Schema:  schemaName,
+		Table:   schemaTable.Name,
+		TableID: schemaTable.PgstreamID,
+	}
+
+	t := newTable(schemaName, schemaTable, v.versionColumn)
+	if t == nil {
+		report.Skipped = "no primary key or unique not null column"
+		return report
+	}
+
+	if report.Err = v.verifyTableRows(ctx, t, &report); report.Err != nil {
+		return report
+	}
+	report.Err = v.verifyTableDocuments(ctx, t, &report)
+	return report
+}
+
+// verifyTableRows looks for the table rows with a missing or stale document.
+func (v *Verifier) verifyTableRows(ctx context.Context, t *table, report *TableReport) error {
+	var err error
+	if report.Rows, err = v.source.countRows(ctx, t); err != nil {
+		return err
+	}
+
+	compare := func(rows []row) error {
+		ids := make([]string, 0, len(rows))
+		for _, r := range rows {
+			ids = append(ids, r.docID)
+		}
+		versions, err := v.reader.GetTableDocumentVersions(ctx, t.schema, t.id, ids)
+		if err != nil {
+			return fmt.Errorf("retrieving document versions: %w", err)
+		}
+
+		drifted := []string{}
+		for _, r := range rows {
+			version, found := versions[r.docID]
+			switch {
+			case !found:
+				report.Missing.add(r.docID)
+			case r.version != nil && version < *r.version:
+				report.Stale.add(r.docID)
+			default:
+				continue
+			}
+			drifted = append(drifted, r.docID)
+		}
+		return v.repairRows(ctx, t, drifted, report)
+	}
+
+	if v.sampleSize > 0 {
+		rows, err := v.source.sampleRows(ctx, t, v.sampleSize)
+		if err != nil {
+			return err
+		}
+		return compare(rows)
+	}
+	return v.source.scanRows(ctx, t, v.batchSize, compare)
+}
+
+// verifyTableDocuments looks for the table documents without a row.
+func (v *Verifier) verifyTableDocuments(ctx context.Context, t *table, report *TableReport) error {
+	var err error
+	if report.Documents, err = v.reader.CountTableDocuments(ctx, t.schema, t.id); err != nil {
+		return fmt.Errorf("counting documents: %w", err)
+	}
+
+	compare := func(versions map[string]int) error {
+		ids := make([]string, 0, len(versions))
+		for id := range versions {
+			ids = append(ids, id)
+		}
+		existing, err := v.source.existingRows(ctx, t, ids)
+		if err != nil {
+			return err
+		}
+
+		extra := map[string]int{}
+		for id, version := range versions {
+			if _, found := existing[id]; !found {
+				report.Extra.add(id)
+				extra[id] = version
+			}
+		}
+		return v.repairDocuments(ctx, t, extra, report)
+	}
+
+	if v.sampleSize > 0 {
+		versions, err := v.reader.SampleTableDocuments(ctx, t.schema, t.id, v.sampleSize)
+		if err != nil {
+			return fmt.Errorf("sampling documents: %w", err)
+		}
+		return compare(versions)
+	}
+	return v.reader.ScanTableDocuments(ctx, t.schema, t.id, v.batchSize, compare)
+}
+
+// repairRows re-emits the WAL events of the rows on input, so that their
+// documents are indexed again.
+func (v *Verifier) repairRows(ctx context.Context, t *table, docIDs []string, report *TableReport) error {
+	if !v.repair || len(docIDs) == 0 {
+		return nil
+	}
+	touched, err := v.source.touchRows(ctx, t, docIDs)
+	if err != nil {
+		return err
+	}
+	report.Repaired += touched
+	return nil
+}
+
+// repairDocuments deletes the documents on input from the search store. There
+// are no row events to re-emit for them, since the rows don't exist.
+func (v *Verifier) repairDocuments(ctx context.Context, t *table, versions map[string]int, report *TableReport) error {
+	if !v.repair || len(versions) == 0 {
+		return nil
+	}
+
+	docs := make([]search.Document, 0, len(versions))
+	for id, version := range versions {
+		docs = append(docs, search.Document{
+			ID:     id,
+			Schema: t.schema,
+			Data:   map[string]any{"_table": t.id},
+			// the delete needs a newer version than the indexed document
+			Version: version + 1,
+			Delete:  true,
+		})
+	}
+
+	failed, err := v.store.SendDocuments(ctx, docs)
+	if err != nil {
+		return fmt.Errorf("deleting extra documents: %w", err)
+	}
+	deleted := len(docs)
+	for _, f := range failed {
+		if f.Severity == search.SeverityNone {
+			continue
+		}
+		deleted--
+		v.logger.Warn(nil, "failed to delete extra document", loglib.Fields{
+			"schema":   t.schema,
+			"table":    t.name,
+			"doc_id":   f.Document.ID,
+			"severity": f.Severity.String(),
+			"error":    f.Error,
+		})
+	}
+	report.Repaired += deleted
+	return nil
+}
+
+func (v *Verifier) logReport(report *Report) {
+	for _, t := range report.Tables {
+		fields := loglib.Fields{
+			"schema":    t.Schema,
+			"table":     t.Table,
+			"rows":      t.Rows,
+			"documents": t.Documents,
+			"missing":   t.Missing.Count,
+			"extra":     t.Extra.Count,
+			"stale":     t.Stale.Count,
+			"repaired":  t.Repaired,
+		}
+		switch {
+		case t.Err != nil:
+			v.logger.Error(t.Err, "verifying search table documents", fields)
+		case t.Drifted():
+			v.logger.Warn(nil, "search table documents drifted", fields)
+		}
+	}
+}
+
+// Drifted returns true if any of the verified tables drifted, or failed to be
+// verified.
+func (r *Report) Drifted() bool {
+	for _, t := range r.Tables {
+		if t.Err != nil || t.Drifted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Drifted returns true if the table documents don't match the table rows.
+func (t *TableReport) Drifted() bool {
+	if t.Skipped != "" {
+		return false
+	}
+	return t.Rows != t.Documents || t.Missing.Count > 0 || t.Extra.Count > 0 || t.Stale.Count > 0
+}
+
+func (d *Drift) add(id string) {
+	d.Count++
+	if len(d.IDs) < maxDriftIDs {
+		d.IDs = append(d.IDs, id)
+	}
+}
@@ -0,0 +1,333 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal/processor/search"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	t.Parallel()
+
+	testSchema := "test_schema"
+	testLogEntry := &schemalog.LogEntry{
+		SchemaName: testSchema,
+		Schema: schemalog.Schema{
+			Tables: []schemalog.Table{
+				{
+					Name:       "users",
+					PgstreamID: "t1",
+					Columns: []schemalog.Column{
+						{Name: "id", DataType: "integer", PgstreamID: "t1-1"},
+						{Name: "version", DataType: "bigint", PgstreamID: "t1-2"},
+						{Name: "name", DataType: "text", PgstreamID: "t1-3"},
+					},
+					PrimaryKeyColumns: []string{"id"},
+				},
+				{
+					Name:       "logs",
+					PgstreamID: "t2",
+					Columns: []schemalog.Column{
+						{Name: "message", DataType: "text", PgstreamID: "t2-1", Nullable: true},
+					},
+				},
+			},
+		},
+	}
+
+	// rows returns the table rows, with their versions only if the table has a
+	// version column
+	rows := func(tbl *table, versions map[string]int) []row {
+		rows := []row{}
+		for _, id := range []string{"t1_1", "t1_2", "t1_3"} {
+			version, found := versions[id]
+			if !found {
+				continue
+			}
+			r := row{docID: id}
+			if tbl.versionColumn != nil {
+				r.version = intPtr(version)
+			}
+			rows = append(rows, r)
+		}
+		return rows
+	}
+
+	consistentRows := map[string]int{"t1_1": 1, "t1_2": 2}
+	consistentDocs := map[string]int{"t1_1": 1, "t1_2": 2}
+	driftedRows := map[string]int{"t1_1": 1, "t1_2": 3, "t1_3": 1}
+	driftedDocs := map[string]int{"t1_1": 1, "t1_2": 2, "t1_4": 5}
+
+	newSource := func(rowVersions map[string]int) *mockRowSource {
+		return &mockRowSource{
+			schemaLogsFn: func(ctx context.Context, schemas []string) ([]*schemalog.LogEntry, error) {
+				return []*schemalog.LogEntry{testLogEntry}, nil
+			},
+			countRowsFn: func(ctx context.Context, tbl *table) (int, error) {
+				return len(rowVersions), nil
+			},
+			sampleRowsFn: func(ctx context.Context, tbl *table, size int) ([]row, error) {
+				require.Equal(t, 2, size)
+				return rows(tbl, rowVersions)[:size], nil
+			},
+			scanRowsFn: func(ctx context.Context, tbl *table, batchSize int, fn func([]row) error) error {
+				require.Equal(t, "users", tbl.name)
+				require.Equal(t, defaultBatchSize, batchSize)
+				return fn(rows(tbl, rowVersions))
+			},
+			existingRowsFn: func(ctx context.Context, tbl *table, docIDs []string) (map[string]struct{}, error) {
+				existing := map[string]struct{}{}
+				for _, id := range docIDs {
+					if _, found := rowVersions[id]; found {
+						existing[id] = struct{}{}
+					}
+				}
+				return existing, nil
+			},
+			touchRowsFn: func(ctx context.Context, tbl *table, docIDs []string) (int, error) {
+				return 0, errTest
+			},
+		}
+	}
+
+	newReader := func(docVersions map[string]int) *mockSearchReader {
+		return &mockSearchReader{
+			countTableDocumentsFn: func(ctx context.Context, schemaName, tableID string) (int, error) {
+				require.Equal(t, testSchema, schemaName)
+				require.Equal(t, "t1", tableID)
+				return len(docVersions), nil
+			},
+			getTableDocumentVersionsFn: func(ctx context.Context, schemaName, tableID string, ids []string) (map[string]int, error) {
+				versions := map[string]int{}
+				for _, id := range ids {
+					if v, found := docVersions[id]; found {
+						versions[id] = v
+					}
+				}
+				return versions, nil
+			},
+			sampleTableDocumentsFn: func(ctx context.Context, schemaName, tableID string, size int) (map[string]int, error) {
+				return map[string]int{"t1_4": docVersions["t1_4"]}, nil
+			},
+			scanTableDocumentsFn: func(ctx context.Context, schemaName, tableID string, batchSize int, fn func(map[string]int) error) error {
+				return fn(docVersions)
+			},
+		}
+	}
+
+	skippedTable := TableReport{
+		Schema:  testSchema,
+		Table:   "logs",
+		TableID: "t2",
+		Skipped: "no primary key or unique not null column",
+	}
+
+	tests := []struct {
+		name          string
+		source        *mockRowSource
+		reader        *mockSearchReader
+		store         *mockStore
+		sampleSize    int
+		versionColumn string
+		repair        bool
+
+		wantReport *Report
+		wantErr    error
+	}{
+		{
+			name:          "ok - consistent",
+			source:        newSource(consistentRows),
+			reader:        newReader(consistentDocs),
+			versionColumn: "version",
+
+			wantReport: &Report{
+				Tables: []TableReport{
+					{Schema: testSchema, Table: "users", TableID: "t1", Rows: 2, Documents: 2},
+					skippedTable,
+				},
+			},
+		},
+		{
+			name:          "ok - drifted",
+			source:        newSource(driftedRows),
+			reader:        newReader(driftedDocs),
+			versionColumn: "version",
+
+			wantReport: &Report{
+				Tables: []TableReport{
+					{
+						Schema: testSchema, Table: "users", TableID: "t1", Rows: 3, Documents: 3,
+						Missing: Drift{Count: 1, IDs: []string{"t1_3"}},
+						Extra:   Drift{Count: 1, IDs: []string{"t1_4"}},
+						Stale:   Drift{Count: 1, IDs: []string{"t1_2"}},
+					},
+					skippedTable,
+				},
+			},
+		},
+		{
+			name:   "ok - drifted without version column",
+			source: newSource(driftedRows),
+			reader: newReader(driftedDocs),
+
+			wantReport: &Report{
+				Tables: []TableReport{
+					{
+						Schema: testSchema, Table: "users", TableID: "t1", Rows: 3, Documents: 3,
+						Missing: Drift{Count: 1, IDs: []string{"t1_3"}},
+						Extra:   Drift{Count: 1, IDs: []string{"t1_4"}},
+					},
+					skippedTable,
+				},
+			},
+		},
+		{
+			name:          "ok - drifted sample",
+			source:        newSource(driftedRows),
+			reader:        newReader(driftedDocs),
+			sampleSize:    2,
+			versionColumn: "version",
+
+			wantReport: &Report{
+				Tables: []TableReport{
+					{
+						Schema: testSchema, Table: "users", TableID: "t1", Rows: 3, Documents: 3,
+						Extra: Drift{Count: 1, IDs: []string{"t1_4"}},
+						Stale: Drift{Count: 1, IDs: []string{"t1_2"}},
+					},
+					skippedTable,
+				},
+			},
+		},
+		{
+			name: "ok - drifted and repaired",
+			source: func() *mockRowSource {
+				s := newSource(driftedRows)
+				s.touchRowsFn = func(ctx context.Context, tbl *table, docIDs []string) (int, error) {
+					require.Equal(t, []string{"t1_2", "t1_3"}, docIDs)
+					return len(docIDs), nil
+				}
+				return s
+			}(),
+			reader: newReader(driftedDocs),
+			store: &mockStore{
+				sendDocumentsFn: func(ctx context.Context, docs []search.Document) ([]search.DocumentError, error) {
+					require.Equal(t, []search.Document{
+						{ID: "t1_4", Schema: testSchema, Data: map[string]any{"_table": "t1"}, Version: 6, Delete: true},
+					}, docs)
+					return nil, nil
+				},
+			},
+			versionColumn: "version",
+			repair:        true,
+
+			wantReport: &Report{
+				Tables: []TableReport{
+					{
+						Schema: testSchema, Table: "users", TableID: "t1", Rows: 3, Documents: 3,
+						Missing:  Drift{Count: 1, IDs: []string{"t1_3"}},
+						Extra:    Drift{Count: 1, IDs: []string{"t1_4"}},
+						Stale:    Drift{Count: 1, IDs: []string{"t1_2"}},
+						Repaired: 3,
+					},
+					skippedTable,
+				},
+			},
+		},
+		{
+			name: "ok - table error",
+			source: func() *mockRowSource {
+				s := newSource(consistentRows)
+				s.countRowsFn = func(ctx context.Context, tbl *table) (int, error) {
+					return 0, errTest
+				}
+				return s
+			}(),
+			reader: newReader(consistentDocs),
+
+			wantReport: &Report{
+				Tables: []TableReport{
+					{Schema: testSchema, Table: "users", TableID: "t1", Err: errTest},
+					skippedTable,
+				},
+			},
+		},
+		{
+			name: "error - retrieving schema logs",
+			source: &mockRowSource{
+				schemaLogsFn: func(ctx context.Context, schemas []string) ([]*schemalog.LogEntry, error) {
+					return nil, errTest
+				},
+			},
+			reader: newReader(consistentDocs),
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := &Verifier{
+				source:        tc.source,
+				reader:        tc.reader,
+				store:         tc.store,
+				sampleSize:    tc.sampleSize,
+				batchSize:     defaultBatchSize,
+				versionColumn: tc.versionColumn,
+				repair:        tc.repair,
+			}
+
+			report, err := v.Verify(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantReport, report)
+		})
+	}
+}
+
+func TestTableReport_Drifted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		report TableReport
+
+		wantDrifted bool
+	}{
+		{
+			name:        "consistent",
+			report:      TableReport{Rows: 2, Documents: 2},
+			wantDrifted: false,
+		},
+		{
+			name:        "count mismatch",
+			report:      TableReport{Rows: 2, Documents: 1},
+			wantDrifted: true,
+		},
+		{
+			name:        "stale documents",
+			report:      TableReport{Rows: 2, Documents: 2, Stale: Drift{Count: 1}},
+			wantDrifted: true,
+		},
+		{
+			name:        "skipped",
+			report:      TableReport{Rows: 2, Skipped: "no primary key or unique not null column"},
+			wantDrifted: false,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantDrifted, tc.report.Drifted())
+		})
+	}
+}
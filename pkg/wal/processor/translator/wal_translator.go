@@ -208,6 +208,9 @@ func (t *Translator) fillEventMetadata(event *wal.Data, log *schemalog.LogEntry,
 	foundID, foundVersion := false, false
 	for i := range tbl.Columns {
 		col := &tbl.Columns[i]
+		if col.Enum {
+			event.Metadata.EnumColIDs = append(event.Metadata.EnumColIDs, col.PgstreamID)
+		}
 		if t.idFinder(col, tbl) {
 			foundID = true
 			event.Metadata.InternalColIDs = append(event.Metadata.InternalColIDs, col.PgstreamID)
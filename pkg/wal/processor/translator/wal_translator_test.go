@@ -281,6 +281,27 @@ func TestTranslator_translate(t *testing.T) {
 			}(),
 			wantErr: nil,
 		},
+		{
+			name: "ok - enum column",
+			store: &schemalogmocks.Store{
+				FetchFn: func(ctx context.Context, schemaName string, ackedOnly bool) (*schemalog.LogEntry, error) {
+					require.Equal(t, testSchemaName, schemaName)
+					logEntry := newTestLogEntry()
+					logEntry.Schema.Tables[0].Columns[1].Enum = true
+					return logEntry, nil
+				},
+			},
+			data:     newTestDataEvent("I").Data,
+			idFinder: func(c *schemalog.Column, _ *schemalog.Table) bool { return c.Name == "col-1" },
+
+			wantData: func() *wal.Data {
+				d := newTestDataEventWithMetadata("I").Data
+				d.Metadata.InternalColVersion = ""
+				d.Metadata.EnumColIDs = []string{fmt.Sprintf("%s_col-2", testTableID)}
+				return d
+			}(),
+			wantErr: nil,
+		},
 		{
 			name: "error - fetching schema log entry",
 			store: &schemalogmocks.Store{
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package delivery
+
+import "time"
+
+// Delivery is a webhook notification payload to be sent to a subscribed url.
+type Delivery struct {
+	ID  int64
+	URL string
+	// Schema and Table are the filters of the subscription the delivery is
+	// sent to.
+	Schema string
+	Table  string
+	// Secret is the current secret of the subscription, used to sign the
+	// delivery. It's not stored in the retry queue, so that rotated secrets
+	// are used for the deliveries sent from it.
+	Secret string
+	// Payload is the serialised webhook payload, sent as is so that its
+	// signature can be computed again on every attempt.
+	Payload []byte
+	// CommitPosition is the position of the wal event the delivery notifies.
+	CommitPosition string
+	Status         Status
+	// Attempts is the number of failed attempts to send the delivery from the
+	// retry queue.
+	Attempts      uint
+	LastError     string
+	CreatedAt     time.Time
+	NextAttemptAt time.Time
+}
+
+// Attempt is the record of an attempt to send a webhook delivery.
+type Attempt struct {
+	ID     int64  `json:"id"`
+	URL    string `json:"url"`
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// CommitPosition is the position of the wal event the delivery notifies.
+	CommitPosition string `json:"commit_position"`
+	// StatusCode is the response status code, 0 if no response was received.
+	StatusCode int   `json:"status_code"`
+	LatencyMS  int64 `json:"latency_ms"`
+	// ResponseBody is the response body, truncated to the first 1KiB.
+	ResponseBody string    `json:"response_body"`
+	Error        string    `json:"error,omitempty"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+	// Payload is the webhook payload sent, not included in the attempt
+	// listings.
+	Payload []byte `json:"-"`
+}
+
+// Failed returns true if the attempt didn't succeed.
+func (a *Attempt) Failed() bool {
+	return a.Error != ""
+}
+
+type Status string
+
+const (
+	// StatusPending deliveries are waiting to be sent again.
+	StatusPending Status = "pending"
+	// StatusFailed deliveries won't be sent again.
+	StatusFailed Status = "failed"
+)
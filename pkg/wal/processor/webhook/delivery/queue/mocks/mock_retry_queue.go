@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue"
+)
+
+type Queue struct {
+	EnqueueFn     func(ctx context.Context, d *delivery.Delivery) error
+	ClaimFn       func(ctx context.Context, now, leaseUntil time.Time, limit uint) ([]*delivery.Delivery, error)
+	RescheduleFn  func(ctx context.Context, id int64, nextAttemptAt time.Time, lastErr string) error
+	FailFn        func(ctx context.Context, id int64, lastErr string) error
+	DeleteFn      func(ctx context.Context, id int64) error
+	PurgeFailedFn func(ctx context.Context, createdBefore time.Time) error
+	StatsFn       func(ctx context.Context) (*queue.Stats, error)
+}
+
+func (m *Queue) Enqueue(ctx context.Context, d *delivery.Delivery) error {
+	return m.EnqueueFn(ctx, d)
+}
+
+func (m *Queue) Claim(ctx context.Context, now, leaseUntil time.Time, limit uint) ([]*delivery.Delivery, error) {
+	return m.ClaimFn(ctx, now, leaseUntil, limit)
+}
+
+func (m *Queue) Reschedule(ctx context.Context, id int64, nextAttemptAt time.Time, lastErr string) error {
+	return m.RescheduleFn(ctx, id, nextAttemptAt, lastErr)
+}
+
+func (m *Queue) Fail(ctx context.Context, id int64, lastErr string) error {
+	return m.FailFn(ctx, id, lastErr)
+}
+
+func (m *Queue) Delete(ctx context.Context, id int64) error {
+	return m.DeleteFn(ctx, id)
+}
+
+func (m *Queue) PurgeFailed(ctx context.Context, createdBefore time.Time) error {
+	return m.PurgeFailedFn(ctx, createdBefore)
+}
+
+func (m *Queue) Stats(ctx context.Context) (*queue.Stats, error) {
+	return m.StatsFn(ctx)
+}
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue"
+)
+
+// Queue is a webhook delivery retry queue stored in a postgres table.
+type Queue struct {
+	conn   pglib.Querier
+	logger loglib.Logger
+}
+
+type Option func(*Queue)
+
+const retryQueueTable = "webhook_retry_queue"
+
+func NewRetryQueue(ctx context.Context, url string, opts ...Option) (*Queue, error) {
+	pgpool, err := pglib.NewConnPool(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("create postgres connection pool: %w", err)
+	}
+	q := &Queue{
+		conn:   pgpool,
+		logger: loglib.NewNoopLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	// create retry queue table if it doesn't exist
+	if err := q.createTable(ctx); err != nil {
+		return nil, fmt.Errorf("creating retry queue table: %w", err)
+	}
+
+	return q, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(q *Queue) {
+		q.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ServiceField: "webhook_retry_queue",
+		})
+	}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, d *delivery.Delivery) error {
+	query := fmt.Sprintf(`INSERT INTO %s(url, schema_name, table_name, payload, commit_position, status, attempts, last_error, created_at, next_attempt_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, retryQueueTable)
+	_, err := q.conn.Exec(ctx, query, d.URL, d.Schema, d.Table, d.Payload, d.CommitPosition, delivery.StatusPending, d.Attempts, d.LastError, d.CreatedAt, d.NextAttemptAt)
+	return err
+}
+
+func (q *Queue) Claim(ctx context.Context, now, leaseUntil time.Time, limit uint) ([]*delivery.Delivery, error) {
+	// the claimed deliveries are moved to the lease expiry, so that they're
+	// not claimed again while they're being sent. Deliveries locked by other
+	// claims are skipped.
+	query := fmt.Sprintf(`UPDATE %[1]s SET next_attempt_at = $1 WHERE id IN (
+	SELECT id FROM %[1]s WHERE status = $2 AND next_attempt_at <= $3 ORDER BY next_attempt_at LIMIT $4 FOR UPDATE SKIP LOCKED)
+	RETURNING id, url, schema_name, table_name, payload, commit_position, status, attempts, last_error, created_at, next_attempt_at`, retryQueueTable)
+	rows, err := q.conn.Query(ctx, query, leaseUntil, delivery.StatusPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claiming retry queue deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []*delivery.Delivery{}
+	for rows.Next() {
+		d := &delivery.Delivery{}
+		if err := rows.Scan(&d.ID, &d.URL, &d.Schema, &d.Table, &d.Payload, &d.CommitPosition, &d.Status, &d.Attempts, &d.LastError, &d.CreatedAt, &d.NextAttemptAt); err != nil {
+			return nil, fmt.Errorf("scanning retry queue delivery row: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (q *Queue) Reschedule(ctx context.Context, id int64, nextAttemptAt time.Time, lastErr string) error {
+	query := fmt.Sprintf(`UPDATE %s SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2 WHERE id = $3`, retryQueueTable)
+	_, err := q.conn.Exec(ctx, query, nextAttemptAt, lastErr, id)
+	return err
+}
+
+func (q *Queue) Fail(ctx context.Context, id int64, lastErr string) error {
+	query := fmt.Sprintf(`UPDATE %s SET status = $1, last_error = $2 WHERE id = $3`, retryQueueTable)
+	_, err := q.conn.Exec(ctx, query, delivery.StatusFailed, lastErr, id)
+	return err
+}
+
+func (q *Queue) Delete(ctx context.Context, id int64) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, retryQueueTable)
+	_, err := q.conn.Exec(ctx, query, id)
+	return err
+}
+
+func (q *Queue) PurgeFailed(ctx context.Context, createdBefore time.Time) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE status = $1 AND created_at < $2`, retryQueueTable)
+	_, err := q.conn.Exec(ctx, query, delivery.StatusFailed, createdBefore)
+	return err
+}
+
+func (q *Queue) Stats(ctx context.Context) (*queue.Stats, error) {
+	query := fmt.Sprintf(`SELECT COUNT(*) FILTER (WHERE status = $1), COUNT(*) FILTER (WHERE status = $2) FROM %s`, retryQueueTable)
+	stats := &queue.Stats{}
+	if err := q.conn.QueryRow(ctx, query, delivery.StatusPending, delivery.StatusFailed).Scan(&stats.Pending, &stats.Failed); err != nil {
+		return nil, fmt.Errorf("counting retry queue deliveries: %w", err)
+	}
+	return stats, nil
+}
+
+// createTable creates the retry queue table and its index if they don't exist.
+// The subscription secrets are not stored with the deliveries, they're
+// retrieved on every attempt.
+func (q *Queue) createTable(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s(
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	schema_name TEXT NOT NULL DEFAULT '',
+	table_name TEXT NOT NULL DEFAULT '',
+	payload BYTEA NOT NULL,
+	commit_position TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	next_attempt_at TIMESTAMPTZ NOT NULL);
+	CREATE INDEX IF NOT EXISTS %[1]s_status_next_attempt_at_idx ON %[1]s(status, next_attempt_at)`, retryQueueTable)
+	_, err := q.conn.Exec(ctx, query)
+	return err
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+)
+
+func TestQueue_Enqueue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	testDelivery := &delivery.Delivery{
+		URL:            "url-1",
+		Schema:         "test_schema",
+		Table:          "test_table",
+		Payload:        []byte("test payload"),
+		CommitPosition: "0/1",
+		LastError:      "oh noes",
+		CreatedAt:      now,
+		NextAttemptAt:  now.Add(time.Minute),
+	}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name    string
+		querier pglib.Querier
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			querier: &pgmocks.Querier{
+				ExecFn: func(ctx context.Context, query string, args ...any) (pglib.CommandTag, error) {
+					require.Equal(t, fmt.Sprintf(`INSERT INTO %s(url, schema_name, table_name, payload, commit_position, status, attempts, last_error, created_at, next_attempt_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, retryQueueTable), query)
+					require.Equal(t, []any{
+						"url-1", "test_schema", "test_table", []byte("test payload"), "0/1", delivery.StatusPending, uint(0), "oh noes", now, now.Add(time.Minute),
+					}, args)
+					return pglib.CommandTag{}, nil
+				},
+			},
+		},
+		{
+			name: "error - inserting delivery",
+			querier: &pgmocks.Querier{
+				ExecFn: func(ctx context.Context, query string, args ...any) (pglib.CommandTag, error) {
+					return pglib.CommandTag{}, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := &Queue{conn: tc.querier}
+			err := q.Enqueue(context.Background(), testDelivery)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+)
+
+// Queue is a durable queue of the webhook deliveries that failed to be sent,
+// so that they can be redriven later on.
+type Queue interface {
+	// Enqueue adds the pending delivery on input to the queue, to be sent at
+	// its next attempt time.
+	Enqueue(ctx context.Context, d *delivery.Delivery) error
+	// Claim returns up to limit pending deliveries due by now, which won't be
+	// returned by other claims until the lease expires.
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit uint) ([]*delivery.Delivery, error)
+	// Reschedule records a failed attempt of the delivery, which will be sent
+	// again at the next attempt time.
+	Reschedule(ctx context.Context, id int64, nextAttemptAt time.Time, lastErr string) error
+	// Fail marks the delivery as failed, so that it's not sent again.
+	Fail(ctx context.Context, id int64, lastErr string) error
+	// Delete removes the delivery from the queue.
+	Delete(ctx context.Context, id int64) error
+	// PurgeFailed removes the failed deliveries created before the time on
+	// input.
+	PurgeFailed(ctx context.Context, createdBefore time.Time) error
+	// Stats returns the number of deliveries in the queue by status.
+	Stats(ctx context.Context) (*Stats, error)
+}
+
+type Stats struct {
+	Pending int64
+	Failed  int64
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+)
+
+// Store keeps the log of the webhook delivery attempts.
+type Store interface {
+	RecordAttempt(ctx context.Context, a *delivery.Attempt) error
+	// GetAttempt returns the delivery attempt with the id on input, including
+	// its payload. It returns ErrNotFound if it doesn't exist.
+	GetAttempt(ctx context.Context, id int64) (*delivery.Attempt, error)
+	// ListAttempts returns the delivery attempts matching the filter, from the
+	// most recent one, without their payload.
+	ListAttempts(ctx context.Context, filter *Filter) ([]*delivery.Attempt, error)
+	// PurgeAttempts removes the delivery attempts made before the time on
+	// input.
+	PurgeAttempts(ctx context.Context, attemptedBefore time.Time) error
+}
+
+// Filter restricts the delivery attempts listed. Empty fields are ignored.
+type Filter struct {
+	URL            string
+	Schema         string
+	Table          string
+	CommitPosition string
+	// Failed returns only the failed attempts when true, or only the
+	// successful ones when false.
+	Failed *bool
+	From   time.Time
+	To     time.Time
+	// BeforeID is the pagination cursor, only attempts with a lower id are
+	// returned.
+	BeforeID int64
+	Limit    uint
+}
+
+var ErrNotFound = errors.New("delivery attempt not found")
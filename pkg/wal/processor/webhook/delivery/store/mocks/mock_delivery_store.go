@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store"
+)
+
+type Store struct {
+	RecordAttemptFn func(ctx context.Context, a *delivery.Attempt) error
+	GetAttemptFn    func(ctx context.Context, id int64) (*delivery.Attempt, error)
+	ListAttemptsFn  func(ctx context.Context, filter *store.Filter) ([]*delivery.Attempt, error)
+	PurgeAttemptsFn func(ctx context.Context, attemptedBefore time.Time) error
+}
+
+func (m *Store) RecordAttempt(ctx context.Context, a *delivery.Attempt) error {
+	return m.RecordAttemptFn(ctx, a)
+}
+
+func (m *Store) GetAttempt(ctx context.Context, id int64) (*delivery.Attempt, error) {
+	return m.GetAttemptFn(ctx, id)
+}
+
+func (m *Store) ListAttempts(ctx context.Context, filter *store.Filter) ([]*delivery.Attempt, error) {
+	return m.ListAttemptsFn(ctx, filter)
+}
+
+func (m *Store) PurgeAttempts(ctx context.Context, attemptedBefore time.Time) error {
+	return m.PurgeAttemptsFn(ctx, attemptedBefore)
+}
@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store"
+)
+
+// Store is a webhook delivery log stored in postgres tables. The attempts are
+// stored in the deliveries table, and their payloads in the payloads table,
+// once per distinct payload, so that the retries and the deliveries of the
+// same event to multiple subscriptions don't keep a copy each. The payloads
+// contain the row data of the notified events, so they can include personal
+// data.
+type Store struct {
+	conn   pglib.Querier
+	logger loglib.Logger
+}
+
+type Option func(*Store)
+
+const (
+	deliveriesTable = "webhook_deliveries"
+	payloadsTable   = "webhook_delivery_payloads"
+	// attemptColumns are the columns of the listed attempts, which don't
+	// include the payload
+	attemptColumns = "id, url, schema_name, table_name, commit_position, status_code, latency_ms, response_body, error, attempted_at"
+)
+
+func NewDeliveryStore(ctx context.Context, url string, opts ...Option) (*Store, error) {
+	pgpool, err := pglib.NewConnPool(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("create postgres connection pool: %w", err)
+	}
+	s := &Store{
+		conn:   pgpool,
+		logger: loglib.NewNoopLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// create deliveries table if it doesn't exist
+	if err := s.createTable(ctx); err != nil {
+		return nil, fmt.Errorf("creating deliveries table: %w", err)
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *Store) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ServiceField: "webhook_delivery_store",
+		})
+	}
+}
+
+// RecordAttempt stores the delivery attempt, along with its payload if it's
+// not stored yet. The last attempt time of the payload is updated, so that
+// it's not purged while it's in use.
+func (s *Store) RecordAttempt(ctx context.Context, a *delivery.Attempt) error {
+	query := fmt.Sprintf(`WITH payload AS (
+	INSERT INTO %[1]s(hash, payload, last_attempted_at) VALUES($5, $6, $11)
+	ON CONFLICT (hash) DO UPDATE SET last_attempted_at = GREATEST(%[1]s.last_attempted_at, EXCLUDED.last_attempted_at))
+	INSERT INTO %[2]s(url, schema_name, table_name, commit_position, payload_hash, status_code, latency_ms, response_body, error, attempted_at)
+	VALUES($1, $2, $3, $4, $5, $7, $8, $9, $10, $11)`, payloadsTable, deliveriesTable)
+	hash := sha256.Sum256(a.Payload)
+	_, err := s.conn.Exec(ctx, query, a.URL, a.Schema, a.Table, a.CommitPosition, hash[:], a.Payload, a.StatusCode, a.LatencyMS, sanitiseText(a.ResponseBody), sanitiseText(a.Error), a.AttemptedAt)
+	return err
+}
+
+func (s *Store) GetAttempt(ctx context.Context, id int64) (*delivery.Attempt, error) {
+	query := fmt.Sprintf(`SELECT %s, p.payload FROM %s d JOIN %s p ON p.hash = d.payload_hash WHERE d.id = $1`, qualifiedAttemptColumns(), deliveriesTable, payloadsTable)
+	a := &delivery.Attempt{}
+	if err := s.conn.QueryRow(ctx, query, id).Scan(&a.ID, &a.URL, &a.Schema, &a.Table, &a.CommitPosition, &a.StatusCode,
+		&a.LatencyMS, &a.ResponseBody, &a.Error, &a.AttemptedAt, &a.Payload); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, store.ErrNotFound
+		}
+		return nil, fmt.Errorf("getting delivery attempt: %w", err)
+	}
+	return a, nil
+}
+
+func (s *Store) ListAttempts(ctx context.Context, filter *store.Filter) ([]*delivery.Attempt, error) {
+	query, params := s.buildListQuery(filter)
+	s.logger.Trace("listing delivery attempts", loglib.Fields{
+		"query":  query,
+		"params": params,
+	})
+	rows, err := s.conn.Query(ctx, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("querying deliveries table: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []*delivery.Attempt{}
+	for rows.Next() {
+		a := &delivery.Attempt{}
+		if err := rows.Scan(&a.ID, &a.URL, &a.Schema, &a.Table, &a.CommitPosition, &a.StatusCode,
+			&a.LatencyMS, &a.ResponseBody, &a.Error, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("scanning delivery attempt row: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+
+	return attempts, rows.Err()
+}
+
+// PurgeAttempts removes the attempts made before the time on input, and their
+// payloads. The payloads last attempted before then are no longer referenced
+// by any attempt.
+func (s *Store) PurgeAttempts(ctx context.Context, attemptedBefore time.Time) error {
+	query := fmt.Sprintf(`WITH payloads AS (DELETE FROM %[1]s WHERE last_attempted_at < $1)
+	DELETE FROM %[2]s WHERE attempted_at < $1`, payloadsTable, deliveriesTable)
+	_, err := s.conn.Exec(ctx, query, attemptedBefore)
+	return err
+}
+
+func (s *Store) createTable(ctx context.Context) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s(
+	hash BYTEA PRIMARY KEY,
+	payload BYTEA NOT NULL,
+	last_attempted_at TIMESTAMPTZ NOT NULL);
+	CREATE TABLE IF NOT EXISTS %[2]s(
+	id BIGSERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	schema_name TEXT NOT NULL DEFAULT '',
+	table_name TEXT NOT NULL DEFAULT '',
+	commit_position TEXT NOT NULL DEFAULT '',
+	payload_hash BYTEA NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	latency_ms BIGINT NOT NULL DEFAULT 0,
+	response_body TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	attempted_at TIMESTAMPTZ NOT NULL);
+	CREATE INDEX IF NOT EXISTS %[1]s_last_attempted_at_idx ON %[1]s(last_attempted_at);
+	CREATE INDEX IF NOT EXISTS %[2]s_attempted_at_idx ON %[2]s(attempted_at)`, payloadsTable, deliveriesTable)
+	_, err := s.conn.Exec(ctx, query)
+	return err
+}
+
+// qualifiedAttemptColumns returns the attempt columns qualified with the
+// deliveries table alias, for the queries joining the payloads table.
+func qualifiedAttemptColumns() string {
+	columns := strings.Split(attemptColumns, ", ")
+	for i, c := range columns {
+		columns[i] = "d." + c
+	}
+	return strings.Join(columns, ", ")
+}
+
+// sanitiseText makes the text on input valid for a text column, since the
+// response bodies can be truncated in the middle of a character, or not be
+// text at all.
+func sanitiseText(text string) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(text, "\x00", ""), "")
+}
+
+func (s *Store) buildListQuery(filter *store.Filter) (string, []any) {
+	query := fmt.Sprintf(`SELECT %s FROM %s`, attemptColumns, deliveriesTable)
+
+	var params []any
+	where := func(condition string, param any) {
+		separator := "AND"
+		if len(params) == 0 {
+			separator = "WHERE"
+		}
+		params = append(params, param)
+		query = fmt.Sprintf("%s %s "+condition, query, separator, len(params))
+	}
+
+	if filter.URL != "" {
+		where("url=$%d", filter.URL)
+	}
+	if filter.Schema != "" {
+		where("schema_name=$%d", filter.Schema)
+	}
+	if filter.Table != "" {
+		where("table_name=$%d", filter.Table)
+	}
+	if filter.CommitPosition != "" {
+		where("commit_position=$%d", filter.CommitPosition)
+	}
+	if filter.Failed != nil {
+		if *filter.Failed {
+			where("error<>$%d", "")
+		} else {
+			where("error=$%d", "")
+		}
+	}
+	if !filter.From.IsZero() {
+		where("attempted_at>=$%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("attempted_at<$%d", filter.To)
+	}
+	if filter.BeforeID > 0 {
+		where("id<$%d", filter.BeforeID)
+	}
+
+	params = append(params, filter.Limit)
+	return fmt.Sprintf("%s ORDER BY id DESC LIMIT $%d", query, len(params)), params
+}
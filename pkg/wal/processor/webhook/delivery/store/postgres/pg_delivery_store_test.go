@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store"
+)
+
+func TestStore_buildListQuery(t *testing.T) {
+	t.Parallel()
+
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %s`, attemptColumns, deliveriesTable)
+	failed := true
+	succeeded := false
+	from := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		filter *store.Filter
+
+		wantQuery  string
+		wantParams []any
+	}{
+		{
+			name:       "no filters",
+			filter:     &store.Filter{Limit: 100},
+			wantQuery:  selectQuery + " ORDER BY id DESC LIMIT $1",
+			wantParams: []any{uint(100)},
+		},
+		{
+			name:       "with failed filter",
+			filter:     &store.Filter{Failed: &failed, Limit: 100},
+			wantQuery:  selectQuery + " WHERE error<>$1 ORDER BY id DESC LIMIT $2",
+			wantParams: []any{"", uint(100)},
+		},
+		{
+			name:       "with succeeded filter",
+			filter:     &store.Filter{Failed: &succeeded, Limit: 100},
+			wantQuery:  selectQuery + " WHERE error=$1 ORDER BY id DESC LIMIT $2",
+			wantParams: []any{"", uint(100)},
+		},
+		{
+			name: "with all filters",
+			filter: &store.Filter{
+				URL:            "url-1",
+				Schema:         "test_schema",
+				Table:          "test_table",
+				CommitPosition: "0/1",
+				Failed:         &failed,
+				From:           from,
+				To:             to,
+				BeforeID:       50,
+				Limit:          10,
+			},
+			wantQuery: selectQuery +
+				" WHERE url=$1 AND schema_name=$2 AND table_name=$3 AND commit_position=$4" +
+				" AND error<>$5 AND attempted_at>=$6 AND attempted_at<$7 AND id<$8" +
+				" ORDER BY id DESC LIMIT $9",
+			wantParams: []any{"url-1", "test_schema", "test_table", "0/1", "", from, to, int64(50), uint(10)},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Store{}
+			query, params := s.buildListQuery(tc.filter)
+			require.Equal(t, tc.wantQuery, query)
+			require.Equal(t, tc.wantParams, params)
+		})
+	}
+}
+
+func TestStore_RecordAttempt(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	payload := []byte("test payload")
+	payloadHash := sha256.Sum256(payload)
+	testAttempt := func(responseBody string) *delivery.Attempt {
+		return &delivery.Attempt{
+			URL:            "url-1",
+			Schema:         "test_schema",
+			Table:          "test_table",
+			CommitPosition: "0/1",
+			StatusCode:     500,
+			LatencyMS:      10,
+			ResponseBody:   responseBody,
+			Error:          "oh noes",
+			AttemptedAt:    now,
+			Payload:        payload,
+		}
+	}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name    string
+		attempt *delivery.Attempt
+		querier pglib.Querier
+
+		wantErr error
+	}{
+		{
+			name:    "ok - payload stored by hash, sanitised response body",
+			attempt: testAttempt("invalid \x00text\xff"),
+			querier: &pgmocks.Querier{
+				ExecFn: func(ctx context.Context, query string, args ...any) (pglib.CommandTag, error) {
+					require.Contains(t, query, fmt.Sprintf("INSERT INTO %s(hash, payload, last_attempted_at)", payloadsTable))
+					require.Contains(t, query, fmt.Sprintf("INSERT INTO %s(url, schema_name, table_name, commit_position, payload_hash,", deliveriesTable))
+					require.Equal(t, []any{
+						"url-1", "test_schema", "test_table", "0/1", payloadHash[:], payload, 500, int64(10), "invalid text", "oh noes", now,
+					}, args)
+					return pglib.CommandTag{}, nil
+				},
+			},
+		},
+		{
+			name:    "error - inserting attempt",
+			attempt: testAttempt(""),
+			querier: &pgmocks.Querier{
+				ExecFn: func(ctx context.Context, query string, args ...any) (pglib.CommandTag, error) {
+					return pglib.CommandTag{}, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Store{conn: tc.querier}
+			err := s.RecordAttempt(context.Background(), tc.attempt)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
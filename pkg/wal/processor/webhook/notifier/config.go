@@ -2,7 +2,11 @@
 
 package notifier
 
-import "time"
+import (
+	"time"
+
+	"github.com/xataio/pgstream/internal/backoff"
+)
 
 type Config struct {
 	// MaxQueueBytes is the max memory used by the webhook notifier for inflight
@@ -14,12 +18,48 @@ type Config struct {
 	// ClientTimeout is the max time the notifier will wait for a response from
 	// a webhook url before it times out. Defaults to 10s.
 	ClientTimeout time.Duration
+	// RetryBackoff is the retry policy applied to the failed webhook
+	// deliveries before they're sent to the retry queue. If not provided it
+	// defaults to using exponential backoff with initial interval of 500ms, max
+	// interval of 5s, and 2 max retries.
+	RetryBackoff backoff.Config
+	// RetryTimeout is the max time spent sending a webhook delivery, including
+	// its retries, before it's sent to the retry queue. It bounds the time a
+	// failing url delays the notification of the following events. Defaults to
+	// 5s.
+	RetryTimeout time.Duration
+	// RedriveInterval is the interval at which the deliveries in the retry
+	// queue are redriven. Failed redrives are delayed exponentially from it, up
+	// to 1h. Defaults to 30s.
+	RedriveInterval time.Duration
+	// RedriveBatchSize is the max number of deliveries claimed from the retry
+	// queue at once. Defaults to 100.
+	RedriveBatchSize uint
+	// RetryMaxAge is the max time a delivery will be retried for since the
+	// first attempt. Older deliveries are marked as failed. Defaults to 24h.
+	RetryMaxAge time.Duration
+	// FailedRetention is the time the failed deliveries are kept in the retry
+	// queue. Defaults to 7 days.
+	FailedRetention time.Duration
+	// DeliveryLogRetention is the time the delivery attempts, and their
+	// payloads, are kept in the delivery log. The payloads contain the row data
+	// of the notified events. Defaults to 7 days.
+	DeliveryLogRetention time.Duration
 }
 
 const (
-	defaultMaxQueueBytes  = int64(100 * 1024 * 1024) // 100MiB
-	defaultURLWorkerCount = 10
-	defaultClientTimeout  = 10 * time.Second
+	defaultMaxQueueBytes        = int64(100 * 1024 * 1024) // 100MiB
+	defaultURLWorkerCount       = 10
+	defaultClientTimeout        = 10 * time.Second
+	defaultRetryInitialInterval = 500 * time.Millisecond
+	defaultRetryMaxInterval     = 5 * time.Second
+	defaultRetryMaxRetries      = 2
+	defaultRetryTimeout         = 5 * time.Second
+	defaultRedriveInterval      = 30 * time.Second
+	defaultRedriveBatchSize     = 100
+	defaultRetryMaxAge          = 24 * time.Hour
+	defaultFailedRetention      = 7 * 24 * time.Hour
+	defaultDeliveryLogRetention = 7 * 24 * time.Hour
 )
 
 func (c *Config) maxQueueBytes() int64 {
@@ -45,3 +85,64 @@ func (c *Config) clientTimeout() time.Duration {
 
 	return defaultClientTimeout
 }
+
+func (c *Config) retryBackoff() *backoff.Config {
+	if c.RetryBackoff.Constant != nil || c.RetryBackoff.Exponential != nil {
+		return &c.RetryBackoff
+	}
+	return &backoff.Config{
+		Exponential: &backoff.ExponentialConfig{
+			InitialInterval: defaultRetryInitialInterval,
+			MaxInterval:     defaultRetryMaxInterval,
+			MaxRetries:      defaultRetryMaxRetries,
+		},
+	}
+}
+
+func (c *Config) retryTimeout() time.Duration {
+	if c.RetryTimeout > 0 {
+		return c.RetryTimeout
+	}
+
+	return defaultRetryTimeout
+}
+
+func (c *Config) redriveInterval() time.Duration {
+	if c.RedriveInterval > 0 {
+		return c.RedriveInterval
+	}
+
+	return defaultRedriveInterval
+}
+
+func (c *Config) redriveBatchSize() uint {
+	if c.RedriveBatchSize > 0 {
+		return c.RedriveBatchSize
+	}
+
+	return defaultRedriveBatchSize
+}
+
+func (c *Config) retryMaxAge() time.Duration {
+	if c.RetryMaxAge > 0 {
+		return c.RetryMaxAge
+	}
+
+	return defaultRetryMaxAge
+}
+
+func (c *Config) failedRetention() time.Duration {
+	if c.FailedRetention > 0 {
+		return c.FailedRetention
+	}
+
+	return defaultFailedRetention
+}
+
+func (c *Config) deliveryLogRetention() time.Duration {
+	if c.DeliveryLogRetention > 0 {
+		return c.DeliveryLogRetention
+	}
+
+	return defaultDeliveryLogRetention
+}
@@ -11,6 +11,7 @@ import (
 
 var (
 	testCommitPos = wal.CommitPosition("test-pos")
+	testSecret    = "test-secret"
 	errTest       = errors.New("oh noes")
 )
 
@@ -23,9 +24,9 @@ func newTestSubscription(url, schema, table string, eventTypes []string) *subscr
 	}
 }
 
-func testNotifyMsg(urls []string, payload []byte) *notifyMsg {
+func testNotifyMsg(subscriptions []*subscription.Subscription, payload []byte) *notifyMsg {
 	return &notifyMsg{
-		urls:           urls,
+		subscriptions:  subscriptions,
 		payload:        payload,
 		commitPosition: testCommitPos,
 	}
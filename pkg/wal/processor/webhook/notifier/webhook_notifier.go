@@ -6,19 +6,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"runtime/debug"
 	"sync"
+	"time"
 
+	"github.com/xataio/pgstream/internal/backoff"
 	httplib "github.com/xataio/pgstream/internal/http"
 	synclib "github.com/xataio/pgstream/internal/sync"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue"
+	deliverystore "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/signature"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
+
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Notifier represents the process that notifies any subscribed webhooks when
@@ -32,9 +41,34 @@ type Notifier struct {
 	// queueBytesSema is used to limit the amount of memory used by the
 	// unbuffered msg channel, optimising the channel performance for variable
 	// size messages, while preventing the process from running oom
-	queueBytesSema synclib.WeightedSemaphore
-	notifyChan     chan *notifyMsg
-	workerCount    uint
+	queueBytesSema  synclib.WeightedSemaphore
+	notifyChan      chan *notifyMsg
+	workerCount     uint
+	backoffProvider backoff.Provider
+	retryTimeout    time.Duration
+	// retryQueue keeps the deliveries that failed after all the retries, so
+	// that they can be redriven. They're dropped if not set.
+	retryQueue       queue.Queue
+	redriveInterval  time.Duration
+	redriveBatchSize uint
+	retryMaxAge      time.Duration
+	failedRetention  time.Duration
+	// deliveryStore keeps the log of the delivery attempts. They're not
+	// recorded if not set.
+	deliveryStore        deliverystore.Store
+	deliveryLogRetention time.Duration
+	clock                func() time.Time
+	metrics              *notifierMetrics
+}
+
+// maxResponseBodyBytes is the max size of the webhook response bodies read
+const maxResponseBodyBytes = 1024
+
+type notifierMetrics struct {
+	deliveriesEnqueued metric.Int64Counter
+	deliveriesRedriven metric.Int64Counter
+	pendingDeliveries  metric.Int64ObservableGauge
+	failedDeliveries   metric.Int64ObservableGauge
 }
 
 type subscriptionRetriever interface {
@@ -49,10 +83,18 @@ func New(cfg *Config, store subscriptionRetriever, opts ...Option) *Notifier {
 		client: &http.Client{
 			Timeout: cfg.clientTimeout(),
 		},
-		subscriptionStore: store,
-		notifyChan:        make(chan *notifyMsg),
-		workerCount:       cfg.workerCount(),
-		serialiser:        json.Marshal,
+		subscriptionStore:    store,
+		notifyChan:           make(chan *notifyMsg),
+		workerCount:          cfg.workerCount(),
+		serialiser:           json.Marshal,
+		backoffProvider:      backoff.NewProvider(cfg.retryBackoff()),
+		retryTimeout:         cfg.retryTimeout(),
+		redriveInterval:      cfg.redriveInterval(),
+		redriveBatchSize:     cfg.redriveBatchSize(),
+		retryMaxAge:          cfg.retryMaxAge(),
+		failedRetention:      cfg.failedRetention(),
+		deliveryLogRetention: cfg.deliveryLogRetention(),
+		clock:                time.Now,
 	}
 
 	// this allows us to bound and configure the memory used by the internal msg
@@ -80,6 +122,36 @@ func WithCheckpoint(c checkpointer.Checkpoint) Option {
 	}
 }
 
+// WithRetryQueue sets the queue the failed deliveries are sent to once the
+// retries are exhausted. The deliveries in the queue are sent again by
+// Redrive.
+func WithRetryQueue(q queue.Queue) Option {
+	return func(n *Notifier) {
+		n.retryQueue = q
+	}
+}
+
+// WithDeliveryLog sets the store where every delivery attempt is recorded.
+// The attempts older than the retention are purged by Redrive.
+func WithDeliveryLog(s deliverystore.Store) Option {
+	return func(n *Notifier) {
+		n.deliveryStore = s
+	}
+}
+
+// WithInstrumentation sets the meter used to record the webhook delivery
+// metrics.
+func WithInstrumentation(meter metric.Meter) Option {
+	return func(n *Notifier) {
+		metrics, err := n.newMetrics(meter)
+		if err != nil {
+			n.logger.Error(err, "initialising webhook notifier instrumentation")
+			return
+		}
+		n.metrics = metrics
+	}
+}
+
 func (n *Notifier) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -99,7 +171,7 @@ func (n *Notifier) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (er
 		if err != nil {
 			return fmt.Errorf("retrieving subscriptions: %w", err)
 		}
-		n.logger.Debug("matching subscriptions", loglib.Fields{"subscriptions": subscriptions})
+		n.logger.Debug("matching subscriptions", loglib.Fields{"subscription_count": len(subscriptions)})
 	}
 
 	msg, err := newNotifyMsg(walEvent, subscriptions, n.serialiser)
@@ -133,7 +205,7 @@ func (n *Notifier) Notify(ctx context.Context) error {
 			n.queueBytesSema.Release(int64(msg.size()))
 			if err != nil {
 				n.logger.Error(err, "sending webhook event", loglib.Fields{
-					"urls":            msg.urls,
+					"urls":            msg.urls(),
 					"commit position": msg.commitPosition,
 					"payload":         string(msg.payload),
 				})
@@ -153,21 +225,39 @@ func (n *Notifier) Close() error {
 }
 
 func (n *Notifier) notify(ctx context.Context, msg *notifyMsg) error {
-	n.logger.Trace("notifying", loglib.Fields{"urls": msg.urls})
-	if len(msg.urls) > 0 {
-		urlChan := make(chan string, n.workerCount)
+	n.logger.Trace("notifying", loglib.Fields{"urls": msg.urls()})
+	if len(msg.subscriptions) > 0 {
+		subscriptionChan := make(chan *subscription.Subscription, n.workerCount)
+		errChan := make(chan error, len(msg.subscriptions))
 		wg := &sync.WaitGroup{}
 		for i := 0; i < int(n.workerCount); i++ {
 			wg.Add(1)
-			go n.webhookWorker(ctx, wg, msg.payload, urlChan)
+			go n.webhookWorker(ctx, wg, msg, subscriptionChan, errChan)
 		}
 
-		for _, url := range msg.urls {
-			urlChan <- url
+		for _, s := range msg.subscriptions {
+			subscriptionChan <- s
 		}
 
-		close(urlChan)
+		close(subscriptionChan)
 		wg.Wait()
+		close(errChan)
+
+		// the deliveries that could not be sent or added to the retry queue
+		// would be lost if the event was checkpointed, so it's processed again
+		errs := []error{}
+		for err := range errChan {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	}
+
+	// the deliveries that were interrupted have not been sent or added to the
+	// retry queue, so the event can't be checkpointed
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	if n.checkpointer != nil {
@@ -179,24 +269,105 @@ func (n *Notifier) notify(ctx context.Context, msg *notifyMsg) error {
 	return nil
 }
 
-func (n *Notifier) webhookWorker(ctx context.Context, wg *sync.WaitGroup, payload []byte, urls <-chan string) {
+func (n *Notifier) webhookWorker(ctx context.Context, wg *sync.WaitGroup, msg *notifyMsg, subscriptions <-chan *subscription.Subscription, errChan chan<- error) {
 	defer wg.Done()
-	for url := range urls {
-		if err := n.sendWebhook(ctx, payload, url); err != nil {
-			n.logger.Error(err, "sending webhook payload", loglib.Fields{
-				"payload": payload,
-				"url":     url,
-			})
-			continue
+	for s := range subscriptions {
+		if err := n.deliver(ctx, msg, s); err != nil {
+			errChan <- err
 		}
 	}
 }
 
-func (n *Notifier) sendWebhook(ctx context.Context, payload []byte, url string) error {
-	n.logger.Trace("sending webhook", loglib.Fields{"url": url})
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(payload))
+// deliver sends the webhook payload to the subscription url, retrying with the
+// configured backoff policy within the retry timeout. Deliveries that fail
+// after all the retries are added to the retry queue. An error is returned if the delivery was neither
+// sent nor added to the retry queue.
+func (n *Notifier) deliver(ctx context.Context, msg *notifyMsg, s *subscription.Subscription) error {
+	numRetries := 0
+	reportErr := func(err error, d time.Duration) {
+		n.logger.Warn(err, "sending webhook payload, retrying", loglib.Fields{
+			"url":     s.URL,
+			"retries": numRetries,
+			"backoff": d,
+		})
+		numRetries++
+	}
+
+	d := &delivery.Delivery{
+		URL:            s.URL,
+		Schema:         s.Schema,
+		Table:          s.Table,
+		Secret:         s.Secret,
+		Payload:        msg.payload,
+		CommitPosition: string(msg.commitPosition),
+		CreatedAt:      n.clock(),
+	}
+	retryCtx, cancel := context.WithTimeout(ctx, n.retryTimeout)
+	defer cancel()
+	bo := n.backoffProvider(retryCtx)
+	err := bo.RetryNotify(func() error { return n.sendWebhook(retryCtx, d) }, reportErr)
+	if err == nil {
+		return nil
+	}
+
+	n.logger.Error(err, "sending webhook payload", loglib.Fields{
+		"payload": msg.payload,
+		"url":     s.URL,
+	})
+	// interrupted deliveries are sent again when the event is processed again,
+	// since it's not checkpointed
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if n.retryQueue == nil {
+		n.logger.Warn(nil, "webhook delivery dropped, no retry queue configured", loglib.Fields{"url": s.URL})
+		return nil
+	}
+
+	d.LastError = err.Error()
+	d.NextAttemptAt = n.clock().Add(n.redriveInterval)
+	if err := n.retryQueue.Enqueue(ctx, d); err != nil {
+		return fmt.Errorf("adding webhook delivery for %s to retry queue: %w", s.URL, err)
+	}
+	if n.metrics != nil {
+		n.metrics.deliveriesEnqueued.Add(ctx, 1)
+	}
+	return nil
+}
+
+// sendWebhook sends the delivery payload to its url once, recording the
+// attempt in the delivery log.
+func (n *Notifier) sendWebhook(ctx context.Context, d *delivery.Delivery) error {
+	attempt := &delivery.Attempt{
+		URL:            d.URL,
+		Schema:         d.Schema,
+		Table:          d.Table,
+		CommitPosition: d.CommitPosition,
+		Payload:        d.Payload,
+		AttemptedAt:    n.clock(),
+	}
+	err := n.postWebhook(ctx, d, attempt)
+	attempt.LatencyMS = n.clock().Sub(attempt.AttemptedAt).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	n.recordAttempt(ctx, attempt)
+	return err
+}
+
+// postWebhook sends the delivery payload request, setting the response status
+// code and body of the attempt.
+func (n *Notifier) postWebhook(ctx context.Context, d *delivery.Delivery, attempt *delivery.Attempt) error {
+	n.logger.Trace("sending webhook", loglib.Fields{"url": d.URL})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewBuffer(d.Payload))
 	if err != nil {
-		return fmt.Errorf("building webhook payload request: %w", err)
+		return fmt.Errorf("building webhook payload request: %w: %w", err, backoff.ErrPermanent)
+	}
+
+	// the timestamp is signed with the payload so that receivers can reject
+	// replayed deliveries
+	if d.Secret != "" {
+		req.Header.Set(signature.Header, signature.Sign(d.Secret, n.clock(), d.Payload))
 	}
 
 	resp, err := n.client.Do(req)
@@ -205,17 +376,87 @@ func (n *Notifier) sendWebhook(ctx context.Context, payload []byte, url string)
 	}
 	defer resp.Body.Close()
 
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseBody = getResponseBody(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("error response from payload request, status code: %s, body: %v", resp.Status, getResponseBody(resp.Body))
+		return fmt.Errorf("error response from payload request, status code: %s, body: %v", resp.Status, attempt.ResponseBody)
 	}
 
 	return nil
 }
 
-func getResponseBody(respBody io.ReadCloser) string {
-	bodyBytes, err := io.ReadAll(respBody)
+func (n *Notifier) recordAttempt(ctx context.Context, attempt *delivery.Attempt) {
+	// interrupted attempts are not recorded, they'll be attempted again, but
+	// the ones that exceeded the retry timeout are
+	if n.deliveryStore == nil || errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if err := n.deliveryStore.RecordAttempt(context.WithoutCancel(ctx), attempt); err != nil {
+		n.logger.Error(err, "recording webhook delivery attempt", loglib.Fields{
+			"url":             attempt.URL,
+			"commit_position": attempt.CommitPosition,
+		})
+	}
+}
+
+// getResponseBody returns the first bytes of the response body, enough to
+// identify the response errors.
+func getResponseBody(respBody io.Reader) string {
+	bodyBytes, err := io.ReadAll(io.LimitReader(respBody, maxResponseBodyBytes))
 	if err != nil {
 		return ""
 	}
 	return string(bodyBytes)
 }
+
+func (n *Notifier) newMetrics(meter metric.Meter) (*notifierMetrics, error) {
+	metrics := &notifierMetrics{}
+	var err error
+	metrics.deliveriesEnqueued, err = meter.Int64Counter("pgstream.webhook.notifier.deliveries.enqueued",
+		metric.WithUnit("deliveries"),
+		metric.WithDescription("Number of webhook deliveries added to the retry queue after exhausting their retries"))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.deliveriesRedriven, err = meter.Int64Counter("pgstream.webhook.notifier.deliveries.redriven",
+		metric.WithUnit("deliveries"),
+		metric.WithDescription("Number of webhook deliveries sent successfully from the retry queue"))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.pendingDeliveries, err = meter.Int64ObservableGauge("pgstream.webhook.notifier.deliveries.pending",
+		metric.WithUnit("deliveries"),
+		metric.WithDescription("Number of webhook deliveries in the retry queue waiting to be redriven"))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.failedDeliveries, err = meter.Int64ObservableGauge("pgstream.webhook.notifier.deliveries.failed",
+		metric.WithUnit("deliveries"),
+		metric.WithDescription("Number of webhook deliveries in the retry queue that failed permanently"))
+	if err != nil {
+		return nil, err
+	}
+
+	observe := func(ctx context.Context, o metric.Observer) error {
+		if n.retryQueue == nil {
+			return nil
+		}
+		stats, err := n.retryQueue.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		o.ObserveInt64(metrics.pendingDeliveries, stats.Pending)
+		o.ObserveInt64(metrics.failedDeliveries, stats.Failed)
+		return nil
+	}
+
+	_, err = meter.RegisterCallback(observe, metrics.pendingDeliveries, metrics.failedDeliveries)
+	if err != nil {
+		return nil, fmt.Errorf("registering webhook notifier metric callbacks: %w", err)
+	}
+
+	return metrics, nil
+}
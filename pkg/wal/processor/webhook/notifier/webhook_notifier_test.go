@@ -7,12 +7,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"sync"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/backoff"
 	httplib "github.com/xataio/pgstream/internal/http"
 	httpmocks "github.com/xataio/pgstream/internal/http/mocks"
 	syncmocks "github.com/xataio/pgstream/internal/sync/mocks"
@@ -20,6 +23,10 @@ import (
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	queuemocks "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue/mocks"
+	deliverymocks "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/signature"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/store/mocks"
 )
@@ -39,6 +46,11 @@ func TestNotifier_ProcessWALEvent(t *testing.T) {
 	testSubscription := func(url string) *subscription.Subscription {
 		return newTestSubscription(url, "", "", nil)
 	}
+	testSignedSubscription := func(url string) *subscription.Subscription {
+		s := testSubscription(url)
+		s.Secret = testSecret
+		return s
+	}
 
 	testPayload, err := json.Marshal(&webhook.Payload{Data: testEvent.Data})
 	require.NoError(t, err)
@@ -68,7 +80,7 @@ func TestNotifier_ProcessWALEvent(t *testing.T) {
 			},
 			event: testEvent,
 
-			wantMsgs: []*notifyMsg{testNotifyMsg([]string{}, nil)},
+			wantMsgs: []*notifyMsg{testNotifyMsg([]*subscription.Subscription{}, nil)},
 			wantErr:  nil,
 		},
 		{
@@ -76,20 +88,20 @@ func TestNotifier_ProcessWALEvent(t *testing.T) {
 			store: &mocks.Store{
 				GetSubscriptionsFn: func(ctx context.Context, action, schema, table string) ([]*subscription.Subscription, error) {
 					return []*subscription.Subscription{
-						testSubscription("url-1"), testSubscription("url-2"),
+						testSubscription("url-1"), testSignedSubscription("url-2"),
 					}, nil
 				},
 			},
 			weightedSemaphore: &syncmocks.WeightedSemaphore{
 				TryAcquireFn: func(i int64) bool {
-					require.Equal(t, int64(len(testPayload)+len("url-1")+len("url-2")), i)
+					require.Equal(t, int64(len(testPayload)+len("url-1")+len("url-2")+len(testSecret)), i)
 					return true
 				},
 			},
 			event: testEvent,
 
 			wantMsgs: []*notifyMsg{
-				testNotifyMsg([]string{"url-1", "url-2"}, testPayload),
+				testNotifyMsg([]*subscription.Subscription{testSubscription("url-1"), testSignedSubscription("url-2")}, testPayload),
 			},
 			wantErr: nil,
 		},
@@ -187,15 +199,28 @@ func TestNotifier_Notify(t *testing.T) {
 	testPayload := []byte("test payload")
 	url1 := "url-1"
 	url2 := "url-2"
+	subscription1 := newTestSubscription(url1, "", "", nil)
+	subscription2 := newTestSubscription(url2, "", "", nil)
+	subscription2.Secret = testSecret
 
 	testCfg := &Config{
 		URLWorkerCount: 2,
+		RetryTimeout:   100 * time.Millisecond,
+		RetryBackoff: backoff.Config{
+			Constant: &backoff.ConstantConfig{
+				Interval:   time.Millisecond,
+				MaxRetries: 2,
+			},
+		},
 	}
 
+	var doCalls atomic.Int32
+
 	tests := []struct {
 		name         string
 		semaphore    *syncmocks.WeightedSemaphore
 		client       httplib.Client
+		retryQueue   *queuemocks.Queue
 		msgs         []*notifyMsg
 		checkpointer func(chan struct{}) checkpointer.Checkpoint
 
@@ -205,13 +230,19 @@ func TestNotifier_Notify(t *testing.T) {
 			name: "ok",
 			client: &httpmocks.Client{
 				DoFn: func(r *http.Request) (*http.Response, error) {
-					if r.URL.Path == url1 || r.URL.Path == url2 {
-						return &http.Response{
-							StatusCode: http.StatusOK,
-							Body:       http.NoBody,
-						}, nil
+					switch r.URL.Path {
+					case url1:
+						require.Empty(t, r.Header.Get(signature.Header))
+					case url2:
+						_, err := signature.VerifyRequest(r, testSecret, signature.DefaultTolerance)
+						require.NoError(t, err)
+					default:
+						return nil, fmt.Errorf("unexpected request url: %v", r.URL)
 					}
-					return nil, fmt.Errorf("unexpected request url: %v", r.URL)
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       http.NoBody,
+					}, nil
 				},
 			},
 			semaphore: &syncmocks.WeightedSemaphore{
@@ -222,13 +253,54 @@ func TestNotifier_Notify(t *testing.T) {
 				},
 			},
 			msgs: []*notifyMsg{
-				testNotifyMsg([]string{url1, url2}, testPayload),
+				testNotifyMsg([]*subscription.Subscription{subscription1, subscription2}, testPayload),
+			},
+			checkpointer: func(doneChan chan struct{}) checkpointer.Checkpoint {
+				return func(ctx context.Context, positions []wal.CommitPosition) error {
+					defer func() {
+						doneChan <- struct{}{}
+					}()
+					require.Equal(t, []wal.CommitPosition{testCommitPos}, positions)
+					return nil
+				}
+			},
+
+			wantErr: context.Canceled,
+		},
+		{
+			name: "ok - webhook sent after retries",
+			client: &httpmocks.Client{
+				DoFn: func(r *http.Request) (*http.Response, error) {
+					if doCalls.Add(1) == 1 {
+						return &http.Response{
+							StatusCode: http.StatusServiceUnavailable,
+							Status:     http.StatusText(http.StatusServiceUnavailable),
+							Body:       http.NoBody,
+						}, nil
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       http.NoBody,
+					}, nil
+				},
+			},
+			retryQueue: &queuemocks.Queue{
+				EnqueueFn: func(ctx context.Context, d *delivery.Delivery) error {
+					return errors.New("EnqueueFn: should not be called")
+				},
+			},
+			semaphore: &syncmocks.WeightedSemaphore{
+				ReleaseFn: func(i uint64, bytes int64) {},
+			},
+			msgs: []*notifyMsg{
+				testNotifyMsg([]*subscription.Subscription{subscription1}, testPayload),
 			},
 			checkpointer: func(doneChan chan struct{}) checkpointer.Checkpoint {
 				return func(ctx context.Context, positions []wal.CommitPosition) error {
 					defer func() {
 						doneChan <- struct{}{}
 					}()
+					require.Equal(t, int32(2), doCalls.Load())
 					require.Equal(t, []wal.CommitPosition{testCommitPos}, positions)
 					return nil
 				}
@@ -243,6 +315,16 @@ func TestNotifier_Notify(t *testing.T) {
 					return nil, errTest
 				},
 			},
+			retryQueue: &queuemocks.Queue{
+				EnqueueFn: func(ctx context.Context, d *delivery.Delivery) error {
+					require.Equal(t, url1, d.URL)
+					require.Equal(t, testPayload, d.Payload)
+					require.Equal(t, string(testCommitPos), d.CommitPosition)
+					require.Contains(t, d.LastError, errTest.Error())
+					require.True(t, d.NextAttemptAt.After(d.CreatedAt))
+					return nil
+				},
+			},
 			semaphore: &syncmocks.WeightedSemaphore{
 				ReleaseFn: func(i uint64, bytes int64) {
 					if i == 0 {
@@ -251,7 +333,41 @@ func TestNotifier_Notify(t *testing.T) {
 				},
 			},
 			msgs: []*notifyMsg{
-				testNotifyMsg([]string{url1}, testPayload),
+				testNotifyMsg([]*subscription.Subscription{subscription1}, testPayload),
+			},
+			checkpointer: func(doneChan chan struct{}) checkpointer.Checkpoint {
+				return func(ctx context.Context, positions []wal.CommitPosition) error {
+					defer func() {
+						doneChan <- struct{}{}
+					}()
+					require.Equal(t, []wal.CommitPosition{testCommitPos}, positions)
+					return nil
+				}
+			},
+
+			wantErr: context.Canceled,
+		},
+		{
+			name: "ok - retry timeout exceeded",
+			client: &httpmocks.Client{
+				DoFn: func(r *http.Request) (*http.Response, error) {
+					<-r.Context().Done()
+					return nil, r.Context().Err()
+				},
+			},
+			retryQueue: &queuemocks.Queue{
+				EnqueueFn: func(ctx context.Context, d *delivery.Delivery) error {
+					require.NoError(t, ctx.Err())
+					require.Equal(t, url1, d.URL)
+					require.Contains(t, d.LastError, context.DeadlineExceeded.Error())
+					return nil
+				},
+			},
+			semaphore: &syncmocks.WeightedSemaphore{
+				ReleaseFn: func(i uint64, bytes int64) {},
+			},
+			msgs: []*notifyMsg{
+				testNotifyMsg([]*subscription.Subscription{subscription1}, testPayload),
 			},
 			checkpointer: func(doneChan chan struct{}) checkpointer.Checkpoint {
 				return func(ctx context.Context, positions []wal.CommitPosition) error {
@@ -265,6 +381,32 @@ func TestNotifier_Notify(t *testing.T) {
 
 			wantErr: context.Canceled,
 		},
+		{
+			name: "error - adding delivery to retry queue",
+			client: &httpmocks.Client{
+				DoFn: func(r *http.Request) (*http.Response, error) {
+					return nil, errTest
+				},
+			},
+			retryQueue: &queuemocks.Queue{
+				EnqueueFn: func(ctx context.Context, d *delivery.Delivery) error {
+					return errTest
+				},
+			},
+			semaphore: &syncmocks.WeightedSemaphore{
+				ReleaseFn: func(i uint64, bytes int64) {},
+			},
+			msgs: []*notifyMsg{
+				testNotifyMsg([]*subscription.Subscription{subscription1, subscription2}, testPayload),
+			},
+			checkpointer: func(doneChan chan struct{}) checkpointer.Checkpoint {
+				return func(ctx context.Context, positions []wal.CommitPosition) error {
+					return errors.New("checkpointer: should not be called")
+				}
+			},
+
+			wantErr: errTest,
+		},
 		{
 			name: "error - checkpointing",
 			client: &httpmocks.Client{
@@ -276,7 +418,7 @@ func TestNotifier_Notify(t *testing.T) {
 				ReleaseFn: func(i uint64, bytes int64) {},
 			},
 			msgs: []*notifyMsg{
-				testNotifyMsg([]string{}, nil),
+				testNotifyMsg([]*subscription.Subscription{}, nil),
 			},
 			checkpointer: func(doneChan chan struct{}) checkpointer.Checkpoint {
 				return func(ctx context.Context, positions []wal.CommitPosition) error {
@@ -303,37 +445,138 @@ func TestNotifier_Notify(t *testing.T) {
 			n := New(testCfg, &mocks.Store{})
 			n.client = tc.client
 			n.queueBytesSema = tc.semaphore
+			if tc.retryQueue != nil {
+				n.retryQueue = tc.retryQueue
+			}
 			n.checkpointer = tc.checkpointer(doneChan)
 
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
 
-			wg := sync.WaitGroup{}
-			wg.Add(1)
+			errChan := make(chan error, 1)
 			go func() {
-				defer wg.Done()
-				err := n.Notify(ctx)
-				require.ErrorIs(t, err, tc.wantErr)
+				errChan <- n.Notify(ctx)
 			}()
 
 			for _, msg := range tc.msgs {
 				n.notifyChan <- msg
 			}
 
-			for {
-				select {
-				case <-ctx.Done():
-					t.Log("test timeout reached")
-					wg.Wait()
-					return
-				case <-doneChan:
-					if errors.Is(tc.wantErr, context.Canceled) {
-						cancel()
-					}
-					wg.Wait()
-					return
+			select {
+			case <-ctx.Done():
+				t.Log("test timeout reached")
+				<-errChan
+			case <-doneChan:
+				if errors.Is(tc.wantErr, context.Canceled) {
+					cancel()
 				}
+				require.ErrorIs(t, <-errChan, tc.wantErr)
+			case err := <-errChan:
+				require.ErrorIs(t, err, tc.wantErr)
 			}
 		})
 	}
 }
+
+func TestNotifier_sendWebhook(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	testDelivery := &delivery.Delivery{
+		URL:            "url-1",
+		Schema:         "test_schema",
+		Table:          "test_table",
+		Secret:         testSecret,
+		Payload:        []byte("test payload"),
+		CommitPosition: string(testCommitPos),
+	}
+	longBody := strings.Repeat("a", 2*maxResponseBodyBytes)
+
+	tests := []struct {
+		name string
+		doFn func(r *http.Request) (*http.Response, error)
+
+		wantAttempt *delivery.Attempt
+		wantErr     bool
+	}{
+		{
+			name: "ok",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				require.Equal(t, signature.Sign(testSecret, now, []byte("test payload")), r.Header.Get(signature.Header))
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+			},
+
+			wantAttempt: &delivery.Attempt{
+				URL:            "url-1",
+				Schema:         "test_schema",
+				Table:          "test_table",
+				CommitPosition: string(testCommitPos),
+				Payload:        []byte("test payload"),
+				StatusCode:     http.StatusOK,
+				ResponseBody:   "ok",
+				AttemptedAt:    now,
+			},
+		},
+		{
+			name: "error - response status code, truncated body",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusInternalServerError,
+					Status:     "500 Internal Server Error",
+					Body:       io.NopCloser(strings.NewReader(longBody)),
+				}, nil
+			},
+
+			wantAttempt: &delivery.Attempt{
+				URL:            "url-1",
+				Schema:         "test_schema",
+				Table:          "test_table",
+				CommitPosition: string(testCommitPos),
+				Payload:        []byte("test payload"),
+				StatusCode:     http.StatusInternalServerError,
+				ResponseBody:   longBody[:maxResponseBodyBytes],
+				Error:          "error response from payload request, status code: 500 Internal Server Error, body: " + longBody[:maxResponseBodyBytes],
+				AttemptedAt:    now,
+			},
+			wantErr: true,
+		},
+		{
+			name: "error - sending request",
+			doFn: func(r *http.Request) (*http.Response, error) {
+				return nil, errTest
+			},
+
+			wantAttempt: &delivery.Attempt{
+				URL:            "url-1",
+				Schema:         "test_schema",
+				Table:          "test_table",
+				CommitPosition: string(testCommitPos),
+				Payload:        []byte("test payload"),
+				Error:          "sending webhook payload request: " + errTest.Error(),
+				AttemptedAt:    now,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var recorded *delivery.Attempt
+			n := New(&Config{}, &mocks.Store{}, WithDeliveryLog(&deliverymocks.Store{
+				RecordAttemptFn: func(ctx context.Context, a *delivery.Attempt) error {
+					recorded = a
+					return nil
+				},
+			}))
+			n.client = &httpmocks.Client{DoFn: tc.doFn}
+			n.clock = func() time.Time { return now }
+
+			err := n.sendWebhook(context.Background(), testDelivery)
+			require.Equal(t, tc.wantErr, err != nil)
+			require.Equal(t, tc.wantAttempt, recorded)
+		})
+	}
+}
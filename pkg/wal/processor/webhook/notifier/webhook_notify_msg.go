@@ -11,7 +11,7 @@ import (
 )
 
 type notifyMsg struct {
-	urls           []string
+	subscriptions  []*subscription.Subscription
 	payload        []byte
 	commitPosition wal.CommitPosition
 }
@@ -20,30 +20,33 @@ type serialiser func(any) ([]byte, error)
 
 func newNotifyMsg(event *wal.Event, subscriptions []*subscription.Subscription, serialiser serialiser) (*notifyMsg, error) {
 	var payload []byte
-	urls := make([]string, 0, len(subscriptions))
 	if len(subscriptions) > 0 {
 		var err error
 		payload, err = serialiser(&webhook.Payload{Data: event.Data})
 		if err != nil {
 			return nil, fmt.Errorf("serialising webhook payload: %w", err)
 		}
-
-		for _, s := range subscriptions {
-			urls = append(urls, s.URL)
-		}
 	}
 
 	return &notifyMsg{
-		urls:           urls,
+		subscriptions:  subscriptions,
 		payload:        payload,
 		commitPosition: event.CommitPosition,
 	}, nil
 }
 
 func (m *notifyMsg) size() int {
-	urlSize := 0
-	for _, url := range m.urls {
-		urlSize += len(url)
+	subscriptionsSize := 0
+	for _, s := range m.subscriptions {
+		subscriptionsSize += len(s.URL) + len(s.Secret)
+	}
+	return len(m.payload) + subscriptionsSize
+}
+
+func (m *notifyMsg) urls() []string {
+	urls := make([]string, 0, len(m.subscriptions))
+	for _, s := range m.subscriptions {
+		urls = append(urls, s.URL)
 	}
-	return len(m.payload) + urlSize
+	return urls
 }
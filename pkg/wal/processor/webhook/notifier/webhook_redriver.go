@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+)
+
+const (
+	// redriveLease is the time the claimed deliveries are hidden from other
+	// claims, long enough for a batch to be redriven.
+	redriveLease = 10 * time.Minute
+	// maxRedriveDelay is the max time between two attempts to send a delivery
+	// from the retry queue.
+	maxRedriveDelay = time.Hour
+)
+
+var (
+	errMaxAgeExceeded       = errors.New("delivery max age exceeded")
+	errSubscriptionNotFound = errors.New("delivery subscription not found")
+)
+
+// Redrive sends the due deliveries in the retry queue on every redrive
+// interval, until the context is cancelled. This call is blocking. The
+// deliveries are sent in no particular order, independently of the events
+// being notified. The delivery attempts older than the retention are purged
+// from the delivery log on every redrive too.
+func (n *Notifier) Redrive(ctx context.Context) error {
+	if n.retryQueue == nil && n.deliveryStore == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(n.redriveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			n.purgeDeliveryLog(ctx)
+			n.redrive(ctx)
+		}
+	}
+}
+
+// redrive purges the expired failed deliveries from the retry queue, and sends
+// the due deliveries in batches until there are none left. Failures are logged
+// and retried on the next redrive.
+func (n *Notifier) redrive(ctx context.Context) {
+	if n.retryQueue == nil {
+		return
+	}
+
+	now := n.clock()
+	if err := n.retryQueue.PurgeFailed(ctx, now.Add(-n.failedRetention)); err != nil {
+		n.logger.Error(err, "purging failed webhook deliveries")
+	}
+
+	for {
+		deliveries, err := n.retryQueue.Claim(ctx, now, now.Add(redriveLease), n.redriveBatchSize)
+		if err != nil {
+			n.logger.Error(err, "claiming webhook deliveries from retry queue")
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		n.logger.Debug("redriving webhook deliveries", loglib.Fields{"deliveries": len(deliveries)})
+		deliveryChan := make(chan *delivery.Delivery, n.workerCount)
+		wg := &sync.WaitGroup{}
+		for i := 0; i < int(n.workerCount); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for d := range deliveryChan {
+					n.redriveDelivery(ctx, d)
+				}
+			}()
+		}
+		for _, d := range deliveries {
+			deliveryChan <- d
+		}
+		close(deliveryChan)
+		wg.Wait()
+
+		if ctx.Err() != nil || uint(len(deliveries)) < n.redriveBatchSize {
+			return
+		}
+	}
+}
+
+// redriveDelivery sends the delivery from the retry queue once, signed with the
+// current secret of its subscription. Successful deliveries are removed from
+// the queue, while failed ones are rescheduled with an exponential delay, or
+// marked as failed if they would exceed the max age or their subscription
+// doesn't exist anymore.
+func (n *Notifier) redriveDelivery(ctx context.Context, d *delivery.Delivery) {
+	now := n.clock()
+	if now.Sub(d.CreatedAt) > n.retryMaxAge {
+		n.failDelivery(ctx, d, errMaxAgeExceeded)
+		return
+	}
+
+	sendErr := n.setSecret(ctx, d)
+	switch {
+	case errors.Is(sendErr, errSubscriptionNotFound):
+		n.failDelivery(ctx, d, sendErr)
+		return
+	case sendErr == nil:
+		sendErr = n.sendWebhook(ctx, d)
+	}
+	if sendErr == nil {
+		if err := n.retryQueue.Delete(ctx, d.ID); err != nil {
+			n.logger.Error(err, "removing webhook delivery from retry queue", loglib.Fields{"delivery_id": d.ID})
+			return
+		}
+		if n.metrics != nil {
+			n.metrics.deliveriesRedriven.Add(ctx, 1)
+		}
+		return
+	}
+
+	nextAttempt := now.Add(n.redriveDelay(d.Attempts + 1))
+	if nextAttempt.Sub(d.CreatedAt) > n.retryMaxAge {
+		n.failDelivery(ctx, d, sendErr)
+		return
+	}
+
+	n.logger.Warn(sendErr, "redriving webhook delivery", loglib.Fields{
+		"delivery_id":  d.ID,
+		"url":          d.URL,
+		"attempts":     d.Attempts + 1,
+		"next_attempt": nextAttempt,
+	})
+	if err := n.retryQueue.Reschedule(ctx, d.ID, nextAttempt, sendErr.Error()); err != nil {
+		n.logger.Error(err, "rescheduling webhook delivery", loglib.Fields{"delivery_id": d.ID})
+	}
+}
+
+// setSecret sets the current secret of the delivery subscription, so that
+// rotated secrets are used for the deliveries in the retry queue.
+func (n *Notifier) setSecret(ctx context.Context, d *delivery.Delivery) error {
+	subscriptions, err := n.subscriptionStore.GetSubscriptions(ctx, "", d.Schema, d.Table)
+	if err != nil {
+		return fmt.Errorf("retrieving subscriptions: %w", err)
+	}
+	for _, s := range subscriptions {
+		if s.URL == d.URL && s.Schema == d.Schema && s.Table == d.Table {
+			d.Secret = s.Secret
+			return nil
+		}
+	}
+	return errSubscriptionNotFound
+}
+
+func (n *Notifier) failDelivery(ctx context.Context, d *delivery.Delivery, cause error) {
+	n.logger.Error(cause, "webhook delivery failed", loglib.Fields{
+		"delivery_id": d.ID,
+		"url":         d.URL,
+		"attempts":    d.Attempts,
+		"created_at":  d.CreatedAt,
+	})
+	if err := n.retryQueue.Fail(ctx, d.ID, cause.Error()); err != nil {
+		n.logger.Error(err, "marking webhook delivery as failed", loglib.Fields{"delivery_id": d.ID})
+	}
+}
+
+// redriveDelay returns the time to wait before the next attempt to send a
+// delivery from the retry queue, doubling the redrive interval on every
+// failed attempt.
+func (n *Notifier) redriveDelay(attempts uint) time.Duration {
+	delay := n.redriveInterval
+	for i := uint(0); i < attempts && delay < maxRedriveDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRedriveDelay)
+}
+
+func (n *Notifier) purgeDeliveryLog(ctx context.Context) {
+	if n.deliveryStore == nil {
+		return
+	}
+	attemptedBefore := n.clock().Add(-n.deliveryLogRetention)
+	if err := n.deliveryStore.PurgeAttempts(ctx, attemptedBefore); err != nil {
+		n.logger.Error(err, "purging webhook delivery log", loglib.Fields{"attempted_before": attemptedBefore})
+	}
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	httpmocks "github.com/xataio/pgstream/internal/http/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	queuemocks "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/signature"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/store/mocks"
+)
+
+func TestNotifier_redrive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	testDelivery := func(id int64) *delivery.Delivery {
+		return &delivery.Delivery{
+			ID:        id,
+			URL:       "url-1",
+			Schema:    "test_schema",
+			Payload:   []byte("test payload"),
+			CreatedAt: now.Add(-time.Minute),
+		}
+	}
+	// the secret of the subscription has been rotated since the delivery was
+	// added to the queue
+	getSubscriptions := func(ctx context.Context, action, schema, table string) ([]*subscription.Subscription, error) {
+		require.Equal(t, "", action)
+		require.Equal(t, "test_schema", schema)
+		require.Equal(t, "", table)
+		rotated := newTestSubscription("url-1", "test_schema", "", nil)
+		rotated.Secret = testSecret
+		return []*subscription.Subscription{
+			newTestSubscription("url-1", "", "", nil),
+			newTestSubscription("url-2", "test_schema", "", nil),
+			rotated,
+		}, nil
+	}
+	okResponse := func(r *http.Request) (*http.Response, error) {
+		_, err := signature.VerifyRequest(r, testSecret, signature.DefaultTolerance)
+		require.NoError(t, err)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}
+	errorResponse := func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error", Body: http.NoBody}, nil
+	}
+
+	tests := []struct {
+		name             string
+		delivery         *delivery.Delivery
+		getSubscriptions func(ctx context.Context, action, schema, table string) ([]*subscription.Subscription, error)
+		doFn             func(r *http.Request) (*http.Response, error)
+		retryMaxAge      time.Duration
+
+		wantDeleted    bool
+		wantReschedule time.Time
+		wantFailed     bool
+	}{
+		{
+			name:     "ok - delivery sent",
+			delivery: testDelivery(1),
+			doFn:     okResponse,
+
+			wantDeleted: true,
+		},
+		{
+			name: "ok - delivery rescheduled",
+			delivery: func() *delivery.Delivery {
+				d := testDelivery(1)
+				d.Attempts = 2
+				return d
+			}(),
+			doFn: errorResponse,
+
+			wantReschedule: now.Add(8 * time.Second),
+		},
+		{
+			name:        "ok - delivery failed, next attempt exceeds max age",
+			delivery:    testDelivery(1),
+			doFn:        errorResponse,
+			retryMaxAge: time.Minute + time.Second,
+
+			wantFailed: true,
+		},
+		{
+			name:        "ok - delivery failed, max age exceeded",
+			delivery:    testDelivery(1),
+			doFn:        func(r *http.Request) (*http.Response, error) { return nil, errors.New("DoFn: should not be called") },
+			retryMaxAge: time.Second,
+
+			wantFailed: true,
+		},
+		{
+			name:     "ok - delivery failed, subscription not found",
+			delivery: testDelivery(1),
+			getSubscriptions: func(ctx context.Context, action, schema, table string) ([]*subscription.Subscription, error) {
+				return []*subscription.Subscription{newTestSubscription("url-2", "test_schema", "", nil)}, nil
+			},
+			doFn: func(r *http.Request) (*http.Response, error) { return nil, errors.New("DoFn: should not be called") },
+
+			wantFailed: true,
+		},
+		{
+			name:     "ok - delivery rescheduled, error retrieving subscriptions",
+			delivery: testDelivery(1),
+			getSubscriptions: func(ctx context.Context, action, schema, table string) ([]*subscription.Subscription, error) {
+				return nil, errTest
+			},
+			doFn: func(r *http.Request) (*http.Response, error) { return nil, errors.New("DoFn: should not be called") },
+
+			wantReschedule: now.Add(2 * time.Second),
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var deleted, failed bool
+			var rescheduled time.Time
+			claims := 0
+			store := &mocks.Store{GetSubscriptionsFn: getSubscriptions}
+			if tc.getSubscriptions != nil {
+				store.GetSubscriptionsFn = tc.getSubscriptions
+			}
+			n := New(&Config{
+				RedriveInterval:  time.Second,
+				RedriveBatchSize: 1,
+				RetryMaxAge:      tc.retryMaxAge,
+			}, store,
+				WithRetryQueue(&queuemocks.Queue{
+					PurgeFailedFn: func(ctx context.Context, createdBefore time.Time) error {
+						require.Equal(t, now.Add(-defaultFailedRetention), createdBefore)
+						return nil
+					},
+					ClaimFn: func(ctx context.Context, claimNow, leaseUntil time.Time, limit uint) ([]*delivery.Delivery, error) {
+						require.Equal(t, now, claimNow)
+						require.Equal(t, now.Add(redriveLease), leaseUntil)
+						require.Equal(t, uint(1), limit)
+						claims++
+						if claims > 1 {
+							return []*delivery.Delivery{}, nil
+						}
+						return []*delivery.Delivery{tc.delivery}, nil
+					},
+					DeleteFn: func(ctx context.Context, id int64) error {
+						require.Equal(t, tc.delivery.ID, id)
+						deleted = true
+						return nil
+					},
+					RescheduleFn: func(ctx context.Context, id int64, nextAttemptAt time.Time, lastErr string) error {
+						require.Equal(t, tc.delivery.ID, id)
+						require.NotEmpty(t, lastErr)
+						rescheduled = nextAttemptAt
+						return nil
+					},
+					FailFn: func(ctx context.Context, id int64, lastErr string) error {
+						require.Equal(t, tc.delivery.ID, id)
+						require.NotEmpty(t, lastErr)
+						failed = true
+						return nil
+					},
+				}))
+			n.client = &httpmocks.Client{DoFn: tc.doFn}
+			n.clock = func() time.Time { return now }
+
+			n.redrive(context.Background())
+			require.Equal(t, 2, claims)
+			require.Equal(t, tc.wantDeleted, deleted)
+			require.Equal(t, tc.wantReschedule, rescheduled)
+			require.Equal(t, tc.wantFailed, failed)
+		})
+	}
+}
+
+func TestNotifier_redriveDelay(t *testing.T) {
+	t.Parallel()
+
+	n := New(&Config{RedriveInterval: 30 * time.Second}, &mocks.Store{})
+	require.Equal(t, time.Minute, n.redriveDelay(1))
+	require.Equal(t, 4*time.Minute, n.redriveDelay(3))
+	require.Equal(t, maxRedriveDelay, n.redriveDelay(10))
+	require.Equal(t, maxRedriveDelay, n.redriveDelay(1000))
+}
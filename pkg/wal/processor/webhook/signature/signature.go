@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package signature implements the signing scheme of the webhook deliveries,
+// so that receivers can verify the requests were sent by pgstream with the
+// secret of their subscription.
+//
+// Signed deliveries include the X-Pgstream-Signature header, with the format
+// `t=<timestamp>,v1=<signature>`, where the timestamp is the Unix time in
+// seconds at which the delivery was signed, and the signature is the hex
+// encoded HMAC-SHA256 of `<timestamp>.<request body>` using the subscription
+// secret as key. Receivers should reject the deliveries whose timestamp is
+// older than their tolerance to prevent replays.
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Header is the request header containing the delivery signature.
+	Header = "X-Pgstream-Signature"
+	// DefaultTolerance is the default max age of a signed delivery.
+	DefaultTolerance = 5 * time.Minute
+
+	timestampKey = "t"
+	signatureKey = "v1"
+)
+
+var (
+	ErrMissingSecret    = errors.New("missing signature secret")
+	ErrMissingHeader    = errors.New("missing signature header")
+	ErrInvalidHeader    = errors.New("invalid signature header")
+	ErrNoValidSignature = errors.New("no valid signature found")
+	ErrTimestampExpired = errors.New("signature timestamp outside of tolerance")
+)
+
+// Sign returns the signature header value of the payload for the secret and
+// timestamp on input.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	ts := timestamp.Unix()
+	return fmt.Sprintf("%s=%d,%s=%s", timestampKey, ts, signatureKey, hex.EncodeToString(computeSignature(secret, ts, payload)))
+}
+
+// Verify checks the signature header value on input is a valid signature of
+// the payload for the secret, and that it was signed within the tolerance. A
+// zero tolerance disables the timestamp check.
+func Verify(header, secret string, payload []byte, tolerance time.Duration) error {
+	return verify(header, secret, payload, tolerance, time.Now())
+}
+
+// VerifyRequest checks the signature of the webhook request on input, and
+// returns its body. The request body is replaced so that it can be read again.
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	var payload []byte
+	if r.Body != nil {
+		var err error
+		payload, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+		r.Body.Close()
+	}
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+
+	if err := Verify(r.Header.Get(Header), secret, payload, tolerance); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func verify(header, secret string, payload []byte, tolerance time.Duration, now time.Time) error {
+	if secret == "" {
+		return ErrMissingSecret
+	}
+	if header == "" {
+		return ErrMissingHeader
+	}
+
+	ts, signatures, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+
+	if tolerance > 0 && now.Sub(time.Unix(ts, 0)).Abs() > tolerance {
+		return ErrTimestampExpired
+	}
+
+	expected := computeSignature(secret, ts, payload)
+	for _, signature := range signatures {
+		if hmac.Equal(expected, signature) {
+			return nil
+		}
+	}
+	return ErrNoValidSignature
+}
+
+// parseHeader returns the timestamp and the signatures of the header value.
+// Multiple signatures are allowed so that secrets can be rotated.
+func parseHeader(header string) (int64, [][]byte, error) {
+	var ts int64
+	var tsFound bool
+	signatures := [][]byte{}
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			return 0, nil, ErrInvalidHeader
+		}
+		switch key {
+		case timestampKey:
+			var err error
+			ts, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%w: parsing timestamp: %w", ErrInvalidHeader, err)
+			}
+			tsFound = true
+		case signatureKey:
+			signature, err := hex.DecodeString(value)
+			if err != nil {
+				// ignore malformed signatures, there might be other valid ones
+				continue
+			}
+			signatures = append(signatures, signature)
+		}
+	}
+
+	if !tsFound {
+		return 0, nil, fmt.Errorf("%w: missing timestamp", ErrInvalidHeader)
+	}
+	if len(signatures) == 0 {
+		return 0, nil, ErrNoValidSignature
+	}
+	return ts, signatures, nil
+}
+
+func computeSignature(secret string, timestamp int64, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
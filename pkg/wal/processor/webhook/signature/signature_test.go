@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package signature
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+
+	header := Sign("secret", time.Unix(1714557600, 0), []byte(`{"Data":null}`))
+	// echo -n '1714557600.{"Data":null}' | openssl dgst -sha256 -hmac secret
+	require.Equal(t, "t=1714557600,v1=43d2a4c4d188d64cf7e4158f80b0de7b3889a39146bbb39f8f2158176be91f63", header)
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	testPayload := []byte(`{"Data":null}`)
+	now := time.Unix(1714557600, 0)
+	validHeader := Sign("secret", now, testPayload)
+
+	tests := []struct {
+		name      string
+		header    string
+		secret    string
+		payload   []byte
+		tolerance time.Duration
+
+		wantErr error
+	}{
+		{
+			name:      "ok",
+			header:    validHeader,
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+		},
+		{
+			name:      "ok - multiple signatures",
+			header:    validHeader + ",v1=" + "zz,v1=0123",
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+		},
+		{
+			name:      "ok - no tolerance",
+			header:    Sign("secret", now.Add(-time.Hour), testPayload),
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: 0,
+		},
+		{
+			name:      "error - missing secret",
+			header:    validHeader,
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrMissingSecret,
+		},
+		{
+			name:      "error - missing header",
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrMissingHeader,
+		},
+		{
+			name:      "error - invalid header",
+			header:    "invalid",
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrInvalidHeader,
+		},
+		{
+			name:      "error - missing timestamp",
+			header:    "v1=0123",
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrInvalidHeader,
+		},
+		{
+			name:      "error - missing signature",
+			header:    "t=1714557600",
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrNoValidSignature,
+		},
+		{
+			name:      "error - wrong secret",
+			header:    validHeader,
+			secret:    "other-secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrNoValidSignature,
+		},
+		{
+			name:      "error - tampered payload",
+			header:    validHeader,
+			secret:    "secret",
+			payload:   []byte(`{"Data":{}}`),
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrNoValidSignature,
+		},
+		{
+			name:      "error - expired timestamp",
+			header:    Sign("secret", now.Add(-DefaultTolerance-time.Second), testPayload),
+			secret:    "secret",
+			payload:   testPayload,
+			tolerance: DefaultTolerance,
+
+			wantErr: ErrTimestampExpired,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := verify(tc.header, tc.secret, tc.payload, tc.tolerance, now)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	t.Parallel()
+
+	testPayload := []byte(`{"Data":null}`)
+	req, err := http.NewRequest(http.MethodPost, "http://localhost/webhook", bytes.NewReader(testPayload))
+	require.NoError(t, err)
+	req.Header.Set(Header, Sign("secret", time.Now(), testPayload))
+
+	payload, err := VerifyRequest(req, "secret", DefaultTolerance)
+	require.NoError(t, err)
+	require.Equal(t, testPayload, payload)
+
+	// the body can be read again
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, testPayload, body)
+
+	req.Header.Set(Header, Sign("other-secret", time.Now(), testPayload))
+	_, err = VerifyRequest(req, "secret", DefaultTolerance)
+	require.ErrorIs(t, err, ErrNoValidSignature)
+}
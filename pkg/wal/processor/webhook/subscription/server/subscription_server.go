@@ -6,12 +6,15 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
 	httplib "github.com/xataio/pgstream/internal/http"
 	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue"
+	deliverystore "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/store"
 )
@@ -21,6 +24,11 @@ type Server struct {
 	logger  loglib.Logger
 	store   store.Store
 	address string
+	// deliveryStore and retryQueue are used by the delivery endpoints, which
+	// are not available when they're not set.
+	deliveryStore deliverystore.Store
+	retryQueue    queue.Queue
+	clock         func() time.Time
 }
 
 type Option func(*Server)
@@ -30,6 +38,7 @@ func New(cfg *Config, store store.Store, opts ...Option) *Server {
 		address: cfg.address(),
 		store:   store,
 		logger:  loglib.NewNoopLogger(),
+		clock:   time.Now,
 	}
 
 	e := echo.New()
@@ -41,6 +50,8 @@ func New(cfg *Config, store store.Store, opts ...Option) *Server {
 
 	e.POST("/webhooks/subscribe", s.subscribe)
 	e.POST("/webhooks/unsubscribe", s.unsubscribe)
+	e.GET("/webhooks/deliveries", s.listDeliveries)
+	e.POST("/webhooks/deliveries/:id/replay", s.replayDelivery)
 
 	s.server = e
 
@@ -59,6 +70,15 @@ func WithLogger(l loglib.Logger) Option {
 	}
 }
 
+// WithDeliveryLog enables the delivery endpoints, listing the delivery
+// attempts in the store on input, and replaying them through the retry queue.
+func WithDeliveryLog(s deliverystore.Store, q queue.Queue) Option {
+	return func(srv *Server) {
+		srv.deliveryStore = s
+		srv.retryQueue = q
+	}
+}
+
 // Start will start the subscription server. This call is blocking.
 func (s *Server) Start() error {
 	s.logger.Info(fmt.Sprintf("subscription server listening on: %s...", s.address))
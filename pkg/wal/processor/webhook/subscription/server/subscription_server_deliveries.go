@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	deliverystore "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
+)
+
+type deliveriesResponse struct {
+	Deliveries []*delivery.Attempt `json:"deliveries"`
+	// NextCursor is the cursor to get the next page of deliveries, not set on
+	// the last page.
+	NextCursor int64 `json:"next_cursor,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+const (
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+
+	deliveryStatusSuccess = "success"
+	deliveryStatusFailed  = "failed"
+)
+
+var (
+	errDeliveryLogDisabled  = errors.New("delivery log not enabled")
+	errSubscriptionNotFound = errors.New("subscription not found")
+)
+
+// listDeliveries returns the delivery attempts matching the query parameter
+// filters, from the most recent one.
+func (s *Server) listDeliveries(c echo.Context) error {
+	if s.deliveryStore == nil {
+		return c.JSON(http.StatusNotImplemented, errorResponse{Error: errDeliveryLogDisabled.Error()})
+	}
+
+	s.logger.Trace("request received on /deliveries endpoint")
+
+	filter, err := parseDeliveriesFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+	}
+
+	attempts, err := s.deliveryStore.ListAttempts(c.Request().Context(), filter)
+	if err != nil {
+		s.logger.Error(err, "listing webhook deliveries")
+		return c.JSON(http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+	}
+
+	resp := deliveriesResponse{Deliveries: attempts}
+	if len(attempts) > 0 && uint(len(attempts)) == filter.Limit {
+		resp.NextCursor = attempts[len(attempts)-1].ID
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// replayDelivery sends the payload of the delivery attempt again, by adding it
+// to the retry queue. It's signed with the current secret of its subscription
+// when it's sent.
+func (s *Server) replayDelivery(c echo.Context) error {
+	if s.deliveryStore == nil || s.retryQueue == nil {
+		return c.JSON(http.StatusNotImplemented, errorResponse{Error: errDeliveryLogDisabled.Error()})
+	}
+
+	s.logger.Trace("request received on /deliveries/:id/replay endpoint")
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid delivery id: %v", err)})
+	}
+
+	ctx := c.Request().Context()
+	attempt, err := s.deliveryStore.GetAttempt(ctx, id)
+	if err != nil {
+		if errors.Is(err, deliverystore.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+	}
+
+	if _, err := s.getSubscription(c, attempt); err != nil {
+		if errors.Is(err, errSubscriptionNotFound) {
+			return c.JSON(http.StatusNotFound, errorResponse{Error: err.Error()})
+		}
+		return c.JSON(http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+	}
+
+	now := s.clock()
+	if err := s.retryQueue.Enqueue(ctx, &delivery.Delivery{
+		URL:            attempt.URL,
+		Schema:         attempt.Schema,
+		Table:          attempt.Table,
+		Payload:        attempt.Payload,
+		CommitPosition: attempt.CommitPosition,
+		CreatedAt:      now,
+		NextAttemptAt:  now,
+	}); err != nil {
+		s.logger.Error(err, "adding replayed webhook delivery to retry queue", loglib.Fields{"delivery_id": id})
+		return c.JSON(http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+	}
+
+	return c.JSON(http.StatusAccepted, nil)
+}
+
+// getSubscription returns the subscription the delivery attempt was sent to.
+func (s *Server) getSubscription(c echo.Context, attempt *delivery.Attempt) (*subscription.Subscription, error) {
+	subscriptions, err := s.store.GetSubscriptions(c.Request().Context(), "", attempt.Schema, attempt.Table)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving subscriptions: %w", err)
+	}
+	for _, sub := range subscriptions {
+		if sub.URL == attempt.URL && sub.Schema == attempt.Schema && sub.Table == attempt.Table {
+			return sub, nil
+		}
+	}
+	return nil, errSubscriptionNotFound
+}
+
+func parseDeliveriesFilter(c echo.Context) (*deliverystore.Filter, error) {
+	filter := &deliverystore.Filter{
+		URL:            c.QueryParam("url"),
+		Schema:         c.QueryParam("schema"),
+		Table:          c.QueryParam("table"),
+		CommitPosition: c.QueryParam("commit_position"),
+		Limit:          defaultDeliveriesLimit,
+	}
+
+	switch status := c.QueryParam("status"); status {
+	case "":
+	case deliveryStatusSuccess, deliveryStatusFailed:
+		failed := status == deliveryStatusFailed
+		filter.Failed = &failed
+	default:
+		return nil, fmt.Errorf("invalid status %q, must be one of %s or %s", status, deliveryStatusSuccess, deliveryStatusFailed)
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(c, "from"); err != nil {
+		return nil, err
+	}
+	if filter.To, err = parseTimeParam(c, "to"); err != nil {
+		return nil, err
+	}
+
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		filter.BeforeID, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil || filter.BeforeID <= 0 {
+			return nil, fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		l, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil || l == 0 {
+			return nil, fmt.Errorf("invalid limit %q", limit)
+		}
+		filter.Limit = uint(min(l, maxDeliveriesLimit))
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam parses the RFC3339 timestamp query parameter, returning the
+// zero time if it's not set.
+func parseTimeParam(c echo.Context, name string) (time.Time, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s timestamp %q, must be RFC3339: %w", name, value, err)
+	}
+	return t, nil
+}
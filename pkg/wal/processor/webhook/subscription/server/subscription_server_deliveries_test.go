@@ -0,0 +1,291 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery"
+	queuemocks "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/queue/mocks"
+	deliverystore "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store"
+	deliverymocks "github.com/xataio/pgstream/pkg/wal/processor/webhook/delivery/store/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/store/mocks"
+)
+
+func TestSubscriptionServer_listDeliveries(t *testing.T) {
+	t.Parallel()
+
+	testAttempts := []*delivery.Attempt{
+		{ID: 5, URL: "url-1", StatusCode: http.StatusOK},
+		{ID: 3, URL: "url-1", StatusCode: http.StatusInternalServerError, Error: "oh noes"},
+	}
+	failed := true
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name          string
+		deliveryStore deliverystore.Store
+		query         string
+
+		wantStatusCode int
+		wantResponse   *deliveriesResponse
+	}{
+		{
+			name: "ok - default filter",
+			deliveryStore: &deliverymocks.Store{
+				ListAttemptsFn: func(ctx context.Context, filter *deliverystore.Filter) ([]*delivery.Attempt, error) {
+					require.Equal(t, &deliverystore.Filter{Limit: defaultDeliveriesLimit}, filter)
+					return testAttempts, nil
+				},
+			},
+
+			wantStatusCode: http.StatusOK,
+			wantResponse:   &deliveriesResponse{Deliveries: testAttempts},
+		},
+		{
+			name: "ok - with filters and next page",
+			deliveryStore: &deliverymocks.Store{
+				ListAttemptsFn: func(ctx context.Context, filter *deliverystore.Filter) ([]*delivery.Attempt, error) {
+					require.Equal(t, &deliverystore.Filter{
+						URL:            "url-1",
+						Schema:         "test_schema",
+						Table:          "test_table",
+						CommitPosition: "0/1",
+						Failed:         &failed,
+						From:           time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+						To:             time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC),
+						BeforeID:       10,
+						Limit:          2,
+					}, filter)
+					return testAttempts, nil
+				},
+			},
+			query: "url=url-1&schema=test_schema&table=test_table&commit_position=0/1&status=failed" +
+				"&from=2024-05-01T10:00:00Z&to=2024-05-02T10:00:00Z&cursor=10&limit=2",
+
+			wantStatusCode: http.StatusOK,
+			wantResponse:   &deliveriesResponse{Deliveries: testAttempts, NextCursor: 3},
+		},
+		{
+			name: "ok - limit capped",
+			deliveryStore: &deliverymocks.Store{
+				ListAttemptsFn: func(ctx context.Context, filter *deliverystore.Filter) ([]*delivery.Attempt, error) {
+					require.Equal(t, uint(maxDeliveriesLimit), filter.Limit)
+					return []*delivery.Attempt{}, nil
+				},
+			},
+			query: "limit=5000",
+
+			wantStatusCode: http.StatusOK,
+			wantResponse:   &deliveriesResponse{Deliveries: []*delivery.Attempt{}},
+		},
+		{
+			name:  "error - invalid status",
+			query: "status=unknown",
+
+			deliveryStore:  &deliverymocks.Store{},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "error - invalid timestamp",
+			query: "from=yesterday",
+
+			deliveryStore:  &deliverymocks.Store{},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "error - invalid cursor",
+			query: "cursor=-1",
+
+			deliveryStore:  &deliverymocks.Store{},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "error - listing attempts",
+			deliveryStore: &deliverymocks.Store{
+				ListAttemptsFn: func(ctx context.Context, filter *deliverystore.Filter) ([]*delivery.Attempt, error) {
+					return nil, errTest
+				},
+			},
+
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name: "error - delivery log not enabled",
+
+			wantStatusCode: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := &Server{
+				logger:        log.NewNoopLogger(),
+				deliveryStore: tc.deliveryStore,
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/webhooks/deliveries?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			echoCtx := echo.New().NewContext(req, w)
+
+			server.listDeliveries(echoCtx)
+			require.Equal(t, tc.wantStatusCode, w.Result().StatusCode)
+			if tc.wantResponse != nil {
+				resp := &deliveriesResponse{}
+				require.NoError(t, json.NewDecoder(w.Body).Decode(resp))
+				require.Equal(t, tc.wantResponse, resp)
+			}
+		})
+	}
+}
+
+func TestSubscriptionServer_replayDelivery(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	testAttempt := &delivery.Attempt{
+		ID:             1,
+		URL:            "url-1",
+		Schema:         "test_schema",
+		Table:          "test_table",
+		CommitPosition: "0/1",
+		Payload:        []byte("test payload"),
+	}
+	testSubscription := &subscription.Subscription{
+		URL:    "url-1",
+		Schema: "test_schema",
+		Table:  "test_table",
+		Secret: "secret",
+	}
+	getAttempt := func(ctx context.Context, id int64) (*delivery.Attempt, error) {
+		require.Equal(t, int64(1), id)
+		return testAttempt, nil
+	}
+	getSubscriptions := func(ctx context.Context, action, schema, table string) ([]*subscription.Subscription, error) {
+		require.Equal(t, "", action)
+		require.Equal(t, "test_schema", schema)
+		require.Equal(t, "test_table", table)
+		// wildcard subscription for the same url
+		return []*subscription.Subscription{{URL: "url-1"}, testSubscription}, nil
+	}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name          string
+		id            string
+		deliveryStore deliverystore.Store
+		store         *mocks.Store
+		retryQueue    *queuemocks.Queue
+
+		wantStatusCode int
+	}{
+		{
+			name:          "ok",
+			id:            "1",
+			deliveryStore: &deliverymocks.Store{GetAttemptFn: getAttempt},
+			store:         &mocks.Store{GetSubscriptionsFn: getSubscriptions},
+			retryQueue: &queuemocks.Queue{
+				EnqueueFn: func(ctx context.Context, d *delivery.Delivery) error {
+					require.Equal(t, &delivery.Delivery{
+						URL:            "url-1",
+						Schema:         "test_schema",
+						Table:          "test_table",
+						Payload:        []byte("test payload"),
+						CommitPosition: "0/1",
+						CreatedAt:      now,
+						NextAttemptAt:  now,
+					}, d)
+					return nil
+				},
+			},
+
+			wantStatusCode: http.StatusAccepted,
+		},
+		{
+			name:           "error - invalid id",
+			id:             "one",
+			deliveryStore:  &deliverymocks.Store{},
+			retryQueue:     &queuemocks.Queue{},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name: "error - attempt not found",
+			id:   "1",
+			deliveryStore: &deliverymocks.Store{
+				GetAttemptFn: func(ctx context.Context, id int64) (*delivery.Attempt, error) {
+					return nil, deliverystore.ErrNotFound
+				},
+			},
+			retryQueue:     &queuemocks.Queue{},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:          "error - subscription not found",
+			id:            "1",
+			deliveryStore: &deliverymocks.Store{GetAttemptFn: getAttempt},
+			store: &mocks.Store{
+				GetSubscriptionsFn: func(ctx context.Context, action, schema, table string) ([]*subscription.Subscription, error) {
+					return []*subscription.Subscription{{URL: "url-2", Schema: "test_schema", Table: "test_table"}}, nil
+				},
+			},
+			retryQueue:     &queuemocks.Queue{},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:          "error - enqueueing delivery",
+			id:            "1",
+			deliveryStore: &deliverymocks.Store{GetAttemptFn: getAttempt},
+			store:         &mocks.Store{GetSubscriptionsFn: getSubscriptions},
+			retryQueue: &queuemocks.Queue{
+				EnqueueFn: func(ctx context.Context, d *delivery.Delivery) error {
+					return errTest
+				},
+			},
+			wantStatusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "error - delivery log not enabled",
+			id:             "1",
+			wantStatusCode: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := &Server{
+				logger:        log.NewNoopLogger(),
+				store:         tc.store,
+				deliveryStore: tc.deliveryStore,
+				clock:         func() time.Time { return now },
+			}
+			if tc.retryQueue != nil {
+				server.retryQueue = tc.retryQueue
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/deliveries/"+tc.id+"/replay", nil)
+			w := httptest.NewRecorder()
+			echoCtx := echo.New().NewContext(req, w)
+			echoCtx.SetParamNames("id")
+			echoCtx.SetParamValues(tc.id)
+
+			server.replayDelivery(echoCtx)
+			require.Equal(t, tc.wantStatusCode, w.Result().StatusCode)
+		})
+	}
+}
@@ -115,13 +115,16 @@ func (s *Store) refresh(ctx context.Context) error {
 	defer s.cacheLock.Unlock()
 
 	s.cache = make(map[string]*subscription.Subscription, len(subscriptions))
+	keys := make([]string, 0, len(subscriptions))
 	for _, subscription := range subscriptions {
 		s.cache[subscription.Key()] = subscription
+		keys = append(keys, subscription.Key())
 	}
 
+	// the subscriptions are not logged to avoid leaking their secrets
 	s.logger.Debug("cache refreshed", loglib.Fields{
 		"subscription_total_count": len(s.cache),
-		"subscriptions":            s.cache,
+		"subscriptions":            keys,
 	})
 
 	return nil
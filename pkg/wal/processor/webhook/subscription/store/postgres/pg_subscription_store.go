@@ -51,9 +51,9 @@ func WithLogger(l loglib.Logger) Option {
 
 func (s *Store) CreateSubscription(ctx context.Context, subscription *subscription.Subscription) error {
 	query := fmt.Sprintf(`
-	INSERT INTO %s(url, schema_name, table_name, event_types) VALUES($1, $2, $3, $4)
-	ON CONFLICT (url,schema_name,table_name) DO UPDATE SET event_types = EXCLUDED.event_types;`, subscriptionsTable)
-	_, err := s.conn.Exec(ctx, query, subscription.URL, subscription.Schema, subscription.Table, subscription.EventTypes)
+	INSERT INTO %s(url, schema_name, table_name, event_types, secret) VALUES($1, $2, $3, $4, $5)
+	ON CONFLICT (url,schema_name,table_name) DO UPDATE SET event_types = EXCLUDED.event_types, secret = EXCLUDED.secret;`, subscriptionsTable)
+	_, err := s.conn.Exec(ctx, query, subscription.URL, subscription.Schema, subscription.Table, subscription.EventTypes, subscription.Secret)
 	return err
 }
 
@@ -78,7 +78,7 @@ func (s *Store) GetSubscriptions(ctx context.Context, action, schema, table stri
 	subscriptions := []*subscription.Subscription{}
 	for rows.Next() {
 		subscription := &subscription.Subscription{}
-		if err := rows.Scan(&subscription.URL, &subscription.Schema, &subscription.Table, &subscription.EventTypes); err != nil {
+		if err := rows.Scan(&subscription.URL, &subscription.Schema, &subscription.Table, &subscription.EventTypes, &subscription.Secret); err != nil {
 			return nil, fmt.Errorf("scanning subscription row: %w", err)
 		}
 
@@ -94,13 +94,21 @@ func (s *Store) createTable(ctx context.Context) error {
 	schema_name TEXT,
 	table_name TEXT,
 	event_types TEXT[],
+	secret TEXT NOT NULL DEFAULT '',
 	PRIMARY KEY(url,schema_name,table_name))`, subscriptionsTable)
+	if _, err := s.conn.Exec(ctx, query); err != nil {
+		return err
+	}
+
+	// tables created before the subscriptions could be signed don't have the
+	// secret column
+	query = fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS secret TEXT NOT NULL DEFAULT ''`, subscriptionsTable)
 	_, err := s.conn.Exec(ctx, query)
 	return err
 }
 
 func (s *Store) buildGetQuery(action, schema, table string) (string, []any) {
-	query := fmt.Sprintf(`SELECT url, schema_name, table_name, event_types FROM %s`, subscriptionsTable)
+	query := fmt.Sprintf(`SELECT url, schema_name, table_name, event_types, secret FROM %s`, subscriptionsTable)
 
 	separator := func(params []any) string {
 		if len(params) == 0 {
@@ -23,25 +23,25 @@ func TestStore_buildGetQuery(t *testing.T) {
 	}{
 		{
 			name:       "no filters",
-			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types FROM %s LIMIT 1000`, subscriptionsTable),
+			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types, secret FROM %s LIMIT 1000`, subscriptionsTable),
 			wantParams: nil,
 		},
 		{
 			name:       "with action filter",
 			action:     "I",
-			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types FROM %s WHERE ($1=ANY(event_types) OR event_types IS NULL) LIMIT 1000`, subscriptionsTable),
+			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types, secret FROM %s WHERE ($1=ANY(event_types) OR event_types IS NULL) LIMIT 1000`, subscriptionsTable),
 			wantParams: []any{"I"},
 		},
 		{
 			name:       "with schema filter",
 			schema:     "test_schema",
-			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types FROM %s WHERE (schema_name=$1 OR schema_name='') LIMIT 1000`, subscriptionsTable),
+			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types, secret FROM %s WHERE (schema_name=$1 OR schema_name='') LIMIT 1000`, subscriptionsTable),
 			wantParams: []any{"test_schema"},
 		},
 		{
 			name:       "with table filter",
 			table:      "test_table",
-			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types FROM %s WHERE (table_name=$1 OR table_name='') LIMIT 1000`, subscriptionsTable),
+			wantQuery:  fmt.Sprintf(`SELECT url, schema_name, table_name, event_types, secret FROM %s WHERE (table_name=$1 OR table_name='') LIMIT 1000`, subscriptionsTable),
 			wantParams: []any{"test_table"},
 		},
 		{
@@ -49,7 +49,7 @@ func TestStore_buildGetQuery(t *testing.T) {
 			action: "I",
 			schema: "test_schema",
 			table:  "test_table",
-			wantQuery: fmt.Sprintf(`SELECT url, schema_name, table_name, event_types FROM %s `, subscriptionsTable) +
+			wantQuery: fmt.Sprintf(`SELECT url, schema_name, table_name, event_types, secret FROM %s `, subscriptionsTable) +
 				"WHERE (schema_name=$1 OR schema_name='') " +
 				"AND (table_name=$2 OR table_name='') " +
 				"AND ($3=ANY(event_types) OR event_types IS NULL) LIMIT 1000",
@@ -12,6 +12,9 @@ type Subscription struct {
 	EventTypes []string `json:"event_types"`
 	Schema     string   `json:"schema"`
 	Table      string   `json:"table"`
+	// Secret is used to sign the webhook deliveries of the subscription. The
+	// deliveries are not signed when empty.
+	Secret string `json:"secret,omitempty"`
 }
 
 func (s *Subscription) IsFor(action, schema, table string) bool {
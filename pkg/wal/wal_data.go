@@ -41,6 +41,9 @@ type Metadata struct {
 	// This is the Pgstream ID of the "version" column. We track this specifically, as we extract it from the event
 	// in order to use as the version when working with optimistic concurrency checks.
 	InternalColVersion string `json:"version_col_pgstream_id"`
+	// EnumColIDs are the Pgstream IDs of the columns with an enum type, which
+	// can't be identified from the column type name.
+	EnumColIDs []string `json:"enum_col_pgstream_ids,omitempty"`
 }
 
 type Column struct {
@@ -86,5 +89,10 @@ func (m Metadata) IsIDColumn(colID string) bool {
 	return slices.Contains(m.InternalColIDs, colID)
 }
 
+// IsEnumColumn returns true if the column id on input has an enum type.
+func (m Metadata) IsEnumColumn(colID string) bool {
+	return slices.Contains(m.EnumColIDs, colID)
+}
+
 // CommitPosition represents a position in the input stream
 type CommitPosition string
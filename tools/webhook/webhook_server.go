@@ -13,13 +13,22 @@ import (
 	"github.com/xataio/pgstream/internal/log/zerolog"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook"
+	"github.com/xataio/pgstream/pkg/wal/processor/webhook/signature"
 )
 
-var logger loglib.Logger
+var (
+	logger loglib.Logger
+	// secret is used to verify the signature of the webhook requests. The
+	// signatures are not verified when empty.
+	secret    string
+	tolerance time.Duration
+)
 
 func main() {
 	address := flag.String("address", ":9910", "Webhook server address")
 	logLevel := flag.String("log-level", "debug", "Webhook server log level")
+	flag.StringVar(&secret, "secret", "", "Webhook subscription secret used to verify the request signatures")
+	flag.DurationVar(&tolerance, "signature-tolerance", signature.DefaultTolerance, "Max age of the signed webhook requests")
 	flag.Parse()
 
 	logger = zerolog.NewStdLogger(zerolog.NewLogger(&zerolog.Config{
@@ -51,6 +60,14 @@ func processWebhook(w http.ResponseWriter, r *http.Request) {
 
 	logger.Debug("got /webhook request")
 
+	if secret != "" {
+		if _, err := signature.VerifyRequest(r, secret, tolerance); err != nil {
+			logger.Warn(err, "invalid webhook request signature")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
 	payload := webhook.Payload{}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		w.WriteHeader(http.StatusBadRequest)